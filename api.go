@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// coreServiceName is the gRPC service of the core built into the CLI
+const coreServiceName = "OrcaCore"
+
+// coreAPI is the services `orca api` can call, as reflected from the core or as
+// described by the protobufs the CLI was built with
+type coreAPI struct {
+	services []protoreflect.ServiceDescriptor
+	// reflected is set when the services were read from the core
+	reflected bool
+}
+
+// builtinCoreAPI returns the API of the core this CLI was built with
+func builtinCoreAPI() coreAPI {
+	return coreAPI{services: []protoreflect.ServiceDescriptor{pb.File_service_proto.Services().ByName(coreServiceName)}}
+}
+
+// loadCoreAPI reflects the API of the core, falling back to the API the CLI was
+// built with when the core does not offer reflection
+func loadCoreAPI(ctx context.Context, conn grpc.ClientConnInterface, reflect bool) (coreAPI, error) {
+	if !reflect {
+		return builtinCoreAPI(), nil
+	}
+	api, err := reflectCoreAPI(ctx, conn)
+	if err != nil {
+		return builtinCoreAPI(), err
+	}
+	return api, nil
+}
+
+func (a coreAPI) methods() []protoreflect.MethodDescriptor {
+	var methods []protoreflect.MethodDescriptor
+	for _, service := range a.services {
+		for ii := range service.Methods().Len() {
+			methods = append(methods, service.Methods().Get(ii))
+		}
+	}
+	return methods
+}
+
+// methodNames returns the names of the methods, qualified by their service when
+// several services are offered
+func (a coreAPI) methodNames() []string {
+	var names []string
+	for _, method := range a.methods() {
+		if len(a.services) > 1 {
+			names = append(names, fmt.Sprintf("%s/%s", method.Parent().Name(), method.Name()))
+		} else {
+			names = append(names, string(method.Name()))
+		}
+	}
+	return names
+}
+
+// findMethod looks a method up by name, given as Expose, OrcaCore/Expose or
+// /OrcaCore/Expose in any case. Services may be given by their full name.
+func (a coreAPI) findMethod(name string) (protoreflect.MethodDescriptor, error) {
+	service, method, qualified := strings.Cut(strings.TrimPrefix(name, "/"), "/")
+	if !qualified {
+		service, method = "", service
+	}
+	var matches []protoreflect.MethodDescriptor
+	for _, candidate := range a.methods() {
+		parent := candidate.Parent()
+		if service != "" && !strings.EqualFold(string(parent.Name()), service) && !strings.EqualFold(string(parent.FullName()), service) {
+			continue
+		}
+		if strings.EqualFold(string(candidate.Name()), method) {
+			matches = append(matches, candidate)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("unknown method %q, must be one of: %s", name, strings.Join(a.methodNames(), ", "))
+	case 1:
+		return matches[0], nil
+	}
+	return nil, fmt.Errorf("method %q is offered by several services, name it as <service>/%s", name, matches[0].Name())
+}
+
+// coreMethodNames returns the names of the methods of the core this CLI was built
+// with, for completion, which does not contact the core
+func coreMethodNames() []string {
+	return builtinCoreAPI().methodNames()
+}
+
+// readAPIRequest reads the JSON request of `orca api`: given inline, from a file as
+// @path, or from stdin as -. No request is an empty message.
+func readAPIRequest(arg string, stdin io.Reader) ([]byte, error) {
+	switch {
+	case arg == "":
+		return []byte("{}"), nil
+	case arg == "-":
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the request from stdin: %w", err)
+		}
+		return data, nil
+	case strings.HasPrefix(arg, "@"):
+		data, err := os.ReadFile(arg[1:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the request: %w", err)
+		}
+		return data, nil
+	}
+	return []byte(arg), nil
+}
+
+// headerFlag collects repeated `-H "name: value"` flags, sent as gRPC metadata
+type headerFlag []string
+
+func (f *headerFlag) String() string { return strings.Join(*f, ", ") }
+
+func (f *headerFlag) Set(value string) error {
+	name, _, found := strings.Cut(value, ":")
+	if !found || strings.TrimSpace(name) == "" {
+		return fmt.Errorf(`expected "name: value", got %q`, value)
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// pairs returns the headers as alternating lowercase names and values, as taken by
+// metadata.AppendToOutgoingContext
+func (f headerFlag) pairs() []string {
+	var pairs []string
+	for _, header := range f {
+		name, value, _ := strings.Cut(header, ":")
+		pairs = append(pairs, strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value))
+	}
+	return pairs
+}
+
+// invokeCoreMethod calls an RPC of the core with a request given as JSON, returning
+// the response. Fields of the request are checked against the method's input message.
+// Headers are sent as metadata of ctx.
+func invokeCoreMethod(ctx context.Context, conn grpc.ClientConnInterface, method protoreflect.MethodDescriptor, requestJSON []byte) (proto.Message, error) {
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return nil, fmt.Errorf("%s is a streaming method, which orca api cannot call", method.Name())
+	}
+	request := dynamicpb.NewMessage(method.Input())
+	if err := protojson.Unmarshal(requestJSON, request); err != nil {
+		return nil, fmt.Errorf("invalid %s request: %w", method.Input().Name(), err)
+	}
+	response := dynamicpb.NewMessage(method.Output())
+	fullMethod := fmt.Sprintf("/%s/%s", method.Parent().FullName(), method.Name())
+	if err := conn.Invoke(ctx, fullMethod, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// printAPIResponse writes a response as indented JSON
+func printAPIResponse(w io.Writer, response proto.Message) error {
+	output, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to format the response: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(output))
+	return err
+}
+
+// printCoreMethods lists the methods of the core with their request and response
+// messages
+func printCoreMethods(w io.Writer, api coreAPI) {
+	rows := [][]string{{"SERVICE", "METHOD", "REQUEST", "RESPONSE"}}
+	for _, method := range api.methods() {
+		rows = append(rows, []string{
+			string(method.Parent().FullName()),
+			string(method.Name()),
+			string(method.Input().FullName()),
+			string(method.Output().FullName()),
+		})
+	}
+	printTable(w, rows)
+}
+
+// printMessageFields describes the fields of a message, and of the messages it
+// contains, for writing requests by hand
+func printMessageFields(w io.Writer, message protoreflect.MessageDescriptor) {
+	seen := map[protoreflect.FullName]bool{}
+	pending := []protoreflect.MessageDescriptor{message}
+	for len(pending) > 0 {
+		message, pending = pending[0], pending[1:]
+		if seen[message.FullName()] {
+			continue
+		}
+		seen[message.FullName()] = true
+
+		fmt.Fprintf(w, "\n%s:\n", message.FullName())
+		rows := [][]string{{"FIELD", "TYPE"}}
+		fields := message.Fields()
+		for ii := range fields.Len() {
+			field := fields.Get(ii)
+			kind := field.Kind().String()
+			switch {
+			case field.IsMap():
+				kind = fmt.Sprintf("map<%s, %s>", field.MapKey().Kind(), field.MapValue().Kind())
+			case field.Message() != nil:
+				kind = string(field.Message().FullName())
+				// well-known types such as Struct and Timestamp take their usual JSON forms
+				if field.Message().ParentFile().Package() != "google.protobuf" {
+					pending = append(pending, field.Message())
+				}
+			case field.Enum() != nil:
+				kind = string(field.Enum().FullName())
+			}
+			if field.IsList() {
+				kind = "repeated " + kind
+			}
+			rows = append(rows, []string{field.JSONName(), kind})
+		}
+		if len(rows) == 1 {
+			fmt.Fprintln(w, "(no fields)")
+			continue
+		}
+		printTable(w, rows)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+func TestFindCoreMethod(t *testing.T) {
+	for _, name := range []string{"Expose", "expose", "OrcaCore/Expose", "/OrcaCore/Expose"} {
+		method, err := builtinCoreAPI().findMethod(name)
+		if err != nil || method.Name() != "Expose" {
+			t.Errorf("findCoreMethod(%q) = %v, %v", name, method, err)
+		}
+	}
+	if _, err := builtinCoreAPI().findMethod("Delete"); err == nil || !strings.Contains(err.Error(), "RegisterProcessor, EmitWindow, Expose") {
+		t.Errorf("expected an unknown method to list the methods, got %v", err)
+	}
+	if _, err := builtinCoreAPI().findMethod("OrcaProcessor/HealthCheck"); err == nil {
+		t.Error("expected a method of another service to be rejected")
+	}
+}
+
+func TestInvokeCoreMethod(t *testing.T) {
+	core := &fakeCore{}
+	conn, _, err := dialCore(startFakeCore(t, core))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	register, _ := builtinCoreAPI().findMethod("RegisterProcessor")
+	request := []byte(`{"name": "stats", "runtime": "python3.12", "supportedAlgorithms": [{"name": "Mean", "version": "1.0.0", "resultType": "VALUE"}]}`)
+	response, err := invokeCoreMethod(ctx, conn, register, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := printAPIResponse(&out, response); err != nil {
+		t.Fatal(err)
+	}
+	var status struct{ Received bool }
+	if err := json.Unmarshal(out.Bytes(), &status); err != nil || !status.Received {
+		t.Errorf("RegisterProcessor answered %s", out.String())
+	}
+	if len(core.processors) != 1 || core.processors[0].GetSupportedAlgorithms()[0].GetResultType() != pb.ResultType_VALUE {
+		t.Errorf("the core received %v", core.processors)
+	}
+
+	expose, _ := builtinCoreAPI().findMethod("Expose")
+	if _, err := invokeCoreMethod(ctx, conn, expose, []byte(`{"unknown": 1}`)); err == nil || !strings.Contains(err.Error(), "invalid ExposeSettings request") {
+		t.Errorf("expected an unknown field to be rejected, got %v", err)
+	}
+}
+
+func TestReflectCoreAPI(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	core := &fakeCore{processors: []*pb.ProcessorRegistration{{Name: "stats"}}}
+	server := grpc.NewServer()
+	pb.RegisterOrcaCoreServer(server, core)
+	reflection.Register(server)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, _, err := dialCore(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var out bytes.Buffer
+	api, err := loadCoreAPI(ctx, conn, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !api.reflected || strings.Join(api.methodNames(), ",") != "RegisterProcessor,EmitWindow,Expose" {
+		t.Fatalf("reflected %v (reflected %v)", api.methodNames(), api.reflected)
+	}
+	// the reflected descriptors are independent of those compiled in, down to the imports
+	emit, err := api.findMethod("orcacore/emitwindow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if emit.Input() == pb.File_service_proto.Messages().ByName("Window") || emit.Input().Fields().ByJSONName("timeFrom").Message().FullName() != "google.protobuf.Timestamp" {
+		t.Errorf("unexpected request message %v", emit.Input())
+	}
+
+	expose, _ := api.findMethod("Expose")
+	response, err := invokeCoreMethod(ctx, conn, expose, []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	printAPIResponse(&out, response)
+	var state struct{ Processors []struct{ Name string } }
+	if err := json.Unmarshal(out.Bytes(), &state); err != nil || len(state.Processors) != 1 || state.Processors[0].Name != "stats" {
+		t.Errorf("Expose answered %s", out.String())
+	}
+
+	// a core without reflection is called with the API the CLI was built with
+	bare, _, err := dialCore(startFakeCore(t, core))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bare.Close()
+	api, err = loadCoreAPI(ctx, bare, true)
+	if err == nil || api.reflected || len(api.methods()) != 3 {
+		t.Errorf("expected reflection to fail over to the built-in API, got %v, %v", api.methodNames(), err)
+	}
+}
+
+func TestReadAPIRequest(t *testing.T) {
+	for _, tc := range []struct {
+		arg, stdin, want string
+	}{
+		{"", "", "{}"},
+		{`{"name": "stats"}`, "", `{"name": "stats"}`},
+		{"-", `{"origin": "stdin"}`, `{"origin": "stdin"}`},
+	} {
+		got, err := readAPIRequest(tc.arg, strings.NewReader(tc.stdin))
+		if err != nil || string(got) != tc.want {
+			t.Errorf("readAPIRequest(%q) = %s, %v, want %s", tc.arg, got, err, tc.want)
+		}
+	}
+	if _, err := readAPIRequest("@/does/not/exist.json", nil); err == nil {
+		t.Error("expected a missing request file to fail")
+	}
+}
+
+func TestHeaderFlag(t *testing.T) {
+	var headers headerFlag
+	if err := headers.Set("no colon"); err == nil {
+		t.Error("expected a header without a colon to be rejected")
+	}
+	headers.Set("Authorization: Bearer a:b")
+	headers.Set("x-tenant:acme")
+	want := []string{"authorization", "Bearer a:b", "x-tenant", "acme"}
+	if got := headers.pairs(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("pairs = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// BackupConfig sets how `orca backup` encrypts the archives it writes
+type BackupConfig struct {
+	// Age lists the age recipients archives are encrypted to, e.g. age1... or ssh-ed25519 keys
+	Age []string `json:"age,omitempty"`
+	// GPG lists the key ids or emails of the GPG recipients archives are encrypted to
+	GPG []string `json:"gpg,omitempty"`
+	// Identity is the age identity file `orca restore` decrypts with, relative to orca.json
+	Identity string `json:"identity,omitempty"`
+	// Endpoint is the S3-compatible service backups at s3:// locations are kept in,
+	// e.g. https://minio.example.com. AWS S3 is used when empty.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+func (c *BackupConfig) validate() error {
+	if c != nil && len(c.Age) > 0 && len(c.GPG) > 0 {
+		return fmt.Errorf("backup.age and backup.gpg cannot both be set, archives are encrypted with one of them")
+	}
+	return nil
+}
+
+// backup archives are tar files holding a manifest, then a gzipped tar of each data
+// volume named after its component, so that they restore into any stack project
+const (
+	backupManifestName = "manifest.json"
+	backupVolumeDir    = "volumes/"
+	backupFormat       = 1
+)
+
+type backupManifest struct {
+	Format  int    `json:"format"`
+	Created string `json:"created"`
+	// CLIVersion is the version of the CLI that wrote the archive
+	CLIVersion string `json:"cliVersion"`
+	// Components are the components whose data volumes are archived, in order
+	Components []string `json:"components"`
+}
+
+// backup encryptions, recognised on restore from the first bytes of an archive
+const (
+	backupEncryptionAge = "age"
+	backupEncryptionGPG = "gpg"
+)
+
+// backupExtension returns the extension of an archive written with an encryption
+func backupExtension(encryption string) string {
+	if encryption == "" {
+		return ".tar"
+	}
+	return ".tar." + encryption
+}
+
+// defaultBackupPath names an archive after the stack project and the time it was taken
+func defaultBackupPath(encryption string, now time.Time) string {
+	name := "orca-backup"
+	if stackProject != "" {
+		name += "-" + stackProject
+	}
+	return name + "-" + now.UTC().Format("20060102T150405Z") + backupExtension(encryption)
+}
+
+// encryptCommand returns the command encrypting stdin to stdout for the configured
+// recipients, and the encryption it applies
+func (c *BackupConfig) encryptCommand() (*exec.Cmd, string, error) {
+	if err := c.validate(); err != nil {
+		return nil, "", err
+	}
+	var args []string
+	var encryption string
+	switch {
+	case c != nil && len(c.Age) > 0:
+		encryption, args = backupEncryptionAge, []string{"--encrypt"}
+		for _, recipient := range c.Age {
+			args = append(args, "--recipient", recipient)
+		}
+	case c != nil && len(c.GPG) > 0:
+		encryption, args = backupEncryptionGPG, []string{"--batch", "--encrypt"}
+		for _, recipient := range c.GPG {
+			args = append(args, "--recipient", recipient)
+		}
+	default:
+		return nil, "", fmt.Errorf("-encrypt needs recipients, set them with `orca config set backup.age age1...` or `orca config set backup.gpg you@example.com`")
+	}
+	if _, err := exec.LookPath(encryption); err != nil {
+		return nil, "", fmt.Errorf("%s is not on your PATH, it is needed to encrypt backups to backup.%s", encryption, encryption)
+	}
+	return exec.Command(encryption, args...), encryption, nil
+}
+
+// detectBackupEncryption recognises an encrypted archive from its first bytes, which
+// are a tar header when it is not encrypted
+func detectBackupEncryption(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("age-encryption.org/")),
+		bytes.HasPrefix(header, []byte("-----BEGIN AGE ENCRYPTED FILE-----")):
+		return backupEncryptionAge
+	case bytes.HasPrefix(header, []byte("-----BEGIN PGP MESSAGE-----")):
+		return backupEncryptionGPG
+	case len(header) > 0 && header[0]&0x80 != 0:
+		// an OpenPGP packet, where tar headers start with a printable file name
+		return backupEncryptionGPG
+	}
+	return ""
+}
+
+// decryptCommand returns the command decrypting stdin to stdout. age decrypts with an
+// identity file, GPG with the keys of the user's keyring.
+func decryptCommand(encryption, identity string) (*exec.Cmd, error) {
+	if _, err := exec.LookPath(encryption); err != nil {
+		return nil, fmt.Errorf("the backup is encrypted with %s, which is not on your PATH", encryption)
+	}
+	if encryption == backupEncryptionGPG {
+		return exec.Command("gpg", "--quiet", "--decrypt"), nil
+	}
+	if identity == "" {
+		return nil, fmt.Errorf("the backup is encrypted with age, pass the identity to decrypt it with -identity, or set backup.identity in orca.json")
+	}
+	return exec.Command("age", "--decrypt", "--identity", identity), nil
+}
+
+// writeBackupArchive writes the manifest and each volume's data to w. Volumes are
+// exported to a temporary file first, as tar records the size of an entry up front.
+func writeBackupArchive(w io.Writer, manifest backupManifest, volumes []string, export func(volume string, w io.Writer) error) error {
+	archive := tar.NewWriter(w)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	modified, _ := time.Parse(time.RFC3339, manifest.Created)
+	if err := archive.WriteHeader(&tar.Header{Name: backupManifestName, Mode: 0644, Size: int64(len(data)), ModTime: modified}); err != nil {
+		return err
+	}
+	if _, err := archive.Write(data); err != nil {
+		return err
+	}
+
+	for ii, volumeName := range volumes {
+		if err := writeBackupVolume(archive, manifest.Components[ii], volumeName, modified, export); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func writeBackupVolume(archive *tar.Writer, component, volumeName string, modified time.Time, export func(volume string, w io.Writer) error) error {
+	temp, err := os.CreateTemp("", "orca-backup-*.tar.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	if err := export(volumeName, temp); err != nil {
+		return err
+	}
+	size, err := temp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := temp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	header := &tar.Header{Name: backupVolumeDir + component + ".tar.gz", Mode: 0644, Size: size, ModTime: modified}
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(archive, temp)
+	return err
+}
+
+// readBackupArchive reads the manifest of an archive, then passes the data of each
+// volume to restore with the component it belongs to
+func readBackupArchive(r io.Reader, restore func(component string, r io.Reader) error) (backupManifest, error) {
+	archive := tar.NewReader(r)
+	var manifest backupManifest
+	header, err := archive.Next()
+	if err != nil || header.Name != backupManifestName {
+		return manifest, fmt.Errorf("not an orca backup archive")
+	}
+	if err := json.NewDecoder(archive).Decode(&manifest); err != nil {
+		return manifest, fmt.Errorf("invalid backup manifest: %w", err)
+	}
+	if manifest.Format > backupFormat {
+		return manifest, fmt.Errorf("the backup was written by a newer CLI (%s), upgrade orca to restore it", manifest.CLIVersion)
+	}
+
+	restored := 0
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return manifest, fmt.Errorf("failed to read the backup: %w", err)
+		}
+		component, ok := strings.CutPrefix(header.Name, backupVolumeDir)
+		component, isVolume := strings.CutSuffix(component, ".tar.gz")
+		if !ok || !isVolume || !slices.Contains(manifest.Components, component) {
+			return manifest, fmt.Errorf("unexpected file %s in the backup", header.Name)
+		}
+		if err := restore(component, archive); err != nil {
+			return manifest, err
+		}
+		restored++
+	}
+	if restored != len(manifest.Components) {
+		return manifest, fmt.Errorf("the backup is incomplete: %d of %d volumes found", restored, len(manifest.Components))
+	}
+	return manifest, nil
+}
+
+// exportVolume writes the contents of a volume as a gzipped tar, using a throwaway
+// helper container
+func exportVolume(volumeName string, w io.Writer) error {
+	cmd := dockerCommand("run", "--rm", "-v", volumeName+":/from:ro", helperImage, "tar", "-czf", "-", "-C", "/from", ".")
+	var stderr bytes.Buffer
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to export volume %s: %w: %s", volumeName, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// importVolume extracts a gzipped tar into an empty volume
+func importVolume(volumeName string, r io.Reader) error {
+	cmd := dockerCommand("run", "--rm", "-i", "-v", volumeName+":/to", helperImage, "tar", "-xzf", "-", "-C", "/to")
+	var stderr bytes.Buffer
+	cmd.Stdin = r
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to import volume %s: %w: %s", volumeName, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// componentVolume returns the data volume of a component in the current stack
+func componentVolume(component string) (string, bool) {
+	for _, volumeName := range orcaVolumes {
+		if volumeComponent(volumeName) == component {
+			return volumeName, true
+		}
+	}
+	return "", false
+}
+
+// createBackup archives every stack volume to path, encrypted for the configured
+// recipients when encrypt is set. The archive is written next to path and renamed
+// once complete, so a failed backup never leaves a truncated file behind.
+func createBackup(path string, config *BackupConfig, encrypt bool) error {
+	var encryptor *exec.Cmd
+	if encrypt {
+		var err error
+		if encryptor, _, err = config.encryptCommand(); err != nil {
+			return err
+		}
+	}
+	for _, volumeName := range orcaVolumes {
+		if !volumeExists(volumeName) {
+			return fmt.Errorf("volume %s does not exist. Start the stack with `orca start` first", volumeName)
+		}
+	}
+
+	partial := path + ".partial"
+	file, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer os.Remove(partial)
+	defer file.Close()
+
+	running := pauseStack()
+	defer resumeStack(running)
+
+	manifest := backupManifest{Format: backupFormat, Created: time.Now().UTC().Format(time.RFC3339), CLIVersion: Version}
+	for _, volumeName := range orcaVolumes {
+		manifest.Components = append(manifest.Components, volumeComponent(volumeName))
+	}
+	export := func(volumeName string, w io.Writer) error {
+		fmt.Fprintf(os.Stderr, "Backing up %s... ", volumeName)
+		if err := exportVolume(volumeName, w); err != nil {
+			fmt.Fprintln(os.Stderr, renderError("FAILED"))
+			return err
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess("DONE"))
+		return nil
+	}
+
+	if encryptor == nil {
+		err = writeBackupArchive(file, manifest, orcaVolumes, export)
+	} else {
+		err = writeEncrypted(encryptor, file, func(w io.Writer) error {
+			return writeBackupArchive(w, manifest, orcaVolumes, export)
+		})
+	}
+	if err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(partial, path)
+}
+
+// writeEncrypted pipes what write produces through an encryption command into out
+func writeEncrypted(encryptor *exec.Cmd, out io.Writer, write func(w io.Writer) error) error {
+	var stderr bytes.Buffer
+	encryptor.Stdout = out
+	encryptor.Stderr = &stderr
+	stdin, err := encryptor.StdinPipe()
+	if err != nil {
+		return err
+	}
+	logDebug("+ %s", shellJoin(encryptor.Args))
+	if err := encryptor.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", encryptor.Args[0], err)
+	}
+	writeErr := write(stdin)
+	stdin.Close()
+	if err := encryptor.Wait(); err != nil {
+		return fmt.Errorf("%s failed to encrypt the backup: %s", encryptor.Args[0], lastLine(cmp.Or(strings.TrimSpace(stderr.String()), err.Error())))
+	}
+	return writeErr
+}
+
+// restoreBackup replaces the data of every stack volume with that of an archive. The
+// archive is decrypted and checked in full first, then each volume is extracted into
+// a staging volume, and the stack's volumes are only replaced once all of them have
+// been extracted, so a truncated or undecryptable archive leaves the data as it was.
+func restoreBackup(path, identity string) error {
+	archivePath, err := decryptBackup(path, identity)
+	if err != nil {
+		return err
+	}
+	if archivePath != path {
+		defer os.RemoveAll(filepath.Dir(archivePath))
+	}
+
+	fmt.Fprintf(os.Stderr, "Checking %s... ", filepath.Base(path))
+	if err := verifyBackup(archivePath); err != nil {
+		fmt.Fprintln(os.Stderr, renderError("FAILED"))
+		return err
+	}
+	fmt.Fprintln(os.Stderr, renderSuccess("OK"))
+
+	var staged []string
+	defer func() {
+		for _, staging := range staged {
+			dockerCommand("volume", "rm", staging).Run()
+		}
+	}()
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer file.Close()
+	manifest, err := readBackupArchive(file, func(component string, r io.Reader) error {
+		volumeName, ok := componentVolume(component)
+		if !ok {
+			return fmt.Errorf("the backup holds data of %s, which the stack has no volume for", component)
+		}
+		staging := stagingVolumeName(volumeName)
+		// a staging volume left by an interrupted restore holds partial data
+		dockerCommand("volume", "rm", staging).Run()
+		args := append([]string{"volume", "create"}, labelArgs(componentRestore)...)
+		if output, err := dockerCommand(append(args, staging)...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to create volume %s: %w: %s", staging, err, strings.TrimSpace(string(output)))
+		}
+		staged = append(staged, staging)
+
+		fmt.Fprintf(os.Stderr, "Extracting %s... ", component)
+		if err := importVolume(staging, r); err != nil {
+			fmt.Fprintln(os.Stderr, renderError("FAILED"))
+			return err
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess("DONE"))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	running := pauseStack()
+	defer resumeStack(running)
+
+	for _, component := range manifest.Components {
+		volumeName, _ := componentVolume(component)
+		if !volumeExists(volumeName) {
+			args := append([]string{"volume", "create"}, labelArgs(component)...)
+			if err := dockerCommand(append(args, volumeName)...).Run(); err != nil {
+				return fmt.Errorf("failed to create volume %s: %w", volumeName, err)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Restoring %s... ", volumeName)
+		if err := copyVolume(stagingVolumeName(volumeName), volumeName); err != nil {
+			fmt.Fprintln(os.Stderr, renderError("FAILED"))
+			return err
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess("DONE"))
+	}
+	return nil
+}
+
+// componentRestore labels the staging volumes `orca restore` extracts archives into
+const componentRestore = "restore"
+
+// stagingVolumeName names the volume a stack volume is extracted into before it is
+// replaced
+func stagingVolumeName(volumeName string) string {
+	return volumeName + "-restore"
+}
+
+// decryptBackup decrypts an encrypted archive into a private temporary directory,
+// returning the path of the decrypted archive, or path itself when it is not
+// encrypted. The decryption completes before anything is restored, so a wrong key
+// fails the restore up front.
+func decryptBackup(path, identity string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer file.Close()
+
+	source := bufio.NewReader(file)
+	header, _ := source.Peek(64)
+	encryption := detectBackupEncryption(header)
+	if encryption == "" {
+		return path, nil
+	}
+	decryptor, err := decryptCommand(encryption, identity)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := os.MkdirTemp("", "orca-restore-*")
+	if err != nil {
+		return "", err
+	}
+	decrypted, err := os.OpenFile(filepath.Join(dir, "backup.tar"), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	defer decrypted.Close()
+
+	var stderr bytes.Buffer
+	decryptor.Stdin = source
+	decryptor.Stdout = decrypted
+	decryptor.Stderr = &stderr
+	fmt.Fprintf(os.Stderr, "Decrypting %s with %s... ", filepath.Base(path), encryption)
+	logDebug("+ %s", shellJoin(decryptor.Args))
+	if err := decryptor.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, renderError("FAILED"))
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to decrypt the backup: %s", lastLine(cmp.Or(strings.TrimSpace(stderr.String()), err.Error())))
+	}
+	if err := decrypted.Close(); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	fmt.Fprintln(os.Stderr, renderSuccess("DONE"))
+	return decrypted.Name(), nil
+}
+
+// verifyBackup reads a whole archive, decompressing the data of each volume, so that
+// a truncated or corrupted archive is found before anything is replaced
+func verifyBackup(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer file.Close()
+	_, err = readBackupArchive(file, func(component string, r io.Reader) error {
+		if err := verifyVolumeArchive(r); err != nil {
+			return fmt.Errorf("the data of %s in the backup is corrupted: %w", component, err)
+		}
+		return nil
+	})
+	return err
+}
+
+// verifyVolumeArchive reads every entry of the gzipped tar of a volume, which fails
+// on a truncated stream or a gzip checksum mismatch
+func verifyVolumeArchive(r io.Reader) error {
+	decompressed, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	volume := tar.NewReader(decompressed)
+	for {
+		_, err := volume.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.Discard, volume); err != nil {
+			return err
+		}
+	}
+	// the gzip trailer, holding the checksum, follows the end of the tar
+	if _, err := io.Copy(io.Discard, decompressed); err != nil {
+		return err
+	}
+	return decompressed.Close()
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupArchive(t *testing.T) {
+	manifest := backupManifest{Format: backupFormat, Created: "2026-10-16T09:30:00Z", CLIVersion: "dev", Components: []string{componentPostgres, componentRedis}}
+	volumes := []string{"orca-pg-instance-data", "orca-redis-instance-data"}
+	var archive bytes.Buffer
+	err := writeBackupArchive(&archive, manifest, volumes, func(volume string, w io.Writer) error {
+		_, err := fmt.Fprintf(w, "data of %s", volume)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encryption := detectBackupEncryption(archive.Bytes()); encryption != "" {
+		t.Errorf("a plain archive was detected as encrypted with %s", encryption)
+	}
+
+	restored := map[string]string{}
+	read, err := readBackupArchive(bytes.NewReader(archive.Bytes()), func(component string, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		restored[component] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Created != manifest.Created || len(read.Components) != 2 {
+		t.Errorf("manifest = %+v", read)
+	}
+	if restored[componentPostgres] != "data of orca-pg-instance-data" || restored[componentRedis] != "data of orca-redis-instance-data" {
+		t.Errorf("restored %v", restored)
+	}
+
+	truncated := archive.Bytes()[:1536]
+	if _, err := readBackupArchive(bytes.NewReader(truncated), func(string, io.Reader) error { return nil }); err == nil {
+		t.Error("a truncated archive was restored")
+	}
+	if _, err := readBackupArchive(strings.NewReader("not an archive"), nil); err == nil || !strings.Contains(err.Error(), "not an orca backup") {
+		t.Errorf("reading garbage = %v", err)
+	}
+}
+
+// volumeArchive returns a gzipped tar holding a single file, as exportVolume writes
+func volumeArchive(t *testing.T, content string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	compressed := gzip.NewWriter(&buffer)
+	archive := tar.NewWriter(compressed)
+	archive.WriteHeader(&tar.Header{Name: "PG_VERSION", Mode: 0600, Size: int64(len(content))})
+	archive.Write([]byte(content))
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestRestoreBackupChecksFirst(t *testing.T) {
+	useFakeEngine(t)
+	if err := setStackProject(""); err != nil {
+		t.Fatal(err)
+	}
+	for _, volumeName := range orcaVolumes {
+		if err := dockerCommand("volume", "create", volumeName).Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manifest := backupManifest{Format: backupFormat, Created: "2026-10-16T09:30:00Z", CLIVersion: "dev"}
+	for _, volumeName := range orcaVolumes {
+		manifest.Components = append(manifest.Components, volumeComponent(volumeName))
+	}
+	var archive bytes.Buffer
+	err := writeBackupArchive(&archive, manifest, orcaVolumes, func(volume string, w io.Writer) error {
+		_, err := w.Write(volumeArchive(t, "data of "+volume))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	complete := filepath.Join(dir, "complete.tar")
+	os.WriteFile(complete, archive.Bytes(), 0600)
+	if err := verifyBackup(complete); err != nil {
+		t.Fatalf("verifyBackup of a complete archive: %v", err)
+	}
+
+	// cut within the data of the last volume, which is padded to a 512 byte block and
+	// followed by the two empty blocks ending the archive
+	truncated := filepath.Join(dir, "truncated.tar")
+	os.WriteFile(truncated, archive.Bytes()[:archive.Len()-1024-512+60], 0600)
+	if err := verifyBackup(truncated); err == nil {
+		t.Error("verifyBackup accepted a truncated archive")
+	}
+	if err := restoreBackup(truncated, ""); err == nil {
+		t.Fatal("restoreBackup restored a truncated archive")
+	}
+	for _, volumeName := range orcaVolumes {
+		if volumeExists(stagingVolumeName(volumeName)) {
+			t.Errorf("%s was left behind", stagingVolumeName(volumeName))
+		}
+	}
+
+	if err := restoreBackup(complete, ""); err != nil {
+		t.Fatalf("restoreBackup: %v", err)
+	}
+	for _, volumeName := range orcaVolumes {
+		if !volumeExists(volumeName) || volumeExists(stagingVolumeName(volumeName)) {
+			t.Errorf("after restoring, %s exists %v and its staging volume %v", volumeName, volumeExists(volumeName), volumeExists(stagingVolumeName(volumeName)))
+		}
+	}
+}
+
+func TestDetectBackupEncryption(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"age-encryption.org/v1\n-> X25519 ", backupEncryptionAge},
+		{"-----BEGIN AGE ENCRYPTED FILE-----\n", backupEncryptionAge},
+		{"-----BEGIN PGP MESSAGE-----\n", backupEncryptionGPG},
+		{"\x85\x01\x0c\x03", backupEncryptionGPG},
+		{"\xc1\xc0\x4c\x03", backupEncryptionGPG},
+		{"manifest.json\x00\x00\x00", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := detectBackupEncryption([]byte(test.header)); got != test.want {
+			t.Errorf("detectBackupEncryption(%q) = %q, want %q", test.header, got, test.want)
+		}
+	}
+}
+
+func TestBackupConfig(t *testing.T) {
+	if _, _, err := (*BackupConfig)(nil).encryptCommand(); err == nil || !strings.Contains(err.Error(), "backup.age") {
+		t.Errorf("encrypting without recipients = %v", err)
+	}
+
+	config := &OrcaConfigFile{}
+	if err := configKeys["backup.age"].Set(config, "age1abc, age1def"); err != nil {
+		t.Fatal(err)
+	}
+	if got := configKeys["backup.age"].Get(config); got != "age1abc,age1def" {
+		t.Errorf("backup.age = %q", got)
+	}
+	if err := configKeys["backup.gpg"].Set(config, "ops@example.com"); err == nil {
+		t.Error("set backup.gpg alongside backup.age succeeded")
+	}
+	if config.Backup.GPG != nil {
+		t.Errorf("a rejected value was written: %+v", config.Backup)
+	}
+
+	if got := backupExtension(backupEncryptionAge); got != ".tar.age" {
+		t.Errorf("backupExtension(age) = %q", got)
+	}
+}
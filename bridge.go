@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+// bridgeOrigin is the origin of windows forwarded by `orca bridge`, unless one is given
+const bridgeOrigin = "orca-bridge"
+
+// bridgeOptions configure the consumer of a message bus
+type bridgeOptions struct {
+	// Brokers is a comma separated list of host:port addresses
+	Brokers string
+	// Topics are consumed together into the same windows
+	Topics []string
+	// Group is the Kafka consumer group, which keeps the offsets between runs
+	Group         string
+	FromBeginning bool
+	// QoS is the MQTT quality of service level, 0 to 2
+	QoS int
+}
+
+// bridgeSource is a message bus `orca bridge` consumes from. Consumers run in a
+// container and print a message per line, so messages must be single line JSON.
+type bridgeSource struct {
+	Name          string
+	Description   string
+	Image         string
+	DefaultBroker string
+	// command returns the consumer command run in Image, for brokers already
+	// rewritten to be reachable from the container
+	command func(brokers []string, options bridgeOptions) []string
+}
+
+var bridgeSources = map[string]bridgeSource{
+	"kafka": {
+		Name:          "kafka",
+		Description:   "Consume Kafka topics in a consumer group",
+		Image:         "edenhill/kcat:1.7.1",
+		DefaultBroker: "localhost:9092",
+		command: func(brokers []string, options bridgeOptions) []string {
+			offset := "latest"
+			if options.FromBeginning {
+				offset = "earliest"
+			}
+			command := []string{
+				"-b", strings.Join(brokers, ","),
+				"-G", options.Group,
+				"-X", "auto.offset.reset=" + offset,
+				"-f", `%s\n`,
+				"-q", "-u",
+			}
+			return append(command, options.Topics...)
+		},
+	},
+	"mqtt": {
+		Name:          "mqtt",
+		Description:   "Subscribe to MQTT topics, which may use + and # wildcards",
+		Image:         "eclipse-mosquitto:2",
+		DefaultBroker: "localhost:1883",
+		command: func(brokers []string, options bridgeOptions) []string {
+			host, port, _ := net.SplitHostPort(brokers[0])
+			command := []string{"mosquitto_sub", "-h", host, "-p", port, "-q", strconv.Itoa(options.QoS)}
+			for _, topic := range options.Topics {
+				command = append(command, "-t", topic)
+			}
+			return command
+		},
+	},
+}
+
+// bridgeSourceNames returns the supported message buses, sorted
+func bridgeSourceNames() []string {
+	names := make([]string, 0, len(bridgeSources))
+	for name := range bridgeSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// containerBrokerAddresses splits a broker list and points addresses on this
+// machine at hostAlias, so that the consumer container can reach them
+func containerBrokerAddresses(brokers, hostAlias string) ([]string, error) {
+	var addresses []string
+	for _, broker := range strings.Split(brokers, ",") {
+		broker = strings.TrimSpace(broker)
+		if broker == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(broker)
+		if err != nil {
+			return nil, fmt.Errorf("invalid broker %q, expected host:port", broker)
+		}
+		if slices.Contains([]string{"", "localhost", "127.0.0.1", "::1", "0.0.0.0"}, host) {
+			host = hostAlias
+		}
+		addresses = append(addresses, net.JoinHostPort(host, port))
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no broker given")
+	}
+	return addresses, nil
+}
+
+// bridgeConsumerArgs returns the `docker run` arguments of the consumer container.
+// It joins the orca network when the stack is running, so that brokers started
+// alongside it are reachable by container name.
+func bridgeConsumerArgs(source bridgeSource, containerName string, brokers []string, options bridgeOptions, network string) []string {
+	args := []string{"run", "--rm", "--name", containerName, "--add-host", "host.docker.internal:host-gateway"}
+	if network != "" {
+		args = append(args, "--network", network)
+	}
+	args = append(args, labelArgs("bridge")...)
+	args = append(args, source.Image)
+	return append(args, source.command(brokers, options)...)
+}
+
+// startBridgeConsumer starts the consumer container of a message bus, returning
+// the command running it and the messages it prints. Stopping the command does
+// not stop the container, which is removed with stopBridgeConsumer.
+func startBridgeConsumer(ctx context.Context, source bridgeSource, options bridgeOptions) (*exec.Cmd, io.ReadCloser, string, error) {
+	brokers, err := containerBrokerAddresses(options.Brokers, detectContainerRuntime().HostAlias)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	network := ""
+	if getContainerStatus(orcaContainerName) == "running" {
+		network = networkName
+	}
+	containerName := fmt.Sprintf("%s-%d", namespaced("orca-bridge-"+source.Name), os.Getpid())
+
+	cmd := dockerCommandContext(ctx, bridgeConsumerArgs(source, containerName, brokers, options, network)...)
+	cmd.Stderr = os.Stderr
+	messages, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, "", fmt.Errorf("failed to start the %s consumer: %w", source.Name, err)
+	}
+	return cmd, messages, containerName, nil
+}
+
+// stopBridgeConsumer removes the consumer container, which also ends its messages
+func stopBridgeConsumer(containerName string) {
+	dockerCommand("rm", "-f", containerName).Run()
+}
+
+// runBridge forwards the messages of a consumer to the core through windows until
+// interrupted or the consumer stops
+func runBridge(client pb.OrcaCoreClient, source bridgeSource, options bridgeOptions, windows *windowImport) (importResult, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	consumer, messages, containerName, err := startBridgeConsumer(ctx, source, options)
+	if err != nil {
+		return importResult{}, err
+	}
+	fmt.Fprintf(os.Stderr, "\nForwarding messages from %s %s to the core. Press Ctrl+C to stop.\n\n", source.Name, strings.Join(options.Topics, ", "))
+
+	result, err := windows.run(client, newNDJSONRecordReader(messages))
+	interrupted := ctx.Err() != nil
+	stopBridgeConsumer(containerName)
+	// the consumer is stopped here when forwarding failed, so only its own failures
+	// are reported
+	if waitErr := consumer.Wait(); waitErr != nil && !interrupted && err == nil {
+		return result, fmt.Errorf("the %s consumer stopped: %w", source.Name, waitErr)
+	}
+	return result, err
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+// labels of processor images built by `orca build`, recording what they register
+const (
+	imageProjectLabel        = "orca.processor.project"
+	imageAlgorithmsLabel     = "orca.processor.algorithms"
+	imageAlgorithmsHashLabel = "orca.processor.algorithms-hash"
+)
+
+// imageRepositoryInvalid matches what docker does not allow in a repository name
+var imageRepositoryInvalid = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// processorImageRepository returns the default image repository of a project
+func processorImageRepository(projectName string) string {
+	repository := strings.Trim(imageRepositoryInvalid.ReplaceAllString(strings.ToLower(projectName), "-"), "-._")
+	if repository == "" {
+		return "orca-processor"
+	}
+	return repository
+}
+
+// processorRepository returns the image repository of the project's processor, set
+// by processor.image in orca.json, or named after the project
+func processorRepository(config *OrcaConfigFile) string {
+	if config.Processor != nil && config.Processor.Image != "" {
+		return config.Processor.Image
+	}
+	return processorImageRepository(config.ProjectName)
+}
+
+// projectAlgorithms returns the algorithms the project's processors registered, as
+// processor/name@version, and a hash of their registration. The hash covers the
+// algorithms, their window types, result types and dependencies, but not where the
+// processors run, so it only changes when what the image registers does.
+func projectAlgorithms(state *pb.InternalState, projectName string) ([]string, string, error) {
+	project := &pb.InternalState{}
+	var algorithms []string
+	for _, processor := range canonicalRegistry(state).GetProcessors() {
+		if processor.GetProjectName() != projectName {
+			continue
+		}
+		processor.ConnectionStr = ""
+		project.Processors = append(project.Processors, processor)
+		for _, algorithm := range processor.GetSupportedAlgorithms() {
+			algorithms = append(algorithms, fmt.Sprintf("%s/%s@%s", processor.GetName(), algorithm.GetName(), algorithm.GetVersion()))
+		}
+	}
+	if len(algorithms) == 0 {
+		return nil, "", nil
+	}
+	data, err := marshalCanonicalJSON(project)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	return algorithms, hex.EncodeToString(sum[:])[:12], nil
+}
+
+// fetchProjectAlgorithms fetches the registry and returns the project's algorithms
+// and their hash, as for projectAlgorithms
+func fetchProjectAlgorithms(coreAddress, projectName string, timeout time.Duration) ([]string, string, error) {
+	conn, client, err := dialCore(coreAddress)
+	if err != nil {
+		return nil, "", err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	state, err := exposeCompressed(ctx, client, &pb.ExposeSettings{})
+	if err != nil {
+		return nil, "", fmt.Errorf("issue contacting Orca: %w", err)
+	}
+	return projectAlgorithms(state, projectName)
+}
+
+const dockerfileHeader = `# Generated by orca build
+FROM python:3.12-slim
+WORKDIR /app
+`
+
+// generatedDockerfile returns a Dockerfile for the processor project, installing
+// its dependencies with its package manager before copying in the code, so that
+// code changes do not reinstall them
+func generatedDockerfile(config *OrcaConfigFile) (string, error) {
+	processor := config.Processor
+	if processor == nil {
+		processor = &ProcessorConfig{PackageManager: "pip"}
+	}
+	entrypoint := cmp.Or(processor.Entrypoint, defaultProcessorEntrypoint)
+
+	var dockerfile strings.Builder
+	dockerfile.WriteString(dockerfileHeader)
+	switch processor.PackageManager {
+	case "uv":
+		dockerfile.WriteString("COPY --from=ghcr.io/astral-sh/uv:latest /uv /usr/local/bin/uv\n")
+		dockerfile.WriteString("COPY pyproject.toml uv.lock* ./\n")
+		dockerfile.WriteString("RUN uv sync --no-install-project --no-dev\n")
+		dockerfile.WriteString("ENV PATH=\"/app/.venv/bin:$PATH\"\n")
+	case "poetry":
+		dockerfile.WriteString("RUN pip install --no-cache-dir poetry\n")
+		dockerfile.WriteString("ENV POETRY_VIRTUALENVS_IN_PROJECT=true\n")
+		dockerfile.WriteString("COPY pyproject.toml poetry.lock* ./\n")
+		dockerfile.WriteString("RUN poetry install --no-root --only main\n")
+		dockerfile.WriteString("ENV PATH=\"/app/.venv/bin:$PATH\"\n")
+	case "pip", "":
+		dockerfile.WriteString("COPY requirements.txt ./\n")
+		dockerfile.WriteString("RUN pip install --no-cache-dir -r requirements.txt\n")
+	default:
+		return "", fmt.Errorf("invalid package manager %q in orca.json, must be one of: %s", processor.PackageManager, strings.Join(packageManagers, ", "))
+	}
+	dockerfile.WriteString("COPY . .\n")
+	if config.ProcessorPort > 0 {
+		fmt.Fprintf(&dockerfile, "ENV PROCESSOR_PORT=%d\n", config.ProcessorPort)
+		fmt.Fprintf(&dockerfile, "EXPOSE %d\n", config.ProcessorPort)
+	}
+	fmt.Fprintf(&dockerfile, "CMD [\"python\", %q]\n", entrypoint)
+	return dockerfile.String(), nil
+}
+
+// generatedDockerignore keeps local environments and caches out of the build context
+const generatedDockerignore = `.venv
+__pycache__
+*.pyc
+.git
+.orca
+`
+
+// projectDockerfile returns the Dockerfile in the project directory, or "" when the
+// project has none and one is generated
+func projectDockerfile(dir string) string {
+	path := filepath.Join(dir, "Dockerfile")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// buildFlags are the options shared by `orca build` and `orca push`
+type buildFlags struct {
+	configPath  *string
+	dockerfile  *string
+	repository  *string
+	tags        *string
+	platforms   *string
+	coreAddress *string
+	timeout     *time.Duration
+	noCache     *bool
+}
+
+// addBuildFlags adds the options of building a processor image to a command
+func addBuildFlags(fs *flag.FlagSet) *buildFlags {
+	options := &buildFlags{
+		configPath:  fs.String("config", findProjectConfig(), "Path to orca.json configuration file of the processor project"),
+		dockerfile:  fs.String("dockerfile", "", "Dockerfile to build with (defaults to the project's Dockerfile, or one generated for its package manager)"),
+		repository:  fs.String("repository", "", "Image repository, e.g. ghcr.io/acme/speed (defaults to processor.image in orca.json, then the project name)"),
+		tags:        fs.String("tag", "", "Comma separated extra tags, as repository:tag or a bare tag of -repository"),
+		platforms:   fs.String("platform", "", "Comma separated platforms to build with buildx, e.g. linux/amd64,linux/arm64 (defaults to processor.platforms in orca.json)"),
+		coreAddress: fs.String("core", "", "Address of the Orca core to read the project's algorithms from (defaults to orca.json, then the local stack)"),
+		timeout:     fs.Duration("timeout", time.Second*10, "Timeout for fetching the registry from the core"),
+		noCache:     fs.Bool("no-cache", false, "Build without the docker layer cache"),
+	}
+	addGRPCFlags(fs)
+	return options
+}
+
+// newBuild resolves the build of the project's processor from the options and
+// orca.json, tagging it with the hash of the project's algorithms when the core
+// knows them
+func (o *buildFlags) newBuild(config *OrcaConfigFile) *processorBuild {
+	processor := config.Processor
+	if processor == nil {
+		processor = &ProcessorConfig{}
+	}
+	projectDir := filepath.Dir(*o.configPath)
+	build := &processorBuild{
+		Dir:        projectDir,
+		Dockerfile: cmp.Or(*o.dockerfile, projectDockerfile(projectDir)),
+		NoCache:    *o.noCache,
+		Platforms:  processor.Platforms,
+		Labels: map[string]string{
+			imageProjectLabel:                  config.ProjectName,
+			"org.opencontainers.image.title":   config.ProjectName,
+			"org.opencontainers.image.created": time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+	if *o.platforms != "" {
+		build.Platforms = strings.Split(*o.platforms, ",")
+	}
+	repository := cmp.Or(*o.repository, processorRepository(config))
+	build.Tags = []string{repository + ":latest"}
+
+	address := cmp.Or(*o.coreAddress, config.OrcaConnectionString)
+	var err error
+	if address == "" {
+		address, err = localCoreAddress()
+	}
+	var algorithms []string
+	var algorithmsHash string
+	if err == nil {
+		algorithms, algorithmsHash, err = fetchProjectAlgorithms(address, config.ProjectName, *o.timeout)
+	}
+	switch {
+	case err != nil:
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Could not read the project's algorithms, the image is only tagged latest: %v", err)))
+	case len(algorithms) == 0:
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("No algorithms are registered for project %q, the image is only tagged latest. Run the processor to register them.", config.ProjectName)))
+	default:
+		build.Tags = append(build.Tags, repository+":"+algorithmsHash)
+		build.Labels[imageAlgorithmsLabel] = strings.Join(algorithms, ",")
+		build.Labels[imageAlgorithmsHashLabel] = algorithmsHash
+	}
+	for _, tag := range strings.Split(*o.tags, ",") {
+		if tag = strings.TrimSpace(tag); tag == "" {
+			continue
+		}
+		if !strings.Contains(tag, ":") {
+			tag = repository + ":" + tag
+		}
+		build.Tags = append(build.Tags, tag)
+	}
+	return build
+}
+
+// processorBuild is an `orca build` of a processor project
+type processorBuild struct {
+	// Dir is the build context, the project directory
+	Dir string
+	// Dockerfile is the user's own, when set, otherwise one is generated
+	Dockerfile string
+	Tags       []string
+	Labels     map[string]string
+	NoCache    bool
+	// Platforms are built with buildx, e.g. linux/arm64. Several can only be kept
+	// by pushing them, or by an engine using the containerd image store.
+	Platforms []string
+	// Push pushes the tags from buildx as they are built. Without platforms the
+	// image is built locally and pushed afterwards.
+	Push bool
+}
+
+// buildArgs returns the `docker build` arguments
+func (b *processorBuild) buildArgs(dockerfile string) []string {
+	args := []string{"build"}
+	if len(b.Platforms) > 0 {
+		args = []string{"buildx", "build", "--platform", strings.Join(b.Platforms, ",")}
+		if b.Push {
+			args = append(args, "--push")
+		} else {
+			args = append(args, "--load")
+		}
+	}
+	args = append(args, "-f", dockerfile)
+	for _, tag := range b.Tags {
+		args = append(args, "-t", tag)
+	}
+	keys := make([]string, 0, len(b.Labels))
+	for key := range b.Labels {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		args = append(args, "--label", key+"="+b.Labels[key])
+	}
+	if b.NoCache {
+		args = append(args, "--no-cache")
+	}
+	return append(args, b.Dir)
+}
+
+// run builds the image, writing a generated Dockerfile and its ignore file to a
+// temporary directory so the project is left untouched
+func (b *processorBuild) run(config *OrcaConfigFile) error {
+	if len(b.Platforms) > 0 && dockerCommand("buildx", "version").Run() != nil {
+		return fmt.Errorf("building for platforms %s needs docker buildx, which is not installed", strings.Join(b.Platforms, ","))
+	}
+	dockerfile := b.Dockerfile
+	if dockerfile == "" {
+		content, err := generatedDockerfile(config)
+		if err != nil {
+			return err
+		}
+		tempDir, err := os.MkdirTemp("", "orca-build-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tempDir)
+		dockerfile = filepath.Join(tempDir, "Dockerfile")
+		if err := os.WriteFile(dockerfile, []byte(content), 0644); err != nil {
+			return err
+		}
+		// BuildKit reads the ignore file named after the Dockerfile, before any in the context
+		if err := os.WriteFile(dockerfile+".dockerignore", []byte(generatedDockerignore), 0644); err != nil {
+			return err
+		}
+	}
+
+	cmd := dockerCommand(b.buildArgs(dockerfile)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("docker build failed: %w", err)
+	}
+	return nil
+}
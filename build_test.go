@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+func TestProjectAlgorithms(t *testing.T) {
+	algorithm := func(name string) *pb.Algorithm {
+		return &pb.Algorithm{Name: name, Version: "1.0.0", WindowType: &pb.WindowType{Name: "FastWindow", Version: "1.0.0"}, ResultType: pb.ResultType_VALUE}
+	}
+	state := &pb.InternalState{Processors: []*pb.ProcessorRegistration{
+		{Name: "ml", ProjectName: "speed", ConnectionStr: "host.docker.internal:5377", SupportedAlgorithms: []*pb.Algorithm{algorithm("SpeedCheck"), algorithm("Acceleration")}},
+		{Name: "routes", ProjectName: "other", SupportedAlgorithms: []*pb.Algorithm{algorithm("RouteCheck")}},
+	}}
+
+	algorithms, hash, err := projectAlgorithms(state, "speed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(algorithms, ",") != "ml/Acceleration@1.0.0,ml/SpeedCheck@1.0.0" || len(hash) != 12 {
+		t.Fatalf("projectAlgorithms = %v, %q", algorithms, hash)
+	}
+
+	// where the processor runs does not change the hash, its algorithms do
+	state.Processors[0].ConnectionStr = "speed:5377"
+	if _, moved, _ := projectAlgorithms(state, "speed"); moved != hash {
+		t.Errorf("hash changed with the connection string, %s to %s", hash, moved)
+	}
+	state.Processors[0].SupportedAlgorithms[0].Version = "1.1.0"
+	if _, changed, _ := projectAlgorithms(state, "speed"); changed == hash {
+		t.Errorf("hash did not change with an algorithm version")
+	}
+
+	if algorithms, hash, err := projectAlgorithms(state, "missing"); err != nil || algorithms != nil || hash != "" {
+		t.Errorf("projectAlgorithms of an unregistered project = %v, %q, %v", algorithms, hash, err)
+	}
+}
+
+func TestGeneratedDockerfile(t *testing.T) {
+	for manager, want := range map[string]string{
+		"uv":     "RUN uv sync --no-install-project --no-dev\n",
+		"poetry": "RUN poetry install --no-root --only main\n",
+		"pip":    "RUN pip install --no-cache-dir -r requirements.txt\n",
+	} {
+		dockerfile, err := generatedDockerfile(&OrcaConfigFile{ProcessorPort: 5377, Processor: &ProcessorConfig{PackageManager: manager}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range []string{want, "EXPOSE 5377\n", `CMD ["python", "main.py"]` + "\n"} {
+			if !strings.Contains(dockerfile, line) {
+				t.Errorf("Dockerfile for %s is missing %q:\n%s", manager, line, dockerfile)
+			}
+		}
+	}
+}
+
+func TestBuildArgs(t *testing.T) {
+	build := &processorBuild{Dir: "speed", Tags: []string{"ghcr.io/acme/speed:latest"}, Labels: map[string]string{imageProjectLabel: "speed"}}
+	if got := strings.Join(build.buildArgs("Dockerfile"), " "); got != "build -f Dockerfile -t ghcr.io/acme/speed:latest --label orca.processor.project=speed speed" {
+		t.Errorf("buildArgs = %s", got)
+	}
+
+	// platforms are built with buildx, and pushed from it as they cannot all be loaded
+	build.Platforms = []string{"linux/amd64", "linux/arm64"}
+	build.Push = true
+	if got := strings.Join(build.buildArgs("Dockerfile"), " "); !strings.HasPrefix(got, "buildx build --platform linux/amd64,linux/arm64 --push -f Dockerfile ") {
+		t.Errorf("buildArgs with platforms = %s", got)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// callOrigin is the origin of windows built by `orca call`, unless one is given
+const callOrigin = "orca-cli"
+
+// metadataFlag collects repeated `-meta key=value` flags. Values that parse as JSON
+// keep their type, anything else is a string.
+type metadataFlag map[string]any
+
+func (m metadataFlag) String() string {
+	pairs := make([]string, 0, len(m))
+	for key, value := range m {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, value))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (m metadataFlag) Set(pair string) error {
+	key, raw, found := strings.Cut(pair, "=")
+	if !found || key == "" {
+		return fmt.Errorf("expected key=value, got %q", pair)
+	}
+	var value any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		value = raw
+	}
+	m[key] = value
+	return nil
+}
+
+// callTarget is an algorithm to call along with the processor registered for it
+type callTarget struct {
+	Processor *pb.ProcessorRegistration
+	Algorithm *pb.Algorithm
+}
+
+// findCallTarget looks an algorithm up in the registry. The version and processor
+// narrow the search when several processors register the same algorithm name.
+func findCallTarget(state *pb.InternalState, name, version, processor string) (callTarget, error) {
+	var matches []callTarget
+	for _, registration := range state.GetProcessors() {
+		if processor != "" && registration.GetName() != processor {
+			continue
+		}
+		for _, algorithm := range registration.GetSupportedAlgorithms() {
+			if algorithm.GetName() == name && (version == "" || algorithm.GetVersion() == version) {
+				matches = append(matches, callTarget{Processor: registration, Algorithm: algorithm})
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return callTarget{}, fmt.Errorf("algorithm %q is not registered with the core. Is its processor running?", name)
+	case 1:
+		return matches[0], nil
+	}
+	var candidates []string
+	for _, match := range matches {
+		candidates = append(candidates, fmt.Sprintf("%s %s on %s", match.Algorithm.GetName(), match.Algorithm.GetVersion(), match.Processor.GetName()))
+	}
+	return callTarget{}, fmt.Errorf("algorithm %q is ambiguous, pick one with -version or -processor: %s", name, strings.Join(candidates, ", "))
+}
+
+// readWindowFile reads a window in its protobuf JSON form from a file, or stdin for "-"
+func readWindowFile(path string) (*pb.Window, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read window: %w", err)
+	}
+	window := &pb.Window{}
+	if err := protojson.Unmarshal(data, window); err != nil {
+		return nil, fmt.Errorf("failed to parse window: %w", err)
+	}
+	return window, nil
+}
+
+// buildCallWindow completes a window for the algorithm's window type. Times left
+// unset cover the hour before now.
+func buildCallWindow(window *pb.Window, windowType *pb.WindowType, from, to time.Time, origin string, metadata map[string]any) (*pb.Window, error) {
+	if window == nil {
+		window = &pb.Window{}
+	}
+	window.WindowTypeName = windowType.GetName()
+	window.WindowTypeVersion = windowType.GetVersion()
+	if origin != "" || window.Origin == "" {
+		window.Origin = origin
+	}
+	if window.Origin == "" {
+		window.Origin = callOrigin
+	}
+
+	if !to.IsZero() || window.TimeTo == nil {
+		if to.IsZero() {
+			to = time.Now()
+		}
+		window.TimeTo = timestamppb.New(to)
+	}
+	if !from.IsZero() || window.TimeFrom == nil {
+		if from.IsZero() {
+			from = window.TimeTo.AsTime().Add(-time.Hour)
+		}
+		window.TimeFrom = timestamppb.New(from)
+	}
+	if !window.TimeTo.AsTime().After(window.TimeFrom.AsTime()) {
+		return nil, fmt.Errorf("the window must end after it starts")
+	}
+
+	if len(metadata) > 0 {
+		fields := map[string]any{}
+		if window.Metadata != nil {
+			fields = window.Metadata.AsMap()
+		}
+		for key, value := range metadata {
+			fields[key] = value
+		}
+		structured, err := structpb.NewStruct(fields)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata: %w", err)
+		}
+		window.Metadata = structured
+	}
+
+	var missing []string
+	for _, field := range windowType.GetMetadataFields() {
+		if _, ok := window.GetMetadata().GetFields()[field.GetName()]; !ok {
+			missing = append(missing, field.GetName())
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf(
+			"Window type %s %s carries metadata the window does not set: %s. Set it with -meta key=value",
+			windowType.GetName(), windowType.GetVersion(), strings.Join(missing, ", "),
+		)))
+	}
+	return window, nil
+}
+
+// newExecID returns a random execution id for a call
+func newExecID() string {
+	buffer := make([]byte, 8)
+	rand.Read(buffer)
+	return "orca-call-" + hex.EncodeToString(buffer)
+}
+
+// callAlgorithm runs a single algorithm on its processor for the window, writing
+// each result to w as JSON. Dependencies are not run, so the algorithm sees no
+// results from them.
+func callAlgorithm(ctx context.Context, target callTarget, window *pb.Window, w io.Writer) error {
+	if len(target.Algorithm.GetDependencies()) > 0 {
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf(
+			"%s depends on %d other algorithms, whose results are not passed to it by `orca call`",
+			target.Algorithm.GetName(), len(target.Algorithm.GetDependencies()),
+		)))
+	}
+
+	address := processorProbeAddress(target.Processor.GetConnectionStr())
+	conn, err := dialInsecure(address)
+	if err != nil {
+		return fmt.Errorf("issue preparing to contact processor %s: %w", target.Processor.GetName(), err)
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(os.Stderr, "Calling %s %s on processor %s at %s...\n",
+		target.Algorithm.GetName(), target.Algorithm.GetVersion(), target.Processor.GetName(), address)
+	stream, err := pb.NewOrcaProcessorClient(conn).ExecuteDagPart(ctx, &pb.ExecutionRequest{
+		ExecId:              newExecID(),
+		Window:              window,
+		AlgorithmExecutions: []*pb.ExecuteAlgorithm{{Algorithm: target.Algorithm}},
+	})
+	if err != nil {
+		return fmt.Errorf("issue contacting processor %s: %w", target.Processor.GetName(), err)
+	}
+
+	marshal := protojson.MarshalOptions{Multiline: true, Indent: "    "}
+	received := 0
+	failed := false
+	for {
+		result, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("processor %s failed: %w", target.Processor.GetName(), err)
+		}
+		received++
+		algorithmResult := result.GetAlgorithmResult().GetResult()
+		if algorithmResult.GetStatus() != pb.ResultStatus_RESULT_STATUS_SUCEEDED {
+			failed = true
+		}
+		data, err := marshal.Marshal(algorithmResult)
+		if err != nil {
+			return fmt.Errorf("failed to serialize result: %w", err)
+		}
+		fmt.Fprintln(w, string(data))
+	}
+
+	if received == 0 {
+		return fmt.Errorf("processor %s returned no result", target.Processor.GetName())
+	}
+	if failed {
+		return fmt.Errorf("%s did not succeed", target.Algorithm.GetName())
+	}
+	return nil
+}
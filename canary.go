@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// the canary is a second core, of another version, running against a copy of the
+// store so that a release can be tried with real data before the stack switches to it
+const (
+	defaultCanaryContainerName      = "orca-canary-core"
+	defaultCanaryStoreContainerName = "orca-canary-pg"
+
+	componentCanaryCore  = "canary-core"
+	componentCanaryStore = "canary-postgres"
+
+	// the canary is published from the port after the core's
+	canaryPreferredPort = 33671
+)
+
+func canaryContainerName() string {
+	return namespaced(defaultCanaryContainerName)
+}
+
+func canaryStoreContainerName() string {
+	return namespaced(defaultCanaryStoreContainerName)
+}
+
+func canaryVolumeName() string {
+	return canaryStoreContainerName() + "-data"
+}
+
+// canaryContainers are the containers of the canary, store first
+func canaryContainers() []string {
+	return []string{canaryStoreContainerName(), canaryContainerName()}
+}
+
+// canaryImage returns the core image of a version, or the image itself when given a
+// full reference
+func canaryImage(version string) (string, error) {
+	version = strings.TrimSpace(version)
+	if version == "" {
+		return "", fmt.Errorf("-canary requires a core version, e.g. %s", orcaImageVersion)
+	}
+	if strings.ContainsAny(version, ":/@") {
+		return version, nil
+	}
+	return imageRepository(stackImages[componentCore]) + ":" + version, nil
+}
+
+// startCanary starts the canary core running image. A canary of another image is
+// replaced, with a fresh copy of the store, while one of the same image is started
+// again with the copy it has. It returns the host port the canary is published on.
+func startCanary(image, networkName string, pgConfig *PostgresConfig, env []string) (int, error) {
+	canary := canaryContainerName()
+	output, err := dockerCommand("inspect", "--format", "{{.Config.Image}}", canary).Output()
+	exists := err == nil
+	if exists && strings.TrimSpace(string(output)) != image {
+		fmt.Fprintf(os.Stderr, "Replacing the canary running %s...\n", strings.TrimSpace(string(output)))
+		if err := removeCanary(); err != nil {
+			return 0, err
+		}
+		exists = false
+	}
+
+	started := time.Now()
+	if exists {
+		for _, containerName := range canaryContainers() {
+			if state, err := getContainerState(containerName); err == nil && state.Status == "running" {
+				continue
+			}
+			if _, err := runDockerRetry("start", containerName); err != nil {
+				return 0, fmt.Errorf("failed to start %s: %w", containerName, err)
+			}
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "Pulling %s...\n", image)
+		if err := streamDockerRetry("Canary:", "pull", image); err != nil {
+			return 0, fmt.Errorf("failed to pull %s: %w", image, err)
+		}
+		// leftovers of a canary that was not fully created would hold stale data
+		if err := removeCanary(); err != nil {
+			return 0, err
+		}
+		if err := copyCanaryStore(); err != nil {
+			return 0, err
+		}
+		if err := runCanaryStore(networkName, pgConfig); err != nil {
+			return 0, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+	defer cancel()
+	if err := waitForPgReady(ctx, canaryStoreContainerName(), pollInterval); err != nil {
+		return 0, fmt.Errorf("%w after %s, raise the limit with --startup-timeout", err, startupTimeout)
+	}
+
+	if !exists {
+		port, err := assignHostPort(canary, orcaInternalPort, canaryPreferredPort)
+		if err != nil {
+			return 0, err
+		}
+		args := coreRunArgs(canary, canaryStoreContainerName(), componentCanaryCore, image, networkName, port, env)
+		if err := streamDockerRetry("Canary:", args...); err != nil {
+			return 0, fmt.Errorf("failed to create %s: %w", canary, err)
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "Waiting for the canary core to migrate its copy of the store...")
+	if err := followCoreStartup(canary, started); err != nil {
+		return 0, err
+	}
+	if err := recordPublishedPorts([]string{canary}); err != nil {
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Could not record the published ports: %v", err)))
+	}
+	return getPublishedHostPort(canary, orcaInternalPort)
+}
+
+// copyCanaryStore copies the data of the store into the canary's volume, stopping the
+// stack while copying so the copy is consistent
+func copyCanaryStore() error {
+	source := pgContainerName + "-data"
+	if !volumeExists(source) {
+		return fmt.Errorf("volume %s does not exist. Start the stack with `orca start` first", source)
+	}
+
+	running := pauseStack()
+	defer resumeStack(running)
+
+	destination := canaryVolumeName()
+	fmt.Fprintf(os.Stderr, "Copying %s to %s... ", source, destination)
+	args := append([]string{"volume", "create"}, labelArgs(componentCanaryStore)...)
+	if output, err := dockerCommand(append(args, destination)...).CombinedOutput(); err != nil {
+		fmt.Fprintln(os.Stderr, renderError("FAILED"))
+		return fmt.Errorf("failed to create volume %s: %w: %s", destination, err, strings.TrimSpace(string(output)))
+	}
+	if err := copyVolume(source, destination); err != nil {
+		fmt.Fprintln(os.Stderr, renderError("FAILED"))
+		return err
+	}
+	fmt.Fprintln(os.Stderr, renderSuccess("DONE"))
+	return nil
+}
+
+// runCanaryStore creates the Postgres container of the canary on its copy of the store.
+// It is not published, only the canary core connects to it.
+func runCanaryStore(networkName string, config *PostgresConfig) error {
+	args := []string{
+		"run",
+		"-d",
+		"--name", canaryStoreContainerName(),
+		"--network", networkName,
+		"-e", "POSTGRES_USER=orca",
+		"-e", "POSTGRES_PASSWORD=orca",
+		"-e", "POSTGRES_DB=orca",
+		"-v", canaryVolumeName() + ":/var/lib/postgresql",
+	}
+	args = append(args, labelArgs(componentCanaryStore)...)
+	args = append(args, stackImages[componentPostgres], "postgres")
+	args = append(args, postgresServerArgs(config)...)
+	if err := streamDockerRetry("Canary store:", args...); err != nil {
+		return fmt.Errorf("failed to create %s: %w", canaryStoreContainerName(), err)
+	}
+	return nil
+}
+
+// removeCanary removes the canary's containers and its copy of the store
+func removeCanary() error {
+	for _, containerName := range canaryContainers() {
+		if _, err := getContainerState(containerName); err != nil {
+			continue
+		}
+		if err := removeContainerFunc(containerName)(); err != nil {
+			return err
+		}
+	}
+	if volumeExists(canaryVolumeName()) {
+		output, err := dockerCommand("volume", "rm", canaryVolumeName()).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to remove volume %s: %w: %s", canaryVolumeName(), err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
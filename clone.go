@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// volumeClone pairs a data volume of the current stack with its counterpart in the target project
+type volumeClone struct {
+	Source      string
+	Destination string
+	Component   string
+}
+
+func cloneVolumes(target string) []volumeClone {
+	return []volumeClone{
+		{
+			Source:      namespaced(defaultPgContainerName) + "-data",
+			Destination: namespacedFor(target, defaultPgContainerName) + "-data",
+			Component:   componentPostgres,
+		},
+		{
+			Source:      namespaced(defaultRedisContainerName) + "-data",
+			Destination: namespacedFor(target, defaultRedisContainerName) + "-data",
+			Component:   componentRedis,
+		},
+	}
+}
+
+// cloneStack copies the data volumes of the current stack into a new stack project.
+// Running containers are stopped while copying so the copy is consistent.
+func cloneStack(target string) (err error) {
+	if err := validateStackProject(target); err != nil {
+		return err
+	}
+	if target == stackProject {
+		return fmt.Errorf("cannot clone project %q into itself", target)
+	}
+
+	volumes := cloneVolumes(target)
+	for _, volume := range volumes {
+		if !volumeExists(volume.Source) {
+			return fmt.Errorf("volume %s does not exist. Start the stack with `orca start` first", volume.Source)
+		}
+		if volumeExists(volume.Destination) {
+			return fmt.Errorf("volume %s already exists. Destroy project %q first with `orca --project %s destroy`", volume.Destination, target, target)
+		}
+	}
+
+	running := pauseStack()
+	defer resumeStack(running)
+
+	// a half cloned project would block a retry with "already exists", so a failure
+	// removes the volumes made so far
+	var made []string
+	defer func() {
+		if err != nil {
+			for _, volumeName := range made {
+				dockerCommand("volume", "rm", volumeName).Run()
+			}
+		}
+	}()
+
+	for _, volume := range volumes {
+		fmt.Fprintf(os.Stderr, "Copying %s to %s... ", volume.Source, volume.Destination)
+
+		args := append([]string{"volume", "create"}, labelArgsFor(target, volume.Component)...)
+		output, err := dockerCommand(append(args, volume.Destination)...).CombinedOutput()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, renderError("FAILED"))
+			return fmt.Errorf("failed to create volume %s: %w: %s", volume.Destination, err, strings.TrimSpace(string(output)))
+		}
+		made = append(made, volume.Destination)
+
+		if err := copyVolume(volume.Source, volume.Destination); err != nil {
+			fmt.Fprintln(os.Stderr, renderError("FAILED"))
+			return err
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess("DONE"))
+	}
+	return nil
+}
+
+// runOrca runs the orca executable itself with the given arguments, attached to
+// the CLI's standard streams
+func runOrca(args ...string) error {
+	return runOrcaIn("", args...)
+}
+
+// runOrcaIn runs the orca executable as runOrca does, in the given directory
+func runOrcaIn(dir string, args ...string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the orca executable: %w", err)
+	}
+
+	cmd := exec.Command(executable, args...)
+	cmd.Dir = dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// companionService describes an optional helper container that can be launched
+// alongside the Orca stack with `orca start -with <name>`
+type companionService struct {
+	Name          string
+	Description   string
+	ContainerName string
+	Image         string
+	InternalPort  int
+	// PreferredPort is the host port to try first. Zero keeps the service internal
+	// to the orca network without publishing a port.
+	PreferredPort int
+	Env           []string
+	// Files are copied into the container before it first starts, keyed by container path
+	Files map[string]string
+	// URLPath is appended to the published address when printing the service URL
+	URLPath string
+	// Login describes how to sign in, if the service needs credentials
+	Login string
+}
+
+func pgAdminServersJSON() string {
+	return `{
+    "Servers": {
+        "1": {
+            "Name": "Orca Store",
+            "Group": "Orca",
+            "Host": "` + pgContainerName + `",
+            "Port": 5432,
+            "MaintenanceDB": "orca",
+            "Username": "orca",
+            "SSLMode": "disable"
+        }
+    }
+}
+`
+}
+
+var companionServices = buildCompanionServices()
+
+// buildCompanionServices returns the available companion services, named for the current stack project
+func buildCompanionServices() map[string]companionService {
+	return map[string]companionService{
+		"pgadmin": {
+			Name:          "pgadmin",
+			Description:   "pgAdmin web UI for the Postgres store",
+			ContainerName: namespaced("orca-pgadmin-instance"),
+			Image:         "dpage/pgadmin4",
+			InternalPort:  80,
+			PreferredPort: 5050,
+			Env: []string{
+				"PGADMIN_DEFAULT_EMAIL=orca@example.com",
+				"PGADMIN_DEFAULT_PASSWORD=orca",
+				"PGADMIN_CONFIG_SERVER_MODE=False",
+				"PGADMIN_CONFIG_MASTER_PASSWORD_REQUIRED=False",
+				"PGADMIN_SERVER_JSON_FILE=/pgadmin4/servers.json",
+			},
+			Files: map[string]string{
+				"/pgadmin4/servers.json": pgAdminServersJSON(),
+			},
+			Login: "store password: orca",
+		},
+		"adminer": {
+			Name:          "adminer",
+			Description:   "Adminer web UI for the Postgres store",
+			ContainerName: namespaced("orca-adminer-instance"),
+			Image:         "adminer",
+			InternalPort:  8080,
+			PreferredPort: 8081,
+			Env: []string{
+				"ADMINER_DEFAULT_SERVER=" + pgContainerName,
+			},
+			URLPath: "/?pgsql=" + pgContainerName + "&username=orca&db=orca",
+			Login:   "password: orca",
+		},
+		"redisinsight": {
+			Name:          "redisinsight",
+			Description:   "RedisInsight web UI for the Redis cache",
+			ContainerName: namespaced("orca-redisinsight-instance"),
+			Image:         "redis/redisinsight",
+			InternalPort:  5540,
+			PreferredPort: 5540,
+			Env: []string{
+				// pre-registers the stack's Redis instance as a database connection
+				"RI_REDIS_HOST=" + redisContainerName,
+				fmt.Sprintf("RI_REDIS_PORT=%d", redisInternalPort),
+				"RI_REDIS_ALIAS=Orca Cache",
+			},
+		},
+		"postgres-exporter": {
+			Name:          "postgres-exporter",
+			Description:   "Prometheus exporter for the Postgres store",
+			ContainerName: namespaced("orca-postgres-exporter-instance"),
+			Image:         "quay.io/prometheuscommunity/postgres-exporter",
+			InternalPort:  9187,
+			Env: []string{
+				fmt.Sprintf(
+					"DATA_SOURCE_NAME=postgresql://orca:orca@%s:%d/orca?sslmode=disable",
+					pgContainerName,
+					pgInternalPort,
+				),
+			},
+		},
+		"redis-exporter": {
+			Name:          "redis-exporter",
+			Description:   "Prometheus exporter for the Redis cache",
+			ContainerName: namespaced("orca-redis-exporter-instance"),
+			Image:         "oliver006/redis_exporter",
+			InternalPort:  9121,
+			Env: []string{
+				fmt.Sprintf("REDIS_ADDR=redis://%s:%d", redisContainerName, redisInternalPort),
+			},
+		},
+		"prometheus": {
+			Name:          "prometheus",
+			Description:   "Prometheus metrics for the Orca stack",
+			ContainerName: namespaced("orca-prometheus-instance"),
+			Image:         "prom/prometheus",
+			InternalPort:  9090,
+			PreferredPort: 9090,
+			Files: map[string]string{
+				"/etc/prometheus/prometheus.yml": prometheusConfig(),
+			},
+		},
+		"grafana": {
+			Name:          "grafana",
+			Description:   "Grafana dashboards for the Orca stack",
+			ContainerName: namespaced("orca-grafana-instance"),
+			Image:         "grafana/grafana",
+			InternalPort:  3000,
+			PreferredPort: 3000,
+			Env: []string{
+				// dashboards are readable without logging in, changing them needs the admin login
+				"GF_AUTH_ANONYMOUS_ENABLED=true",
+				"GF_AUTH_ANONYMOUS_ORG_ROLE=Viewer",
+			},
+			Files: map[string]string{
+				"/etc/grafana/provisioning/datasources/orca.yaml":      grafanaDatasource(),
+				"/etc/grafana/provisioning/dashboards/orca.yaml":       grafanaDashboardProvider,
+				"/etc/grafana/provisioning/dashboards/orca-stack.json": grafanaStackDashboard,
+			},
+			Login: "none to view, admin / admin to edit",
+		},
+	}
+}
+
+// companionBundles group companion services that are started together
+var companionBundles = map[string][]string{
+	"observability": {"postgres-exporter", "redis-exporter", "prometheus", "grafana"},
+}
+
+// companionServiceNames returns the names of all available companion services, sorted
+func companionServiceNames() []string {
+	names := make([]string, 0, len(companionServices))
+	for name := range companionServices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// companionChoices returns every name accepted by `-with`, bundles included
+func companionChoices() []string {
+	choices := companionServiceNames()
+	for name := range companionBundles {
+		choices = append(choices, name)
+	}
+	sort.Strings(choices)
+	return choices
+}
+
+// resolveCompanionServices validates and de-duplicates requested service names,
+// expanding bundles into their member services
+func resolveCompanionServices(names []string) ([]companionService, error) {
+	var expanded []string
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if bundle, ok := companionBundles[name]; ok {
+			expanded = append(expanded, bundle...)
+		} else {
+			expanded = append(expanded, name)
+		}
+	}
+
+	seen := map[string]bool{}
+	var services []companionService
+	for _, name := range expanded {
+		if name == "" || seen[name] {
+			continue
+		}
+		svc, ok := companionServices[name]
+		if !ok {
+			return nil, fmt.Errorf(
+				"unknown service %q, must be one of: %s",
+				name,
+				strings.Join(companionChoices(), ", "),
+			)
+		}
+		seen[name] = true
+		services = append(services, svc)
+	}
+	return services, nil
+}
+
+// startCompanion creates (or starts) a companion container on the orca network
+func startCompanion(networkName string, svc companionService) {
+	prefix := svc.Name + ":"
+	if exists := checkStartContainer(svc.ContainerName); exists {
+		return
+	}
+
+	args := []string{
+		"create",
+		"--name", svc.ContainerName,
+		"--network", networkName,
+	}
+
+	if svc.PreferredPort > 0 {
+		hostPort, err := assignHostPort(svc.ContainerName, svc.InternalPort, svc.PreferredPort)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		args = append(args, "-p", fmt.Sprintf("%d:%d", hostPort, svc.InternalPort))
+	}
+	for _, env := range svc.Env {
+		args = append(args, "-e", env)
+	}
+	args = append(args, labelArgs(svc.Name)...)
+	args = append(args, svc.Image)
+	streamDockerCommand(prefix, args...)
+
+	for containerPath, content := range svc.Files {
+		if err := copyContentToContainer(svc.ContainerName, containerPath, content); err != nil {
+			printError(fmt.Sprintf("%s failed to copy %s: %v", prefix, containerPath, err))
+			exit(1)
+		}
+	}
+
+	streamDockerCommand(prefix, "start", svc.ContainerName)
+}
+
+// copyContentToContainer writes content to a path inside a (possibly stopped) container
+func copyContentToContainer(containerName, containerPath, content string) error {
+	tmpDir, err := os.MkdirTemp("", "orca-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	localPath := filepath.Join(tmpDir, filepath.Base(containerPath))
+	if err := os.WriteFile(localPath, []byte(content), 0644); err != nil {
+		return err
+	}
+
+	output, err := dockerCommand("cp", localPath, containerName+":"+containerPath).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// showCompanionStatus prints the status of any companion services that have been created
+func showCompanionStatus(containers containerList) {
+	for _, name := range companionServiceNames() {
+		svc := companionServices[name]
+		containerName := containers.component(svc.Name, svc.ContainerName)
+		status := containers.status(containerName)
+		if status == "not found" {
+			continue
+		}
+
+		fmt.Println()
+		fmt.Printf("%s: %s\n", svc.Description, renderStdout(statusColor(status), status))
+		if status == "running" && svc.PreferredPort > 0 {
+			port := containers.port(containerName, svc.InternalPort)
+			fmt.Printf("URL: http://localhost:%s%s\n", port, svc.URLPath)
+			if svc.Login != "" {
+				fmt.Println("Login: " + svc.Login)
+			}
+		}
+	}
+}
+
+// companionContainers returns the container names of all companion services
+func companionContainers() []string {
+	var names []string
+	for _, name := range companionServiceNames() {
+		names = append(names, companionServices[name].ContainerName)
+	}
+	return names
+}
+
+// stackContainers returns any companion or other labelled containers of the stack
+// project that currently exist, followed by the core Orca containers, so that
+// companions are stopped first
+func stackContainers() []string {
+	var containers []string
+	for _, containerName := range companionContainers() {
+		if getContainerStatus(containerName) != "not found" {
+			containers = append(containers, containerName)
+		}
+	}
+
+	var extra []string
+	for _, containerName := range withManaged("container", containers) {
+		if !slices.Contains(orcaContainers, containerName) {
+			extra = append(extra, containerName)
+		}
+	}
+	return append(extra, orcaContainers...)
+}
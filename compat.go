@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// OCI label carrying the release version of an image
+const imageVersionLabel = "org.opencontainers.image.version"
+
+// getCoreVersion returns the version of the core running in a container. The core
+// has no version RPC, so it is read from the image label, falling back to the image tag.
+func getCoreVersion(containerName string) (string, error) {
+	output, err := dockerCommand(
+		"inspect",
+		"--format", fmt.Sprintf(`{{index .Config.Labels "%s"}} {{.Config.Image}}`, imageVersionLabel),
+		containerName,
+	).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect %s: %w", containerName, err)
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) == 2 {
+		return fields[0], nil
+	}
+	if len(fields) == 1 {
+		if _, tag, found := strings.Cut(fields[0][strings.LastIndex(fields[0], "/")+1:], ":"); found {
+			return tag, nil
+		}
+	}
+	return "", fmt.Errorf("could not determine the core version of %s", containerName)
+}
+
+// coreVersionCompatible reports whether a core version speaks the API this CLI
+// was built against. Before 1.0 a minor release may break the API, so the major
+// and minor versions must match; from 1.0 only the major version must.
+func coreVersionCompatible(coreVersion string) bool {
+	core, ok := parseVersion(coreVersion)
+	if !ok {
+		return false
+	}
+	supported, _ := parseVersion(orcaImageVersion)
+	if len(core) < 2 {
+		core = append(core, 0)
+	}
+
+	if core[0] != supported[0] {
+		return false
+	}
+	return supported[0] > 0 || core[1] == supported[1]
+}
+
+// checkCoreCompatibility compares the running core against the CLI, warning on a
+// mismatch, or failing when strict is set
+func checkCoreCompatibility(strict bool) error {
+	coreVersion, err := getCoreVersion(orcaContainerName)
+	if err != nil {
+		if strict {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Could not check core compatibility: %v", err)))
+		return nil
+	}
+
+	if coreVersionCompatible(coreVersion) {
+		return nil
+	}
+
+	message := fmt.Sprintf(
+		"Orca core %s is not compatible with this CLI, which supports core %s. Stubs and registry data may not match.",
+		coreVersion,
+		orcaImageVersion,
+	)
+	if strict {
+		return fmt.Errorf("%s", message)
+	}
+	fmt.Fprintln(os.Stderr, warningStyle.Render(message))
+	fmt.Fprintln(os.Stderr, "Switch the core to the supported version with `orca upgrade`.")
+	return nil
+}
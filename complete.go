@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/orca-telemetry/cli/stub"
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+// commandNames lists the user facing subcommands, for completion
+var commandNames = []string{
+	"api", "backup", "bridge", "build", "call", "clone", "completion", "config", "cp",
+	"daemon", "deploy", "destroy", "dev", "doctor", "du", "export", "failures", "health",
+	"help", "import", "init", "login", "logout", "logs", "maintenance", "new", "pause",
+	"port", "processor", "psql", "purge", "push", "queue", "redis-cli", "repair", "restore",
+	"results", "resume", "run", "scan", "schedule", "seed", "serve", "service", "shell",
+	"snapshot", "sql", "start", "status", "stop", "stub", "sync", "telemetry", "trace",
+	"update-check", "upgrade", "version", "watch",
+}
+
+// subcommandActions lists the fixed first arguments of subcommands that take an action
+var subcommandActions = map[string][]string{
+	"bridge":       {"kafka", "mqtt"},
+	"config":       {"get", "set", "path", "schema"},
+	"daemon":       {"start", "run", "stop", "status", "refresh"},
+	"export":       {"compose", "k8s"},
+	"failures":     {"list", "retry", "purge"},
+	"new":          {"processor"},
+	"processor":    {"register", "status"},
+	"processors":   {"register", "status"},
+	"queue":        {"stats"},
+	"service":      {"install", "uninstall", "status", "print"},
+	"results":      {"export"},
+	"snapshot":     {"list", "create", "restore", "delete"},
+	"stub":         {"verify"},
+	"telemetry":    {"status", "enable", "disable"},
+	"update-check": {"status", "enable", "disable"},
+	"completion":   {"bash", "zsh", "fish"},
+}
+
+// completeArgs returns the completion candidates for the last of args, which are
+// the words following `orca` on the command line, the last one possibly partial
+func completeArgs(args []string) []string {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	current := args[len(args)-1]
+	words := args[:len(args)-1]
+
+	// skip global flags, which come before the subcommand
+	for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+		if isProjectFlag(words[0]) && !strings.Contains(words[0], "=") {
+			if len(words) == 1 {
+				return filterPrefix(completeProjects(), current)
+			}
+			words = words[1:]
+		}
+		words = words[1:]
+	}
+
+	if len(words) == 0 {
+		return filterPrefix(commandNames, current)
+	}
+	command := words[0]
+	previous := words[len(words)-1]
+
+	// values of flags
+	if len(words) > 1 && strings.HasPrefix(previous, "-") {
+		if values, ok := completeFlagValue(strings.TrimLeft(previous, "-")); ok {
+			return filterPrefix(values, current)
+		}
+	}
+
+	positional := countPositional(words[1:])
+	if positional == 0 {
+		if actions, ok := subcommandActions[command]; ok {
+			return filterPrefix(actions, current)
+		}
+	}
+
+	switch {
+	case command == "snapshot" && positional == 1 && (words[1] == "restore" || words[1] == "delete"):
+		return filterPrefix(completeSnapshots(), current)
+	case (command == "shell" || command == "port") && positional == 0:
+		return filterPrefix(componentNames(), current)
+	case command == "logs":
+		return filterPrefix(append(componentNames(), "all"), current)
+	case command == "cp" && positional < 2 && !strings.Contains(current, ":"):
+		var prefixes []string
+		for _, name := range componentNames() {
+			prefixes = append(prefixes, name+":")
+		}
+		return filterPrefix(prefixes, current)
+	case command == "api" && positional == 0:
+		return filterPrefix(append([]string{"list", "describe"}, coreMethodNames()...), current)
+	case command == "api" && positional == 1 && words[1] == "describe":
+		return filterPrefix(coreMethodNames(), current)
+	case command == "call" && positional == 0:
+		return filterPrefix(completeRegistry(registryAlgorithmNames), current)
+	case command == "config" && positional == 1 && isGlobalConfig(words):
+		return filterPrefix(globalConfigKeyNames(), current)
+	case command == "config" && positional == 1:
+		return filterPrefix(configKeyNames(), current)
+	case command == "config" && positional == 2 && isGlobalConfig(words):
+		return filterPrefix(globalConfigKeys[previous].Values, current)
+	case command == "config" && positional == 2:
+		return filterPrefix(configKeys[previous].Values, current)
+	}
+	return nil
+}
+
+// completeFlagValue returns the candidates for the value of a flag, if known
+func completeFlagValue(name string) ([]string, bool) {
+	switch name {
+	case "project":
+		return completeProjects(), true
+	case "with":
+		return companionChoices(), true
+	case "log-level", "level":
+		return coreLogLevels, true
+	case "redis-persistence":
+		return redisPersistenceModes, true
+	case "redis-eviction-policy":
+		return redisEvictionPolicies, true
+	case "restart":
+		var values []string
+		values = append(values, restartPolicies...)
+		for _, component := range restartComponents {
+			for _, policy := range restartPolicies {
+				values = append(values, component+"="+policy)
+			}
+		}
+		return values, true
+	case "algorithm":
+		return completeRegistry(registryAlgorithmNames), true
+	case "processor":
+		return completeRegistry(registryProcessorNames), true
+	case "package-manager":
+		return packageManagers, true
+	case "deps":
+		return pythonDepsFormats, true
+	case "platform":
+		return []string{"linux/amd64", "linux/arm64", "linux/arm/v7"}, true
+	case "layout":
+		return []string{string(stub.LayoutFlat), string(stub.LayoutPackage)}, true
+	case "o", "format":
+		return []string{"table", "csv", "json", "parquet", "pb", "text", templateOutputPrefix}, true
+	}
+	return nil, false
+}
+
+// isGlobalConfig reports whether `orca config` is completing global settings
+func isGlobalConfig(words []string) bool {
+	return slices.Contains(words, "-global") || slices.Contains(words, "--global")
+}
+
+func isProjectFlag(word string) bool {
+	name, _, _ := strings.Cut(strings.TrimLeft(word, "-"), "=")
+	return name == "project"
+}
+
+// countPositional counts the arguments in words that are neither flags nor flag values
+func countPositional(words []string) int {
+	count := 0
+	for ii := 0; ii < len(words); ii++ {
+		if strings.HasPrefix(words[ii], "-") {
+			// assume a value follows unless given inline, as only a few flags are boolean
+			name := strings.TrimLeft(words[ii], "-")
+			if _, ok := completeFlagValue(name); ok && !strings.Contains(name, "=") {
+				ii++
+			}
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+func filterPrefix(candidates []string, prefix string) []string {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+// completeProjects returns the stack projects that have labelled resources
+func completeProjects() []string {
+	output, err := dockerCommand(
+		"volume", "ls",
+		"--filter", "label="+managedLabel+"=true",
+		"--format", fmt.Sprintf(`{{.Label "%s"}}`, projectLabel),
+	).Output()
+	if err != nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	var projects []string
+	for _, project := range strings.Fields(string(output)) {
+		if project != "default" && !seen[project] {
+			seen[project] = true
+			projects = append(projects, project)
+		}
+	}
+	sort.Strings(projects)
+	return projects
+}
+
+func completeSnapshots() []string {
+	snapshots, err := listSnapshots()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, snapshot := range snapshots {
+		names = append(names, snapshot.Name)
+	}
+	return names
+}
+
+// completeRegistry returns names from the registry of a running daemon, or else
+// from the last synced registry, if there is one
+func completeRegistry(names func(*pb.InternalState) []string) []string {
+	state, err := daemonRegistry("")
+	if err != nil {
+		state, err = loadRegistryCache()
+	}
+	if err != nil {
+		return nil
+	}
+	return names(state)
+}
+
+// completionScript returns the script that wires a shell's completion up to `orca __complete`
+func completionScript(shell string) (string, error) {
+	switch shell {
+	case "bash":
+		return `_orca() {
+    local IFS=$'\n'
+    COMPREPLY=($(orca __complete "${COMP_WORDS[@]:1:$COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _orca orca
+`, nil
+	case "zsh":
+		return `#compdef orca
+_orca() {
+    local -a candidates
+    candidates=("${(@f)$(orca __complete "${(@)words[2,$CURRENT]}" 2>/dev/null)}")
+    compadd -a candidates
+}
+compdef _orca orca
+`, nil
+	case "fish":
+		return `complete -c orca -f -a '(orca __complete (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)'
+`, nil
+	}
+	return "", fmt.Errorf("unsupported shell %q, must be one of: bash, zsh, fish", shell)
+}
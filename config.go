@@ -0,0 +1,781 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultConfigPath = "orca.json"
+
+// findProjectConfig returns the nearest orca.json in the working directory or its
+// parents, as git finds its repository, so commands work from any subdirectory of a
+// project. The path is relative to the working directory, or defaultConfigPath when
+// there is none.
+func findProjectConfig() string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return defaultConfigPath
+	}
+	for dir := cwd; ; dir = filepath.Dir(dir) {
+		path := filepath.Join(dir, defaultConfigPath)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			if rel, err := filepath.Rel(cwd, path); err == nil {
+				return rel
+			}
+			return path
+		}
+		if filepath.Dir(dir) == dir {
+			return defaultConfigPath
+		}
+	}
+}
+
+// OrcaConfigFile is the project configuration stored in orca.json
+type OrcaConfigFile struct {
+	// Schema references the JSON Schema of orca.json, for editors
+	Schema string `json:"$schema,omitempty"`
+
+	ProjectName               string `json:"projectName"`
+	OrcaConnectionString      string `json:"orcaConnectionString"`
+	ProcessorPort             int    `json:"processorPort"`
+	ProcessorConnectionString string `json:"processorConnectionString"`
+	// StackProject is the stack project (--project) whose core orcaConnectionString
+	// points at, empty for the default stack
+	StackProject string `json:"stackProject,omitempty"`
+
+	Postgres *PostgresConfig `json:"postgres,omitempty"`
+	Redis    *RedisConfig    `json:"redis,omitempty"`
+	Core     *CoreConfig     `json:"core,omitempty"`
+	// Timezone is an IANA timezone, e.g. Europe/London, or "local" for the host's
+	// timezone, applied to the Postgres store and the core. Defaults to UTC.
+	Timezone string `json:"timezone,omitempty"`
+	// Restart maps stack components (postgres, redis, core) to a docker restart policy
+	Restart map[string]string `json:"restart,omitempty"`
+	// Services lists optional companion services to start with the stack, e.g. ["pgadmin"]
+	Services []string `json:"services,omitempty"`
+	// Startup sets how long to wait for the stack to become ready
+	Startup *StartupConfig `json:"startup,omitempty"`
+	// Processor sets how `orca run` starts the project's processor
+	Processor *ProcessorConfig `json:"processor,omitempty"`
+	// Backup sets who `orca backup -encrypt` encrypts archives to
+	Backup *BackupConfig `json:"backup,omitempty"`
+}
+
+// orcaHostPort returns the port of orcaConnectionString when it points at this
+// machine, which is the host port `orca start` publishes the core on. It returns
+// zero when the connection string is unset or names another host.
+func (c *OrcaConfigFile) orcaHostPort() (int, error) {
+	if c.OrcaConnectionString == "" {
+		return 0, nil
+	}
+	host, port, err := net.SplitHostPort(c.OrcaConnectionString)
+	if err != nil {
+		return 0, fmt.Errorf("invalid orcaConnectionString %q, expected host:port: %w", c.OrcaConnectionString, err)
+	}
+	if host != "" && host != "localhost" && host != "127.0.0.1" && host != "::1" && host != "0.0.0.0" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(port)
+	if err != nil || value <= 0 || value > 65535 {
+		return 0, fmt.Errorf("invalid port %q in orcaConnectionString", port)
+	}
+	return value, nil
+}
+
+// StartupConfig holds the readiness wait settings, as Go durations such as 90s or 2m
+type StartupConfig struct {
+	// Timeout is how long to wait for a component to become ready. Defaults to 15s.
+	Timeout string `json:"timeout,omitempty"`
+	// PollInterval is how often readiness is checked while waiting. Defaults to 500ms.
+	PollInterval string `json:"pollInterval,omitempty"`
+}
+
+// durations parses the configured timeout and poll interval, returning zero for unset values
+func (c *StartupConfig) durations() (time.Duration, time.Duration, error) {
+	if c == nil {
+		return 0, 0, nil
+	}
+	var timeout, interval time.Duration
+	var err error
+	if c.Timeout != "" {
+		if timeout, err = time.ParseDuration(c.Timeout); err != nil || timeout <= 0 {
+			return 0, 0, fmt.Errorf("invalid startup timeout %q, must be a positive duration such as 60s", c.Timeout)
+		}
+	}
+	if c.PollInterval != "" {
+		if interval, err = time.ParseDuration(c.PollInterval); err != nil || interval <= 0 {
+			return 0, 0, fmt.Errorf("invalid startup poll interval %q, must be a positive duration such as 1s", c.PollInterval)
+		}
+	}
+	return timeout, interval, nil
+}
+
+// PostgresConfig holds server settings applied when the Postgres container is created
+type PostgresConfig struct {
+	SharedBuffers  string `json:"sharedBuffers,omitempty"`
+	MaxConnections int    `json:"maxConnections,omitempty"`
+	WalLevel       string `json:"walLevel,omitempty"`
+	MaxWalSize     string `json:"maxWalSize,omitempty"`
+	MinWalSize     string `json:"minWalSize,omitempty"`
+	// Locale is the database locale, e.g. en_US.UTF-8. It is only applied when the
+	// store is first initialised on a new volume.
+	Locale string `json:"locale,omitempty"`
+	// Settings holds any additional postgresql.conf parameters, e.g. {"work_mem": "16MB"}
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// RedisConfig holds persistence and eviction settings applied when the Redis container is created
+type RedisConfig struct {
+	// Persistence is one of aof, rdb, both or none. When empty, AOF is enabled
+	// alongside the default RDB snapshots of the Redis image.
+	Persistence    string `json:"persistence,omitempty"`
+	MaxMemory      string `json:"maxMemory,omitempty"`
+	EvictionPolicy string `json:"evictionPolicy,omitempty"`
+}
+
+// CoreConfig holds settings applied when the Orca core container is created
+type CoreConfig struct {
+	// EnvFile is a docker-style env file of variables forwarded to the core,
+	// resolved relative to orca.json
+	EnvFile string `json:"envFile,omitempty"`
+	// Env holds variables forwarded to the core, taking precedence over EnvFile
+	Env map[string]string `json:"env,omitempty"`
+	// LogLevel is one of debug, info, warn or error. When empty the core logs at debug.
+	LogLevel string `json:"logLevel,omitempty"`
+}
+
+const defaultCoreLogLevel = "debug"
+
+var coreLogLevels = []string{"debug", "info", "warn", "error"}
+
+func (c *CoreConfig) validate() error {
+	if c.LogLevel != "" && !slices.Contains(coreLogLevels, c.LogLevel) {
+		return fmt.Errorf(
+			"invalid core log level %q, must be one of: %s",
+			c.LogLevel,
+			strings.Join(coreLogLevels, ", "),
+		)
+	}
+	return nil
+}
+
+// logLevelEnv returns the ORCA_LOG_LEVEL variable for the configured log level
+func (c *CoreConfig) logLevelEnv() string {
+	level := defaultCoreLogLevel
+	if c != nil && c.LogLevel != "" {
+		level = c.LogLevel
+	}
+	return "ORCA_LOG_LEVEL=" + strings.ToUpper(level)
+}
+
+var (
+	restartComponents = []string{"postgres", "redis", "core"}
+	restartPolicies   = []string{"no", "on-failure", "unless-stopped", "always"}
+)
+
+// parseRestartPolicies parses a restart policy given either as a single policy
+// for every component, e.g. "unless-stopped", or per component, e.g.
+// "postgres=unless-stopped,core=on-failure"
+func parseRestartPolicies(spec string) (map[string]string, error) {
+	policies := map[string]string{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		component, policy, found := strings.Cut(part, "=")
+		if !found {
+			for _, component := range restartComponents {
+				policies[component] = part
+			}
+			continue
+		}
+		policies[strings.TrimSpace(component)] = strings.TrimSpace(policy)
+	}
+	return policies, validateRestartPolicies(policies)
+}
+
+func validateRestartPolicies(policies map[string]string) error {
+	for component, policy := range policies {
+		if !slices.Contains(restartComponents, component) {
+			return fmt.Errorf(
+				"invalid restart component %q, must be one of: %s",
+				component,
+				strings.Join(restartComponents, ", "),
+			)
+		}
+		// on-failure optionally takes a retry limit, e.g. on-failure:3
+		name, _, _ := strings.Cut(policy, ":")
+		if !slices.Contains(restartPolicies, name) {
+			return fmt.Errorf(
+				"invalid restart policy %q for %s, must be one of: %s",
+				policy,
+				component,
+				strings.Join(restartPolicies, ", "),
+			)
+		}
+	}
+	return nil
+}
+
+// default RDB snapshot schedule used by Redis itself
+const redisDefaultSaveSchedule = "3600 1 300 100 60 10000"
+
+var redisPersistenceModes = []string{"aof", "rdb", "both", "none"}
+
+var redisEvictionPolicies = []string{
+	"noeviction",
+	"allkeys-lru",
+	"allkeys-lfu",
+	"allkeys-random",
+	"volatile-lru",
+	"volatile-lfu",
+	"volatile-random",
+	"volatile-ttl",
+}
+
+func (c *RedisConfig) validate() error {
+	if c.Persistence != "" && !slices.Contains(redisPersistenceModes, c.Persistence) {
+		return fmt.Errorf(
+			"invalid redis persistence %q, must be one of: %s",
+			c.Persistence,
+			strings.Join(redisPersistenceModes, ", "),
+		)
+	}
+	if c.EvictionPolicy != "" && !slices.Contains(redisEvictionPolicies, c.EvictionPolicy) {
+		return fmt.Errorf(
+			"invalid redis eviction policy %q, must be one of: %s",
+			c.EvictionPolicy,
+			strings.Join(redisEvictionPolicies, ", "),
+		)
+	}
+	return nil
+}
+
+// readProjectConfig reads and parses an orca.json file
+func readProjectConfig(path string) (*OrcaConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var config OrcaConfigFile
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+// writeProjectConfig writes the config to an orca.json file, referencing its schema
+func writeProjectConfig(path string, config *OrcaConfigFile) error {
+	config.Schema = configSchemaURL
+	data, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize configuration: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadProjectConfig loads orca.json for commands that can run without one.
+// A missing file at the default path yields an empty config, but a missing
+// file that the user explicitly asked for is an error.
+func loadProjectConfig(path string) *OrcaConfigFile {
+	config, err := readProjectConfig(path)
+	if errors.Is(err, fs.ErrNotExist) && path == defaultConfigPath {
+		return &OrcaConfigFile{}
+	} else if errors.Is(err, fs.ErrNotExist) {
+		printError(fmt.Sprintf("Config file not found: %s", path))
+		exit(1)
+	} else if err != nil {
+		printError(err.Error())
+		exit(1)
+	}
+	return config
+}
+
+// postgresServerArgs converts the Postgres config into `-c key=value` server arguments
+func postgresServerArgs(config *PostgresConfig) []string {
+	if config == nil {
+		return nil
+	}
+
+	settings := map[string]string{}
+	for key, value := range config.Settings {
+		settings[key] = value
+	}
+	if config.SharedBuffers != "" {
+		settings["shared_buffers"] = config.SharedBuffers
+	}
+	if config.MaxConnections > 0 {
+		settings["max_connections"] = fmt.Sprintf("%d", config.MaxConnections)
+	}
+	if config.WalLevel != "" {
+		settings["wal_level"] = config.WalLevel
+	}
+	if config.MaxWalSize != "" {
+		settings["max_wal_size"] = config.MaxWalSize
+	}
+	if config.MinWalSize != "" {
+		settings["min_wal_size"] = config.MinWalSize
+	}
+
+	// sort for a stable argument order, so existing containers can be compared
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, len(keys)*2)
+	for _, key := range keys {
+		args = append(args, "-c", fmt.Sprintf("%s=%s", key, settings[key]))
+	}
+	return args
+}
+
+// redisServerArgs converts the Redis config into redis-server arguments
+func redisServerArgs(config *RedisConfig) []string {
+	if config == nil {
+		config = &RedisConfig{}
+	}
+
+	var args []string
+	switch config.Persistence {
+	case "", "aof":
+		args = append(args, "--appendonly", "yes")
+		if config.Persistence == "aof" {
+			args = append(args, "--save", "")
+		}
+	case "rdb":
+		args = append(args, "--appendonly", "no", "--save", redisDefaultSaveSchedule)
+	case "both":
+		args = append(args, "--appendonly", "yes", "--save", redisDefaultSaveSchedule)
+	case "none":
+		args = append(args, "--appendonly", "no", "--save", "")
+	}
+
+	if config.MaxMemory != "" {
+		args = append(args, "--maxmemory", config.MaxMemory)
+	}
+	if config.EvictionPolicy != "" {
+		args = append(args, "--maxmemory-policy", config.EvictionPolicy)
+	}
+	return args
+}
+
+// resolveTimezone validates an IANA timezone name, resolving "local" to the
+// timezone of the host
+func resolveTimezone(name string) (string, error) {
+	if name == "local" {
+		name = hostTimezone()
+		if name == "" {
+			return "", fmt.Errorf("could not determine the local timezone, set an IANA name such as Europe/London instead")
+		}
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return "", fmt.Errorf("invalid timezone %q: %w", name, err)
+	}
+	return name, nil
+}
+
+// hostTimezone returns the IANA name of the host timezone, from TZ or the
+// /etc/localtime symlink, or empty when it cannot be determined
+func hostTimezone() string {
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" {
+		return tz
+	}
+	target, err := os.Readlink("/etc/localtime")
+	if err != nil {
+		return ""
+	}
+	_, name, found := strings.Cut(target, "zoneinfo/")
+	if !found {
+		return ""
+	}
+	return name
+}
+
+// readEnvFile parses a docker-style env file of KEY=VALUE lines. Blank lines and
+// lines starting with # are ignored, and a bare KEY takes its value from the
+// current environment, skipping it when unset.
+func readEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	defer file.Close()
+
+	env := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("%s:%d: invalid variable name %q", path, lineNumber, key)
+		}
+		if !found {
+			hostValue, ok := os.LookupEnv(key)
+			if !ok {
+				continue
+			}
+			value = hostValue
+		}
+		env[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	return env, nil
+}
+
+// coreEnv resolves the variables forwarded to the core container as sorted
+// KEY=VALUE pairs. A relative env file path is resolved against baseDir.
+func coreEnv(config *CoreConfig, baseDir string) ([]string, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	env := map[string]string{}
+	if config.EnvFile != "" {
+		path := config.EnvFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		fileEnv, err := readEnvFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range fileEnv {
+			env[key] = value
+		}
+	}
+	for key, value := range config.Env {
+		env[key] = value
+	}
+	if config.LogLevel != "" {
+		key, value, _ := strings.Cut(config.logLevelEnv(), "=")
+		env[key] = value
+	}
+
+	pairs := make([]string, 0, len(env))
+	for key, value := range env {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return pairs, nil
+}
+
+// configKey reads and writes a single orca.json setting addressed by a dotted key
+type configKey struct {
+	Description string
+	Get         func(config *OrcaConfigFile) string
+	Set         func(config *OrcaConfigFile, value string) error
+	// Values are the accepted values, when there is a fixed set, for completion
+	Values []string
+}
+
+// configKeys are the settings supported by `orca config get/set`
+var configKeys = map[string]configKey{
+	"core.logLevel": {
+		Description: "Log level of the Orca core - " + strings.Join(coreLogLevels, "|"),
+		Get: func(config *OrcaConfigFile) string {
+			if config.Core == nil {
+				return ""
+			}
+			return config.Core.LogLevel
+		},
+		Set: func(config *OrcaConfigFile, value string) error {
+			core := &CoreConfig{}
+			if config.Core != nil {
+				*core = *config.Core
+			}
+			core.LogLevel = strings.ToLower(value)
+			if err := core.validate(); err != nil {
+				return err
+			}
+			config.Core = core
+			return nil
+		},
+		Values: coreLogLevels,
+	},
+	"projectName": stringConfigKey("Name of the project the processor registers under",
+		func(config *OrcaConfigFile) *string { return &config.ProjectName },
+		func(value string) error {
+			if strings.TrimSpace(value) == "" {
+				return fmt.Errorf("projectName cannot be empty")
+			}
+			return nil
+		}),
+	"orcaConnectionString": stringConfigKey("Address of the Orca core, as host:port",
+		func(config *OrcaConfigFile) *string { return &config.OrcaConnectionString },
+		validateConfigAddress("orcaConnectionString")),
+	"processorConnectionString": stringConfigKey("Address the core reaches the processor at, as host:port",
+		func(config *OrcaConfigFile) *string { return &config.ProcessorConnectionString },
+		validateConfigAddress("processorConnectionString")),
+	"stackProject": stringConfigKey("Stack project whose core orcaConnectionString points at, empty for the default stack",
+		func(config *OrcaConfigFile) *string { return &config.StackProject },
+		func(value string) error {
+			if value == "" {
+				return nil
+			}
+			return validateStackProject(value)
+		}),
+	"processorPort": {
+		Description: "Port the processor listens on",
+		Get: func(config *OrcaConfigFile) string {
+			if config.ProcessorPort == 0 {
+				return ""
+			}
+			return strconv.Itoa(config.ProcessorPort)
+		},
+		Set: func(config *OrcaConfigFile, value string) error {
+			port, err := strconv.Atoi(value)
+			if err != nil || port < 1 || port > 65535 {
+				return fmt.Errorf("invalid processorPort %q, must be a port between 1 and 65535", value)
+			}
+			config.ProcessorPort = port
+			return nil
+		},
+	},
+	"timezone": stringConfigKey("IANA timezone of the store and core, e.g. Europe/London, or local, empty for UTC",
+		func(config *OrcaConfigFile) *string { return &config.Timezone },
+		func(value string) error {
+			if value == "" {
+				return nil
+			}
+			_, err := resolveTimezone(value)
+			return err
+		}),
+	"processor.packageManager": {
+		Description: "Package manager of the processor - " + strings.Join(packageManagers, "|"),
+		Get:         processorConfigGet(func(processor *ProcessorConfig) string { return processor.PackageManager }),
+		Set: processorConfigSet(func(processor *ProcessorConfig, value string) error {
+			if !slices.Contains(packageManagers, value) {
+				return fmt.Errorf("invalid processor.packageManager %q, must be one of: %s", value, strings.Join(packageManagers, ", "))
+			}
+			processor.PackageManager = value
+			return nil
+		}),
+		Values: packageManagers,
+	},
+	"processor.entrypoint": {
+		Description: "Python script starting the processor, relative to orca.json",
+		Get:         processorConfigGet(func(processor *ProcessorConfig) string { return processor.Entrypoint }),
+		Set: processorConfigSet(func(processor *ProcessorConfig, value string) error {
+			if value != "" && (filepath.IsAbs(value) || !strings.HasSuffix(value, ".py")) {
+				return fmt.Errorf("invalid processor.entrypoint %q, must be a .py file relative to orca.json", value)
+			}
+			processor.Entrypoint = value
+			return nil
+		}),
+	},
+	"processor.image": {
+		Description: "Image repository of the processor, e.g. ghcr.io/acme/speed",
+		Get:         processorConfigGet(func(processor *ProcessorConfig) string { return processor.Image }),
+		Set: processorConfigSet(func(processor *ProcessorConfig, value string) error {
+			if value != "" && !imageReferencePattern.MatchString(value) {
+				return fmt.Errorf("invalid processor.image %q, must be a lowercase repository such as ghcr.io/acme/speed", value)
+			}
+			processor.Image = value
+			return nil
+		}),
+	},
+	"processor.platforms": {
+		Description: "Comma separated platforms `orca push` builds, e.g. linux/amd64,linux/arm64",
+		Get: processorConfigGet(func(processor *ProcessorConfig) string {
+			return strings.Join(processor.Platforms, ",")
+		}),
+		Set: processorConfigSet(func(processor *ProcessorConfig, value string) error {
+			var platforms []string
+			for _, platform := range strings.Split(value, ",") {
+				if platform = strings.TrimSpace(platform); platform == "" {
+					continue
+				}
+				if !platformPattern.MatchString(platform) {
+					return fmt.Errorf("invalid platform %q, expected os/arch such as linux/arm64", platform)
+				}
+				platforms = append(platforms, platform)
+			}
+			processor.Platforms = platforms
+			return nil
+		}),
+	},
+	"backup.age": {
+		Description: "Comma separated age recipients `orca backup -encrypt` encrypts to, e.g. age1...",
+		Get:         backupConfigGet(func(backup *BackupConfig) string { return strings.Join(backup.Age, ",") }),
+		Set: backupConfigSet(func(backup *BackupConfig, value string) error {
+			backup.Age = splitConfigList(value)
+			return nil
+		}),
+	},
+	"backup.gpg": {
+		Description: "Comma separated GPG key ids or emails `orca backup -encrypt` encrypts to",
+		Get:         backupConfigGet(func(backup *BackupConfig) string { return strings.Join(backup.GPG, ",") }),
+		Set: backupConfigSet(func(backup *BackupConfig, value string) error {
+			backup.GPG = splitConfigList(value)
+			return nil
+		}),
+	},
+	"backup.endpoint": {
+		Description: "S3-compatible endpoint of s3:// backups, e.g. https://minio.example.com, empty for AWS",
+		Get:         backupConfigGet(func(backup *BackupConfig) string { return backup.Endpoint }),
+		Set: backupConfigSet(func(backup *BackupConfig, value string) error {
+			if value != "" && !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+				return fmt.Errorf("invalid backup.endpoint %q, must be an http(s) URL", value)
+			}
+			backup.Endpoint = value
+			return nil
+		}),
+	},
+	"backup.identity": {
+		Description: "age identity file `orca restore` decrypts backups with, relative to orca.json",
+		Get:         backupConfigGet(func(backup *BackupConfig) string { return backup.Identity }),
+		Set: backupConfigSet(func(backup *BackupConfig, value string) error {
+			backup.Identity = value
+			return nil
+		}),
+	},
+}
+
+var (
+	// imageReferencePattern matches an image repository, with an optional registry host and port
+	imageReferencePattern = regexp.MustCompile(`^([a-z0-9.-]+(:[0-9]+)?/)?[a-z0-9]+([._-][a-z0-9]+)*(/[a-z0-9]+([._-][a-z0-9]+)*)*$`)
+	// platformPattern matches a platform as os/arch[/variant]
+	platformPattern = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`)
+)
+
+// stringConfigKey is the config key of a top level string setting
+func stringConfigKey(description string, field func(config *OrcaConfigFile) *string, validate func(value string) error) configKey {
+	return configKey{
+		Description: description,
+		Get:         func(config *OrcaConfigFile) string { return *field(config) },
+		Set: func(config *OrcaConfigFile, value string) error {
+			if err := validate(value); err != nil {
+				return err
+			}
+			*field(config) = value
+			return nil
+		},
+	}
+}
+
+// validateConfigAddress returns the validation of a host:port setting, which may be emptied
+func validateConfigAddress(name string) func(value string) error {
+	return func(value string) error {
+		if value == "" {
+			return nil
+		}
+		if _, port, err := net.SplitHostPort(value); err != nil || port == "" {
+			return fmt.Errorf("invalid %s %q, expected host:port", name, value)
+		}
+		return nil
+	}
+}
+
+// processorConfigGet reads a setting of the processor section, which may be missing
+func processorConfigGet(field func(processor *ProcessorConfig) string) func(config *OrcaConfigFile) string {
+	return func(config *OrcaConfigFile) string {
+		if config.Processor == nil {
+			return ""
+		}
+		return field(config.Processor)
+	}
+}
+
+// processorConfigSet writes a setting of the processor section, creating it if needed
+func processorConfigSet(set func(processor *ProcessorConfig, value string) error) func(config *OrcaConfigFile, value string) error {
+	return func(config *OrcaConfigFile, value string) error {
+		processor := &ProcessorConfig{}
+		if config.Processor != nil {
+			*processor = *config.Processor
+		}
+		if err := set(processor, value); err != nil {
+			return err
+		}
+		config.Processor = processor
+		return nil
+	}
+}
+
+// backupConfigGet reads a setting of the backup section, empty when it is unset
+func backupConfigGet(field func(backup *BackupConfig) string) func(config *OrcaConfigFile) string {
+	return func(config *OrcaConfigFile) string {
+		if config.Backup == nil {
+			return ""
+		}
+		return field(config.Backup)
+	}
+}
+
+// backupConfigSet writes a setting of the backup section, creating it if needed
+func backupConfigSet(set func(backup *BackupConfig, value string) error) func(config *OrcaConfigFile, value string) error {
+	return func(config *OrcaConfigFile, value string) error {
+		backup := &BackupConfig{}
+		if config.Backup != nil {
+			*backup = *config.Backup
+		}
+		if err := set(backup, value); err != nil {
+			return err
+		}
+		if err := backup.validate(); err != nil {
+			return err
+		}
+		config.Backup = backup
+		return nil
+	}
+}
+
+// splitConfigList splits a comma separated setting, dropping empty items
+func splitConfigList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// configKeyNames returns the supported config keys, sorted
+func configKeyNames() []string {
+	names := make([]string, 0, len(configKeys))
+	for name := range configKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupConfigKey returns the setting for a dotted key
+func lookupConfigKey(name string) (configKey, error) {
+	key, ok := configKeys[name]
+	if !ok {
+		return configKey{}, fmt.Errorf(
+			"unknown config key %q, must be one of: %s",
+			name,
+			strings.Join(configKeyNames(), ", "),
+		)
+	}
+	return key, nil
+}
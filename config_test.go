@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigKeys(t *testing.T) {
+	config := &OrcaConfigFile{}
+	for _, set := range []struct{ key, value string }{
+		{"processorPort", "6000"},
+		{"orcaConnectionString", "localhost:33670"},
+		{"processor.packageManager", "uv"},
+		{"processor.image", "ghcr.io/acme/speed"},
+		{"processor.platforms", "linux/amd64, linux/arm64"},
+	} {
+		key, err := lookupConfigKey(set.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := key.Set(config, set.value); err != nil {
+			t.Errorf("set %s %s: %v", set.key, set.value, err)
+		}
+	}
+	if config.ProcessorPort != 6000 || config.Processor.PackageManager != "uv" || configKeys["processor.platforms"].Get(config) != "linux/amd64,linux/arm64" {
+		t.Errorf("config after set = %+v, processor %+v", config, config.Processor)
+	}
+
+	for _, set := range []struct{ key, value string }{
+		{"processorPort", "70000"},
+		{"orcaConnectionString", "localhost"},
+		{"processor.packageManager", "conda"},
+		{"processor.image", "ghcr.io/Acme/speed"},
+		{"processor.platforms", "arm64"},
+		{"projectName", ""},
+	} {
+		if err := configKeys[set.key].Set(config, set.value); err == nil {
+			t.Errorf("set %s %q succeeded", set.key, set.value)
+		}
+	}
+	if config.ProcessorPort != 6000 || config.Processor.PackageManager != "uv" {
+		t.Errorf("a rejected value was written: %+v", config)
+	}
+
+	global := &GlobalConfig{}
+	if err := globalConfigKeys["updateCheck.disabled"].Set(global, "true"); err != nil || !global.UpdateCheck.Disabled {
+		t.Errorf("set updateCheck.disabled = %v, %+v", err, global.UpdateCheck)
+	}
+	if err := globalConfigKeys["telemetry.endpoint"].Set(global, "localhost:4318"); err == nil {
+		t.Errorf("set telemetry.endpoint without a scheme succeeded")
+	}
+}
+
+func TestFindProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "processor", "algorithms")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Chdir(nested)
+	if path := findProjectConfig(); path != defaultConfigPath {
+		t.Errorf("findProjectConfig without an orca.json = %q", path)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, defaultConfigPath), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if path := findProjectConfig(); path != filepath.Join("..", "..", defaultConfigPath) {
+		t.Errorf("findProjectConfig from a subdirectory = %q", path)
+	}
+}
@@ -1,16 +1,49 @@
 package main
 
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
 const (
-	pgContainerName    = "orca-pg-instance"
-	redisContainerName = "orca-redis-instance"
-	orcaContainerName  = "orca-instance"
-	networkName        = "orca-network"
-	orcaInternalPort   = 3335
-	pgInternalPort     = 5432
-	redisInternalPort  = 6379
+	orcaInternalPort  = 3335
+	pgInternalPort    = 5432
+	redisInternalPort = 6379
 
 	// versions
 	orcaImageVersion = "0.14.2"
+
+	// small image used for one-off volume operations
+	helperImage = "alpine"
+)
+
+// images of the core stack components, keyed by component. `orca start --locked`
+// replaces them with the digests recorded in orca.lock.
+var stackImages = map[string]string{
+	componentPostgres: "postgres",
+	componentRedis:    "redis",
+	componentCore:     "ghcr.io/orca-telemetry/core:" + orcaImageVersion,
+}
+
+// resource names of the default stack project. Other projects insert their
+// name after the "orca-" prefix, e.g. orca-myproject-pg-instance.
+const (
+	defaultPgContainerName    = "orca-pg-instance"
+	defaultRedisContainerName = "orca-redis-instance"
+	defaultOrcaContainerName  = "orca-instance"
+	defaultNetworkName        = "orca-network"
+)
+
+// stackProject namespaces every docker resource the CLI manages, so that several
+// independent stacks can run side by side. Empty selects the default stack.
+var stackProject string
+
+var (
+	pgContainerName    = defaultPgContainerName
+	redisContainerName = defaultRedisContainerName
+	orcaContainerName  = defaultOrcaContainerName
+	networkName        = defaultNetworkName
 )
 
 var orcaContainers = []string{
@@ -21,6 +54,69 @@ var orcaContainers = []string{
 
 // follows pattern of <container-name>-data
 var orcaVolumes = []string{
-	"orca-pg-instance-data",
-	"orca-redis-instance-data",
+	pgContainerName + "-data",
+	redisContainerName + "-data",
+}
+
+// stackProjectPattern rejects "--" and a trailing '-', so that the "--" separating
+// the project from the component in a resource name is never part of the project
+var stackProjectPattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9_]|-[a-z0-9_])*$`)
+
+// namespaced returns the name of a default stack resource within the current stack project
+func namespaced(name string) string {
+	return namespacedFor(stackProject, name)
+}
+
+// namespacedFor returns the name of a default stack resource within the given stack project
+func namespacedFor(project, name string) string {
+	if project == "" {
+		return name
+	}
+	return "orca-" + project + "--" + strings.TrimPrefix(name, "orca-")
+}
+
+// validateStackProject rejects project names that could share resource names or
+// labels with another project
+func validateStackProject(project string) error {
+	if !stackProjectPattern.MatchString(project) {
+		return fmt.Errorf(
+			"invalid project name %q: use lowercase letters, digits, '_' and single '-' between them",
+			project,
+		)
+	}
+	if project == projectLabelValue("") {
+		return fmt.Errorf("project name %q is reserved for the stack without a project", project)
+	}
+	return nil
+}
+
+// setStackProject switches every resource name to the given stack project. The
+// name "default" selects the default stack, as it does in labels.
+func setStackProject(project string) error {
+	if project == projectLabelValue("") {
+		project = ""
+	}
+	if project != "" {
+		if err := validateStackProject(project); err != nil {
+			return err
+		}
+	}
+
+	stackProject = project
+	pgContainerName = namespaced(defaultPgContainerName)
+	redisContainerName = namespaced(defaultRedisContainerName)
+	orcaContainerName = namespaced(defaultOrcaContainerName)
+	networkName = namespaced(defaultNetworkName)
+
+	orcaContainers = []string{
+		pgContainerName,
+		redisContainerName,
+		orcaContainerName,
+	}
+	orcaVolumes = []string{
+		pgContainerName + "-data",
+		redisContainerName + "-data",
+	}
+	companionServices = buildCompanionServices()
+	return nil
 }
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestStackProjectNamesDoNotCollide(t *testing.T) {
+	t.Cleanup(func() { setStackProject("") })
+
+	// "pg" once named its core container like the default stack's Postgres, and
+	// "a-pg" its Postgres like the core of "a"
+	seen := map[string]string{}
+	for _, project := range []string{"", "pg", "a", "a-pg", "redis"} {
+		if err := setStackProject(project); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range append(append([]string{networkName}, orcaContainers...), orcaVolumes...) {
+			if other, ok := seen[name]; ok {
+				t.Errorf("project %q reuses %s of project %q", project, name, other)
+			}
+			seen[name] = project
+		}
+	}
+
+	for _, project := range []string{"a--pg", "a-", "-a", "A"} {
+		if err := setStackProject(project); err == nil {
+			t.Errorf("project %q was accepted", project)
+		}
+	}
+	if err := setStackProject("default"); err != nil || stackProject != "" {
+		t.Errorf("project default selected %q (%v), want the default stack", stackProject, err)
+	}
+	if err := validateStackProject("default"); err == nil {
+		t.Error("default was accepted as the name of a new project")
+	}
+}
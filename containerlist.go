@@ -0,0 +1,142 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// listedContainer is a container as listed by `docker ps`
+type listedContainer struct {
+	Name string
+	// Status is docker's description, e.g. "Up 2 hours" or "Exited (0) 5 minutes ago"
+	Status string
+	// State is running, paused, exited, created or restarting
+	State string
+	Image string
+	// Ports maps published tcp ports of the container to their host ports
+	Ports  map[int]string
+	Labels map[string]string
+}
+
+// containerList is every container of the engine, keyed by name, read with a single
+// `docker ps` so that reporting on several containers costs one docker call
+type containerList map[string]listedContainer
+
+// listContainerFormat lists each container on a line, in the order of the fields of
+// listContainers
+var listContainerFormat = strings.Join([]string{
+	"{{.Names}}",
+	"{{.Status}}",
+	"{{.State}}",
+	"{{.Image}}",
+	"{{.Ports}}",
+	`{{.Label "` + projectLabel + `"}}`,
+	`{{.Label "` + componentLabel + `"}}`,
+	`{{.Label "` + imageProjectLabel + `"}}`,
+}, "\t")
+
+// listContainers lists every container, whether running or not. A failing docker
+// lists none, so every container reads as not found.
+func listContainers() containerList {
+	containers := containerList{}
+	output, err := dockerCommand("ps", "-a", "--no-trunc", "--format", listContainerFormat).Output()
+	if err != nil {
+		return containers
+	}
+	// lines are not trimmed, as trailing fields are empty for containers without labels
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(strings.TrimSuffix(line, "\r"), "\t")
+		if len(fields) != 8 {
+			continue
+		}
+		containers[fields[0]] = listedContainer{
+			Name:   fields[0],
+			Status: fields[1],
+			State:  fields[2],
+			Image:  fields[3],
+			Ports:  parseListedPorts(fields[4]),
+			Labels: map[string]string{
+				projectLabel:      fields[5],
+				componentLabel:    fields[6],
+				imageProjectLabel: fields[7],
+			},
+		}
+	}
+	return containers
+}
+
+// parseListedPorts parses the ports column of `docker ps`, e.g.
+// "0.0.0.0:32768->5432/tcp, :::32768->5432/tcp", skipping ports not published
+func parseListedPorts(column string) map[int]string {
+	ports := map[int]string{}
+	for _, entry := range strings.Split(column, ",") {
+		host, internal, found := strings.Cut(strings.TrimSpace(entry), "->")
+		if !found {
+			continue
+		}
+		port, protocol, _ := strings.Cut(internal, "/")
+		internalPort, err := strconv.Atoi(port)
+		if err != nil || (protocol != "" && protocol != "tcp") {
+			continue
+		}
+		hostPort := host[strings.LastIndex(host, ":")+1:]
+		if _, err := strconv.Atoi(hostPort); err != nil {
+			continue
+		}
+		if _, ok := ports[internalPort]; !ok {
+			ports[internalPort] = hostPort
+		}
+	}
+	return ports
+}
+
+// component returns the container of the stack project labelled as the given
+// component, or legacy when none is, as for containers created before labelling
+func (l containerList) component(component, legacy string) string {
+	for _, container := range l {
+		if container.Labels[componentLabel] == component && container.Labels[projectLabel] == projectLabelValue(stackProject) {
+			return container.Name
+		}
+	}
+	return legacy
+}
+
+// status returns running, stopped or not found, as getContainerStatus does
+func (l containerList) status(containerName string) string {
+	container, ok := l[containerName]
+	if !ok {
+		return "not found"
+	}
+	return containerStatusFromDescription(container.Status)
+}
+
+// port returns the host port an internal port of a container is published on, or
+// the internal port itself when it is not published, as getContainerPort does
+func (l containerList) port(containerName string, internalPort int) string {
+	if hostPort, ok := l[containerName].Ports[internalPort]; ok {
+		return hostPort
+	}
+	return strconv.Itoa(internalPort)
+}
+
+// deployedProcessors returns the processor containers of the stack project, sorted
+// by name
+func (l containerList) deployedProcessors() []componentStatus {
+	processors := []componentStatus{}
+	for _, container := range l {
+		if container.Labels[componentLabel] != componentProcessor || container.Labels[projectLabel] != projectLabelValue(stackProject) {
+			continue
+		}
+		processors = append(processors, componentStatus{
+			Name:      container.Labels[imageProjectLabel],
+			Container: container.Name,
+			Image:     container.Image,
+			Status:    container.State,
+		})
+	}
+	slices.SortFunc(processors, func(a, b componentStatus) int {
+		return strings.Compare(a.Container, b.Container)
+	})
+	return processors
+}
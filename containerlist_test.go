@@ -0,0 +1,55 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParseListedPorts(t *testing.T) {
+	ports := parseListedPorts("0.0.0.0:32768->5432/tcp, :::32768->5432/tcp, [::]:32770->6379/tcp, 8080/tcp, 0.0.0.0:5353->53/udp")
+	want := map[int]string{5432: "32768", 6379: "32770"}
+	if !maps.Equal(ports, want) {
+		t.Errorf("parseListedPorts = %v, want %v", ports, want)
+	}
+	if ports := parseListedPorts(""); len(ports) != 0 {
+		t.Errorf("parseListedPorts of no ports = %v", ports)
+	}
+}
+
+func TestContainerListComponent(t *testing.T) {
+	if err := setStackProject(""); err != nil {
+		t.Fatal(err)
+	}
+	containers := containerList{
+		"pg-renamed": {Name: "pg-renamed", Labels: map[string]string{projectLabel: "default", componentLabel: componentPostgres}},
+		"other-core": {Name: "other-core", Labels: map[string]string{projectLabel: "staging", componentLabel: componentCore}},
+	}
+	if got := containers.component(componentPostgres, pgContainerName); got != "pg-renamed" {
+		t.Errorf("component(postgres) = %q, want the labelled container", got)
+	}
+	if got := containers.component(componentCore, orcaContainerName); got != orcaContainerName {
+		t.Errorf("component(core) = %q, want the legacy name as the only labelled core is another project's", got)
+	}
+}
+
+func TestWithManaged(t *testing.T) {
+	useFakeEngine(t)
+	if err := setStackProject(""); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		append(append([]string{"volume", "create"}, labelArgs(componentCanaryStore)...), "orca-canary-data"),
+		{"volume", "create", pgContainerName + "-data"},
+	} {
+		if output, err := dockerCommand(args...).CombinedOutput(); err != nil {
+			t.Fatalf("%v: %s", err, output)
+		}
+	}
+
+	// the unlabelled pg volume is kept as a legacy one, the redis volume does not exist
+	got := withManaged("volume", orcaVolumes)
+	if want := []string{"orca-canary-data", pgContainerName + "-data"}; !slices.Equal(got, want) {
+		t.Errorf("withManaged = %v, want %v", got, want)
+	}
+}
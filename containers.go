@@ -1,10 +1,13 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
 	"net"
-	"os/exec"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 func isPortAvailable(port int) bool {
@@ -27,18 +30,34 @@ func findAvailablePort(startPort int) int {
 }
 
 // startPostgres starts the postgres instance that orca needs.
-func startPostgres(networkName string) {
+// Tuning settings are only applied when the container is created.
+func startPostgres(networkName string, config *PostgresConfig) {
+	serverArgs := postgresServerArgs(config)
 	exists := checkStartContainer(pgContainerName)
 
-	if !exists {
+	if exists {
+		warnOnSettingsDrift(pgContainerName, serverArgs)
+	} else {
+		if config != nil && config.Locale != "" && volumeExists(pgContainerName+"-data") {
+			fmt.Fprintln(os.Stderr, warningStyle.Render(
+				"The store is already initialised, so the configured locale is not applied to it.",
+			))
+		}
+
 		// create or start a volume
-		volumeName := checkCreateVolume(pgContainerName)
+		volumeName := checkCreateVolume(pgContainerName, componentPostgres)
+
+		hostPort, err := assignHostPort(pgContainerName, pgInternalPort, 0)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
 
 		// run container with volume mounted
 		args := []string{
 			"run",
 			"-d",
-			"-p", "0:5432",
+			"-p", fmt.Sprintf("%d:%d", hostPort, pgInternalPort),
 			"--name",
 			pgContainerName,
 			"--network",
@@ -51,66 +70,292 @@ func startPostgres(networkName string) {
 			"POSTGRES_DB=orca",
 			"-v",
 			volumeName + ":/var/lib/postgresql",
-			"postgres",
 		}
+		if config != nil && config.Locale != "" {
+			// initdb only runs when the volume is empty
+			args = append(args,
+				"-e", "POSTGRES_INITDB_ARGS=--locale="+config.Locale,
+				"-e", "LANG="+config.Locale,
+			)
+		}
+		args = append(args, labelArgs(componentPostgres)...)
+		args = append(args,
+			stackImages[componentPostgres],
+			// the image's default command, repeated so server settings can follow
+			"postgres",
+		)
+		args = append(args, serverArgs...)
 
-		runCmd := exec.Command("docker", args...)
 		// stream container creation logs
-		streamCommandOutput(runCmd, "PostgreSQL Store:")
+		streamDockerCommand("PostgreSQL Store:", args...)
 	}
 }
 
-func startRedis(networkName string) {
+// warnOnSettingsDrift warns when an existing container was created with different
+// server arguments than the ones currently configured
+func warnOnSettingsDrift(containerName string, wantArgs []string) {
+	output, err := dockerCommand(
+		"inspect", "--format", "{{json .Args}}", containerName,
+	).Output()
+	if err != nil {
+		return
+	}
+
+	var currentArgs []string
+	if err := json.Unmarshal(output, &currentArgs); err != nil {
+		return
+	}
+	// the first argument is the server binary itself
+	if len(currentArgs) > 0 {
+		currentArgs = currentArgs[1:]
+	}
+
+	if !slices.Equal(currentArgs, wantArgs) {
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf(
+			"%s was created with different settings. Settings only apply when the container is created.",
+			containerName,
+		)))
+		fmt.Fprintf(os.Stderr,
+			"Recreate it to apply them (data is kept in its volume): docker rm -f %s && orca start\n",
+			containerName,
+		)
+	}
+}
+
+// startRedis starts the redis instance that orca needs.
+// Persistence and eviction settings are only applied when the container is created.
+func startRedis(networkName string, config *RedisConfig) {
+	serverArgs := redisServerArgs(config)
 	exists := checkStartContainer(redisContainerName)
 
-	if !exists {
+	if exists {
+		warnOnSettingsDrift(redisContainerName, serverArgs)
+	} else {
 		// create or start a volume
-		volumeName := checkCreateVolume(redisContainerName)
+		volumeName := checkCreateVolume(redisContainerName, componentRedis)
+
+		hostPort, err := assignHostPort(redisContainerName, redisInternalPort, 0)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
 
 		// run container with volume mounted
 		args := []string{
 			"run",
 			"--name", redisContainerName,
 			"--network", networkName,
-			"-p", "0:6379",
+			"-p", fmt.Sprintf("%d:%d", hostPort, redisInternalPort),
 			"-d",
 			"-v", volumeName + ":/data",
-			"redis",
-			"redis-server", "--appendonly", "yes",
 		}
+		args = append(args, labelArgs(componentRedis)...)
+		args = append(args, stackImages[componentRedis], "redis-server")
+		args = append(args, serverArgs...)
 
-		runCmd := exec.Command("docker", args...)
 		// stream container creation logs
-		streamCommandOutput(runCmd, "Redis Cache:")
+		streamDockerCommand("Redis Cache:", args...)
 	}
 }
 
-func startOrca(networkName string) {
+// startOrca starts the orca core, publishing it on hostPort, or on a free port when
+// zero. Extra environment variables, given as KEY=VALUE pairs, are only applied when
+// the container is created.
+func startOrca(networkName string, env []string, hostPort int) {
 	exists := checkStartContainer(orcaContainerName)
 
-	if !exists {
+	if exists {
+		warnOnEnvDrift(orcaContainerName, env)
+	} else {
 		preferredPort := 33670
-		availablePort := findAvailablePort(preferredPort)
-		if availablePort == -1 {
-			log.Fatal("No available ports found")
+		availablePort := hostPort
+		if availablePort == 0 {
+			var err error
+			availablePort, err = assignHostPort(orcaContainerName, orcaInternalPort, preferredPort)
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
 		}
-		portMapping := fmt.Sprintf("%d:3335", availablePort)
-		args := []string{
-			"run",
-			"-d",
-			"--name",
-			orcaContainerName,
-			"--network",
-			networkName,
-			"--add-host", "host.docker.internal:host-gateway",
-			"-p", portMapping,
-			"-e", fmt.Sprintf("ORCA_CONNECTION_STRING=postgresql://orca:orca@%s:5432/orca?sslmode=disable", pgContainerName),
-			"-e", "ORCA_PORT=3335",
-			"-e", "ORCA_LOG_LEVEL=DEBUG",
-			fmt.Sprintf("ghcr.io/orca-telemetry/core:%v", orcaImageVersion),
-			"-migrate",
+		args := coreRunArgs(orcaContainerName, pgContainerName, componentCore, stackImages[componentCore], networkName, availablePort, env)
+		streamDockerCommand("Orca-Core:", args...)
+	}
+}
+
+// coreRunArgs returns the `docker run` arguments of a core container using the store
+// of storeContainer, migrating it on start
+func coreRunArgs(containerName, storeContainer, component, image, networkName string, hostPort int, env []string) []string {
+	args := []string{
+		"run",
+		"-d",
+		"--name",
+		containerName,
+		"--network",
+		networkName,
+		"--add-host", "host.docker.internal:host-gateway",
+		"-p", fmt.Sprintf("%d:3335", hostPort),
+		"-e", fmt.Sprintf("ORCA_CONNECTION_STRING=postgresql://orca:orca@%s:5432/orca?sslmode=disable", storeContainer),
+		"-e", "ORCA_PORT=3335",
+		"-e", "ORCA_LOG_LEVEL=DEBUG",
+	}
+	// later variables win, so configured ones can override the defaults above
+	for _, pair := range env {
+		args = append(args, "-e", pair)
+	}
+	args = append(args, labelArgs(component)...)
+	return append(args, image, "-migrate")
+}
+
+// warnOnEnvDrift warns when an existing container is missing any of the wanted
+// environment variables, as they are only applied when the container is created
+func warnOnEnvDrift(containerName string, wantEnv []string) {
+	if len(wantEnv) == 0 {
+		return
+	}
+
+	currentEnv, err := getContainerEnv(containerName)
+	if err != nil {
+		return
+	}
+
+	for _, pair := range wantEnv {
+		if !slices.Contains(currentEnv, pair) {
+			fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf(
+				"%s was created with a different environment. Environment variables only apply when the container is created.",
+				containerName,
+			)))
+			fmt.Fprintf(os.Stderr, "Recreate it to apply them: docker rm -f %s && orca start\n", containerName)
+			return
+		}
+	}
+}
+
+// getContainerEnv returns the KEY=VALUE environment a container was created with
+func getContainerEnv(containerName string) ([]string, error) {
+	output, err := dockerCommand(
+		"inspect", "--format", "{{json .Config.Env}}", containerName,
+	).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var env []string
+	if err := json.Unmarshal(output, &env); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// removeOrcaIfEnvChanged removes the core container when it lacks the given
+// variable, so that the next start recreates it. The core keeps no state of its
+// own, so recreating it is safe.
+func removeOrcaIfEnvChanged(pair string) error {
+	currentEnv, err := getContainerEnv(orcaContainerName)
+	if err != nil || slices.Contains(currentEnv, pair) {
+		// a missing container is created with the right environment anyway
+		return nil
+	}
+
+	key, value, _ := strings.Cut(pair, "=")
+	current := "unset"
+	for _, env := range currentEnv {
+		if previous, ok := strings.CutPrefix(env, key+"="); ok {
+			current = previous
+		}
+	}
+	return removeOrcaToRecreate(fmt.Sprintf("%s changed from %s to %s", key, current, value))
+}
+
+// removeOrcaToRecreate removes the core container so that the next start recreates
+// it, first telling the user why, as removing a running core interrupts the windows
+// it is processing
+func removeOrcaToRecreate(reason string) error {
+	fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("%s, so %s is recreated to apply it. Windows it is processing are interrupted.", reason, orcaContainerName)))
+	fmt.Fprintf(os.Stderr, "Removing %s... ", orcaContainerName)
+	output, err := dockerCommand("rm", "-f", orcaContainerName).CombinedOutput()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, renderError("FAILED"))
+		return fmt.Errorf("failed to remove %s: %w: %s", orcaContainerName, err, strings.TrimSpace(string(output)))
+	}
+	fmt.Fprintln(os.Stderr, renderSuccess("REMOVED"))
+	return nil
+}
+
+// getPublishedHostPort returns the host port a container's port is bound to. Unlike
+// the port reported by `docker port`, it is known while the container is stopped.
+func getPublishedHostPort(containerName string, internalPort int) (int, error) {
+	output, err := dockerCommand("inspect", "--format", "{{json .HostConfig.PortBindings}}", containerName).Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect %s: %w", containerName, err)
+	}
+	var bindings map[string][]struct{ HostPort string }
+	if err := json.Unmarshal(output, &bindings); err != nil {
+		return 0, fmt.Errorf("failed to parse the port bindings of %s: %w", containerName, err)
+	}
+	for _, binding := range bindings[fmt.Sprintf("%d/tcp", internalPort)] {
+		if port, err := strconv.Atoi(binding.HostPort); err == nil && port > 0 {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("%s does not publish port %d on a fixed host port", containerName, internalPort)
+}
+
+// checkOrcaPort fails when the host port configured for the core is taken by
+// anything other than the core container itself
+func checkOrcaPort(port int) error {
+	if current, err := getPublishedHostPort(orcaContainerName, orcaInternalPort); err == nil && current == port {
+		return nil
+	}
+	if !isPortAvailable(port) {
+		return fmt.Errorf(
+			"port %d of orcaConnectionString in orca.json is in use by another process. Free it, or set orcaConnectionString to a free port",
+			port,
+		)
+	}
+	return nil
+}
+
+// removeOrcaIfPortChanged removes the core container when it is published on another
+// host port than the given one, so that the next start recreates it on that port
+func removeOrcaIfPortChanged(port int) error {
+	current, err := getPublishedHostPort(orcaContainerName, orcaInternalPort)
+	if getContainerStatus(orcaContainerName) == "not found" || (err == nil && current == port) {
+		return nil
+	}
+
+	reason := fmt.Sprintf("The core port changed to %d", port)
+	if err == nil {
+		reason = fmt.Sprintf("The core port changed from %d to %d", current, port)
+	}
+	return removeOrcaToRecreate(reason)
+}
+
+// restartComponentContainer returns the container name of a stack component
+func restartComponentContainer(component string) string {
+	switch component {
+	case "postgres":
+		return pgContainerName
+	case "redis":
+		return redisContainerName
+	default:
+		return orcaContainerName
+	}
+}
+
+// applyRestartPolicies sets the restart policy of each component's container.
+// Policies can be changed on existing containers, so no recreation is needed.
+func applyRestartPolicies(policies map[string]string) error {
+	for _, component := range restartComponents {
+		policy, ok := policies[component]
+		if !ok {
+			continue
+		}
+		containerName := restartComponentContainer(component)
+		output, err := dockerCommand("update", "--restart", policy, containerName).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to set restart policy of %s: %w: %s", containerName, err, strings.TrimSpace(string(output)))
 		}
-		runCmd := exec.Command("docker", args...)
-		streamCommandOutput(runCmd, "Orca-Core:")
+		fmt.Fprintf(os.Stderr, "Restart policy of %s set to %s\n", containerName, policy)
 	}
+	return nil
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// followCoreStartup streams the logs a core container wrote since the given time
+// until its gRPC endpoint answers, then stops following. It fails when the core exits
+// or does not become ready within startupTimeout, so that crashes such as a failed
+// migration show up right away.
+func followCoreStartup(containerName string, since time.Time) error {
+	// the fake engine starts no core to probe
+	if _, fake := engine.(fakeEngine); fake {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+	defer cancel()
+
+	// allow for clock skew between the host and the docker daemon
+	since = since.Add(-time.Second)
+	logsCtx, stopLogs := context.WithCancel(ctx)
+	logsDone := streamContainerLogs(logsCtx, containerName, since, dimStyle.Render("core |"))
+	defer func() {
+		stopLogs()
+		<-logsDone
+	}()
+
+	for {
+		state, err := getContainerState(containerName)
+		if err == nil && state.Status != "running" && state.Status != "created" && state.Status != "restarting" {
+			// let the last log lines through before reporting
+			time.Sleep(pollInterval)
+			return fmt.Errorf("%s %s with code %d during startup, see the logs above", containerName, state.Status, state.ExitCode)
+		}
+
+		probeCtx, cancelProbe := context.WithTimeout(ctx, max(pollInterval, time.Second))
+		err = probeCore(probeCtx, containerName)
+		cancelProbe()
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s did not become ready within %s (%v), raise the limit with --startup-timeout", containerName, startupTimeout, err)
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// streamContainerLogs follows a container's logs from since, writing each line to
+// stderr under prefix until ctx is done. The returned channel closes once it stops.
+func streamContainerLogs(ctx context.Context, containerName string, since time.Time, prefix string) <-chan struct{} {
+	done := make(chan struct{})
+	reader, writer := io.Pipe()
+	cmd := dockerCommandContext(ctx, "logs", "--follow", "--since", since.UTC().Format(time.RFC3339), containerName)
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			fmt.Fprintln(os.Stderr, prefix, scanner.Text())
+		}
+		io.Copy(io.Discard, reader)
+	}()
+
+	go func() {
+		defer close(done)
+		cmd.Run()
+		writer.Close()
+		wg.Wait()
+	}()
+	return done
+}
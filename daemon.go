@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// daemonQueryTimeout bounds queries of commands to the daemon, which fall back to
+// contacting the core themselves when it does not answer in time
+const daemonQueryTimeout = 250 * time.Millisecond
+
+// daemonPaths returns the socket the daemon of the stack project listens on, and the
+// log it writes when started in the background
+func daemonPaths() (socket, log string, err error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", "", err
+	}
+	name := projectLabelValue(stackProject)
+	return filepath.Join(dir, "daemon", name+".sock"), filepath.Join(dir, "daemon", name+".log"), nil
+}
+
+// daemonStatus is the state of the daemon, as reported by `orca daemon status -o`
+type daemonStatus struct {
+	PID  int    `json:"pid"`
+	Core string `json:"core"`
+	// FetchedAt is when the registry was last read from the core
+	FetchedAt  time.Time `json:"fetchedAt,omitzero"`
+	Processors int       `json:"processors"`
+	// Error is why the last refresh failed, while the previous registry is served
+	Error string `json:"error,omitempty"`
+}
+
+// registryDaemon keeps a connection to the core and the registry it last returned,
+// serving both to other commands over a unix socket
+type registryDaemon struct {
+	address  string
+	interval time.Duration
+	timeout  time.Duration
+	client   pb.OrcaCoreClient
+
+	mu        sync.RWMutex
+	state     *pb.InternalState
+	fetchedAt time.Time
+	lastErr   error
+
+	// shutdown is closed by a stop request
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+}
+
+// refresh reads the registry from the core, keeping the previous one on failure.
+// A refreshed registry is also saved as the registry cache.
+func (d *registryDaemon) refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	state, err := exposeCompressed(ctx, d.client, &pb.ExposeSettings{})
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastErr = err
+	if err != nil {
+		return err
+	}
+	d.state, d.fetchedAt = state, time.Now()
+	if err := saveRegistryCache(state); err != nil {
+		logDebug("failed to save the registry cache: %v", err)
+	}
+	return nil
+}
+
+func (d *registryDaemon) status() daemonStatus {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	status := daemonStatus{
+		PID:        os.Getpid(),
+		Core:       d.address,
+		FetchedAt:  d.fetchedAt,
+		Processors: len(d.state.GetProcessors()),
+	}
+	if d.lastErr != nil {
+		status.Error = d.lastErr.Error()
+	}
+	return status
+}
+
+// handler serves GET /status, GET /registry as protobuf, POST /refresh and POST /stop.
+// A registry request naming another core with ?core= is refused, so that callers
+// read the registry of that core themselves.
+func (d *registryDaemon) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(d.status())
+	})
+	mux.HandleFunc("GET /registry", func(w http.ResponseWriter, r *http.Request) {
+		if core := r.URL.Query().Get("core"); core != "" && core != d.address {
+			http.Error(w, fmt.Sprintf("the daemon serves the registry of %s, not %s", d.address, core), http.StatusConflict)
+			return
+		}
+		d.mu.RLock()
+		state := d.state
+		d.mu.RUnlock()
+		if state == nil {
+			http.Error(w, "the registry has not been read from the core yet", http.StatusServiceUnavailable)
+			return
+		}
+		data, err := proto.Marshal(state)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(data)
+	})
+	mux.HandleFunc("POST /refresh", func(w http.ResponseWriter, r *http.Request) {
+		if err := d.refresh(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /stop", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+		d.shutdownOnce.Do(func() { close(d.shutdown) })
+	})
+	return mux
+}
+
+// runDaemon serves the registry of the core at address on the daemon socket,
+// refreshing it every interval, until interrupted or stopped
+func runDaemon(address string, interval, timeout time.Duration) error {
+	socket, _, err := daemonPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := queryDaemonStatus(); err == nil {
+		return fmt.Errorf("a daemon is already running for project %s, stop it with `orca daemon stop`", projectLabelValue(stackProject))
+	}
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return fmt.Errorf("failed to create the daemon directory: %w", err)
+	}
+	// a socket left by a daemon that did not stop cleanly refuses connections
+	os.Remove(socket)
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socket, err)
+	}
+	defer os.Remove(socket)
+
+	conn, client, err := dialCore(address)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	defer conn.Close()
+
+	daemon := &registryDaemon{
+		address:  address,
+		interval: interval,
+		timeout:  timeout,
+		client:   client,
+		shutdown: make(chan struct{}),
+	}
+	if err := daemon.refresh(); err != nil {
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Failed to read the registry from %s, retrying every %s: %v", address, interval, err)))
+	}
+
+	server := &http.Server{Handler: daemon.handler()}
+	go server.Serve(listener)
+	defer func() {
+		// let the reply to a stop request finish before closing
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	fmt.Fprintf(os.Stderr, "Serving the registry of %s on %s, refreshing every %s\n", address, socket, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sigs:
+			return nil
+		case <-daemon.shutdown:
+			return nil
+		case <-ticker.C:
+			previous := daemon.status().Error
+			if err := daemon.refresh(); err != nil && err.Error() != previous {
+				fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("[%s] Failed to refresh the registry: %v", time.Now().Format(time.TimeOnly), err)))
+			} else if err == nil && previous != "" {
+				fmt.Fprintf(os.Stderr, "[%s] The registry is refreshing again\n", time.Now().Format(time.TimeOnly))
+			}
+		}
+	}
+}
+
+// daemonRequest makes a request of the daemon of the stack project
+func daemonRequest(method, path string, timeout time.Duration) ([]byte, error) {
+	socket, _, err := daemonPaths()
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	request, err := http.NewRequest(method, "http://orca-daemon"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 300 {
+		return nil, fmt.Errorf("daemon: %s", bytes.TrimSpace(body))
+	}
+	return body, nil
+}
+
+// queryDaemonStatus returns the status of the running daemon
+func queryDaemonStatus() (daemonStatus, error) {
+	var status daemonStatus
+	body, err := daemonRequest(http.MethodGet, "/status", daemonQueryTimeout)
+	if err != nil {
+		return status, err
+	}
+	err = json.Unmarshal(body, &status)
+	return status, err
+}
+
+// daemonRegistry returns the registry the daemon caches for the core at address, or
+// for whichever core it serves when address is empty. It fails quickly when no daemon
+// is running or it serves another core, so that callers can read the registry themselves.
+func daemonRegistry(address string) (*pb.InternalState, error) {
+	body, err := daemonRequest(http.MethodGet, "/registry?core="+url.QueryEscape(address), daemonQueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	state := &pb.InternalState{}
+	if err := proto.Unmarshal(body, state); err != nil {
+		return nil, fmt.Errorf("failed to parse the registry from the daemon: %w", err)
+	}
+	return state, nil
+}
+
+// exposeRegistry reads the registry of the core at address from the daemon when it
+// caches that core's, and from the core through client otherwise. The daemon
+// connects without TLS, so callers connecting with TLS use exposeCompressed instead.
+func exposeRegistry(ctx context.Context, client pb.OrcaCoreClient, address string, settings *pb.ExposeSettings) (*pb.InternalState, error) {
+	state, err := daemonRegistry(address)
+	if err != nil {
+		logDebug("reading the registry from the core: %v", err)
+		return exposeCompressed(ctx, client, settings)
+	}
+	if exclude := settings.GetExcludeProject(); exclude != "" {
+		// as the core does for ExcludeProject
+		kept := &pb.InternalState{}
+		for _, processor := range state.GetProcessors() {
+			if processor.GetProjectName() != exclude {
+				kept.Processors = append(kept.Processors, processor)
+			}
+		}
+		state = kept
+	}
+	return state, nil
+}
+
+// startDaemon runs `orca daemon run` in the background with the given flags,
+// logging to the daemon log, and waits for it to answer
+func startDaemon(flags []string) error {
+	if status, err := queryDaemonStatus(); err == nil {
+		return fmt.Errorf("a daemon is already running for project %s (pid %d)", projectLabelValue(stackProject), status.PID)
+	}
+	_, logPath, err := daemonPaths()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(logPath), 0700); err != nil {
+		return fmt.Errorf("failed to create the daemon directory: %w", err)
+	}
+	log, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open the daemon log: %w", err)
+	}
+	defer log.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the orca executable: %w", err)
+	}
+	cmd := exec.Command(executable, slices.Concat([]string{"daemon"}, flags, []string{"run"})...)
+	cmd.Env = append(os.Environ(), "ORCA_PROJECT="+stackProject, "ORCA_NO_UPDATE_CHECK=1")
+	cmd.Stdout = log
+	cmd.Stderr = log
+	cmd.SysProcAttr = detachedProcessAttr()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start the daemon: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("the daemon exited (%v), see %s", err, logPath)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if _, err := queryDaemonStatus(); err == nil {
+			cmd.Process.Release()
+			return nil
+		}
+	}
+	return fmt.Errorf("the daemon did not answer within 10s, see %s", logPath)
+}
+
+// refreshDaemon has the running daemon read the registry from the core now
+func refreshDaemon(timeout time.Duration) error {
+	_, err := daemonRequest(http.MethodPost, "/refresh", timeout)
+	return err
+}
+
+// stopDaemon asks the running daemon to exit
+func stopDaemon() error {
+	if _, err := daemonRequest(http.MethodPost, "/stop", 2*time.Second); err != nil {
+		if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ECONNREFUSED) {
+			return fmt.Errorf("no daemon is running for project %s", projectLabelValue(stackProject))
+		}
+		return err
+	}
+	return nil
+}
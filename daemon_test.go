@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+func TestDaemonServesRegistry(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	core := &fakeCore{processors: []*pb.ProcessorRegistration{{
+		Name:                "stats",
+		ProjectName:         "stats",
+		SupportedAlgorithms: []*pb.Algorithm{{Name: "Mean", Version: "1.0.0"}},
+	}}}
+	address := startFakeCore(t, core)
+
+	if _, err := daemonRegistry(address); err == nil {
+		t.Fatal("daemonRegistry answered with no daemon running")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- runDaemon(address, time.Hour, 5*time.Second) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := queryDaemonStatus()
+		if err == nil && !status.FetchedAt.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the daemon did not serve the registry: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if got := completeRegistry(registryAlgorithmNames); !slices.Equal(got, []string{"Mean"}) {
+		t.Errorf("completeRegistry = %v, want the algorithms served by the daemon", got)
+	}
+
+	// a refresh picks up processors registered since
+	core.processors = append(core.processors, &pb.ProcessorRegistration{Name: "routes"})
+	if err := refreshDaemon(5 * time.Second); err != nil {
+		t.Fatalf("refreshDaemon: %v", err)
+	}
+	state, err := daemonRegistry(address)
+	if err != nil {
+		t.Fatalf("daemonRegistry: %v", err)
+	}
+	if got := registryProcessorNames(state); !slices.Equal(got, []string{"routes", "stats"}) {
+		t.Errorf("processors after refresh = %v", got)
+	}
+	if cached, err := loadRegistryCache(); err != nil || len(cached.GetProcessors()) != 2 {
+		t.Errorf("the registry cache was not updated by the daemon: %v", err)
+	}
+
+	// the registry of another core is read from that core
+	if _, err := daemonRegistry("localhost:1"); err == nil {
+		t.Error("daemonRegistry answered for a core the daemon does not serve")
+	}
+	core.processors = core.processors[:1]
+	state, err = exposeRegistry(context.Background(), nil, address, &pb.ExposeSettings{ExcludeProject: "stats"})
+	if err != nil {
+		t.Fatalf("exposeRegistry: %v", err)
+	}
+	if got := registryProcessorNames(state); !slices.Equal(got, []string{"routes"}) {
+		t.Errorf("processors of the daemon excluding project stats = %v", got)
+	}
+
+	if err := stopDaemon(); err != nil {
+		t.Fatalf("stopDaemon: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("runDaemon: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the daemon did not stop")
+	}
+	if err := stopDaemon(); err == nil {
+		t.Error("stopDaemon succeeded with no daemon running")
+	}
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// detachedProcessAttr starts the daemon in a session of its own, so that it
+// outlives the terminal it was started from
+func detachedProcessAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// componentProcessor is the component label of processor containers run by `orca deploy`
+const componentProcessor = "processor"
+
+// defaultProcessorPort is the port processors listen on when orca.json sets none
+const defaultProcessorPort = 5377
+
+// processorContainerName returns the container a project's processor is deployed to
+func processorContainerName(projectName string) string {
+	return namespaced("orca-processor-" + processorImageRepository(projectName))
+}
+
+// processorDeployment is an `orca deploy` of a processor image onto the orca network
+type processorDeployment struct {
+	Project string
+	Image   string
+	// Container is also the host name the core reaches the processor at
+	Container string
+	Port      int
+	// Core is the address of a remote core, empty for the core of the stack
+	Core string
+	// Address is where a remote core reaches the processor, on HostPort of this machine
+	Address  string
+	HostPort int
+}
+
+// useRemoteCore points the deployment at a remote core, which reaches the processor
+// at address, a host:port of this machine
+func (d *processorDeployment) useRemoteCore(core, address string) error {
+	if address == "" {
+		return fmt.Errorf("a remote core needs processorConnectionString in orca.json, the host:port it reaches this machine at")
+	}
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid processorConnectionString %q, expected host:port: %w", address, err)
+	}
+	hostPort, err := strconv.Atoi(port)
+	if err != nil || hostPort <= 0 || hostPort > 65535 {
+		return fmt.Errorf("invalid port %q in processorConnectionString", port)
+	}
+	d.Core, d.Address, d.HostPort = core, address, hostPort
+	return nil
+}
+
+// address returns where the core reaches the processor, by container name on the
+// orca network unless the core is remote
+func (d *processorDeployment) address() string {
+	if d.Core != "" {
+		return d.Address
+	}
+	return fmt.Sprintf("%s:%d", d.Container, d.Port)
+}
+
+// env returns the variables telling the processor where the core is and where the
+// core reaches it
+func (d *processorDeployment) env() []string {
+	core := cmp.Or(d.Core, fmt.Sprintf("%s:%d", orcaContainerName, orcaInternalPort))
+	return []string{
+		"ORCA_CORE=" + core,
+		"PROCESSOR_ADDRESS=" + d.address(),
+		"PROCESSOR_PORT=" + strconv.Itoa(d.Port),
+	}
+}
+
+// runArgs returns the `docker run` arguments of the processor container. The port
+// is only published for a remote core, as the core of the stack calls the processor
+// on the same network.
+func (d *processorDeployment) runArgs() []string {
+	args := []string{
+		"run", "-d",
+		"--name", d.Container,
+		"--network", networkName,
+		"--restart", "unless-stopped",
+	}
+	if d.HostPort > 0 {
+		args = append(args, "-p", fmt.Sprintf("%d:%d", d.HostPort, d.Port))
+	}
+	for _, env := range d.env() {
+		args = append(args, "-e", env)
+	}
+	args = append(args, labelArgs(componentProcessor)...)
+	args = append(args, "--label", imageProjectLabel+"="+d.Project)
+	return append(args, d.Image)
+}
+
+// run replaces any container of an earlier deployment and starts the processor
+func (d *processorDeployment) run() error {
+	if getContainerStatus(d.Container) != "not found" {
+		if output, err := dockerCommand("rm", "-f", d.Container).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to remove the previous deployment %s: %s", d.Container, strings.TrimSpace(string(output)))
+		}
+	}
+	if output, err := dockerCommand(d.runArgs()...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to start %s: %s", d.Container, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// showDeployedStatus prints the status of any deployed processors
+func showDeployedStatus(processors []componentStatus) {
+	for _, processor := range processors {
+		fmt.Println()
+		fmt.Printf("Processor %s: %s\n", processor.Name, renderStdout(statusColor(processor.Status), processor.Status))
+		fmt.Println("Image: " + processor.Image)
+	}
+}
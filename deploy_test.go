@@ -0,0 +1,41 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestProcessorDeployment(t *testing.T) {
+	useFakeEngine(t)
+	if err := dockerCommand("network", "create", networkName).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	deployment := &processorDeployment{
+		Project:   "Speed Checks",
+		Image:     "speed-checks:latest",
+		Container: processorContainerName("Speed Checks"),
+		Port:      defaultProcessorPort,
+	}
+	if deployment.Container != "orca-processor-speed-checks" {
+		t.Errorf("processorContainerName = %q", deployment.Container)
+	}
+	if !slices.Contains(deployment.env(), "PROCESSOR_ADDRESS=orca-processor-speed-checks:5377") {
+		t.Errorf("env = %v, missing the address the core reaches the processor at", deployment.env())
+	}
+
+	// deploying again replaces the container of the earlier deployment
+	for range 2 {
+		if err := deployment.run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	processors := listContainers().deployedProcessors()
+	if len(processors) != 1 || processors[0].Name != "Speed Checks" || processors[0].Status != "running" || processors[0].Image != "speed-checks:latest" {
+		t.Errorf("deployedProcessors = %+v", processors)
+	}
+	if !slices.Contains(stackContainers(), deployment.Container) {
+		t.Errorf("stackContainers = %v, missing the processor, so orca stop would leave it running", stackContainers())
+	}
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+// sourceSnapshot records the modification time and size of each source file of a
+// project, keyed by path relative to its directory
+type sourceSnapshot map[string]string
+
+// devIgnoredDirs are not watched by `orca dev`, besides hidden directories, as they
+// hold environments and caches rather than source
+var devIgnoredDirs = []string{"__pycache__", "node_modules", "venv"}
+
+// snapshotSources walks the project directory, skipping environments, caches and
+// hidden files
+func snapshotSources(dir string) (sourceSnapshot, error) {
+	snapshot := sourceSnapshot{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		if path != dir && (strings.HasPrefix(name, ".") || slices.Contains(devIgnoredDirs, name)) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || strings.HasSuffix(name, ".pyc") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		snapshot[filepath.ToSlash(rel)] = fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size())
+		return nil
+	})
+	return snapshot, err
+}
+
+// changes returns the files added, removed or modified since before, sorted
+func (s sourceSnapshot) changes(before sourceSnapshot) []string {
+	var changed []string
+	for path, stamp := range s {
+		if before[path] != stamp {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := s[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// dependencyCommands returns the commands reinstalling the dependencies of a local
+// processor after its dependency files changed. uv syncs on every run, so it needs none.
+func dependencyCommands(config *ProcessorConfig, dir string, changed []string) [][]string {
+	switch config.PackageManager {
+	case "poetry":
+		if slices.Contains(changed, "pyproject.toml") || slices.Contains(changed, "poetry.lock") {
+			return [][]string{{"poetry", "install", "--no-root"}}
+		}
+	case "pip", "":
+		if slices.Contains(changed, "requirements.txt") {
+			return [][]string{{venvPython(dir), "-m", "pip", "install", "-r", "requirements.txt"}}
+		}
+	}
+	return nil
+}
+
+// devSession is an `orca dev` loop, restarting the processor of a project as its
+// source changes, either as a local process or as a container deployed to the stack
+type devSession struct {
+	config      *OrcaConfigFile
+	dir         string
+	coreAddress string
+	timeout     time.Duration
+	container   bool
+
+	// cmd is the local processor, or the log follower of the container
+	cmd *exec.Cmd
+	// exited receives the result of cmd once it exits
+	exited chan error
+	// algorithms are those registered after the previous restart
+	algorithms []string
+}
+
+// start runs the processor, building and deploying its image first in container mode
+func (d *devSession) start() error {
+	if !d.container {
+		cmd, err := prepareProcessor(d.config, d.dir)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Running %s\n", shellJoin(cmd.Args))
+		return d.startCmd(cmd)
+	}
+
+	repository := processorRepository(d.config)
+	build := &processorBuild{
+		Dir:        d.dir,
+		Dockerfile: projectDockerfile(d.dir),
+		Tags:       []string{repository + ":latest"},
+		Labels:     map[string]string{imageProjectLabel: d.config.ProjectName},
+	}
+	if err := build.run(d.config); err != nil {
+		return err
+	}
+	deployment := &processorDeployment{
+		Project:   d.config.ProjectName,
+		Image:     repository + ":latest",
+		Container: processorContainerName(d.config.ProjectName),
+		Port:      cmp.Or(d.config.ProcessorPort, defaultProcessorPort),
+	}
+	if err := deployment.run(); err != nil {
+		return err
+	}
+	cmd := dockerCommand("logs", "-f", deployment.Container)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return d.startCmd(cmd)
+}
+
+func (d *devSession) startCmd(cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	d.cmd = cmd
+	d.exited = make(chan error, 1)
+	go func() { d.exited <- cmd.Wait() }()
+	return nil
+}
+
+// stop interrupts the local processor, killing it if it does not exit in time. The
+// container of container mode is left running, the next deploy replaces it.
+func (d *devSession) stop() {
+	if d.cmd == nil {
+		return
+	}
+	select {
+	case <-d.exited:
+	default:
+		if runtime.GOOS == "windows" {
+			d.cmd.Process.Kill()
+		} else {
+			d.cmd.Process.Signal(os.Interrupt)
+		}
+		select {
+		case <-d.exited:
+		case <-time.After(5 * time.Second):
+			d.cmd.Process.Kill()
+			<-d.exited
+		}
+	}
+	d.cmd = nil
+}
+
+// verify waits for the processor to register with the core and, for a local
+// processor, to pass its health check, then reports how its algorithms changed
+func (d *devSession) verify() error {
+	deadline := time.Now().Add(d.timeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		if !d.container {
+			select {
+			case err := <-d.exited:
+				d.exited <- err
+				return fmt.Errorf("the processor exited: %v", err)
+			default:
+			}
+		}
+
+		state, err := d.fetchRegistry()
+		if err == nil {
+			err = d.registered(state)
+		}
+		if err == nil {
+			algorithms, _, err := projectAlgorithms(state, d.config.ProjectName)
+			if err != nil {
+				return err
+			}
+			d.reportAlgorithms(algorithms)
+			return nil
+		}
+		lastErr = err
+		time.Sleep(500 * time.Millisecond)
+	}
+	return fmt.Errorf("the processor did not register within %s: %v", d.timeout, lastErr)
+}
+
+func (d *devSession) fetchRegistry() (*pb.InternalState, error) {
+	conn, client, err := dialCore(d.coreAddress)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	state, err := exposeCompressed(ctx, client, &pb.ExposeSettings{})
+	if err != nil {
+		return nil, fmt.Errorf("issue contacting Orca: %w", err)
+	}
+	return state, nil
+}
+
+// registered checks the project's processors are registered and serving. Container
+// names do not resolve from this machine, so in container mode the container is
+// checked instead of the health check.
+func (d *devSession) registered(state *pb.InternalState) error {
+	var registrations []*pb.ProcessorRegistration
+	for _, registration := range state.GetProcessors() {
+		if registration.GetProjectName() == d.config.ProjectName {
+			registrations = append(registrations, registration)
+		}
+	}
+	if len(registrations) == 0 {
+		return fmt.Errorf("no processor of project %q is registered", d.config.ProjectName)
+	}
+
+	if d.container {
+		containerName := processorContainerName(d.config.ProjectName)
+		containerState, err := getContainerState(containerName)
+		if err != nil {
+			return err
+		}
+		if containerState.Status != "running" {
+			return fmt.Errorf("%s is %s", containerName, containerState.Status)
+		}
+		return nil
+	}
+
+	for _, registration := range registrations {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		status := probeProcessor(ctx, registration)
+		cancel()
+		if status.Status != "serving" {
+			return fmt.Errorf("processor %s is %s: %s", status.Name, status.Status, status.Message)
+		}
+	}
+	return nil
+}
+
+// reportAlgorithms prints the registered algorithms the first time, then those
+// added or removed by each restart
+func (d *devSession) reportAlgorithms(algorithms []string) {
+	previous := d.algorithms
+	d.algorithms = algorithms
+	if previous == nil {
+		fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Registered %d algorithm(s): %s", len(algorithms), strings.Join(algorithms, ", "))))
+		return
+	}
+
+	var changes []string
+	for _, algorithm := range algorithms {
+		if !slices.Contains(previous, algorithm) {
+			changes = append(changes, "+ "+algorithm)
+		}
+	}
+	for _, algorithm := range previous {
+		if !slices.Contains(algorithms, algorithm) {
+			changes = append(changes, "- "+algorithm)
+		}
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(os.Stderr, renderSuccess("Registered, algorithms unchanged"))
+		return
+	}
+	fmt.Fprintln(os.Stderr, renderSuccess("Registered, algorithms changed:"))
+	for _, change := range changes {
+		fmt.Fprintln(os.Stderr, "  "+change)
+	}
+}
+
+// restart stops the processor, reinstalls changed dependencies and starts it again
+func (d *devSession) restart(changed []string) {
+	d.stop()
+	if !d.container && d.config.Processor != nil {
+		for _, args := range dependencyCommands(d.config.Processor, d.dir, changed) {
+			fmt.Fprintf(os.Stderr, "Updating dependencies: %s\n", shellJoin(args))
+			cmd := exec.Command(args[0], args[1:]...)
+			cmd.Dir = d.dir
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				printError(fmt.Sprintf("Failed to update dependencies: %v", err))
+			}
+		}
+	}
+	d.startAndVerify()
+}
+
+func (d *devSession) startAndVerify() {
+	if err := d.start(); err != nil {
+		printError(fmt.Sprintf("Failed to start the processor: %v. Waiting for changes", err))
+		return
+	}
+	if err := d.verify(); err != nil {
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("%v. Waiting for changes", err)))
+	}
+}
+
+// run starts the processor, then polls the project for changes every interval until
+// interrupted. A restart waits for the changes to settle for one interval, so that
+// saving several files restarts once.
+func (d *devSession) run(interval time.Duration) error {
+	dir, err := filepath.Abs(d.dir)
+	if err != nil {
+		return err
+	}
+	d.dir = dir
+	snapshot, err := snapshotSources(d.dir)
+	if err != nil {
+		return err
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	fmt.Fprintf(os.Stderr, "Watching %s for changes. Press Ctrl+C to stop.\n", d.dir)
+	d.startAndVerify()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var pending []string
+	for {
+		select {
+		case <-sigs:
+			fmt.Fprintln(os.Stderr)
+			d.stop()
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := snapshotSources(d.dir)
+		if err != nil {
+			return err
+		}
+		changed := current.changes(snapshot)
+		snapshot = current
+		if len(changed) > 0 {
+			for _, path := range changed {
+				if !slices.Contains(pending, path) {
+					pending = append(pending, path)
+				}
+			}
+			continue
+		}
+		if len(pending) == 0 {
+			continue
+		}
+
+		slices.Sort(pending)
+		fmt.Fprintf(os.Stderr, "\n[%s] %s changed, restarting the processor\n", time.Now().Format(time.TimeOnly), strings.Join(pending, ", "))
+		d.restart(pending)
+		pending = nil
+	}
+}
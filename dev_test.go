@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSnapshotSources(t *testing.T) {
+	dir := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.py", "print(1)\n")
+	write("requirements.txt", "orca-python\n")
+	write("algorithms/speed.py", "")
+	before, err := snapshotSources(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// environments, caches and hidden files are not source
+	write(".venv/lib/site.py", "")
+	write("__pycache__/main.cpython-312.pyc", "")
+	write(".orca/manifest.json", "{}")
+	write("main.py", "print(10)\n")
+	write("algorithms/routes.py", "")
+	if err := os.Remove(filepath.Join(dir, "algorithms", "speed.py")); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := snapshotSources(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"algorithms/routes.py", "algorithms/speed.py", "main.py"}
+	if changed := after.changes(before); !slices.Equal(changed, want) {
+		t.Errorf("changes = %v, want %v", changed, want)
+	}
+
+	if commands := dependencyCommands(&ProcessorConfig{PackageManager: "pip"}, dir, []string{"main.py"}); commands != nil {
+		t.Errorf("dependencyCommands without dependency changes = %v", commands)
+	}
+	if commands := dependencyCommands(&ProcessorConfig{PackageManager: "pip"}, dir, []string{"requirements.txt"}); len(commands) != 1 {
+		t.Errorf("dependencyCommands after requirements.txt changed = %v", commands)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// containerRuntime describes the Docker-compatible engine the CLI talks to, and
+// how containers on it reach services, such as processors, running on the host
+type containerRuntime struct {
+	Name string
+	// Host is the endpoint of the engine, e.g. unix:///var/run/docker.sock
+	Host string
+	// HostAlias is the hostname containers use to reach the host
+	HostAlias string
+	// Notes hold runtime specific advice for exposing processors to the core
+	Notes []string
+}
+
+var (
+	dockerDesktopRuntime = containerRuntime{
+		Name:      "Docker Desktop",
+		HostAlias: "host.docker.internal",
+	}
+	dockerEngineRuntime = containerRuntime{
+		Name:      "Docker Engine",
+		HostAlias: "host.docker.internal",
+		Notes: []string{
+			"Processors must listen on 0.0.0.0 (or the docker bridge address), not 127.0.0.1, to be reachable from the core.",
+		},
+	}
+	rootlessDockerRuntime = containerRuntime{
+		Name:      "Docker Engine (rootless)",
+		HostAlias: "host.docker.internal",
+		Notes: []string{
+			"Rootless Docker cannot reach the host's loopback interface by default, so processors must listen on a non-loopback address.",
+			"Alternatively set DOCKERD_ROOTLESS_ROOTLESSKIT_DISABLE_HOST_LOOPBACK=false for the daemon and use 10.0.2.2 as the processor host.",
+		},
+	}
+	colimaRuntime = containerRuntime{
+		Name: "Colima",
+		// host.docker.internal resolves to the VM rather than the host on Colima
+		HostAlias: "host.lima.internal",
+	}
+	rancherDesktopRuntime = containerRuntime{
+		Name:      "Rancher Desktop",
+		HostAlias: "host.docker.internal",
+		Notes: []string{
+			"Rancher Desktop must use the dockerd (moby) container engine, not containerd.",
+		},
+	}
+	podmanRuntime = containerRuntime{
+		Name:      "Podman",
+		HostAlias: "host.containers.internal",
+	}
+)
+
+// dockerEndpoint returns the engine endpoint the docker CLI uses, from DOCKER_HOST
+// or the current docker context
+func dockerEndpoint() string {
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		return host
+	}
+	output, err := dockerCommand("context", "inspect", "--format", "{{.Endpoints.docker.Host}}").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// runtimeFromEndpoint recognises a runtime from the socket path it listens on
+func runtimeFromEndpoint(endpoint string) (containerRuntime, bool) {
+	path := filepath.ToSlash(endpoint)
+	switch {
+	case strings.Contains(path, "/.colima/"):
+		return colimaRuntime, true
+	case strings.Contains(path, "/.rd/"):
+		return rancherDesktopRuntime, true
+	case strings.Contains(path, "podman"):
+		return podmanRuntime, true
+	case strings.Contains(path, "/.docker/run/"), strings.Contains(path, "/.docker/desktop/"), strings.Contains(path, "dockerDesktop"):
+		return dockerDesktopRuntime, true
+	case strings.HasPrefix(path, "unix:///run/user/"):
+		return rootlessDockerRuntime, true
+	}
+	return containerRuntime{}, false
+}
+
+// detectContainerRuntime works out which engine the docker CLI talks to, first from
+// its endpoint, then by asking the engine itself. Unknown engines are treated as a
+// plain Docker Engine.
+func detectContainerRuntime() containerRuntime {
+	endpoint := dockerEndpoint()
+	detected, ok := runtimeFromEndpoint(endpoint)
+	if !ok {
+		detected = runtimeFromEngineInfo()
+	}
+	detected.Host = endpoint
+	return withPlatformGuidance(detected)
+}
+
+// runtimeFromEngineInfo recognises a runtime from `docker info`
+func runtimeFromEngineInfo() containerRuntime {
+	output, err := dockerCommand(
+		"info", "--format", "{{json .OperatingSystem}} {{json .SecurityOptions}}",
+	).Output()
+	if err != nil {
+		return dockerEngineRuntime
+	}
+
+	operatingSystem, securityOptions, _ := strings.Cut(strings.TrimSpace(string(output)), " ")
+	var osName string
+	json.Unmarshal([]byte(operatingSystem), &osName)
+
+	switch {
+	case strings.Contains(osName, "Docker Desktop"):
+		return dockerDesktopRuntime
+	case strings.Contains(osName, "Rancher Desktop"):
+		return rancherDesktopRuntime
+	case strings.Contains(securityOptions, "name=rootless"):
+		return rootlessDockerRuntime
+	case strings.Contains(strings.ToLower(osName), "podman"):
+		return podmanRuntime
+	}
+	return dockerEngineRuntime
+}
+
+// knownRuntimeSockets lists sockets of runtimes the docker CLI may not be pointed at
+func knownRuntimeSockets() map[string]string {
+	home, _ := os.UserHomeDir()
+	sockets := map[string]string{
+		"Docker Desktop":  filepath.Join(home, ".docker", "run", "docker.sock"),
+		"Colima":          filepath.Join(home, ".colima", "default", "docker.sock"),
+		"Rancher Desktop": filepath.Join(home, ".rd", "docker.sock"),
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		sockets["Docker Engine (rootless)"] = filepath.Join(runtimeDir, "docker.sock")
+		sockets["Podman"] = filepath.Join(runtimeDir, "podman", "podman.sock")
+	}
+	return sockets
+}
+
+// suggestRuntimeSockets returns hints for reaching runtimes whose sockets exist,
+// used when the docker CLI cannot reach an engine
+func suggestRuntimeSockets() []string {
+	var hints []string
+	for name, socket := range knownRuntimeSockets() {
+		if _, err := os.Stat(socket); err == nil {
+			hints = append(hints, "Found a "+name+" socket. Try: export DOCKER_HOST=unix://"+filepath.ToSlash(socket))
+		}
+	}
+	sort.Strings(hints)
+	return hints
+}
+
+// showRuntimeGuidance prints the detected runtime and how processors should be addressed on it
+func showRuntimeGuidance(detected containerRuntime) {
+	if detected.Host != "" {
+		fmt.Fprintf(os.Stderr, "Container runtime: %s (%s)\n", detected.Name, detected.Host)
+	} else {
+		fmt.Fprintf(os.Stderr, "Container runtime: %s\n", detected.Name)
+	}
+	fmt.Fprintf(os.Stderr, "Processors are reached from the core at %s:<processor-port>\n", detected.HostAlias)
+	for _, note := range detected.Notes {
+		fmt.Fprintln(os.Stderr, warningStyle.Render("Note: ")+note)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// doctorFinding is a problem found by `orca doctor`
+type doctorFinding struct {
+	Resource string
+	Problem  string
+	Fix      string
+	// Apply fixes the problem in place, nil when the fix is left to the user
+	Apply func() error
+}
+
+// findStateProblems checks the files the CLI keeps for the stack project, which a
+// command that was killed leaves behind
+func findStateProblems() []doctorFinding {
+	var findings []doctorFinding
+	if path, err := stackLockPath(); err == nil {
+		if holder, stale := staleLockRecord(path); stale {
+			findings = append(findings, doctorFinding{
+				Resource: path,
+				Problem:  "lock file left by a command that did not exit cleanly" + holder,
+				Fix:      "clear it",
+				Apply:    clearLockFunc(path),
+			})
+		}
+	}
+	if socket, _, err := daemonPaths(); err == nil && staleDaemonSocket(socket) {
+		findings = append(findings, doctorFinding{
+			Resource: socket,
+			Problem:  "daemon socket refuses connections, the daemon did not stop cleanly",
+			Fix:      "remove it",
+			Apply: func() error {
+				if err := os.Remove(socket); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					return err
+				}
+				return nil
+			},
+		})
+	}
+	return findings
+}
+
+// staleLockRecord reports whether the lock file records a holder while no process
+// holds the lock, returning the holder as described by describeLockHolder
+func staleLockRecord(path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(data)) == "" {
+		return "", false
+	}
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+	// a running command holds the lock, and closing the file releases it again
+	if tryLockFile(file) != nil {
+		return "", false
+	}
+	return describeLockHolder(path), true
+}
+
+// clearLockFunc clears the record of a lock, unless a command has taken it since
+func clearLockFunc(path string) func() error {
+	return func() error {
+		file, err := os.OpenFile(path, os.O_RDWR, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open lock file: %w", err)
+		}
+		defer file.Close()
+		if err := tryLockFile(file); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", path, err)
+		}
+		return file.Truncate(0)
+	}
+}
+
+// staleDaemonSocket reports whether a daemon socket exists that no daemon listens on
+func staleDaemonSocket(socket string) bool {
+	if _, err := os.Stat(socket); err != nil {
+		return false
+	}
+	_, err := daemonRequest(http.MethodGet, "/status", time.Second)
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// findStackProblems turns the inventory of `orca repair` into findings, fixing in
+// place what can be and leaving the rest to `orca repair`
+func findStackProblems(issues []repairIssue) []doctorFinding {
+	var findings []doctorFinding
+	for _, issue := range issues {
+		finding := doctorFinding{
+			Resource: issue.Resource,
+			Problem:  issue.Problem,
+			Fix:      issue.Fix,
+			Apply:    issue.Restore,
+		}
+		if issue.Restore == nil && !issue.ReportOnly {
+			finding.Fix = "run `orca repair` to " + issue.Fix
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// showDoctorFindings prints the problems found, marking those -fix applies
+func showDoctorFindings(w io.Writer, findings []doctorFinding) {
+	rows := [][]string{{"RESOURCE", "PROBLEM", "FIX"}}
+	for _, finding := range findings {
+		fix := finding.Fix
+		if finding.Apply != nil {
+			fix += " (-fix)"
+		}
+		rows = append(rows, []string{finding.Resource, finding.Problem, fix})
+	}
+	printTable(w, rows)
+}
+
+// confirmFix asks on stdin whether to apply the fix of a finding
+func confirmFix(finding doctorFinding) bool {
+	fmt.Fprint(os.Stderr, warningStyle.Render(fmt.Sprintf("%s: %s? (y/N): ", finding.Resource, finding.Fix)))
+	var response string
+	fmt.Scanln(&response)
+	return strings.ToLower(strings.TrimSpace(response)) == "y"
+}
+
+// applyDoctorFixes applies the fixes of the findings in order, each once confirmed.
+// A nil confirm applies them all. A fix that fails does not stop the others, and the
+// number applied is returned with the failures.
+func applyDoctorFixes(findings []doctorFinding, confirm func(doctorFinding) bool) (int, error) {
+	var errs []error
+	fixed := 0
+	for _, finding := range findings {
+		if finding.Apply == nil {
+			continue
+		}
+		if confirm != nil && !confirm(finding) {
+			fmt.Fprintf(os.Stderr, "Skipped %s\n", finding.Resource)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Fixing %s... ", finding.Resource)
+		if err := finding.Apply(); err != nil {
+			fmt.Fprintln(os.Stderr, renderError("FAILED"))
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess("DONE"))
+		fixed++
+	}
+	return fixed, errors.Join(errs...)
+}
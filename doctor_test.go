@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStaleLockRecord(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path, err := stackLockPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, stale := staleLockRecord(path); stale {
+		t.Error("a missing lock file is stale")
+	}
+
+	// a command that exits clears its record
+	if err := lockStack("start"); err != nil {
+		t.Fatalf("lockStack: %v", err)
+	}
+	if _, stale := staleLockRecord(path); stale {
+		t.Error("a lock held by a running command is stale")
+	}
+	unlockStack()
+	if _, stale := staleLockRecord(path); stale {
+		t.Error("a released lock is stale")
+	}
+
+	// one that is killed leaves it behind
+	if err := os.WriteFile(path, []byte("12345\nstart\n2026-01-02T03:04:05Z\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	holder, stale := staleLockRecord(path)
+	if !stale || !strings.Contains(holder, "pid 12345") {
+		t.Errorf("staleLockRecord = %q, %v, want the record of pid 12345", holder, stale)
+	}
+	if err := clearLockFunc(path)(); err != nil {
+		t.Fatalf("clearing the lock: %v", err)
+	}
+	if _, stale := staleLockRecord(path); stale {
+		t.Error("the record is still stale once cleared")
+	}
+}
+
+func TestFindStackProblems(t *testing.T) {
+	restore := func() error { return nil }
+	findings := findStackProblems([]repairIssue{
+		{Resource: "orca-network", Problem: "network is missing", Fix: "create it", Restore: restore},
+		{Resource: "orca-core", Problem: "container is missing", Fix: "create it"},
+		{Resource: "stray", Problem: "unknown", Fix: "none", ReportOnly: true},
+	})
+	if len(findings) != 3 {
+		t.Fatalf("got %d findings, want 3", len(findings))
+	}
+	if findings[0].Apply == nil || findings[0].Fix != "create it" {
+		t.Errorf("a restorable issue became %+v, want it fixed in place", findings[0])
+	}
+	if findings[1].Apply != nil || findings[1].Fix != "run `orca repair` to create it" {
+		t.Errorf("a missing container became %+v, want it left to orca repair", findings[1])
+	}
+	if findings[2].Apply != nil || findings[2].Fix != "none" {
+		t.Errorf("a report only issue became %+v", findings[2])
+	}
+}
+
+func TestApplyDoctorFixes(t *testing.T) {
+	var applied []string
+	fix := func(name string, err error) func() error {
+		return func() error {
+			applied = append(applied, name)
+			return err
+		}
+	}
+	findings := []doctorFinding{
+		{Resource: "network", Apply: fix("network", nil)},
+		{Resource: "core", Apply: fix("core", errors.New("failed to start core"))},
+		{Resource: "manual"},
+		{Resource: "declined", Apply: fix("declined", nil)},
+		{Resource: "volume", Apply: fix("volume", nil)},
+	}
+
+	var asked []string
+	fixed, err := applyDoctorFixes(findings, func(finding doctorFinding) bool {
+		asked = append(asked, finding.Resource)
+		return finding.Resource != "declined"
+	})
+	if want := "network,core,declined,volume"; strings.Join(asked, ",") != want {
+		t.Errorf("asked about %v, want %s", asked, want)
+	}
+	// a failed fix does not stop the ones after it
+	if want := "network,core,volume"; strings.Join(applied, ",") != want {
+		t.Errorf("applied %v, want %s", applied, want)
+	}
+	if fixed != 2 || err == nil || !strings.Contains(err.Error(), "failed to start core") {
+		t.Errorf("applyDoctorFixes = %d, %v, want 2 fixed and the failure of core", fixed, err)
+	}
+
+	applied = nil
+	if fixed, err := applyDoctorFixes(findings[3:], nil); fixed != 2 || err != nil {
+		t.Errorf("applyDoctorFixes without confirmation = %d, %v, want both applied", fixed, err)
+	}
+}
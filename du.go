@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// diskUsage is what `orca du` reports, sizes in bytes. A size of -1 could not be
+// measured.
+type diskUsage struct {
+	Volumes []volumeUsage `json:"volumes"`
+	Images  []imageUsage  `json:"images"`
+	// Tables is null when the store is not running
+	Tables []tableUsage `json:"tables"`
+}
+
+type volumeUsage struct {
+	Name string `json:"name"`
+	// Snapshot names the snapshot the volume belongs to, empty for stack data
+	Snapshot string `json:"snapshot,omitempty"`
+	Size     int64  `json:"size"`
+}
+
+type imageUsage struct {
+	Image string `json:"image"`
+	Size  int64  `json:"size"`
+}
+
+type tableUsage struct {
+	Table   string `json:"table"`
+	Rows    int64  `json:"rows"`
+	Data    int64  `json:"data"`
+	Indexes int64  `json:"indexes"`
+	Total   int64  `json:"total"`
+}
+
+// tableUsageQuery sizes the tables of the store, TOAST included in data, largest first
+const tableUsageQuery = `
+SELECT schemaname || '.' || relname AS "table",
+       n_live_tup AS rows,
+       pg_total_relation_size(relid) - pg_indexes_size(relid) AS data,
+       pg_indexes_size(relid) AS indexes,
+       pg_total_relation_size(relid) AS total
+FROM pg_stat_user_tables
+ORDER BY total DESC, "table"`
+
+// measureVolumes sizes volumes with du in a single throwaway helper container, each
+// volume mounted read-only under /v
+func measureVolumes(volumes []string) map[string]int64 {
+	sizes := map[string]int64{}
+	if len(volumes) == 0 {
+		return sizes
+	}
+	args := []string{"run", "--rm"}
+	paths := []string{}
+	for _, volumeName := range volumes {
+		args = append(args, "-v", volumeName+":/v/"+volumeName+":ro")
+		paths = append(paths, "/v/"+volumeName)
+	}
+	args = append(append(args, helperImage, "du", "-sk"), paths...)
+	output, err := dockerCommand(args...).Output()
+	if err != nil {
+		logDebug("failed to measure volumes: %v", err)
+		return sizes
+	}
+	return parseDuOutput(string(output))
+}
+
+// parseDuOutput reads `du -sk` lines of kilobytes and /v/<volume> paths
+func parseDuOutput(output string) map[string]int64 {
+	sizes := map[string]int64{}
+	for _, line := range strings.Split(output, "\n") {
+		kilobytes, path, found := strings.Cut(strings.TrimSpace(line), "\t")
+		size, err := strconv.ParseInt(kilobytes, 10, 64)
+		if !found || err != nil {
+			continue
+		}
+		sizes[strings.TrimPrefix(path, "/v/")] = size * 1024
+	}
+	return sizes
+}
+
+// imageSize returns the size of a local image, or -1 when it has not been pulled
+func imageSize(image string) int64 {
+	output, err := dockerCommand("image", "inspect", "--format", "{{.Size}}", image).Output()
+	if err != nil {
+		return -1
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+// collectDiskUsage sizes the data volumes and snapshots of the stack, its images, and
+// the tables of the store when it is running
+func collectDiskUsage(config *OrcaConfigFile) (diskUsage, error) {
+	usage := diskUsage{Volumes: []volumeUsage{}, Images: []imageUsage{}}
+	for _, volumeName := range orcaVolumes {
+		if volumeExists(volumeName) {
+			usage.Volumes = append(usage.Volumes, volumeUsage{Name: volumeName})
+		}
+	}
+	snapshots, err := listSnapshots()
+	if err != nil {
+		return usage, err
+	}
+	for _, snapshot := range snapshots {
+		for _, volumeName := range snapshot.Volumes {
+			usage.Volumes = append(usage.Volumes, volumeUsage{Name: volumeName, Snapshot: snapshot.Name})
+		}
+	}
+	var names []string
+	for _, volume := range usage.Volumes {
+		names = append(names, volume.Name)
+	}
+	sizes := measureVolumes(names)
+	for ii, volume := range usage.Volumes {
+		size, ok := sizes[volume.Name]
+		if !ok {
+			size = -1
+		}
+		usage.Volumes[ii].Size = size
+	}
+
+	for _, image := range listStackImages(listContainers(), config) {
+		usage.Images = append(usage.Images, imageUsage{Image: image, Size: imageSize(image)})
+	}
+
+	if getContainerStatus(pgContainerName) != "running" {
+		return usage, nil
+	}
+	output, err := queryStoreJSON(tableUsageQuery)
+	if err != nil {
+		return usage, fmt.Errorf("failed to size the tables of the store: %w", err)
+	}
+	usage.Tables = []tableUsage{}
+	if err := json.Unmarshal(output, &usage.Tables); err != nil {
+		return usage, fmt.Errorf("failed to read table sizes: %w", err)
+	}
+	return usage, nil
+}
+
+// formatSize renders a size in bytes with decimal units, as docker does
+func formatSize(size int64) string {
+	if size < 0 {
+		return "-"
+	}
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1000 && unit < len(units)-1 {
+		value /= 1000
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
+
+// totalSize sums the sizes that could be measured
+func totalSize(sizes ...int64) int64 {
+	var total int64
+	for _, size := range sizes {
+		if size > 0 {
+			total += size
+		}
+	}
+	return total
+}
+
+func showDiskUsage(w io.Writer, usage diskUsage) {
+	var sizes []int64
+	rows := [][]string{{"VOLUME", "SNAPSHOT", "SIZE"}}
+	for _, volume := range usage.Volumes {
+		rows = append(rows, []string{volume.Name, volume.Snapshot, formatSize(volume.Size)})
+		sizes = append(sizes, volume.Size)
+	}
+	fmt.Fprintf(w, "Volumes (%s):\n", formatSize(totalSize(sizes...)))
+	if len(usage.Volumes) == 0 {
+		fmt.Fprintln(w, "No volumes. Start the stack with `orca start`")
+	} else {
+		printTable(w, rows)
+	}
+
+	sizes = nil
+	rows = [][]string{{"IMAGE", "SIZE"}}
+	for _, image := range usage.Images {
+		rows = append(rows, []string{image.Image, formatSize(image.Size)})
+		sizes = append(sizes, image.Size)
+	}
+	fmt.Fprintf(w, "\nImages (%s):\n", formatSize(totalSize(sizes...)))
+	printTable(w, rows)
+
+	sizes = nil
+	rows = [][]string{{"TABLE", "ROWS", "DATA", "INDEXES", "TOTAL"}}
+	for _, table := range usage.Tables {
+		rows = append(rows, []string{table.Table, strconv.FormatInt(table.Rows, 10), formatSize(table.Data), formatSize(table.Indexes), formatSize(table.Total)})
+		sizes = append(sizes, table.Total)
+	}
+	fmt.Fprintf(w, "\nStore tables (%s):\n", formatSize(totalSize(sizes...)))
+	switch {
+	case usage.Tables == nil:
+		fmt.Fprintln(w, "The store is not running, start it with `orca start` to size its tables")
+	case len(usage.Tables) == 0:
+		fmt.Fprintln(w, "No tables")
+	default:
+		printTable(w, rows)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseDuOutput(t *testing.T) {
+	output := "52344\t/v/orca-pg-instance-data\n8\t/v/orca-redis-instance-data\ndu: cannot read directory\n"
+	sizes := parseDuOutput(output)
+	if sizes["orca-pg-instance-data"] != 52344*1024 || sizes["orca-redis-instance-data"] != 8*1024 || len(sizes) != 2 {
+		t.Errorf("parseDuOutput = %v", sizes)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		-1:            "-",
+		0:             "0 B",
+		999:           "999 B",
+		1500:          "1.5 kB",
+		53600000:      "53.6 MB",
+		2_100_000_000: "2.1 GB",
+	}
+	for size, want := range tests {
+		if got := formatSize(size); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", size, got, want)
+		}
+	}
+}
+
+func TestShowDiskUsage(t *testing.T) {
+	usage := diskUsage{
+		Volumes: []volumeUsage{
+			{Name: "orca-pg-instance-data", Size: 2_000_000},
+			{Name: "orca-snapshot-before-orca-pg-instance-data", Snapshot: "before", Size: 1_000_000},
+			{Name: "orca-redis-instance-data", Size: -1},
+		},
+		Images: []imageUsage{{Image: "redis:7", Size: 40_000_000}},
+	}
+	var out bytes.Buffer
+	showDiskUsage(&out, usage)
+	for _, want := range []string{"Volumes (3.0 MB)", "before", "Images (40.0 MB)", "The store is not running"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	usage.Tables = []tableUsage{{Table: "public.results", Rows: 120, Data: 800_000, Indexes: 200_000, Total: 1_000_000}}
+	out.Reset()
+	showDiskUsage(&out, usage)
+	if !strings.Contains(out.String(), "Store tables (1.0 MB)") || !strings.Contains(out.String(), "public.results") {
+		t.Errorf("output missing the tables:\n%s", out.String())
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// fakeDockerEnv selects the fake engine. It is set to 1 for the default state file
+// under the state directory, or to the path of a state file.
+const fakeDockerEnv = "ORCA_FAKE_DOCKER"
+
+// dockerEngine runs docker CLI commands. Every container operation of the CLI goes
+// through it, so that the fake engine can stand in for Docker.
+//
+// The seam is the docker command line rather than an interface of container
+// operations. Commands are run for their output, streamed, fed on stdin, attached
+// to the terminal for psql and shell, killed through their context, echoed by
+// --show-commands and timed by --profile-cli, all of which an *exec.Cmd already
+// models. The docker command line is also how the CLI reaches Podman and Colima,
+// so faking it checks the exact arguments those engines receive.
+type dockerEngine interface {
+	CommandContext(ctx context.Context, args ...string) *exec.Cmd
+}
+
+// cliEngine runs the docker CLI
+type cliEngine struct{}
+
+func (cliEngine) CommandContext(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, "docker", args...)
+}
+
+// fakeEngine runs the CLI itself as a fake docker, which keeps its containers,
+// volumes and networks in a state file rather than creating anything. It runs as
+// a separate process so that the state outlives a single orca invocation, as a
+// demo without Docker needs, and so that commands fail, stream and exit as docker's
+// do. Tests that need no process drive fakeDocker in memory instead.
+type fakeEngine struct {
+	statePath string
+}
+
+func (e fakeEngine) CommandContext(ctx context.Context, args ...string) *exec.Cmd {
+	executable, err := os.Executable()
+	if err != nil {
+		executable = os.Args[0]
+	}
+	cmd := exec.CommandContext(ctx, executable, append([]string{fakeDockerCommand}, args...)...)
+	cmd.Env = append(os.Environ(), fakeDockerEnv+"="+e.statePath)
+	return cmd
+}
+
+var engine = selectEngine()
+
+// selectEngine returns the fake engine when ORCA_FAKE_DOCKER is set, otherwise docker
+func selectEngine() dockerEngine {
+	value := os.Getenv(fakeDockerEnv)
+	if value == "" || value == "0" || value == "false" {
+		return cliEngine{}
+	}
+	path, err := fakeDockerStatePath(value)
+	if err != nil {
+		return cliEngine{}
+	}
+	return fakeEngine{statePath: path}
+}
+
+// fakeDockerStatePath resolves the value of ORCA_FAKE_DOCKER to a state file
+func fakeDockerStatePath(value string) (string, error) {
+	if value == "1" || value == "true" {
+		dir, err := stateDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "fake-docker.json"), nil
+	}
+	return filepath.Abs(value)
+}
+
+// showCommands is set by --show-commands, echoing every docker command to stderr
+var showCommands bool
+
+// dockerCommand builds a docker command on the selected engine
+func dockerCommand(args ...string) *exec.Cmd {
+	return dockerCommandContext(context.Background(), args...)
+}
+
+// dockerCommandContext builds a docker command that is killed when ctx is done
+func dockerCommandContext(ctx context.Context, args ...string) *exec.Cmd {
+	command := shellJoin(append([]string{"docker"}, args...))
+	if showCommands {
+		fmt.Fprintln(os.Stderr, dimStyle.Render("+ "+command))
+	} else {
+		logDebug("+ %s", command)
+	}
+	return engine.CommandContext(ctx, args...)
+}
+
+// shellJoin quotes arguments so that the command can be pasted into a POSIX shell
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for ii, arg := range args {
+		if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=,@%+") == "" {
+			quoted[ii] = arg
+		} else {
+			quoted[ii] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain lets the test binary stand in for docker when the fake engine runs it
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == fakeDockerCommand {
+		os.Exit(runFakeDockerCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == profileCommand {
+		os.Exit(runProfileCommand(os.Args[2:]))
+	}
+	os.Exit(m.Run())
+}
+
+// useFakeEngine switches the CLI to a fake engine with an empty state for one test
+func useFakeEngine(t *testing.T) string {
+	t.Helper()
+	statePath := filepath.Join(t.TempDir(), "fake-docker.json")
+	t.Setenv(fakeDockerEnv, statePath)
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	previous := engine
+	engine = fakeEngine{statePath: statePath}
+	t.Cleanup(func() { engine = previous })
+	return statePath
+}
+
+func runFake(t *testing.T, fake *fakeDocker, args ...string) string {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	fake.stdout = &stdout
+	fake.stderr = &stderr
+	if code := fake.run(args); code != 0 {
+		t.Fatalf("docker %s exited with %d: %s", strings.Join(args, " "), code, stderr.String())
+	}
+	return strings.TrimSpace(stdout.String())
+}
+
+func TestFakeDockerContainerLifecycle(t *testing.T) {
+	fake := &fakeDocker{state: newFakeDockerState()}
+
+	runFake(t, fake, "network", "create", "--driver", "bridge", "--label", "orca.managed=true", "orca-network")
+	runFake(t, fake, "run", "-d", "-p", "0:5432", "--name", "orca-pg-instance", "--network", "orca-network",
+		"-e", "POSTGRES_USER=orca", "-e", "POSTGRES_USER=other", "-v", "orca-pg-instance-data:/var/lib/postgresql",
+		"--label", "orca.component=postgres", "postgres", "postgres", "-c", "max_connections=200")
+
+	if got := runFake(t, fake, "ps", "-a", "--filter", "name=orca-pg", "--format", "{{.Names}}"); got != "orca-pg-instance" {
+		t.Errorf("ps names = %q", got)
+	}
+	if got := runFake(t, fake, "ps", "--filter", "label=orca.component=postgres", "--format", "{{.Status}}"); !strings.HasPrefix(got, "Up") {
+		t.Errorf("ps status = %q, want Up", got)
+	}
+	if got := runFake(t, fake, "port", "orca-pg-instance"); got != "5432/tcp -> 0.0.0.0:32768" {
+		t.Errorf("port = %q", got)
+	}
+	if got := runFake(t, fake, "inspect", "--format", "{{json .Args}} {{json .Config.Env}}", "orca-pg-instance"); got != `["postgres","-c","max_connections=200"] ["POSTGRES_USER=other"]` {
+		t.Errorf("inspect = %q", got)
+	}
+	if got := runFake(t, fake, "volume", "ls", "--format", "{{.Name}}"); got != "orca-pg-instance-data" {
+		t.Errorf("volume ls = %q", got)
+	}
+
+	var stderr bytes.Buffer
+	fake.stderr = &stderr
+	if code := fake.run([]string{"volume", "rm", "orca-pg-instance-data"}); code == 0 {
+		t.Error("removing a volume in use succeeded")
+	}
+
+	runFake(t, fake, "stop", "orca-pg-instance")
+	if got := runFake(t, fake, "inspect", "--format", "{{.State.Status}}", "orca-pg-instance"); got != "exited" {
+		t.Errorf("state after stop = %q", got)
+	}
+	if code := fake.run([]string{"exec", "orca-pg-instance", "pg_isready"}); code == 0 {
+		t.Error("exec in a stopped container succeeded")
+	}
+
+	runFake(t, fake, "rm", "-f", "orca-pg-instance")
+	runFake(t, fake, "volume", "rm", "orca-pg-instance-data")
+	runFake(t, fake, "network", "rm", "orca-network")
+	if len(fake.state.Containers)+len(fake.state.Volumes)+len(fake.state.Networks) != 0 {
+		t.Errorf("resources left after removal: %+v", fake.state)
+	}
+}
+
+func TestStackLifecycle(t *testing.T) {
+	statePath := useFakeEngine(t)
+	if err := setStackProject(""); err != nil {
+		t.Fatal(err)
+	}
+
+	network := createNetworkIfNotExists()
+	startPostgres(network, nil)
+	startRedis(network, nil)
+	startOrca(network, []string{"ORCA_LOG_LEVEL=INFO"}, 0)
+
+	status := collectStatus()
+	for _, component := range []componentStatus{status.Postgres, status.Redis, status.Orca} {
+		if component.Status != "running" {
+			t.Errorf("%s is %s after start, want running", component.Name, component.Status)
+		}
+		if component.ConnectionString == "" {
+			t.Errorf("%s has no connection string", component.Name)
+		}
+	}
+	if status.Orca.Port == "3335" {
+		t.Errorf("orca port = %s, want the published port rather than the internal one", status.Orca.Port)
+	}
+	if env, err := getContainerEnv(orcaContainerName); err != nil || !strings.Contains(strings.Join(env, " "), "ORCA_LOG_LEVEL=INFO") {
+		t.Errorf("orca env = %v (%v), want the configured log level", env, err)
+	}
+
+	// starting again reuses the existing containers
+	startPostgres(network, nil)
+	if got := getContainerStatus(pgContainerName); got != "running" {
+		t.Errorf("postgres is %s after a second start", got)
+	}
+
+	stopContainers()
+	status = collectStatus()
+	for _, component := range []componentStatus{status.Postgres, status.Redis, status.Orca} {
+		if component.Status != "stopped" {
+			t.Errorf("%s is %s after stop, want stopped", component.Name, component.Status)
+		}
+	}
+
+	stdin := os.Stdin
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.WriteString("y\n")
+	writer.Close()
+	os.Stdin = reader
+	destroy()
+	os.Stdin = stdin
+
+	if got := getContainerStatus(orcaContainerName); got != "not found" {
+		t.Errorf("orca is %s after destroy, want not found", got)
+	}
+	state, err := loadFakeDockerState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Containers)+len(state.Volumes)+len(state.Networks) != 0 {
+		t.Errorf("resources left after destroy: %d containers, %d volumes, %d networks",
+			len(state.Containers), len(state.Volumes), len(state.Networks))
+	}
+}
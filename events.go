@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// eventPollLimit bounds the windows and results read by each poll, later polls
+	// catching up on a backlog
+	eventPollLimit = 500
+	// webhookQueueSize is how many events wait for a slow webhook before new ones are
+	// dropped
+	webhookQueueSize = 1000
+	// webhookAttempts is how often delivering an event is tried before giving up
+	webhookAttempts = 3
+	// eventLookback is how many ids below the highest seen are read again on each
+	// poll. Ids are taken when a row is inserted but become visible when its
+	// transaction commits, so a lower id can appear after a higher one was read.
+	eventLookback = 1000
+)
+
+// Event types forwarded by `orca serve --events`
+const (
+	eventWindowReceived      = "window.received"
+	eventResultProduced      = "result.produced"
+	eventProcessorRegistered = "processor.registered"
+)
+
+// pipelineEvent is posted as JSON to each webhook, with one of Window, Result or
+// Processor set according to Type
+type pipelineEvent struct {
+	Type      string             `json:"type"`
+	Time      time.Time          `json:"time"`
+	Window    *eventWindow       `json:"window,omitempty"`
+	Result    *eventResult       `json:"result,omitempty"`
+	Processor *processorResource `json:"processor,omitempty"`
+}
+
+type eventWindow struct {
+	ID                int64           `json:"id"`
+	WindowType        string          `json:"windowType"`
+	WindowTypeVersion string          `json:"windowTypeVersion"`
+	TimeFrom          time.Time       `json:"timeFrom"`
+	TimeTo            time.Time       `json:"timeTo"`
+	Origin            string          `json:"origin"`
+	Metadata          json.RawMessage `json:"metadata,omitempty"`
+}
+
+type eventResult struct {
+	ID        int64           `json:"id"`
+	WindowID  int64           `json:"windowId"`
+	Processor string          `json:"processor"`
+	Algorithm string          `json:"algorithm"`
+	Version   string          `json:"version"`
+	Value     *float64        `json:"value,omitempty"`
+	Array     []float64       `json:"array,omitempty"`
+	JSON      json.RawMessage `json:"json,omitempty"`
+}
+
+// eventResultRow is a stored result, as read by eventPoller
+type eventResultRow struct {
+	ID          int64           `json:"id"`
+	WindowID    int64           `json:"window_id"`
+	Processor   string          `json:"processor"`
+	Algorithm   string          `json:"algorithm"`
+	Version     string          `json:"version"`
+	ResultValue *float64        `json:"result_value"`
+	ResultArray []float64       `json:"result_array"`
+	ResultJSON  json.RawMessage `json:"result_json"`
+}
+
+// seenIDs remembers the ids already reported within eventLookback of the highest,
+// forgetting older ones as it advances
+type seenIDs struct {
+	last int64
+	ids  map[int64]bool
+}
+
+// since is the id above which rows are read again
+func (s *seenIDs) since() int64 {
+	return max(s.last-eventLookback, 0)
+}
+
+// seen reports whether an id was recorded before or is too old to tell
+func (s *seenIDs) seen(id int64) bool {
+	return id <= s.since() || s.ids[id]
+}
+
+// add records an id once its event has been reported
+func (s *seenIDs) add(id int64) {
+	if s.seen(id) {
+		return
+	}
+	if s.ids == nil {
+		s.ids = map[int64]bool{}
+	}
+	s.ids[id] = true
+	if id > s.last {
+		s.last = id
+		for seen := range s.ids {
+			if seen <= s.since() {
+				delete(s.ids, seen)
+			}
+		}
+	}
+}
+
+// eventPoller finds what happened in the pipeline since it last looked. The core
+// publishes no events, so windows and results are read from the store by id and
+// registrations by comparing the registry with the one read before.
+type eventPoller struct {
+	client  pb.OrcaCoreClient
+	timeout time.Duration
+
+	windows seenIDs
+	results seenIDs
+	// processors are the registrations last read, nil until the registry is first read
+	processors map[string]*pb.ProcessorRegistration
+}
+
+// start skips the windows and results already stored and reads the registry, so
+// that only what happens from now on is reported
+func (p *eventPoller) start() error {
+	for table, seen := range map[string]*seenIDs{"windows": &p.windows, "results": &p.results} {
+		output, err := queryStoreJSON(fmt.Sprintf(`SELECT id FROM %[1]s
+WHERE id > (SELECT coalesce(max(id), 0) FROM %[1]s) - %[2]d
+ORDER BY id`, table, eventLookback))
+		if err != nil {
+			return fmt.Errorf("failed to read the store: %w", err)
+		}
+		var rows []struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.Unmarshal(output, &rows); err != nil {
+			return fmt.Errorf("failed to read the store: %w", err)
+		}
+		// the highest id goes first, so that older ones are not taken as too old
+		for ii := len(rows) - 1; ii >= 0; ii-- {
+			seen.add(rows[ii].ID)
+		}
+	}
+	// an unreachable core only delays the baseline until the first poll that reaches it
+	if _, err := p.pollRegistry(time.Now()); err != nil {
+		logDebug("failed to read the registry: %v", err)
+	}
+	return nil
+}
+
+// poll returns the events since the last poll, in the order they happened within each
+// type. Events found before an error are returned with it.
+func (p *eventPoller) poll(now time.Time) ([]pipelineEvent, error) {
+	var events []pipelineEvent
+	var errs []error
+	for _, read := range []func(time.Time) ([]pipelineEvent, error){p.pollRegistry, p.pollWindows, p.pollResults} {
+		found, err := read(now)
+		events = append(events, found...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return events, errors.Join(errs...)
+}
+
+func (p *eventPoller) pollRegistry(now time.Time) ([]pipelineEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	state, err := exposeCompressed(ctx, p.client, &pb.ExposeSettings{})
+	if err != nil {
+		return nil, fmt.Errorf("issue contacting Orca: %w", err)
+	}
+	return p.registrationEvents(canonicalRegistry(state), now), nil
+}
+
+// registrationEvents compares a registry with the one read before, reporting
+// processors that are new or registered differently. The first registry read is
+// only remembered.
+func (p *eventPoller) registrationEvents(state *pb.InternalState, now time.Time) []pipelineEvent {
+	var events []pipelineEvent
+	processors := map[string]*pb.ProcessorRegistration{}
+	for _, processor := range state.GetProcessors() {
+		processors[processor.GetName()] = processor
+		if p.processors == nil {
+			continue
+		}
+		if previous, ok := p.processors[processor.GetName()]; ok && proto.Equal(previous, processor) {
+			continue
+		}
+		resource := processorResourceOf(processor)
+		events = append(events, pipelineEvent{Type: eventProcessorRegistered, Time: now, Processor: &resource})
+	}
+	p.processors = processors
+	return events
+}
+
+func (p *eventPoller) pollWindows(now time.Time) ([]pipelineEvent, error) {
+	output, err := queryStoreJSON(fmt.Sprintf(`SELECT
+    w.id,
+    wt.name AS window_type,
+    wt.version AS window_type_version,
+    w.time_from,
+    w.time_to,
+    w.origin,
+    w.metadata,
+    %s AS created
+FROM windows w
+JOIN window_type wt ON wt.id = w.window_type_id
+WHERE w.id > %d
+ORDER BY w.id
+LIMIT %d`, storeClockColumn("w.created"), p.windows.since(), eventLookback+eventPollLimit))
+	if err != nil {
+		return nil, err
+	}
+	var rows []traceWindowRow
+	if err := json.Unmarshal(output, &rows); err != nil {
+		return nil, fmt.Errorf("failed to read windows: %w", err)
+	}
+
+	var events []pipelineEvent
+	for _, row := range rows {
+		if p.windows.seen(row.ID) {
+			continue
+		}
+		// a window that cannot be reported now is read again on the next poll
+		event, err := windowEvent(row)
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+		p.windows.add(row.ID)
+	}
+	return events, nil
+}
+
+// windowEvent reports a stored window as received when the store created it
+func windowEvent(row traceWindowRow) (pipelineEvent, error) {
+	var times [2]time.Time
+	for ii, value := range []string{row.TimeFrom, row.TimeTo} {
+		at, err := parseStoreTimestamp(value)
+		if err != nil {
+			return pipelineEvent{}, err
+		}
+		times[ii] = at.UTC()
+	}
+	created, err := parseStoreClock(row.Created)
+	if err != nil {
+		return pipelineEvent{}, err
+	}
+	return pipelineEvent{
+		Type: eventWindowReceived,
+		Time: created.UTC(),
+		Window: &eventWindow{
+			ID:                row.ID,
+			WindowType:        row.WindowType,
+			WindowTypeVersion: row.WindowTypeVersion,
+			TimeFrom:          times[0],
+			TimeTo:            times[1],
+			Origin:            row.Origin,
+			Metadata:          row.Metadata,
+		},
+	}, nil
+}
+
+func (p *eventPoller) pollResults(now time.Time) ([]pipelineEvent, error) {
+	output, err := queryStoreJSON(fmt.Sprintf(`SELECT
+    r.id,
+    r.windows_id AS window_id,
+    p.name AS processor,
+    a.name AS algorithm,
+    a.version,
+    r.result_value,
+    r.result_array,
+    r.result_json
+FROM results r
+JOIN algorithm a ON a.id = r.algorithm_id
+JOIN processor p ON p.id = a.processor_id
+WHERE r.id > %d
+ORDER BY r.id
+LIMIT %d`, p.results.since(), eventLookback+eventPollLimit))
+	if err != nil {
+		return nil, err
+	}
+	var rows []eventResultRow
+	if err := json.Unmarshal(output, &rows); err != nil {
+		return nil, fmt.Errorf("failed to read results: %w", err)
+	}
+
+	var events []pipelineEvent
+	for _, row := range rows {
+		if p.results.seen(row.ID) {
+			continue
+		}
+		// the store does not timestamp results, so they are reported as found
+		events = append(events, pipelineEvent{Type: eventResultProduced, Time: now, Result: &eventResult{
+			ID:        row.ID,
+			WindowID:  row.WindowID,
+			Processor: row.Processor,
+			Algorithm: row.Algorithm,
+			Version:   row.Version,
+			Value:     row.ResultValue,
+			Array:     row.ResultArray,
+			JSON:      row.ResultJSON,
+		}})
+		p.results.add(row.ID)
+	}
+	return events, nil
+}
+
+// webhookFlag collects repeated `-webhook URL` flags
+type webhookFlag []string
+
+func (f *webhookFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *webhookFlag) Set(value string) error {
+	if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+		return fmt.Errorf("expected an http:// or https:// URL, got %q", value)
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// webhookForwarder posts events to a webhook in order, queueing them so that a slow
+// webhook holds up neither the others nor polling
+type webhookForwarder struct {
+	url    string
+	client *http.Client
+	queue  chan pipelineEvent
+}
+
+func newWebhookForwarder(url string, timeout time.Duration) *webhookForwarder {
+	return &webhookForwarder{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+		queue:  make(chan pipelineEvent, webhookQueueSize),
+	}
+}
+
+// enqueue queues an event, reporting false when the queue is full and the event is
+// dropped
+func (f *webhookForwarder) enqueue(event pipelineEvent) bool {
+	select {
+	case f.queue <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// run delivers queued events until ctx is done
+func (f *webhookForwarder) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-f.queue:
+			if err := f.deliver(ctx, event); err != nil && ctx.Err() == nil {
+				fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("[%s] Failed to deliver a %s event to %s: %v", time.Now().Format(time.TimeOnly), event.Type, f.url, err)))
+			}
+		}
+	}
+}
+
+// deliver posts an event, retrying on connection failures and server errors
+func (f *webhookForwarder) deliver(ctx context.Context, event pipelineEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	for retry := 1; ; retry++ {
+		retryable, err := f.post(ctx, event.Type, body)
+		if err == nil || !retryable || retry == webhookAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay(retry)):
+		}
+	}
+}
+
+// post makes a single delivery, reporting whether a failure is worth retrying
+func (f *webhookForwarder) post(ctx context.Context, eventType string, body []byte) (retryable bool, err error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Orca-Event", eventType)
+	response, err := f.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		retryable := response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests
+		return retryable, fmt.Errorf("webhook answered %s", response.Status)
+	}
+	return false, nil
+}
+
+// forwardEvents polls the pipeline every interval, posting each event to every
+// webhook, until ctx is done
+func forwardEvents(ctx context.Context, poller *eventPoller, webhooks []string, interval time.Duration) error {
+	if err := poller.start(); err != nil {
+		return err
+	}
+	forwarders := make([]*webhookForwarder, len(webhooks))
+	for ii, url := range webhooks {
+		forwarders[ii] = newWebhookForwarder(url, poller.timeout)
+		go forwarders[ii].run(ctx)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var previous string
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			events, err := poller.poll(now.UTC())
+			// a failure is reported once, rather than on every poll until it clears
+			if err != nil && err.Error() != previous {
+				fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("[%s] Failed to poll for events: %v", now.Format(time.TimeOnly), err)))
+			}
+			previous = ""
+			if err != nil {
+				previous = err.Error()
+			}
+			for _, event := range events {
+				for _, forwarder := range forwarders {
+					if !forwarder.enqueue(event) {
+						fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Dropped a %s event for %s, which is not keeping up", event.Type, forwarder.url)))
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+func TestRegistrationEvents(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	mean := &pb.Algorithm{Name: "Mean", Version: "1.0.0"}
+	registry := func(processors ...*pb.ProcessorRegistration) *pb.InternalState {
+		return &pb.InternalState{Processors: processors}
+	}
+	poller := &eventPoller{}
+
+	if events := poller.registrationEvents(registry(&pb.ProcessorRegistration{Name: "stats", SupportedAlgorithms: []*pb.Algorithm{mean}}), now); len(events) != 0 {
+		t.Errorf("the first registry read reported %v", events)
+	}
+	if events := poller.registrationEvents(registry(&pb.ProcessorRegistration{Name: "stats", SupportedAlgorithms: []*pb.Algorithm{mean}}), now); len(events) != 0 {
+		t.Errorf("an unchanged registry reported %v", events)
+	}
+
+	events := poller.registrationEvents(registry(
+		&pb.ProcessorRegistration{Name: "stats", SupportedAlgorithms: []*pb.Algorithm{mean, {Name: "Spread", Version: "1.0.0"}}},
+		&pb.ProcessorRegistration{Name: "routes"},
+	), now)
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want the changed and the new processor", len(events))
+	}
+	for _, event := range events {
+		if event.Type != eventProcessorRegistered || event.Processor == nil || !event.Time.Equal(now) {
+			t.Errorf("unexpected event %+v", event)
+		}
+	}
+	if events[0].Processor.Name != "stats" || len(events[0].Processor.Algorithms) != 2 || events[1].Processor.Name != "routes" {
+		t.Errorf("events = %+v, %+v", events[0].Processor, events[1].Processor)
+	}
+}
+
+func TestWindowEvent(t *testing.T) {
+	event, err := windowEvent(traceWindowRow{
+		ID:                7,
+		WindowType:        "Hourly",
+		WindowTypeVersion: "1.0.0",
+		TimeFrom:          "2026-01-02T03:00:00",
+		TimeTo:            "2026-01-02T04:00:00",
+		Origin:            "sensor",
+		Metadata:          json.RawMessage(`{"asset_id":3}`),
+		Created:           "2026-01-02T05:00:01.5+01:00",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(event)
+	want := `{"type":"window.received","time":"2026-01-02T04:00:01.5Z","window":{"id":7,"windowType":"Hourly","windowTypeVersion":"1.0.0","timeFrom":"2026-01-02T03:00:00Z","timeTo":"2026-01-02T04:00:00Z","origin":"sensor","metadata":{"asset_id":3}}}`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	if _, err := windowEvent(traceWindowRow{TimeFrom: "yesterday"}); err == nil {
+		t.Error("expected an unparseable timestamp to fail")
+	}
+}
+
+func TestSeenIDs(t *testing.T) {
+	var seen seenIDs
+	for _, id := range []int64{5, 7} {
+		if seen.seen(id) {
+			t.Errorf("seen(%d) = true for a new id", id)
+		}
+		seen.add(id)
+	}
+	if !seen.seen(7) {
+		t.Error("seen(7) = false for an id already reported")
+	}
+	// a row committed late, below the highest id read, is still reported once
+	if seen.seen(6) {
+		t.Error("seen(6) = true for a late id not yet reported")
+	}
+	seen.add(6)
+	if !seen.seen(6) {
+		t.Error("seen(6) = false once the late id was reported")
+	}
+
+	seen.add(7 + eventLookback)
+	if got := seen.since(); got != 7 {
+		t.Errorf("since() = %d, want the lookback below the highest id", got)
+	}
+	if !seen.seen(6) || len(seen.ids) != 1 {
+		t.Errorf("ids below the lookback were kept: %v", seen.ids)
+	}
+}
+
+func TestWebhookForwarderDeliver(t *testing.T) {
+	var attempts atomic.Int32
+	var received pipelineEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rejects":
+			w.WriteHeader(http.StatusBadRequest)
+		case attempts.Add(1) == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			if r.Header.Get("X-Orca-Event") != eventResultProduced {
+				t.Errorf("X-Orca-Event = %q", r.Header.Get("X-Orca-Event"))
+			}
+			json.NewDecoder(r.Body).Decode(&received)
+		}
+	}))
+	defer server.Close()
+
+	event := pipelineEvent{Type: eventResultProduced, Result: &eventResult{ID: 3, Algorithm: "Mean"}}
+	if err := newWebhookForwarder(server.URL, time.Second).deliver(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if attempts.Load() != 2 || received.Result == nil || received.Result.ID != 3 {
+		t.Errorf("after %d attempts received %+v, want a retry after the 503", attempts.Load(), received)
+	}
+
+	attempts.Store(0)
+	if err := newWebhookForwarder(server.URL+"/rejects", time.Second).deliver(context.Background(), event); err == nil {
+		t.Error("expected a 400 to fail the delivery")
+	}
+	if attempts.Load() != 0 {
+		t.Errorf("a 400 was retried")
+	}
+}
+
+func TestWebhookFlag(t *testing.T) {
+	var webhooks webhookFlag
+	if err := webhooks.Set("hooks.example.com"); err == nil {
+		t.Error("expected a URL without a scheme to be rejected")
+	}
+	webhooks.Set("https://hooks.example.com/orca")
+	webhooks.Set("http://localhost:9000")
+	if webhooks.String() != "https://hooks.example.com/orca,http://localhost:9000" {
+		t.Errorf("webhooks = %s", webhooks.String())
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// isTerminal reports whether the file is attached to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := terminalFile(f).Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// execInContainer runs a command inside a container with the CLI's standard streams
+// attached, allocating a TTY when stdin is a terminal. It returns the command's exit code.
+func execInContainer(containerName string, command ...string) int {
+	if status := getContainerStatus(containerName); status != "running" {
+		printError(fmt.Sprintf("%s is %s. Start the stack with `orca start`", containerName, status))
+		return 1
+	}
+
+	args := []string{"exec", "-i"}
+	if isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		args = append(args, "-t")
+	}
+	args = append(args, containerName)
+	args = append(args, command...)
+
+	cmd := dockerCommand(args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		printError(fmt.Sprintf("Failed to run command in %s: %v", containerName, err))
+		return 1
+	}
+	return 0
+}
+
+// stackComponents are the core stack components that commands such as `orca shell`
+// accept in place of container names
+var stackComponents = []string{componentPostgres, componentRedis, componentCore}
+
+// componentNames returns the stack components followed by the companion services
+func componentNames() []string {
+	return append(slices.Clone(stackComponents), companionServiceNames()...)
+}
+
+// componentContainer resolves a component or companion service name to its container
+func componentContainer(name string) (string, error) {
+	switch name {
+	case componentPostgres:
+		return pgContainerName, nil
+	case componentRedis:
+		return redisContainerName, nil
+	case componentCore, "orca":
+		return orcaContainerName, nil
+	}
+	if svc, ok := companionServices[name]; ok {
+		return svc.ContainerName, nil
+	}
+	return "", fmt.Errorf("unknown component %q, must be one of: %s", name, strings.Join(componentNames(), ", "))
+}
+
+// shellCommand starts bash where the image has it, falling back to sh
+const shellCommand = "if command -v bash >/dev/null 2>&1; then exec bash; else exec sh; fi"
+
+// openShell starts an interactive shell in a container, returning its exit code
+func openShell(containerName, shell string) int {
+	if shell == "" {
+		return execInContainer(containerName, "sh", "-c", shellCommand)
+	}
+	return execInContainer(containerName, shell)
+}
+
+// resolveCopyPath replaces the component in a `component:path` argument of `orca cp`
+// with its container. Other arguments, including Windows paths such as C:\data, are
+// local paths and returned unchanged.
+func resolveCopyPath(arg string) (string, bool, error) {
+	name, path, found := strings.Cut(arg, ":")
+	if !found {
+		return arg, false, nil
+	}
+	containerName, err := componentContainer(name)
+	if err != nil {
+		return arg, false, nil
+	}
+	if path == "" {
+		return "", false, fmt.Errorf("missing path in %q, e.g. %s:/tmp", arg, name)
+	}
+	return containerName + ":" + path, true, nil
+}
+
+// copyFiles runs docker cp between a container and the host, returning its exit code
+func copyFiles(source, destination string, flags ...string) int {
+	source, fromContainer, err := resolveCopyPath(source)
+	if err != nil {
+		printError(err.Error())
+		return 1
+	}
+	destination, toContainer, err := resolveCopyPath(destination)
+	if err != nil {
+		printError(err.Error())
+		return 1
+	}
+	if fromContainer == toContainer {
+		printError(fmt.Sprintf(
+			"Exactly one of the source and destination must be a component path such as postgres:/tmp, with a component from: %s",
+			strings.Join(componentNames(), ", "),
+		))
+		return 1
+	}
+
+	containerName, _, _ := strings.Cut(source, ":")
+	if toContainer {
+		containerName, _, _ = strings.Cut(destination, ":")
+	}
+	if status := getContainerStatus(containerName); status == "not found" {
+		printError(fmt.Sprintf("%s does not exist. Start the stack with `orca start`", containerName))
+		return 1
+	}
+
+	args := append(append([]string{"cp"}, flags...), source, destination)
+	cmd := dockerCommand(args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		printError(fmt.Sprintf("Failed to copy: %v", err))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var exportFormats = []string{"compose", "k8s"}
+
+// exportService is a container of the pipeline, described independently of the
+// format it is exported to
+type exportService struct {
+	Name    string
+	Image   string
+	Command []string
+	// Env holds KEY=VALUE pairs
+	Env []string
+	// Port is the port the container listens on, zero when it is not called
+	Port int
+	// HostPort publishes Port on the host, zero when it is not published
+	HostPort int
+	// Volume holds the data of the service, mounted at Mount
+	Volume    string
+	Mount     string
+	Restart   string
+	DependsOn []string
+	// ExtraHosts are host:address entries, e.g. host.docker.internal:host-gateway
+	ExtraHosts []string
+}
+
+// exportServices describes the stack of orca.json, with the settings `orca start`
+// applies, followed by the project's processor, as `orca deploy` runs it. A
+// relative env file of the core is resolved against baseDir.
+func exportServices(config *OrcaConfigFile, baseDir string) ([]exportService, error) {
+	pgConfig := config.Postgres
+	if pgConfig == nil {
+		pgConfig = &PostgresConfig{}
+	}
+	var tz string
+	if config.Timezone != "" {
+		resolved, err := resolveTimezone(config.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		tz = resolved
+		settings := map[string]string{"timezone": tz, "log_timezone": tz}
+		for key, value := range pgConfig.Settings {
+			settings[key] = value
+		}
+		withTimezone := *pgConfig
+		withTimezone.Settings = settings
+		pgConfig = &withTimezone
+	}
+	if config.Redis != nil {
+		if err := config.Redis.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.Core != nil {
+		if err := config.Core.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateRestartPolicies(config.Restart); err != nil {
+		return nil, err
+	}
+	coreEnvPairs, err := coreEnv(config.Core, baseDir)
+	if err != nil {
+		return nil, err
+	}
+	if tz != "" {
+		coreEnvPairs = append(coreEnvPairs, "TZ="+tz)
+	}
+	corePort, err := config.orcaHostPort()
+	if err != nil {
+		return nil, err
+	}
+
+	postgres := exportService{
+		Name:    pgContainerName,
+		Image:   stackImages[componentPostgres],
+		Command: append([]string{"postgres"}, postgresServerArgs(pgConfig)...),
+		Env:     []string{"POSTGRES_USER=orca", "POSTGRES_PASSWORD=orca", "POSTGRES_DB=orca"},
+		Port:    pgInternalPort,
+		Volume:  pgContainerName + "-data",
+		Mount:   "/var/lib/postgresql",
+		Restart: config.Restart[componentPostgres],
+	}
+	if pgConfig.Locale != "" {
+		postgres.Env = append(postgres.Env, "POSTGRES_INITDB_ARGS=--locale="+pgConfig.Locale, "LANG="+pgConfig.Locale)
+	}
+	redis := exportService{
+		Name:    redisContainerName,
+		Image:   stackImages[componentRedis],
+		Command: append([]string{"redis-server"}, redisServerArgs(config.Redis)...),
+		Port:    redisInternalPort,
+		Volume:  redisContainerName + "-data",
+		Mount:   "/data",
+		Restart: config.Restart[componentRedis],
+	}
+	// the core's arguments, bar the ones specific to docker run
+	core := exportService{
+		Name:    orcaContainerName,
+		Image:   stackImages[componentCore],
+		Command: []string{"-migrate"},
+		Env: mergeEnv([]string{
+			fmt.Sprintf("ORCA_CONNECTION_STRING=postgresql://orca:orca@%s:%d/orca?sslmode=disable", pgContainerName, pgInternalPort),
+			fmt.Sprintf("ORCA_PORT=%d", orcaInternalPort),
+			"ORCA_LOG_LEVEL=DEBUG",
+		}, coreEnvPairs),
+		Port:       orcaInternalPort,
+		HostPort:   cmp.Or(corePort, 33670),
+		Restart:    config.Restart[componentCore],
+		DependsOn:  []string{pgContainerName, redisContainerName},
+		ExtraHosts: []string{"host.docker.internal:host-gateway"},
+	}
+	services := []exportService{postgres, redis, core}
+
+	if config.ProjectName != "" {
+		deployment := &processorDeployment{
+			Project:   config.ProjectName,
+			Image:     processorRepository(config) + ":latest",
+			Container: processorContainerName(config.ProjectName),
+			Port:      cmp.Or(config.ProcessorPort, defaultProcessorPort),
+		}
+		services = append(services, exportService{
+			Name:      deployment.Container,
+			Image:     deployment.Image,
+			Env:       deployment.env(),
+			Port:      deployment.Port,
+			Restart:   "unless-stopped",
+			DependsOn: []string{orcaContainerName},
+		})
+	}
+	return services, nil
+}
+
+// mergeEnv returns the KEY=VALUE pairs of base with those of overrides, which replace
+// the value of a key already in base, as later -e flags do with docker run
+func mergeEnv(base, overrides []string) []string {
+	merged := slices.Clone(base)
+	for _, pair := range overrides {
+		key, _, _ := strings.Cut(pair, "=")
+		index := slices.IndexFunc(merged, func(existing string) bool { return strings.HasPrefix(existing, key+"=") })
+		if index >= 0 {
+			merged[index] = pair
+		} else {
+			merged = append(merged, pair)
+		}
+	}
+	return merged
+}
+
+// composeManifest returns a Docker Compose file running the services. Services are
+// named after the containers of the stack, so they reach each other by the same
+// host names as under `orca start`.
+func composeManifest(project string, services []exportService) string {
+	var entries, volumes yamlMap
+	for _, service := range services {
+		entry := yamlMap{{"image", service.Image}}
+		if len(service.Command) > 0 {
+			entry = append(entry, yamlField{"command", service.Command})
+		}
+		if len(service.Env) > 0 {
+			entry = append(entry, yamlField{"environment", service.Env})
+		}
+		if service.HostPort > 0 {
+			entry = append(entry, yamlField{"ports", []string{fmt.Sprintf("%d:%d", service.HostPort, service.Port)}})
+		}
+		if service.Volume != "" {
+			entry = append(entry, yamlField{"volumes", []string{service.Volume + ":" + service.Mount}})
+			volumes = append(volumes, yamlField{service.Volume, yamlMap{}})
+		}
+		if len(service.ExtraHosts) > 0 {
+			entry = append(entry, yamlField{"extra_hosts", service.ExtraHosts})
+		}
+		if len(service.DependsOn) > 0 {
+			entry = append(entry, yamlField{"depends_on", service.DependsOn})
+		}
+		if service.Restart != "" {
+			entry = append(entry, yamlField{"restart", service.Restart})
+		}
+		entries = append(entries, yamlField{service.Name, entry})
+	}
+
+	manifest := yamlMap{{"name", project}, {"services", entries}}
+	if len(volumes) > 0 {
+		manifest = append(manifest, yamlField{"volumes", volumes})
+	}
+	return exportHeader + marshalYAML(manifest)
+}
+
+// kubernetesNamePattern matches the DNS labels Kubernetes requires of service names
+var kubernetesNamePattern = regexp.MustCompile(`^[a-z]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// kubernetesManifest returns Kubernetes manifests running the services: a
+// Deployment per service, a Service for each one that is called, and a
+// PersistentVolumeClaim for each data volume. The core's Service is a LoadBalancer,
+// as clients outside the cluster connect to it.
+func kubernetesManifest(project string, services []exportService) (string, error) {
+	var documents []string
+	for _, service := range services {
+		if !kubernetesNamePattern.MatchString(service.Name) {
+			return "", fmt.Errorf("%s is not a valid Kubernetes service name, use a stack project of lowercase letters, digits and '-'", service.Name)
+		}
+		labels := yamlMap{
+			{"app.kubernetes.io/name", service.Name},
+			{"app.kubernetes.io/part-of", project},
+		}
+
+		container := yamlMap{{"name", service.Name}, {"image", service.Image}}
+		if len(service.Command) > 0 {
+			// the images' entrypoints run the command, as with docker run
+			container = append(container, yamlField{"args", service.Command})
+		}
+		if len(service.Env) > 0 {
+			var env []any
+			for _, pair := range service.Env {
+				key, value, _ := strings.Cut(pair, "=")
+				env = append(env, yamlMap{{"name", key}, {"value", value}})
+			}
+			container = append(container, yamlField{"env", env})
+		}
+		if service.Port > 0 {
+			container = append(container, yamlField{"ports", []any{yamlMap{{"containerPort", service.Port}}}})
+		}
+		podSpec := yamlMap{}
+		deploymentSpec := yamlMap{{"replicas", 1}, {"selector", yamlMap{{"matchLabels", labels}}}}
+		if service.Volume != "" {
+			container = append(container, yamlField{"volumeMounts", []any{yamlMap{{"name", "data"}, {"mountPath", service.Mount}}}})
+			podSpec = append(podSpec, yamlField{"volumes", []any{yamlMap{
+				{"name", "data"},
+				{"persistentVolumeClaim", yamlMap{{"claimName", service.Volume}}},
+			}}})
+			// a ReadWriteOnce volume cannot be mounted by two pods during a rolling update
+			deploymentSpec = append(deploymentSpec, yamlField{"strategy", yamlMap{{"type", "Recreate"}}})
+
+			documents = append(documents, marshalYAML(yamlMap{
+				{"apiVersion", "v1"},
+				{"kind", "PersistentVolumeClaim"},
+				{"metadata", yamlMap{{"name", service.Volume}, {"labels", labels}}},
+				{"spec", yamlMap{
+					{"accessModes", []string{"ReadWriteOnce"}},
+					{"resources", yamlMap{{"requests", yamlMap{{"storage", "1Gi"}}}}},
+				}},
+			}))
+		}
+		podSpec = append(yamlMap{{"containers", []any{container}}}, podSpec...)
+		deploymentSpec = append(deploymentSpec, yamlField{"template", yamlMap{
+			{"metadata", yamlMap{{"labels", labels}}},
+			{"spec", podSpec},
+		}})
+
+		documents = append(documents, marshalYAML(yamlMap{
+			{"apiVersion", "apps/v1"},
+			{"kind", "Deployment"},
+			{"metadata", yamlMap{{"name", service.Name}, {"labels", labels}}},
+			{"spec", deploymentSpec},
+		}))
+
+		if service.Port > 0 {
+			serviceSpec := yamlMap{
+				{"selector", labels},
+				{"ports", []any{yamlMap{{"port", service.Port}, {"targetPort", service.Port}}}},
+			}
+			if service.HostPort > 0 {
+				serviceSpec = append(yamlMap{{"type", "LoadBalancer"}}, serviceSpec...)
+			}
+			documents = append(documents, marshalYAML(yamlMap{
+				{"apiVersion", "v1"},
+				{"kind", "Service"},
+				{"metadata", yamlMap{{"name", service.Name}, {"labels", labels}}},
+				{"spec", serviceSpec},
+			}))
+		}
+	}
+	return exportHeader + strings.Join(documents, "---\n"), nil
+}
+
+const exportHeader = "# Generated by orca export from orca.json\n"
+
+// exportManifest returns the manifest of the pipeline of orca.json at configPath in
+// one of exportFormats
+func exportManifest(config *OrcaConfigFile, configPath, format string) (string, error) {
+	services, err := exportServices(config, filepath.Dir(configPath))
+	if err != nil {
+		return "", err
+	}
+	project := "orca"
+	if stackProject != "" {
+		project += "-" + stackProject
+	}
+	switch format {
+	case "compose":
+		return composeManifest(project, services), nil
+	case "k8s":
+		return kubernetesManifest(project, services)
+	}
+	return "", fmt.Errorf("invalid export format %q, must be one of: %s", format, strings.Join(exportFormats, ", "))
+}
+
+// yamlMap is a YAML mapping that keeps the order of its keys, as manifests read
+// best in their conventional order
+type yamlMap []yamlField
+
+type yamlField struct {
+	Key   string
+	Value any
+}
+
+// marshalYAML encodes a yamlMap, []any, []string, string or int as block YAML
+func marshalYAML(value any) string {
+	var b strings.Builder
+	writeYAML(&b, value, 0)
+	return b.String()
+}
+
+func writeYAML(b *strings.Builder, value any, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch value := value.(type) {
+	case yamlMap:
+		for _, field := range value {
+			b.WriteString(pad + yamlScalar(field.Key) + ":")
+			writeYAMLValue(b, field.Value, indent+1)
+		}
+	case []any:
+		for _, item := range value {
+			b.WriteString(pad + "-")
+			if nested, ok := item.(yamlMap); ok && len(nested) > 0 {
+				// the first key of a mapping follows the dash, the others align with it
+				var inner strings.Builder
+				writeYAML(&inner, nested, indent+1)
+				b.WriteString(" " + strings.TrimPrefix(inner.String(), pad+"  "))
+				continue
+			}
+			writeYAMLValue(b, item, indent+1)
+		}
+	case []string:
+		items := make([]any, len(value))
+		for ii, item := range value {
+			items[ii] = item
+		}
+		writeYAML(b, items, indent)
+	}
+}
+
+// writeYAMLValue writes the value of a key or list item, inline when it is a scalar
+// or empty, and on the following lines otherwise
+func writeYAMLValue(b *strings.Builder, value any, indent int) {
+	switch nested := value.(type) {
+	case yamlMap:
+		if len(nested) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+	case []any:
+		if len(nested) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+	case []string:
+		if len(nested) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+	default:
+		b.WriteString(" " + yamlScalar(value) + "\n")
+		return
+	}
+	b.WriteString("\n")
+	writeYAML(b, value, indent)
+}
+
+// yamlPlainPattern matches strings that YAML reads back as the same string without
+// quotes. Anything else, including numbers and words YAML 1.1 reads as booleans,
+// is double quoted.
+var yamlPlainPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_./:@=-]*$`)
+
+var yamlReservedWords = []string{"y", "n", "yes", "no", "on", "off", "true", "false", "null"}
+
+func yamlScalar(value any) string {
+	switch value := value.(type) {
+	case int:
+		return strconv.Itoa(value)
+	case string:
+		if yamlPlainPattern.MatchString(value) && !strings.HasSuffix(value, ":") && !slices.Contains(yamlReservedWords, strings.ToLower(value)) {
+			return value
+		}
+		// JSON strings are valid double-quoted YAML scalars
+		quoted, _ := json.Marshal(value)
+		return string(quoted)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarshalYAML(t *testing.T) {
+	got := marshalYAML(yamlMap{
+		{"name", "orca"},
+		{"command", []string{"-migrate", "yes", "3335", "", "tab\there", "bell\a"}},
+		{"env", []any{
+			yamlMap{{"name", "URL"}, {"value", "postgresql://orca@pg:5432/orca?sslmode=disable"}},
+			yamlMap{{"name", "PORT"}, {"value", 3335}},
+		}},
+		{"volumes", yamlMap{{"data", yamlMap{}}}},
+		{"extra", []string{}},
+	})
+	want := `name: orca
+command:
+  - "-migrate"
+  - "yes"
+  - "3335"
+  - ""
+  - "tab\there"
+  - "bell\u0007"
+env:
+  - name: URL
+    value: "postgresql://orca@pg:5432/orca?sslmode=disable"
+  - name: PORT
+    value: 3335
+volumes:
+  data: {}
+extra: []
+`
+	if got != want {
+		t.Errorf("marshalYAML =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExportManifest(t *testing.T) {
+	if err := setStackProject(""); err != nil {
+		t.Fatal(err)
+	}
+	config := &OrcaConfigFile{
+		ProjectName:          "Speed Checks",
+		OrcaConnectionString: "localhost:32670",
+		Core:                 &CoreConfig{LogLevel: "info"},
+		Restart:              map[string]string{componentCore: "on-failure:3"},
+	}
+
+	compose, err := exportManifest(config, "orca.json", "compose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\n  orca-processor-speed-checks:\n    image: speed-checks:latest\n",
+		"      - ORCA_CORE=orca-instance:3335\n      - PROCESSOR_ADDRESS=orca-processor-speed-checks:5377\n",
+		"    ports:\n      - \"32670:3335\"\n",
+		"    restart: on-failure:3\n",
+		"  orca-pg-instance-data: {}\n",
+	} {
+		if !strings.Contains(compose, want) {
+			t.Errorf("compose manifest is missing %q:\n%s", want, compose)
+		}
+	}
+	// the configured level replaces the default, rather than following it
+	if strings.Contains(compose, "ORCA_LOG_LEVEL=DEBUG") || !strings.Contains(compose, "ORCA_LOG_LEVEL=INFO") {
+		t.Errorf("compose manifest does not apply the core log level:\n%s", compose)
+	}
+
+	k8s, err := exportManifest(config, "orca.json", "k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(k8s, "kind: Deployment\n"); got != 4 {
+		t.Errorf("k8s manifest has %d Deployments, want one per stack component and the processor", got)
+	}
+	if got := strings.Count(k8s, "kind: PersistentVolumeClaim\n"); got != 2 {
+		t.Errorf("k8s manifest has %d PersistentVolumeClaims, want one per data volume", got)
+	}
+	if !strings.Contains(k8s, "            - name: PROCESSOR_PORT\n              value: \"5377\"\n") {
+		t.Errorf("k8s manifest is missing the processor port as a string:\n%s", k8s)
+	}
+
+	if _, err := exportManifest(config, "orca.json", "helm"); err == nil {
+		t.Error("exportManifest accepted an unknown format")
+	}
+
+	if err := setStackProject("staging_eu"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { setStackProject("") })
+	if _, err := exportManifest(config, "orca.json", "k8s"); err == nil {
+		t.Error("exportManifest accepted names Kubernetes rejects")
+	}
+}
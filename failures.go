@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// failedExecution is an algorithm that stored no result for a window the core
+// dispatched to it. The store keeps no record of failures, so they are found as
+// results missing once processing should have finished.
+type failedExecution struct {
+	WindowID          int64     `json:"windowId"`
+	WindowType        string    `json:"windowType"`
+	WindowTypeVersion string    `json:"windowTypeVersion"`
+	Algorithm         string    `json:"algorithm"`
+	AlgorithmVersion  string    `json:"algorithmVersion"`
+	Processor         string    `json:"processor"`
+	Received          time.Time `json:"received"`
+	// Errors are those the core logged while processing the window
+	Errors []string `json:"errors,omitempty"`
+}
+
+// failuresFilter narrows which failed executions are listed, retried or purged
+type failuresFilter struct {
+	// Algorithm is an algorithm name, optionally pinned to a version as name@version
+	Algorithm string
+	Since     time.Time
+	// Grace excludes windows received this recently, which may still be processing
+	Grace     time.Duration
+	WindowIDs []int64
+	Limit     int
+}
+
+// buildFailuresQuery returns a query of the algorithms missing a result for the
+// windows they take. Algorithms registered after a window was received were never
+// dispatched to it, so they are left out.
+func buildFailuresQuery(filter failuresFilter) string {
+	query := `SELECT
+    w.id AS window_id,
+    wt.name AS window_type,
+    wt.version AS window_type_version,
+    a.name AS algorithm,
+    a.version AS algorithm_version,
+    p.name AS processor,
+    ` + storeClockColumn("w.created") + ` AS created
+FROM windows w
+JOIN window_type wt ON wt.id = w.window_type_id
+JOIN algorithm a ON a.window_type_id = w.window_type_id
+JOIN processor p ON p.id = a.processor_id
+WHERE NOT EXISTS (
+    SELECT 1 FROM results r WHERE r.windows_id = w.id AND r.algorithm_id = a.id
+)
+  AND a.created <= w.created`
+
+	// created holds the store's local time, so bounds are computed in its clock
+	conditions := []string{
+		fmt.Sprintf("w.created < LOCALTIMESTAMP - make_interval(secs => %d)", int64(filter.Grace.Seconds())),
+	}
+	if filter.Algorithm != "" {
+		name, version, pinned := strings.Cut(filter.Algorithm, "@")
+		conditions = append(conditions, "a.name = "+sqlLiteral(name))
+		if pinned {
+			conditions = append(conditions, "a.version = "+sqlLiteral(version))
+		}
+	}
+	if !filter.Since.IsZero() {
+		since := "TIMESTAMPTZ " + sqlLiteral(filter.Since.UTC().Format(time.RFC3339Nano))
+		conditions = append(conditions, "w.created >= "+storeClockColumn(since))
+	}
+	if len(filter.WindowIDs) > 0 {
+		ids := make([]string, len(filter.WindowIDs))
+		for ii, id := range filter.WindowIDs {
+			ids[ii] = strconv.FormatInt(id, 10)
+		}
+		conditions = append(conditions, "w.id IN ("+strings.Join(ids, ", ")+")")
+	}
+	query += "\n  AND " + strings.Join(conditions, "\n  AND ")
+	query += "\nORDER BY w.created, w.id, p.name, a.name, a.version"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf("\nLIMIT %d", filter.Limit)
+	}
+	return query
+}
+
+// listFailures returns the failed executions matching the filter. Errors the core
+// logged up to logSpan after each window was received are attached when the core
+// is running and logSpan is positive.
+func listFailures(filter failuresFilter, logSpan time.Duration) ([]failedExecution, error) {
+	output, err := queryStoreJSON(buildFailuresQuery(filter))
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		WindowID          int64  `json:"window_id"`
+		WindowType        string `json:"window_type"`
+		WindowTypeVersion string `json:"window_type_version"`
+		Algorithm         string `json:"algorithm"`
+		AlgorithmVersion  string `json:"algorithm_version"`
+		Processor         string `json:"processor"`
+		Created           string `json:"created"`
+	}
+	if err := json.Unmarshal(output, &rows); err != nil {
+		return nil, fmt.Errorf("failed to read failures: %w", err)
+	}
+
+	failures := make([]failedExecution, len(rows))
+	for ii, row := range rows {
+		received, err := parseStoreClock(row.Created)
+		if err != nil {
+			return nil, err
+		}
+		failures[ii] = failedExecution{
+			WindowID:          row.WindowID,
+			WindowType:        row.WindowType,
+			WindowTypeVersion: row.WindowTypeVersion,
+			Algorithm:         row.Algorithm,
+			AlgorithmVersion:  row.AlgorithmVersion,
+			Processor:         row.Processor,
+			Received:          received,
+		}
+	}
+
+	if len(failures) == 0 || logSpan <= 0 || getContainerStatus(orcaContainerName) != "running" {
+		return failures, nil
+	}
+	// failures are ordered by when their window was received, so one read of the
+	// logs covers them all
+	logs, err := readCoreLogs(failures[0].Received.Add(-time.Second), failures[len(failures)-1].Received.Add(logSpan))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, warningStyle.Render("Skipping core logs: "+err.Error()))
+		return failures, nil
+	}
+	attachFailureErrors(failures, logs, logSpan)
+	return failures, nil
+}
+
+// attachFailureErrors attaches to each failure the errors the core logged within
+// logSpan of its window being received
+func attachFailureErrors(failures []failedExecution, logs []coreLogLine, logSpan time.Duration) {
+	for ii := range failures {
+		from := failures[ii].Received.Add(-time.Second)
+		to := failures[ii].Received.Add(logSpan)
+		for _, line := range logs {
+			if line.Level != "ERROR" || line.Time.Before(from) || !line.Time.Before(to) {
+				continue
+			}
+			message := line.Message
+			if line.Attrs != "" {
+				message += " " + line.Attrs
+			}
+			if !slices.Contains(failures[ii].Errors, message) {
+				failures[ii].Errors = append(failures[ii].Errors, message)
+			}
+		}
+	}
+}
+
+// failedWindowIDs returns the distinct windows of the failures, in order
+func failedWindowIDs(failures []failedExecution) []int64 {
+	var ids []int64
+	for _, failure := range failures {
+		if !slices.Contains(ids, failure.WindowID) {
+			ids = append(ids, failure.WindowID)
+		}
+	}
+	return ids
+}
+
+// showFailures prints the failed executions as a table, with the first error
+// logged for each
+func showFailures(w io.Writer, failures []failedExecution) {
+	rows := [][]string{{"WINDOW", "RECEIVED", "WINDOW TYPE", "PROCESSOR", "ALGORITHM", "ERROR"}}
+	for _, failure := range failures {
+		logged := "-"
+		if len(failure.Errors) > 0 {
+			logged = failure.Errors[0]
+			if len(logged) > 80 {
+				logged = logged[:77] + "..."
+			}
+			if len(failure.Errors) > 1 {
+				logged += fmt.Sprintf(" (+%d more)", len(failure.Errors)-1)
+			}
+		}
+		rows = append(rows, []string{
+			strconv.FormatInt(failure.WindowID, 10),
+			failure.Received.Format("2006-01-02 15:04:05"),
+			failure.WindowType + " " + failure.WindowTypeVersion,
+			failure.Processor,
+			failure.Algorithm + " " + failure.AlgorithmVersion,
+			logged,
+		})
+	}
+	printTable(w, rows)
+}
+
+// storedWindow rebuilds a window as the core received it from its stored row
+func storedWindow(row traceWindowRow) (*pb.Window, error) {
+	from, err := parseStoreTimestamp(row.TimeFrom)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseStoreTimestamp(row.TimeTo)
+	if err != nil {
+		return nil, err
+	}
+	window := &pb.Window{
+		TimeFrom:          timestamppb.New(from),
+		TimeTo:            timestamppb.New(to),
+		WindowTypeName:    row.WindowType,
+		WindowTypeVersion: row.WindowTypeVersion,
+		Origin:            row.Origin,
+	}
+	if len(row.Metadata) > 0 && string(row.Metadata) != "null" {
+		var fields map[string]any
+		if err := json.Unmarshal(row.Metadata, &fields); err != nil {
+			return nil, fmt.Errorf("window %d has unreadable metadata: %w", row.ID, err)
+		}
+		if window.Metadata, err = structpb.NewStruct(fields); err != nil {
+			return nil, fmt.Errorf("window %d has unsupported metadata: %w", row.ID, err)
+		}
+	}
+	return window, nil
+}
+
+// deleteWindows deletes windows along with their results in a single transaction
+func deleteWindows(windowIDs []int64) error {
+	ids := make([]string, len(windowIDs))
+	for ii, id := range windowIDs {
+		ids[ii] = strconv.FormatInt(id, 10)
+	}
+	list := strings.Join(ids, ", ")
+	script := fmt.Sprintf("DELETE FROM results WHERE windows_id IN (%s);\nDELETE FROM windows WHERE id IN (%s);", list, list)
+	_, err := runPsql(strings.NewReader(script), "--single-transaction", "-q", "-f", "-")
+	return err
+}
+
+// retryFailedWindow emits a stored window to the core again, which dispatches it
+// to every algorithm of its window type. The core stores it as a new window, so
+// the original is deleted with its partial results once processing is triggered.
+func retryFailedWindow(ctx context.Context, client pb.OrcaCoreClient, windowID int64) error {
+	row, err := queryTraceWindow(windowID)
+	if err != nil {
+		return err
+	}
+	window, err := storedWindow(*row)
+	if err != nil {
+		return err
+	}
+
+	status, err := client.EmitWindow(ctx, window)
+	if err != nil {
+		return fmt.Errorf("the core rejected window %d: %w", windowID, err)
+	}
+	switch status.GetStatus() {
+	case pb.WindowEmitStatus_PROCESSING_TRIGGERED:
+	case pb.WindowEmitStatus_NO_TRIGGERED_ALGORITHMS:
+		return fmt.Errorf("no algorithms take window type %s %s any longer", row.WindowType, row.WindowTypeVersion)
+	default:
+		return fmt.Errorf("the core failed to trigger processing of window %d", windowID)
+	}
+
+	if err := deleteWindows([]int64{windowID}); err != nil {
+		return fmt.Errorf("window %d was emitted again, but removing the original failed: %w", windowID, err)
+	}
+	return nil
+}
@@ -0,0 +1,932 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// fakeDockerCommand is the hidden command the fake engine runs the CLI as
+const fakeDockerCommand = "__fake-docker"
+
+// fakeDockerFirstPort is where the fake engine starts assigning ports published as 0
+const fakeDockerFirstPort = 32768
+
+// fakeDockerState is everything the fake engine knows about. Nothing is actually run:
+// containers only record how they were created and whether they are running.
+type fakeDockerState struct {
+	Containers map[string]*fakeContainer `json:"containers"`
+	Volumes    map[string]*fakeResource  `json:"volumes"`
+	Networks   map[string]*fakeResource  `json:"networks"`
+	NextPort   int                       `json:"nextPort"`
+}
+
+type fakeContainer struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	// Args is the command following the image
+	Args    []string          `json:"args"`
+	Env     []string          `json:"env"`
+	Labels  map[string]string `json:"labels"`
+	Ports   map[string]string `json:"ports"`
+	Mounts  []string          `json:"mounts"`
+	Network string            `json:"network"`
+	Restart string            `json:"restart"`
+	Running bool              `json:"running"`
+	Paused  bool              `json:"paused,omitempty"`
+	Started time.Time         `json:"started"`
+	Stopped time.Time         `json:"stopped"`
+}
+
+type fakeResource struct {
+	Name    string            `json:"name"`
+	Labels  map[string]string `json:"labels"`
+	Created time.Time         `json:"created"`
+}
+
+// fakeDocker runs docker CLI commands against a fakeDockerState
+type fakeDocker struct {
+	state  *fakeDockerState
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// fakeListRow is the data of a row of `ps` and `ls` output, as used by --format
+type fakeListRow struct {
+	ID     string
+	Name   string
+	Names  string
+	Image  string
+	Status string
+	State  string
+	Ports  string
+	Driver string
+	labels map[string]string
+}
+
+// Label returns the value of a label, as `{{.Label "name"}}` does in docker
+func (r fakeListRow) Label(name string) string {
+	return r.labels[name]
+}
+
+var fakeTemplateFuncs = template.FuncMap{
+	"json": func(value any) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+func newFakeDockerState() *fakeDockerState {
+	return &fakeDockerState{
+		Containers: map[string]*fakeContainer{},
+		Volumes:    map[string]*fakeResource{},
+		Networks:   map[string]*fakeResource{},
+		NextPort:   fakeDockerFirstPort,
+	}
+}
+
+func loadFakeDockerState(path string) (*fakeDockerState, error) {
+	state := newFakeDockerState()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return state, nil
+}
+
+func saveFakeDockerState(path string, state *fakeDockerState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return err
+	}
+	// replace the file in one step, as commands may run concurrently
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+// runFakeDockerCommand runs one docker command against the state file named by
+// ORCA_FAKE_DOCKER, returning its exit code
+func runFakeDockerCommand(args []string) int {
+	path, err := fakeDockerStatePath(os.Getenv(fakeDockerEnv))
+	if err != nil || os.Getenv(fakeDockerEnv) == "" {
+		fmt.Fprintln(os.Stderr, "fake docker: "+fakeDockerEnv+" is not set")
+		return 1
+	}
+	state, err := loadFakeDockerState(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fake docker: "+err.Error())
+		return 1
+	}
+
+	fake := &fakeDocker{state: state, stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	code := fake.run(args)
+	if err := saveFakeDockerState(path, state); err != nil {
+		fmt.Fprintln(os.Stderr, "fake docker: "+err.Error())
+		return 1
+	}
+	return code
+}
+
+// run runs a docker command, returning its exit code
+func (d *fakeDocker) run(args []string) int {
+	if len(args) == 0 {
+		return d.fail("no command given")
+	}
+	switch args[0] {
+	case "--version", "version":
+		fmt.Fprintln(d.stdout, "Docker version 0.0.0-fake, build orca")
+		return 0
+	case "info":
+		return d.info(args[1:])
+	case "context":
+		return d.context(args[1:])
+	case "ps":
+		return d.ps(args[1:])
+	case "inspect":
+		return d.inspect(args[1:])
+	case "run", "create":
+		return d.create(args[0] == "run", args[1:])
+	case "start", "stop", "pause", "unpause", "rm", "update", "port", "logs", "cp", "exec":
+		return d.container(args[0], args[1:])
+	case "volume":
+		return d.resource("volume", d.state.Volumes, args[1:])
+	case "network":
+		return d.resource("network", d.state.Networks, args[1:])
+	case "image":
+		return d.image(args[1:])
+	case "login", "logout":
+		return d.login(args[0], args[1:])
+	case "pull":
+		return d.pull(args[1:])
+	}
+	return d.unsupported(args)
+}
+
+// pull accepts any image, as there is no registry behind the fake engine
+func (d *fakeDocker) pull(args []string) int {
+	_, refs := parseFakeArgs(args, nil, false)
+	if len(refs) != 1 {
+		return d.fail("invalid reference format")
+	}
+	fmt.Fprintf(d.stdout, "Status: Downloaded newer image for %s\n", refs[0])
+	return 0
+}
+
+// login accepts any credentials, as there is no registry behind the fake engine
+func (d *fakeDocker) login(command string, args []string) int {
+	flags, registries := parseFakeArgs(args, []string{"username", "u", "password", "p"}, false)
+	registry := "https://index.docker.io/v1/"
+	if len(registries) > 0 {
+		registry = registries[0]
+	}
+	if command == "logout" {
+		fmt.Fprintf(d.stdout, "Removing login credentials for %s\n", registry)
+		return 0
+	}
+	if len(flags["password-stdin"]) > 0 {
+		password, _ := io.ReadAll(d.stdin)
+		if len(bytes.TrimSpace(password)) == 0 {
+			return d.fail("password required")
+		}
+	}
+	fmt.Fprintln(d.stdout, "Login Succeeded")
+	return 0
+}
+
+func (d *fakeDocker) fail(format string, args ...any) int {
+	fmt.Fprintf(d.stderr, "Error response from daemon: "+format+"\n", args...)
+	return 1
+}
+
+func (d *fakeDocker) unsupported(args []string) int {
+	fmt.Fprintf(d.stderr, "fake docker: unsupported command: docker %s\n", strings.Join(args, " "))
+	return 1
+}
+
+// parseFakeArgs splits docker arguments into flags and positional arguments. Flags in
+// valueFlags take a value, others are boolean. With stopAtPositional, everything from
+// the first positional argument on is positional, as for the command given to run.
+func parseFakeArgs(args []string, valueFlags []string, stopAtPositional bool) (map[string][]string, []string) {
+	flags := map[string][]string{}
+	var positional []string
+	for ii := 0; ii < len(args); ii++ {
+		arg := args[ii]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if stopAtPositional {
+				return flags, append(positional, args[ii:]...)
+			}
+			positional = append(positional, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !hasValue && slices.Contains(valueFlags, name) && ii+1 < len(args) {
+			ii++
+			value = args[ii]
+		}
+		flags[name] = append(flags[name], value)
+	}
+	return flags, positional
+}
+
+// parseLabels parses KEY=VALUE label flags
+func parseLabels(values []string) map[string]string {
+	labels := map[string]string{}
+	for _, value := range values {
+		key, val, _ := strings.Cut(value, "=")
+		labels[key] = val
+	}
+	return labels
+}
+
+// matchesFilters applies --filter name=... and label=... as docker does, requiring all to match
+func matchesFilters(name string, labels map[string]string, filters []string) bool {
+	for _, filter := range filters {
+		key, value, _ := strings.Cut(filter, "=")
+		switch key {
+		case "name":
+			if !strings.Contains(name, value) {
+				return false
+			}
+		case "label":
+			labelKey, labelValue, hasValue := strings.Cut(value, "=")
+			current, ok := labels[labelKey]
+			if !ok || (hasValue && current != labelValue) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// render writes each value through a --format template
+func (d *fakeDocker) render(format string, values []any) int {
+	tmpl, err := template.New("format").Funcs(fakeTemplateFuncs).Parse(format)
+	if err != nil {
+		fmt.Fprintf(d.stderr, "template parsing error: %v\n", err)
+		return 1
+	}
+	for _, value := range values {
+		if err := tmpl.Execute(d.stdout, value); err != nil {
+			fmt.Fprintf(d.stderr, "template: %v\n", err)
+			return 1
+		}
+		fmt.Fprintln(d.stdout)
+	}
+	return 0
+}
+
+func (d *fakeDocker) info(args []string) int {
+	flags, _ := parseFakeArgs(args, []string{"format", "f"}, false)
+	info := map[string]any{
+		"ServerVersion":   "0.0.0-fake",
+		"OperatingSystem": "Orca fake Docker",
+		"SecurityOptions": []string{},
+		"Containers":      len(d.state.Containers),
+	}
+	if format := lastFlag(flags, "format", "f"); format != "" {
+		return d.render(format, []any{info})
+	}
+	fmt.Fprintln(d.stdout, "Server Version: 0.0.0-fake")
+	fmt.Fprintln(d.stdout, "Operating System: Orca fake Docker")
+	return 0
+}
+
+func (d *fakeDocker) context(args []string) int {
+	if len(args) == 0 || args[0] != "inspect" {
+		return d.unsupported(append([]string{"context"}, args...))
+	}
+	flags, _ := parseFakeArgs(args[1:], []string{"format", "f"}, false)
+	context := map[string]any{
+		"Name": "orca-fake",
+		"Endpoints": map[string]any{
+			"docker": map[string]any{"Host": "fake://" + os.Getenv(fakeDockerEnv)},
+		},
+	}
+	if format := lastFlag(flags, "format", "f"); format != "" {
+		return d.render(format, []any{context})
+	}
+	data, _ := json.MarshalIndent([]any{context}, "", "    ")
+	fmt.Fprintln(d.stdout, string(data))
+	return 0
+}
+
+func lastFlag(flags map[string][]string, names ...string) string {
+	for _, name := range names {
+		if values := flags[name]; len(values) > 0 {
+			return values[len(values)-1]
+		}
+	}
+	return ""
+}
+
+// sortedContainers returns the containers ordered by name
+func (d *fakeDocker) sortedContainers() []*fakeContainer {
+	var containers []*fakeContainer
+	for _, container := range d.state.Containers {
+		containers = append(containers, container)
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+	return containers
+}
+
+// findContainer looks a container up by name or ID prefix
+func (d *fakeDocker) findContainer(ref string) *fakeContainer {
+	ref = strings.TrimPrefix(ref, "/")
+	if container, ok := d.state.Containers[ref]; ok {
+		return container
+	}
+	for _, container := range d.state.Containers {
+		if len(ref) >= 4 && strings.HasPrefix(container.ID, ref) {
+			return container
+		}
+	}
+	return nil
+}
+
+func fakeDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%d seconds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	}
+}
+
+func (c *fakeContainer) state() string {
+	switch {
+	case c.Running && c.Paused:
+		return "paused"
+	case c.Running:
+		return "running"
+	case c.Started.IsZero():
+		return "created"
+	default:
+		return "exited"
+	}
+}
+
+func (c *fakeContainer) status() string {
+	switch c.state() {
+	case "running":
+		return "Up " + fakeDuration(time.Since(c.Started))
+	case "paused":
+		return "Up " + fakeDuration(time.Since(c.Started)) + " (Paused)"
+	case "created":
+		return "Created"
+	default:
+		return fmt.Sprintf("Exited (0) %s ago", fakeDuration(time.Since(c.Stopped)))
+	}
+}
+
+func (c *fakeContainer) listRow() fakeListRow {
+	return fakeListRow{
+		ID:     c.ID[:12],
+		Name:   c.Name,
+		Names:  c.Name,
+		Image:  c.Image,
+		Status: c.status(),
+		State:  c.state(),
+		Ports:  c.listPorts(),
+		labels: c.Labels,
+	}
+}
+
+// listPorts returns the published ports in the shape of the ports column of `docker ps`
+func (c *fakeContainer) listPorts() string {
+	var ports []string
+	for containerPort, hostPort := range c.Ports {
+		ports = append(ports, fmt.Sprintf("0.0.0.0:%s->%s", hostPort, containerPort))
+	}
+	sort.Strings(ports)
+	return strings.Join(ports, ", ")
+}
+
+// inspect returns the container in the shape of `docker inspect`
+func (c *fakeContainer) inspect() map[string]any {
+	ports := map[string]any{}
+	for containerPort, hostPort := range c.Ports {
+		ports[containerPort] = []map[string]string{{"HostIp": "0.0.0.0", "HostPort": hostPort}}
+	}
+	return map[string]any{
+		"Id":    c.ID,
+		"Name":  "/" + c.Name,
+		"Path":  "docker-entrypoint.sh",
+		"Args":  c.Args,
+		"Image": fakeImageID(c.Image),
+		"State": map[string]any{
+			"Status":     c.state(),
+			"Running":    c.Running,
+			"ExitCode":   0,
+			"OOMKilled":  false,
+			"StartedAt":  c.Started.Format(time.RFC3339Nano),
+			"FinishedAt": c.Stopped.Format(time.RFC3339Nano),
+		},
+		"Config": map[string]any{
+			"Image":  c.Image,
+			"Cmd":    c.Args,
+			"Env":    c.Env,
+			"Labels": c.Labels,
+		},
+		"HostConfig": map[string]any{
+			"NetworkMode":   c.Network,
+			"RestartPolicy": map[string]any{"Name": c.Restart},
+			"PortBindings":  ports,
+		},
+		"NetworkSettings": map[string]any{"Ports": ports},
+		"Mounts":          c.Mounts,
+	}
+}
+
+// fakeImageID derives a stable image ID from an image reference
+func fakeImageID(image string) string {
+	sum := sha256.Sum256([]byte(image))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (d *fakeDocker) ps(args []string) int {
+	flags, _ := parseFakeArgs(args, []string{"filter", "f", "format"}, false)
+	all := len(flags["a"]) > 0 || len(flags["all"]) > 0
+
+	var rows []any
+	for _, container := range d.sortedContainers() {
+		if (all || container.Running) && matchesFilters(container.Name, container.Labels, append(flags["filter"], flags["f"]...)) {
+			rows = append(rows, container.listRow())
+		}
+	}
+
+	format := lastFlag(flags, "format")
+	if format == "" {
+		fmt.Fprintln(d.stdout, "CONTAINER ID\tIMAGE\tSTATUS\tNAMES")
+		format = "{{.ID}}\t{{.Image}}\t{{.Status}}\t{{.Names}}"
+	}
+	return d.render(format, rows)
+}
+
+func (d *fakeDocker) inspect(args []string) int {
+	flags, refs := parseFakeArgs(args, []string{"format", "f", "type"}, false)
+	if len(refs) == 0 {
+		return d.fail("inspect requires at least one argument")
+	}
+
+	var values []any
+	for _, ref := range refs {
+		if container := d.findContainer(ref); container != nil {
+			values = append(values, container.inspect())
+		} else if volume, ok := d.state.Volumes[ref]; ok {
+			values = append(values, volume.inspect("volume"))
+		} else if network, ok := d.state.Networks[ref]; ok {
+			values = append(values, network.inspect("network"))
+		} else {
+			fmt.Fprintf(d.stderr, "Error: No such object: %s\n", ref)
+			return 1
+		}
+	}
+
+	if format := lastFlag(flags, "format", "f"); format != "" {
+		return d.render(format, values)
+	}
+	data, _ := json.MarshalIndent(values, "", "    ")
+	fmt.Fprintln(d.stdout, string(data))
+	return 0
+}
+
+// create creates a container from `docker run` or `docker create` arguments
+func (d *fakeDocker) create(start bool, args []string) int {
+	flags, positional := parseFakeArgs(args, []string{
+		"name", "network", "p", "publish", "e", "env", "v", "volume", "label", "l",
+		"restart", "add-host", "entrypoint", "u", "user", "w", "workdir", "memory", "m", "cpus",
+	}, true)
+	if len(positional) == 0 {
+		return d.fail("run requires an image")
+	}
+
+	name := lastFlag(flags, "name")
+	if name == "" {
+		name = "fake_" + randomHex(4)
+	}
+	if _, exists := d.state.Containers[name]; exists {
+		return d.fail("Conflict. The container name \"/%s\" is already in use", name)
+	}
+	network := lastFlag(flags, "network")
+	if network != "" && network != "bridge" && network != "host" {
+		if _, ok := d.state.Networks[network]; !ok {
+			return d.fail("network %s not found", network)
+		}
+	}
+
+	container := &fakeContainer{
+		ID:      randomHex(32),
+		Name:    name,
+		Image:   positional[0],
+		Args:    positional[1:],
+		Labels:  parseLabels(append(flags["label"], flags["l"]...)),
+		Ports:   map[string]string{},
+		Network: network,
+		Restart: lastFlag(flags, "restart"),
+	}
+	if container.Restart == "" {
+		container.Restart = "no"
+	}
+	if container.Args == nil {
+		container.Args = []string{}
+	}
+	for _, pair := range append(flags["e"], flags["env"]...) {
+		container.Env = setEnv(container.Env, pair)
+	}
+	for _, mapping := range append(flags["p"], flags["publish"]...) {
+		hostPort, containerPort, ok := strings.Cut(mapping, ":")
+		if !ok {
+			hostPort, containerPort = "0", mapping
+		}
+		if hostPort == "0" {
+			hostPort = fmt.Sprint(d.state.NextPort)
+			d.state.NextPort++
+		}
+		if !strings.Contains(containerPort, "/") {
+			containerPort += "/tcp"
+		}
+		container.Ports[containerPort] = hostPort
+	}
+	for _, mount := range append(flags["v"], flags["volume"]...) {
+		source, _, _ := strings.Cut(mount, ":")
+		if !strings.HasPrefix(source, "/") && !strings.HasPrefix(source, ".") {
+			if _, ok := d.state.Volumes[source]; !ok {
+				d.state.Volumes[source] = &fakeResource{Name: source, Labels: map[string]string{}, Created: time.Now()}
+			}
+		}
+		container.Mounts = append(container.Mounts, mount)
+	}
+
+	// one-off helper containers, such as volume copies, complete immediately
+	if len(flags["rm"]) > 0 {
+		return 0
+	}
+
+	d.state.Containers[name] = container
+	if start {
+		container.Running = true
+		container.Started = time.Now()
+		if len(flags["d"]) > 0 || len(flags["detach"]) > 0 {
+			fmt.Fprintln(d.stdout, container.ID)
+		}
+	} else {
+		fmt.Fprintln(d.stdout, container.ID)
+	}
+	return 0
+}
+
+// setEnv sets a KEY=VALUE pair, replacing an earlier value of the key as docker does
+func setEnv(env []string, pair string) []string {
+	key, _, _ := strings.Cut(pair, "=")
+	for ii, existing := range env {
+		if existingKey, _, _ := strings.Cut(existing, "="); existingKey == key {
+			env[ii] = pair
+			return env
+		}
+	}
+	return append(env, pair)
+}
+
+func randomHex(n int) string {
+	data := make([]byte, n)
+	rand.Read(data)
+	return hex.EncodeToString(data)
+}
+
+// container runs the commands operating on existing containers
+func (d *fakeDocker) container(command string, args []string) int {
+	flags, positional := parseFakeArgs(args, []string{"restart", "tail", "n", "since", "until", "e", "env", "u", "user", "w", "workdir"}, command == "exec")
+	if len(positional) == 0 {
+		return d.fail("%s requires at least one argument", command)
+	}
+
+	if command == "cp" {
+		if len(positional) != 2 {
+			return d.fail("cp requires a source and a destination")
+		}
+		ref, _, found := strings.Cut(positional[1], ":")
+		if !found {
+			ref, _, _ = strings.Cut(positional[0], ":")
+		}
+		if d.findContainer(ref) == nil {
+			return d.fail("No such container: %s", ref)
+		}
+		return 0
+	}
+
+	refs := positional
+	if command == "exec" {
+		refs = positional[:1]
+	}
+	for _, ref := range refs {
+		container := d.findContainer(ref)
+		if container == nil {
+			if command == "rm" && len(flags["f"]) > 0 {
+				continue
+			}
+			return d.fail("No such container: %s", ref)
+		}
+
+		switch command {
+		case "start":
+			if !container.Running {
+				container.Running = true
+				container.Started = time.Now()
+			}
+			fmt.Fprintln(d.stdout, ref)
+		case "stop":
+			if container.Running {
+				container.Running = false
+				container.Paused = false
+				container.Stopped = time.Now()
+			}
+			fmt.Fprintln(d.stdout, ref)
+		case "pause", "unpause":
+			if !container.Running {
+				return d.fail("Container %s is not running", container.ID)
+			}
+			if command == "pause" && container.Paused {
+				return d.fail("Container %s is already paused", container.ID)
+			}
+			if command == "unpause" && !container.Paused {
+				return d.fail("Container %s is not paused", container.ID)
+			}
+			container.Paused = command == "pause"
+			fmt.Fprintln(d.stdout, ref)
+		case "rm":
+			if container.Running && len(flags["f"]) == 0 && len(flags["force"]) == 0 {
+				return d.fail("You cannot remove a running container %s. Stop the container before attempting removal or force remove", container.ID)
+			}
+			delete(d.state.Containers, container.Name)
+			fmt.Fprintln(d.stdout, ref)
+		case "update":
+			if restart := lastFlag(flags, "restart"); restart != "" {
+				container.Restart = restart
+			}
+			fmt.Fprintln(d.stdout, ref)
+		case "port":
+			var lines []string
+			for containerPort, hostPort := range container.Ports {
+				lines = append(lines, fmt.Sprintf("%s -> 0.0.0.0:%s", containerPort, hostPort))
+			}
+			sort.Strings(lines)
+			for _, line := range lines {
+				fmt.Fprintln(d.stdout, line)
+			}
+		case "logs":
+			fmt.Fprintf(d.stdout, "fake docker: %s runs %s, which is not actually started\n", container.Name, container.Image)
+		case "exec":
+			if !container.Running {
+				return d.fail("container %s is not running", container.ID)
+			}
+			return d.exec(container, flags, positional[1:])
+		}
+	}
+	return 0
+}
+
+// exec imitates the few in-container tools the CLI relies on
+func (d *fakeDocker) exec(container *fakeContainer, flags map[string][]string, command []string) int {
+	if len(command) == 0 {
+		return d.fail("exec requires a command")
+	}
+
+	switch command[0] {
+	case "pg_isready":
+		fmt.Fprintln(d.stdout, "/var/run/postgresql:5432 - accepting connections")
+		return 0
+
+	case "psql":
+		// there is no database behind the fake engine, so queries return no rows
+		if len(flags["i"]) > 0 && d.stdin != nil {
+			io.Copy(io.Discard, d.stdin)
+		}
+		for ii, arg := range command {
+			if arg == "-c" && ii+1 < len(command) && strings.Contains(command[ii+1], "json_agg") {
+				fmt.Fprintln(d.stdout, "[]")
+			}
+		}
+		if !slices.Contains(command, "-c") && !slices.Contains(command, "-f") {
+			fmt.Fprintln(d.stderr, "fake docker: interactive psql sessions are not available")
+			return 1
+		}
+		return 0
+
+	case "redis-cli":
+		return d.redisCLI(container, command[1:])
+	}
+
+	fmt.Fprintf(d.stderr, "OCI runtime exec failed: fake docker cannot run %q\n", command[0])
+	return 126
+}
+
+// redisCLI answers the redis-cli commands the CLI sends, using the server arguments
+// the container was created with for CONFIG GET
+func (d *fakeDocker) redisCLI(container *fakeContainer, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(d.stderr, "fake docker: interactive redis-cli sessions are not available")
+		return 1
+	}
+
+	config := map[string]string{
+		"appendonly":       "no",
+		"save":             "3600 1 300 100 60 10000",
+		"maxmemory":        "0",
+		"maxmemory-policy": "noeviction",
+	}
+	for ii, arg := range container.Args {
+		if key, ok := strings.CutPrefix(arg, "--"); ok && ii+1 < len(container.Args) {
+			config[key] = container.Args[ii+1]
+		}
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		fmt.Fprintln(d.stdout, "PONG")
+	case "CONFIG":
+		if len(args) == 3 && strings.ToUpper(args[1]) == "GET" {
+			if value, ok := config[args[2]]; ok {
+				fmt.Fprintln(d.stdout, args[2])
+				fmt.Fprintln(d.stdout, value)
+			}
+		}
+	case "MEMORY":
+		fmt.Fprintln(d.stdout, "Hi Sam, this instance is empty or is using very little memory, my issues detector can't be used in these conditions.")
+	case "INFO":
+		fmt.Fprintln(d.stdout, "# Server")
+		fmt.Fprintln(d.stdout, "redis_version:0.0.0-fake")
+	default:
+		fmt.Fprintln(d.stdout, "OK")
+	}
+	return 0
+}
+
+// inspect returns a volume or network in the shape of `docker inspect`
+func (r *fakeResource) inspect(kind string) map[string]any {
+	value := map[string]any{
+		"Name":      r.Name,
+		"Driver":    "local",
+		"Labels":    r.Labels,
+		"Scope":     "local",
+		"CreatedAt": r.Created.Format(time.RFC3339),
+	}
+	if kind == "network" {
+		value["Driver"] = "bridge"
+	} else {
+		value["Mountpoint"] = "/var/lib/docker/volumes/" + r.Name + "/_data"
+	}
+	return value
+}
+
+// resource runs the volume and network subcommands
+func (d *fakeDocker) resource(kind string, resources map[string]*fakeResource, args []string) int {
+	if len(args) == 0 {
+		return d.unsupported([]string{kind})
+	}
+	flags, names := parseFakeArgs(args[1:], []string{"filter", "f", "format", "label", "driver", "d"}, false)
+
+	switch args[0] {
+	case "create":
+		if len(names) != 1 {
+			return d.fail("%s create requires exactly one name", kind)
+		}
+		if _, exists := resources[names[0]]; !exists {
+			resources[names[0]] = &fakeResource{Name: names[0], Labels: parseLabels(flags["label"]), Created: time.Now()}
+		} else if kind == "network" {
+			return d.fail("network with name %s already exists", names[0])
+		}
+		fmt.Fprintln(d.stdout, names[0])
+		return 0
+
+	case "ls", "list":
+		var sorted []string
+		for name := range resources {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+
+		var rows []any
+		for _, name := range sorted {
+			resource := resources[name]
+			if matchesFilters(name, resource.Labels, append(flags["filter"], flags["f"]...)) {
+				rows = append(rows, fakeListRow{ID: name, Name: name, Driver: resource.inspect(kind)["Driver"].(string), labels: resource.Labels})
+			}
+		}
+		format := lastFlag(flags, "format")
+		if format == "" {
+			fmt.Fprintln(d.stdout, "DRIVER\tNAME")
+			format = "{{.Driver}}\t{{.Name}}"
+		}
+		return d.render(format, rows)
+
+	case "inspect":
+		var values []any
+		for _, name := range names {
+			resource, ok := resources[name]
+			if !ok {
+				fmt.Fprintf(d.stderr, "Error response from daemon: get %s: no such %s\n", name, kind)
+				return 1
+			}
+			values = append(values, resource.inspect(kind))
+		}
+		if format := lastFlag(flags, "format", "f"); format != "" {
+			return d.render(format, values)
+		}
+		data, _ := json.MarshalIndent(values, "", "    ")
+		fmt.Fprintln(d.stdout, string(data))
+		return 0
+
+	case "rm", "remove":
+		for _, name := range names {
+			if _, ok := resources[name]; !ok {
+				return d.fail("get %s: no such %s", name, kind)
+			}
+			for _, container := range d.state.Containers {
+				if kind == "network" && container.Network == name {
+					return d.fail("error while removing network: network %s has active endpoints", name)
+				}
+				for _, mount := range container.Mounts {
+					if source, _, _ := strings.Cut(mount, ":"); kind == "volume" && source == name {
+						return d.fail("remove %s: volume is in use - [%s]", name, container.ID)
+					}
+				}
+			}
+			delete(resources, name)
+			fmt.Fprintln(d.stdout, name)
+		}
+		return 0
+	}
+	return d.unsupported(append([]string{kind}, args...))
+}
+
+// image answers `image inspect` for the images of existing containers
+func (d *fakeDocker) image(args []string) int {
+	if len(args) == 0 || args[0] != "inspect" {
+		return d.unsupported(append([]string{"image"}, args...))
+	}
+	flags, refs := parseFakeArgs(args[1:], []string{"format", "f"}, false)
+
+	var values []any
+	for _, ref := range refs {
+		var image string
+		for _, container := range d.state.Containers {
+			if container.Image == ref || fakeImageID(container.Image) == ref {
+				image = container.Image
+			}
+		}
+		if image == "" {
+			fmt.Fprintf(d.stderr, "Error response from daemon: No such image: %s\n", ref)
+			return 1
+		}
+		repository := imageRepository(image)
+		values = append(values, map[string]any{
+			"Id":          fakeImageID(image),
+			"RepoTags":    []string{image},
+			"RepoDigests": []string{repository + "@" + fakeImageID(image)},
+		})
+	}
+
+	if format := lastFlag(flags, "format", "f"); format != "" {
+		return d.render(format, values)
+	}
+	data, _ := json.MarshalIndent(values, "", "    ")
+	fmt.Fprintln(d.stdout, string(data))
+	return 0
+}
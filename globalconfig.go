@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GlobalConfig holds user-level CLI settings shared across all projects.
+// It lives outside of any project, unlike orca.json.
+type GlobalConfig struct {
+	Telemetry   TelemetryConfig   `json:"telemetry"`
+	UpdateCheck UpdateCheckConfig `json:"updateCheck"`
+	Logging     LoggingConfig     `json:"logging"`
+}
+
+type LoggingConfig struct {
+	// Enabled mirrors all CLI output to a rotating log file, as --log-file does
+	Enabled bool `json:"enabled"`
+	// Path of the log file. Defaults to ~/.local/state/orca/logs/orca.log
+	Path string `json:"path,omitempty"`
+}
+
+type UpdateCheckConfig struct {
+	// Disabled turns off the daily check for a newer CLI release
+	Disabled bool `json:"disabled"`
+}
+
+type TelemetryConfig struct {
+	Enabled bool `json:"enabled"`
+	// OTLP/HTTP endpoint to export spooled events to. Falls back to
+	// OTEL_EXPORTER_OTLP_ENDPOINT when empty.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// globalConfigDir returns the directory holding the global CLI configuration
+func globalConfigDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine config directory: %w", err)
+	}
+	return filepath.Join(configDir, "orca"), nil
+}
+
+// stateDir returns the directory for CLI state (spools, logs, caches), following
+// the XDG base directory convention of ~/.local/state/orca
+func stateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "orca"), nil
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("LocalAppData"); dir != "" {
+			return filepath.Join(dir, "orca", "state"), nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "state", "orca"), nil
+}
+
+func globalConfigPath() (string, error) {
+	dir, err := globalConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// loadGlobalConfig reads the global configuration, returning defaults if none exists
+func loadGlobalConfig() (*GlobalConfig, error) {
+	config := &GlobalConfig{}
+
+	path, err := globalConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// saveGlobalConfig writes the global configuration, creating its directory if needed
+func saveGlobalConfig(config *GlobalConfig) error {
+	path, err := globalConfigPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal global config: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// globalConfigKey reads and writes a single global setting addressed by a dotted key
+type globalConfigKey struct {
+	Description string
+	Get         func(config *GlobalConfig) string
+	Set         func(config *GlobalConfig, value string) error
+	// Values are the accepted values, when there is a fixed set, for completion
+	Values []string
+}
+
+// globalConfigKeys are the settings supported by `orca config -global get/set`
+var globalConfigKeys = map[string]globalConfigKey{
+	"telemetry.enabled": boolGlobalConfigKey("Whether CLI usage telemetry is sent",
+		func(config *GlobalConfig) *bool { return &config.Telemetry.Enabled }),
+	"telemetry.endpoint": {
+		Description: "OTLP/HTTP endpoint telemetry is exported to",
+		Get:         func(config *GlobalConfig) string { return config.Telemetry.Endpoint },
+		Set: func(config *GlobalConfig, value string) error {
+			if value != "" {
+				parsed, err := url.Parse(value)
+				if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+					return fmt.Errorf("invalid telemetry.endpoint %q, expected an http or https URL", value)
+				}
+			}
+			config.Telemetry.Endpoint = value
+			return nil
+		},
+	},
+	"updateCheck.disabled": boolGlobalConfigKey("Whether the daily check for a newer CLI release is turned off",
+		func(config *GlobalConfig) *bool { return &config.UpdateCheck.Disabled }),
+	"logging.enabled": boolGlobalConfigKey("Whether all CLI output is mirrored to a log file, as --log-file does",
+		func(config *GlobalConfig) *bool { return &config.Logging.Enabled }),
+	"logging.path": {
+		Description: "Path of the log file, empty for the default",
+		Get:         func(config *GlobalConfig) string { return config.Logging.Path },
+		Set: func(config *GlobalConfig, value string) error {
+			if value != "" && !filepath.IsAbs(value) {
+				return fmt.Errorf("invalid logging.path %q, must be an absolute path", value)
+			}
+			config.Logging.Path = value
+			return nil
+		},
+	},
+}
+
+// boolGlobalConfigKey is the global config key of a true or false setting
+func boolGlobalConfigKey(description string, field func(config *GlobalConfig) *bool) globalConfigKey {
+	return globalConfigKey{
+		Description: description,
+		Get:         func(config *GlobalConfig) string { return strconv.FormatBool(*field(config)) },
+		Set: func(config *GlobalConfig, value string) error {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid value %q, must be true or false", value)
+			}
+			*field(config) = enabled
+			return nil
+		},
+		Values: []string{"true", "false"},
+	}
+}
+
+// globalConfigKeyNames returns the supported global config keys, sorted
+func globalConfigKeyNames() []string {
+	names := make([]string, 0, len(globalConfigKeys))
+	for name := range globalConfigKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupGlobalConfigKey returns the global setting for a dotted key
+func lookupGlobalConfigKey(name string) (globalConfigKey, error) {
+	key, ok := globalConfigKeys[name]
+	if !ok {
+		return globalConfigKey{}, fmt.Errorf(
+			"unknown global config key %q, must be one of: %s",
+			name,
+			strings.Join(globalConfigKeyNames(), ", "),
+		)
+	}
+	return key, nil
+}
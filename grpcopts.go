@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+// defaultGRPCMaxRecvSize is well above gRPC's own 4MB default, which large
+// registries exceed
+const defaultGRPCMaxRecvSize = 64 << 20
+
+// grpcSettings tune the gRPC connections made to the core and processors
+type grpcSettings struct {
+	// Keepalive pings the server this often while a call is in flight, so that long
+	// calls through proxies and load balancers are not dropped as idle. Zero disables it.
+	Keepalive time.Duration
+	// MaxRecvSize bounds the size of a received message, in bytes
+	MaxRecvSize byteSize
+	// Compress asks the core for the registry gzip compressed
+	Compress bool
+}
+
+// grpcDialSettings are used for every connection, set from the flags registered
+// by addGRPCFlags
+var grpcDialSettings = grpcSettings{MaxRecvSize: defaultGRPCMaxRecvSize}
+
+// addGRPCFlags registers the flags tuning gRPC connections on a command
+func addGRPCFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&grpcDialSettings.Keepalive, "keepalive", 0, "Ping the server this often during calls to keep long ones alive through proxies, 0 to disable. The server must permit pings this often")
+	fs.Var(&grpcDialSettings.MaxRecvSize, "max-recv-size", "Largest `size` of message to accept from the core or processors, e.g. 64MB or 1GB")
+	fs.BoolVar(&grpcDialSettings.Compress, "compress-transfer", false, "Fetch the registry gzip compressed, for large registries over slow links. Needs a core with gzip support")
+}
+
+// grpcDialOptions returns the options to dial with, over the given transport
+func grpcDialOptions(creds credentials.TransportCredentials) []grpc.DialOption {
+	options := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(int(grpcDialSettings.MaxRecvSize))),
+	}
+	if grpcDialSettings.Keepalive > 0 {
+		options = append(options, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:    grpcDialSettings.Keepalive,
+			Timeout: 20 * time.Second,
+		}))
+	}
+	if cliProfiler != nil {
+		options = append(options,
+			grpc.WithChainUnaryInterceptor(profileUnaryInterceptor),
+			grpc.WithChainStreamInterceptor(profileStreamInterceptor),
+		)
+	}
+	return options
+}
+
+// coreTransportCredentials returns the transport to reach a core over: TLS verified
+// against the CA certificate in caCert when given, TLS with the system's CAs when
+// secure is set, and otherwise plain text as for the local stack
+func coreTransportCredentials(secure bool, caCert string) (credentials.TransportCredentials, error) {
+	if caCert != "" {
+		pemServerCA, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(pemServerCA) {
+			return nil, fmt.Errorf("failed to add CA certificate to pool (invalid PEM format?)")
+		}
+		fmt.Fprintln(os.Stderr, "Using custom CA certificate for TLS...")
+		return credentials.NewTLS(&tls.Config{RootCAs: certPool}), nil
+	}
+	if secure {
+		fmt.Fprintln(os.Stderr, "Using system default CA for TLS...")
+		return credentials.NewTLS(&tls.Config{}), nil
+	}
+	return insecure.NewCredentials(), nil
+}
+
+// dialInsecure prepares a client connection without TLS, as for the local stack
+func dialInsecure(address string) (*grpc.ClientConn, error) {
+	return grpc.NewClient(address, grpcDialOptions(insecure.NewCredentials())...)
+}
+
+// gzipUnsupported is set once a core rejects a compressed request, so that later
+// fetches go uncompressed straight away
+var gzipUnsupported atomic.Bool
+
+// exposeCompressed fetches the registry, with gzip compressing the transfer when
+// -compress-transfer is given. Cores without gzip support, such as v0.12.0, reject
+// compressed requests, so the fetch is then repeated uncompressed and compression
+// is not tried again.
+func exposeCompressed(ctx context.Context, client pb.OrcaCoreClient, settings *pb.ExposeSettings) (*pb.InternalState, error) {
+	if !grpcDialSettings.Compress || gzipUnsupported.Load() {
+		return client.Expose(ctx, settings)
+	}
+	state, err := client.Expose(ctx, settings, grpc.UseCompressor(gzip.Name))
+	if status.Code(err) == codes.Unimplemented && strings.Contains(err.Error(), "Decompressor is not installed") {
+		logDebug("the core does not support gzip, fetching the registry uncompressed")
+		gzipUnsupported.Store(true)
+		return client.Expose(ctx, settings)
+	}
+	return state, err
+}
+
+// isMessageTooLarge reports whether a call failed on a message above MaxRecvSize
+func isMessageTooLarge(err error) bool {
+	return status.Code(err) == codes.ResourceExhausted && strings.Contains(err.Error(), "larger than max")
+}
+
+// byteSize is a size in bytes, given on the command line with an optional unit.
+// Units are binary, so 1MB is 1048576 bytes.
+type byteSize int64
+
+var byteSizeUnits = []struct {
+	Suffix string
+	Bytes  int64
+}{
+	{"gib", 1 << 30}, {"gb", 1 << 30}, {"g", 1 << 30},
+	{"mib", 1 << 20}, {"mb", 1 << 20}, {"m", 1 << 20},
+	{"kib", 1 << 10}, {"kb", 1 << 10}, {"k", 1 << 10},
+	{"b", 1},
+}
+
+func (b *byteSize) String() string {
+	size := int64(*b)
+	for _, unit := range []struct {
+		Suffix string
+		Bytes  int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}} {
+		if size >= unit.Bytes && size%unit.Bytes == 0 {
+			return strconv.FormatInt(size/unit.Bytes, 10) + unit.Suffix
+		}
+	}
+	return strconv.FormatInt(size, 10)
+}
+
+func (b *byteSize) Set(value string) error {
+	size, err := parseByteSize(value)
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
+
+// parseByteSize parses a size such as 512, 64MB or 1GiB
+func parseByteSize(value string) (byteSize, error) {
+	text := strings.ToLower(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(text, unit.Suffix) {
+			text = strings.TrimSpace(strings.TrimSuffix(text, unit.Suffix))
+			multiplier = unit.Bytes
+			break
+		}
+	}
+	number, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || number <= 0 || number > (1<<31-1)/multiplier {
+		return 0, fmt.Errorf("invalid size %q, expected e.g. 64MB, less than 2GB", value)
+	}
+	return byteSize(number * multiplier), nil
+}
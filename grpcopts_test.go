@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for value, want := range map[string]byteSize{
+		"512":    512,
+		"64MB":   64 << 20,
+		"64mb":   64 << 20,
+		"1GiB":   1 << 30,
+		"16 KB":  16 << 10,
+		"100b":   100,
+		"1023MB": 1023 << 20,
+	} {
+		got, err := parseByteSize(value)
+		if err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "MB", "-1MB", "2GB", "1.5MB", "64XB"} {
+		if got, err := parseByteSize(value); err == nil {
+			t.Errorf("parseByteSize(%q) = %d, want an error", value, got)
+		}
+	}
+
+	size := byteSize(defaultGRPCMaxRecvSize)
+	if size.String() != "64MB" {
+		t.Errorf("String() = %q, want 64MB", size.String())
+	}
+}
+
+func TestExposeCompressedFallsBackOnce(t *testing.T) {
+	settings := grpcDialSettings
+	t.Cleanup(func() {
+		grpcDialSettings = settings
+		gzipUnsupported.Store(false)
+	})
+	grpcDialSettings.Compress = true
+
+	// the first call is rejected as a core without gzip support rejects it
+	var calls int
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		calls++
+		if calls == 1 {
+			return nil, status.Error(codes.Unimplemented, `grpc: Decompressor is not installed for grpc-encoding "gzip"`)
+		}
+		return handler(ctx, req)
+	}))
+	pb.RegisterOrcaCoreServer(server, &fakeCore{})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := dialInsecure(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewOrcaCoreClient(conn)
+	for range 2 {
+		if _, err := exposeCompressed(context.Background(), client, &pb.ExposeSettings{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("core was called %d times, want compression tried only on the first fetch", calls)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+// health check exit codes, following the Nagios plugin convention
+const (
+	healthExitHealthy  = 0
+	healthExitDegraded = 1
+	healthExitDown     = 2
+)
+
+// healthCheckConcurrency bounds how many health probes run at once
+const healthCheckConcurrency = 4
+
+type healthResult struct {
+	Name    string
+	Err     error
+	Elapsed time.Duration
+}
+
+type healthCheck struct {
+	Name  string
+	Probe func(ctx context.Context) error
+}
+
+var healthChecks = []healthCheck{
+	{Name: "postgres", Probe: probePostgres},
+	{Name: "redis", Probe: probeRedis},
+	{Name: "orca", Probe: probeOrca},
+}
+
+// probePostgres checks that the Postgres store is accepting connections
+func probePostgres(ctx context.Context) error {
+	ready, err := checkPostgresReady(ctx, pgContainerName)
+	if err != nil {
+		return err
+	}
+	if !ready {
+		return fmt.Errorf("not accepting connections")
+	}
+	return nil
+}
+
+// probeRedis checks that the Redis cache responds to PING
+func probeRedis(ctx context.Context) error {
+	cmd := dockerCommandContext(ctx, "exec", redisContainerName, "redis-cli", "ping")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("redis-cli ping failed: %w", err)
+	}
+	if strings.TrimSpace(string(output)) != "PONG" {
+		return fmt.Errorf("unexpected ping response: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// probeOrca checks that Orca core answers gRPC requests on its published port
+func probeOrca(ctx context.Context) error {
+	return probeCore(ctx, orcaContainerName)
+}
+
+// probeCore checks that the core of a container answers gRPC requests
+func probeCore(ctx context.Context, containerName string) error {
+	if status := getContainerStatus(containerName); status != "running" {
+		return fmt.Errorf("container %s", status)
+	}
+
+	orcaPort := getContainerPort(containerName, orcaInternalPort)
+	conn, err := dialInsecure(fmt.Sprintf("localhost:%s", orcaPort))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = pb.NewOrcaCoreClient(conn).Expose(ctx, &pb.ExposeSettings{})
+	return err
+}
+
+// runHealthChecks runs the health probes concurrently, each bounded by the given
+// timeout, so that they take as long as the slowest rather than the sum. Results are
+// in the order of healthChecks.
+func runHealthChecks(timeout time.Duration) []healthResult {
+	results := make([]healthResult, len(healthChecks))
+	slots := make(chan struct{}, healthCheckConcurrency)
+	var wg sync.WaitGroup
+	for ii, check := range healthChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			started := time.Now()
+			err := check.Probe(ctx)
+			if err == nil && ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			results[ii] = healthResult{Name: check.Name, Err: err, Elapsed: time.Since(started)}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// healthExitCode maps probe results to an exit code: healthy when every probe
+// passes, down when every probe fails, and degraded otherwise
+func healthExitCode(results []healthResult) int {
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+
+	switch failed {
+	case 0:
+		return healthExitHealthy
+	case len(results):
+		return healthExitDown
+	default:
+		return healthExitDegraded
+	}
+}
+
+// formatHealthLine renders the results as a single Nagios-style line,
+// e.g. "ORCA DEGRADED - postgres=up redis=down orca=up"
+func formatHealthLine(results []healthResult) string {
+	var label string
+	switch healthExitCode(results) {
+	case healthExitHealthy:
+		label = "OK"
+	case healthExitDegraded:
+		label = "DEGRADED"
+	default:
+		label = "DOWN"
+	}
+
+	parts := make([]string, len(results))
+	for ii, result := range results {
+		state := "up"
+		if result.Err != nil {
+			state = "down"
+		}
+		parts[ii] = fmt.Sprintf("%s=%s", result.Name, state)
+	}
+
+	return fmt.Sprintf("ORCA %s - %s", label, strings.Join(parts, " "))
+}
+
+// componentHealth is the result of a health probe, as reported by `orca status -deep -o`
+type componentHealth struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+	// ElapsedMs is how long the probe took, in milliseconds
+	ElapsedMs int64 `json:"elapsedMs"`
+}
+
+// componentHealthOf converts probe results for reporting
+func componentHealthOf(results []healthResult) []componentHealth {
+	health := make([]componentHealth, len(results))
+	for ii, result := range results {
+		health[ii] = componentHealth{Name: result.Name, Healthy: result.Err == nil, ElapsedMs: result.Elapsed.Milliseconds()}
+		if result.Err != nil {
+			health[ii].Error = result.Err.Error()
+		}
+	}
+	return health
+}
+
+// showHealth prints the probe results as a table
+func showHealth(w io.Writer, health []componentHealth) {
+	rows := [][]string{{"CHECK", "STATE", "TIME", "DETAIL"}}
+	for _, check := range health {
+		state := renderStdout(successStyle, "up")
+		if !check.Healthy {
+			state = renderStdout(errorStyle, "down")
+		}
+		elapsed := (time.Duration(check.ElapsedMs) * time.Millisecond).String()
+		rows = append(rows, []string{check.Name, state, elapsed, check.Error})
+	}
+	printTable(w, rows)
+}
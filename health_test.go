@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunHealthChecksConcurrently(t *testing.T) {
+	previous := healthChecks
+	t.Cleanup(func() { healthChecks = previous })
+	sleep := func(d time.Duration, err error) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			select {
+			case <-time.After(d):
+				return err
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	healthChecks = []healthCheck{
+		{Name: "postgres", Probe: sleep(200*time.Millisecond, nil)},
+		{Name: "redis", Probe: sleep(200*time.Millisecond, errors.New("refused"))},
+		{Name: "orca", Probe: sleep(time.Minute, nil)},
+	}
+
+	started := time.Now()
+	results := runHealthChecks(300 * time.Millisecond)
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("health checks took %s, want the time of the slowest probe rather than the sum", elapsed)
+	}
+
+	var names []string
+	for _, result := range results {
+		names = append(names, result.Name)
+	}
+	if len(names) != 3 || names[0] != "postgres" || names[1] != "redis" || names[2] != "orca" {
+		t.Fatalf("results are in order %v, want that of healthChecks", names)
+	}
+	if results[0].Err != nil || results[1].Err == nil || !errors.Is(results[2].Err, context.DeadlineExceeded) {
+		t.Errorf("results = %+v, want postgres up, redis down and orca timed out", results)
+	}
+	if code := healthExitCode(results); code != healthExitDegraded {
+		t.Errorf("healthExitCode = %d, want degraded", code)
+	}
+}
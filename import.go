@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// importOrigin is the origin of windows pushed by `orca import`, unless one is given
+const importOrigin = "orca-import"
+
+// importMapping maps the columns of an imported file onto windows. Windows carry no
+// payload besides their metadata, so every other value a row provides is metadata.
+type importMapping struct {
+	// TimeFrom and TimeTo name the columns holding when each window starts and ends
+	TimeFrom string `json:"timeFrom"`
+	TimeTo   string `json:"timeTo"`
+	// TimeFormat is a Go time layout, or unix / unixms for epoch timestamps. RFC3339
+	// and "2006-01-02 15:04:05" are accepted when it is empty.
+	TimeFormat string `json:"timeFormat,omitempty"`
+	// Origin names a column holding the origin of each window
+	Origin string `json:"origin,omitempty"`
+	// WindowType names a column holding the window type of each window, as
+	// name[@version], for rows imported without -window
+	WindowType string `json:"windowType,omitempty"`
+	// Metadata maps metadata fields to the columns holding them. When it is unset
+	// every other column is a metadata field of the same name.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// defaultImportMapping is used when no mapping file is given
+var defaultImportMapping = importMapping{TimeFrom: "time_from", TimeTo: "time_to", Origin: "origin", WindowType: "window_type"}
+
+// readImportMapping reads a mapping file, filling in the default time columns
+func readImportMapping(path string) (importMapping, error) {
+	mapping := defaultImportMapping
+	if path == "" {
+		return mapping, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return mapping, fmt.Errorf("failed to read mapping: %w", err)
+	}
+	mapping.Origin = ""
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return mapping, fmt.Errorf("failed to parse mapping %s: %w", path, err)
+	}
+	return mapping, nil
+}
+
+// errMalformedRow is returned by record readers for a row they could not parse. The
+// rows after it can still be read.
+var errMalformedRow = errors.New("malformed row")
+
+// importRecord is a row of an imported file
+type importRecord struct {
+	// Row counts the rows of the file from 1
+	Row    int
+	Values map[string]any
+	// Untyped rows hold only strings, as read from CSV. Rows read from JSON keep
+	// their JSON types.
+	Untyped bool
+}
+
+// recordReader reads the rows of an imported file one at a time, returning io.EOF
+// after the last
+type recordReader interface {
+	Next() (importRecord, error)
+}
+
+// csvRecordReader reads rows of a CSV file with a header line
+type csvRecordReader struct {
+	reader *csv.Reader
+	header []string
+	row    int
+}
+
+func newCSVRecordReader(r io.Reader) (*csvRecordReader, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the file is empty, expected a header line")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the header line: %w", err)
+	}
+	for ii := range header {
+		header[ii] = strings.TrimSpace(header[ii])
+	}
+	return &csvRecordReader{reader: reader, header: header}, nil
+}
+
+func (c *csvRecordReader) Next() (importRecord, error) {
+	fields, err := c.reader.Read()
+	if err != nil {
+		return importRecord{}, err
+	}
+	c.row++
+	values := make(map[string]any, len(fields))
+	for ii, field := range fields {
+		values[c.header[ii]] = field
+	}
+	return importRecord{Row: c.row, Values: values, Untyped: true}, nil
+}
+
+// jsonRecordReader reads the objects of a JSON array one at a time, so that large
+// exports need not fit in memory
+type jsonRecordReader struct {
+	decoder *json.Decoder
+	row     int
+}
+
+func newJSONRecordReader(r io.Reader) (*jsonRecordReader, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("expected a JSON array of objects: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, fmt.Errorf("expected a JSON array of objects")
+	}
+	return &jsonRecordReader{decoder: decoder}, nil
+}
+
+func (j *jsonRecordReader) Next() (importRecord, error) {
+	if !j.decoder.More() {
+		return importRecord{}, io.EOF
+	}
+	j.row++
+	var values map[string]any
+	if err := j.decoder.Decode(&values); err != nil {
+		return importRecord{}, fmt.Errorf("row %d: expected an object: %w", j.row, err)
+	}
+	return importRecord{Row: j.row, Values: values}, nil
+}
+
+// ndjsonRecordReader reads line delimited JSON objects as they arrive, e.g. from a
+// pipe. Blank lines are skipped.
+type ndjsonRecordReader struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func newNDJSONRecordReader(r io.Reader) *ndjsonRecordReader {
+	return &ndjsonRecordReader{reader: bufio.NewReader(r)}
+}
+
+func (n *ndjsonRecordReader) Next() (importRecord, error) {
+	for {
+		line, err := n.reader.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return importRecord{}, err
+		}
+		n.line++
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+		var values map[string]any
+		if err := decoder.Decode(&values); err != nil {
+			return importRecord{}, fmt.Errorf("line %d: %w, expected a JSON object: %v", n.line, errMalformedRow, err)
+		}
+		return importRecord{Row: n.line, Values: values}, nil
+	}
+}
+
+// openRecordReader picks the reader for a file by its extension
+func openRecordReader(path string, r io.Reader) (recordReader, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return newCSVRecordReader(r)
+	case ".json":
+		return newJSONRecordReader(r)
+	}
+	return nil, fmt.Errorf("cannot import %s, expected a .csv or .json file", filepath.Base(path))
+}
+
+// parseImportTime parses a time value of a row in the mapping's format
+func parseImportTime(value any, format string) (time.Time, error) {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = strings.TrimSpace(v)
+	case json.Number:
+		text = v.String()
+	case nil:
+		return time.Time{}, fmt.Errorf("no value")
+	default:
+		return time.Time{}, fmt.Errorf("unsupported value %v", v)
+	}
+
+	switch format {
+	case "unix", "unixms":
+		epoch, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid epoch %q", text)
+		}
+		if format == "unixms" {
+			return time.UnixMilli(int64(epoch)).UTC(), nil
+		}
+		return time.UnixMilli(int64(epoch * 1000)).UTC(), nil
+	case "":
+		for _, layout := range []string{time.RFC3339Nano, time.DateTime, storeTimestampLayout} {
+			if t, err := time.Parse(layout, text); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid time %q, set timeFormat in the mapping", text)
+	}
+	t, err := time.Parse(format, text)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q for format %q", text, format)
+	}
+	return t, nil
+}
+
+// importMetadataValue converts a row value into a metadata value. Values of untyped
+// rows that parse as JSON keep their type, as with `-meta`.
+func importMetadataValue(value any, untyped bool) any {
+	switch v := value.(type) {
+	case string:
+		var parsed any
+		if untyped && json.Unmarshal([]byte(v), &parsed) == nil {
+			return parsed
+		}
+		return v
+	case json.Number:
+		if number, err := v.Float64(); err == nil {
+			return number
+		}
+		return v.String()
+	case map[string]any:
+		converted := make(map[string]any, len(v))
+		for key, inner := range v {
+			converted[key] = importMetadataValue(inner, untyped)
+		}
+		return converted
+	case []any:
+		converted := make([]any, len(v))
+		for ii, inner := range v {
+			converted[ii] = importMetadataValue(inner, untyped)
+		}
+		return converted
+	}
+	return value
+}
+
+// buildImportWindow maps a row onto a window of the window type
+func buildImportWindow(record importRecord, mapping importMapping, windowType *pb.WindowType, origin string) (*pb.Window, error) {
+	from, err := parseImportTime(record.Values[mapping.TimeFrom], mapping.TimeFormat)
+	if err != nil {
+		return nil, fmt.Errorf("row %d: column %s: %w", record.Row, mapping.TimeFrom, err)
+	}
+	to, err := parseImportTime(record.Values[mapping.TimeTo], mapping.TimeFormat)
+	if err != nil {
+		return nil, fmt.Errorf("row %d: column %s: %w", record.Row, mapping.TimeTo, err)
+	}
+	if !to.After(from) {
+		return nil, fmt.Errorf("row %d: the window must end after it starts", record.Row)
+	}
+
+	window := &pb.Window{
+		TimeFrom:          timestamppb.New(from),
+		TimeTo:            timestamppb.New(to),
+		WindowTypeName:    windowType.GetName(),
+		WindowTypeVersion: windowType.GetVersion(),
+		Origin:            origin,
+	}
+	if value, ok := record.Values[mapping.Origin].(string); ok && mapping.Origin != "" && value != "" {
+		window.Origin = value
+	}
+
+	metadata := map[string]any{}
+	if mapping.Metadata == nil {
+		for column, value := range record.Values {
+			if column != mapping.TimeFrom && column != mapping.TimeTo && column != mapping.Origin && column != mapping.WindowType {
+				metadata[column] = importMetadataValue(value, record.Untyped)
+			}
+		}
+	} else {
+		for field, column := range mapping.Metadata {
+			value, ok := record.Values[column]
+			if !ok {
+				return nil, fmt.Errorf("row %d: no column %s for metadata %s", record.Row, column, field)
+			}
+			metadata[field] = importMetadataValue(value, record.Untyped)
+		}
+	}
+	if len(metadata) > 0 {
+		if window.Metadata, err = structpb.NewStruct(metadata); err != nil {
+			return nil, fmt.Errorf("row %d: invalid metadata: %w", record.Row, err)
+		}
+	}
+	return window, nil
+}
+
+// windowImport pushes the rows of a file into the core as windows
+type windowImport struct {
+	// WindowType is the window type of every row. When nil, each row names its own,
+	// which is looked up in State.
+	WindowType *pb.WindowType
+	State      *pb.InternalState
+	Mapping    importMapping
+	Origin     string
+	// KeepGoing carries on past rows that fail, reporting them at the end
+	KeepGoing bool
+	DryRun    bool
+	Timeout   time.Duration
+
+	windowTypes map[string]*pb.WindowType
+	// warned holds the window types already warned about missing metadata
+	warned map[string]bool
+}
+
+// importResult counts the rows an import went through
+type importResult struct {
+	Imported    int
+	Untriggered int
+	Failed      int
+}
+
+// run reads every row and emits its window, stopping at the first failure unless
+// KeepGoing is set. client may be nil for a dry run.
+func (i *windowImport) run(client pb.OrcaCoreClient, records recordReader) (importResult, error) {
+	var result importResult
+	for {
+		record, err := records.Next()
+		if err == io.EOF {
+			break
+		}
+		var window *pb.Window
+		if err == nil {
+			window, err = i.buildWindow(record)
+		} else if !errors.Is(err, errMalformedRow) {
+			return result, err
+		}
+		if err == nil && !i.DryRun {
+			err = i.emit(client, window, record.Row, &result)
+		}
+		if err != nil {
+			result.Failed++
+			if !i.KeepGoing {
+				return result, err
+			}
+			fmt.Fprintln(os.Stderr, renderError(err.Error()))
+			continue
+		}
+		result.Imported++
+		if result.Imported%100 == 0 {
+			fmt.Fprintf(os.Stderr, "  %d window(s) imported...\n", result.Imported)
+		}
+	}
+	if result.Failed > 0 {
+		return result, fmt.Errorf("%d row(s) failed", result.Failed)
+	}
+	return result, nil
+}
+
+// rowWindowType returns the window type of a row
+func (i *windowImport) rowWindowType(record importRecord) (*pb.WindowType, error) {
+	if i.WindowType != nil {
+		return i.WindowType, nil
+	}
+	spec, _ := record.Values[i.Mapping.WindowType].(string)
+	if spec == "" {
+		return nil, fmt.Errorf("row %d: no window type in column %s, name one with -window", record.Row, i.Mapping.WindowType)
+	}
+	if windowType, ok := i.windowTypes[spec]; ok {
+		return windowType, nil
+	}
+	windowType, err := findWindowType(i.State, spec)
+	if err != nil {
+		return nil, fmt.Errorf("row %d: %w", record.Row, err)
+	}
+	if i.windowTypes == nil {
+		i.windowTypes = map[string]*pb.WindowType{}
+	}
+	i.windowTypes[spec] = windowType
+	return windowType, nil
+}
+
+// buildWindow builds the window of a row, warning once per window type about
+// metadata fields the rows do not set
+func (i *windowImport) buildWindow(record importRecord) (*pb.Window, error) {
+	windowType, err := i.rowWindowType(record)
+	if err != nil {
+		return nil, err
+	}
+	window, err := buildImportWindow(record, i.Mapping, windowType, i.Origin)
+	key := windowType.GetName() + "@" + windowType.GetVersion()
+	if err != nil || i.warned[key] {
+		return window, err
+	}
+	if i.warned == nil {
+		i.warned = map[string]bool{}
+	}
+	i.warned[key] = true
+	if missing := missingMetadataFields(windowType, window.GetMetadata().AsMap()); len(missing) > 0 {
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf(
+			"Window type %s %s carries metadata the rows do not set: %s. Map it in the mapping file",
+			windowType.GetName(), windowType.GetVersion(), strings.Join(missing, ", "),
+		)))
+	}
+	return window, nil
+}
+
+// emit pushes a window to the core
+func (i *windowImport) emit(client pb.OrcaCoreClient, window *pb.Window, row int, result *importResult) error {
+	ctx, cancel := context.WithTimeout(context.Background(), i.Timeout)
+	defer cancel()
+	status, err := client.EmitWindow(ctx, window)
+	if err != nil {
+		return fmt.Errorf("row %d: the core rejected the window: %w", row, err)
+	}
+	switch status.GetStatus() {
+	case pb.WindowEmitStatus_TRIGGERING_FAILED:
+		return fmt.Errorf("row %d: the core failed to trigger processing", row)
+	case pb.WindowEmitStatus_NO_TRIGGERED_ALGORITHMS:
+		result.Untriggered++
+	}
+	return nil
+}
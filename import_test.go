@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+func TestBuildImportWindow(t *testing.T) {
+	windowType := &pb.WindowType{Name: "FastWindow", Version: "1.0.0"}
+	csvRows := "start,end,asset,reading,note\n1735725600,1735725630,pump-1,4.5,\"a, b\"\n"
+	records, err := openRecordReader("data.csv", strings.NewReader(csvRows))
+	if err != nil {
+		t.Fatalf("openRecordReader: %v", err)
+	}
+	record, err := records.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if _, err := records.Next(); err != io.EOF {
+		t.Fatalf("Next after the last row = %v, want io.EOF", err)
+	}
+
+	mapping := importMapping{TimeFrom: "start", TimeTo: "end", TimeFormat: "unix", Metadata: map[string]string{"asset_id": "asset", "value": "reading"}}
+	window, err := buildImportWindow(record, mapping, windowType, importOrigin)
+	if err != nil {
+		t.Fatalf("buildImportWindow: %v", err)
+	}
+	if want := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC); !window.GetTimeFrom().AsTime().Equal(want) {
+		t.Errorf("time from = %s, want %s", window.GetTimeFrom().AsTime(), want)
+	}
+	metadata := window.GetMetadata().AsMap()
+	if len(metadata) != 2 || metadata["asset_id"] != "pump-1" || metadata["value"] != 4.5 {
+		t.Errorf("metadata = %v", metadata)
+	}
+	if window.GetOrigin() != importOrigin || window.GetWindowTypeVersion() != "1.0.0" {
+		t.Errorf("window = %v", window)
+	}
+
+	jsonRows := `[{"time_from": "2025-01-01T10:00:00Z", "time_to": "2025-01-01T10:00:30Z", "origin": "plant", "asset_id": "7"}]`
+	records, err = openRecordReader("data.json", strings.NewReader(jsonRows))
+	if err != nil {
+		t.Fatalf("openRecordReader: %v", err)
+	}
+	if record, err = records.Next(); err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	window, err = buildImportWindow(record, defaultImportMapping, windowType, importOrigin)
+	if err != nil {
+		t.Fatalf("buildImportWindow: %v", err)
+	}
+	// strings of JSON rows stay strings, and origin is not metadata
+	if metadata := window.GetMetadata().AsMap(); len(metadata) != 1 || metadata["asset_id"] != "7" || window.GetOrigin() != "plant" {
+		t.Errorf("window = %v", window)
+	}
+
+	record.Values["time_to"] = "2025-01-01T09:00:00Z"
+	if _, err := buildImportWindow(record, defaultImportMapping, windowType, importOrigin); err == nil {
+		t.Errorf("buildImportWindow accepted a window ending before it starts")
+	}
+
+	ndjson := "{\"time_from\": \"2025-01-01T10:00:00Z\", \"time_to\": \"2025-01-01T10:00:30Z\"}\n\nnot json\n{}"
+	records = newNDJSONRecordReader(strings.NewReader(ndjson))
+	if record, err = records.Next(); err != nil || record.Row != 1 {
+		t.Fatalf("Next = %+v, %v", record, err)
+	}
+	if _, err = records.Next(); !errors.Is(err, errMalformedRow) {
+		t.Fatalf("Next on a malformed line = %v, want errMalformedRow", err)
+	}
+	if record, err = records.Next(); err != nil || record.Row != 4 {
+		t.Fatalf("Next after a malformed line = %+v, %v", record, err)
+	}
+	if _, err = records.Next(); err != io.EOF {
+		t.Fatalf("Next after the last line = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// every container, volume and network the CLI creates carries these labels, so
+// stack resources can be discovered without relying on their names
+const (
+	managedLabel   = "orca.managed"
+	projectLabel   = "orca.project"
+	componentLabel = "orca.component"
+)
+
+// components of the core stack, as recorded in the component label
+const (
+	componentPostgres = "postgres"
+	componentRedis    = "redis"
+	componentCore     = "core"
+	componentNetwork  = "network"
+)
+
+// projectLabelValue names a stack project in labels, where the default project is "default"
+func projectLabelValue(project string) string {
+	if project == "" {
+		return "default"
+	}
+	return project
+}
+
+// labelArgs returns the `--label` arguments for a resource of the current stack project
+func labelArgs(component string) []string {
+	return labelArgsFor(stackProject, component)
+}
+
+// labelArgsFor returns the `--label` arguments for a resource of the given stack project
+func labelArgsFor(project, component string) []string {
+	return []string{
+		"--label", managedLabel + "=true",
+		"--label", projectLabel + "=" + projectLabelValue(project),
+		"--label", componentLabel + "=" + component,
+	}
+}
+
+// volumeComponent returns the component a data volume of the current stack belongs to
+func volumeComponent(volumeName string) string {
+	if volumeName == pgContainerName+"-data" {
+		return componentPostgres
+	}
+	return componentRedis
+}
+
+// listManaged returns the names of labelled resources of the current stack project.
+// kind is one of container, volume or network.
+func listManaged(kind string) ([]string, error) {
+	return listResources(kind,
+		"label="+managedLabel+"=true",
+		"label="+projectLabel+"="+projectLabelValue(stackProject),
+	)
+}
+
+// listResources returns the names of the resources of a kind that match every
+// `--filter`, or of all of them without filters
+func listResources(kind string, filters ...string) ([]string, error) {
+	format := "{{.Name}}"
+	args := []string{kind, "ls"}
+	if kind == "container" {
+		// containers are listed with ps, which also includes stopped ones with -a
+		args = []string{"ps", "-a"}
+		format = "{{.Names}}"
+	}
+	for _, filter := range filters {
+		args = append(args, "--filter", filter)
+	}
+	args = append(args, "--format", format)
+
+	output, err := dockerCommand(args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %ss: %w", kind, err)
+	}
+
+	var names []string
+	for _, name := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// withManaged returns the labelled resources of the given kind, followed by those
+// of legacy that exist without labels. Resources created before labelling was
+// introduced are only known by name, so legacy names are kept as a fallback, but
+// only for resources docker actually lists. When docker cannot list resources,
+// legacy is returned as it is.
+func withManaged(kind string, legacy []string) []string {
+	managed, err := listManaged(kind)
+	if err != nil {
+		return legacy
+	}
+	existing, err := listResources(kind)
+	if err != nil {
+		existing = legacy
+	}
+	result := slices.Clone(managed)
+	for _, name := range legacy {
+		if slices.Contains(existing, name) && !slices.Contains(result, name) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
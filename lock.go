@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	lockFileName    = "orca.lock"
+	lockFileVersion = 1
+)
+
+// LockFile records the exact images a stack was started with, so that
+// `orca start --locked` can recreate an identical stack
+type LockFile struct {
+	Version int `json:"version"`
+	// Images maps each component, or companion service name, to its locked image
+	Images map[string]LockedImage `json:"images"`
+}
+
+type LockedImage struct {
+	// Image is the reference the component is configured with, e.g. postgres
+	Image string `json:"image"`
+	// Digest is the content-addressed reference, e.g. postgres@sha256:...
+	Digest string `json:"digest"`
+}
+
+// lockFilePath returns the path of the lockfile that sits next to orca.json
+func lockFilePath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), lockFileName)
+}
+
+func readLockFile(path string) (*LockFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s not found. Run `orca start` without --locked to create it", path)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var lock LockFile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if lock.Version != lockFileVersion {
+		return nil, fmt.Errorf("unsupported %s version %d", path, lock.Version)
+	}
+	return &lock, nil
+}
+
+func writeLockFile(path string, lock *LockFile) error {
+	data, err := json.MarshalIndent(lock, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize lockfile: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// imageRepository strips the tag or digest from an image reference
+func imageRepository(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if slash, colon := strings.LastIndex(image, "/"), strings.LastIndex(image, ":"); colon > slash {
+		image = image[:colon]
+	}
+	return image
+}
+
+// containerImageDigests returns the repository digests of the image a container runs
+func containerImageDigests(containerName string) ([]string, error) {
+	imageID, err := dockerCommand("inspect", "--format", "{{.Image}}", containerName).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s: %w", containerName, err)
+	}
+
+	output, err := dockerCommand(
+		"image", "inspect", "--format", "{{json .RepoDigests}}", strings.TrimSpace(string(imageID)),
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect the image of %s: %w", containerName, err)
+	}
+
+	var digests []string
+	if err := json.Unmarshal(output, &digests); err != nil {
+		return nil, fmt.Errorf("failed to parse image digests of %s: %w", containerName, err)
+	}
+	return digests, nil
+}
+
+// lockedDigest returns the digest of a running container's image that belongs to
+// the configured image's repository
+func lockedDigest(containerName, image string) (string, error) {
+	digests, err := containerImageDigests(containerName)
+	if err != nil {
+		return "", err
+	}
+	repository := imageRepository(image)
+	for _, digest := range digests {
+		if imageRepository(digest) == repository || strings.HasSuffix(imageRepository(digest), "/"+repository) {
+			return repository + "@" + digest[strings.Index(digest, "@")+1:], nil
+		}
+	}
+	return "", fmt.Errorf("no registry digest found for %s, was its image built locally?", containerName)
+}
+
+// buildLockFile captures the image digests of the core stack and the given
+// companions, keeping any companions locked previously that were not started
+func buildLockFile(companions []companionService, previous *LockFile) (*LockFile, error) {
+	lock := &LockFile{Version: lockFileVersion, Images: map[string]LockedImage{}}
+
+	for _, component := range restartComponents {
+		image := stackImages[component]
+		digest, err := lockedDigest(restartComponentContainer(component), image)
+		if err != nil {
+			return nil, err
+		}
+		lock.Images[component] = LockedImage{Image: image, Digest: digest}
+	}
+	for _, svc := range companions {
+		digest, err := lockedDigest(svc.ContainerName, svc.Image)
+		if err != nil {
+			return nil, err
+		}
+		lock.Images[svc.Name] = LockedImage{Image: svc.Image, Digest: digest}
+	}
+
+	if previous != nil {
+		for name, image := range previous.Images {
+			if _, ok := lock.Images[name]; !ok {
+				lock.Images[name] = image
+			}
+		}
+	}
+	return lock, nil
+}
+
+// applyLockFile switches the stack and companion images to the locked digests.
+// It fails when a companion to be started has no locked image.
+func applyLockFile(lock *LockFile, companions []companionService) ([]companionService, error) {
+	for _, component := range restartComponents {
+		locked, ok := lock.Images[component]
+		if !ok {
+			return nil, fmt.Errorf("%s has no locked image for %s", lockFileName, component)
+		}
+		stackImages[component] = locked.Digest
+	}
+
+	locked := slices.Clone(companions)
+	var missing []string
+	for ii, svc := range locked {
+		image, ok := lock.Images[svc.Name]
+		if !ok {
+			missing = append(missing, svc.Name)
+			continue
+		}
+		locked[ii].Image = image.Digest
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf(
+			"%s has no locked image for %s. Start without --locked to update it",
+			lockFileName,
+			strings.Join(missing, ", "),
+		)
+	}
+	return locked, nil
+}
+
+// warnOnImageDrift warns when existing containers run images other than the
+// locked ones, since images only apply when a container is created
+func warnOnImageDrift(lock *LockFile) {
+	for _, component := range restartComponents {
+		containerName := restartComponentContainer(component)
+		if getContainerStatus(containerName) == "not found" {
+			continue
+		}
+
+		digests, err := containerImageDigests(containerName)
+		if err != nil {
+			continue
+		}
+		_, want, _ := strings.Cut(lock.Images[component].Digest, "@")
+		if !slices.ContainsFunc(digests, func(digest string) bool { return strings.HasSuffix(digest, "@"+want) }) {
+			fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf(
+				"%s runs a different image than %s. Images only apply when the container is created.",
+				containerName,
+				lockFileName,
+			)))
+			fmt.Fprintf(os.Stderr, "Recreate it to use the locked image: docker rm -f %s && orca start --locked\n", containerName)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// logFileMaxSize is the size past which the log file is rotated when the CLI starts
+	logFileMaxSize = 5 * 1024 * 1024
+	// logFileBackups is the number of rotated log files kept next to the current one
+	logFileBackups = 3
+)
+
+var ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// logFileFlag is the value of --log-file, which may be given bare to log to the default file
+type logFileFlag struct {
+	path string
+}
+
+func (f *logFileFlag) String() string { return f.path }
+
+func (f *logFileFlag) Set(value string) error {
+	if value == "true" {
+		value = "default"
+	} else if value == "false" {
+		value = ""
+	}
+	f.path = value
+	return nil
+}
+
+// IsBoolFlag allows `--log-file` without a value, while `--log-file=<path>` picks the file
+func (f *logFileFlag) IsBoolFlag() bool { return true }
+
+// logMirror copies everything written to stdout and stderr into the log file
+type logMirror struct {
+	file     *os.File
+	stdout   *os.File
+	stderr   *os.File
+	writers  []*os.File
+	copiers  sync.WaitGroup
+	fileLock sync.Mutex
+}
+
+var activeLogMirror *logMirror
+
+// defaultLogFilePath returns ~/.local/state/orca/logs/orca.log
+func defaultLogFilePath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "logs", "orca.log"), nil
+}
+
+// resolveLogFilePath returns the log file to write from the --log-file flag, falling
+// back to the logging section of the global config
+func resolveLogFilePath(flagValue string) (string, error) {
+	path := flagValue
+	if path == "" {
+		config, err := loadGlobalConfig()
+		if err != nil {
+			return "", err
+		}
+		if config.Logging.Enabled {
+			path = config.Logging.Path
+			if path == "" {
+				path = "default"
+			}
+		}
+	}
+	if path == "default" {
+		return defaultLogFilePath()
+	}
+	return path, nil
+}
+
+// rotateLogFile shifts path to path.1, path.1 to path.2 and so on once path has grown
+// past logFileMaxSize, dropping the oldest
+func rotateLogFile(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && info.Size() < logFileMaxSize) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", path, logFileBackups))
+	for ii := logFileBackups - 1; ii >= 1; ii-- {
+		os.Rename(fmt.Sprintf("%s.%d", path, ii), fmt.Sprintf("%s.%d", path, ii+1))
+	}
+	return os.Rename(path, path+".1")
+}
+
+// startLogMirror mirrors all CLI output to the log file at path, until stopLogMirror
+func startLogMirror(path string, args []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if err := rotateLogFile(path); err != nil {
+		return fmt.Errorf("failed to rotate %s: %w", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	mirror := &logMirror{file: file, stdout: os.Stdout, stderr: os.Stderr}
+	stdout, err := mirror.tee(os.Stdout)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	stderr, err := mirror.tee(os.Stderr)
+	if err != nil {
+		stdout.Close()
+		file.Close()
+		return err
+	}
+
+	activeLogMirror = mirror
+	os.Stdout = stdout
+	os.Stderr = stderr
+
+	logDebug("orca %s (version %s, project %s)", strings.Join(args, " "), Version, projectLabelValue(stackProject))
+	return nil
+}
+
+// tee returns a pipe whose output is copied to target and to the log file
+func (m *logMirror) tee(target *os.File) (*os.File, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to mirror output to the log file: %w", err)
+	}
+	m.writers = append(m.writers, writer)
+	m.copiers.Add(1)
+	go func() {
+		defer m.copiers.Done()
+		defer reader.Close()
+		buffer := make([]byte, 32*1024)
+		for {
+			n, err := reader.Read(buffer)
+			if n > 0 {
+				target.Write(buffer[:n])
+				m.fileLock.Lock()
+				io.WriteString(m.file, ansiEscapePattern.ReplaceAllString(string(buffer[:n]), ""))
+				m.fileLock.Unlock()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return writer, nil
+}
+
+// terminalFile returns the original stream behind a mirrored one, so that terminal
+// detection is unaffected by the log file
+func terminalFile(f *os.File) *os.File {
+	if activeLogMirror == nil {
+		return f
+	}
+	for ii, writer := range activeLogMirror.writers {
+		if f == writer {
+			return []*os.File{activeLogMirror.stdout, activeLogMirror.stderr}[ii]
+		}
+	}
+	return f
+}
+
+// logDebug writes a timestamped line to the log file only, if one is open
+func logDebug(format string, args ...any) {
+	if activeLogMirror == nil {
+		return
+	}
+	activeLogMirror.fileLock.Lock()
+	defer activeLogMirror.fileLock.Unlock()
+	fmt.Fprintf(activeLogMirror.file, "[%s] %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
+
+// stopLogMirror restores stdout and stderr and flushes the log file
+func stopLogMirror(code int) {
+	mirror := activeLogMirror
+	if mirror == nil {
+		return
+	}
+	os.Stdout = mirror.stdout
+	os.Stderr = mirror.stderr
+	for _, writer := range mirror.writers {
+		writer.Close()
+	}
+	mirror.copiers.Wait()
+
+	logDebug("exited with code %d", code)
+	activeLogMirror = nil
+	mirror.file.WriteString("\n")
+	mirror.file.Close()
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// defaultImageRegistry hosts the core image, and is logged in to when the project
+// names no registry of its own
+const defaultImageRegistry = "ghcr.io"
+
+// registryHost returns the registry of an image repository, which docker takes to be
+// Docker Hub when the first part of the name is not a host
+func registryHost(repository string) string {
+	host, _, found := strings.Cut(repository, "/")
+	if found && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		return host
+	}
+	return "docker.io"
+}
+
+// projectRegistry returns the registry to log in to for a project: that of its
+// processor image when set, otherwise the registry of the core image
+func projectRegistry(config *OrcaConfigFile) string {
+	if config.Processor != nil && config.Processor.Image != "" {
+		return registryHost(config.Processor.Image)
+	}
+	return registryHost(stackImages[componentCore])
+}
+
+// keyringCredentialHelpers are the docker credential helpers keeping credentials in
+// the keyring of each platform
+var keyringCredentialHelpers = map[string]string{
+	"darwin":  "osxkeychain",
+	"windows": "wincred",
+	"linux":   "secretservice",
+}
+
+// dockerConfigPath returns the docker CLI configuration, which records where
+// credentials are stored
+func dockerConfigPath() (string, error) {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".docker", "config.json"), nil
+}
+
+// readDockerConfig reads the docker CLI configuration as a generic document, so that
+// settings the CLI does not know of are written back unchanged
+func readDockerConfig(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]any{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	config := map[string]any{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return config, nil
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// dockerCredentialHelper returns the credential helper docker stores the credentials
+// of a registry with, or "" when it stores them in its configuration file
+func dockerCredentialHelper(config map[string]any, registry string) string {
+	if helpers, ok := config["credHelpers"].(map[string]any); ok {
+		if helper, ok := helpers[registry].(string); ok && helper != "" {
+			return helper
+		}
+	}
+	helper, _ := config["credsStore"].(string)
+	return helper
+}
+
+// ensureKeyringCredentials has docker keep the credentials of registry in the
+// platform's keyring when it has no credential store for it yet and the keyring's
+// helper is installed. Only the helper of registry is set in credHelpers, as a
+// global credsStore would hide the credentials docker keeps for other registries in
+// its configuration file. The change is made once confirm agrees, or without asking
+// when confirm is nil. It returns the helper now in use, and whether the
+// configuration was changed.
+func ensureKeyringCredentials(registry string, confirm func(helper, path string) bool) (string, bool, error) {
+	path, err := dockerConfigPath()
+	if err != nil {
+		return "", false, err
+	}
+	config, err := readDockerConfig(path)
+	if err != nil {
+		return "", false, err
+	}
+	if helper := dockerCredentialHelper(config, registry); helper != "" {
+		return helper, false, nil
+	}
+	helper := keyringCredentialHelpers[runtime.GOOS]
+	if helper == "" {
+		return "", false, nil
+	}
+	if _, err := exec.LookPath("docker-credential-" + helper); err != nil {
+		return "", false, nil
+	}
+
+	if confirm != nil && !confirm(helper, path) {
+		return "", false, nil
+	}
+
+	helpers, _ := config["credHelpers"].(map[string]any)
+	if helpers == nil {
+		helpers = map[string]any{}
+	}
+	helpers[registry] = helper
+	config["credHelpers"] = helpers
+	data, err := json.MarshalIndent(config, "", "\t")
+	if err != nil {
+		return "", false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", false, err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return "", false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return helper, true, nil
+}
+
+// githubCLICredentials returns the user and token the GitHub CLI is logged in with,
+// which ghcr.io accepts when the token has the read:packages scope
+func githubCLICredentials() (string, string, error) {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return "", "", fmt.Errorf("-gh requires the GitHub CLI on your PATH. See https://cli.github.com")
+	}
+	token, err := exec.Command("gh", "auth", "token").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("the GitHub CLI is not logged in, run `gh auth login` first")
+	}
+	user, err := exec.Command("gh", "api", "user", "--jq", ".login").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read the GitHub user: %w", err)
+	}
+	return strings.TrimSpace(string(user)), strings.TrimSpace(string(token)), nil
+}
+
+// dockerLogin logs docker in to a registry. With a password it is passed on stdin,
+// otherwise docker prompts for whatever it needs.
+func dockerLogin(registry, username string, password io.Reader) error {
+	args := []string{"login"}
+	if username != "" {
+		args = append(args, "--username", username)
+	}
+	if password != nil {
+		args = append(args, "--password-stdin")
+	}
+	cmd := dockerCommand(append(args, registry)...)
+	cmd.Stdin = os.Stdin
+	if password != nil {
+		cmd.Stdin = password
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to log in to %s", registry)
+	}
+	return nil
+}
+
+// dockerLogout removes the credentials docker keeps for a registry
+func dockerLogout(registry string) error {
+	output, err := dockerCommand("logout", registry).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to log out of %s: %s", registry, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestRegistryHost(t *testing.T) {
+	for repository, want := range map[string]string{
+		"ghcr.io/orca-telemetry/core:0.14.2": "ghcr.io",
+		"registry.example.com:5000/speed":    "registry.example.com:5000",
+		"localhost/speed":                    "localhost",
+		"acme/speed":                         "docker.io",
+		"speed":                              "docker.io",
+	} {
+		if got := registryHost(repository); got != want {
+			t.Errorf("registryHost(%q) = %s, want %s", repository, got, want)
+		}
+	}
+
+	if got := projectRegistry(&OrcaConfigFile{}); got != defaultImageRegistry {
+		t.Errorf("projectRegistry without a processor image = %s, want the core's registry", got)
+	}
+	if got := projectRegistry(&OrcaConfigFile{Processor: &ProcessorConfig{Image: "quay.io/acme/speed"}}); got != "quay.io" {
+		t.Errorf("projectRegistry = %s, want the processor image's registry", got)
+	}
+}
+
+func TestDockerCredentialHelper(t *testing.T) {
+	config := map[string]any{
+		"credsStore":  "desktop",
+		"credHelpers": map[string]any{"123.dkr.ecr.eu-west-1.amazonaws.com": "ecr-login"},
+	}
+	if got := dockerCredentialHelper(config, "123.dkr.ecr.eu-west-1.amazonaws.com"); got != "ecr-login" {
+		t.Errorf("got %q, want the registry's own helper", got)
+	}
+	if got := dockerCredentialHelper(config, "ghcr.io"); got != "desktop" {
+		t.Errorf("got %q, want the default store", got)
+	}
+	if got := dockerCredentialHelper(map[string]any{}, "ghcr.io"); got != "" {
+		t.Errorf("got %q, want none", got)
+	}
+}
+
+func TestEnsureKeyringCredentials(t *testing.T) {
+	helper := keyringCredentialHelpers[runtime.GOOS]
+	if helper == "" || runtime.GOOS == "windows" {
+		t.Skip("no keyring helper can be faked on", runtime.GOOS)
+	}
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	path := filepath.Join(dir, "config.json")
+	os.WriteFile(path, []byte(`{"auths": {"ghcr.io": {}}, "currentContext": "colima"}`), 0600)
+
+	// without the helper installed, the store is left as it is
+	if got, changed, err := ensureKeyringCredentials("ghcr.io", nil); err != nil || changed || got != "" {
+		t.Fatalf("without a helper got %q, %v, %v", got, changed, err)
+	}
+
+	os.WriteFile(filepath.Join(bin, "docker-credential-"+helper), []byte("#!/bin/sh\n"), 0755)
+	decline := func(string, string) bool { return false }
+	if got, changed, err := ensureKeyringCredentials("ghcr.io", decline); err != nil || changed || got != "" {
+		t.Fatalf("once declined got %q, %v, %v, want the configuration left as it is", got, changed, err)
+	}
+	if got, changed, err := ensureKeyringCredentials("ghcr.io", nil); err != nil || !changed || got != helper {
+		t.Fatalf("got %q, %v, %v, want the store set to %s", got, changed, err, helper)
+	}
+	config, err := readDockerConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config["credsStore"] != nil || config["currentContext"] != "colima" || config["auths"] == nil {
+		t.Errorf("config = %v, want only the helper of the registry added to the other settings", config)
+	}
+	if helpers, _ := config["credHelpers"].(map[string]any); helpers["ghcr.io"] != helper || len(helpers) != 1 {
+		t.Errorf("credHelpers = %v, want %s for ghcr.io only", config["credHelpers"], helper)
+	}
+	if _, changed, _ := ensureKeyringCredentials("ghcr.io", nil); changed {
+		t.Error("a configured store was changed again")
+	}
+
+	// a global store of the user is kept
+	os.WriteFile(path, []byte(`{"credsStore": "pass"}`), 0600)
+	if got, changed, err := ensureKeyringCredentials("ghcr.io", nil); err != nil || changed || got != "pass" {
+		t.Errorf("with a credsStore got %q, %v, %v, want it kept", got, changed, err)
+	}
+}
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// logLevelPatterns find the level of a line in the formats of the stack: the core's
+// slog text or JSON, Postgres' "ERROR:" prefixes and Redis' level marks
+var logLevelPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\blevel=(\w+)`),
+	regexp.MustCompile(`(?i)"level"\s*:\s*"(\w+)"`),
+	regexp.MustCompile(`\b(DEBUG[1-5]?|LOG|INFO|NOTICE|WARNING|ERROR|FATAL|PANIC):  `),
+	regexp.MustCompile(`^\d+:[XCSM] \d+ \w+ \d{4} [\d:.]+ ([.\-*#]) `),
+}
+
+// normalizeLogLevel maps the levels of the stack's components onto coreLogLevels,
+// returning "" for one that is not known
+func normalizeLogLevel(level string) string {
+	switch strings.ToLower(level) {
+	case "trace", "debug", "debug1", "debug2", "debug3", "debug4", "debug5", ".", "-":
+		return "debug"
+	case "info", "log", "notice", "*":
+		return "info"
+	case "warn", "warning", "#":
+		return "warn"
+	case "error", "err", "fatal", "panic", "critical":
+		return "error"
+	}
+	return ""
+}
+
+// logLineLevel returns the level of a log line, or "" when it has none
+func logLineLevel(line string) string {
+	for _, pattern := range logLevelPatterns {
+		if match := pattern.FindStringSubmatch(line); match != nil {
+			if level := normalizeLogLevel(match[1]); level != "" {
+				return level
+			}
+		}
+	}
+	return ""
+}
+
+// logFilter keeps the log lines of a container at least as severe as level and
+// matching pattern. Lines without a level, such as those of a stack trace, take the
+// level of the line before them.
+type logFilter struct {
+	level   string
+	pattern *regexp.Regexp
+	last    string
+}
+
+func newLogFilter(level, pattern string) (*logFilter, error) {
+	filter := &logFilter{}
+	if level != "" {
+		filter.level = normalizeLogLevel(level)
+		if filter.level == "" {
+			return nil, fmt.Errorf("invalid level %q, must be one of: %s", level, strings.Join(coreLogLevels, ", "))
+		}
+	}
+	if pattern != "" {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -grep pattern: %w", err)
+		}
+		filter.pattern = compiled
+	}
+	return filter, nil
+}
+
+// clone returns a filter with the same settings, for another container
+func (f *logFilter) clone() *logFilter {
+	return &logFilter{level: f.level, pattern: f.pattern}
+}
+
+func (f *logFilter) keep(line string) bool {
+	if level := logLineLevel(line); level != "" {
+		f.last = level
+	}
+	if f.level != "" && slices.Index(coreLogLevels, f.last) < slices.Index(coreLogLevels, f.level) {
+		return false
+	}
+	return f.pattern == nil || f.pattern.MatchString(line)
+}
+
+// parseLogTime reads -since and -until: a duration before now such as 90m or 2d, an
+// RFC 3339 time, or a date
+func parseLogTime(value string, now time.Time) (time.Time, error) {
+	if duration, err := parseDurationWithDays(value); err == nil {
+		return now.Add(-duration), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if at, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return at, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected a duration such as 1h or 2d, or a time such as 2006-01-02T15:04:05Z", value)
+}
+
+// logOptions select the lines `orca logs` reads from docker
+type logOptions struct {
+	since  time.Time
+	until  time.Time
+	tail   string
+	follow bool
+	// timestamps prefixes each line with the time docker received it
+	timestamps bool
+}
+
+func (o logOptions) dockerArgs(containerName string) []string {
+	args := []string{"logs"}
+	if !o.since.IsZero() {
+		args = append(args, "--since", o.since.UTC().Format(time.RFC3339Nano))
+	}
+	if !o.until.IsZero() {
+		args = append(args, "--until", o.until.UTC().Format(time.RFC3339Nano))
+	}
+	if o.tail != "" {
+		args = append(args, "--tail", o.tail)
+	}
+	if o.follow {
+		args = append(args, "--follow")
+	}
+	if o.timestamps {
+		args = append(args, "--timestamps")
+	}
+	return append(args, containerName)
+}
+
+// lockedWriter writes whole lines from several goroutines
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) println(args ...any) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Fprintln(w.w, args...)
+}
+
+// logSource is a container `orca logs` reads, named by its component or processor
+type logSource struct {
+	Name      string
+	Container string
+}
+
+// resolveLogSources returns the containers of stack components, companion services
+// and deployed processors, named by the user. all stands for every stack component
+// and deployed processor.
+func resolveLogSources(names []string, processors []componentStatus) ([]logSource, error) {
+	var sources []logSource
+	add := func(source logSource) {
+		if !slices.Contains(sources, source) {
+			sources = append(sources, source)
+		}
+	}
+	for _, name := range names {
+		if name == "all" {
+			for _, component := range stackComponents {
+				containerName, _ := componentContainer(component)
+				add(logSource{component, containerName})
+			}
+			for _, processor := range processors {
+				add(logSource{processor.Name, processor.Container})
+			}
+			continue
+		}
+		if index := slices.IndexFunc(processors, func(processor componentStatus) bool { return processor.Name == name }); index >= 0 {
+			add(logSource{name, processors[index].Container})
+			continue
+		}
+		containerName, err := componentContainer(name)
+		if err != nil {
+			return nil, err
+		}
+		add(logSource{name, containerName})
+	}
+	return sources, nil
+}
+
+// logPrefixes returns the prefix naming the source of each line when there are
+// several sources, padded to line up
+func logPrefixes(sources []logSource) []string {
+	prefixes := make([]string, len(sources))
+	if len(sources) < 2 {
+		return prefixes
+	}
+	width := 0
+	for _, source := range sources {
+		width = max(width, len(source.Name))
+	}
+	for ii, source := range sources {
+		prefixes[ii] = renderStdout(dimStyle, fmt.Sprintf("%-*s |", width, source.Name)) + " "
+	}
+	return prefixes
+}
+
+// readSources reads the logs of every source concurrently, passing each line to
+// handle with the index of its source. Each source has a filter of its own, as
+// lines take the level of the line before them.
+func readSources(ctx context.Context, sources []logSource, options logOptions, filter *logFilter, handle func(source int, line string)) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(sources))
+	for ii, source := range sources {
+		sourceFilter := filter.clone()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[ii] = streamContainerLines(ctx, source.Container, options, func(line string) {
+				if sourceFilter.keep(line) {
+					handle(ii, line)
+				}
+			})
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// streamLogs writes the lines of each source's logs that pass filter to w, under a
+// prefix naming the source when there are several. Structured lines are rendered
+// with prettyLogLine unless raw is set. It returns once every log has ended, or ctx
+// is done.
+func streamLogs(ctx context.Context, w io.Writer, sources []logSource, options logOptions, filter *logFilter, raw bool) error {
+	out := &lockedWriter{w: w}
+	prefixes := logPrefixes(sources)
+	return readSources(ctx, sources, options, filter, func(source int, line string) {
+		if !raw {
+			line = prettyLogLine(line)
+		}
+		out.println(prefixes[source] + line)
+	})
+}
+
+// correlationPattern matches an id as a whole word, so that window 12 does not match
+// window 120
+func correlationPattern(id string) *regexp.Regexp {
+	return regexp.MustCompile(`(^|[^\w-])` + regexp.QuoteMeta(id) + `($|[^\w-])`)
+}
+
+// correlatedLine is a line mentioning the id being correlated, with the time docker
+// received it
+type correlatedLine struct {
+	Time   time.Time
+	Source int
+	Line   string
+}
+
+// splitDockerTimestamp separates the timestamp `docker logs --timestamps` prefixes a
+// line with
+func splitDockerTimestamp(line string) (time.Time, string, bool) {
+	stamp, rest, found := strings.Cut(line, " ")
+	if !found {
+		return time.Time{}, line, false
+	}
+	at, err := time.Parse(time.RFC3339Nano, stamp)
+	if err != nil {
+		return time.Time{}, line, false
+	}
+	return at, rest, true
+}
+
+// sortCorrelatedLines orders lines by time, keeping the order of each source's lines
+// when their times tie
+func sortCorrelatedLines(lines []correlatedLine) {
+	slices.SortStableFunc(lines, func(a, b correlatedLine) int {
+		return a.Time.Compare(b.Time)
+	})
+}
+
+// correlateLogs writes the lines of every source mentioning id, such as a window or
+// request id, interleaved in the order they were logged, to follow one window across
+// the stack
+func correlateLogs(ctx context.Context, w io.Writer, sources []logSource, options logOptions, filter *logFilter, id string, raw bool) (int, error) {
+	options.timestamps = true
+	pattern := correlationPattern(id)
+	var mu sync.Mutex
+	var lines []correlatedLine
+	err := readSources(ctx, sources, options, filter, func(source int, line string) {
+		at, text, _ := splitDockerTimestamp(line)
+		if !pattern.MatchString(text) {
+			return
+		}
+		mu.Lock()
+		lines = append(lines, correlatedLine{Time: at, Source: source, Line: text})
+		mu.Unlock()
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sortCorrelatedLines(lines)
+	prefixes := logPrefixes(sources)
+	for _, line := range lines {
+		text := line.Line
+		if !raw {
+			text = prettyLogLine(text)
+		}
+		fmt.Fprintln(w, prefixes[line.Source]+text)
+	}
+	return len(lines), nil
+}
+
+// streamContainerLines passes each line of a container's logs, from its stdout and
+// stderr, to handle
+func streamContainerLines(ctx context.Context, containerName string, options logOptions, handle func(line string)) error {
+	reader, writer := io.Pipe()
+	cmd := dockerCommandContext(ctx, options.dockerArgs(containerName)...)
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			handle(scanner.Text())
+		}
+		io.Copy(io.Discard, reader)
+	}()
+
+	err := cmd.Run()
+	writer.Close()
+	<-done
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to read the logs of %s: %w", containerName, err)
+	}
+	return nil
+}
+
+// logRecord is a structured log line, as written by slog's JSON or text handlers
+type logRecord struct {
+	Time    time.Time
+	Level   string
+	Message string
+	// Attrs are the remaining fields as key=value, flattened and sorted by key
+	Attrs []string
+}
+
+// logRecordKeys are the fields of JSON log lines holding the time, level and message,
+// under the names of slog and of other common loggers
+var logRecordKeys = struct{ time, level, message []string }{
+	time:    []string{"time", "ts", "timestamp", "@timestamp"},
+	level:   []string{"level", "lvl", "severity"},
+	message: []string{"msg", "message"},
+}
+
+// parseJSONLogLine reads a JSON log line, reporting false for any other line
+func parseJSONLogLine(line string) (logRecord, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return logRecord{}, false
+	}
+	fields := map[string]any{}
+	decoder := json.NewDecoder(strings.NewReader(trimmed))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return logRecord{}, false
+	}
+	take := func(keys []string) string {
+		for _, key := range keys {
+			if value, ok := fields[key].(string); ok {
+				delete(fields, key)
+				return value
+			}
+		}
+		return ""
+	}
+	record := logRecord{Level: take(logRecordKeys.level), Message: take(logRecordKeys.message)}
+	if at := take(logRecordKeys.time); at != "" {
+		record.Time, _ = time.Parse(time.RFC3339Nano, at)
+	}
+	if record.Level == "" && record.Message == "" {
+		return logRecord{}, false
+	}
+	record.Attrs = flattenLogAttrs("", fields)
+	sort.Strings(record.Attrs)
+	return record, true
+}
+
+// flattenLogAttrs renders fields as key=value, naming those of nested groups
+// group.key as slog's text handler does
+func flattenLogAttrs(prefix string, fields map[string]any) []string {
+	var attrs []string
+	for key, value := range fields {
+		switch value := value.(type) {
+		case map[string]any:
+			attrs = append(attrs, flattenLogAttrs(prefix+key+".", value)...)
+		case string:
+			if value == "" || strings.ContainsAny(value, " \t\"=") {
+				value = strconv.Quote(value)
+			}
+			attrs = append(attrs, prefix+key+"="+value)
+		default:
+			data, _ := json.Marshal(value)
+			attrs = append(attrs, prefix+key+"="+string(data))
+		}
+	}
+	return attrs
+}
+
+// parseTextLogLine reads a line of slog's text handler, as the core writes without
+// JSON logging
+func parseTextLogLine(line string) (logRecord, bool) {
+	match := coreLogPattern.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return logRecord{}, false
+	}
+	record := logRecord{Level: match[2], Message: match[3]}
+	record.Time, _ = time.Parse(time.RFC3339Nano, match[1])
+	if unquoted, err := strconv.Unquote(record.Message); err == nil {
+		record.Message = unquoted
+	}
+	if match[4] != "" {
+		record.Attrs = []string{match[4]}
+	}
+	return record, true
+}
+
+// logLevelStyles color levels by severity
+var logLevelStyles = map[string]lipgloss.Style{
+	"debug": dimStyle,
+	"info":  successStyle,
+	"warn":  warningStyle,
+	"error": errorStyle,
+}
+
+// prettyLogLine renders a structured log line as its local time, colored level and
+// message followed by its attributes. Other lines are returned unchanged.
+func prettyLogLine(line string) string {
+	record, ok := parseJSONLogLine(line)
+	if !ok {
+		if record, ok = parseTextLogLine(line); !ok {
+			return line
+		}
+	}
+	var parts []string
+	if !record.Time.IsZero() {
+		parts = append(parts, renderStdout(dimStyle, record.Time.Local().Format("15:04:05.000")))
+	}
+	if record.Level != "" {
+		level := fmt.Sprintf("%-5s", strings.ToUpper(record.Level))
+		if style, ok := logLevelStyles[normalizeLogLevel(record.Level)]; ok {
+			level = renderStdout(style, level)
+		}
+		parts = append(parts, level)
+	}
+	parts = append(parts, record.Message)
+	if len(record.Attrs) > 0 {
+		parts = append(parts, renderStdout(dimStyle, strings.Join(record.Attrs, " ")))
+	}
+	return strings.Join(parts, " ")
+}
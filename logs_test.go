@@ -0,0 +1,178 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLogLineLevel(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{`time=2026-10-16T09:30:00.000Z level=ERROR msg="failed to dispatch" window=12`, "error"},
+		{`time=2026-10-16T09:30:00.000Z level=INFO msg=started`, "info"},
+		{`{"time":"2026-10-16T09:30:00Z","level":"WARN","msg":"slow processor"}`, "warn"},
+		{`2026-10-16 09:30:00.123 UTC [68] ERROR:  relation "windowz" does not exist`, "error"},
+		{`2026-10-16 09:30:00.123 UTC [1] LOG:  database system is ready to accept connections`, "info"},
+		{`1:M 16 Oct 2026 09:30:00.123 # WARNING Memory overcommit must be enabled!`, "warn"},
+		{`1:M 16 Oct 2026 09:30:00.123 * Ready to accept connections tcp`, "info"},
+		{`    at dispatch (dispatch.go:42)`, ""},
+	}
+	for _, test := range tests {
+		if got := logLineLevel(test.line); got != test.want {
+			t.Errorf("logLineLevel(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestLogFilter(t *testing.T) {
+	filter, err := newLogFilter("warn", "dispatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := []string{
+		`level=INFO msg="dispatch started"`,
+		`level=ERROR msg="dispatch failed"`,
+		`goroutine 1: dispatch()`,
+		`level=ERROR msg="store unreachable"`,
+		`level=DEBUG msg="dispatch retried"`,
+		`  dispatch.go:42`,
+	}
+	var kept []string
+	for _, line := range lines {
+		if filter.keep(line) {
+			kept = append(kept, line)
+		}
+	}
+	if want := []string{lines[1], lines[2]}; !slices.Equal(kept, want) {
+		t.Errorf("kept %q, want %q", kept, want)
+	}
+
+	if _, err := newLogFilter("loud", ""); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if _, err := newLogFilter("", "("); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+func TestParseLogTime(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"1h", now.Add(-time.Hour)},
+		{"2d", now.Add(-48 * time.Hour)},
+		{"2026-10-15T08:00:00Z", time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		got, err := parseLogTime(test.value, now)
+		if err != nil || !got.Equal(test.want) {
+			t.Errorf("parseLogTime(%q) = %v, %v, want %v", test.value, got, err, test.want)
+		}
+	}
+	if _, err := parseLogTime("yesterday", now); err == nil {
+		t.Error("expected an error for an unknown time")
+	}
+
+	options := logOptions{since: now.Add(-time.Hour), tail: "50", follow: true}
+	want := []string{"logs", "--since", "2026-10-16T08:30:00Z", "--tail", "50", "--follow", "orca-instance"}
+	if got := options.dockerArgs("orca-instance"); !slices.Equal(got, want) {
+		t.Errorf("dockerArgs = %q, want %q", got, want)
+	}
+}
+
+func TestPrettyLogLine(t *testing.T) {
+	line := `{"time":"2026-10-16T09:30:00.125Z","level":"ERROR","msg":"failed to dispatch","window":12,"processor":{"name":"speed","address":"speed:5377"},"error":"connection refused"}`
+	record, ok := parseJSONLogLine(line)
+	if !ok {
+		t.Fatal("a JSON log line was not recognised")
+	}
+	if record.Level != "ERROR" || record.Message != "failed to dispatch" || !record.Time.Equal(time.Date(2026, 10, 16, 9, 30, 0, 125e6, time.UTC)) {
+		t.Errorf("record = %+v", record)
+	}
+	want := []string{`error="connection refused"`, "processor.address=speed:5377", "processor.name=speed", "window=12"}
+	if !slices.Equal(record.Attrs, want) {
+		t.Errorf("attrs = %q, want %q", record.Attrs, want)
+	}
+
+	pretty := prettyLogLine(line)
+	wantPretty := record.Time.Local().Format("15:04:05.000") + ` ERROR failed to dispatch error="connection refused" processor.address=speed:5377 processor.name=speed window=12`
+	if pretty != wantPretty {
+		t.Errorf("prettyLogLine = %q, want %q", pretty, wantPretty)
+	}
+
+	text := `time=2026-10-16T09:30:00.125Z level=INFO msg="processor registered" name=speed`
+	if got := prettyLogLine(text); got != record.Time.Local().Format("15:04:05.000")+" INFO  processor registered name=speed" {
+		t.Errorf("prettyLogLine(text) = %q", got)
+	}
+
+	for _, plain := range []string{"starting orca core", `{"not": "a log line"}`, "{broken"} {
+		if got := prettyLogLine(plain); got != plain {
+			t.Errorf("prettyLogLine(%q) = %q, want it unchanged", plain, got)
+		}
+	}
+}
+
+func TestResolveLogSources(t *testing.T) {
+	processors := []componentStatus{{Name: "speed", Container: "orca-processor-speed"}}
+	sources, err := resolveLogSources([]string{"core", "all", "speed"}, processors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, source := range sources {
+		names = append(names, source.Name)
+	}
+	if want := []string{"core", "postgres", "redis", "speed"}; !slices.Equal(names, want) {
+		t.Errorf("sources %v, want %v", names, want)
+	}
+	if sources[3].Container != "orca-processor-speed" {
+		t.Errorf("processor source = %+v", sources[3])
+	}
+	if _, err := resolveLogSources([]string{"kafka"}, processors); err == nil {
+		t.Error("expected an error for an unknown component")
+	}
+}
+
+func TestCorrelateLogLines(t *testing.T) {
+	pattern := correlationPattern("42")
+	for line, want := range map[string]bool{
+		`level=INFO msg="window received" window=42`:           true,
+		`{"msg":"executing","window_id":42,"algorithm":"avg"}`: true,
+		`processing window 42 for speed`:                       true,
+		`level=INFO msg="window received" window=420`:          false,
+		`stored 2026-10-42`:                                    false,
+	} {
+		if got := pattern.MatchString(line); got != want {
+			t.Errorf("correlationPattern(42) matches %q = %v, want %v", line, got, want)
+		}
+	}
+
+	at, text, ok := splitDockerTimestamp("2026-10-16T09:30:00.123456789Z level=INFO msg=started")
+	if !ok || text != "level=INFO msg=started" || at.Nanosecond() != 123456789 {
+		t.Errorf("splitDockerTimestamp = %v, %q, %v", at, text, ok)
+	}
+	if _, text, ok := splitDockerTimestamp("no timestamp"); ok || text != "no timestamp" {
+		t.Errorf("splitDockerTimestamp without a timestamp = %q, %v", text, ok)
+	}
+
+	base := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	lines := []correlatedLine{
+		{Time: base.Add(2 * time.Millisecond), Source: 1, Line: "processor ran"},
+		{Time: base, Source: 0, Line: "core received"},
+		{Time: base.Add(2 * time.Millisecond), Source: 1, Line: "processor replied"},
+		{Time: base.Add(time.Millisecond), Source: 0, Line: "core dispatched"},
+	}
+	sortCorrelatedLines(lines)
+	var order []string
+	for _, line := range lines {
+		order = append(order, line.Line)
+	}
+	if want := []string{"core received", "core dispatched", "processor ran", "processor replied"}; !slices.Equal(order, want) {
+		t.Errorf("order %q, want %q", order, want)
+	}
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"cmp"
 	"context"
-	"crypto/tls"
-	"crypto/x509"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,8 +19,7 @@ import (
 	pb "github.com/orca-telemetry/core/protobufs/go"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 )
 
 // Version information - set during build with ldflags
@@ -38,24 +40,90 @@ func printVersion() {
 }
 
 func main() {
-	flag.Bool("version", false, "Show version information")
+	// the fake engine runs the CLI itself in place of docker, see engine.go
+	if len(os.Args) > 1 && os.Args[1] == fakeDockerCommand {
+		os.Exit(runFakeDockerCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == profileCommand {
+		os.Exit(runProfileCommand(os.Args[2:]))
+	}
+
+	showVersion := flag.Bool("version", false, "Show version information")
+	flag.BoolVar(showVersion, "v", false, "Show version information (shorthand)")
+	noColor := flag.Bool("no-color", false, "Disable colored output (env: NO_COLOR)")
+	flag.BoolVar(&showCommands, "show-commands", false, "Print every docker command before it runs, to audit or reproduce steps by hand")
+	profileCLI := flag.Bool("profile-cli", false, "Report how long each docker call, gRPC call and template render took when the command exits")
+	var logFile logFileFlag
+	flag.Var(&logFile, "log-file", "Mirror all output to a rotating log file, ~/.local/state/orca/logs/orca.log unless `path` is given with --log-file=<path>")
+	project := flag.String("project", os.Getenv("ORCA_PROJECT"), "Stack project to operate on, allowing several stacks side by side (env: ORCA_PROJECT)")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Orca CLI\n\n")
 		fmt.Fprintf(os.Stderr, "Usage:\n")
-		fmt.Fprintf(os.Stderr, "  orca <command> [options]\n\n")
+		fmt.Fprintf(os.Stderr, "  orca [--project <name>] [--no-color] [--show-commands] [--profile-cli] [--log-file[=<path>]] <command> [options]\n\n")
 		fmt.Fprintf(os.Stderr, "Commands:\n")
-		fmt.Fprintf(os.Stderr, "  start    Start the Orca stack\n")
-		fmt.Fprintf(os.Stderr, "  stop     Stop all Orca containers\n")
-		fmt.Fprintf(os.Stderr, "  status   Show status of Orca components\n")
-		fmt.Fprintf(os.Stderr, "  destroy  Delete all Orca resources\n")
-		fmt.Fprintf(os.Stderr, "  init     Initialize orca.json configuration\n")
-		fmt.Fprintf(os.Stderr, "  sync     Sync Orca registry data\n")
-		fmt.Fprintf(os.Stderr, "  help     Show help information\n\n")
+		fmt.Fprintf(os.Stderr, "  start         Start the Orca stack\n")
+		fmt.Fprintf(os.Stderr, "  stop          Stop all Orca containers\n")
+		fmt.Fprintf(os.Stderr, "  status        Show status of Orca components\n")
+		fmt.Fprintf(os.Stderr, "  destroy       Delete all Orca resources\n")
+		fmt.Fprintf(os.Stderr, "  init          Initialize orca.json configuration\n")
+		fmt.Fprintf(os.Stderr, "  sync          Sync Orca registry data\n")
+		fmt.Fprintf(os.Stderr, "  stub          Check generated stubs still match the registry\n")
+		fmt.Fprintf(os.Stderr, "  new           Scaffold a new python processor project\n")
+		fmt.Fprintf(os.Stderr, "  run           Run the processor of the project in this directory\n")
+		fmt.Fprintf(os.Stderr, "  build         Build a container image of the processor of the project\n")
+		fmt.Fprintf(os.Stderr, "  push          Build the processor image of the project and push it to its registry\n")
+		fmt.Fprintf(os.Stderr, "  deploy        Run the processor image of the project on the orca network\n")
+		fmt.Fprintf(os.Stderr, "  export        Export the stack and the project's processor as Compose or Kubernetes manifests\n")
+		fmt.Fprintf(os.Stderr, "  login         Log docker in to the registry of private core or processor images\n")
+		fmt.Fprintf(os.Stderr, "  logout        Remove the credentials kept for an image registry\n")
+		fmt.Fprintf(os.Stderr, "  scan          Scan the stack and processor images for vulnerabilities with Trivy or Grype\n")
+		fmt.Fprintf(os.Stderr, "  dev           Run the processor of the project, restarting it as its source changes\n")
+		fmt.Fprintf(os.Stderr, "  processor     Register processors and check they are reachable\n")
+		fmt.Fprintf(os.Stderr, "  call          Run a single algorithm on a window and print its result\n")
+		fmt.Fprintf(os.Stderr, "  schedule      Emit windows on a fixed cadence to exercise pipelines locally\n")
+		fmt.Fprintf(os.Stderr, "  import        Push windows from CSV or JSON files into the core\n")
+		fmt.Fprintf(os.Stderr, "  bridge        Forward messages from Kafka or MQTT to the core as windows\n")
+		fmt.Fprintf(os.Stderr, "  trace         Follow a window from the core to its stored results\n")
+		fmt.Fprintf(os.Stderr, "  failures      List, retry or purge algorithm executions that stored no result\n")
+		fmt.Fprintf(os.Stderr, "  queue         Show queue depths and processing rates per window type\n")
+		fmt.Fprintf(os.Stderr, "  pause         Drain in-flight processing, then freeze the core entirely\n")
+		fmt.Fprintf(os.Stderr, "  resume        Resume processing paused with `orca pause`\n")
+		fmt.Fprintf(os.Stderr, "  watch         Restart Orca containers that exit unexpectedly\n")
+		fmt.Fprintf(os.Stderr, "  service       Start the stack on boot with systemd, launchd or the Task Scheduler\n")
+		fmt.Fprintf(os.Stderr, "  daemon        Keep the registry cached in a background agent for fast local queries\n")
+		fmt.Fprintf(os.Stderr, "  serve         Serve the registry as read-only JSON over HTTP\n")
+		fmt.Fprintf(os.Stderr, "  api           Call any RPC of the core with a JSON request\n")
+		fmt.Fprintf(os.Stderr, "  health        Probe Orca components and exit 0/1/2 (healthy/degraded/down)\n")
+		fmt.Fprintf(os.Stderr, "  telemetry     Manage opt-in anonymous usage telemetry\n")
+		fmt.Fprintf(os.Stderr, "  update-check  Manage the daily notice about new CLI releases\n")
+		fmt.Fprintf(os.Stderr, "  psql          Open a psql session against the Postgres store\n")
+		fmt.Fprintf(os.Stderr, "  redis-cli     Open a redis-cli session against the Redis cache\n")
+		fmt.Fprintf(os.Stderr, "  logs          Show the logs of stack components, filtered by level, pattern and time\n")
+		fmt.Fprintf(os.Stderr, "  shell         Open an interactive shell in a stack container\n")
+		fmt.Fprintf(os.Stderr, "  cp            Copy files between a stack container and the host\n")
+		fmt.Fprintf(os.Stderr, "  port          List the host ports published by the stack containers\n")
+		fmt.Fprintf(os.Stderr, "  sql           Run a query against the Postgres store\n")
+		fmt.Fprintf(os.Stderr, "  seed          Load fixture data into the Postgres store\n")
+		fmt.Fprintf(os.Stderr, "  snapshot      Save and restore named snapshots of the stack data\n")
+		fmt.Fprintf(os.Stderr, "  backup        Archive the stack data to a file, optionally encrypted\n")
+		fmt.Fprintf(os.Stderr, "  restore       Replace the stack data with an archive written by `orca backup`\n")
+		fmt.Fprintf(os.Stderr, "  results       Export processed results from the store\n")
+		fmt.Fprintf(os.Stderr, "  purge         Delete aged windows and results from the store\n")
+		fmt.Fprintf(os.Stderr, "  du            Show the disk used by Orca volumes, images and store tables\n")
+		fmt.Fprintf(os.Stderr, "  maintenance   Vacuum the store and report table sizes and bloat\n")
+		fmt.Fprintf(os.Stderr, "  clone         Duplicate the stack and its data into a new project\n")
+		fmt.Fprintf(os.Stderr, "  config        Get or set orca.json settings\n")
+		fmt.Fprintf(os.Stderr, "  repair        Find and fix a partially created or broken stack\n")
+		fmt.Fprintf(os.Stderr, "  doctor        Diagnose the stack and fix what can be fixed in place\n")
+		fmt.Fprintf(os.Stderr, "  upgrade       Upgrade the core to the version this CLI supports, or roll it back\n")
+		fmt.Fprintf(os.Stderr, "  completion    Print a shell completion script\n")
+		fmt.Fprintf(os.Stderr, "  help          Show help information\n\n")
 		fmt.Fprintf(os.Stderr, "Examples:\n")
 		fmt.Fprintf(os.Stderr, "  orca start\n")
 		fmt.Fprintf(os.Stderr, "  orca sync -out ./data\n")
-		fmt.Fprintf(os.Stderr, "  orca init -name myproject\n\n")
+		fmt.Fprintf(os.Stderr, "  orca init -name myproject\n")
+		fmt.Fprintf(os.Stderr, "  orca --project staging start\n\n")
 		fmt.Fprintf(os.Stderr, "For more information on a command, run:\n")
 		fmt.Fprintf(os.Stderr, "  orca <command> help / -h\n")
 		flag.PrintDefaults()
@@ -68,19 +136,106 @@ func main() {
 	destroyCmd := flag.NewFlagSet("destroy", flag.ExitOnError)
 	syncCmd := flag.NewFlagSet("sync", flag.ExitOnError)
 	initCmd := flag.NewFlagSet("init", flag.ExitOnError)
+	watchCmd := flag.NewFlagSet("watch", flag.ExitOnError)
+	healthCmd := flag.NewFlagSet("health", flag.ExitOnError)
+	telemetryCmd := flag.NewFlagSet("telemetry", flag.ExitOnError)
+	updateCheckCmd := flag.NewFlagSet("update-check", flag.ExitOnError)
+	sqlCmd := flag.NewFlagSet("sql", flag.ExitOnError)
+	seedCmd := flag.NewFlagSet("seed", flag.ExitOnError)
+	snapshotCmd := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	resultsCmd := flag.NewFlagSet("results", flag.ExitOnError)
+	purgeCmd := flag.NewFlagSet("purge", flag.ExitOnError)
+	maintenanceCmd := flag.NewFlagSet("maintenance", flag.ExitOnError)
+	cloneCmd := flag.NewFlagSet("clone", flag.ExitOnError)
+	configCmd := flag.NewFlagSet("config", flag.ExitOnError)
+	repairCmd := flag.NewFlagSet("repair", flag.ExitOnError)
+	doctorCmd := flag.NewFlagSet("doctor", flag.ExitOnError)
+	upgradeCmd := flag.NewFlagSet("upgrade", flag.ExitOnError)
+	shellCmd := flag.NewFlagSet("shell", flag.ExitOnError)
+	cpCmd := flag.NewFlagSet("cp", flag.ExitOnError)
+	portCmd := flag.NewFlagSet("port", flag.ExitOnError)
+	processorCmd := flag.NewFlagSet("processor", flag.ExitOnError)
+	callCmd := flag.NewFlagSet("call", flag.ExitOnError)
+	traceCmd := flag.NewFlagSet("trace", flag.ExitOnError)
+	failuresCmd := flag.NewFlagSet("failures", flag.ExitOnError)
+	queueCmd := flag.NewFlagSet("queue", flag.ExitOnError)
+	pauseCmd := flag.NewFlagSet("pause", flag.ExitOnError)
+	resumeCmd := flag.NewFlagSet("resume", flag.ExitOnError)
+	scheduleCmd := flag.NewFlagSet("schedule", flag.ExitOnError)
+	importCmd := flag.NewFlagSet("import", flag.ExitOnError)
+	bridgeCmd := flag.NewFlagSet("bridge", flag.ExitOnError)
+	stubCmd := flag.NewFlagSet("stub", flag.ExitOnError)
+	newCmd := flag.NewFlagSet("new", flag.ExitOnError)
+	runCmd := flag.NewFlagSet("run", flag.ExitOnError)
+	buildCmd := flag.NewFlagSet("build", flag.ExitOnError)
+	pushCmd := flag.NewFlagSet("push", flag.ExitOnError)
+	deployCmd := flag.NewFlagSet("deploy", flag.ExitOnError)
+	exportCmd := flag.NewFlagSet("export", flag.ExitOnError)
+	devCmd := flag.NewFlagSet("dev", flag.ExitOnError)
+	daemonCmd := flag.NewFlagSet("daemon", flag.ExitOnError)
+	serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
+	apiCmd := flag.NewFlagSet("api", flag.ExitOnError)
+	serviceCmd := flag.NewFlagSet("service", flag.ExitOnError)
+	loginCmd := flag.NewFlagSet("login", flag.ExitOnError)
+	logoutCmd := flag.NewFlagSet("logout", flag.ExitOnError)
+	scanCmd := flag.NewFlagSet("scan", flag.ExitOnError)
+	backupCmd := flag.NewFlagSet("backup", flag.ExitOnError)
+	restoreCmd := flag.NewFlagSet("restore", flag.ExitOnError)
+	duCmd := flag.NewFlagSet("du", flag.ExitOnError)
+	logsCmd := flag.NewFlagSet("logs", flag.ExitOnError)
+
+	// global flags come before the subcommand, which then sees its own arguments at os.Args[2:]
+	flag.Parse()
+	if *noColor {
+		disableColor()
+	}
+	if *showVersion {
+		printVersion()
+		exit(0)
+	}
+	if *profileCLI {
+		if err := startProfiling(); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+	}
+	os.Args = append([]string{os.Args[0]}, flag.Args()...)
+
+	if err := setStackProject(*project); err != nil {
+		printError(err.Error())
+		exit(1)
+	}
+
+	// completion output is parsed by the shell, so it is never mirrored
+	if len(os.Args) < 2 || os.Args[1] != "__complete" {
+		logPath, err := resolveLogFilePath(logFile.path)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if logPath != "" {
+			if err := startLogMirror(logPath, os.Args[1:]); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+	}
 
-	// check if a subcommand is provided
+	// check if a subcommand is provided, offering a menu of common actions in a terminal
 	if len(os.Args) < 2 {
-		fmt.Println()
+		if isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+			exit(runInteractiveMenu())
+		}
+		fmt.Fprintln(os.Stderr)
 		flag.Usage()
-		fmt.Println()
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr)
+		exit(1)
 	}
 
-	// Check for --version flag before parsing subcommandsA
-	if os.Args[1] == "--version" || os.Args[1] == "-v" {
-		printVersion()
-		os.Exit(0)
+	// completion runs on every tab press, so it is neither recorded nor checks for updates
+	if os.Args[1] != "__complete" {
+		initTelemetry(os.Args[1])
+		initUpdateCheck()
 	}
 
 	// parse the appropriate subcommand
@@ -88,115 +243,470 @@ func main() {
 
 	case "version":
 		printVersion()
-		os.Exit(0)
+		exit(0)
 
 	case "start":
+		supervise := startCmd.Bool("supervise", false, "Keep running after start and restart containers that exit unexpectedly")
+		maxRestarts := startCmd.Int("max-restarts", 5, "Maximum number of restarts per container when supervising")
+		configPath := startCmd.String("config", findProjectConfig(), "Path to orca.json configuration file")
+		pgSharedBuffers := startCmd.String("pg-shared-buffers", "", "Postgres shared_buffers setting, e.g. 256MB (overrides orca.json)")
+		pgMaxConnections := startCmd.Int("pg-max-connections", 0, "Postgres max_connections setting (overrides orca.json)")
+		pgMaxWalSize := startCmd.String("pg-max-wal-size", "", "Postgres max_wal_size setting, e.g. 2GB (overrides orca.json)")
+		redisPersistence := startCmd.String("redis-persistence", "", "Redis persistence mode - aof|rdb|both|none (overrides orca.json)")
+		redisMaxMemory := startCmd.String("redis-maxmemory", "", "Redis maxmemory limit, e.g. 256mb (overrides orca.json)")
+		withServices := startCmd.String("with", "", fmt.Sprintf("Comma-separated optional services to start alongside the stack - %s (adds to orca.json services)", strings.Join(companionChoices(), "|")))
+		redisEvictionPolicy := startCmd.String("redis-eviction-policy", "", "Redis maxmemory-policy, e.g. allkeys-lru (overrides orca.json)")
+		envFile := startCmd.String("env-file", "", "Env file of variables to forward to the Orca core container (overrides orca.json core.envFile)")
+		locked := startCmd.Bool("locked", false, "Create containers from the exact image digests recorded in orca.lock")
+		strict := startCmd.Bool("strict", false, "Fail instead of warning when the running core is not compatible with this CLI")
+		restart := startCmd.String("restart", "", fmt.Sprintf("Restart policy - %s - for every component, or per component as e.g. postgres=unless-stopped,core=on-failure (overrides orca.json)", strings.Join(restartPolicies, "|")))
+		timezone := startCmd.String("timezone", "", "IANA timezone for the store and core, e.g. Europe/London, or \"local\" for the host timezone (overrides orca.json)")
+		logLevel := startCmd.String("log-level", "", fmt.Sprintf("Orca core log level - %s (overrides orca.json core.logLevel)", strings.Join(coreLogLevels, "|")))
+		startTimeout := startCmd.Duration("startup-timeout", 0, "How long to wait for each component to become ready, e.g. 2m (default 15s, overrides orca.json startup.timeout)")
+		startPollInterval := startCmd.Duration("poll-interval", 0, "How often to check readiness while waiting (default 500ms, overrides orca.json startup.pollInterval)")
+		canaryVersion := startCmd.String("canary", "", "Also run this core version, e.g. 0.15.0, as a canary against a copy of the store, on its own port")
+		processorsOnly := startCmd.Bool("processors-only", false, "Only run the processors of the stack, against the remote core of -connStr, without Postgres, Redis or a core")
+		startConnStr := startCmd.String("connStr", "", "Address of the remote core with -processors-only (defaults to orcaConnectionString in orca.json)")
+		startSecure := startCmd.Bool("secure", false, "With -processors-only, connect to the remote core with System Default Root CA credentials (via TLS)")
+		startCACert := startCmd.String("caCert", "", "With -processors-only, path to custom CA certificate file (PEM format) for TLS verification")
+
 		startCmd.Usage = func() {
-			fmt.Fprintf(os.Stderr, "Usage: orca start\n\n")
-			fmt.Fprintf(os.Stderr, "Start the Orca stack (Postgres, Redis, and Orca services)\n")
+			fmt.Fprintf(os.Stderr, "Usage: orca start [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Start the Orca stack (Postgres, Redis, and Orca services)\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			startCmd.PrintDefaults()
 		}
 
 		startCmd.Parse(os.Args[2:])
 
 		if startCmd.NArg() > 0 && (startCmd.Arg(0) == "help" || startCmd.Arg(0) == "-h") {
 			startCmd.Usage()
-			os.Exit(0)
+			exit(0)
 		}
 
 		if startCmd.NArg() > 0 {
-			fmt.Println()
-			fmt.Println(renderError(fmt.Sprintf("Unknown argument: %s", startCmd.Arg(0))))
-			fmt.Println("Run 'orca start help' for usage information.")
-			fmt.Println()
-			os.Exit(1)
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", startCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca start help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		config := loadProjectConfig(*configPath)
+		// without orca.json the defaults are used, and there is no project to lock
+		_, statErr := os.Stat(*configPath)
+		configLoaded := statErr == nil
+		if err := setReadinessWait(config.Startup, *startTimeout, *startPollInterval); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		pgConfig := config.Postgres
+		if pgConfig == nil {
+			pgConfig = &PostgresConfig{}
+		}
+		if *pgSharedBuffers != "" {
+			pgConfig.SharedBuffers = *pgSharedBuffers
+		}
+		if *pgMaxConnections > 0 {
+			pgConfig.MaxConnections = *pgMaxConnections
+		}
+		if *pgMaxWalSize != "" {
+			pgConfig.MaxWalSize = *pgMaxWalSize
+		}
+
+		if *timezone != "" {
+			config.Timezone = *timezone
+		}
+		var tz string
+		if config.Timezone != "" {
+			resolved, err := resolveTimezone(config.Timezone)
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			tz = resolved
+			if pgConfig.Settings == nil {
+				pgConfig.Settings = map[string]string{}
+			}
+			// explicit settings take precedence
+			for _, key := range []string{"timezone", "log_timezone"} {
+				if _, ok := pgConfig.Settings[key]; !ok {
+					pgConfig.Settings[key] = tz
+				}
+			}
+		}
+
+		redisConfig := config.Redis
+		if redisConfig == nil {
+			redisConfig = &RedisConfig{}
+		}
+		if *redisPersistence != "" {
+			redisConfig.Persistence = *redisPersistence
+		}
+		if *redisMaxMemory != "" {
+			redisConfig.MaxMemory = *redisMaxMemory
+		}
+		if *redisEvictionPolicy != "" {
+			redisConfig.EvictionPolicy = *redisEvictionPolicy
+		}
+		if err := redisConfig.validate(); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		coreConfig := config.Core
+		if coreConfig == nil {
+			coreConfig = &CoreConfig{}
+		}
+		// env files from orca.json are relative to it, while the flag is relative to the working directory
+		envBaseDir := filepath.Dir(*configPath)
+		if *envFile != "" {
+			coreConfig.EnvFile = *envFile
+			envBaseDir = "."
+		}
+		if *logLevel != "" {
+			coreConfig.LogLevel = strings.ToLower(*logLevel)
+		}
+		if err := coreConfig.validate(); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		orcaEnv, err := coreEnv(coreConfig, envBaseDir)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if tz != "" {
+			orcaEnv = append(orcaEnv, "TZ="+tz)
+		}
+
+		restartConfig := config.Restart
+		if restartConfig == nil {
+			restartConfig = map[string]string{}
+		}
+		if err := validateRestartPolicies(restartConfig); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if *restart != "" {
+			policies, err := parseRestartPolicies(*restart)
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			maps.Copy(restartConfig, policies)
+		}
+
+		var canary string
+		if *canaryVersion != "" {
+			canary, err = canaryImage(*canaryVersion)
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+
+		companions, err := resolveCompanionServices(
+			append(config.Services, strings.Split(*withServices, ",")...),
+		)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		lockPath := lockFilePath(*configPath)
+		var lock *LockFile
+		if *locked {
+			lock, err = readLockFile(lockPath)
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			companions, err = applyLockFile(lock, companions)
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
 		}
 
 		checkDockerInstalled()
+		if err := lockStack("start"); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		if *processorsOnly {
+			address := cmp.Or(*startConnStr, config.OrcaConnectionString)
+			if address == "" {
+				printError("-processors-only requires -connStr or orcaConnectionString in orca.json")
+				exit(1)
+			}
+			transportCreds, err := coreTransportCredentials(*startSecure, *startCACert)
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr)
+			if err := startProcessorsOnly(config, address, transportCreds); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr)
+			fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf(" Processors running against the core at %s.", address)))
+			if address != config.OrcaConnectionString {
+				fmt.Fprintf(os.Stderr, "Have `orca run` and `orca deploy` use it too with `orca config set orcaConnectionString %s`\n", address)
+			}
+			fmt.Fprintln(os.Stderr)
+			break
+		}
+
+		// the core is published on the port init recorded, so that orca.json stays valid.
+		// Other stack projects get an assigned port, as orca.json does not point at them.
+		orcaPort, err := config.orcaHostPort()
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if orcaPort > 0 && config.StackProject != stackProject {
+			fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf(
+				"%s points at the core of project %s, not %s. The core is published on an assigned port, run `orca --project %s init` to point %s at it.",
+				*configPath, projectLabelValue(config.StackProject), projectLabelValue(stackProject), projectLabelValue(stackProject), *configPath,
+			)))
+			orcaPort = 0
+		}
+		if orcaPort > 0 {
+			if err := checkOrcaPort(orcaPort); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+		if config.ProcessorPort > 0 && !isPortAvailable(config.ProcessorPort) {
+			fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf(
+				"Processor port %d from orca.json is in use. That is expected while your processor runs, otherwise free it before starting the processor.",
+				config.ProcessorPort,
+			)))
+		}
+
+		if lock != nil {
+			warnOnImageDrift(lock)
+		}
 
-		fmt.Println()
+		fmt.Fprintln(os.Stderr)
 		networkName := createNetworkIfNotExists()
-		fmt.Println()
+		fmt.Fprintln(os.Stderr)
 
-		startPostgres(networkName)
-		fmt.Println()
+		startPostgres(networkName, pgConfig)
+		fmt.Fprintln(os.Stderr)
 
-		startRedis(networkName)
-		fmt.Println()
+		startRedis(networkName, redisConfig)
+		fmt.Fprintln(os.Stderr)
 
 		// check for postgres instance running first
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
-		defer cancel()
-		err := waitForPgReady(ctx, pgContainerName, time.Millisecond*500)
-		if err != nil {
-			fmt.Println(
-				renderError(
-					fmt.Sprintf("Issue waiting for Postgres store to start: %v", err.Error()),
-				),
-			)
-			os.Exit(1)
+		if err := waitForStore(); err != nil {
+			printError(fmt.Sprintf("Issue waiting for Postgres store to start: %v", err.Error()))
+			exit(1)
+		}
+		if coreConfig.LogLevel != "" {
+			if err := removeOrcaIfEnvChanged(coreConfig.logLevelEnv()); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+		if orcaPort > 0 {
+			if err := removeOrcaIfPortChanged(orcaPort); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+		coreStarted := time.Now()
+		startOrca(networkName, orcaEnv, orcaPort)
+		fmt.Fprintln(os.Stderr, "Waiting for Orca core to become ready...")
+		if err := followCoreStartup(orcaContainerName, coreStarted); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr, successStyle.Render("Orca core is ready"))
+		fmt.Fprintln(os.Stderr)
+
+		if err := checkCoreCompatibility(*strict); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		if len(restartConfig) > 0 {
+			if err := applyRestartPolicies(restartConfig); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+
+		for _, svc := range companions {
+			startCompanion(networkName, svc)
+			fmt.Fprintln(os.Stderr)
+		}
+
+		if err := recordPublishedPorts(append(append([]string{}, orcaContainers...), companionContainers()...)); err != nil {
+			fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Could not record the published ports: %v", err)))
+		}
+
+		// record the images this stack runs next to orca.json, unless it was started from them
+		if lock == nil && configLoaded {
+			previous, _ := readLockFile(lockPath)
+			newLock, err := buildLockFile(companions, previous)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Could not write %s: %v", lockFileName, err)))
+			} else if err := writeLockFile(lockPath, newLock); err != nil {
+				fmt.Fprintln(os.Stderr, warningStyle.Render(err.Error()))
+			} else {
+				fmt.Fprintf(os.Stderr, "Image digests recorded in %s\n", lockPath)
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+
+		if canary != "" {
+			canaryPort, err := startCanary(canary, networkName, pgConfig, orcaEnv)
+			if err != nil {
+				printError(fmt.Sprintf("The stack is running, but the canary failed: %v", err))
+				fmt.Fprintf(os.Stderr, "Remove the canary with `orca stop -canary`.\n")
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr, successStyle.Render(fmt.Sprintf("Canary core %s is ready on localhost:%d", canary, canaryPort)))
+			fmt.Fprintf(os.Stderr, "It runs against a copy of the store, remove it with `orca stop -canary`.\n")
+			fmt.Fprintln(os.Stderr)
 		}
-		startOrca(networkName)
-		fmt.Println()
 
-		fmt.Println(renderSuccess(" Orca stack started successfully."))
-		fmt.Println()
+		fmt.Fprintln(os.Stderr, renderSuccess(" Orca stack started successfully."))
+		fmt.Fprintln(os.Stderr)
+
+		if *supervise {
+			// supervising runs until interrupted, so other commands may change the stack meanwhile
+			unlockStack()
+			watchContainers(time.Second*5, *maxRestarts)
+		}
 
 	case "stop":
+		stopCanary := stopCmd.Bool("canary", false, "Only remove the canary core of `orca start -canary` and its copy of the store")
+
 		stopCmd.Usage = func() {
-			fmt.Fprintf(os.Stderr, "Usage: orca stop\n\n")
-			fmt.Fprintf(os.Stderr, "Stop all running Orca containers\n")
+			fmt.Fprintf(os.Stderr, "Usage: orca stop [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Stop all running Orca containers\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			stopCmd.PrintDefaults()
 		}
 
 		stopCmd.Parse(os.Args[2:])
 
 		if stopCmd.NArg() > 0 && (stopCmd.Arg(0) == "help" || stopCmd.Arg(0) == "-h") {
 			stopCmd.Usage()
-			os.Exit(0)
+			exit(0)
 		}
 
 		if stopCmd.NArg() > 0 {
-			fmt.Println()
-			fmt.Println(renderError(fmt.Sprintf("Unknown argument: %s", stopCmd.Arg(0))))
-			fmt.Println("Run 'orca stop help' for usage information.")
-			fmt.Println()
-			os.Exit(1)
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", stopCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca stop help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
 		}
 
 		checkDockerInstalled()
+		if err := lockStack("stop"); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
 
-		fmt.Println()
+		fmt.Fprintln(os.Stderr)
+		if *stopCanary {
+			if err := removeCanary(); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr, renderSuccess(" Canary removed."))
+			fmt.Fprintln(os.Stderr)
+			break
+		}
 		stopContainers()
 
-		fmt.Println()
-		fmt.Println(renderSuccess(" All containers stopped."))
-		fmt.Println()
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, renderSuccess(" All containers stopped."))
+		fmt.Fprintln(os.Stderr)
 
 	case "status":
+		statusOutput := statusCmd.String("o", "text", "Output format - text|json|template=<go-template>, e.g. template='{{.Orca.Port}}'")
+		statusJSON := statusCmd.Bool("json", false, "Shorthand for -o json")
+		statusAllProjects := statusCmd.Bool("all-projects", false, "Show the deployment and registration of each project listed in the nearest orca.workspace.json")
+		statusTimeout := statusCmd.Duration("timeout", time.Second*10, "Timeout for fetching the registry from the core with -all-projects, and for each health probe with -deep")
+		statusDeep := statusCmd.Bool("deep", false, "Also probe that Postgres, Redis and the core answer requests, running the probes concurrently")
+
 		statusCmd.Usage = func() {
-			fmt.Fprintf(os.Stderr, "Usage: orca status\n\n")
-			fmt.Fprintf(os.Stderr, "Show the status of all Orca components\n")
+			fmt.Fprintf(os.Stderr, "Usage: orca status [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Show the status of all Orca components\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			statusCmd.PrintDefaults()
 		}
 
 		statusCmd.Parse(os.Args[2:])
 
 		if statusCmd.NArg() > 0 && (statusCmd.Arg(0) == "help" || statusCmd.Arg(0) == "-h") {
 			statusCmd.Usage()
-			os.Exit(0)
+			exit(0)
 		}
 
 		if statusCmd.NArg() > 0 {
-			fmt.Println()
-			fmt.Println(renderError(fmt.Sprintf("Unknown argument: %s", statusCmd.Arg(0))))
-			fmt.Println("Run 'orca status help' for usage information.")
-			fmt.Println()
-			os.Exit(1)
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", statusCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca status help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		if *statusJSON {
+			*statusOutput = "json"
+		}
+		if err := validateOutputFormat(*statusOutput); err != nil {
+			printError(err.Error())
+			exit(1)
 		}
 
 		checkDockerInstalled()
 
-		fmt.Println()
+		if *statusAllProjects {
+			statuses, err := collectWorkspaceStatus(*statusTimeout)
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			if *statusOutput != "text" {
+				if err := renderOutput(os.Stdout, statuses, *statusOutput); err != nil {
+					printError(err.Error())
+					exit(1)
+				}
+				break
+			}
+			showWorkspaceStatus(os.Stdout, statuses)
+			break
+		}
+
+		if *statusOutput != "text" {
+			status := collectStatus()
+			if *statusDeep {
+				status.Health = componentHealthOf(runHealthChecks(*statusTimeout))
+			}
+			if err := renderOutput(os.Stdout, status, *statusOutput); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			break
+		}
+
+		fmt.Fprintln(os.Stderr)
 		showStatus()
-		fmt.Println()
+		if *statusDeep {
+			fmt.Println()
+			showHealth(os.Stdout, componentHealthOf(runHealthChecks(*statusTimeout)))
+		}
+		fmt.Fprintln(os.Stderr)
 
 	case "destroy":
 		destroyCmd.Usage = func() {
@@ -208,24 +718,29 @@ func main() {
 
 		if destroyCmd.NArg() > 0 && (destroyCmd.Arg(0) == "help" || destroyCmd.Arg(0) == "-h") {
 			destroyCmd.Usage()
-			os.Exit(0)
+			exit(0)
 		}
 
 		if destroyCmd.NArg() > 0 {
-			fmt.Println()
-			fmt.Println(renderError(fmt.Sprintf("Unknown argument: %s", destroyCmd.Arg(0))))
-			fmt.Println("Run 'orca destroy help' for usage information.")
-			fmt.Println()
-			os.Exit(1)
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", destroyCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca destroy help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
 		}
 
 		checkDockerInstalled()
-		fmt.Println()
+		if err := lockStack("destroy"); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr)
 		destroy()
-		fmt.Println()
+		fmt.Fprintln(os.Stderr)
 
 	case "init":
 		projectNameFlag := initCmd.String("name", "", "Project name (defaults to current directory name)")
+		wslPortProxy := initCmd.Bool("wsl-portproxy", false, "Under WSL2, forward the processor port from Windows into this distribution (requires an elevated prompt)")
 
 		initCmd.Usage = func() {
 			fmt.Fprintf(os.Stderr, "Usage: orca init [options]\n\n")
@@ -238,37 +753,32 @@ func main() {
 
 		if initCmd.NArg() > 0 && (initCmd.Arg(0) == "help" || initCmd.Arg(0) == "-h") {
 			initCmd.Usage()
-			os.Exit(0)
+			exit(0)
 		}
 
 		if initCmd.NArg() > 0 {
-			fmt.Println()
-			fmt.Println(renderError(fmt.Sprintf("Unknown argument: %s", initCmd.Arg(0))))
-			fmt.Println("Run 'orca init help' for usage information.")
-			fmt.Println()
-			os.Exit(1)
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", initCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca init help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
 		}
 
-		type OrcaConfigFile struct {
-			ProjectName               string `json:"projectName"`
-			OrcaConnectionString      string `json:"orcaConnectionString"`
-			ProcessorPort             int    `json:"processorPort"`
-			ProcessorConnectionString string `json:"processorConnectionString"`
-		}
-		preferredProcessorPort := 5377
+		preferredProcessorPort := defaultProcessorPort
 
 		orcaStatus := getContainerStatus(orcaContainerName)
 		if orcaStatus != "running" {
-			fmt.Println(renderError("Orca not running. Cannot initialise configuration file. Start orca locally with the command `orca start`"))
-			os.Exit(1)
+			printError("Orca not running. Cannot initialise configuration file. Start orca locally with the command `orca start`")
+			exit(1)
 		}
 
 		orcaPort := getContainerPort(orcaContainerName, orcaInternalPort)
 		processorPort := findAvailablePort(preferredProcessorPort)
+		detectedRuntime := detectContainerRuntime()
 
 		if processorPort < 0 {
-			fmt.Println(renderError("Could not find an available port to use for the processor"))
-			os.Exit(1)
+			printError("Could not find an available port to use for the processor")
+			exit(1)
 		}
 		var projectName string
 		if *projectNameFlag != "" {
@@ -277,8 +787,8 @@ func main() {
 			// infer from parent directory name
 			cwd, err := os.Getwd()
 			if err != nil {
-				fmt.Println(renderError(fmt.Sprintf("Failed to get current directory: %v", err)))
-				os.Exit(1)
+				printError(fmt.Sprintf("Failed to get current directory: %v", err))
+				exit(1)
 			}
 			projectName = toCamelCase(filepath.Base(cwd))
 		}
@@ -287,65 +797,84 @@ func main() {
 			ProjectName:               projectName,
 			OrcaConnectionString:      fmt.Sprintf("localhost:%s", orcaPort),
 			ProcessorPort:             processorPort,
-			ProcessorConnectionString: fmt.Sprintf("host.docker.internal:%d", processorPort),
+			ProcessorConnectionString: fmt.Sprintf("%s:%d", detectedRuntime.HostAlias, processorPort),
+			StackProject:              stackProject,
 		}
 
-		configPath := "orca.json"
+		configPath := defaultConfigPath
 
 		if _, err := os.Stat(configPath); err == nil {
-			existingData, err := os.ReadFile(configPath)
-			if err != nil {
-				fmt.Println(renderError(fmt.Sprintf("Failed to read existing orca.json: %v", err)))
-				os.Exit(1)
+			// settings the CLI does not know would be lost when the file is rewritten
+			if problems, err := validateProjectConfigFile(configPath); err != nil || len(problems) > 0 {
+				if err == nil {
+					err = schemaProblemsError(configPath, problems)
+				}
+				printError(fmt.Sprintf("Failed to load existing orca.json: %v", err))
+				exit(1)
 			}
-
-			var existingConfig OrcaConfigFile
-			err = json.Unmarshal(existingData, &existingConfig)
+			existingConfig, err := readProjectConfig(configPath)
 			if err != nil {
-				fmt.Println(renderError(fmt.Sprintf("Failed to parse existing orca.json: %v", err)))
-				os.Exit(1)
+				printError(fmt.Sprintf("Failed to load existing orca.json: %v", err))
+				exit(1)
 			}
 
+			// keep any settings that init does not manage, e.g. stack tuning
+			managed := newConfig
+			newConfig = *existingConfig
+			newConfig.ProjectName = managed.ProjectName
+			newConfig.OrcaConnectionString = managed.OrcaConnectionString
+			newConfig.ProcessorPort = managed.ProcessorPort
+			newConfig.ProcessorConnectionString = managed.ProcessorConnectionString
+			newConfig.StackProject = managed.StackProject
+
 			// compare configurations
 			if existingConfig.OrcaConnectionString != newConfig.OrcaConnectionString ||
 				existingConfig.ProcessorPort != newConfig.ProcessorPort ||
 				existingConfig.ProjectName != newConfig.ProjectName ||
-				existingConfig.ProcessorConnectionString != newConfig.ProcessorConnectionString {
-				fmt.Println("Existing orca.json found with different configuration:")
-				fmt.Printf("  Current - Connection: %s, Port: %d, Name: %s, ProcessorConnection: %s\n", existingConfig.OrcaConnectionString, existingConfig.ProcessorPort, existingConfig.ProjectName, existingConfig.ProcessorConnectionString)
-				fmt.Printf("  New     - Connection: %s, Port: %d, Name: %s, ProcessorConnection: %s\n", newConfig.OrcaConnectionString, newConfig.ProcessorPort, newConfig.ProjectName, newConfig.ProcessorConnectionString)
-				fmt.Print("Do you want to update the configuration? (y/n): ")
+				existingConfig.ProcessorConnectionString != newConfig.ProcessorConnectionString ||
+				existingConfig.StackProject != newConfig.StackProject {
+				fmt.Fprintln(os.Stderr, "Existing orca.json found with different configuration:")
+				fmt.Fprintf(os.Stderr, "  Current - Connection: %s, Port: %d, Name: %s, ProcessorConnection: %s, Stack: %s\n", existingConfig.OrcaConnectionString, existingConfig.ProcessorPort, existingConfig.ProjectName, existingConfig.ProcessorConnectionString, projectLabelValue(existingConfig.StackProject))
+				fmt.Fprintf(os.Stderr, "  New     - Connection: %s, Port: %d, Name: %s, ProcessorConnection: %s, Stack: %s\n", newConfig.OrcaConnectionString, newConfig.ProcessorPort, newConfig.ProjectName, newConfig.ProcessorConnectionString, projectLabelValue(newConfig.StackProject))
+				fmt.Fprint(os.Stderr, "Do you want to update the configuration? (y/n): ")
 
 				var response string
 				fmt.Scanln(&response)
 
 				if strings.ToLower(strings.TrimSpace(response)) != "y" {
-					fmt.Println("Configuration update cancelled.")
-					os.Exit(0)
+					fmt.Fprintln(os.Stderr, "Configuration update cancelled.")
+					exit(0)
 				}
 			} else {
-				fmt.Println("Existing orca.json matches current configuration. No update needed.")
-				os.Exit(0)
+				fmt.Fprintln(os.Stderr, "Existing orca.json matches current configuration. No update needed.")
+				exit(0)
 			}
 		}
 
-		data, err := json.MarshalIndent(&newConfig, "", "    ")
-		if err != nil {
-			fmt.Println(renderError(fmt.Sprintf("Failed to marshal configuration: %v", err)))
-			os.Exit(1)
-		}
-
-		err = os.WriteFile(configPath, data, 0644)
-		if err != nil {
-			fmt.Println(renderError(fmt.Sprintf("Failed to write orca.json: %v", err)))
-			os.Exit(1)
+		if err := writeProjectConfig(configPath, &newConfig); err != nil {
+			printError(err.Error())
+			exit(1)
 		}
 
-		fmt.Println(successStyle.Render("orca.json created successfully!"))
+		fmt.Fprintln(os.Stderr, successStyle.Render("orca.json created successfully!"))
 		fmt.Printf("Project name: %s\n", newConfig.ProjectName)
 		fmt.Printf("Orca connection string: %s\n", newConfig.OrcaConnectionString)
 		fmt.Printf("Processor port: %d\n", newConfig.ProcessorPort)
 		fmt.Printf("Processor connection string: %s\n", newConfig.ProcessorConnectionString)
+		for _, note := range detectedRuntime.Notes {
+			fmt.Fprintln(os.Stderr, warningStyle.Render("Note: ")+note)
+		}
+
+		if *wslPortProxy {
+			if !isWSL() {
+				printError("-wsl-portproxy only applies when running inside WSL2")
+				exit(1)
+			}
+			if err := setupWSLPortProxy(newConfig.ProcessorPort); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
 
 	case "sync":
 		outDir := syncCmd.String("out", "./", "Output directory for Orca registry data")
@@ -353,12 +882,31 @@ func main() {
 		tgtSdk := syncCmd.String("sdk", "", "The SDK to generate type stubs for - python|go|typescript|zig|rust (defaults to inferring from the environment)")
 		secure := syncCmd.Bool("secure", false, "Set to connect to Orca core with System Default Root CA credentials (via TLS). Only use when using a custom Orca connection string that supports TLS")
 		caCert := syncCmd.String("caCert", "", "Path to custom CA certificate file (PEM format) for TLS verification")
-		configPath := syncCmd.String("config", "orca.json", "Path to orca.json configuration file. Used to get the project name.")
+		configPath := syncCmd.String("config", findProjectConfig(), "Path to orca.json configuration file. Used to get the project name.")
 		projectNameOverride := syncCmd.String("projectName", "", "Specify a project to exclude stubs from. Defaults the `orca.json`, or '' if it can't be found.")
+		syncStrict := syncCmd.Bool("strict", false, "Fail instead of warning when the local core is not compatible with this CLI")
+		compress := syncCmd.Bool("compress", false, "Write the registry gzipped, as registry.json.gz and registry.pb.gz")
+		deadline := syncCmd.Duration("deadline", 0, "How long fetching the registry may take before giving up, 0 for no limit")
+		registryFormat := syncCmd.String("format", "json", "Format to write the registry in - json writes registry.json, pb writes registry.pb as well")
+		layout := syncCmd.String("layout", "", "Layout of the python stubs - flat generates registry/algorithms.py, package generates an orca_stubs package with a module per processor (defaults to the layout of the existing stubs, or flat)")
+		deps := syncCmd.String("deps", "", "Also pin the orca-python version compatible with the core - pyproject writes pyproject.toml, requirements writes requirements.txt")
+		force := syncCmd.Bool("force", false, "Overwrite generated stubs even if they were edited outside their user code regions since the last sync")
+		allProjects := syncCmd.Bool("all-projects", false, "Run in each project listed in the nearest orca.workspace.json, with paths relative to the project")
+
+		addGRPCFlags(syncCmd)
 
 		syncCmd.Usage = func() {
 			fmt.Fprintf(os.Stderr, "Usage: orca sync [options]\n\n")
 			fmt.Fprintf(os.Stderr, "Sync Orca registry data to local directory\n\n")
+			fmt.Fprintf(os.Stderr, "registry.pb holds the registry as a serialized InternalState message, keeping the\n")
+			fmt.Fprintf(os.Stderr, "field presence and enum values that JSON loses. It starts with a header: the bytes\n")
+			fmt.Fprintf(os.Stderr, "%q, a format version byte (%d), and the full message name as a uvarint length\n", registrySnapshotMagic, registrySnapshotVersion)
+			fmt.Fprintf(os.Stderr, "followed by the name. The message fills the rest of the file.\n\n")
+			fmt.Fprintf(os.Stderr, "The registry is fetched gzipped from cores that support it.\n\n")
+			fmt.Fprintf(os.Stderr, "Code between `# orca:begin-user-code` and `# orca:end-user-code` markers in the\n")
+			fmt.Fprintf(os.Stderr, "stubs is kept when they are regenerated. Stubs edited elsewhere are not overwritten\n")
+			fmt.Fprintf(os.Stderr, "without -force, going by the hashes kept in .orca/manifest.json. Changing -layout\n")
+			fmt.Fprintf(os.Stderr, "moves user code into the new stubs and removes the old ones.\n\n")
 			fmt.Fprintf(os.Stderr, "Options:\n")
 			syncCmd.PrintDefaults()
 		}
@@ -367,22 +915,30 @@ func main() {
 
 		if syncCmd.NArg() > 0 && (syncCmd.Arg(0) == "help" || syncCmd.Arg(0) == "-h") {
 			syncCmd.Usage()
-			os.Exit(0)
+			exit(0)
 		}
 
 		if syncCmd.NArg() > 0 {
-			fmt.Println()
-			fmt.Println(renderError(fmt.Sprintf("Unknown argument: %s", syncCmd.Arg(0))))
-			fmt.Println("Run 'orca sync help' for usage information.")
-			fmt.Println()
-			os.Exit(1)
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", syncCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca sync help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
 		}
-
-		type OrcaConfigFile struct {
-			ProjectName               string `json:"projectName"`
-			OrcaConnectionString      string `json:"orcaConnectionString"`
-			ProcessorPort             int    `json:"processorPort"`
-			ProcessorConnectionString string `json:"processorConnectionString"`
+		if *allProjects {
+			exit(runAllProjects("sync", os.Args[2:]))
+		}
+		if !slices.Contains(registryFormats, *registryFormat) {
+			printError(fmt.Sprintf("Invalid format: %s. Must be one of: %s", *registryFormat, strings.Join(registryFormats, ", ")))
+			exit(1)
+		}
+		if *layout != "" && !slices.Contains(stub.Layouts, stub.Layout(*layout)) {
+			printError(fmt.Sprintf("Invalid layout: %s. Must be one of: flat, package", *layout))
+			exit(1)
+		}
+		if *deps != "" && !slices.Contains(pythonDepsFormats, *deps) {
+			printError(fmt.Sprintf("Invalid deps: %s. Must be one of: %s", *deps, strings.Join(pythonDepsFormats, ", ")))
+			exit(1)
 		}
 
 		// parse orca.json configuration
@@ -390,32 +946,25 @@ func main() {
 		if *projectNameOverride != "" {
 			// use the command-line override if provided
 			projectName = *projectNameOverride
-			fmt.Printf("Excluding algorithms from project name: '%s'\n", projectName)
+			fmt.Fprintf(os.Stderr, "Excluding algorithms from project name: '%s'\n", projectName)
 		} else {
 			// try to load from config file
 			if _, err := os.Stat(*configPath); err == nil {
-				fmt.Println("Found config file")
-				configData, err := os.ReadFile(*configPath)
-				if err != nil {
-					fmt.Println(renderError(fmt.Sprintf("Failed to read %s: %v", *configPath, err)))
-					os.Exit(1)
-				}
-
-				var config OrcaConfigFile
-				err = json.Unmarshal(configData, &config)
+				fmt.Fprintln(os.Stderr, "Found config file")
+				config, err := readProjectConfig(*configPath)
 				if err != nil {
-					fmt.Println(renderError(fmt.Sprintf("Failed to parse %s: %v", *configPath, err)))
-					os.Exit(1)
+					printError(err.Error())
+					exit(1)
 				}
 
 				projectName = config.ProjectName
 				if projectName != "" {
-					fmt.Printf("Excluding algorithms from project name '%s', as defined in %s\n", projectName, *configPath)
+					fmt.Fprintf(os.Stderr, "Excluding algorithms from project name '%s', as defined in %s\n", projectName, *configPath)
 				}
-			} else if *configPath != "orca.json" {
+			} else if *configPath != defaultConfigPath {
 				// Only error if user explicitly specified a config file that doesn't exist
-				fmt.Println(renderError(fmt.Sprintf("Config file not found: %s", *configPath)))
-				os.Exit(1)
+				printError(fmt.Sprintf("Config file not found: %s", *configPath))
+				exit(1)
 			}
 			// if default orca.json doesn't exist and no override provided, projectName remains empty string
 		}
@@ -440,8 +989,8 @@ func main() {
 
 		if *tgtSdk != "" {
 			if !validSDKs[SDKType(*tgtSdk)] {
-				fmt.Println(renderError(fmt.Sprintf("Invalid SDK: %s. Must be one of: python, go, typescript, zig, rust\n", *tgtSdk)))
-				os.Exit(1)
+				printError(fmt.Sprintf("Invalid SDK: %s. Must be one of: python, go, typescript, zig, rust\n", *tgtSdk))
+				exit(1)
 			}
 
 		} else {
@@ -474,10 +1023,10 @@ func main() {
 				// } else if _, err := os.Stat("./Cargo.toml"); !os.IsNotExist(err) {
 				// 	*tgtSdk = "rust"
 			} else {
-				fmt.Println(renderError("Cannot infer language from environment. Specify it with the `sdk` command. Run `orca sync help` for more information"))
-				os.Exit(1)
+				printError("Cannot infer language from environment. Specify it with the `sdk` command. Run `orca sync help` for more information")
+				exit(1)
 			}
-			fmt.Printf("Inferred sdk langauge as %v\n", *tgtSdk)
+			fmt.Fprintf(os.Stderr, "Inferred sdk langauge as %v\n", *tgtSdk)
 		}
 
 		var connStr string
@@ -487,9 +1036,14 @@ func main() {
 			if orcaStatus == "running" {
 				orcaPort := getContainerPort(orcaContainerName, 3335)
 				connStr = fmt.Sprintf("localhost:%s", orcaPort)
+
+				if err := checkCoreCompatibility(*syncStrict); err != nil {
+					printError(err.Error())
+					exit(1)
+				}
 			} else {
-				fmt.Println(renderError("Orca is not running. Cannot generate registry data. Start Orca with `orca start`"))
-				os.Exit(1)
+				printError("Orca is not running. Cannot generate registry data. Start Orca with `orca start`")
+				exit(1)
 			}
 		} else {
 			connStr = *orcaConnStr
@@ -498,110 +1052,3858 @@ func main() {
 		// fmt.Printf("Generating registry data to %s\n", *outDir)
 
 		if err := os.MkdirAll(*outDir, 0755); err != nil {
-			fmt.Println(renderError(fmt.Sprintf("Failed to create output directory: %v", err)))
-			os.Exit(1)
+			printError(fmt.Sprintf("Failed to create output directory: %v", err))
+			exit(1)
 		}
-		var conn *grpc.ClientConn
-		var err error
-		var transportCreds credentials.TransportCredentials
-
-		if *caCert != "" {
-			// user provided a specific CA file
-			pemServerCA, err := os.ReadFile(*caCert)
-			if err != nil {
-				fmt.Println(renderError(fmt.Sprintf("Failed to read CA certificate: %v", err)))
-				os.Exit(1)
-			}
-
-			certPool := x509.NewCertPool()
-			if !certPool.AppendCertsFromPEM(pemServerCA) {
-				fmt.Println(renderError("Failed to add CA certificate to pool (invalid PEM format?)"))
-				os.Exit(1)
-			}
-
-			config := &tls.Config{
-				RootCAs: certPool,
-			}
-			transportCreds = credentials.NewTLS(config)
-			fmt.Println("Using custom CA certificate for TLS...")
-
-		} else if *secure {
-			// use system default certificates
-			transportCreds = credentials.NewTLS(&tls.Config{})
-			fmt.Println("Using system default CA for TLS...")
-		} else {
-			// insecure connection - good for accessing internal Orca service
-			transportCreds = insecure.NewCredentials()
+		transportCreds, err := coreTransportCredentials(*secure, *caCert)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
 		}
-		conn, err = grpc.NewClient(connStr, grpc.WithTransportCredentials(transportCreds))
+		conn, err := grpc.NewClient(connStr, grpcDialOptions(transportCreds)...)
 		if err != nil {
-			fmt.Println(renderError(fmt.Sprintf("Issue preparing to contact Orca: %v", err)))
-			os.Exit(1)
+			printError(fmt.Sprintf("Issue preparing to contact Orca: %v", err))
+			exit(1)
 		}
 		defer conn.Close()
 
 		orcaCoreClient := pb.NewOrcaCoreClient(conn)
+		exposeCtx, cancelExpose := context.Background(), context.CancelFunc(func() {})
+		if *deadline > 0 {
+			exposeCtx, cancelExpose = context.WithTimeout(exposeCtx, *deadline)
+		}
 		var internalState *pb.InternalState
-		if len(projectName) > 0 {
-			internalState, err = orcaCoreClient.Expose(context.Background(), &pb.ExposeSettings{
-				ExcludeProject: projectName,
-			})
+		exposeSettings := &pb.ExposeSettings{ExcludeProject: projectName}
+		if *secure || *caCert != "" {
+			internalState, err = exposeCompressed(exposeCtx, orcaCoreClient, exposeSettings)
 		} else {
-			internalState, err = orcaCoreClient.Expose(context.Background(), &pb.ExposeSettings{})
+			internalState, err = exposeRegistry(exposeCtx, orcaCoreClient, connStr, exposeSettings)
 		}
+		cancelExpose()
 
 		if err != nil {
-			fmt.Println(renderError(fmt.Sprintf("Issue contacting Orca: %v", err)))
-			os.Exit(1)
+			printError(fmt.Sprintf("Issue contacting Orca: %v", err))
+			if isMessageTooLarge(err) {
+				fmt.Fprintf(os.Stderr, "The registry is larger than -max-recv-size (%s), raise it to sync.\n", grpcDialSettings.MaxRecvSize.String())
+			}
+			exit(1)
 		}
 
-		// TODO: include back in if we need it
+		if err := saveRegistryCache(internalState); err != nil {
+			fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Could not cache the registry for completion: %v", err)))
+		}
 
-		// data, err := json.MarshalIndent(internalState, "", "    ")
-		// if err != nil {
-		// 	fmt.Println(renderError(fmt.Sprintf("Failed to marshal configuration: %v", err)))
-		// 	os.Exit(1)
-		// }
-		//
-		// err = os.WriteFile(filepath.Join(*outDir, "registry.json"), data, 0644)
-		// if err != nil {
-		// 	fmt.Println(renderError(fmt.Sprintf("Failed to write orca.json: %v", err)))
-		// 	os.Exit(1)
-		// }
-		//
-		// fmt.Println(renderSuccess(fmt.Sprintf("registry data generated successfully in %s", filepath.Join(*outDir, "registry.json"))))
+		registryPaths, err := writeRegistryFiles(*outDir, internalState, *registryFormat, *compress)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Registry data written to %s\n", strings.Join(registryPaths, ", "))
 
 		switch SDKType(*tgtSdk) {
 		case SDKPython:
-			fmt.Printf("Generating python stubs to %s\n", *outDir)
-			err := stub.GeneratePythonStubs(internalState, *outDir)
+			fmt.Fprintf(os.Stderr, "Generating python stubs to %s\n", *outDir)
+			orphaned, err := stub.GeneratePythonStubs(internalState, *outDir, stub.GenerateOptions{
+				Layout: stub.Layout(*layout),
+				Force:  *force,
+				OnRender: func(path string, elapsed time.Duration) {
+					recordProfileStep("template", path, elapsed)
+				},
+			})
+			var modified *stub.ModifiedFilesError
+			if errors.As(err, &modified) {
+				printError("Generated stubs were edited since the last sync, refusing to overwrite them:")
+				for _, path := range modified.Files {
+					fmt.Fprintf(os.Stderr, "  %s\n", filepath.Join(*outDir, path))
+				}
+				fmt.Fprintln(os.Stderr, "Move your changes into user code regions, or run sync with -force to discard them.")
+				exit(1)
+			}
 			if err != nil {
-				fmt.Println(renderError(fmt.Sprintf("Issue generating python stubs: %s", err)))
-				os.Exit(1)
+				printError(fmt.Sprintf("Issue generating python stubs: %s", err))
+				exit(1)
+			}
+			for _, path := range orphaned {
+				fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("User code of algorithms no longer in the registry was saved to %s", path)))
+			}
+			fmt.Println(renderStdout(successStyle, fmt.Sprintf("python stubs successfully generated in %s", *outDir)))
+
+			if *deps != "" {
+				coreVersion := orcaImageVersion
+				if *orcaConnStr == "" {
+					if version, err := getCoreVersion(orcaContainerName); err == nil {
+						coreVersion = version
+					}
+				} else {
+					fmt.Fprintf(os.Stderr, "Pinning %s for core %s, the version this CLI supports, as the version of a remote core is unknown\n", pythonSDKPackage, coreVersion)
+				}
+				requirement, err := pythonSDKRequirement(coreVersion)
+				if err != nil {
+					printError(err.Error())
+					exit(1)
+				}
+
+				switch *deps {
+				case "requirements":
+					path := filepath.Join(*outDir, "requirements.txt")
+					if err := writeRequirementsFile(path, requirement); err != nil {
+						printError(fmt.Sprintf("Failed to write %s: %v", path, err))
+						exit(1)
+					}
+					fmt.Println(renderStdout(successStyle, fmt.Sprintf("Pinned %s in %s", requirement, path)))
+				case "pyproject":
+					path := filepath.Join(*outDir, "pyproject.toml")
+					name := projectName
+					if name == "" {
+						if absOut, err := filepath.Abs(*outDir); err == nil {
+							name = filepath.Base(absOut)
+						}
+					}
+					pinned, err := writePyprojectFile(path, name, requirement)
+					if err != nil {
+						printError(fmt.Sprintf("Failed to write %s: %v", path, err))
+						exit(1)
+					}
+					if pinned {
+						fmt.Println(renderStdout(successStyle, fmt.Sprintf("Pinned %s in %s", requirement, path)))
+					} else {
+						fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("%s does not depend on %s yet, add \"%s\" to its [project] dependencies", path, pythonSDKPackage, requirement)))
+					}
+				}
 			}
-			fmt.Println(renderSuccess(fmt.Sprintf("python stubs successfully generated in %s", *outDir)))
 		}
 
 		// projectName variable is now available for use
 		// If no config file exists and no override provided, it will be an empty string
 		_ = projectName // You can use this variable as needed
 
-	case "help":
-		fmt.Println()
-		flag.Usage()
-		fmt.Println()
-		os.Exit(0)
-	case "-h":
-		fmt.Println()
-		flag.Usage()
-		fmt.Println()
-		os.Exit(0)
+	case "stub":
+		outDir := stubCmd.String("out", "./", "Directory the stubs were generated in, as given to `orca sync -out`")
+		registryPath := stubCmd.String("registry", "", "Verify against a registry file written by sync (registry.json, registry.pb or gzipped) instead of the core")
+		coreAddress := stubCmd.String("core", "", "Address of the Orca core (defaults to the local stack)")
+		configPath := stubCmd.String("config", findProjectConfig(), "Path to orca.json configuration file. Used to get the project name excluded from the stubs.")
+		timeout := stubCmd.Duration("timeout", time.Second*10, "Timeout for fetching the registry from the core")
+		stubOutput := stubCmd.String("o", "text", "Output format - text|json|template=<go-template>")
+		stubJSON := stubCmd.Bool("json", false, "Shorthand for -o json")
+		allProjects := stubCmd.Bool("all-projects", false, "Run in each project listed in the nearest orca.workspace.json, with paths relative to the project")
+		addGRPCFlags(stubCmd)
 
-	default:
-		fmt.Println()
-		fmt.Println(renderError(fmt.Sprintf("Unknown subcommand: %s", os.Args[1])))
-		fmt.Println("Run 'orca help' for usage information.")
-		fmt.Println()
-		os.Exit(1)
-	}
+		stubCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca stub [options] verify\n\n")
+			fmt.Fprintf(os.Stderr, "Check the stubs generated by `orca sync` against the registry: every algorithm's\n")
+			fmt.Fprintf(os.Stderr, "hash must match, and every file must be as sync would generate it now. Exits 1 on\n")
+			fmt.Fprintf(os.Stderr, "any mismatch, so that CI catches edited stubs and registries that moved on.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			stubCmd.PrintDefaults()
+		}
+
+		stubCmd.Parse(os.Args[2:])
+
+		if stubCmd.NArg() == 0 || stubCmd.Arg(0) == "help" || stubCmd.Arg(0) == "-h" {
+			stubCmd.Usage()
+			exit(0)
+		}
+
+		if stubCmd.NArg() != 1 || stubCmd.Arg(0) != "verify" {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", strings.Join(stubCmd.Args(), " ")))
+			fmt.Fprintln(os.Stderr, "Run 'orca stub help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		if *allProjects {
+			exit(runAllProjects("stub", os.Args[2:]))
+		}
+		if *stubJSON {
+			*stubOutput = "json"
+		}
+		if err := validateOutputFormat(*stubOutput); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		var state *pb.InternalState
+		var err error
+		if *registryPath != "" {
+			state, err = readRegistryFile(*registryPath)
+		} else {
+			state, err = fetchStubRegistry(*coreAddress, *configPath, *timeout)
+		}
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		mismatches, err := stub.VerifyPythonStubs(state, *outDir)
+		if err != nil {
+			printError(fmt.Sprintf("Issue verifying stubs: %v", err))
+			exit(1)
+		}
+		if *stubOutput != "text" {
+			if mismatches == nil {
+				mismatches = []stub.StubMismatch{}
+			}
+			if err := renderOutput(os.Stdout, mismatches, *stubOutput); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		} else {
+			showStubMismatches(os.Stdout, mismatches)
+		}
+		if len(mismatches) > 0 {
+			exit(1)
+		}
+
+	case "processor", "processors":
+		configPath := processorCmd.String("config", findProjectConfig(), "Path to orca.json configuration file")
+		processorName := processorCmd.String("name", "", "Name to register the processor under (defaults to projectName in orca.json)")
+		runtime := processorCmd.String("runtime", "", fmt.Sprintf("Runtime of the processor (defaults to its current registration, or %s)", defaultProcessorRuntime))
+		coreAddress := processorCmd.String("core", "", "Address of the Orca core (defaults to the local stack)")
+		timeout := processorCmd.Duration("timeout", time.Second*10, "Timeout for requests to the core and each processor")
+		processorOutput := processorCmd.String("o", "text", "Output format of status - text|json|template=<go-template>")
+
+		addGRPCFlags(processorCmd)
+
+		processorCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca processor [options] <register|status>\n\n")
+			fmt.Fprintf(os.Stderr, "register  Register the project's processor with the core, using the name and\n")
+			fmt.Fprintf(os.Stderr, "          connection string in orca.json. This tests the handshake before the\n")
+			fmt.Fprintf(os.Stderr, "          processor runs, and refreshes a stale registration keeping its algorithms.\n")
+			fmt.Fprintf(os.Stderr, "status    Call the health check of every registered processor and report whether\n")
+			fmt.Fprintf(os.Stderr, "          it is reachable. Exits 1 when any processor is not serving.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			processorCmd.PrintDefaults()
+		}
+
+		processorCmd.Parse(os.Args[2:])
+
+		if processorCmd.NArg() == 0 || processorCmd.Arg(0) == "help" || processorCmd.Arg(0) == "-h" {
+			processorCmd.Usage()
+			exit(0)
+		}
+
+		action := processorCmd.Arg(0)
+		if processorCmd.NArg() != 1 || (action != "register" && action != "status") {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", strings.Join(processorCmd.Args(), " ")))
+			fmt.Fprintln(os.Stderr, "Run 'orca processor help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		if err := validateOutputFormat(*processorOutput); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		address := *coreAddress
+		if address == "" {
+			checkDockerInstalled()
+			var err error
+			if address, err = localCoreAddress(); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+
+		if action == "status" {
+			statuses, err := collectProcessorStatus(address, *timeout)
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			if *processorOutput != "text" {
+				if err := renderOutput(os.Stdout, statuses, *processorOutput); err != nil {
+					printError(err.Error())
+					exit(1)
+				}
+			} else if len(statuses) == 0 {
+				fmt.Fprintln(os.Stderr, "No processors are registered. Run your processor, or `orca processor register`")
+			} else {
+				showProcessorStatus(os.Stdout, statuses)
+			}
+			for _, status := range statuses {
+				if status.Status != "serving" {
+					exit(1)
+				}
+			}
+			break
+		}
+
+		config := loadProjectConfig(*configPath)
+		fmt.Fprintln(os.Stderr)
+		if err := registerProcessor(address, config, *processorName, *runtime, *timeout); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr)
+
+	case "call":
+		version := callCmd.String("version", "", "Version of the algorithm, when several are registered")
+		processorName := callCmd.String("processor", "", "Processor to run the algorithm on, when several register it")
+		windowFile := callCmd.String("window", "", "File holding the window as JSON, or - for stdin. Flags override its fields")
+		from := callCmd.String("from", "", "Start of the window, RFC 3339 (defaults to an hour before -to)")
+		to := callCmd.String("to", "", "End of the window, RFC 3339 (defaults to now)")
+		origin := callCmd.String("origin", "", fmt.Sprintf("Origin of the window (defaults to %s)", callOrigin))
+		metadata := metadataFlag{}
+		callCmd.Var(metadata, "meta", "Window metadata as key=value, repeatable. JSON values keep their type")
+		coreAddress := callCmd.String("core", "", "Address of the Orca core (defaults to the local stack)")
+		timeout := callCmd.Duration("timeout", time.Minute, "Timeout for the call")
+
+		addGRPCFlags(callCmd)
+
+		callCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca call [options] <algorithm>\n\n")
+			fmt.Fprintf(os.Stderr, "Build a window of the algorithm's window type, run the algorithm on it on its\n")
+			fmt.Fprintf(os.Stderr, "processor, and print the result as JSON. Dependencies are not run. e.g.\n\n")
+			fmt.Fprintf(os.Stderr, "  orca call -from 2025-01-01T00:00:00Z -to 2025-01-02T00:00:00Z -meta asset_id=7 DailyMean\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			callCmd.PrintDefaults()
+		}
+
+		callCmd.Parse(os.Args[2:])
+
+		if callCmd.NArg() > 0 && (callCmd.Arg(0) == "help" || callCmd.Arg(0) == "-h") {
+			callCmd.Usage()
+			exit(0)
+		}
+
+		if callCmd.NArg() != 1 {
+			fmt.Fprintln(os.Stderr)
+			printError("Expected exactly one algorithm name")
+			fmt.Fprintln(os.Stderr, "Run 'orca call help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		var fromTime, toTime time.Time
+		for _, value := range []struct {
+			flag   string
+			raw    string
+			parsed *time.Time
+		}{{"-from", *from, &fromTime}, {"-to", *to, &toTime}} {
+			if value.raw == "" {
+				continue
+			}
+			parsed, err := time.Parse(time.RFC3339, value.raw)
+			if err != nil {
+				printError(fmt.Sprintf("Invalid %s time %q, expected RFC 3339 such as 2025-01-01T00:00:00Z", value.flag, value.raw))
+				exit(1)
+			}
+			*value.parsed = parsed
+		}
+
+		var window *pb.Window
+		if *windowFile != "" {
+			var err error
+			if window, err = readWindowFile(*windowFile); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+
+		address := *coreAddress
+		if address == "" {
+			checkDockerInstalled()
+			var err error
+			if address, err = localCoreAddress(); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		conn, client, err := dialCore(address)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		state, err := exposeRegistry(ctx, client, address, &pb.ExposeSettings{})
+		conn.Close()
+		if err != nil {
+			printError(fmt.Sprintf("Issue contacting Orca: %v", err))
+			exit(1)
+		}
+
+		target, err := findCallTarget(state, callCmd.Arg(0), *version, *processorName)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		window, err = buildCallWindow(window, target.Algorithm.GetWindowType(), fromTime, toTime, *origin, metadata)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if err := callAlgorithm(ctx, target, window, os.Stdout); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+	case "schedule":
+		every := scheduleCmd.Duration("every", time.Second*30, "How often to emit a window. Windows end on multiples of it, as with cron")
+		span := scheduleCmd.Duration("span", 0, "How much time each window covers, ending when it is emitted (defaults to -every)")
+		origin := scheduleCmd.String("origin", scheduleOrigin, "Origin of the windows")
+		metadata := metadataFlag{}
+		scheduleCmd.Var(metadata, "meta", "Window metadata as key=value, repeatable. Values may be Go templates, see above")
+		count := scheduleCmd.Int("count", 0, "Stop after emitting this many windows, 0 to run until interrupted")
+		coreAddress := scheduleCmd.String("core", "", "Address of the Orca core (defaults to the local stack)")
+		timeout := scheduleCmd.Duration("timeout", time.Second*10, "Timeout for each request to the core")
+
+		addGRPCFlags(scheduleCmd)
+
+		scheduleCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca schedule [options] <window-type>[@version]\n\n")
+			fmt.Fprintf(os.Stderr, "Emit windows of a window type to the core on a fixed cadence while it runs, so that\n")
+			fmt.Fprintf(os.Stderr, "time based pipelines can be exercised without real telemetry sources.\n\n")
+			fmt.Fprintf(os.Stderr, "Metadata values are Go templates rendered for each window with .Seq (counting from\n")
+			fmt.Fprintf(os.Stderr, "1), .From and .To, and the functions mod, pick, randInt and randFloat. e.g.\n\n")
+			fmt.Fprintf(os.Stderr, "  orca schedule -every 30s -meta 'asset_id={{pick .Seq \"pump-1\" \"pump-2\"}}' FastWindow@1.0.0\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			scheduleCmd.PrintDefaults()
+		}
+
+		scheduleCmd.Parse(os.Args[2:])
+
+		if scheduleCmd.NArg() > 0 && (scheduleCmd.Arg(0) == "help" || scheduleCmd.Arg(0) == "-h") {
+			scheduleCmd.Usage()
+			exit(0)
+		}
+
+		if scheduleCmd.NArg() != 1 {
+			fmt.Fprintln(os.Stderr)
+			printError("Expected exactly one window type")
+			fmt.Fprintln(os.Stderr, "Run 'orca schedule help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		if *every < time.Second {
+			printError("-every must be at least a second")
+			exit(1)
+		}
+		if *span < 0 || *count < 0 {
+			printError("-span and -count cannot be negative")
+			exit(1)
+		}
+
+		templates, fixed, err := parseMetadataTemplates(metadata)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		address := *coreAddress
+		if address == "" {
+			checkDockerInstalled()
+			if address, err = localCoreAddress(); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+		conn, client, err := dialCore(address)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		state, err := exposeRegistry(ctx, client, address, &pb.ExposeSettings{})
+		cancel()
+		if err != nil {
+			printError(fmt.Sprintf("Issue contacting Orca: %v", err))
+			exit(1)
+		}
+		windowType, err := findWindowType(state, scheduleCmd.Arg(0))
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if missing := missingMetadataFields(windowType, metadata); len(missing) > 0 {
+			fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf(
+				"Window type %s %s carries metadata the windows do not set: %s. Set it with -meta key=value",
+				windowType.GetName(), windowType.GetVersion(), strings.Join(missing, ", "),
+			)))
+		}
+
+		schedule := &windowSchedule{
+			WindowType: windowType,
+			Every:      *every,
+			Span:       *span,
+			Origin:     *origin,
+			Templates:  templates,
+			Fixed:      fixed,
+			Count:      *count,
+			Timeout:    *timeout,
+		}
+		fmt.Fprintln(os.Stderr)
+		emitted, failed := schedule.run(client)
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "Emitted %d window(s), %d failed.\n", emitted, failed)
+		if failed > 0 {
+			exit(1)
+		}
+
+	case "import":
+		windowSpec := importCmd.String("window", "", "Window type of the imported windows, as name[@version]. Without it rows name theirs in window_type")
+		fromStdin := importCmd.Bool("stdin", false, "Read line delimited JSON from stdin, importing each line as it arrives")
+		mappingPath := importCmd.String("mapping", "", "JSON file mapping file columns onto window times, origin and metadata")
+		origin := importCmd.String("origin", importOrigin, "Origin of windows whose rows name none")
+		keepGoing := importCmd.Bool("keep-going", false, "Carry on past rows that fail, reporting them at the end")
+		dryRun := importCmd.Bool("dry-run", false, "Check every row maps onto a window without pushing any")
+		coreAddress := importCmd.String("core", "", "Address of the Orca core (defaults to the local stack)")
+		timeout := importCmd.Duration("timeout", time.Second*10, "Timeout for each request to the core")
+
+		addGRPCFlags(importCmd)
+
+		importCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca import [options] <file.csv|file.json>\n")
+			fmt.Fprintf(os.Stderr, "       orca import [options] -stdin\n\n")
+			fmt.Fprintf(os.Stderr, "Push every row of a CSV file, object of a JSON array, or line of JSON read from stdin\n")
+			fmt.Fprintf(os.Stderr, "into the core as a window, e.g. to backfill, to replay data exported from production,\n")
+			fmt.Fprintf(os.Stderr, "or to pipe in events from other tools:\n\n")
+			fmt.Fprintf(os.Stderr, "  cat events.ndjson | orca import -stdin -keep-going\n\n")
+			fmt.Fprintf(os.Stderr, "Without a mapping, rows give the window times in time_from and time_to, its origin\n")
+			fmt.Fprintf(os.Stderr, "in origin, its window type in window_type unless -window is given, and every other\n")
+			fmt.Fprintf(os.Stderr, "column is metadata. A mapping file names the columns:\n\n")
+			fmt.Fprintf(os.Stderr, "  {\n")
+			fmt.Fprintf(os.Stderr, "    \"timeFrom\": \"start\",\n")
+			fmt.Fprintf(os.Stderr, "    \"timeTo\": \"end\",\n")
+			fmt.Fprintf(os.Stderr, "    \"timeFormat\": \"unix\",\n")
+			fmt.Fprintf(os.Stderr, "    \"origin\": \"source\",\n")
+			fmt.Fprintf(os.Stderr, "    \"windowType\": \"type\",\n")
+			fmt.Fprintf(os.Stderr, "    \"metadata\": {\"asset_id\": \"asset\"}\n")
+			fmt.Fprintf(os.Stderr, "  }\n\n")
+			fmt.Fprintf(os.Stderr, "timeFormat is a Go time layout, or unix / unixms for epoch timestamps, and defaults\n")
+			fmt.Fprintf(os.Stderr, "to RFC3339. With metadata set, only the columns it names are imported.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			importCmd.PrintDefaults()
+		}
+
+		importCmd.Parse(os.Args[2:])
+
+		if importCmd.NArg() > 0 && (importCmd.Arg(0) == "help" || importCmd.Arg(0) == "-h") {
+			importCmd.Usage()
+			exit(0)
+		}
+
+		if (*fromStdin && importCmd.NArg() != 0) || (!*fromStdin && importCmd.NArg() != 1) {
+			fmt.Fprintln(os.Stderr)
+			printError("Expected exactly one file, or -stdin")
+			fmt.Fprintln(os.Stderr, "Run 'orca import help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		mapping, err := readImportMapping(*mappingPath)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		var records recordReader
+		if *fromStdin {
+			records = newNDJSONRecordReader(os.Stdin)
+		} else {
+			path := importCmd.Arg(0)
+			file, err := os.Open(path)
+			if err != nil {
+				printError(fmt.Sprintf("Failed to open %s: %v", path, err))
+				exit(1)
+			}
+			defer file.Close()
+			if records, err = openRecordReader(path, file); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+
+		address := *coreAddress
+		if address == "" {
+			checkDockerInstalled()
+			if address, err = localCoreAddress(); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+		conn, client, err := dialCore(address)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		state, err := exposeRegistry(ctx, client, address, &pb.ExposeSettings{})
+		cancel()
+		if err != nil {
+			printError(fmt.Sprintf("Issue contacting Orca: %v", err))
+			exit(1)
+		}
+		var windowType *pb.WindowType
+		if *windowSpec != "" {
+			if windowType, err = findWindowType(state, *windowSpec); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+
+		windows := &windowImport{
+			WindowType: windowType,
+			State:      state,
+			Mapping:    mapping,
+			Origin:     *origin,
+			KeepGoing:  *keepGoing,
+			DryRun:     *dryRun,
+			Timeout:    *timeout,
+		}
+		fmt.Fprintln(os.Stderr)
+		result, err := windows.run(client, records)
+		fmt.Fprintln(os.Stderr)
+		switch {
+		case *dryRun && err == nil:
+			fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("All %d row(s) map onto windows.", result.Imported)))
+		case *dryRun:
+			fmt.Fprintf(os.Stderr, "%d row(s) map onto windows, %d failed.\n", result.Imported, result.Failed)
+		default:
+			fmt.Fprintf(os.Stderr, "Imported %d window(s), %d failed.\n", result.Imported, result.Failed)
+			if result.Untriggered > 0 {
+				fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("%d window(s) triggered no algorithms", result.Untriggered)))
+			}
+		}
+		if err != nil {
+			if !*keepGoing {
+				printError(err.Error())
+			}
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr)
+
+	case "new":
+		name := newCmd.String("name", "", "Processor name (defaults to the directory name)")
+		packageManager := newCmd.String("package-manager", "", "Package manager of the project - uv|poetry|pip (defaults to uv when installed, otherwise pip)")
+
+		newCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca new processor [options] [directory]\n\n")
+			fmt.Fprintf(os.Stderr, "Scaffold a python processor project in the directory, by default the current one.\n")
+			fmt.Fprintf(os.Stderr, "It depends on the orca-python release for the core this CLI supports, managed\n")
+			fmt.Fprintf(os.Stderr, "with uv or poetry through pyproject.toml, or with pip through requirements.txt.\n")
+			fmt.Fprintf(os.Stderr, "orca.json records the package manager for `orca run`. Run `orca init` in the\n")
+			fmt.Fprintf(os.Stderr, "project to connect it to the local stack.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			newCmd.PrintDefaults()
+		}
+
+		if len(os.Args) < 3 || os.Args[2] == "help" || os.Args[2] == "-h" {
+			newCmd.Usage()
+			exit(0)
+		}
+		if os.Args[2] != "processor" {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown template %q, must be: processor", os.Args[2]))
+			fmt.Fprintln(os.Stderr, "Run 'orca new help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		newCmd.Parse(os.Args[3:])
+
+		if newCmd.NArg() > 1 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", newCmd.Arg(1)))
+			fmt.Fprintln(os.Stderr, "Run 'orca new help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		dir := "."
+		if newCmd.NArg() == 1 {
+			dir = newCmd.Arg(0)
+		}
+		if *packageManager == "" {
+			*packageManager = defaultPackageManager()
+		}
+		if !slices.Contains(packageManagers, *packageManager) {
+			printError(fmt.Sprintf("Invalid package manager: %s. Must be one of: %s", *packageManager, strings.Join(packageManagers, ", ")))
+			exit(1)
+		}
+		if *name == "" {
+			absDir, err := filepath.Abs(dir)
+			if err != nil {
+				printError(fmt.Sprintf("Failed to resolve %s: %v", dir, err))
+				exit(1)
+			}
+			*name = toCamelCase(filepath.Base(absDir))
+		}
+
+		written, err := scaffoldProcessor(dir, *name, *packageManager)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		for _, path := range written {
+			fmt.Fprintf(os.Stderr, "  created %s\n", path)
+		}
+		fmt.Println(renderStdout(successStyle, fmt.Sprintf("Processor %s scaffolded with %s", *name, *packageManager)))
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Next steps:")
+		if dir != "." {
+			fmt.Fprintf(os.Stderr, "  cd %s\n", dir)
+		}
+		fmt.Fprintln(os.Stderr, "  orca start    # if the stack is not running yet")
+		fmt.Fprintln(os.Stderr, "  orca init     # connect the project to the stack")
+		fmt.Fprintln(os.Stderr, "  orca run      # start the processor")
+
+	case "run":
+		configPath := runCmd.String("config", findProjectConfig(), "Path to orca.json configuration file of the processor project")
+
+		runCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca run [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Run the processor of a project with its package manager, as recorded in the\n")
+			fmt.Fprintf(os.Stderr, "processor section of orca.json, preparing its environment first if needed. The\n")
+			fmt.Fprintf(os.Stderr, "processor is given ORCA_CORE, PROCESSOR_ADDRESS and PROCESSOR_PORT from orca.json.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			runCmd.PrintDefaults()
+		}
+
+		runCmd.Parse(os.Args[2:])
+
+		if runCmd.NArg() > 0 && (runCmd.Arg(0) == "help" || runCmd.Arg(0) == "-h") {
+			runCmd.Usage()
+			exit(0)
+		}
+		if runCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", runCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca run help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		config, err := readProjectConfig(*configPath)
+		if err != nil {
+			printError(fmt.Sprintf("%v. Scaffold a processor with `orca new processor`", err))
+			exit(1)
+		}
+		if config.OrcaConnectionString == "" {
+			fmt.Fprintln(os.Stderr, warningStyle.Render("orca.json has no connection to the stack yet, run `orca init` first"))
+		}
+		exit(runProcessor(config, filepath.Dir(*configPath)))
+
+	case "build":
+		options := addBuildFlags(buildCmd)
+		printDockerfile := buildCmd.Bool("print-dockerfile", false, "Print the generated Dockerfile and exit, e.g. to start your own from it")
+
+		buildCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca build [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Build a container image of the project's processor. Without a Dockerfile in the\n")
+			fmt.Fprintf(os.Stderr, "project, one is generated that installs its dependencies with its package manager.\n\n")
+			fmt.Fprintf(os.Stderr, "The image is tagged latest and, when the core knows the project's algorithms, with\n")
+			fmt.Fprintf(os.Stderr, "a hash of their registration, which only changes when the algorithms do. The\n")
+			fmt.Fprintf(os.Stderr, "labels %s and %s record them.\n\n", imageAlgorithmsLabel, imageAlgorithmsHashLabel)
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			buildCmd.PrintDefaults()
+		}
+
+		buildCmd.Parse(os.Args[2:])
+
+		if buildCmd.NArg() > 0 && (buildCmd.Arg(0) == "help" || buildCmd.Arg(0) == "-h") {
+			buildCmd.Usage()
+			exit(0)
+		}
+		if buildCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", buildCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca build help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		config, err := readProjectConfig(*options.configPath)
+		if err != nil {
+			printError(fmt.Sprintf("%v. Scaffold a processor with `orca new processor`", err))
+			exit(1)
+		}
+		if *printDockerfile {
+			content, err := generatedDockerfile(config)
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			fmt.Print(content)
+			exit(0)
+		}
+
+		checkDockerInstalled()
+		build := options.newBuild(config)
+		if err := build.run(config); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Println(renderStdout(successStyle, fmt.Sprintf("Built %s", strings.Join(build.Tags, ", "))))
+
+	case "push":
+		options := addBuildFlags(pushCmd)
+
+		pushCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca push [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Build the project's processor image as `orca build` does and push its tags to the\n")
+			fmt.Fprintf(os.Stderr, "registry of its repository, set by processor.image in orca.json or -repository.\n")
+			fmt.Fprintf(os.Stderr, "Log in to the registry first with `orca login`.\n\n")
+			fmt.Fprintf(os.Stderr, "With platforms, from -platform or processor.platforms in orca.json, the image is\n")
+			fmt.Fprintf(os.Stderr, "built with buildx for each of them and pushed as one multi-platform image.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			pushCmd.PrintDefaults()
+		}
+
+		pushCmd.Parse(os.Args[2:])
+
+		if pushCmd.NArg() > 0 && (pushCmd.Arg(0) == "help" || pushCmd.Arg(0) == "-h") {
+			pushCmd.Usage()
+			exit(0)
+		}
+		if pushCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", pushCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca push help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		config, err := readProjectConfig(*options.configPath)
+		if err != nil {
+			printError(fmt.Sprintf("%v. Scaffold a processor with `orca new processor`", err))
+			exit(1)
+		}
+
+		checkDockerInstalled()
+		build := options.newBuild(config)
+		if repository := build.Tags[0][:strings.LastIndex(build.Tags[0], ":")]; !strings.Contains(repository, "/") {
+			fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("%s names no registry, so it is pushed to Docker Hub. Set processor.image in orca.json or -repository to push elsewhere", build.Tags[0])))
+		}
+		build.Push = true
+		if err := build.run(config); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if len(build.Platforms) == 0 {
+			for _, tag := range build.Tags {
+				if err := streamDockerRetry("Push:", "push", tag); err != nil {
+					printError(fmt.Sprintf("Failed to push %s: %v", tag, err))
+					exit(1)
+				}
+			}
+		}
+		fmt.Println(renderStdout(successStyle, fmt.Sprintf("Pushed %s", strings.Join(build.Tags, ", "))))
+
+	case "login":
+		loginConfigPath := loginCmd.String("config", findProjectConfig(), "Path to orca.json configuration file. Used to find the registry of the processor image")
+		loginUsername := loginCmd.String("username", "", "Username to log in with")
+		loginCmd.StringVar(loginUsername, "u", "", "Username to log in with (shorthand)")
+		loginPasswordStdin := loginCmd.Bool("password-stdin", false, "Read the password or token from stdin, for scripts and CI")
+		loginGitHub := loginCmd.Bool("gh", false, "Log in to ghcr.io with the token of the GitHub CLI, which needs the read:packages scope")
+		loginKeyring := loginCmd.Bool("keyring", false, "Keep the credentials of the registry in the keyring without asking, when docker has no credential store for it")
+		loginNoKeyring := loginCmd.Bool("no-keyring", false, "Leave docker's credential store as it is, rather than keeping credentials in the keyring")
+
+		loginCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca login [options] [registry]\n\n")
+			fmt.Fprintf(os.Stderr, "Log docker in to an image registry, so that private core and processor images\n")
+			fmt.Fprintf(os.Stderr, "can be pulled by `orca start` and `orca deploy`, and pushed by `orca push`. The\n")
+			fmt.Fprintf(os.Stderr, "registry defaults to that of processor.image in orca.json, then to %s.\n\n", defaultImageRegistry)
+			fmt.Fprintf(os.Stderr, "When docker has no credential store for the registry and the docker-credential\n")
+			fmt.Fprintf(os.Stderr, "helper of the platform's keyring (osxkeychain, wincred or secretservice) is\n")
+			fmt.Fprintf(os.Stderr, "installed, it offers to keep the registry's credentials in the keyring rather than\n")
+			fmt.Fprintf(os.Stderr, "in ~/.docker/config.json. Only credHelpers of that registry is set, so the\n")
+			fmt.Fprintf(os.Stderr, "credentials of other registries are kept where they are.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			loginCmd.PrintDefaults()
+		}
+
+		loginCmd.Parse(os.Args[2:])
+
+		if loginCmd.NArg() > 0 && (loginCmd.Arg(0) == "help" || loginCmd.Arg(0) == "-h") {
+			loginCmd.Usage()
+			exit(0)
+		}
+		if loginCmd.NArg() > 1 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", loginCmd.Arg(1)))
+			fmt.Fprintln(os.Stderr, "Run 'orca login help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		if *loginKeyring && *loginNoKeyring {
+			printError("-keyring and -no-keyring cannot be used together")
+			exit(1)
+		}
+		if *loginGitHub && *loginPasswordStdin {
+			printError("-gh and -password-stdin cannot be used together")
+			exit(1)
+		}
+
+		registry := loginCmd.Arg(0)
+		if registry == "" && *loginGitHub {
+			registry = defaultImageRegistry
+		} else if registry == "" {
+			registry = projectRegistry(loadProjectConfig(*loginConfigPath))
+		}
+
+		var password io.Reader
+		username := *loginUsername
+		switch {
+		case *loginPasswordStdin:
+			password = os.Stdin
+		case *loginGitHub:
+			if registry != defaultImageRegistry {
+				printError(fmt.Sprintf("-gh logs in to %s, not %s", defaultImageRegistry, registry))
+				exit(1)
+			}
+			user, token, err := githubCLICredentials()
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			username = cmp.Or(username, user)
+			password = strings.NewReader(token)
+		}
+
+		checkDockerInstalled()
+		if !*loginNoKeyring {
+			// the docker configuration is the user's, so it is only changed when they agree
+			confirm := func(helper, path string) bool {
+				if !isTerminal(os.Stdin) || *loginPasswordStdin {
+					fmt.Fprintf(os.Stderr, "Run `orca login -keyring` to keep the credentials of %s in the keyring with docker-credential-%s\n", registry, helper)
+					return false
+				}
+				fmt.Fprint(os.Stderr, warningStyle.Render(fmt.Sprintf(
+					"Keep the credentials of %s in the keyring with docker-credential-%s? This sets credHelpers in %s (y/N): ",
+					registry, helper, path,
+				)))
+				var response string
+				fmt.Scanln(&response)
+				return strings.ToLower(strings.TrimSpace(response)) == "y"
+			}
+			if *loginKeyring {
+				confirm = nil
+			}
+			helper, changed, err := ensureKeyringCredentials(registry, confirm)
+			switch {
+			case err != nil:
+				fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Could not check docker's credential store: %v", err)))
+			case changed:
+				fmt.Fprintf(os.Stderr, "Docker now keeps the credentials of %s in the keyring, with docker-credential-%s\n", registry, helper)
+			case helper == "":
+				fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Docker keeps the credentials of %s in ~/.docker/config.json. See https://docs.docker.com/reference/cli/docker/login/#credential-stores", registry)))
+			}
+		}
+		if err := dockerLogin(registry, username, password); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+	case "logout":
+		logoutConfigPath := logoutCmd.String("config", findProjectConfig(), "Path to orca.json configuration file. Used to find the registry of the processor image")
+
+		logoutCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca logout [options] [registry]\n\n")
+			fmt.Fprintf(os.Stderr, "Remove the credentials docker keeps for an image registry, by default the one\n")
+			fmt.Fprintf(os.Stderr, "`orca login` logs in to\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			logoutCmd.PrintDefaults()
+		}
+
+		logoutCmd.Parse(os.Args[2:])
+
+		if logoutCmd.NArg() > 0 && (logoutCmd.Arg(0) == "help" || logoutCmd.Arg(0) == "-h") {
+			logoutCmd.Usage()
+			exit(0)
+		}
+		if logoutCmd.NArg() > 1 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", logoutCmd.Arg(1)))
+			fmt.Fprintln(os.Stderr, "Run 'orca logout help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		registry := cmp.Or(logoutCmd.Arg(0), projectRegistry(loadProjectConfig(*logoutConfigPath)))
+		checkDockerInstalled()
+		if err := dockerLogout(registry); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Logged out of %s", registry)))
+
+	case "scan":
+		scanner := scanCmd.String("scanner", "auto", "Scanner to run: auto, trivy or grype. auto runs the first on your PATH")
+		severity := scanCmd.String("severity", "low", "Report vulnerabilities at least this severe: unknown, low, medium, high or critical")
+		failOn := scanCmd.String("fail-on", "none", "Exit 1 when an image has a vulnerability at least this severe, or cannot be scanned: none, low, medium, high or critical")
+		scanConfigPath := scanCmd.String("config", findProjectConfig(), "Path to orca.json configuration file. Used to find the processor image of the project")
+		scanOutput := scanCmd.String("o", "text", "Output format - text|json|template=<go-template>")
+
+		scanCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca scan [options] [image...]\n\n")
+			fmt.Fprintf(os.Stderr, "Scan images for known vulnerabilities with Trivy or Grype, whichever is installed.\n")
+			fmt.Fprintf(os.Stderr, "Without images, the images of the stack are scanned: those of its components,\n")
+			fmt.Fprintf(os.Stderr, "of the processors deployed on it, and the project's processor image once built.\n")
+			fmt.Fprintf(os.Stderr, "With -fail-on, for example -fail-on high, it gates CI pipelines on their findings.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			scanCmd.PrintDefaults()
+		}
+
+		scanCmd.Parse(os.Args[2:])
+
+		if scanCmd.NArg() > 0 && (scanCmd.Arg(0) == "help" || scanCmd.Arg(0) == "-h") {
+			scanCmd.Usage()
+			exit(0)
+		}
+
+		if severityRank(*severity) < 0 {
+			printError(fmt.Sprintf("invalid -severity %q, must be one of: %s", *severity, strings.Join(scanSeverities, ", ")))
+			exit(1)
+		}
+		if *failOn != "none" && severityRank(*failOn) < 0 {
+			printError(fmt.Sprintf("invalid -fail-on %q, must be one of: none, %s", *failOn, strings.Join(scanSeverities[1:], ", ")))
+			exit(1)
+		}
+		if err := validateOutputFormat(*scanOutput); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		scannerName, err := findScanner(*scanner)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		images := scanCmd.Args()
+		if len(images) == 0 {
+			checkDockerInstalled()
+			images = listStackImages(listContainers(), loadProjectConfig(*scanConfigPath))
+		}
+
+		var scans []imageScan
+		failed := false
+		for _, image := range images {
+			if *scanOutput == "text" {
+				fmt.Fprintf(os.Stderr, "Scanning %s with %s...\n", image, scannerName)
+			}
+			scan := imageScan{Image: image, Vulnerabilities: []vulnerability{}}
+			if vulnerabilities, err := scanImage(scannerName, image); err != nil {
+				scan.Error = err.Error()
+			} else {
+				scan.Vulnerabilities = vulnerabilities
+			}
+			// the policy gate sees every vulnerability, whatever -severity reports
+			failed = failed || scanFails([]imageScan{scan}, *failOn)
+			scan.Vulnerabilities = filterSeverity(scan.Vulnerabilities, *severity)
+			scans = append(scans, scan)
+		}
+
+		if *scanOutput != "text" {
+			if err := renderOutput(os.Stdout, scans, *scanOutput); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		} else {
+			fmt.Fprintln(os.Stderr)
+			showScans(os.Stdout, scans)
+		}
+		if failed {
+			exit(1)
+		}
+
+	case "deploy":
+		configPath := deployCmd.String("config", findProjectConfig(), "Path to orca.json configuration file of the processor project")
+		image := deployCmd.String("image", "", "Image to run (defaults to the latest image built by `orca build`, of processor.image in orca.json)")
+		port := deployCmd.Int("port", 0, "Port the processor listens on in its container (defaults to orca.json, then 5377)")
+
+		deployCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca deploy [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Run the project's processor image in a container on the orca network, replacing any\n")
+			fmt.Fprintf(os.Stderr, "earlier deployment. The processor reaches the core, and the core reaches it, by\n")
+			fmt.Fprintf(os.Stderr, "container name. The container belongs to the stack, so `orca status` lists it and\n")
+			fmt.Fprintf(os.Stderr, "`orca stop` stops it.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			deployCmd.PrintDefaults()
+		}
+
+		deployCmd.Parse(os.Args[2:])
+
+		if deployCmd.NArg() > 0 && (deployCmd.Arg(0) == "help" || deployCmd.Arg(0) == "-h") {
+			deployCmd.Usage()
+			exit(0)
+		}
+		if deployCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", deployCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca deploy help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		config, err := readProjectConfig(*configPath)
+		if err != nil {
+			printError(fmt.Sprintf("%v. Scaffold a processor with `orca new processor`", err))
+			exit(1)
+		}
+		deployment := &processorDeployment{
+			Project:   config.ProjectName,
+			Image:     cmp.Or(*image, processorRepository(config)+":latest"),
+			Container: processorContainerName(config.ProjectName),
+			Port:      cmp.Or(*port, config.ProcessorPort, defaultProcessorPort),
+		}
+
+		// a remote core reaches the processor at processorConnectionString instead
+		remoteCore := remoteCoreAddress(config)
+		if remoteCore != "" {
+			if err := deployment.useRemoteCore(remoteCore, config.ProcessorConnectionString); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+
+		checkDockerInstalled()
+		if remoteCore == "" && getContainerStatus(orcaContainerName) != "running" {
+			printError("Orca not running. Start orca locally with the command `orca start`")
+			exit(1)
+		}
+		if err := dockerCommand("image", "inspect", deployment.Image).Run(); err != nil {
+			printError(fmt.Sprintf("Image %s not found. Build it with `orca build`", deployment.Image))
+			exit(1)
+		}
+		if err := deployment.run(); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Println(renderStdout(successStyle, fmt.Sprintf("Deployed %s as %s", deployment.Image, deployment.Container)))
+		fmt.Fprintf(os.Stderr, "The core reaches the processor at %s. Follow its logs with `docker logs -f %s`\n", deployment.address(), deployment.Container)
+
+	case "export":
+		configPath := exportCmd.String("config", findProjectConfig(), "Path to orca.json configuration file")
+		out := exportCmd.String("out", "", "Write the manifest to this file instead of stdout")
+
+		exportCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca export <compose|k8s> [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Export the stack of orca.json, with the settings `orca start` applies, and the\n")
+			fmt.Fprintf(os.Stderr, "project's processor, as `orca deploy` runs it, so the pipeline runs elsewhere:\n")
+			fmt.Fprintf(os.Stderr, "  compose  A Docker Compose file\n")
+			fmt.Fprintf(os.Stderr, "  k8s      Kubernetes Deployments, Services and PersistentVolumeClaims\n\n")
+			fmt.Fprintf(os.Stderr, "Companion services are not exported. Push the processor image with `orca push`\n")
+			fmt.Fprintf(os.Stderr, "first, so the cluster can pull it.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			exportCmd.PrintDefaults()
+		}
+
+		if len(os.Args) < 3 || os.Args[2] == "help" || os.Args[2] == "-h" {
+			exportCmd.Usage()
+			exit(0)
+		}
+
+		format := os.Args[2]
+		if !slices.Contains(exportFormats, format) {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown export format: %s", format))
+			fmt.Fprintln(os.Stderr, "Run 'orca export help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		exportCmd.Parse(os.Args[3:])
+
+		if exportCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", exportCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca export help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		config, err := readProjectConfig(*configPath)
+		if err != nil {
+			printError(fmt.Sprintf("%v. Create one with `orca init`", err))
+			exit(1)
+		}
+		manifest, err := exportManifest(config, *configPath, format)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if *out == "" {
+			fmt.Print(manifest)
+			break
+		}
+		if err := os.WriteFile(*out, []byte(manifest), 0644); err != nil {
+			printError(fmt.Sprintf("failed to write %s: %v", *out, err))
+			exit(1)
+		}
+		fmt.Println(renderStdout(successStyle, fmt.Sprintf("Exported the pipeline to %s", *out)))
+
+	case "dev":
+		configPath := devCmd.String("config", findProjectConfig(), "Path to orca.json configuration file of the processor project")
+		container := devCmd.Bool("container", false, "Rebuild and redeploy the processor container on each change, instead of running it locally")
+		coreAddress := devCmd.String("core", "", "Address of the Orca core to verify registration with (defaults to orca.json, then the local stack)")
+		interval := devCmd.Duration("interval", 500*time.Millisecond, "How often to check the project for changes")
+		timeout := devCmd.Duration("timeout", 30*time.Second, "How long to wait for the processor to register after each restart")
+		addGRPCFlags(devCmd)
+
+		devCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca dev [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Run the project's processor as `orca run` does, restarting it whenever a file in the\n")
+			fmt.Fprintf(os.Stderr, "project changes, and check after each restart that it registers with the core and\n")
+			fmt.Fprintf(os.Stderr, "passes its health check. Algorithms added or removed by a change are reported.\n")
+			fmt.Fprintf(os.Stderr, "Hidden directories, virtual environments and caches are not watched.\n\n")
+			fmt.Fprintf(os.Stderr, "With -container, each change rebuilds the image and redeploys it as `orca build`\n")
+			fmt.Fprintf(os.Stderr, "and `orca deploy` do, following its logs. The container keeps running on exit.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			devCmd.PrintDefaults()
+		}
+
+		devCmd.Parse(os.Args[2:])
+
+		if devCmd.NArg() > 0 && (devCmd.Arg(0) == "help" || devCmd.Arg(0) == "-h") {
+			devCmd.Usage()
+			exit(0)
+		}
+		if devCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", devCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca dev help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		config, err := readProjectConfig(*configPath)
+		if err != nil {
+			printError(fmt.Sprintf("%v. Scaffold a processor with `orca new processor`", err))
+			exit(1)
+		}
+		address := cmp.Or(*coreAddress, config.OrcaConnectionString)
+		if *container {
+			checkDockerInstalled()
+			if getContainerStatus(orcaContainerName) != "running" {
+				printError("Orca not running. Start orca locally with the command `orca start`")
+				exit(1)
+			}
+			// orca.json records the address from this machine, the container uses its own
+			address = *coreAddress
+		}
+		if address == "" {
+			address, err = localCoreAddress()
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+
+		session := &devSession{
+			config:      config,
+			dir:         filepath.Dir(*configPath),
+			coreAddress: address,
+			timeout:     *timeout,
+			container:   *container,
+		}
+		if err := session.run(*interval); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+	case "bridge":
+		brokers := bridgeCmd.String("brokers", "", "Comma separated broker addresses (defaults to localhost:9092 for kafka, localhost:1883 for mqtt)")
+		topics := bridgeCmd.String("topic", "", "Comma separated topics to consume (required)")
+		group := bridgeCmd.String("group", "orca-bridge", "Kafka consumer group, which remembers how far the bridge got between runs")
+		fromBeginning := bridgeCmd.Bool("from-beginning", false, "Consume Kafka topics from the earliest offset when the group has none")
+		qos := bridgeCmd.Int("qos", 0, "MQTT quality of service level, 0 to 2")
+		windowSpec := bridgeCmd.String("window", "", "Window type of the windows, as name[@version]. Without it messages name theirs in window_type")
+		mappingPath := bridgeCmd.String("mapping", "", "JSON file mapping message fields onto window times, origin and metadata, as for `orca import`")
+		origin := bridgeCmd.String("origin", bridgeOrigin, "Origin of windows whose messages name none")
+		coreAddress := bridgeCmd.String("core", "", "Address of the Orca core (defaults to the local stack)")
+		timeout := bridgeCmd.Duration("timeout", time.Second*10, "Timeout for each request to the core")
+
+		addGRPCFlags(bridgeCmd)
+
+		bridgeCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca bridge <%s> -topic <topic> [options]\n\n", strings.Join(bridgeSourceNames(), "|"))
+			fmt.Fprintf(os.Stderr, "Consume messages from a message bus and forward each to the core as a window, until\n")
+			fmt.Fprintf(os.Stderr, "interrupted. Messages are single line JSON objects, mapped onto windows as rows are\n")
+			fmt.Fprintf(os.Stderr, "by `orca import`. Messages that fail are reported and skipped.\n\n")
+			fmt.Fprintf(os.Stderr, "The consumer runs in a container on the orca network, so brokers started alongside\n")
+			fmt.Fprintf(os.Stderr, "the stack are reachable by container name, and localhost reaches this machine.\n\n")
+			fmt.Fprintf(os.Stderr, "Sources:\n")
+			for _, name := range bridgeSourceNames() {
+				fmt.Fprintf(os.Stderr, "  %-8s %s\n", name, bridgeSources[name].Description)
+			}
+			fmt.Fprintf(os.Stderr, "\nOptions:\n")
+			bridgeCmd.PrintDefaults()
+		}
+
+		if len(os.Args) < 3 || os.Args[2] == "help" || os.Args[2] == "-h" {
+			bridgeCmd.Usage()
+			exit(0)
+		}
+		source, ok := bridgeSources[os.Args[2]]
+		if !ok {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown source %q, must be one of: %s", os.Args[2], strings.Join(bridgeSourceNames(), ", ")))
+			fmt.Fprintln(os.Stderr, "Run 'orca bridge help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		bridgeCmd.Parse(os.Args[3:])
+
+		if bridgeCmd.NArg() != 0 || *topics == "" {
+			fmt.Fprintln(os.Stderr)
+			printError("Expected the topics to consume with -topic, and no arguments")
+			fmt.Fprintln(os.Stderr, "Run 'orca bridge help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		options := bridgeOptions{
+			Brokers:       *brokers,
+			Group:         *group,
+			FromBeginning: *fromBeginning,
+			QoS:           *qos,
+		}
+		if options.Brokers == "" {
+			options.Brokers = source.DefaultBroker
+		}
+		for _, topic := range strings.Split(*topics, ",") {
+			if topic = strings.TrimSpace(topic); topic != "" {
+				options.Topics = append(options.Topics, topic)
+			}
+		}
+		if source.Name == "mqtt" && (strings.Contains(options.Brokers, ",") || *qos < 0 || *qos > 2) {
+			printError("The mqtt bridge takes a single broker and a -qos of 0 to 2")
+			exit(1)
+		}
+
+		mapping, err := readImportMapping(*mappingPath)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		checkDockerInstalled()
+		address := *coreAddress
+		if address == "" {
+			if address, err = localCoreAddress(); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+		conn, client, err := dialCore(address)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		state, err := exposeRegistry(ctx, client, address, &pb.ExposeSettings{})
+		cancel()
+		if err != nil {
+			printError(fmt.Sprintf("Issue contacting Orca: %v", err))
+			exit(1)
+		}
+		var windowType *pb.WindowType
+		if *windowSpec != "" {
+			if windowType, err = findWindowType(state, *windowSpec); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+
+		windows := &windowImport{
+			WindowType: windowType,
+			State:      state,
+			Mapping:    mapping,
+			Origin:     *origin,
+			KeepGoing:  true,
+			Timeout:    *timeout,
+		}
+		result, err := runBridge(client, source, options, windows)
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "Forwarded %d window(s), %d message(s) failed.\n", result.Imported, result.Failed)
+		if result.Untriggered > 0 {
+			fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("%d window(s) triggered no algorithms", result.Untriggered)))
+		}
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr)
+
+	case "trace":
+		traceOutput := traceCmd.String("o", "text", "Output format - text|json|template=<go-template>")
+		traceJSON := traceCmd.Bool("json", false, "Shorthand for -o json")
+		logSpan := traceCmd.Duration("logs", time.Minute*5, "How long after the window was received to scan the core logs for warnings and errors, 0 to skip")
+		timeout := traceCmd.Duration("timeout", time.Second*5, "Timeout for probing the processors")
+
+		addGRPCFlags(traceCmd)
+
+		traceCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca trace [options] <window-id>\n\n")
+			fmt.Fprintf(os.Stderr, "Follow a window through the pipeline: when the core received it, the processors\n")
+			fmt.Fprintf(os.Stderr, "it was dispatched to, the results stored for each algorithm and those missing,\n")
+			fmt.Fprintf(os.Stderr, "and the warnings and errors the core logged meanwhile.\n\n")
+			fmt.Fprintf(os.Stderr, "The core does not tag its logs with windows, so logged failures are those\n")
+			fmt.Fprintf(os.Stderr, "around the time the window was received.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			traceCmd.PrintDefaults()
+		}
+
+		traceCmd.Parse(os.Args[2:])
+
+		if traceCmd.NArg() > 0 && (traceCmd.Arg(0) == "help" || traceCmd.Arg(0) == "-h") {
+			traceCmd.Usage()
+			exit(0)
+		}
+
+		if traceCmd.NArg() != 1 {
+			fmt.Fprintln(os.Stderr)
+			printError("Expected exactly one window id")
+			fmt.Fprintln(os.Stderr, "Run 'orca trace help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		windowID, err := strconv.ParseInt(traceCmd.Arg(0), 10, 64)
+		if err != nil || windowID <= 0 {
+			printError(fmt.Sprintf("Invalid window id %q, expected the numeric id of a stored window", traceCmd.Arg(0)))
+			exit(1)
+		}
+
+		if *traceJSON {
+			*traceOutput = "json"
+		}
+		if err := validateOutputFormat(*traceOutput); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		checkDockerInstalled()
+
+		if status := getContainerStatus(pgContainerName); status != "running" {
+			printError("Postgres store is not running. Start Orca with `orca start`")
+			exit(1)
+		}
+
+		trace, err := traceWindow(windowID, *logSpan, *timeout)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		if *traceOutput != "text" {
+			if err := renderOutput(os.Stdout, trace, *traceOutput); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			break
+		}
+		showWindowTrace(os.Stdout, trace)
+
+	case "queue":
+		period := queueCmd.Duration("period", time.Minute*15, "Period over which processing rates are measured")
+		queueOutput := queueCmd.String("o", "text", "Output format - text|json|template=<go-template>")
+		queueJSON := queueCmd.Bool("json", false, "Shorthand for -o json")
+
+		queueCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca queue stats [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Show the depth and oldest item of the queues held in Redis, and per window type\n")
+			fmt.Fprintf(os.Stderr, "how many windows were received and results stored over -period. Windows still\n")
+			fmt.Fprintf(os.Stderr, "missing results pile up as unfinished when processors fall behind.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			queueCmd.PrintDefaults()
+		}
+
+		if len(os.Args) < 3 || os.Args[2] == "help" || os.Args[2] == "-h" {
+			queueCmd.Usage()
+			exit(0)
+		}
+
+		if os.Args[2] != "stats" {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown queue action: %s", os.Args[2]))
+			fmt.Fprintln(os.Stderr, "Run 'orca queue help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		queueCmd.Parse(os.Args[3:])
+
+		if queueCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", queueCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca queue help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		if *period < time.Minute {
+			printError("-period must be at least a minute")
+			exit(1)
+		}
+		if *queueJSON {
+			*queueOutput = "json"
+		}
+		if err := validateOutputFormat(*queueOutput); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		checkDockerInstalled()
+
+		for _, containerName := range []string{pgContainerName, redisContainerName} {
+			if status := getContainerStatus(containerName); status != "running" {
+				printError(fmt.Sprintf("%s is %s. Start Orca with `orca start`", containerName, status))
+				exit(1)
+			}
+		}
+
+		stats := queueStats{Period: period.String()}
+		var err error
+		if stats.Queues, err = collectRedisQueues(); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if stats.WindowTypes, err = collectWindowTypeThroughput(*period); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		if *queueOutput != "text" {
+			if err := renderOutput(os.Stdout, stats, *queueOutput); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			break
+		}
+		showQueueStats(os.Stdout, stats)
+
+	case "failures":
+		algorithm := failuresCmd.String("algorithm", "", "Only failures of this algorithm, as name or name@version")
+		since := failuresCmd.String("since", "7d", "Only windows received at or after this time (RFC3339, YYYY-MM-DD, or a duration such as 24h or 7d)")
+		grace := failuresCmd.Duration("grace", time.Minute*5, "Leave out windows received this recently, which may still be processing")
+		limit := failuresCmd.Int("limit", 100, "Maximum number of failures to list, 0 for all")
+		logSpan := failuresCmd.Duration("logs", time.Minute*5, "How long after each window was received to collect core errors, 0 to skip")
+		all := failuresCmd.Bool("all", false, "Retry or purge every failure matching the filters")
+		assumeYes := failuresCmd.Bool("y", false, "Skip the confirmation prompt of purge")
+		timeout := failuresCmd.Duration("timeout", time.Second*10, "Timeout for each request to the core")
+		failuresOutput := failuresCmd.String("o", "text", "Output format of list - text|json|template=<go-template>")
+		failuresJSON := failuresCmd.Bool("json", false, "Shorthand for -o json")
+
+		addGRPCFlags(failuresCmd)
+
+		failuresCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca failures <list|retry|purge> [options] [window-id...]\n\n")
+			fmt.Fprintf(os.Stderr, "The store keeps no record of failed executions, so a failure is an algorithm with\n")
+			fmt.Fprintf(os.Stderr, "no result for a window of its type, once -grace has passed. Errors are those the\n")
+			fmt.Fprintf(os.Stderr, "core logged while processing the window.\n\n")
+			fmt.Fprintf(os.Stderr, "list   List failed executions with the errors logged for them\n")
+			fmt.Fprintf(os.Stderr, "retry  Emit the given windows, or with -all those of every failure, to the core\n")
+			fmt.Fprintf(os.Stderr, "       again. Each is stored as a new window and the original is deleted.\n")
+			fmt.Fprintf(os.Stderr, "purge  Delete the given windows, or with -all those of every failure, along\n")
+			fmt.Fprintf(os.Stderr, "       with the results they did store\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			failuresCmd.PrintDefaults()
+		}
+
+		if len(os.Args) < 3 || os.Args[2] == "help" || os.Args[2] == "-h" {
+			failuresCmd.Usage()
+			exit(0)
+		}
+
+		action := os.Args[2]
+		if action != "list" && action != "retry" && action != "purge" {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown failures action: %s", action))
+			fmt.Fprintln(os.Stderr, "Run 'orca failures help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		failuresCmd.Parse(os.Args[3:])
+
+		filter := failuresFilter{Algorithm: *algorithm, Grace: *grace, Limit: *limit}
+		for _, arg := range failuresCmd.Args() {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil || id <= 0 {
+				printError(fmt.Sprintf("Invalid window id %q, expected the numeric id of a stored window", arg))
+				exit(1)
+			}
+			filter.WindowIDs = append(filter.WindowIDs, id)
+		}
+		if action != "list" && len(filter.WindowIDs) == 0 && !*all {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Pass the window ids to %s, or -all for every failure", action))
+			fmt.Fprintln(os.Stderr, "Run 'orca failures help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		if *since != "" {
+			var err error
+			if filter.Since, err = parseTimeArg(*since); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+		if *failuresJSON {
+			*failuresOutput = "json"
+		}
+		if err := validateOutputFormat(*failuresOutput); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		checkDockerInstalled()
+
+		if status := getContainerStatus(pgContainerName); status != "running" {
+			printError("Postgres store is not running. Start Orca with `orca start`")
+			exit(1)
+		}
+
+		if action == "list" {
+			failures, err := listFailures(filter, *logSpan)
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			if *failuresOutput != "text" {
+				if err := renderOutput(os.Stdout, failures, *failuresOutput); err != nil {
+					printError(err.Error())
+					exit(1)
+				}
+				break
+			}
+			if len(failures) == 0 {
+				fmt.Fprintln(os.Stderr, "No failed executions.")
+				break
+			}
+			showFailures(os.Stdout, failures)
+			if filter.Limit > 0 && len(failures) == filter.Limit {
+				fmt.Fprintln(os.Stderr, dimStyle.Render(fmt.Sprintf("Showing the first %d failures, raise -limit to see more.", filter.Limit)))
+			}
+			break
+		}
+
+		// the given windows are retried or purged whatever their age or algorithms
+		if len(filter.WindowIDs) > 0 {
+			filter = failuresFilter{WindowIDs: filter.WindowIDs}
+		}
+		failures, err := listFailures(filter, 0)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		windowIDs := failedWindowIDs(failures)
+		for _, id := range filter.WindowIDs {
+			if !slices.Contains(windowIDs, id) {
+				fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Window %d has no failed executions, skipping it", id)))
+			}
+		}
+		if len(windowIDs) == 0 {
+			fmt.Fprintln(os.Stderr, "No failed executions.")
+			break
+		}
+
+		if action == "purge" {
+			fmt.Fprintln(os.Stderr)
+			fmt.Fprintf(os.Stderr, "%d failed execution(s) across %d window(s) will be deleted, with the results\n", len(failures), len(windowIDs))
+			fmt.Fprintf(os.Stderr, "those windows did store.\n\n")
+			if !*assumeYes {
+				fmt.Fprint(os.Stderr, warningStyle.Render("Permanently delete these windows? (y/N): "))
+				var response string
+				fmt.Scanln(&response)
+				if strings.ToLower(strings.TrimSpace(response)) != "y" {
+					fmt.Fprintln(os.Stderr, "Operation cancelled.")
+					exit(0)
+				}
+			}
+			if err := deleteWindows(windowIDs); err != nil {
+				printError(fmt.Sprintf("Purge failed: %v", err))
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Purged %d window(s).", len(windowIDs))))
+			fmt.Fprintln(os.Stderr)
+			break
+		}
+
+		address, err := localCoreAddress()
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		conn, client, err := dialCore(address)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		defer conn.Close()
+
+		fmt.Fprintln(os.Stderr)
+		retried := 0
+		for _, id := range windowIDs {
+			fmt.Fprintf(os.Stderr, "Retrying window %d... ", id)
+			ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+			err := retryFailedWindow(ctx, client, id)
+			cancel()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, renderError("FAILED"))
+				fmt.Fprintln(os.Stderr, "  "+err.Error())
+				continue
+			}
+			fmt.Fprintln(os.Stderr, renderSuccess("TRIGGERED"))
+			retried++
+		}
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "Retried %d of %d window(s). Check on them with `orca failures list -grace 0`\n", retried, len(windowIDs))
+		if retried < len(windowIDs) {
+			exit(1)
+		}
+
+	case "pause":
+		noDrain := pauseCmd.Bool("no-drain", false, "Pause right away, freezing work in flight until `orca resume`")
+		drainQuiet := pauseCmd.Duration("drain-quiet", time.Second*10, "How long no result must be stored for processing to count as drained")
+		drainTimeout := pauseCmd.Duration("drain-timeout", time.Minute*5, "How long to wait for processing to drain")
+
+		pauseCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca pause [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Wait for the core to finish processing the windows it has in flight, then\n")
+			fmt.Fprintf(os.Stderr, "freeze the core container entirely, e.g. before a backup, an upgrade or a\n")
+			fmt.Fprintf(os.Stderr, "schema change on the store.\n\n")
+			fmt.Fprintf(os.Stderr, "This is a full freeze: the core cannot stop dispatching windows while it keeps\n")
+			fmt.Fprintf(os.Stderr, "accepting them, so it answers no calls while frozen. Windows emitted meanwhile\n")
+			fmt.Fprintf(os.Stderr, "are rejected, not held until `orca resume`: emitters' requests fail once they\n")
+			fmt.Fprintf(os.Stderr, "time out, and emitters must send those windows again. Processors and commands\n")
+			fmt.Fprintf(os.Stderr, "that reach the core, such as `orca status`, time out too.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			pauseCmd.PrintDefaults()
+		}
+
+		pauseCmd.Parse(os.Args[2:])
+
+		if pauseCmd.NArg() > 0 && (pauseCmd.Arg(0) == "help" || pauseCmd.Arg(0) == "-h") {
+			pauseCmd.Usage()
+			exit(0)
+		}
+
+		if pauseCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", pauseCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca pause help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		checkDockerInstalled()
+		if err := lockStack("pause"); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		if isCoreFrozen() {
+			fmt.Fprintln(os.Stderr, "Processing is already paused. Resume it with `orca resume`")
+			break
+		}
+		if status := getContainerStatus(orcaContainerName); status != "running" {
+			printError(fmt.Sprintf("Orca is %s. Start Orca with `orca start`", status))
+			exit(1)
+		}
+
+		fmt.Fprintln(os.Stderr)
+		if !*noDrain {
+			if status := getContainerStatus(pgContainerName); status != "running" {
+				printError("Postgres store is not running, so processing cannot be drained. Pass -no-drain to pause anyway")
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr, "Draining in-flight processing...")
+			if err := drainCore(*drainQuiet, *drainTimeout); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Pausing %s... ", orcaContainerName)
+		if err := freezeCore(); err != nil {
+			fmt.Fprintln(os.Stderr, renderError("FAILED"))
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess("PAUSED"))
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, warningStyle.Render("The core is frozen and answers no calls: emitted windows are rejected, not held, and processors cannot reach it."))
+		fmt.Fprintln(os.Stderr, "Processing is paused. Resume it with `orca resume`")
+		fmt.Fprintln(os.Stderr)
+
+	case "resume":
+		resumeCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca resume\n\n")
+			fmt.Fprintf(os.Stderr, "Resume processing paused with `orca pause`\n")
+		}
+
+		resumeCmd.Parse(os.Args[2:])
+
+		if resumeCmd.NArg() > 0 && (resumeCmd.Arg(0) == "help" || resumeCmd.Arg(0) == "-h") {
+			resumeCmd.Usage()
+			exit(0)
+		}
+
+		if resumeCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", resumeCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca resume help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		checkDockerInstalled()
+		if err := lockStack("resume"); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		if !isCoreFrozen() {
+			fmt.Fprintln(os.Stderr, "Processing is not paused.")
+			break
+		}
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "Resuming %s... ", orcaContainerName)
+		if err := thawCore(); err != nil {
+			fmt.Fprintln(os.Stderr, renderError("FAILED"))
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess("RESUMED"))
+		fmt.Fprintln(os.Stderr)
+
+	case "daemon":
+		daemonCore := daemonCmd.String("core", "", "Address of the core, by default the local stack's")
+		daemonInterval := daemonCmd.Duration("interval", 10*time.Second, "How often to refresh the registry from the core")
+		daemonTimeout := daemonCmd.Duration("timeout", 10*time.Second, "Timeout for each read of the registry")
+		daemonOutput := daemonCmd.String("o", "text", "Output format of status - text|json|template=<go-template>")
+		addGRPCFlags(daemonCmd)
+
+		daemonCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca daemon [options] <start|run|stop|status|refresh>\n\n")
+			fmt.Fprintf(os.Stderr, "Keep a connection to the core and a cached copy of its registry, serving both\n")
+			fmt.Fprintf(os.Stderr, "over a unix socket so that completion and status answer without dialing the\n")
+			fmt.Fprintf(os.Stderr, "core. start runs the daemon in the background, run in the foreground.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			daemonCmd.PrintDefaults()
+		}
+
+		daemonCmd.Parse(os.Args[2:])
+
+		if daemonCmd.NArg() > 0 && (daemonCmd.Arg(0) == "help" || daemonCmd.Arg(0) == "-h") {
+			daemonCmd.Usage()
+			exit(0)
+		}
+
+		action := daemonCmd.Arg(0)
+		if action == "" {
+			action = "status"
+		}
+		if daemonCmd.NArg() > 1 || !slices.Contains(subcommandActions["daemon"], action) {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", daemonCmd.Arg(daemonCmd.NArg()-1)))
+			fmt.Fprintln(os.Stderr, "Run 'orca daemon help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		if err := validateOutputFormat(*daemonOutput); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		switch action {
+		case "start":
+			// the daemon is passed the flags given here, bar the output format of status
+			var args []string
+			daemonCmd.Visit(func(f *flag.Flag) {
+				if f.Name != "o" {
+					args = append(args, fmt.Sprintf("-%s=%s", f.Name, f.Value))
+				}
+			})
+			if err := startDaemon(args); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			status, _ := queryDaemonStatus()
+			fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Daemon started (pid %d), serving the registry of %s", status.PID, status.Core)))
+
+		case "run":
+			address := *daemonCore
+			if address == "" {
+				checkDockerInstalled()
+				var err error
+				if address, err = localCoreAddress(); err != nil {
+					printError(err.Error())
+					exit(1)
+				}
+			}
+			if err := runDaemon(address, *daemonInterval, *daemonTimeout); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+
+		case "stop":
+			if err := stopDaemon(); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr, renderSuccess("Daemon stopped"))
+
+		case "refresh":
+			if err := refreshDaemon(*daemonTimeout + time.Second); err != nil {
+				printError(fmt.Sprintf("Failed to refresh the registry: %v", err))
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr, renderSuccess("Registry refreshed"))
+
+		case "status":
+			status, err := queryDaemonStatus()
+			if *daemonOutput != "text" {
+				if err != nil {
+					printError(fmt.Sprintf("No daemon is running for project %s", projectLabelValue(stackProject)))
+					exit(1)
+				}
+				if err := renderOutput(os.Stdout, status, *daemonOutput); err != nil {
+					printError(err.Error())
+					exit(1)
+				}
+				break
+			}
+			if err != nil {
+				fmt.Println("Daemon: " + renderStdout(statusColor("not found"), "not running"))
+				exit(1)
+			}
+			fmt.Println("Daemon:", renderStdout(statusColor("running"), "running"), fmt.Sprintf("(pid %d)", status.PID))
+			fmt.Println("Core: " + status.Core)
+			if status.FetchedAt.IsZero() {
+				fmt.Println("Registry: not read yet")
+			} else {
+				fmt.Printf("Registry: %d processor(s), read %s ago\n", status.Processors, time.Since(status.FetchedAt).Round(time.Second))
+			}
+			if status.Error != "" {
+				fmt.Println(renderStdout(warningStyle, "Last refresh failed: "+status.Error))
+			}
+		}
+
+	case "serve":
+		serveHTTP := serveCmd.String("http", "localhost:8080", "Address to serve HTTP on, e.g. :8080 to accept connections from other machines")
+		serveConnStr := serveCmd.String("connStr", "", "Orca connection string (defaults to local Orca)")
+		serveSecure := serveCmd.Bool("secure", false, "Set to connect to Orca core with System Default Root CA credentials (via TLS)")
+		serveCACert := serveCmd.String("caCert", "", "Path to custom CA certificate file (PEM format) for TLS verification")
+		serveTimeout := serveCmd.Duration("timeout", 10*time.Second, "Timeout for each read of the registry from the core")
+		serveCache := serveCmd.Duration("cache", 2*time.Second, "How long to answer from the registry last read before reading it again, 0 to read it for every request")
+		serveEvents := serveCmd.Bool("events", false, "Also forward pipeline events to the webhooks. Windows and results are read from the local store")
+		var serveWebhooks webhookFlag
+		serveCmd.Var(&serveWebhooks, "webhook", "URL to POST pipeline events to as JSON, with -events (repeatable)")
+		servePoll := serveCmd.Duration("poll", 2*time.Second, "How often to look for pipeline events, with -events")
+		addGRPCFlags(serveCmd)
+
+		serveCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca serve [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Serve the registry of the core as read-only JSON over HTTP, for dashboards and\n")
+			fmt.Fprintf(os.Stderr, "scripts without a gRPC client:\n\n")
+			fmt.Fprintf(os.Stderr, "  GET /processors               Registered processors\n")
+			fmt.Fprintf(os.Stderr, "  GET /processors/{name}        One processor\n")
+			fmt.Fprintf(os.Stderr, "  GET /algorithms[?processor=]  Algorithms, optionally of one processor\n")
+			fmt.Fprintf(os.Stderr, "  GET /windows                  Window types the algorithms are triggered by\n")
+			fmt.Fprintf(os.Stderr, "  GET /health                   200 while the core answers, 503 otherwise\n\n")
+			fmt.Fprintf(os.Stderr, "With -events, pipeline events are also posted to each -webhook as JSON, with the\n")
+			fmt.Fprintf(os.Stderr, "event type in the X-Orca-Event header:\n\n")
+			fmt.Fprintf(os.Stderr, "  window.received       The core received a window\n")
+			fmt.Fprintf(os.Stderr, "  result.produced       An algorithm stored a result\n")
+			fmt.Fprintf(os.Stderr, "  processor.registered  A processor registered, or registered different algorithms\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			serveCmd.PrintDefaults()
+		}
+
+		serveCmd.Parse(os.Args[2:])
+
+		if serveCmd.NArg() > 0 && (serveCmd.Arg(0) == "help" || serveCmd.Arg(0) == "-h") {
+			serveCmd.Usage()
+			exit(0)
+		}
+
+		if serveCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", serveCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca serve help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		if *serveEvents && len(serveWebhooks) == 0 {
+			printError("-events requires at least one -webhook to forward the events to")
+			exit(1)
+		}
+		if !*serveEvents && len(serveWebhooks) > 0 {
+			printError("-webhook requires -events")
+			exit(1)
+		}
+
+		address := *serveConnStr
+		if address == "" {
+			checkDockerInstalled()
+			var err error
+			if address, err = localCoreAddress(); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+		transportCreds, err := coreTransportCredentials(*serveSecure, *serveCACert)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		conn, err := grpc.NewClient(address, grpcDialOptions(transportCreds)...)
+		if err != nil {
+			printError(fmt.Sprintf("Issue preparing to contact Orca: %v", err))
+			exit(1)
+		}
+		defer conn.Close()
+
+		client := pb.NewOrcaCoreClient(conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if *serveEvents {
+			checkDockerInstalled()
+			poller := &eventPoller{client: client, timeout: *serveTimeout}
+			go func() {
+				if err := forwardEvents(ctx, poller, serveWebhooks, *servePoll); err != nil {
+					printError(fmt.Sprintf("Failed to forward events: %v", err))
+					exit(1)
+				}
+			}()
+			fmt.Fprintf(os.Stderr, "Forwarding pipeline events to %d webhook(s), polling every %s.\n", len(serveWebhooks), *servePoll)
+		}
+
+		gateway := &registryGateway{client: client, timeout: *serveTimeout, cacheFor: *serveCache}
+		fmt.Fprintf(os.Stderr, "Serving the registry of %s on http://%s. Press Ctrl+C to stop.\n", address, *serveHTTP)
+		if err := serveRegistry(*serveHTTP, gateway); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+	case "api":
+		apiConnStr := apiCmd.String("connStr", "", "Orca connection string (defaults to orcaConnectionString in orca.json, then local Orca)")
+		apiConfigPath := apiCmd.String("config", findProjectConfig(), "Path to orca.json configuration file. Used to get the connection string.")
+		apiSecure := apiCmd.Bool("secure", false, "Set to connect to Orca core with System Default Root CA credentials (via TLS)")
+		apiCACert := apiCmd.String("caCert", "", "Path to custom CA certificate file (PEM format) for TLS verification")
+		apiTimeout := apiCmd.Duration("timeout", 30*time.Second, "How long the call may take before giving up")
+		apiList := apiCmd.Bool("list", false, "List the methods of the core, as `orca api list` does")
+		apiNoReflect := apiCmd.Bool("no-reflect", false, "Use the API this CLI was built with rather than asking the core for its API through gRPC reflection")
+		var apiHeaders headerFlag
+		apiCmd.Var(&apiHeaders, "H", "Header to send as gRPC metadata, e.g. \"authorization: Bearer <token>\" (repeatable)")
+		addGRPCFlags(apiCmd)
+
+		apiCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca api [options] <method> [request]\n")
+			fmt.Fprintf(os.Stderr, "       orca api list\n")
+			fmt.Fprintf(os.Stderr, "       orca api describe <method>\n\n")
+			fmt.Fprintf(os.Stderr, "Call an RPC of the core, including those the CLI has no command for, printing the\n")
+			fmt.Fprintf(os.Stderr, "response as JSON. The request is the JSON form of the method's request message,\n")
+			fmt.Fprintf(os.Stderr, "given inline, from a file as @path, or from stdin as -. It defaults to {}.\n\n")
+			fmt.Fprintf(os.Stderr, "The methods and their messages are read from the core through gRPC server\n")
+			fmt.Fprintf(os.Stderr, "reflection, so methods of newer cores can be called. Cores without reflection are\n")
+			fmt.Fprintf(os.Stderr, "called with the API this CLI was built with.\n\n")
+			fmt.Fprintf(os.Stderr, "Actions:\n")
+			fmt.Fprintf(os.Stderr, "  list      List the methods of the core with their request and response messages\n")
+			fmt.Fprintf(os.Stderr, "  describe  Show the fields of the request message of a method\n\n")
+			fmt.Fprintf(os.Stderr, "Examples:\n")
+			fmt.Fprintf(os.Stderr, "  orca api Expose\n")
+			fmt.Fprintf(os.Stderr, "  orca api -connStr core.example.com:443 -secure -H \"authorization: Bearer $TOKEN\" Expose\n")
+			fmt.Fprintf(os.Stderr, "  orca api EmitWindow @window.json\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			apiCmd.PrintDefaults()
+		}
+
+		apiCmd.Parse(os.Args[2:])
+
+		if apiCmd.Arg(0) == "help" || apiCmd.Arg(0) == "-h" || (apiCmd.NArg() == 0 && !*apiList) {
+			apiCmd.Usage()
+			exit(0)
+		}
+
+		action, args := "call", apiCmd.Args()
+		switch {
+		case *apiList:
+			action = "list"
+		case apiCmd.Arg(0) == "list" || apiCmd.Arg(0) == "describe":
+			action, args = apiCmd.Arg(0), args[1:]
+		}
+		maxArgs := map[string]int{"list": 0, "describe": 1, "call": 2}[action]
+		if action == "describe" && len(args) == 0 {
+			fmt.Fprintln(os.Stderr)
+			printError("describe takes the name of a method")
+			fmt.Fprintln(os.Stderr, "Run 'orca api list' to list them.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		if len(args) > maxArgs {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", args[maxArgs]))
+			fmt.Fprintln(os.Stderr, "Run 'orca api help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		var err error
+		address := cmp.Or(*apiConnStr, loadProjectConfig(*apiConfigPath).OrcaConnectionString)
+		if address == "" {
+			checkDockerInstalled()
+			if address, err = localCoreAddress(); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+		transportCreds, err := coreTransportCredentials(*apiSecure, *apiCACert)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		conn, err := grpc.NewClient(address, grpcDialOptions(transportCreds)...)
+		if err != nil {
+			printError(fmt.Sprintf("Issue preparing to contact Orca: %v", err))
+			exit(1)
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), *apiTimeout)
+		defer cancel()
+		if len(apiHeaders) > 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, apiHeaders.pairs()...)
+		}
+		api, err := loadCoreAPI(ctx, conn, !*apiNoReflect)
+		if err != nil {
+			message := fmt.Sprintf("Could not reflect the API of the core, using the API this CLI was built with: %v", err)
+			if action == "call" {
+				logDebug("%s", message)
+			} else {
+				fmt.Fprintln(os.Stderr, warningStyle.Render(message))
+			}
+		}
+
+		switch action {
+		case "list":
+			printCoreMethods(os.Stdout, api)
+			exit(0)
+		case "describe":
+			method, err := api.findMethod(args[0])
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			fmt.Printf("%s(%s) returns %s\n", method.Name(), method.Input().FullName(), method.Output().FullName())
+			printMessageFields(os.Stdout, method.Input())
+			exit(0)
+		}
+
+		method, err := api.findMethod(args[0])
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		request, err := readAPIRequest(apiCmd.Arg(1), os.Stdin)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		response, err := invokeCoreMethod(ctx, conn, method, request)
+		if err != nil {
+			printError(fmt.Sprintf("%s failed: %v", method.Name(), err))
+			exit(1)
+		}
+		if err := printAPIResponse(os.Stdout, response); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+	case "service":
+		serviceConfigPath := serviceCmd.String("config", findProjectConfig(), "Path to orca.json configuration file the stack is started with")
+		serviceSystem := serviceCmd.Bool("system", false, "Install a systemd unit for the machine, started on boot before anyone logs in, rather than for the user. Requires root")
+		serviceNow := serviceCmd.Bool("now", false, "Also start the service now, with install")
+
+		serviceCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca service [options] <install|uninstall|status|print>\n\n")
+			fmt.Fprintf(os.Stderr, "Run `orca start` when the machine boots or you log in, so that a shared machine\n")
+			fmt.Fprintf(os.Stderr, "keeps the stack of the project available:\n\n")
+			fmt.Fprintf(os.Stderr, "  linux    a systemd unit, which also runs `orca stop` on shutdown. User units\n")
+			fmt.Fprintf(os.Stderr, "           start on boot once lingering is enabled with `loginctl enable-linger`\n")
+			fmt.Fprintf(os.Stderr, "  macOS    a launchd agent, started when you log in\n")
+			fmt.Fprintf(os.Stderr, "  windows  a Task Scheduler task, started when you log in\n\n")
+			fmt.Fprintf(os.Stderr, "Docker Desktop stops the stack as it quits on macOS and Windows.\n\n")
+			fmt.Fprintf(os.Stderr, "Actions:\n")
+			fmt.Fprintf(os.Stderr, "  install    Install and enable the service\n")
+			fmt.Fprintf(os.Stderr, "  uninstall  Disable and remove the service, leaving the stack running\n")
+			fmt.Fprintf(os.Stderr, "  status     Show the state of the service\n")
+			fmt.Fprintf(os.Stderr, "  print      Print what install would write, without installing it\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			serviceCmd.PrintDefaults()
+		}
+
+		serviceCmd.Parse(os.Args[2:])
+
+		if serviceCmd.NArg() == 0 || serviceCmd.Arg(0) == "help" || serviceCmd.Arg(0) == "-h" {
+			serviceCmd.Usage()
+			exit(0)
+		}
+
+		action := serviceCmd.Arg(0)
+		if serviceCmd.NArg() > 1 || !slices.Contains(subcommandActions["service"], action) {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", serviceCmd.Arg(serviceCmd.NArg()-1)))
+			fmt.Fprintln(os.Stderr, "Run 'orca service help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		service, err := newStackService(*serviceConfigPath, *serviceSystem)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		switch action {
+		case "install":
+			if _, err := os.Stat(service.ConfigPath); err != nil {
+				printError(fmt.Sprintf("Config file not found: %s. Run `orca init` first, or pass -config", service.ConfigPath))
+				exit(1)
+			}
+			if err := installService(service, *serviceNow); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Installed %s, starting the stack of project %s with %s", service.Name, projectLabelValue(stackProject), service.ConfigPath)))
+			if service.startsOnLogin() {
+				fmt.Fprintln(os.Stderr, "To start it on boot rather than when you log in, run: loginctl enable-linger")
+			}
+
+		case "uninstall":
+			if err := uninstallService(service); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Uninstalled %s. The stack was left as it is, stop it with `orca stop`", service.Name)))
+
+		case "status":
+			code, err := showServiceStatus(service)
+			if err != nil {
+				printError(err.Error())
+			}
+			exit(code)
+
+		case "print":
+			definition, err := service.definition()
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			if path, _ := service.definitionPath(); path != "" {
+				fmt.Fprintf(os.Stderr, "# %s\n", path)
+			}
+			fmt.Print(definition)
+			if !strings.HasSuffix(definition, "\n") {
+				fmt.Println()
+			}
+		}
+
+	case "watch":
+		interval := watchCmd.Duration("interval", time.Second*5, "How often to check the containers")
+		maxRestarts := watchCmd.Int("max-restarts", 5, "Maximum number of restarts per container before giving up")
+
+		watchCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca watch [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Monitor the Orca containers and restart any that exit unexpectedly\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			watchCmd.PrintDefaults()
+		}
+
+		watchCmd.Parse(os.Args[2:])
+
+		if watchCmd.NArg() > 0 && (watchCmd.Arg(0) == "help" || watchCmd.Arg(0) == "-h") {
+			watchCmd.Usage()
+			exit(0)
+		}
+
+		if watchCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", watchCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca watch help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		checkDockerInstalled()
+
+		fmt.Fprintln(os.Stderr)
+		watchContainers(*interval, *maxRestarts)
+		fmt.Fprintln(os.Stderr)
+
+	case "health":
+		line := healthCmd.Bool("line", false, "Print a single-line summary in addition to setting the exit code")
+		timeout := healthCmd.Duration("timeout", time.Second*5, "Timeout for each health probe")
+
+		healthCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca health [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Probe all Orca components and exit with 0 (healthy), 1 (degraded) or 2 (down)\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			healthCmd.PrintDefaults()
+		}
+
+		healthCmd.Parse(os.Args[2:])
+
+		if healthCmd.NArg() > 0 && (healthCmd.Arg(0) == "help" || healthCmd.Arg(0) == "-h") {
+			healthCmd.Usage()
+			exit(0)
+		}
+
+		if healthCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", healthCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca health help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		// docker is deliberately not checked up front: an unavailable daemon
+		// simply fails every probe and reports the stack as down
+		results := runHealthChecks(*timeout)
+		if *line {
+			fmt.Println(formatHealthLine(results))
+		}
+		exit(healthExitCode(results))
+
+	case "telemetry":
+		telemetryCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca telemetry <status|enable|disable>\n\n")
+			fmt.Fprintf(os.Stderr, "Manage opt-in anonymous usage telemetry. When enabled, command names, durations\n")
+			fmt.Fprintf(os.Stderr, "and error classes are spooled locally and exported via OTLP to the configured endpoint.\n")
+		}
+
+		telemetryCmd.Parse(os.Args[2:])
+
+		if telemetryCmd.NArg() > 0 && (telemetryCmd.Arg(0) == "help" || telemetryCmd.Arg(0) == "-h") {
+			telemetryCmd.Usage()
+			exit(0)
+		}
+
+		if telemetryCmd.NArg() > 1 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", telemetryCmd.Arg(1)))
+			fmt.Fprintln(os.Stderr, "Run 'orca telemetry help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		config, err := loadGlobalConfig()
+		if err != nil {
+			printError(fmt.Sprintf("Failed to load global config: %v", err))
+			exit(1)
+		}
+
+		fmt.Fprintln(os.Stderr)
+		switch telemetryCmd.Arg(0) {
+		case "", "status":
+			showTelemetryStatus(config)
+		case "enable", "disable":
+			config.Telemetry.Enabled = telemetryCmd.Arg(0) == "enable"
+			if err := saveGlobalConfig(config); err != nil {
+				printError(fmt.Sprintf("Failed to update global config: %v", err))
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Telemetry %sd.", telemetryCmd.Arg(0))))
+		default:
+			printError(fmt.Sprintf("Unknown telemetry action: %s", telemetryCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca telemetry help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr)
+
+	case "update-check":
+		updateCheckCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca update-check <status|enable|disable>\n\n")
+			fmt.Fprintf(os.Stderr, "Manage the notice printed when a newer CLI release is available. The latest\n")
+			fmt.Fprintf(os.Stderr, "release is checked at most once a day. Set ORCA_NO_UPDATE_CHECK=1 to disable it per shell.\n")
+		}
+
+		updateCheckCmd.Parse(os.Args[2:])
+
+		if updateCheckCmd.NArg() > 0 && (updateCheckCmd.Arg(0) == "help" || updateCheckCmd.Arg(0) == "-h") {
+			updateCheckCmd.Usage()
+			exit(0)
+		}
+
+		if updateCheckCmd.NArg() > 1 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", updateCheckCmd.Arg(1)))
+			fmt.Fprintln(os.Stderr, "Run 'orca update-check help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		config, err := loadGlobalConfig()
+		if err != nil {
+			printError(fmt.Sprintf("Failed to load global config: %v", err))
+			exit(1)
+		}
+
+		fmt.Fprintln(os.Stderr)
+		switch updateCheckCmd.Arg(0) {
+		case "", "status":
+			showUpdateCheckStatus(config)
+		case "enable", "disable":
+			config.UpdateCheck.Disabled = updateCheckCmd.Arg(0) == "disable"
+			if err := saveGlobalConfig(config); err != nil {
+				printError(fmt.Sprintf("Failed to update global config: %v", err))
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Update notices %sd.", updateCheckCmd.Arg(0))))
+		default:
+			printError(fmt.Sprintf("Unknown update-check action: %s", updateCheckCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca update-check help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr)
+
+	case "psql":
+		// all arguments are forwarded to psql, so no flags are parsed here
+		if len(os.Args) > 2 && (os.Args[2] == "help" || os.Args[2] == "-h") {
+			fmt.Fprintf(os.Stderr, "Usage: orca psql [psql arguments]\n\n")
+			fmt.Fprintf(os.Stderr, "Open an interactive psql session inside the Postgres container, connected\n")
+			fmt.Fprintf(os.Stderr, "to the Orca store. Any arguments are passed through to psql, e.g.\n\n")
+			fmt.Fprintf(os.Stderr, "  orca psql -c 'select count(*) from windows'\n")
+			exit(0)
+		}
+
+		checkDockerInstalled()
+
+		psqlArgs := append([]string{"psql", "-U", "orca", "-d", "orca"}, os.Args[2:]...)
+		exit(execInContainer(pgContainerName, psqlArgs...))
+
+	case "redis-cli":
+		// all arguments are forwarded to redis-cli, so no flags are parsed here
+		if len(os.Args) > 2 && (os.Args[2] == "help" || os.Args[2] == "-h") {
+			fmt.Fprintf(os.Stderr, "Usage: orca redis-cli [redis-cli arguments]\n\n")
+			fmt.Fprintf(os.Stderr, "Open an interactive redis-cli session inside the Redis container. Any\n")
+			fmt.Fprintf(os.Stderr, "arguments are passed through to redis-cli, e.g.\n\n")
+			fmt.Fprintf(os.Stderr, "  orca redis-cli info memory\n")
+			exit(0)
+		}
+
+		checkDockerInstalled()
+
+		// the stack's Redis instance is started without a password,
+		// so no credentials need to be passed
+		redisArgs := append([]string{"redis-cli"}, os.Args[2:]...)
+		exit(execInContainer(redisContainerName, redisArgs...))
+
+	case "shell":
+		shell := shellCmd.String("shell", "", "Shell to run, e.g. sh (defaults to bash where the image has it, otherwise sh)")
+
+		shellCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca shell [options] <component>\n\n")
+			fmt.Fprintf(os.Stderr, "Open an interactive shell inside a stack container, for debugging. The\n")
+			fmt.Fprintf(os.Stderr, "component is one of: %s\n\n", strings.Join(componentNames(), ", "))
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			shellCmd.PrintDefaults()
+		}
+
+		shellCmd.Parse(os.Args[2:])
+
+		if shellCmd.NArg() > 0 && (shellCmd.Arg(0) == "help" || shellCmd.Arg(0) == "-h") {
+			shellCmd.Usage()
+			exit(0)
+		}
+
+		if shellCmd.NArg() != 1 {
+			fmt.Fprintln(os.Stderr)
+			printError("Expected exactly one component")
+			fmt.Fprintln(os.Stderr, "Run 'orca shell help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		containerName, err := componentContainer(shellCmd.Arg(0))
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		checkDockerInstalled()
+		exit(openShell(containerName, *shell))
+
+	case "cp":
+		archive := cpCmd.Bool("a", false, "Archive mode, copying all uid/gid information")
+		followLink := cpCmd.Bool("L", false, "Always follow symbolic links in the source path")
+
+		cpCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca cp [options] <component>:<path> <local-path>\n")
+			fmt.Fprintf(os.Stderr, "       orca cp [options] <local-path> <component>:<path>\n\n")
+			fmt.Fprintf(os.Stderr, "Copy files or directories between a stack container and the host, e.g.\n\n")
+			fmt.Fprintf(os.Stderr, "  orca cp postgres:/var/lib/postgresql/data/pg_hba.conf .\n\n")
+			fmt.Fprintf(os.Stderr, "The component is one of: %s\n\n", strings.Join(componentNames(), ", "))
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			cpCmd.PrintDefaults()
+		}
+
+		cpCmd.Parse(os.Args[2:])
+
+		if cpCmd.NArg() > 0 && (cpCmd.Arg(0) == "help" || cpCmd.Arg(0) == "-h") {
+			cpCmd.Usage()
+			exit(0)
+		}
+
+		if cpCmd.NArg() != 2 {
+			fmt.Fprintln(os.Stderr)
+			printError("Expected a source and a destination")
+			fmt.Fprintln(os.Stderr, "Run 'orca cp help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		var cpFlags []string
+		if *archive {
+			cpFlags = append(cpFlags, "-a")
+		}
+		if *followLink {
+			cpFlags = append(cpFlags, "-L")
+		}
+
+		checkDockerInstalled()
+		exit(copyFiles(cpCmd.Arg(0), cpCmd.Arg(1), cpFlags...))
+
+	case "port":
+		portOutput := portCmd.String("o", "text", "Output format - text|json|template=<go-template>")
+		portJSON := portCmd.Bool("json", false, "Shorthand for -o json")
+
+		portCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca port [options] [component]\n\n")
+			fmt.Fprintf(os.Stderr, "List the internal to host port mappings of every running stack container, or\n")
+			fmt.Fprintf(os.Stderr, "of one component from: %s\n\n", strings.Join(componentNames(), ", "))
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			portCmd.PrintDefaults()
+		}
+
+		portCmd.Parse(os.Args[2:])
+
+		if portCmd.NArg() > 0 && (portCmd.Arg(0) == "help" || portCmd.Arg(0) == "-h") {
+			portCmd.Usage()
+			exit(0)
+		}
+
+		if portCmd.NArg() > 1 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", portCmd.Arg(1)))
+			fmt.Fprintln(os.Stderr, "Run 'orca port help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		if *portJSON {
+			*portOutput = "json"
+		}
+		if err := validateOutputFormat(*portOutput); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		components := componentNames()
+		if portCmd.NArg() == 1 {
+			if _, err := componentContainer(portCmd.Arg(0)); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			components = []string{portCmd.Arg(0)}
+		}
+
+		checkDockerInstalled()
+		mappings, err := collectPortMappings(components)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		if *portOutput != "text" {
+			if err := renderOutput(os.Stdout, mappings, *portOutput); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			break
+		}
+		if len(mappings) == 0 {
+			fmt.Fprintln(os.Stderr, "No published ports. Start the stack with `orca start`")
+			break
+		}
+		showPortMappings(os.Stdout, mappings)
+
+	case "sql":
+		format := sqlCmd.String("o", "table", "Output format - table|csv|json")
+
+		sqlCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca sql [options] <query>\n\n")
+			fmt.Fprintf(os.Stderr, "Run a query against the Postgres store and print the result.\n")
+			fmt.Fprintf(os.Stderr, "Pass '-' as the query to read it from stdin.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			sqlCmd.PrintDefaults()
+			fmt.Fprintf(os.Stderr, "\nExample:\n")
+			fmt.Fprintf(os.Stderr, "  orca sql -o json \"select count(*) from windows\"\n")
+		}
+
+		sqlCmd.Parse(os.Args[2:])
+
+		if sqlCmd.NArg() > 0 && (sqlCmd.Arg(0) == "help" || sqlCmd.Arg(0) == "-h") {
+			sqlCmd.Usage()
+			exit(0)
+		}
+
+		if sqlCmd.NArg() != 1 {
+			fmt.Fprintln(os.Stderr)
+			printError("Expected exactly one query argument")
+			fmt.Fprintln(os.Stderr, "Run 'orca sql help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		checkDockerInstalled()
+		if err := lockStack("sql"); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		if status := getContainerStatus(pgContainerName); status != "running" {
+			printError("Postgres store is not running. Start Orca with `orca start`")
+			exit(1)
+		}
+
+		query, err := readQueryArg(sqlCmd.Arg(0))
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		if err := printQueryResult(os.Stdout, query, *format); err != nil {
+			printError(fmt.Sprintf("Query failed: %v", err))
+			exit(1)
+		}
+
+	case "seed":
+		dryRun := seedCmd.Bool("dry-run", false, "List the fixtures that would be loaded without loading them")
+
+		seedCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca seed [options] <fixture-dir>\n\n")
+			fmt.Fprintf(os.Stderr, "Load fixture data into the Postgres store, in file name order.\n\n")
+			fmt.Fprintf(os.Stderr, "  *.sql   files are executed as-is\n")
+			fmt.Fprintf(os.Stderr, "  *.json  files hold an array of row objects, inserted into the table named\n")
+			fmt.Fprintf(os.Stderr, "          by the file, ignoring any ordering prefix (e.g. 01_window_type.json)\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			seedCmd.PrintDefaults()
+		}
+
+		seedCmd.Parse(os.Args[2:])
+
+		if seedCmd.NArg() > 0 && (seedCmd.Arg(0) == "help" || seedCmd.Arg(0) == "-h") {
+			seedCmd.Usage()
+			exit(0)
+		}
+
+		if seedCmd.NArg() != 1 {
+			fmt.Fprintln(os.Stderr)
+			printError("Expected exactly one fixture directory")
+			fmt.Fprintln(os.Stderr, "Run 'orca seed help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		checkDockerInstalled()
+		if !*dryRun {
+			if err := lockStack("seed"); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+
+		if status := getContainerStatus(pgContainerName); status != "running" {
+			printError("Postgres store is not running. Start Orca with `orca start`")
+			exit(1)
+		}
+
+		fmt.Fprintln(os.Stderr)
+		if err := seedFixtures(seedCmd.Arg(0), *dryRun); err != nil {
+			printError(fmt.Sprintf("Seeding failed: %v", err))
+			exit(1)
+		}
+		if !*dryRun {
+			fmt.Fprintln(os.Stderr)
+			fmt.Fprintln(os.Stderr, renderSuccess("Fixtures loaded successfully."))
+		}
+		fmt.Fprintln(os.Stderr)
+
+	case "snapshot":
+		assumeYes := snapshotCmd.Bool("y", false, "Skip the confirmation prompt when restoring or deleting")
+		snapshotOutput := snapshotCmd.String("o", "text", "Output format of list - text|json|template=<go-template>")
+		snapshotTimeout := snapshotCmd.Duration("startup-timeout", 0, "How long to wait for the store to become ready when resuming the stack (default 15s)")
+		snapshotPollInterval := snapshotCmd.Duration("poll-interval", 0, "How often to check readiness while waiting (default 500ms)")
+
+		snapshotCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca snapshot [options] <create|restore|delete> <name>\n")
+			fmt.Fprintf(os.Stderr, "       orca snapshot [-o format] list\n\n")
+			fmt.Fprintf(os.Stderr, "Save and restore named snapshots of the Postgres and Redis data. Running\n")
+			fmt.Fprintf(os.Stderr, "containers are briefly stopped so that both stores are captured consistently.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			snapshotCmd.PrintDefaults()
+		}
+
+		snapshotCmd.Parse(os.Args[2:])
+
+		if snapshotCmd.NArg() > 0 && (snapshotCmd.Arg(0) == "help" || snapshotCmd.Arg(0) == "-h") {
+			snapshotCmd.Usage()
+			exit(0)
+		}
+
+		action := snapshotCmd.Arg(0)
+		if action == "" {
+			action = "list"
+		}
+		if (action == "list" && snapshotCmd.NArg() > 1) || (action != "list" && snapshotCmd.NArg() != 2) {
+			fmt.Fprintln(os.Stderr)
+			printError("Invalid arguments")
+			fmt.Fprintln(os.Stderr, "Run 'orca snapshot help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		name := snapshotCmd.Arg(1)
+		if err := validateOutputFormat(*snapshotOutput); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if err := setReadinessWait(nil, *snapshotTimeout, *snapshotPollInterval); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		checkDockerInstalled()
+		if action != "list" {
+			if err := lockStack("snapshot " + action); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+
+		if action == "list" && *snapshotOutput != "text" {
+			snapshots, err := listSnapshots()
+			if err == nil {
+				if snapshots == nil {
+					snapshots = []snapshotInfo{}
+				}
+				err = renderOutput(os.Stdout, snapshots, *snapshotOutput)
+			}
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			break
+		}
+
+		fmt.Fprintln(os.Stderr)
+		var err error
+		switch action {
+		case "list":
+			err = showSnapshots()
+		case "create":
+			err = createSnapshot(name)
+			if err == nil {
+				fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Snapshot '%s' created.", name)))
+			}
+		case "restore", "delete":
+			if !*assumeYes {
+				prompt := fmt.Sprintf("This will replace all current stack data with snapshot '%s'. Continue? (y/N): ", name)
+				if action == "delete" {
+					prompt = fmt.Sprintf("Delete snapshot '%s'? (y/N): ", name)
+				}
+				fmt.Fprint(os.Stderr, warningStyle.Render(prompt))
+
+				var response string
+				fmt.Scanln(&response)
+				if strings.ToLower(strings.TrimSpace(response)) != "y" {
+					fmt.Fprintln(os.Stderr, "Operation cancelled.")
+					exit(0)
+				}
+			}
+			if action == "restore" {
+				err = restoreSnapshot(name)
+			} else {
+				err = deleteSnapshot(name)
+			}
+			if err == nil {
+				fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Snapshot '%s' %sd.", name, action)))
+			}
+		default:
+			err = fmt.Errorf("unknown snapshot action: %s", action)
+		}
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr)
+
+	case "backup":
+		backupTo := backupCmd.String("to", "", "File or s3://bucket/path to write the archive to (default orca-backup-<time>.tar in the current directory)")
+		encrypt := backupCmd.Bool("encrypt", false, "Encrypt the archive to the age or GPG recipients of backup.age or backup.gpg in orca.json")
+		backupConfigPath := backupCmd.String("config", findProjectConfig(), "Path to orca.json configuration file. Used for the recipients of -encrypt")
+		backupTimeout := backupCmd.Duration("startup-timeout", 0, "How long to wait for the store to become ready when resuming the stack (default 15s)")
+		backupPollInterval := backupCmd.Duration("poll-interval", 0, "How often to check readiness while waiting (default 500ms)")
+		backupEndpoint := backupCmd.String("endpoint-url", "", "S3-compatible endpoint to upload s3:// backups to (defaults to backup.endpoint in orca.json, then AWS)")
+
+		backupCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca backup [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Archive the Postgres and Redis data to a file, which `orca restore` restores on\n")
+			fmt.Fprintf(os.Stderr, "this or another machine. Running containers are briefly stopped so that both\n")
+			fmt.Fprintf(os.Stderr, "stores are captured consistently. With -encrypt the archive is encrypted with age\n")
+			fmt.Fprintf(os.Stderr, "or GPG, so telemetry data never rests on disk in plaintext.\n\n")
+			fmt.Fprintf(os.Stderr, "With -to s3://bucket/path the archive is uploaded to S3, or an S3-compatible store,\n")
+			fmt.Fprintf(os.Stderr, "with the AWS CLI and the credentials it resolves. A path ending in / is a prefix\n")
+			fmt.Fprintf(os.Stderr, "the archive is written under with its default name.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			backupCmd.PrintDefaults()
+		}
+
+		backupCmd.Parse(os.Args[2:])
+
+		if backupCmd.NArg() > 0 && (backupCmd.Arg(0) == "help" || backupCmd.Arg(0) == "-h") {
+			backupCmd.Usage()
+			exit(0)
+		}
+		if backupCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", strings.Join(backupCmd.Args(), " ")))
+			fmt.Fprintln(os.Stderr, "Run 'orca backup help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		config := loadProjectConfig(*backupConfigPath)
+		if err := setReadinessWait(config.Startup, *backupTimeout, *backupPollInterval); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		encryption := ""
+		if *encrypt {
+			_, encryption, _ = config.Backup.encryptCommand()
+		}
+		name := defaultBackupPath(encryption, time.Now())
+		path := cmp.Or(*backupTo, name)
+		var object string
+		if isS3URL(path) {
+			var err error
+			if object, err = s3ObjectURL(path, name); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			if err := checkAWSInstalled(); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			// the archive is uploaded once the stack is running again
+			dir, err := os.MkdirTemp("", "orca-backup-")
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			onExit(func(int) { os.RemoveAll(dir) })
+			path = filepath.Join(dir, name)
+		}
+
+		checkDockerInstalled()
+		if err := lockStack("backup"); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr)
+		if err := createBackup(path, config.Backup, *encrypt); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if object != "" {
+			fmt.Fprintf(os.Stderr, "Uploading to %s...\n", object)
+			if err := uploadS3(path, object, cmp.Or(*backupEndpoint, backupEndpointOf(config))); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			path = object
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Backup written to %s", path)))
+		fmt.Fprintln(os.Stderr)
+
+	case "restore":
+		restoreFrom := restoreCmd.String("from", "", "Archive written by `orca backup` to restore, as a file or s3://bucket/path")
+		identity := restoreCmd.String("identity", "", "age identity file to decrypt the archive with (defaults to backup.identity in orca.json)")
+		restoreConfigPath := restoreCmd.String("config", findProjectConfig(), "Path to orca.json configuration file. Used for the identity of age encrypted archives")
+		restoreYes := restoreCmd.Bool("y", false, "Skip the confirmation prompt")
+		restoreTimeout := restoreCmd.Duration("startup-timeout", 0, "How long to wait for the store to become ready when resuming the stack (default 15s)")
+		restorePollInterval := restoreCmd.Duration("poll-interval", 0, "How often to check readiness while waiting (default 500ms)")
+		restoreEndpoint := restoreCmd.String("endpoint-url", "", "S3-compatible endpoint to download s3:// backups from (defaults to backup.endpoint in orca.json, then AWS)")
+
+		restoreCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca restore [options] -from <file>\n\n")
+			fmt.Fprintf(os.Stderr, "Replace the Postgres and Redis data with an archive written by `orca backup`.\n")
+			fmt.Fprintf(os.Stderr, "Encrypted archives are decrypted first: GPG with the keys of your keyring, age\n")
+			fmt.Fprintf(os.Stderr, "with the identity of -identity or backup.identity in orca.json. Archives in S3 are\n")
+			fmt.Fprintf(os.Stderr, "downloaded with the AWS CLI. The whole archive is checked and extracted into\n")
+			fmt.Fprintf(os.Stderr, "staging volumes before anything is replaced, so a damaged archive leaves the data\n")
+			fmt.Fprintf(os.Stderr, "as it was.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			restoreCmd.PrintDefaults()
+		}
+
+		restoreCmd.Parse(os.Args[2:])
+
+		if restoreCmd.NArg() > 0 && (restoreCmd.Arg(0) == "help" || restoreCmd.Arg(0) == "-h") {
+			restoreCmd.Usage()
+			exit(0)
+		}
+		if restoreCmd.NArg() > 0 || *restoreFrom == "" {
+			fmt.Fprintln(os.Stderr)
+			if *restoreFrom == "" {
+				printError("-from is required")
+			} else {
+				printError(fmt.Sprintf("Unknown argument: %s", strings.Join(restoreCmd.Args(), " ")))
+			}
+			fmt.Fprintln(os.Stderr, "Run 'orca restore help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		config := loadProjectConfig(*restoreConfigPath)
+		if err := setReadinessWait(config.Startup, *restoreTimeout, *restorePollInterval); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		identityPath := *identity
+		if identityPath == "" && config.Backup != nil && config.Backup.Identity != "" {
+			identityPath = config.Backup.Identity
+			if !filepath.IsAbs(identityPath) {
+				identityPath = filepath.Join(filepath.Dir(*restoreConfigPath), identityPath)
+			}
+		}
+
+		if !*restoreYes {
+			fmt.Fprint(os.Stderr, warningStyle.Render(fmt.Sprintf("This will replace all current stack data with %s. Continue? (y/N): ", *restoreFrom)))
+			var response string
+			fmt.Scanln(&response)
+			if strings.ToLower(strings.TrimSpace(response)) != "y" {
+				fmt.Fprintln(os.Stderr, "Operation cancelled.")
+				exit(0)
+			}
+		}
+
+		checkDockerInstalled()
+		if err := lockStack("restore"); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr)
+		archivePath := *restoreFrom
+		if isS3URL(archivePath) {
+			fmt.Fprintf(os.Stderr, "Downloading %s...\n", archivePath)
+			local, err := downloadS3(archivePath, cmp.Or(*restoreEndpoint, backupEndpointOf(config)))
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			onExit(func(int) { os.RemoveAll(filepath.Dir(local)) })
+			archivePath = local
+		}
+		if err := restoreBackup(archivePath, identityPath); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Restored %s", *restoreFrom)))
+		fmt.Fprintln(os.Stderr)
+
+	case "logs":
+		follow := logsCmd.Bool("f", false, "Follow the logs as they are written")
+		tail := logsCmd.String("tail", "all", "Number of lines to show from the end of each log")
+		since := logsCmd.String("since", "", "Show lines written since a time, or for a duration before now, e.g. 1h, 2d or 2006-01-02T15:04:05Z")
+		until := logsCmd.String("until", "", "Show lines written before a time, or a duration before now")
+		level := logsCmd.String("level", "", "Show lines at least this severe - "+strings.Join(coreLogLevels, "|"))
+		grep := logsCmd.String("grep", "", "Show lines matching a regular expression, (?i) to ignore case")
+		raw := logsCmd.Bool("raw", false, "Show structured log lines as written, instead of as time, level and message")
+		correlate := logsCmd.String("correlate", "", "Show the lines of every component and processor mentioning an id, such as a window id, in the order they were logged")
+
+		logsCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca logs [options] [component...]\n\n")
+			fmt.Fprintf(os.Stderr, "Show the logs of stack components, by default the core. Components are %s,\n", strings.Join(stackComponents, ", "))
+			fmt.Fprintf(os.Stderr, "companion services, deployed processors by name, or all for every stack component\n")
+			fmt.Fprintf(os.Stderr, "and processor. Lines of several components are prefixed with the component they\n")
+			fmt.Fprintf(os.Stderr, "come from. The core's structured log lines\n")
+			fmt.Fprintf(os.Stderr, "are shown as their time, level and message, then their attributes, unless -raw\n")
+			fmt.Fprintf(os.Stderr, "is set.\n\n")
+			fmt.Fprintf(os.Stderr, "-since and -until are passed to docker. -level and -grep filter the lines read;\n")
+			fmt.Fprintf(os.Stderr, "lines without a level, such as those of a stack trace, take the level of the line\n")
+			fmt.Fprintf(os.Stderr, "before them. For example, the core's errors over the last hour:\n\n")
+			fmt.Fprintf(os.Stderr, "  orca logs -since 1h -level error core\n\n")
+			fmt.Fprintf(os.Stderr, "-correlate follows a window or request id across the stack: the lines of every\n")
+			fmt.Fprintf(os.Stderr, "component and deployed processor mentioning it are interleaved by time. For\n")
+			fmt.Fprintf(os.Stderr, "example, everything logged about window 42 today:\n\n")
+			fmt.Fprintf(os.Stderr, "  orca logs -since 1d -correlate 42\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			logsCmd.PrintDefaults()
+		}
+
+		logsCmd.Parse(os.Args[2:])
+
+		if logsCmd.NArg() > 0 && (logsCmd.Arg(0) == "help" || logsCmd.Arg(0) == "-h") {
+			logsCmd.Usage()
+			exit(0)
+		}
+
+		if *correlate != "" && *follow {
+			printError("-correlate reads the logs written so far, and cannot be combined with -f")
+			exit(1)
+		}
+		components := logsCmd.Args()
+		if len(components) == 0 && *correlate != "" {
+			components = []string{"all"}
+		} else if len(components) == 0 {
+			components = []string{componentCore}
+		}
+
+		filter, err := newLogFilter(*level, *grep)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		options := logOptions{follow: *follow}
+		if *tail != "all" {
+			if lines, err := strconv.Atoi(*tail); err != nil || lines < 0 {
+				printError(fmt.Sprintf("invalid -tail %q, must be a number of lines or all", *tail))
+				exit(1)
+			}
+			options.tail = *tail
+		}
+		now := time.Now()
+		if *since != "" {
+			if options.since, err = parseLogTime(*since, now); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+		if *until != "" {
+			if options.until, err = parseLogTime(*until, now); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+
+		checkDockerInstalled()
+		sources, err := resolveLogSources(components, listContainers().deployedProcessors())
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if *correlate == "" {
+			err = streamLogs(context.Background(), os.Stdout, sources, options, filter, *raw)
+		} else {
+			var found int
+			if found, err = correlateLogs(context.Background(), os.Stdout, sources, options, filter, *correlate, *raw); err == nil && found == 0 {
+				fmt.Fprintf(os.Stderr, "No lines mention %s. Widen the time range with -since, or check the id\n", *correlate)
+			}
+		}
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+	case "du":
+		duConfigPath := duCmd.String("config", findProjectConfig(), "Path to orca.json configuration file. Used to find the processor image of the project")
+		duOutput := duCmd.String("o", "text", "Output format - text|json|template=<go-template>")
+
+		duCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca du [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Show the disk used by the stack: its data volumes and snapshots, the images of\n")
+			fmt.Fprintf(os.Stderr, "its components and processors, and each table of the store when it is running.\n")
+			fmt.Fprintf(os.Stderr, "Old windows and results can be removed with `orca purge`, snapshots with\n")
+			fmt.Fprintf(os.Stderr, "`orca snapshot delete`.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			duCmd.PrintDefaults()
+		}
+
+		duCmd.Parse(os.Args[2:])
+
+		if duCmd.NArg() > 0 && (duCmd.Arg(0) == "help" || duCmd.Arg(0) == "-h") {
+			duCmd.Usage()
+			exit(0)
+		}
+		if duCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", strings.Join(duCmd.Args(), " ")))
+			fmt.Fprintln(os.Stderr, "Run 'orca du help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		if err := validateOutputFormat(*duOutput); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		checkDockerInstalled()
+		usage, err := collectDiskUsage(loadProjectConfig(*duConfigPath))
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if *duOutput != "text" {
+			if err := renderOutput(os.Stdout, usage, *duOutput); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			break
+		}
+		fmt.Fprintln(os.Stderr)
+		showDiskUsage(os.Stdout, usage)
+		fmt.Fprintln(os.Stderr)
+
+	case "results":
+		format := resultsCmd.String("format", "csv", "Export format - csv|parquet (parquet requires the DuckDB CLI)")
+		outDir := resultsCmd.String("out", "./results", "Output directory for the exported file")
+		algorithm := resultsCmd.String("algorithm", "", "Only export results of this algorithm, as name or name@version")
+		since := resultsCmd.String("since", "", "Only export windows starting at or after this time (RFC3339, YYYY-MM-DD, or a duration such as 24h or 7d)")
+		until := resultsCmd.String("until", "", "Only export windows ending at or before this time (RFC3339, YYYY-MM-DD, or a duration such as 24h or 7d)")
+
+		resultsCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca results export [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Export processed results, joined with their algorithm and window, to a file\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			resultsCmd.PrintDefaults()
+		}
+
+		if len(os.Args) < 3 || os.Args[2] == "help" || os.Args[2] == "-h" {
+			resultsCmd.Usage()
+			exit(0)
+		}
+
+		if os.Args[2] != "export" {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown results action: %s", os.Args[2]))
+			fmt.Fprintln(os.Stderr, "Run 'orca results help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		resultsCmd.Parse(os.Args[3:])
+
+		if resultsCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", resultsCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca results help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		filter := resultsFilter{Algorithm: *algorithm}
+		var err error
+		if *since != "" {
+			if filter.Since, err = parseTimeArg(*since); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+		if *until != "" {
+			if filter.Until, err = parseTimeArg(*until); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+
+		checkDockerInstalled()
+
+		if status := getContainerStatus(pgContainerName); status != "running" {
+			printError("Postgres store is not running. Start Orca with `orca start`")
+			exit(1)
+		}
+
+		path, err := exportResults(filter, *format, *outDir)
+		if err != nil {
+			printError(fmt.Sprintf("Export failed: %v", err))
+			exit(1)
+		}
+		fmt.Println(renderStdout(successStyle, fmt.Sprintf("Results exported to %s", path)))
+
+	case "purge":
+		olderThan := purgeCmd.String("older-than", "", "Delete data received longer ago than this, e.g. 30d or 12h (required)")
+		purgeWindows := purgeCmd.Bool("windows", false, "Delete aged windows, along with their results")
+		purgeResults := purgeCmd.Bool("results", false, "Delete only the results of aged windows, keeping the windows")
+		dryRun := purgeCmd.Bool("dry-run", false, "Report what would be deleted without deleting anything")
+		assumeYes := purgeCmd.Bool("y", false, "Skip the confirmation prompt")
+
+		purgeCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca purge -older-than <age> [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Delete aged data from the Postgres store. Age is measured from when a window\n")
+			fmt.Fprintf(os.Stderr, "was received. Without -windows or -results, both windows and results are deleted.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			purgeCmd.PrintDefaults()
+		}
+
+		purgeCmd.Parse(os.Args[2:])
+
+		if purgeCmd.NArg() > 0 && (purgeCmd.Arg(0) == "help" || purgeCmd.Arg(0) == "-h") {
+			purgeCmd.Usage()
+			exit(0)
+		}
+
+		if purgeCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", purgeCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca purge help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		if *olderThan == "" {
+			printError("-older-than is required")
+			fmt.Fprintln(os.Stderr, "Run 'orca purge help' for usage information.")
+			exit(1)
+		}
+		age, err := parseDurationWithDays(*olderThan)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		plan := purgePlan{
+			Age:     age,
+			Windows: *purgeWindows || !*purgeResults,
+		}
+
+		checkDockerInstalled()
+		if !*dryRun {
+			if err := lockStack("purge"); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+		}
+
+		if status := getContainerStatus(pgContainerName); status != "running" {
+			printError("Postgres store is not running. Start Orca with `orca start`")
+			exit(1)
+		}
+
+		counts, err := purgeCounts(plan)
+		if err != nil {
+			printError(fmt.Sprintf("Failed to inspect store: %v", err))
+			exit(1)
+		}
+
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "Data received longer ago than %s:\n", *olderThan)
+		fmt.Fprintf(os.Stderr, "  results: %s row(s)\n", counts["results"])
+		if plan.Windows {
+			fmt.Fprintf(os.Stderr, "  windows: %s row(s)\n", counts["windows"])
+		}
+		fmt.Fprintln(os.Stderr)
+
+		if *dryRun {
+			fmt.Fprintln(os.Stderr, "Dry run - nothing was deleted.")
+			exit(0)
+		}
+
+		if !*assumeYes {
+			fmt.Fprint(os.Stderr, warningStyle.Render("Permanently delete this data? (y/N): "))
+			var response string
+			fmt.Scanln(&response)
+			if strings.ToLower(strings.TrimSpace(response)) != "y" {
+				fmt.Fprintln(os.Stderr, "Operation cancelled.")
+				exit(0)
+			}
+		}
+
+		if err := executePurge(plan); err != nil {
+			printError(fmt.Sprintf("Purge failed: %v", err))
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess("Aged data purged."))
+		fmt.Fprintln(os.Stderr)
+
+	case "maintenance":
+		full := maintenanceCmd.Bool("full", false, "Run VACUUM FULL, reclaiming disk space but locking tables while it runs")
+		reportOnly := maintenanceCmd.Bool("report-only", false, "Only report table sizes and bloat, without vacuuming")
+		withRedis := maintenanceCmd.Bool("redis", false, "Also run Redis MEMORY DOCTOR")
+
+		maintenanceCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca maintenance [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Run VACUUM/ANALYZE on the Postgres store and report table sizes and bloat\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			maintenanceCmd.PrintDefaults()
+		}
+
+		maintenanceCmd.Parse(os.Args[2:])
+
+		if maintenanceCmd.NArg() > 0 && (maintenanceCmd.Arg(0) == "help" || maintenanceCmd.Arg(0) == "-h") {
+			maintenanceCmd.Usage()
+			exit(0)
+		}
+
+		if maintenanceCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", maintenanceCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca maintenance help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		checkDockerInstalled()
+
+		if status := getContainerStatus(pgContainerName); status != "running" {
+			printError("Postgres store is not running. Start Orca with `orca start`")
+			exit(1)
+		}
+
+		fmt.Fprintln(os.Stderr)
+		if !*reportOnly {
+			fmt.Fprint(os.Stderr, "Vacuuming and analyzing the store... ")
+			if err := vacuumStore(*full); err != nil {
+				fmt.Fprintln(os.Stderr, renderError("FAILED"))
+				printError(err.Error())
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr, renderSuccess("DONE"))
+			fmt.Fprintln(os.Stderr)
+		}
+
+		if err := showTableStats(); err != nil {
+			printError(fmt.Sprintf("Failed to read table statistics: %v", err))
+			exit(1)
+		}
+
+		if *withRedis {
+			fmt.Fprintln(os.Stderr)
+			report, err := redisMemoryDoctor()
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			fmt.Println("Redis memory doctor:")
+			fmt.Println(report)
+		}
+		fmt.Fprintln(os.Stderr)
+
+	case "clone":
+		noStart := cloneCmd.Bool("no-start", false, "Copy the data without starting the new stack")
+		cloneTimeout := cloneCmd.Duration("startup-timeout", 0, "How long to wait for each component to become ready (default 15s)")
+		clonePollInterval := cloneCmd.Duration("poll-interval", 0, "How often to check readiness while waiting (default 500ms)")
+
+		cloneCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca clone [options] <new-project>\n\n")
+			fmt.Fprintf(os.Stderr, "Duplicate the stack's Postgres and Redis data into a new project and start it.\n")
+			fmt.Fprintf(os.Stderr, "The new stack is managed with `orca --project <new-project> <command>`\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			cloneCmd.PrintDefaults()
+		}
+
+		cloneCmd.Parse(os.Args[2:])
+
+		if cloneCmd.NArg() > 0 && (cloneCmd.Arg(0) == "help" || cloneCmd.Arg(0) == "-h") {
+			cloneCmd.Usage()
+			exit(0)
+		}
+
+		if cloneCmd.NArg() != 1 {
+			fmt.Fprintln(os.Stderr)
+			printError("Expected exactly one new project name")
+			fmt.Fprintln(os.Stderr, "Run 'orca clone help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		if err := setReadinessWait(nil, *cloneTimeout, *clonePollInterval); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		checkDockerInstalled()
+		if err := lockStack("clone"); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		target := cloneCmd.Arg(0)
+		fmt.Fprintln(os.Stderr)
+		if err := cloneStack(target); err != nil {
+			printError(fmt.Sprintf("Clone failed: %v", err))
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr)
+
+		if *noStart {
+			fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Cloned into project %s. Start it with `orca --project %s start`", target, target)))
+			fmt.Fprintln(os.Stderr)
+			break
+		}
+
+		startArgs := []string{"--project", target, "start"}
+		if *cloneTimeout > 0 {
+			startArgs = append(startArgs, "-startup-timeout", cloneTimeout.String())
+		}
+		if *clonePollInterval > 0 {
+			startArgs = append(startArgs, "-poll-interval", clonePollInterval.String())
+		}
+		if err := runOrca(startArgs...); err != nil {
+			printError(fmt.Sprintf("Failed to start project %s: %v", target, err))
+			exit(1)
+		}
+
+	case "config":
+		configPath := configCmd.String("config", findProjectConfig(), "Path to orca.json configuration file")
+		global := configCmd.Bool("global", false, "Get or set a setting of the global CLI configuration instead of orca.json")
+
+		configCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca config [options] <get|set> <key> [value]\n")
+			fmt.Fprintf(os.Stderr, "       orca config <path|schema>\n\n")
+			fmt.Fprintf(os.Stderr, "Get or set a setting in orca.json. Values are validated before they are written. Core\n")
+			fmt.Fprintf(os.Stderr, "settings are applied to a running stack immediately.\n\n")
+			fmt.Fprintf(os.Stderr, "Like other commands, it uses the nearest orca.json in this directory or its parents,\n")
+			fmt.Fprintf(os.Stderr, "which `path` prints.\n\n")
+			fmt.Fprintf(os.Stderr, "orca.json is checked against its JSON Schema, which written files reference for\n")
+			fmt.Fprintf(os.Stderr, "editors. `schema` prints it, e.g. for editors without network access.\n\n")
+			fmt.Fprintf(os.Stderr, "Keys:\n")
+			for _, name := range configKeyNames() {
+				fmt.Fprintf(os.Stderr, "  %-26s %s\n", name, configKeys[name].Description)
+			}
+			fmt.Fprintf(os.Stderr, "\nGlobal keys, with -global:\n")
+			for _, name := range globalConfigKeyNames() {
+				fmt.Fprintf(os.Stderr, "  %-26s %s\n", name, globalConfigKeys[name].Description)
+			}
+			fmt.Fprintf(os.Stderr, "\nOptions:\n")
+			configCmd.PrintDefaults()
+		}
+
+		configCmd.Parse(os.Args[2:])
+
+		if configCmd.NArg() == 0 || configCmd.Arg(0) == "help" || configCmd.Arg(0) == "-h" {
+			configCmd.Usage()
+			exit(0)
+		}
+
+		action := configCmd.Arg(0)
+		if action == "path" && configCmd.NArg() == 1 {
+			path := *configPath
+			var err error
+			if *global {
+				path, err = globalConfigPath()
+			} else if _, err = os.Stat(path); os.IsNotExist(err) {
+				err = fmt.Errorf("no %s found in this directory or its parents", defaultConfigPath)
+			}
+			if err == nil {
+				path, err = filepath.Abs(path)
+			}
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			fmt.Println(path)
+			break
+		}
+		if action == "schema" && configCmd.NArg() == 1 {
+			schema, err := generateConfigSchema()
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			os.Stdout.Write(schema)
+			break
+		}
+		if !(action == "get" && configCmd.NArg() == 2) && !(action == "set" && configCmd.NArg() == 3) {
+			fmt.Fprintln(os.Stderr)
+			printError("Expected `get <key>`, `set <key> <value>`, `path` or `schema`")
+			fmt.Fprintln(os.Stderr, "Run 'orca config help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		if *global {
+			key, err := lookupGlobalConfigKey(configCmd.Arg(1))
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			config, err := loadGlobalConfig()
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			if action == "get" {
+				fmt.Println(key.Get(config))
+				break
+			}
+			if err := key.Set(config, configCmd.Arg(2)); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			if err := saveGlobalConfig(config); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			path, _ := globalConfigPath()
+			fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Set %s to %s in %s", configCmd.Arg(1), configCmd.Arg(2), path)))
+			break
+		}
+
+		key, err := lookupConfigKey(configCmd.Arg(1))
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		config := loadProjectConfig(*configPath)
+		problems, err := validateProjectConfigFile(*configPath)
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if action == "get" {
+			if len(problems) > 0 {
+				fmt.Fprintln(os.Stderr, warningStyle.Render(schemaProblemsError(*configPath, problems).Error()))
+			}
+			fmt.Println(key.Get(config))
+			break
+		}
+		// settings the CLI does not know would be lost when the file is rewritten
+		if len(problems) > 0 {
+			printError(fmt.Sprintf("%v\nFix them before setting %s", schemaProblemsError(*configPath, problems), configCmd.Arg(1)))
+			exit(1)
+		}
+		if err := lockStack("config set"); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		if err := key.Set(config, configCmd.Arg(2)); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if err := writeProjectConfig(*configPath, config); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Set %s to %s in %s", configCmd.Arg(1), configCmd.Arg(2), *configPath)))
+
+		// recreate a running core so the new level takes effect
+		if configCmd.Arg(1) == "core.logLevel" && getContainerStatus(orcaContainerName) == "running" {
+			orcaEnv, err := coreEnv(config.Core, filepath.Dir(*configPath))
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			orcaPort, err := config.orcaHostPort()
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			if config.StackProject != stackProject {
+				orcaPort = 0
+			}
+			if err := removeOrcaIfEnvChanged(config.Core.logLevelEnv()); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			startOrca(networkName, orcaEnv, orcaPort)
+		}
+
+	case "repair":
+		dryRun := repairCmd.Bool("dry-run", false, "Only report what is wrong with the stack")
+		repairConfigPath := repairCmd.String("config", findProjectConfig(), "Path to orca.json, used when recreating missing resources")
+
+		repairCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca repair [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Compare the stack with what `orca start` creates, e.g. after an interrupted start.\n")
+			fmt.Fprintf(os.Stderr, "Containers that cannot be reused are removed, keeping their volumes, and missing\n")
+			fmt.Fprintf(os.Stderr, "or stopped resources are then created and started.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			repairCmd.PrintDefaults()
+		}
+
+		repairCmd.Parse(os.Args[2:])
+
+		if repairCmd.NArg() > 0 && (repairCmd.Arg(0) == "help" || repairCmd.Arg(0) == "-h") {
+			repairCmd.Usage()
+			exit(0)
+		}
+
+		if repairCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", repairCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca repair help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		checkDockerInstalled()
+		if err := lockStack("repair"); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		fmt.Fprintln(os.Stderr)
+		issues := findRepairIssues()
+		if len(issues) == 0 {
+			fmt.Fprintln(os.Stderr, renderSuccess("The stack is consistent, nothing to repair."))
+			fmt.Fprintln(os.Stderr)
+			break
+		}
+		showRepairIssues(issues)
+		fmt.Fprintln(os.Stderr)
+
+		needsStart := false
+		for _, issue := range issues {
+			needsStart = needsStart || !issue.ReportOnly
+		}
+		if *dryRun || !needsStart {
+			break
+		}
+
+		if err := applyRepairs(issues); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+
+		// start takes the lock itself to recreate what is missing
+		unlockStack()
+		startArgs := []string{"start", "-config", *repairConfigPath}
+		if stackProject != "" {
+			startArgs = append([]string{"--project", stackProject}, startArgs...)
+		}
+		if err := runOrca(startArgs...); err != nil {
+			printError(fmt.Sprintf("Starting the stack failed: %v", err))
+			exit(1)
+		}
+
+	case "doctor":
+		doctorFix := doctorCmd.Bool("fix", false, "Apply the fixes of the problems found, asking before each")
+		doctorYes := doctorCmd.Bool("y", false, "With -fix, apply every fix without asking")
+
+		doctorCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca doctor [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Diagnose the stack: a missing network or volume, stopped or crashed containers, and\n")
+			fmt.Fprintf(os.Stderr, "a lock file or daemon socket left by a command that was killed. With -fix, these are\n")
+			fmt.Fprintf(os.Stderr, "fixed in place once confirmed, and what needs recreating is left to `orca repair`.\n")
+			fmt.Fprintf(os.Stderr, "Exits with 1 when problems are found and not fixed.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			doctorCmd.PrintDefaults()
+		}
+
+		doctorCmd.Parse(os.Args[2:])
+
+		if doctorCmd.NArg() > 0 && (doctorCmd.Arg(0) == "help" || doctorCmd.Arg(0) == "-h") {
+			doctorCmd.Usage()
+			exit(0)
+		}
+
+		if doctorCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", doctorCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca doctor help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		checkDockerInstalled()
+		fmt.Fprintln(os.Stderr)
+		stateProblems := findStateProblems()
+		stackProblems := findStackProblems(findRepairIssues())
+		findings := append(append([]doctorFinding{}, stateProblems...), stackProblems...)
+		if len(findings) == 0 {
+			fmt.Fprintln(os.Stderr, renderSuccess("No problems found."))
+			fmt.Fprintln(os.Stderr)
+			break
+		}
+		showDoctorFindings(os.Stdout, findings)
+		fmt.Fprintln(os.Stderr)
+
+		fixable := 0
+		for _, finding := range findings {
+			if finding.Apply != nil {
+				fixable++
+			}
+		}
+		if !*doctorFix || fixable == 0 {
+			if fixable > 0 {
+				fmt.Fprintln(os.Stderr, "Run 'orca doctor -fix' to fix them.")
+			}
+			exit(1)
+		}
+
+		confirm := confirmFix
+		if *doctorYes {
+			confirm = nil
+		}
+		// the lock is cleared before it is taken for fixing the stack
+		fixedState, stateErr := applyDoctorFixes(stateProblems, confirm)
+		if err := lockStack("doctor"); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		fixedStack, stackErr := applyDoctorFixes(stackProblems, confirm)
+		fmt.Fprintln(os.Stderr)
+		if err := errors.Join(stateErr, stackErr); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		if fixed := fixedState + fixedStack; fixed < len(findings) {
+			fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Fixed %d of %d problems.", fixed, len(findings))))
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Fixed %d problems.", len(findings))))
+
+	case "upgrade":
+		rollback := upgradeCmd.Bool("rollback", false, "Restore the core image and the data from before the last upgrade")
+		upgradeConfigPath := upgradeCmd.String("config", findProjectConfig(), "Path to orca.json configuration file")
+		upgradeYes := upgradeCmd.Bool("y", false, "With -rollback, skip the confirmation prompt")
+		upgradeTimeout := upgradeCmd.Duration("startup-timeout", 0, "How long to wait for the core to migrate the store and become ready (default 15s)")
+		upgradePollInterval := upgradeCmd.Duration("poll-interval", 0, "How often to check readiness while waiting (default 500ms)")
+
+		upgradeCmd.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: orca upgrade [options]\n\n")
+			fmt.Fprintf(os.Stderr, "Recreate the core with the version this CLI supports (%s). The data of the stack is\n", orcaImageVersion)
+			fmt.Fprintf(os.Stderr, "snapshotted first, and the new core must migrate the store and pass its health check.\n")
+			fmt.Fprintf(os.Stderr, "When it does not, -rollback restores the previous core image and the snapshotted data.\n")
+			fmt.Fprintf(os.Stderr, "Snapshots taken before upgrades are kept until removed with `orca snapshot delete`.\n\n")
+			fmt.Fprintf(os.Stderr, "Options:\n")
+			upgradeCmd.PrintDefaults()
+		}
+
+		upgradeCmd.Parse(os.Args[2:])
+
+		if upgradeCmd.NArg() > 0 && (upgradeCmd.Arg(0) == "help" || upgradeCmd.Arg(0) == "-h") {
+			upgradeCmd.Usage()
+			exit(0)
+		}
+
+		if upgradeCmd.NArg() > 0 {
+			fmt.Fprintln(os.Stderr)
+			printError(fmt.Sprintf("Unknown argument: %s", upgradeCmd.Arg(0)))
+			fmt.Fprintln(os.Stderr, "Run 'orca upgrade help' for usage information.")
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+
+		config := loadProjectConfig(*upgradeConfigPath)
+		if err := setReadinessWait(config.Startup, *upgradeTimeout, *upgradePollInterval); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		checkDockerInstalled()
+
+		if *rollback {
+			record, err := readUpgradeRecord()
+			if err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			if !*upgradeYes {
+				fmt.Fprint(os.Stderr, warningStyle.Render(fmt.Sprintf(
+					"This will run %s and replace all current stack data with snapshot '%s' from before the upgrade. Continue? (y/N): ",
+					record.Previous, record.Snapshot,
+				)))
+				var response string
+				fmt.Scanln(&response)
+				if strings.ToLower(strings.TrimSpace(response)) != "y" {
+					fmt.Fprintln(os.Stderr, "Operation cancelled.")
+					exit(0)
+				}
+			}
+			if err := lockStack("upgrade"); err != nil {
+				printError(err.Error())
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr)
+			if err := rollbackUpgrade(config, *upgradeConfigPath, record); err != nil {
+				printError(fmt.Sprintf("Rollback failed: %v", err))
+				exit(1)
+			}
+			fmt.Fprintln(os.Stderr)
+			fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Rolled the core back to %s with the data from %s", record.Previous, record.At.Local().Format(time.DateTime))))
+			fmt.Fprintf(os.Stderr, "Recreating the core, e.g. with `docker rm -f %s && orca start`, upgrades it again.\n", orcaContainerName)
+			fmt.Fprintln(os.Stderr)
+			break
+		}
+
+		if err := lockStack("upgrade"); err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		previous, err := coreImage()
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		target := stackImages[componentCore]
+		fmt.Fprintln(os.Stderr)
+		if previous == target {
+			fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("The core already runs %s", target)))
+			fmt.Fprintln(os.Stderr)
+			break
+		}
+		if err := waitForStore(); err != nil {
+			printError(fmt.Sprintf("The store is not ready, start the stack with `orca start` first: %v", err))
+			exit(1)
+		}
+
+		record, err := upgradeCore(config, *upgradeConfigPath, previous, target)
+		if err != nil {
+			printError(err.Error())
+			if record != nil {
+				fmt.Fprintf(os.Stderr, "Restore %s and the data from before the upgrade with 'orca upgrade -rollback'.\n", previous)
+			}
+			fmt.Fprintln(os.Stderr)
+			exit(1)
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("Upgraded the core from %s to %s", previous, target)))
+		fmt.Fprintf(os.Stderr, "The data from before the upgrade is in snapshot '%s'. Roll back with 'orca upgrade -rollback'.\n", record.Snapshot)
+		fmt.Fprintln(os.Stderr)
+
+	case "completion":
+		if len(os.Args) < 3 || os.Args[2] == "help" || os.Args[2] == "-h" {
+			fmt.Fprintf(os.Stderr, "Usage: orca completion <bash|zsh|fish>\n\n")
+			fmt.Fprintf(os.Stderr, "Print a shell completion script. Commands, flag values, snapshots, projects and\n")
+			fmt.Fprintf(os.Stderr, "processor and algorithm names from the last `orca sync` are completed. For example:\n")
+			fmt.Fprintf(os.Stderr, "  source <(orca completion bash)\n")
+			exit(0)
+		}
+
+		script, err := completionScript(os.Args[2])
+		if err != nil {
+			printError(err.Error())
+			exit(1)
+		}
+		fmt.Print(script)
+
+	// hidden command used by the completion scripts
+	case "__complete":
+		for _, candidate := range completeArgs(os.Args[2:]) {
+			fmt.Println(candidate)
+		}
+
+	case "help":
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		fmt.Fprintln(os.Stderr)
+		exit(0)
+	case "-h":
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		fmt.Fprintln(os.Stderr)
+		exit(0)
+
+	default:
+		telemetryCommand = "unknown"
+		fmt.Fprintln(os.Stderr)
+		printError(fmt.Sprintf("Unknown subcommand: %s", os.Args[1]))
+		fmt.Fprintln(os.Stderr, "Run 'orca help' for usage information.")
+		fmt.Fprintln(os.Stderr)
+		exit(1)
+	}
+
+	exit(0)
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// tableStatsQuery reports per-table size along with live and dead tuple counts,
+// the dead tuple percentage being a cheap estimate of table bloat
+const tableStatsQuery = `SELECT
+    relname AS table,
+    pg_size_pretty(pg_total_relation_size(relid)) AS total_size,
+    n_live_tup AS live_rows,
+    n_dead_tup AS dead_rows,
+    CASE WHEN n_live_tup + n_dead_tup > 0
+        THEN round(100.0 * n_dead_tup / (n_live_tup + n_dead_tup), 1)
+        ELSE 0
+    END AS dead_pct,
+    coalesce(to_char(greatest(last_vacuum, last_autovacuum), 'YYYY-MM-DD HH24:MI'), 'never') AS last_vacuum
+FROM pg_stat_user_tables
+ORDER BY pg_total_relation_size(relid) DESC`
+
+// vacuumStore runs VACUUM ANALYZE (or VACUUM FULL ANALYZE) over the whole store
+func vacuumStore(full bool) error {
+	statement := "VACUUM (ANALYZE)"
+	if full {
+		statement = "VACUUM (FULL, ANALYZE)"
+	}
+	_, err := runPsql(nil, "-q", "-c", statement)
+	return err
+}
+
+// showTableStats prints per-table size and bloat for the store
+func showTableStats() error {
+	rows, err := queryStore(tableStatsQuery)
+	if err != nil {
+		return err
+	}
+	printTable(os.Stdout, rows)
+
+	size, err := countStoreRows("SELECT pg_size_pretty(pg_database_size('orca'))")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "\nTotal database size: %s\n", size)
+	return nil
+}
+
+// redisMemoryDoctor returns the output of Redis' MEMORY DOCTOR command
+func redisMemoryDoctor() (string, error) {
+	output, err := dockerCommand("exec", redisContainerName, "redis-cli", "MEMORY", "DOCTOR").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("MEMORY DOCTOR failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
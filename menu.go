@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// menuAction is an entry of the interactive menu shown by a bare `orca`
+type menuAction struct {
+	Label string
+	// Args are passed to orca to run the action
+	Args []string
+	// Run replaces Args for actions that are not an orca subcommand
+	Run func() error
+}
+
+var menuActions = []menuAction{
+	{Label: "Start the Orca stack", Args: []string{"start"}},
+	{Label: "Show stack status", Args: []string{"status"}},
+	{Label: "Sync the registry and generate stubs", Args: []string{"sync"}},
+	{Label: "Follow the Orca core logs", Run: followCoreLogs},
+	{Label: "Stop the Orca stack", Args: []string{"stop"}},
+	{Label: "Show all commands", Args: []string{"help"}},
+}
+
+var (
+	menuTitleStyle = lipgloss.NewStyle().Bold(true)
+	menuKeyStyle   = successStyle.Bold(true)
+)
+
+// followCoreLogs streams the core container's logs until interrupted
+func followCoreLogs() error {
+	cmd := dockerCommand("logs", "--tail", "100", "-f", orcaContainerName)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// runInteractiveMenu lets the user pick a common action, returning the exit code of the action
+func runInteractiveMenu() int {
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, menuTitleStyle.Render("Orca CLI")+" "+dimStyle.Render(Version))
+	fmt.Fprintln(os.Stderr)
+	for ii, action := range menuActions {
+		fmt.Fprintf(os.Stderr, "  %s  %s\n", menuKeyStyle.Render(strconv.Itoa(ii+1)), action.Label)
+	}
+	fmt.Fprintf(os.Stderr, "  %s  %s\n", menuKeyStyle.Render("q"), "Quit")
+	fmt.Fprintln(os.Stderr)
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprintf(os.Stderr, "Select an action [1-%d]: ", len(menuActions))
+		line, err := reader.ReadString('\n')
+		choice := strings.ToLower(strings.TrimSpace(line))
+		if err != nil || choice == "q" || choice == "quit" {
+			fmt.Fprintln(os.Stderr)
+			return 0
+		}
+
+		index, convErr := strconv.Atoi(choice)
+		if convErr != nil || index < 1 || index > len(menuActions) {
+			printError(fmt.Sprintf("Invalid choice %q", choice))
+			continue
+		}
+
+		action := menuActions[index-1]
+		fmt.Fprintln(os.Stderr)
+		if action.Run != nil {
+			err = action.Run()
+		} else {
+			args := action.Args
+			if stackProject != "" {
+				args = append([]string{"--project", stackProject}, args...)
+			}
+			err = runOrca(args...)
+		}
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		} else if err != nil {
+			printError(err.Error())
+			return 1
+		}
+		return 0
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+// Provisioning files for the observability bundle (`orca start -with observability`)
+
+// prometheusConfig scrapes the store and cache through their exporters. The core
+// is not scraped, as no core release serves metrics yet.
+func prometheusConfig() string {
+	return `global:
+  scrape_interval: 15s
+
+scrape_configs:
+  - job_name: prometheus
+    static_configs:
+      - targets: ["localhost:9090"]
+
+  - job_name: postgres
+    static_configs:
+      - targets: ["` + namespaced("orca-postgres-exporter-instance") + `:9187"]
+
+  - job_name: redis
+    static_configs:
+      - targets: ["` + namespaced("orca-redis-exporter-instance") + `:9121"]
+`
+}
+
+func grafanaDatasource() string {
+	return `apiVersion: 1
+
+datasources:
+  - name: Prometheus
+    uid: orca-prometheus
+    type: prometheus
+    access: proxy
+    url: http://` + namespaced("orca-prometheus-instance") + `:9090
+    isDefault: true
+`
+}
+
+const grafanaDashboardProvider = `apiVersion: 1
+
+providers:
+  - name: orca
+    folder: Orca
+    type: file
+    options:
+      path: /etc/grafana/provisioning/dashboards
+`
+
+const grafanaStackDashboard = `{
+  "uid": "orca-stack",
+  "title": "Orca Stack",
+  "schemaVersion": 39,
+  "refresh": "15s",
+  "time": {"from": "now-1h", "to": "now"},
+  "panels": [
+    {
+      "id": 1,
+      "type": "stat",
+      "title": "Targets up",
+      "gridPos": {"x": 0, "y": 0, "w": 24, "h": 4},
+      "datasource": {"type": "prometheus", "uid": "orca-prometheus"},
+      "targets": [{"expr": "up", "legendFormat": "{{job}}"}]
+    },
+    {
+      "id": 2,
+      "type": "timeseries",
+      "title": "Postgres connections",
+      "gridPos": {"x": 0, "y": 4, "w": 12, "h": 8},
+      "datasource": {"type": "prometheus", "uid": "orca-prometheus"},
+      "targets": [{"expr": "sum(pg_stat_database_numbackends{datname=\"orca\"})", "legendFormat": "connections"}]
+    },
+    {
+      "id": 3,
+      "type": "timeseries",
+      "title": "Postgres transactions / s",
+      "gridPos": {"x": 12, "y": 4, "w": 12, "h": 8},
+      "datasource": {"type": "prometheus", "uid": "orca-prometheus"},
+      "targets": [{"expr": "rate(pg_stat_database_xact_commit{datname=\"orca\"}[1m])", "legendFormat": "commits"}]
+    },
+    {
+      "id": 4,
+      "type": "timeseries",
+      "title": "Redis memory",
+      "gridPos": {"x": 0, "y": 12, "w": 12, "h": 8},
+      "datasource": {"type": "prometheus", "uid": "orca-prometheus"},
+      "fieldConfig": {"defaults": {"unit": "bytes"}},
+      "targets": [{"expr": "redis_memory_used_bytes", "legendFormat": "used"}]
+    },
+    {
+      "id": 5,
+      "type": "timeseries",
+      "title": "Redis commands / s",
+      "gridPos": {"x": 12, "y": 12, "w": 12, "h": 8},
+      "datasource": {"type": "prometheus", "uid": "orca-prometheus"},
+      "targets": [{"expr": "rate(redis_commands_processed_total[1m])", "legendFormat": "commands"}]
+    }
+  ]
+}
+`
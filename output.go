@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// templateOutputPrefix selects Go template output, as in `-o template='{{.Orca.Port}}'`
+const templateOutputPrefix = "template="
+
+var outputTemplateFuncs = template.FuncMap{
+	"json": func(value any) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	"join": strings.Join,
+}
+
+// validateOutputFormat checks an output format of a list or status command. Text
+// is the command's own human readable output.
+func validateOutputFormat(format string) error {
+	if format == "text" || format == "json" {
+		return nil
+	}
+	if strings.HasPrefix(format, templateOutputPrefix) {
+		_, err := template.New("output").Funcs(outputTemplateFuncs).Parse(strings.TrimPrefix(format, templateOutputPrefix))
+		if err != nil {
+			return fmt.Errorf("invalid output template: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid output format %q, must be one of: text, json, template=<go-template>", format)
+}
+
+// renderOutput writes value as indented JSON, or through a Go template
+func renderOutput(w io.Writer, value any, format string) error {
+	if format == "json" {
+		data, err := json.MarshalIndent(value, "", "    ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize output: %w", err)
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	tmpl, err := template.New("output").Funcs(outputTemplateFuncs).Parse(strings.TrimPrefix(format, templateOutputPrefix))
+	if err != nil {
+		return fmt.Errorf("invalid output template: %w", err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, value); err != nil {
+		return fmt.Errorf("failed to render output template: %w", err)
+	}
+	text := out.String()
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	fmt.Fprint(w, text)
+	return nil
+}
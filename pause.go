@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// isCoreFrozen reports whether the core container is frozen by `orca pause`
+func isCoreFrozen() bool {
+	state, err := getContainerState(orcaContainerName)
+	return err == nil && state.Status == "paused"
+}
+
+// countStoredResults returns how many results the store holds
+func countStoredResults() (int64, error) {
+	count, err := countStoreRows("SELECT count(*) FROM results")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(count, 10, 64)
+}
+
+// drainCore waits for the core to finish the work it has in flight. The core keeps
+// no record of dispatched windows, so it counts as drained once no result has been
+// stored for the quiet period. It gives up after timeout.
+func drainCore(quiet, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	last, err := countStoredResults()
+	if err != nil {
+		return fmt.Errorf("failed to read results from the store: %w", err)
+	}
+	settled := time.Now()
+
+	for time.Since(settled) < quiet {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("results were still being stored after %s, raise the limit with -drain-timeout or skip draining with -no-drain", timeout)
+		}
+		time.Sleep(pollInterval)
+		count, err := countStoredResults()
+		if err != nil {
+			return fmt.Errorf("failed to read results from the store: %w", err)
+		}
+		if count != last {
+			fmt.Fprintf(os.Stderr, "  %d result(s) stored, waiting for processing to settle...\n", count-last)
+			last = count
+			settled = time.Now()
+		}
+	}
+	return nil
+}
+
+// freezeCore freezes the whole core container with `docker pause` until thawCore.
+// This is not a pause of intake alone: the core answers no calls at all, so
+// emitters, processors and commands such as `orca status` time out against it.
+func freezeCore() error {
+	output, err := dockerCommand("pause", orcaContainerName).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to pause %s: %w: %s", orcaContainerName, err, output)
+	}
+	return nil
+}
+
+// thawCore unfreezes the core container frozen by freezeCore
+func thawCore() error {
+	output, err := dockerCommand("unpause", orcaContainerName).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to resume %s: %w: %s", orcaContainerName, err, output)
+	}
+	return nil
+}
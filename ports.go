@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// portMapping is a published port of a stack container, as listed by `orca port`
+type portMapping struct {
+	Component    string `json:"component"`
+	Container    string `json:"container"`
+	InternalPort int    `json:"internalPort"`
+	Protocol     string `json:"protocol"`
+	HostIP       string `json:"hostIp"`
+	HostPort     int    `json:"hostPort"`
+}
+
+// parsePortMappings parses the output of `docker port`, e.g. "5432/tcp -> 0.0.0.0:32768".
+// A port published on both IPv4 and IPv6 is listed once.
+func parsePortMappings(output string) []portMapping {
+	var mappings []portMapping
+	seen := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		internal, host, found := strings.Cut(line, "->")
+		if !found {
+			continue
+		}
+		port, protocol, _ := strings.Cut(strings.TrimSpace(internal), "/")
+		internalPort, err := strconv.Atoi(port)
+		if err != nil {
+			continue
+		}
+		hostIP, hostPortStr, err := net.SplitHostPort(strings.TrimSpace(host))
+		if err != nil {
+			continue
+		}
+		hostPort, err := strconv.Atoi(hostPortStr)
+		if err != nil {
+			continue
+		}
+
+		key := fmt.Sprintf("%d/%s:%d", internalPort, protocol, hostPort)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		mappings = append(mappings, portMapping{
+			InternalPort: internalPort,
+			Protocol:     protocol,
+			HostIP:       hostIP,
+			HostPort:     hostPort,
+		})
+	}
+	return mappings
+}
+
+// collectPortMappings lists the published ports of the running containers of the
+// given components
+func collectPortMappings(components []string) ([]portMapping, error) {
+	mappings := []portMapping{}
+	for _, component := range components {
+		containerName, err := componentContainer(component)
+		if err != nil {
+			return nil, err
+		}
+		if getContainerStatus(containerName) != "running" {
+			continue
+		}
+		output, err := dockerCommand("port", containerName).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the ports of %s: %w", containerName, err)
+		}
+		for _, mapping := range parsePortMappings(string(output)) {
+			mapping.Component = component
+			mapping.Container = containerName
+			mappings = append(mappings, mapping)
+		}
+	}
+	return mappings, nil
+}
+
+// showPortMappings prints the port mappings as a table
+func showPortMappings(w io.Writer, mappings []portMapping) {
+	rows := [][]string{{"COMPONENT", "CONTAINER", "INTERNAL", "HOST"}}
+	for _, mapping := range mappings {
+		rows = append(rows, []string{
+			mapping.Component,
+			mapping.Container,
+			fmt.Sprintf("%d/%s", mapping.InternalPort, mapping.Protocol),
+			net.JoinHostPort(mapping.HostIP, strconv.Itoa(mapping.HostPort)),
+		})
+	}
+	printTable(w, rows)
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePortMappings(t *testing.T) {
+	output := "5432/tcp -> 0.0.0.0:32768\n5432/tcp -> [::]:32768\n8125/udp -> 127.0.0.1:8125\n"
+	want := []portMapping{
+		{InternalPort: 5432, Protocol: "tcp", HostIP: "0.0.0.0", HostPort: 32768},
+		{InternalPort: 8125, Protocol: "udp", HostIP: "127.0.0.1", HostPort: 8125},
+	}
+	if got := parsePortMappings(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePortMappings = %+v, want %+v", got, want)
+	}
+}
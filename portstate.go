@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// portState maps "<container>:<internal port>" to the host port it was published on,
+// so that recreated containers are published on the same host ports
+type portState map[string]int
+
+func portStateKey(containerName string, internalPort int) string {
+	return containerName + ":" + strconv.Itoa(internalPort)
+}
+
+// portStatePath returns where the host ports of the stack project are recorded
+func portStatePath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ports", projectLabelValue(stackProject)+".json"), nil
+}
+
+// loadPortState returns the recorded host ports of the stack project, if any
+func loadPortState() portState {
+	state := portState{}
+	path, err := portStatePath()
+	if err != nil {
+		return state
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Ignoring unreadable port state %s: %v", path, err)))
+		return portState{}
+	}
+	return state
+}
+
+func savePortState(state portState) error {
+	path, err := portStatePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create port state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize port state: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// assignHostPort picks the host port to publish a new container's port on: the one
+// recorded for it on a previous start if it is free, otherwise the first free port
+// from preferredPort, or any free port when there is no preference. The chosen port
+// is always explicit, so that Docker keeps it when the container restarts.
+func assignHostPort(containerName string, internalPort, preferredPort int) (int, error) {
+	recorded := loadPortState()[portStateKey(containerName, internalPort)]
+	if recorded > 0 {
+		if isPortAvailable(recorded) {
+			return recorded, nil
+		}
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf(
+			"Port %d, which %s was published on before, is in use. Connection strings using it need updating.",
+			recorded, containerName,
+		)))
+	}
+
+	if preferredPort > 0 {
+		if port := findAvailablePort(preferredPort); port != -1 {
+			return port, nil
+		}
+		return 0, fmt.Errorf("no available port found for %s", containerName)
+	}
+
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, fmt.Errorf("no available port found for %s: %w", containerName, err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// recordPublishedPorts records the host ports the given containers are published on
+func recordPublishedPorts(containerNames []string) error {
+	state := loadPortState()
+	for _, containerName := range containerNames {
+		if getContainerStatus(containerName) != "running" {
+			continue
+		}
+		output, err := dockerCommand("port", containerName).Output()
+		if err != nil {
+			continue
+		}
+		for _, mapping := range parsePortMappings(string(output)) {
+			state[portStateKey(containerName, mapping.InternalPort)] = mapping.HostPort
+		}
+	}
+	return savePortState(state)
+}
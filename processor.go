@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/grpc"
+)
+
+// defaultProcessorRuntime is registered for processors the core does not know yet
+const defaultProcessorRuntime = "python3"
+
+// localCoreAddress returns the address of the running core of the stack project
+func localCoreAddress() (string, error) {
+	if status := getContainerStatus(orcaContainerName); status != "running" {
+		return "", fmt.Errorf("Orca is %s. Start Orca with `orca start`", status)
+	}
+	return "localhost:" + getContainerPort(orcaContainerName, orcaInternalPort), nil
+}
+
+// dialCore prepares a client of the core at address, without TLS as for the local stack
+func dialCore(address string) (*grpc.ClientConn, pb.OrcaCoreClient, error) {
+	conn, err := dialInsecure(address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("issue preparing to contact Orca: %w", err)
+	}
+	return conn, pb.NewOrcaCoreClient(conn), nil
+}
+
+// findRegisteredProcessor returns the registration of the named processor, if any
+func findRegisteredProcessor(state *pb.InternalState, name string) *pb.ProcessorRegistration {
+	for _, processor := range state.GetProcessors() {
+		if processor.GetName() == name {
+			return processor
+		}
+	}
+	return nil
+}
+
+// buildProcessorRegistration describes the project's processor from orca.json. The
+// algorithms are only known to the processor code, so they are carried over from its
+// current registration, if any.
+func buildProcessorRegistration(config *OrcaConfigFile, name, runtime string, current *pb.ProcessorRegistration) (*pb.ProcessorRegistration, error) {
+	if name == "" {
+		name = config.ProjectName
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no processor name, set projectName in orca.json with `orca init` or pass -name")
+	}
+	if config.ProcessorConnectionString == "" {
+		return nil, fmt.Errorf("no processorConnectionString in orca.json, create it with `orca init`")
+	}
+
+	registration := &pb.ProcessorRegistration{
+		Name:          name,
+		Runtime:       runtime,
+		ConnectionStr: config.ProcessorConnectionString,
+		ProjectName:   config.ProjectName,
+	}
+	if current != nil {
+		registration.SupportedAlgorithms = current.GetSupportedAlgorithms()
+		if registration.Runtime == "" {
+			registration.Runtime = current.GetRuntime()
+		}
+	}
+	if registration.Runtime == "" {
+		registration.Runtime = defaultProcessorRuntime
+	}
+	return registration, nil
+}
+
+// registerProcessor registers the processor described by orca.json with the core
+func registerProcessor(address string, config *OrcaConfigFile, name, runtime string, timeout time.Duration) error {
+	conn, client, err := dialCore(address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	state, err := client.Expose(ctx, &pb.ExposeSettings{})
+	if err != nil {
+		return fmt.Errorf("issue contacting Orca: %w", err)
+	}
+	if name == "" {
+		name = config.ProjectName
+	}
+	current := findRegisteredProcessor(state, name)
+	registration, err := buildProcessorRegistration(config, name, runtime, current)
+	if err != nil {
+		return err
+	}
+
+	if current == nil {
+		fmt.Fprintf(os.Stderr, "Registering processor %s at %s... ", registration.Name, registration.ConnectionStr)
+	} else {
+		fmt.Fprintf(os.Stderr, "Refreshing processor %s at %s (%d algorithms)... ", registration.Name, registration.ConnectionStr, len(registration.SupportedAlgorithms))
+	}
+	status, err := client.RegisterProcessor(ctx, registration)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, renderError("FAILED"))
+		return fmt.Errorf("the core rejected the registration: %w", err)
+	}
+	if !status.GetReceived() {
+		fmt.Fprintln(os.Stderr, renderError("FAILED"))
+		return fmt.Errorf("the core did not accept the registration: %s", status.GetMessage())
+	}
+	fmt.Fprintln(os.Stderr, renderSuccess("DONE"))
+	if status.GetMessage() != "" {
+		fmt.Fprintln(os.Stderr, status.GetMessage())
+	}
+	if current == nil {
+		fmt.Fprintln(os.Stderr, dimStyle.Render("The processor registers its algorithms itself once it runs."))
+	}
+	return nil
+}
+
+// hostAliases name this machine from inside containers. Processors registered under
+// them listen here, so they are probed on localhost.
+var hostAliases = []string{"host.docker.internal", "host.containers.internal", "host.lima.internal", "gateway.docker.internal"}
+
+// processorStatus is the reachability of a registered processor, as reported by
+// `orca processors status`
+type processorStatus struct {
+	Name      string `json:"name"`
+	Project   string `json:"project"`
+	Runtime   string `json:"runtime"`
+	Address   string `json:"address"`
+	Reachable bool   `json:"reachable"`
+	// Status is serving, transitioning or not serving when reachable, otherwise unreachable
+	Status      string `json:"status"`
+	LatencyMs   int64  `json:"latencyMs,omitempty"`
+	ActiveTasks int32  `json:"activeTasks,omitempty"`
+	Message     string `json:"message,omitempty"`
+}
+
+// processorProbeAddress converts a processor connection string into an address to
+// dial from this machine
+func processorProbeAddress(connStr string) string {
+	address := strings.TrimPrefix(strings.TrimPrefix(connStr, "grpc://"), "http://")
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	if slices.Contains(hostAliases, host) {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// probeProcessor calls the health check of a processor
+func probeProcessor(ctx context.Context, registration *pb.ProcessorRegistration) processorStatus {
+	status := processorStatus{
+		Name:    registration.GetName(),
+		Project: registration.GetProjectName(),
+		Runtime: registration.GetRuntime(),
+		Address: registration.GetConnectionStr(),
+		Status:  "unreachable",
+	}
+
+	conn, err := dialInsecure(processorProbeAddress(registration.GetConnectionStr()))
+	if err != nil {
+		status.Message = err.Error()
+		return status
+	}
+	defer conn.Close()
+
+	started := time.Now()
+	response, err := pb.NewOrcaProcessorClient(conn).HealthCheck(ctx, &pb.HealthCheckRequest{Timestamp: started.UnixMilli()})
+	if err != nil {
+		status.Message = err.Error()
+		return status
+	}
+
+	status.Reachable = true
+	status.LatencyMs = time.Since(started).Milliseconds()
+	status.Message = response.GetMessage()
+	status.ActiveTasks = response.GetMetrics().GetActiveTasks()
+	switch response.GetStatus() {
+	case pb.HealthCheckResponse_STATUS_SERVING:
+		status.Status = "serving"
+	case pb.HealthCheckResponse_STATUS_TRANSITIONING:
+		status.Status = "transitioning"
+	case pb.HealthCheckResponse_STATUS_NOT_SERVING:
+		status.Status = "not serving"
+	default:
+		status.Status = "unknown"
+	}
+	return status
+}
+
+// collectProcessorStatus probes every processor registered with the core at address
+// concurrently, each bounded by timeout
+func collectProcessorStatus(address string, timeout time.Duration) ([]processorStatus, error) {
+	conn, client, err := dialCore(address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	state, err := exposeRegistry(ctx, client, address, &pb.ExposeSettings{})
+	if err != nil {
+		return nil, fmt.Errorf("issue contacting Orca: %w", err)
+	}
+
+	statuses := make([]processorStatus, len(state.GetProcessors()))
+	var wg sync.WaitGroup
+	for ii, registration := range state.GetProcessors() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probeCtx, cancelProbe := context.WithTimeout(context.Background(), timeout)
+			defer cancelProbe()
+			statuses[ii] = probeProcessor(probeCtx, registration)
+		}()
+	}
+	wg.Wait()
+	return statuses, nil
+}
+
+// showProcessorStatus prints the processor statuses as a table
+func showProcessorStatus(w io.Writer, statuses []processorStatus) {
+	rows := [][]string{{"PROCESSOR", "PROJECT", "ADDRESS", "STATUS", "LATENCY", "DETAIL"}}
+	for _, status := range statuses {
+		latency := "-"
+		if status.Reachable {
+			latency = fmt.Sprintf("%dms", status.LatencyMs)
+		}
+		rows = append(rows, []string{
+			status.Name,
+			status.Project,
+			status.Address,
+			renderStdout(statusColor(processorStatusColor(status)), status.Status),
+			latency,
+			status.Message,
+		})
+	}
+	printTable(w, rows)
+}
+
+// processorStatusColor maps a processor status onto the container status colors
+func processorStatusColor(status processorStatus) string {
+	switch status.Status {
+	case "serving":
+		return "running"
+	case "transitioning":
+		return "stopped"
+	default:
+		return status.Status
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/grpc"
+)
+
+// fakeCore is an in-process Orca core keeping the processors registered with it
+type fakeCore struct {
+	pb.UnimplementedOrcaCoreServer
+	processors []*pb.ProcessorRegistration
+}
+
+func (c *fakeCore) RegisterProcessor(_ context.Context, registration *pb.ProcessorRegistration) (*pb.Status, error) {
+	for ii, processor := range c.processors {
+		if processor.GetName() == registration.GetName() {
+			c.processors[ii] = registration
+			return &pb.Status{Received: true}, nil
+		}
+	}
+	c.processors = append(c.processors, registration)
+	return &pb.Status{Received: true}, nil
+}
+
+func (c *fakeCore) Expose(context.Context, *pb.ExposeSettings) (*pb.InternalState, error) {
+	return &pb.InternalState{Processors: c.processors}, nil
+}
+
+// startFakeCore serves core on a local port for the duration of a test, returning its address
+func startFakeCore(t *testing.T, core pb.OrcaCoreServer) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterOrcaCoreServer(server, core)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+func TestRegisterProcessor(t *testing.T) {
+	core := &fakeCore{}
+	address := startFakeCore(t, core)
+	config := &OrcaConfigFile{ProjectName: "demo", ProcessorConnectionString: "host.docker.internal:5377"}
+
+	if err := registerProcessor(address, config, "", "", time.Second*5); err != nil {
+		t.Fatal(err)
+	}
+	if len(core.processors) != 1 || core.processors[0].GetName() != "demo" || core.processors[0].GetRuntime() != defaultProcessorRuntime {
+		t.Fatalf("registered %v, want processor demo with the default runtime", core.processors)
+	}
+
+	// a refresh keeps the algorithms and runtime the processor registered itself
+	core.processors[0].Runtime = "python3.12"
+	core.processors[0].SupportedAlgorithms = []*pb.Algorithm{{Name: "Mean", Version: "1.0.0"}}
+	config.ProcessorConnectionString = "host.docker.internal:5378"
+	if err := registerProcessor(address, config, "", "", time.Second*5); err != nil {
+		t.Fatal(err)
+	}
+	got := core.processors[0]
+	if got.GetConnectionStr() != "host.docker.internal:5378" || got.GetRuntime() != "python3.12" || len(got.GetSupportedAlgorithms()) != 1 {
+		t.Errorf("refreshed registration = %v", got)
+	}
+}
+
+type fakeProcessor struct {
+	pb.UnimplementedOrcaProcessorServer
+}
+
+func (fakeProcessor) HealthCheck(context.Context, *pb.HealthCheckRequest) (*pb.HealthCheckResponse, error) {
+	return &pb.HealthCheckResponse{Status: pb.HealthCheckResponse_STATUS_SERVING, Metrics: &pb.ProcessorMetrics{ActiveTasks: 2}}, nil
+}
+
+func (fakeProcessor) ExecuteDagPart(request *pb.ExecutionRequest, stream grpc.ServerStreamingServer[pb.ExecutionResult]) error {
+	// the fake algorithm returns the window's asset_id metadata
+	for _, execution := range request.GetAlgorithmExecutions() {
+		value := request.GetWindow().GetMetadata().GetFields()["asset_id"].GetNumberValue()
+		stream.Send(&pb.ExecutionResult{
+			ExecId: request.GetExecId(),
+			AlgorithmResult: &pb.AlgorithmResult{
+				Algorithm: execution.GetAlgorithm(),
+				Window:    request.GetWindow(),
+				Result: &pb.Result{
+					Status:     pb.ResultStatus_RESULT_STATUS_SUCEEDED,
+					ResultData: &pb.Result_SingleValue{SingleValue: float32(value)},
+				},
+			},
+		})
+	}
+	return nil
+}
+
+// startFakeProcessor serves a fake processor on a local port, returning the port
+func startFakeProcessor(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterOrcaProcessorServer(server, fakeProcessor{})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+func TestCollectProcessorStatus(t *testing.T) {
+	port := startFakeProcessor(t)
+
+	// a closed port, which the processor listener has just given up
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	core := &fakeCore{processors: []*pb.ProcessorRegistration{
+		{Name: "up", ConnectionStr: "host.docker.internal:" + port},
+		{Name: "down", ConnectionStr: closedAddress},
+	}}
+	statuses, err := collectProcessorStatus(startFakeCore(t, core), time.Second*5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("got %d statuses, want 2", len(statuses))
+	}
+	if up := statuses[0]; up.Status != "serving" || !up.Reachable || up.ActiveTasks != 2 {
+		t.Errorf("up = %+v, want serving with 2 active tasks", up)
+	}
+	if down := statuses[1]; down.Status != "unreachable" || down.Reachable || !strings.Contains(down.Message, "connect") {
+		t.Errorf("down = %+v, want unreachable with the dial error", down)
+	}
+}
+
+func TestCallAlgorithm(t *testing.T) {
+	windowType := &pb.WindowType{Name: "daily", Version: "1.0.0", MetadataFields: []*pb.MetadataField{{Name: "asset_id"}}}
+	state := &pb.InternalState{Processors: []*pb.ProcessorRegistration{{
+		Name:                "demo",
+		ConnectionStr:       "host.docker.internal:" + startFakeProcessor(t),
+		SupportedAlgorithms: []*pb.Algorithm{{Name: "AssetValue", Version: "1.0.0", WindowType: windowType}},
+	}}}
+
+	if _, err := findCallTarget(state, "Missing", "", ""); err == nil {
+		t.Error("found an algorithm that is not registered")
+	}
+	target, err := findCallTarget(state, "AssetValue", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := metadataFlag{}
+	metadata.Set("asset_id=7")
+	window, err := buildCallWindow(nil, windowType, time.Time{}, time.Time{}, "", metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if window.GetWindowTypeName() != "daily" || window.GetOrigin() != callOrigin || window.GetTimeTo().AsTime().Sub(window.GetTimeFrom().AsTime()) != time.Hour {
+		t.Errorf("window = %v, want the last hour of the daily window type", window)
+	}
+
+	var out strings.Builder
+	if err := callAlgorithm(context.Background(), target, window, &out); err != nil {
+		t.Fatal(err)
+	}
+	// protojson varies its whitespace between runs, so compare the parsed result
+	var result map[string]any
+	if err := json.Unmarshal([]byte(out.String()), &result); err != nil {
+		t.Fatalf("result %s is not JSON: %v", out.String(), err)
+	}
+	if result["singleValue"] != 7.0 {
+		t.Errorf("result = %s, want the asset id as a single value", out.String())
+	}
+}
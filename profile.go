@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// profileCommand is the hidden command the profiling engine runs docker through, so
+// that each docker call is timed whichever way its caller runs it
+const profileCommand = "__profile"
+
+// profileSlowest is how many of the slowest steps the profile lists
+const profileSlowest = 10
+
+// profileStep is an internal step of a command timed by --profile-cli
+type profileStep struct {
+	// Kind is docker, grpc or template
+	Kind     string
+	Name     string
+	Duration time.Duration
+}
+
+// profiler collects the steps of a command. Docker calls are timed by the
+// processes of profileCommand, which append them to the record file.
+type profiler struct {
+	mu         sync.Mutex
+	started    time.Time
+	steps      []profileStep
+	recordPath string
+}
+
+// cliProfiler is set by --profile-cli, and nil otherwise
+var cliProfiler *profiler
+
+// startProfiling times the docker calls, gRPC calls and template rendering of the
+// command, printing a summary to stderr when it exits
+func startProfiling() error {
+	record, err := os.CreateTemp("", "orca-profile-*.tsv")
+	if err != nil {
+		return fmt.Errorf("failed to create the profile record: %w", err)
+	}
+	record.Close()
+
+	cliProfiler = &profiler{started: time.Now(), recordPath: record.Name()}
+	engine = profilingEngine{inner: engine, recordPath: record.Name()}
+	onExit(func(code int) {
+		cliProfiler.report(os.Stderr)
+		os.Remove(cliProfiler.recordPath)
+	})
+	return nil
+}
+
+// recordProfileStep adds a step to the profile, when profiling
+func recordProfileStep(kind, name string, elapsed time.Duration) {
+	if cliProfiler == nil {
+		return
+	}
+	cliProfiler.mu.Lock()
+	defer cliProfiler.mu.Unlock()
+	cliProfiler.steps = append(cliProfiler.steps, profileStep{Kind: kind, Name: name, Duration: elapsed})
+}
+
+// profileUnaryInterceptor times each unary gRPC call
+func profileUnaryInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	started := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	recordProfileStep("grpc", method, time.Since(started))
+	return err
+}
+
+// profileStreamInterceptor times opening each gRPC stream, as streams such as log
+// follows last as long as the command
+func profileStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	started := time.Now()
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	recordProfileStep("grpc", method+" (open stream)", time.Since(started))
+	return stream, err
+}
+
+// allSteps returns the steps recorded in this process and by the docker calls
+func (p *profiler) allSteps() []profileStep {
+	p.mu.Lock()
+	steps := slices.Clone(p.steps)
+	p.mu.Unlock()
+
+	file, err := os.Open(p.recordPath)
+	if err != nil {
+		return steps
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		nanos, name, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+		elapsed, err := strconv.ParseInt(nanos, 10, 64)
+		if err != nil {
+			continue
+		}
+		steps = append(steps, profileStep{Kind: "docker", Name: name, Duration: time.Duration(elapsed)})
+	}
+	return steps
+}
+
+// report prints the time spent in each kind of step and the slowest steps
+func (p *profiler) report(w io.Writer) {
+	steps := p.allSteps()
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Profile: %s in total\n", formatProfileDuration(time.Since(p.started)))
+	if len(steps) == 0 {
+		fmt.Fprintln(w, "No docker calls, gRPC calls or template rendering")
+		return
+	}
+
+	rows := [][]string{{"KIND", "CALLS", "TOTAL"}}
+	for _, kind := range []string{"docker", "grpc", "template"} {
+		var calls int
+		var total time.Duration
+		for _, step := range steps {
+			if step.Kind == kind {
+				calls++
+				total += step.Duration
+			}
+		}
+		if calls > 0 {
+			rows = append(rows, []string{kind, strconv.Itoa(calls), formatProfileDuration(total)})
+		}
+	}
+	printTable(w, rows)
+
+	slices.SortStableFunc(steps, func(a, b profileStep) int {
+		return cmp.Compare(b.Duration, a.Duration)
+	})
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Slowest steps:")
+	rows = [][]string{{"TIME", "KIND", "STEP"}}
+	for _, step := range steps[:min(len(steps), profileSlowest)] {
+		// tabs in arguments, such as docker format strings, would split the table columns
+		name := strings.ReplaceAll(step.Name, "\t", `\t`)
+		rows = append(rows, []string{formatProfileDuration(step.Duration), step.Kind, name})
+	}
+	printTable(w, rows)
+}
+
+func formatProfileDuration(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// profilingEngine runs the commands of another engine through profileCommand
+type profilingEngine struct {
+	inner      dockerEngine
+	recordPath string
+}
+
+func (e profilingEngine) CommandContext(ctx context.Context, args ...string) *exec.Cmd {
+	inner := e.inner.CommandContext(context.Background(), args...)
+	executable, err := os.Executable()
+	if inner.Err != nil || err != nil {
+		return e.inner.CommandContext(ctx, args...)
+	}
+
+	name := shellJoin(append([]string{"docker"}, args...))
+	wrapped := append([]string{profileCommand, e.recordPath, name, inner.Path}, inner.Args[1:]...)
+	cmd := exec.CommandContext(ctx, executable, wrapped...)
+	cmd.Env = inner.Env
+	// terminating lets profileCommand stop docker too, where killing would orphan it
+	cmd.Cancel = func() error {
+		if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+	cmd.WaitDelay = 5 * time.Second
+	return cmd
+}
+
+// runProfileCommand runs a command, appending how long it took to the record file,
+// and exits as it did
+func runProfileCommand(args []string) int {
+	if len(args) < 3 {
+		fmt.Fprintf(os.Stderr, "usage: orca %s <record> <name> <command> [args...]\n", profileCommand)
+		return 2
+	}
+	recordPath, name := args[0], args[1]
+	cmd := exec.Command(args[2], args[3:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// an interrupt from the terminal reaches the command too, a termination is passed on
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	started := time.Now()
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 127
+	}
+	go func() {
+		for sig := range sigs {
+			if sig == syscall.SIGTERM {
+				cmd.Process.Signal(sig)
+			}
+		}
+	}()
+	cmd.Wait()
+	elapsed := time.Since(started)
+
+	if record, err := os.OpenFile(recordPath, os.O_WRONLY|os.O_APPEND, 0); err == nil {
+		fmt.Fprintf(record, "%d\t%s\n", elapsed.Nanoseconds(), strings.ReplaceAll(name, "\n", " "))
+		record.Close()
+	}
+	if code := cmd.ProcessState.ExitCode(); code >= 0 {
+		return code
+	}
+	return 1
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfilingEngine(t *testing.T) {
+	useFakeEngine(t)
+	hooks := exitHooks
+	if err := startProfiling(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Remove(cliProfiler.recordPath)
+		cliProfiler = nil
+		exitHooks = hooks
+	})
+
+	if output, err := dockerCommand("network", "create", "orca-test").CombinedOutput(); err != nil {
+		t.Fatalf("network create through the profiler failed: %v: %s", err, output)
+	}
+	if err := dockerCommand("inspect", "missing").Run(); err == nil {
+		t.Fatal("expected inspect of a missing container to keep failing through the profiler")
+	}
+	recordProfileStep("grpc", "/OrcaCore/Expose", 1500*time.Millisecond)
+
+	var out bytes.Buffer
+	cliProfiler.report(&out)
+	report := out.String()
+	// column padding follows the widest duration, so compare fields rather than lines
+	calls := map[string]string{}
+	var steps [][3]string
+	var table string
+	for _, line := range strings.Split(report, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0 || strings.HasPrefix(fields[0], "---"):
+		case fields[0] == "KIND" || fields[0] == "TIME":
+			table = fields[0]
+		case table == "KIND" && len(fields) == 3:
+			calls[fields[0]] = fields[1]
+		case table == "TIME" && len(fields) >= 3:
+			steps = append(steps, [3]string{fields[0], fields[1], strings.Join(fields[2:], " ")})
+		}
+	}
+	if calls["docker"] != "2" || calls["grpc"] != "1" {
+		t.Errorf("profile counts calls as %v, want 2 docker and 1 grpc:\n%s", calls, report)
+	}
+	for _, want := range []struct{ time, kind, step string }{
+		{"1.5s", "grpc", "/OrcaCore/Expose"},
+		{"", "docker", "docker network create orca-test"},
+		{"", "docker", "docker inspect missing"},
+	} {
+		if !slices.ContainsFunc(steps, func(step [3]string) bool {
+			return (want.time == "" || step[0] == want.time) && step[1] == want.kind && step[2] == want.step
+		}) {
+			t.Errorf("profile is missing the %s step %q:\n%s", want.kind, want.step, report)
+		}
+	}
+}
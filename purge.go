@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// purgePlan describes which aged data a purge removes. Results of aged windows are
+// always removed. Age is measured from when a window was received by the store,
+// not from the window's own time range.
+type purgePlan struct {
+	Age time.Duration
+	// Windows also removes the aged windows themselves
+	Windows bool
+}
+
+// cutoff is the time windows were received before to be purged. It is computed in
+// the store's clock, as windows.created holds the store's local time, and within a
+// transaction LOCALTIMESTAMP stays the time the transaction started.
+func (p purgePlan) cutoff() string {
+	return fmt.Sprintf("LOCALTIMESTAMP - make_interval(secs => %d)", int64(p.Age.Seconds()))
+}
+
+// statements returns the delete statements for the plan, results first so that
+// window deletes do not violate their foreign keys
+func (p purgePlan) statements() []string {
+	statements := []string{fmt.Sprintf(
+		"DELETE FROM results r USING windows w WHERE r.windows_id = w.id AND w.created < %s;",
+		p.cutoff(),
+	)}
+	if p.Windows {
+		statements = append(statements, fmt.Sprintf(
+			"DELETE FROM windows WHERE created < %s;",
+			p.cutoff(),
+		))
+	}
+	return statements
+}
+
+// countStoreRows runs a count query and returns the single value it produces
+func countStoreRows(query string) (string, error) {
+	rows, err := queryStore(query)
+	if err != nil {
+		return "", err
+	}
+	if len(rows) < 2 || len(rows[1]) == 0 {
+		return "", fmt.Errorf("unexpected result for count query")
+	}
+	return rows[1][0], nil
+}
+
+// purgeCounts reports how many rows the plan would delete, keyed by table
+func purgeCounts(p purgePlan) (map[string]string, error) {
+	counts := map[string]string{}
+
+	results, err := countStoreRows(fmt.Sprintf(
+		"SELECT count(*) FROM results r JOIN windows w ON w.id = r.windows_id WHERE w.created < %s",
+		p.cutoff(),
+	))
+	if err != nil {
+		return nil, err
+	}
+	counts["results"] = results
+
+	if p.Windows {
+		windows, err := countStoreRows(fmt.Sprintf(
+			"SELECT count(*) FROM windows WHERE created < %s",
+			p.cutoff(),
+		))
+		if err != nil {
+			return nil, err
+		}
+		counts["windows"] = windows
+	}
+	return counts, nil
+}
+
+// executePurge deletes the aged data in a single transaction
+func executePurge(p purgePlan) error {
+	script := strings.Join(p.statements(), "\n")
+	_, err := runPsql(strings.NewReader(script), "--single-transaction", "-q", "-f", "-")
+	return err
+}
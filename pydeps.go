@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// pythonSDKPackage is the distribution name of the python SDK the stubs import
+const pythonSDKPackage = "orca-python"
+
+// pythonDepsFormats are the dependency files `orca sync -deps` writes
+var pythonDepsFormats = []string{"pyproject", "requirements"}
+
+var (
+	// requirementsSDKLine matches the SDK requirement in a requirements.txt
+	requirementsSDKLine = regexp.MustCompile(`(?mi)^[ \t]*orca[-_.]python\b.*$`)
+	// pyprojectSDKDependency matches the SDK in a pyproject.toml dependency list
+	pyprojectSDKDependency = regexp.MustCompile(`(?i)(["'])orca[-_.]python\b[^"'\n]*(["'])`)
+	pyprojectNameInvalid   = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// pythonSDKRequirement returns the requirement of the SDK releases compatible with
+// a core version. The SDK is released alongside the core, so it follows the same
+// rule as coreVersionCompatible: the minor version must match before 1.0, and the
+// major version from then on.
+func pythonSDKRequirement(coreVersion string) (string, error) {
+	version, ok := parseVersion(coreVersion)
+	if !ok {
+		return "", fmt.Errorf("cannot pin %s to core version %q", pythonSDKPackage, coreVersion)
+	}
+	if len(version) < 2 {
+		version = append(version, 0)
+	}
+	if version[0] == 0 {
+		return fmt.Sprintf("%s>=0.%d,<0.%d", pythonSDKPackage, version[1], version[1]+1), nil
+	}
+	return fmt.Sprintf("%s>=%d.0,<%d", pythonSDKPackage, version[0], version[0]+1), nil
+}
+
+// writeRequirementsFile pins the SDK in a requirements.txt, replacing an existing
+// pin and keeping the rest of the file
+func writeRequirementsFile(path, requirement string) error {
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	text := string(content)
+	switch {
+	case requirementsSDKLine.MatchString(text):
+		text = requirementsSDKLine.ReplaceAllLiteralString(text, requirement)
+	case text == "" || strings.HasSuffix(text, "\n"):
+		text += requirement + "\n"
+	default:
+		text += "\n" + requirement + "\n"
+	}
+	return os.WriteFile(path, []byte(text), 0644)
+}
+
+// pyprojectContent returns a minimal pyproject.toml of a project depending on the SDK
+func pyprojectContent(projectName, requirement string) string {
+	name := strings.Trim(pyprojectNameInvalid.ReplaceAllString(strings.ToLower(projectName), "-"), "-")
+	if name == "" {
+		name = "orca-project"
+	}
+	return fmt.Sprintf("[project]\nname = %q\nversion = \"0.1.0\"\nrequires-python = \">=3.10\"\ndependencies = [\n    %q,\n]\n", name, requirement)
+}
+
+// writePyprojectFile pins the SDK in a pyproject.toml. A missing file is created
+// as a minimal project depending on the SDK. An existing file only has an SDK
+// dependency already listed updated, as other edits need a TOML editor, so false
+// is returned when there is none.
+func writePyprojectFile(path, projectName, requirement string) (bool, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return true, os.WriteFile(path, []byte(pyprojectContent(projectName, requirement)), 0644)
+	}
+	if err != nil {
+		return false, err
+	}
+	if !pyprojectSDKDependency.Match(content) {
+		return false, nil
+	}
+	updated := pyprojectSDKDependency.ReplaceAllFunc(content, func(match []byte) []byte {
+		quote := string(match[0])
+		return []byte(quote + requirement + quote)
+	})
+	return true, os.WriteFile(path, updated, 0644)
+}
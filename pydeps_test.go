@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPythonSDKRequirement(t *testing.T) {
+	for version, want := range map[string]string{
+		"0.14.2":  "orca-python>=0.14,<0.15",
+		"v0.9":    "orca-python>=0.9,<0.10",
+		"1.3.0":   "orca-python>=1.0,<2",
+		"2.0-rc1": "orca-python>=2.0,<3",
+	} {
+		if got, err := pythonSDKRequirement(version); err != nil || got != want {
+			t.Errorf("pythonSDKRequirement(%q) = %q, %v, want %q", version, got, err, want)
+		}
+	}
+	if _, err := pythonSDKRequirement("latest"); err == nil {
+		t.Errorf("pythonSDKRequirement(latest) succeeded")
+	}
+}
+
+func TestWritePythonDeps(t *testing.T) {
+	dir := t.TempDir()
+
+	requirements := filepath.Join(dir, "requirements.txt")
+	if err := os.WriteFile(requirements, []byte("numpy==2.1.0\norca_python==0.12.0\npandas"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeRequirementsFile(requirements, "orca-python>=0.14,<0.15"); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(requirements); string(content) != "numpy==2.1.0\norca-python>=0.14,<0.15\npandas" {
+		t.Errorf("requirements.txt = %q", content)
+	}
+
+	pyproject := filepath.Join(dir, "pyproject.toml")
+	if pinned, err := writePyprojectFile(pyproject, "My Project", "orca-python>=0.14,<0.15"); err != nil || !pinned {
+		t.Fatalf("writePyprojectFile on a new file = %v, %v", pinned, err)
+	}
+	content, _ := os.ReadFile(pyproject)
+	if !strings.Contains(string(content), `name = "my-project"`) || !strings.Contains(string(content), `"orca-python>=0.14,<0.15",`) {
+		t.Errorf("pyproject.toml = %s", content)
+	}
+	if pinned, err := writePyprojectFile(pyproject, "My Project", "orca-python>=1.0,<2"); err != nil || !pinned {
+		t.Fatalf("writePyprojectFile on an existing file = %v, %v", pinned, err)
+	}
+	if content, _ := os.ReadFile(pyproject); !strings.Contains(string(content), `"orca-python>=1.0,<2",`) || strings.Contains(string(content), "0.14") {
+		t.Errorf("pyproject.toml after repinning = %s", content)
+	}
+
+	if err := os.WriteFile(pyproject, []byte("[project]\nname = \"other\"\ndependencies = []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if pinned, err := writePyprojectFile(pyproject, "other", "orca-python>=1.0,<2"); err != nil || pinned {
+		t.Errorf("writePyprojectFile without the SDK listed = %v, %v, want it left alone", pinned, err)
+	}
+}
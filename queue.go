@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisQueueScript reports every list, stream and sorted set in Redis as
+// "key, type, length, first stream entry id" lines, in a single round trip
+const redisQueueScript = `
+local out = {}
+local cursor = "0"
+repeat
+  local page = redis.call("SCAN", cursor, "COUNT", 1000)
+  cursor = page[1]
+  for _, key in ipairs(page[2]) do
+    local kind = redis.call("TYPE", key).ok
+    if kind == "list" then
+      table.insert(out, key .. "\t" .. kind .. "\t" .. redis.call("LLEN", key) .. "\t")
+    elseif kind == "zset" then
+      table.insert(out, key .. "\t" .. kind .. "\t" .. redis.call("ZCARD", key) .. "\t")
+    elseif kind == "stream" then
+      local length = redis.call("XLEN", key)
+      local first = ""
+      if length > 0 then
+        first = redis.call("XRANGE", key, "-", "+", "COUNT", 1)[1][1]
+      end
+      table.insert(out, key .. "\t" .. kind .. "\t" .. length .. "\t" .. first)
+    end
+  end
+until cursor == "0"
+return out`
+
+// redisQueue is a Redis key holding queued items
+type redisQueue struct {
+	Key    string `json:"key"`
+	Type   string `json:"type"`
+	Length int64  `json:"length"`
+	// OldestAgeSeconds is known for streams, whose entry ids carry the time they were added
+	OldestAgeSeconds *float64 `json:"oldestAgeSeconds,omitempty"`
+}
+
+// windowTypeThroughput is how windows of a type moved through the core over the period
+type windowTypeThroughput struct {
+	WindowType        string  `json:"windowType"`
+	WindowTypeVersion string  `json:"windowTypeVersion"`
+	Received          int64   `json:"received"`
+	Results           int64   `json:"results"`
+	ReceivedPerMinute float64 `json:"receivedPerMinute"`
+	ResultsPerMinute  float64 `json:"resultsPerMinute"`
+	// Unfinished windows are missing a result from at least one of their algorithms
+	Unfinished              int64    `json:"unfinished"`
+	OldestUnfinishedSeconds *float64 `json:"oldestUnfinishedSeconds,omitempty"`
+}
+
+// queueStats is the report of `orca queue stats`
+type queueStats struct {
+	Period      string                 `json:"period"`
+	Queues      []redisQueue           `json:"queues"`
+	WindowTypes []windowTypeThroughput `json:"windowTypes"`
+}
+
+// parseRedisQueues parses the output of redisQueueScript, ages measured from now
+func parseRedisQueues(output string, now time.Time) []redisQueue {
+	queues := []redisQueue{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			continue
+		}
+		length, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		queue := redisQueue{Key: fields[0], Type: fields[1], Length: length}
+		if millis, _, found := strings.Cut(fields[3], "-"); found {
+			if added, err := strconv.ParseInt(millis, 10, 64); err == nil {
+				age := now.Sub(time.UnixMilli(added)).Seconds()
+				queue.OldestAgeSeconds = &age
+			}
+		}
+		queues = append(queues, queue)
+	}
+	return queues
+}
+
+// collectRedisQueues lists the queues held in Redis
+func collectRedisQueues() ([]redisQueue, error) {
+	output, err := dockerCommand("exec", redisContainerName, "redis-cli", "EVAL", redisQueueScript, "0").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queues from Redis: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return parseRedisQueues(string(output), time.Now()), nil
+}
+
+// collectWindowTypeThroughput reads from the store how many windows of each type
+// were received over the period and how many results their algorithms stored.
+// Times are compared in the store's own clock.
+func collectWindowTypeThroughput(period time.Duration) ([]windowTypeThroughput, error) {
+	output, err := queryStoreJSON(fmt.Sprintf(`WITH recent AS (
+    SELECT w.id, w.window_type_id, w.created FROM windows w
+    WHERE w.created >= LOCALTIMESTAMP - make_interval(secs => %d)
+),
+expected AS (
+    SELECT a.window_type_id, count(*) AS algorithms FROM algorithm a GROUP BY a.window_type_id
+),
+stored AS (
+    SELECT r.windows_id, count(DISTINCT r.algorithm_id) AS results
+    FROM results r JOIN recent ON recent.id = r.windows_id
+    GROUP BY r.windows_id
+)
+SELECT
+    wt.name AS window_type,
+    wt.version AS window_type_version,
+    count(recent.id) AS received,
+    coalesce(sum(stored.results), 0) AS results,
+    count(recent.id) FILTER (WHERE coalesce(stored.results, 0) < coalesce(expected.algorithms, 0)) AS unfinished,
+    extract(epoch FROM LOCALTIMESTAMP - min(recent.created) FILTER (
+        WHERE coalesce(stored.results, 0) < coalesce(expected.algorithms, 0)
+    )) AS oldest_unfinished
+FROM recent
+JOIN window_type wt ON wt.id = recent.window_type_id
+LEFT JOIN expected ON expected.window_type_id = recent.window_type_id
+LEFT JOIN stored ON stored.windows_id = recent.id
+GROUP BY wt.name, wt.version
+ORDER BY wt.name, wt.version`, int64(period.Seconds())))
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		WindowType        string   `json:"window_type"`
+		WindowTypeVersion string   `json:"window_type_version"`
+		Received          int64    `json:"received"`
+		Results           int64    `json:"results"`
+		Unfinished        int64    `json:"unfinished"`
+		OldestUnfinished  *float64 `json:"oldest_unfinished"`
+	}
+	if err := json.Unmarshal(output, &rows); err != nil {
+		return nil, fmt.Errorf("failed to read window throughput: %w", err)
+	}
+
+	minutes := period.Minutes()
+	throughput := make([]windowTypeThroughput, len(rows))
+	for ii, row := range rows {
+		throughput[ii] = windowTypeThroughput{
+			WindowType:              row.WindowType,
+			WindowTypeVersion:       row.WindowTypeVersion,
+			Received:                row.Received,
+			Results:                 row.Results,
+			ReceivedPerMinute:       float64(row.Received) / minutes,
+			ResultsPerMinute:        float64(row.Results) / minutes,
+			Unfinished:              row.Unfinished,
+			OldestUnfinishedSeconds: row.OldestUnfinished,
+		}
+	}
+	return throughput, nil
+}
+
+// formatAge renders an age in seconds for the stats tables
+func formatAge(seconds *float64) string {
+	if seconds == nil {
+		return "-"
+	}
+	return (time.Duration(*seconds) * time.Second).Round(time.Second).String()
+}
+
+// showQueueStats prints the queue statistics as tables
+func showQueueStats(w io.Writer, stats queueStats) {
+	fmt.Fprintln(w, "Redis queues")
+	if len(stats.Queues) == 0 {
+		fmt.Fprintln(w, "  none, the core dispatches windows as it receives them")
+	} else {
+		rows := [][]string{{"KEY", "TYPE", "DEPTH", "OLDEST"}}
+		for _, queue := range stats.Queues {
+			rows = append(rows, []string{queue.Key, queue.Type, strconv.FormatInt(queue.Length, 10), formatAge(queue.OldestAgeSeconds)})
+		}
+		printTable(w, rows)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Windows received in the last %s\n", stats.Period)
+	if len(stats.WindowTypes) == 0 {
+		fmt.Fprintln(w, "  none")
+		return
+	}
+	rows := [][]string{{"WINDOW TYPE", "RECEIVED", "PER MIN", "RESULTS", "PER MIN", "UNFINISHED", "OLDEST UNFINISHED"}}
+	for _, wt := range stats.WindowTypes {
+		unfinished := strconv.FormatInt(wt.Unfinished, 10)
+		if wt.Unfinished > 0 {
+			unfinished = renderStdout(warningStyle, unfinished)
+		}
+		rows = append(rows, []string{
+			wt.WindowType + " " + wt.WindowTypeVersion,
+			strconv.FormatInt(wt.Received, 10),
+			fmt.Sprintf("%.2f", wt.ReceivedPerMinute),
+			strconv.FormatInt(wt.Results, 10),
+			fmt.Sprintf("%.2f", wt.ResultsPerMinute),
+			unfinished,
+			formatAge(wt.OldestUnfinishedSeconds),
+		})
+	}
+	printTable(w, rows)
+}
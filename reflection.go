@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// reflectCoreAPI asks the core which services it offers through gRPC server
+// reflection, so that methods added to cores after this CLI was built can be called
+func reflectCoreAPI(ctx context.Context, conn grpc.ClientConnInterface) (coreAPI, error) {
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return coreAPI{}, err
+	}
+	defer stream.CloseSend()
+	ask := func(request *reflectionpb.ServerReflectionRequest) (*reflectionpb.ServerReflectionResponse, error) {
+		if err := stream.Send(request); err != nil {
+			return nil, err
+		}
+		response, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if failure := response.GetErrorResponse(); failure != nil {
+			return nil, fmt.Errorf("reflection: %s", failure.GetErrorMessage())
+		}
+		return response, nil
+	}
+
+	listed, err := ask(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+	})
+	if err != nil {
+		return coreAPI{}, err
+	}
+	var names []string
+	received := map[string]*descriptorpb.FileDescriptorProto{}
+	for _, service := range listed.GetListServicesResponse().GetService() {
+		if strings.HasPrefix(service.GetName(), "grpc.reflection.") {
+			continue
+		}
+		names = append(names, service.GetName())
+		// the server sends each file once per stream, with the files it imports
+		response, err := ask(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service.GetName()},
+		})
+		if err != nil {
+			return coreAPI{}, err
+		}
+		for _, data := range response.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			file := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(data, file); err != nil {
+				return coreAPI{}, fmt.Errorf("reflection: invalid file descriptor: %w", err)
+			}
+			received[file.GetName()] = file
+		}
+	}
+
+	files, err := buildReflectedFiles(received)
+	if err != nil {
+		return coreAPI{}, err
+	}
+	api := coreAPI{reflected: true}
+	for _, name := range names {
+		descriptor, err := files.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			return coreAPI{}, fmt.Errorf("reflection: service %s: %w", name, err)
+		}
+		if service, ok := descriptor.(protoreflect.ServiceDescriptor); ok {
+			api.services = append(api.services, service)
+		}
+	}
+	return api, nil
+}
+
+// buildReflectedFiles links the file descriptors sent by a server. Imports it did not
+// send are taken from those compiled into the CLI, such as the well-known types, or
+// left unresolved when only options such as validation rules come from them.
+func buildReflectedFiles(received map[string]*descriptorpb.FileDescriptorProto) (*protoregistry.Files, error) {
+	files := &protoregistry.Files{}
+	options := protodesc.FileOptions{AllowUnresolvable: true}
+	resolver := reflectedResolver{files}
+	var add func(path string, importing []string) error
+	add = func(path string, importing []string) error {
+		file, ok := received[path]
+		if !ok {
+			return nil
+		}
+		if _, err := files.FindFileByPath(path); err == nil {
+			return nil
+		}
+		for _, dependency := range file.GetDependency() {
+			if slices.Contains(importing, dependency) || dependency == path {
+				return fmt.Errorf("reflection: %s is imported in a cycle", dependency)
+			}
+			if err := add(dependency, append(importing, path)); err != nil {
+				return err
+			}
+		}
+		descriptor, err := options.New(file, resolver)
+		if err != nil {
+			return fmt.Errorf("reflection: %s: %w", path, err)
+		}
+		return files.RegisterFile(descriptor)
+	}
+	for path := range received {
+		if err := add(path, nil); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// reflectedResolver finds descriptors among the reflected files, then among those
+// compiled into the CLI
+type reflectedResolver struct {
+	files *protoregistry.Files
+}
+
+func (r reflectedResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if file, err := r.files.FindFileByPath(path); err == nil {
+		return file, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r reflectedResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if descriptor, err := r.files.FindDescriptorByName(name); err == nil {
+		return descriptor, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// registryCachePath returns where the last synced registry of the stack project is kept
+func registryCachePath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "registry", projectLabelValue(stackProject)+".json"), nil
+}
+
+// saveRegistryCache keeps the registry returned by sync, so that other commands,
+// such as shell completion, can use it without contacting the core
+func saveRegistryCache(state *pb.InternalState) error {
+	path, err := registryCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create registry cache directory: %w", err)
+	}
+
+	data, err := protojson.MarshalOptions{Multiline: true}.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to serialize registry: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// loadRegistryCache returns the registry from the last sync of the stack project
+func loadRegistryCache() (*pb.InternalState, error) {
+	path, err := registryCachePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &pb.InternalState{}
+	if err := protojson.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// registryProcessorNames returns the sorted processor names in a registry
+func registryProcessorNames(state *pb.InternalState) []string {
+	var names []string
+	for _, processor := range state.GetProcessors() {
+		names = append(names, processor.GetName())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// registryAlgorithmNames returns the sorted, de-duplicated algorithm names in a registry
+func registryAlgorithmNames(state *pb.InternalState) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, processor := range state.GetProcessors() {
+		for _, algorithm := range processor.GetSupportedAlgorithms() {
+			if !seen[algorithm.GetName()] {
+				seen[algorithm.GetName()] = true
+				names = append(names, algorithm.GetName())
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	registryJSONFile     = "registry.json"
+	registrySnapshotFile = "registry.pb"
+
+	// registry.pb starts with registrySnapshotMagic and a format version byte,
+	// followed by the full name of the message as a uvarint length and the name,
+	// then the message in the protobuf wire format up to the end of the file
+	registrySnapshotMagic   = "ORCAPB"
+	registrySnapshotVersion = 1
+)
+
+// registryFormats are the formats `orca sync -format` writes the registry in.
+// JSON is always written, pb adds the protobuf snapshot.
+var registryFormats = []string{"json", "pb"}
+
+// canonicalRegistry returns a copy of the registry with processors, algorithms,
+// dependencies and metadata fields sorted, as the core returns them in no stable order
+func canonicalRegistry(state *pb.InternalState) *pb.InternalState {
+	canonical := proto.Clone(state).(*pb.InternalState)
+	slices.SortFunc(canonical.Processors, func(a, b *pb.ProcessorRegistration) int {
+		return cmp.Or(cmp.Compare(a.GetName(), b.GetName()), cmp.Compare(a.GetProjectName(), b.GetProjectName()))
+	})
+	for _, processor := range canonical.Processors {
+		slices.SortFunc(processor.SupportedAlgorithms, func(a, b *pb.Algorithm) int {
+			return cmp.Or(cmp.Compare(a.GetName(), b.GetName()), cmp.Compare(a.GetVersion(), b.GetVersion()))
+		})
+		for _, algorithm := range processor.SupportedAlgorithms {
+			slices.SortFunc(algorithm.Dependencies, func(a, b *pb.AlgorithmDependency) int {
+				return cmp.Or(
+					cmp.Compare(a.GetProcessorName(), b.GetProcessorName()),
+					cmp.Compare(a.GetName(), b.GetName()),
+					cmp.Compare(a.GetVersion(), b.GetVersion()),
+				)
+			})
+			if windowType := algorithm.GetWindowType(); windowType != nil {
+				slices.SortFunc(windowType.MetadataFields, func(a, b *pb.MetadataField) int {
+					return cmp.Compare(a.GetName(), b.GetName())
+				})
+			}
+		}
+	}
+	return canonical
+}
+
+// marshalCanonicalJSON serializes a message as JSON with sorted keys and fixed
+// indentation. protojson output is deliberately unstable, so it is re-encoded.
+func marshalCanonicalJSON(message proto.Message) ([]byte, error) {
+	var out bytes.Buffer
+	if err := encodeCanonicalJSON(&out, message); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// encodeCanonicalJSON writes a message as marshalCanonicalJSON serializes it. The
+// indented JSON is written to w value by value as it is produced, rather than
+// built in memory first, so that only the decoded message is held at once.
+func encodeCanonicalJSON(w io.Writer, message proto.Message) error {
+	data, err := protojson.Marshal(message)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	// the protojson output is no longer needed while the indented JSON is written
+	data = nil
+
+	out := bufio.NewWriter(w)
+	if err := writeIndentedJSON(out, value, "\n"); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	return out.Flush()
+}
+
+// writeIndentedJSON writes a decoded JSON value with its object keys sorted and
+// two space indentation, as json.Encoder would with SetIndent("", "  ") and HTML
+// escaping off. newline is the line break and indentation the value starts at.
+// Write errors are left to the caller's Flush, where bufio reports them.
+func writeIndentedJSON(w *bufio.Writer, value any, newline string) error {
+	inner := newline + "  "
+	switch value := value.(type) {
+	case map[string]any:
+		if len(value) == 0 {
+			w.WriteString("{}")
+			return nil
+		}
+		keys := slices.Sorted(maps.Keys(value))
+		w.WriteByte('{')
+		for ii, key := range keys {
+			if ii > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(inner)
+			if err := writeJSONScalar(w, key); err != nil {
+				return err
+			}
+			w.WriteString(": ")
+			if err := writeIndentedJSON(w, value[key], inner); err != nil {
+				return err
+			}
+		}
+		w.WriteString(newline)
+		w.WriteByte('}')
+	case []any:
+		if len(value) == 0 {
+			w.WriteString("[]")
+			return nil
+		}
+		w.WriteByte('[')
+		for ii, element := range value {
+			if ii > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(inner)
+			if err := writeIndentedJSON(w, element, inner); err != nil {
+				return err
+			}
+		}
+		w.WriteString(newline)
+		w.WriteByte(']')
+	default:
+		return writeJSONScalar(w, value)
+	}
+	return nil
+}
+
+// writeJSONScalar writes a string, number, boolean or null without HTML escaping
+func writeJSONScalar(w *bufio.Writer, value any) error {
+	var scalar bytes.Buffer
+	encoder := json.NewEncoder(&scalar)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	w.Write(bytes.TrimSuffix(scalar.Bytes(), []byte("\n")))
+	return nil
+}
+
+// marshalRegistrySnapshot serializes the registry as protobuf, behind a header
+// naming the message so that readers can check what they are decoding
+func marshalRegistrySnapshot(state *pb.InternalState) ([]byte, error) {
+	payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize registry: %w", err)
+	}
+	name := string(state.ProtoReflect().Descriptor().FullName())
+
+	data := []byte(registrySnapshotMagic)
+	data = append(data, registrySnapshotVersion)
+	data = binary.AppendUvarint(data, uint64(len(name)))
+	data = append(data, name...)
+	return append(data, payload...), nil
+}
+
+// unmarshalRegistrySnapshot reads a registry serialized by marshalRegistrySnapshot
+func unmarshalRegistrySnapshot(data []byte) (*pb.InternalState, error) {
+	if !bytes.HasPrefix(data, []byte(registrySnapshotMagic)) || len(data) <= len(registrySnapshotMagic) {
+		return nil, fmt.Errorf("not a registry snapshot")
+	}
+	data = data[len(registrySnapshotMagic):]
+	if version := data[0]; version != registrySnapshotVersion {
+		return nil, fmt.Errorf("unsupported registry snapshot version %d", version)
+	}
+	length, read := binary.Uvarint(data[1:])
+	if read <= 0 || uint64(len(data)-1-read) < length {
+		return nil, fmt.Errorf("truncated registry snapshot header")
+	}
+	data = data[1+read:]
+
+	state := &pb.InternalState{}
+	if name := string(data[:length]); name != string(state.ProtoReflect().Descriptor().FullName()) {
+		return nil, fmt.Errorf("registry snapshot holds %s, expected %s", name, state.ProtoReflect().Descriptor().FullName())
+	}
+	if err := proto.Unmarshal(data[length:], state); err != nil {
+		return nil, fmt.Errorf("failed to parse registry snapshot: %w", err)
+	}
+	return state, nil
+}
+
+// writeRegistryFiles writes the registry into dir in the format, gzipped with a
+// .gz suffix when compress is set, returning the paths written. The files are
+// canonical, so that syncing an unchanged registry leaves them byte for byte the same.
+func writeRegistryFiles(dir string, state *pb.InternalState, format string, compress bool) ([]string, error) {
+	state = canonicalRegistry(state)
+	jsonPath, err := writeRegistryFile(filepath.Join(dir, registryJSONFile), compress, func(w io.Writer) error {
+		if err := encodeCanonicalJSON(w, state); err != nil {
+			return fmt.Errorf("failed to serialize registry: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{jsonPath}
+	if format != "pb" {
+		return paths, nil
+	}
+
+	data, err := marshalRegistrySnapshot(state)
+	if err != nil {
+		return nil, err
+	}
+	snapshotPath, err := writeRegistryFile(filepath.Join(dir, registrySnapshotFile), compress, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(paths, snapshotPath), nil
+}
+
+// writeRegistryFile streams a registry file from write, gzipped to path.gz when
+// compress is set, returning the path written. It is written to a temporary file
+// beside path and renamed over it once complete, so an interrupted sync leaves the
+// previous file intact rather than a truncated one. The gzip header carries no
+// timestamp, so that unchanged registries compress to the same bytes.
+func writeRegistryFile(path string, compress bool, write func(w io.Writer) error) (string, error) {
+	if compress {
+		path += ".gz"
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	buffered := bufio.NewWriter(temp)
+	var out io.Writer = buffered
+	var compressor *gzip.Writer
+	if compress {
+		compressor = gzip.NewWriter(buffered)
+		out = compressor
+	}
+	if err := write(out); err != nil {
+		return "", err
+	}
+	if compressor != nil {
+		if err := compressor.Close(); err != nil {
+			return "", fmt.Errorf("failed to compress %s: %w", filepath.Base(path), err)
+		}
+	}
+	if err := buffered.Flush(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := temp.Chmod(0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := temp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// readRegistryFile reads a registry written by sync, as JSON or a protobuf
+// snapshot by its extension, gzipped when it ends in .gz
+func readRegistryFile(path string) (*pb.InternalState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry: %w", err)
+	}
+	name := path
+	if strings.HasSuffix(name, ".gz") {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		if data, err = io.ReadAll(reader); err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		name = strings.TrimSuffix(name, ".gz")
+	}
+
+	if filepath.Ext(name) == ".pb" {
+		return unmarshalRegistrySnapshot(data)
+	}
+	state := &pb.InternalState{}
+	if err := protojson.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return state, nil
+}
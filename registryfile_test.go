@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRegistrySnapshotRoundTrip(t *testing.T) {
+	state := &pb.InternalState{Processors: []*pb.ProcessorRegistration{{
+		Name:          "stats",
+		Runtime:       "python3.12",
+		ConnectionStr: "host.docker.internal:5377",
+		SupportedAlgorithms: []*pb.Algorithm{{
+			Name:       "Mean",
+			Version:    "1.0.0",
+			WindowType: &pb.WindowType{Name: "Hourly", Version: "1.0.0"},
+			ResultType: pb.ResultType_ARRAY,
+		}},
+	}}}
+
+	data, err := marshalRegistrySnapshot(state)
+	if err != nil {
+		t.Fatalf("marshalRegistrySnapshot: %v", err)
+	}
+	decoded, err := unmarshalRegistrySnapshot(data)
+	if err != nil {
+		t.Fatalf("unmarshalRegistrySnapshot: %v", err)
+	}
+	if !proto.Equal(state, decoded) {
+		t.Errorf("decoded registry = %v, want %v", decoded, state)
+	}
+
+	if _, err := unmarshalRegistrySnapshot(data[:len(registrySnapshotMagic)+3]); err == nil {
+		t.Errorf("unmarshalRegistrySnapshot accepted a truncated header")
+	}
+	if _, err := unmarshalRegistrySnapshot([]byte(`{"processors": []}`)); err == nil {
+		t.Errorf("unmarshalRegistrySnapshot accepted JSON")
+	}
+}
+
+func TestCanonicalRegistryJSON(t *testing.T) {
+	hourly := func(fields ...string) *pb.WindowType {
+		windowType := &pb.WindowType{Name: "Hourly", Version: "1.0.0"}
+		for _, field := range fields {
+			windowType.MetadataFields = append(windowType.MetadataFields, &pb.MetadataField{Name: field})
+		}
+		return windowType
+	}
+	first := &pb.InternalState{Processors: []*pb.ProcessorRegistration{
+		{Name: "stats", SupportedAlgorithms: []*pb.Algorithm{
+			{Name: "Mean", Version: "1.0.0", WindowType: hourly("site", "asset")},
+			{Name: "Max", Version: "1.0.0", WindowType: hourly("asset", "site")},
+		}},
+		{Name: "alerts", SupportedAlgorithms: []*pb.Algorithm{{Name: "Spike", Version: "2.0.0", WindowType: hourly()}}},
+	}}
+	second := &pb.InternalState{Processors: []*pb.ProcessorRegistration{first.Processors[1], {
+		Name: "stats", SupportedAlgorithms: []*pb.Algorithm{
+			{Name: "Max", Version: "1.0.0", WindowType: hourly("site", "asset")},
+			{Name: "Mean", Version: "1.0.0", WindowType: hourly("asset", "site")},
+		},
+	}}}
+
+	firstJSON, err := marshalCanonicalJSON(canonicalRegistry(first))
+	if err != nil {
+		t.Fatalf("marshalCanonicalJSON: %v", err)
+	}
+	secondJSON, err := marshalCanonicalJSON(canonicalRegistry(second))
+	if err != nil {
+		t.Fatalf("marshalCanonicalJSON: %v", err)
+	}
+	if !bytes.Equal(firstJSON, secondJSON) {
+		t.Errorf("registries differing only in order serialize differently:\n%s\n%s", firstJSON, secondJSON)
+	}
+	if first.Processors[0].GetName() != "stats" {
+		t.Errorf("canonicalRegistry reordered the registry it was given")
+	}
+}
+
+func TestWriteIndentedJSON(t *testing.T) {
+	decoder := json.NewDecoder(strings.NewReader(`{"name":"a<b>&c","b":[],"a":{"z":1.50,"y":[true,null,{}],"x":"\u00e9\n"}}`))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatal(err)
+	}
+
+	// written value by value, the output must match json.Encoder's
+	var want bytes.Buffer
+	encoder := json.NewEncoder(&want)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	out := bufio.NewWriter(&got)
+	if err := writeIndentedJSON(out, value, "\n"); err != nil {
+		t.Fatal(err)
+	}
+	out.WriteByte('\n')
+	out.Flush()
+	if got.String() != want.String() {
+		t.Errorf("got\n%s\nwant\n%s", got.String(), want.String())
+	}
+}
+
+func TestWriteRegistryFileReplacesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	state := &pb.InternalState{Processors: []*pb.ProcessorRegistration{{Name: "stats"}}}
+	for _, compress := range []bool{false, true} {
+		paths, err := writeRegistryFiles(dir, state, "pb", compress)
+		if err != nil {
+			t.Fatalf("writeRegistryFiles: %v", err)
+		}
+		for _, path := range paths {
+			read, err := readRegistryFile(path)
+			if err != nil {
+				t.Fatalf("readRegistryFile(%s): %v", path, err)
+			}
+			if !proto.Equal(read, state) {
+				t.Errorf("%s holds %v, want %v", path, read, state)
+			}
+		}
+	}
+
+	// a write failing part way leaves the previous file in place
+	path := filepath.Join(dir, registryJSONFile)
+	before, _ := os.ReadFile(path)
+	_, err := writeRegistryFile(path, false, func(w io.Writer) error {
+		w.Write([]byte(`{"processors": [`))
+		return errors.New("interrupted")
+	})
+	if err == nil {
+		t.Fatal("writeRegistryFile returned no error from a failed write")
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+		t.Errorf("%s was changed by a failed write:\n%s", path, after)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			t.Errorf("temporary file %s was left behind", entry.Name())
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// remoteCoreAddress returns orcaConnectionString when it names a core on another
+// host, shared by a team rather than run by `orca start`
+func remoteCoreAddress(config *OrcaConfigFile) string {
+	if config.OrcaConnectionString == "" {
+		return ""
+	}
+	if port, err := config.orcaHostPort(); err != nil || port > 0 {
+		return ""
+	}
+	return config.OrcaConnectionString
+}
+
+// fetchRemoteRegistry reads the registry of the core at address
+func fetchRemoteRegistry(address string, creds credentials.TransportCredentials, timeout time.Duration) (*pb.InternalState, error) {
+	conn, err := grpc.NewClient(address, grpcDialOptions(creds)...)
+	if err != nil {
+		return nil, fmt.Errorf("issue preparing to contact Orca: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	state, err := exposeCompressed(ctx, pb.NewOrcaCoreClient(conn), &pb.ExposeSettings{})
+	if err != nil {
+		return nil, fmt.Errorf("the core at %s does not answer: %w", address, err)
+	}
+	return state, nil
+}
+
+// startProcessorsOnly runs the processors of the stack project against the remote
+// core at address, without Postgres, Redis or a core of their own. The project's
+// processor is deployed when it runs against another core, other deployed
+// processors are only started, and the project's processor must then register.
+func startProcessorsOnly(config *OrcaConfigFile, address string, creds credentials.TransportCredentials) error {
+	fmt.Fprintf(os.Stderr, "Contacting the core at %s... ", address)
+	state, err := fetchRemoteRegistry(address, creds, startupTimeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, renderError("FAILED"))
+		return err
+	}
+	fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("%d processors registered", len(state.GetProcessors()))))
+	fmt.Fprintln(os.Stderr)
+
+	createNetworkIfNotExists()
+	fmt.Fprintln(os.Stderr)
+
+	var own string
+	if config.ProjectName != "" {
+		deployment := &processorDeployment{
+			Project:   config.ProjectName,
+			Image:     processorRepository(config) + ":latest",
+			Container: processorContainerName(config.ProjectName),
+			Port:      cmp.Or(config.ProcessorPort, defaultProcessorPort),
+		}
+		if err := deployment.useRemoteCore(address, config.ProcessorConnectionString); err != nil {
+			return err
+		}
+		if err := dockerCommand("image", "inspect", deployment.Image).Run(); err != nil {
+			return fmt.Errorf("image %s not found. Build it with `orca build`", deployment.Image)
+		}
+		if err := ensureProcessor(deployment); err != nil {
+			return err
+		}
+		own = deployment.Container
+	}
+
+	for _, processor := range listContainers().deployedProcessors() {
+		if processor.Container == own {
+			continue
+		}
+		if env, err := getContainerEnv(processor.Container); err == nil && !slices.Contains(env, "ORCA_CORE="+address) {
+			fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf(
+				"%s runs against another core. Redeploy it from its project with `orca start -processors-only`.",
+				processor.Container,
+			)))
+		}
+		if processor.Status == "running" {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Starting %s... ", processor.Container)
+		if _, err := runDockerRetry("start", processor.Container); err != nil {
+			fmt.Fprintln(os.Stderr, renderError("FAILED"))
+			return fmt.Errorf("failed to start %s: %w", processor.Container, err)
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess("STARTED"))
+	}
+
+	if own == "" {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Waiting for %s to register with the core...\n", config.ProjectName)
+	return waitForRemoteRegistration(address, creds, config.ProjectName)
+}
+
+// ensureProcessor starts the container of a deployment, replacing it when it was
+// created to run another image or against another core
+func ensureProcessor(deployment *processorDeployment) error {
+	state, err := getContainerState(deployment.Container)
+	if err == nil {
+		image, _ := dockerCommand("inspect", "--format", "{{.Config.Image}}", deployment.Container).Output()
+		env, _ := getContainerEnv(deployment.Container)
+		current := strings.TrimSpace(string(image)) == deployment.Image
+		for _, pair := range deployment.env() {
+			current = current && slices.Contains(env, pair)
+		}
+		if current && state.Status == "running" {
+			fmt.Fprintln(os.Stderr, successStyle.Render(fmt.Sprintf("%s already running", deployment.Container)))
+			return nil
+		}
+		if current {
+			fmt.Fprintf(os.Stderr, "Starting %s... ", deployment.Container)
+			if _, err := runDockerRetry("start", deployment.Container); err != nil {
+				fmt.Fprintln(os.Stderr, renderError("FAILED"))
+				return fmt.Errorf("failed to start %s: %w", deployment.Container, err)
+			}
+			fmt.Fprintln(os.Stderr, renderSuccess("STARTED"))
+			return nil
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Deploying %s as %s... ", deployment.Image, deployment.Container)
+	if err := deployment.run(); err != nil {
+		fmt.Fprintln(os.Stderr, renderError("FAILED"))
+		return err
+	}
+	fmt.Fprintln(os.Stderr, renderSuccess("DONE"))
+	return nil
+}
+
+// waitForRemoteRegistration waits up to startupTimeout for a processor of project to
+// be registered with the core at address
+func waitForRemoteRegistration(address string, creds credentials.TransportCredentials, project string) error {
+	deadline := time.Now().Add(startupTimeout)
+	for {
+		state, err := fetchRemoteRegistry(address, creds, max(pollInterval, time.Second))
+		if err == nil {
+			for _, registration := range state.GetProcessors() {
+				if registration.GetProjectName() == project {
+					return nil
+				}
+			}
+			err = fmt.Errorf("no processor of project %q is registered", project)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w after %s, raise the limit with --startup-timeout", err, startupTimeout)
+		}
+		time.Sleep(pollInterval)
+	}
+}
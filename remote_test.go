@@ -0,0 +1,89 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestRemoteCoreAddress(t *testing.T) {
+	for address, want := range map[string]string{
+		"":                         "",
+		"localhost:32670":          "",
+		"127.0.0.1:32670":          "",
+		"core.example.com:443":     "core.example.com:443",
+		"10.0.4.12:32670":          "10.0.4.12:32670",
+		"core.example.com:invalid": "core.example.com:invalid",
+		"not an address":           "",
+	} {
+		if got := remoteCoreAddress(&OrcaConfigFile{OrcaConnectionString: address}); got != want {
+			t.Errorf("remoteCoreAddress(%q) = %q, want %q", address, got, want)
+		}
+	}
+}
+
+func TestUseRemoteCore(t *testing.T) {
+	deployment := &processorDeployment{Container: "orca-processor-demo", Port: defaultProcessorPort}
+	if err := deployment.useRemoteCore("core.example.com:443", ""); err == nil {
+		t.Error("useRemoteCore accepted a remote core without processorConnectionString")
+	}
+	if err := deployment.useRemoteCore("core.example.com:443", "10.0.4.20:70000"); err == nil {
+		t.Error("useRemoteCore accepted an invalid port")
+	}
+
+	if err := deployment.useRemoteCore("core.example.com:443", "10.0.4.20:6377"); err != nil {
+		t.Fatal(err)
+	}
+	env := deployment.env()
+	if !slices.Contains(env, "ORCA_CORE=core.example.com:443") || !slices.Contains(env, "PROCESSOR_ADDRESS=10.0.4.20:6377") {
+		t.Errorf("env = %v, want the processor pointed at the remote core", env)
+	}
+	if args := deployment.runArgs(); !slices.Contains(args, "6377:5377") {
+		t.Errorf("runArgs = %v, want the processor published on the port the remote core reaches it at", args)
+	}
+}
+
+func TestStartProcessorsOnly(t *testing.T) {
+	useFakeEngine(t)
+	if err := setStackProject(""); err != nil {
+		t.Fatal(err)
+	}
+	timeout, interval := startupTimeout, pollInterval
+	t.Cleanup(func() { startupTimeout, pollInterval = timeout, interval })
+	pollInterval = time.Millisecond * 10
+
+	config := &OrcaConfigFile{ProjectName: "demo", ProcessorConnectionString: "10.0.4.20:6377"}
+	local := &processorDeployment{
+		Project:   config.ProjectName,
+		Image:     processorRepository(config) + ":latest",
+		Container: processorContainerName(config.ProjectName),
+		Port:      defaultProcessorPort,
+	}
+	createNetworkIfNotExists()
+	if err := local.run(); err != nil {
+		t.Fatal(err)
+	}
+
+	core := &fakeCore{processors: []*pb.ProcessorRegistration{{Name: "demo", ProjectName: "demo"}}}
+	address := startFakeCore(t, core)
+	if err := startProcessorsOnly(config, address, insecure.NewCredentials()); err != nil {
+		t.Fatalf("startProcessorsOnly: %v", err)
+	}
+	if env, err := getContainerEnv(local.Container); err != nil || !slices.Contains(env, "ORCA_CORE="+address) {
+		t.Errorf("processor env = %v (%v), want it redeployed against the remote core", env, err)
+	}
+	for _, containerName := range []string{orcaContainerName, pgContainerName, redisContainerName} {
+		if got := getContainerStatus(containerName); got != "not found" {
+			t.Errorf("%s is %s, want it not started", containerName, got)
+		}
+	}
+
+	core.processors = nil
+	startupTimeout = time.Millisecond * 50
+	if err := startProcessorsOnly(config, address, insecure.NewCredentials()); err == nil {
+		t.Error("startProcessorsOnly succeeded while the processor never registered")
+	}
+}
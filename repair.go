@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// repairIssue is a discrepancy between the stack and what `orca start` creates
+type repairIssue struct {
+	Resource string
+	Problem  string
+	// Fix describes the repair, which is either done by Apply or by `orca start`
+	Fix   string
+	Apply func() error
+	// Restore resolves the issue in place, without recreating anything, for
+	// `orca doctor -fix`. It is nil when only `orca start` can resolve it.
+	Restore func() error
+	// ReportOnly issues are left for the user to resolve
+	ReportOnly bool
+}
+
+// findRepairIssues inventories the resources of the stack project
+func findRepairIssues() []repairIssue {
+	var issues []repairIssue
+
+	networkExists := dockerCommand("network", "inspect", networkName).Run() == nil
+	if !networkExists {
+		issues = append(issues, repairIssue{
+			Resource: networkName,
+			Problem:  "network is missing",
+			Fix:      "create it",
+			Restore:  createNetworkFunc(),
+		})
+	}
+
+	// volumes come before the containers mounting them, which docker would otherwise
+	// create unlabelled when they are started
+	for _, volumeName := range orcaVolumes {
+		if !volumeExists(volumeName) {
+			issues = append(issues, repairIssue{
+				Resource: volumeName,
+				Problem:  "volume is missing",
+				Fix:      "create it (the store starts empty)",
+				Restore:  createVolumeFunc(volumeName),
+			})
+		}
+	}
+
+	for _, containerName := range []string{pgContainerName, redisContainerName, orcaContainerName} {
+		state, err := getContainerState(containerName)
+		if err != nil {
+			issues = append(issues, repairIssue{
+				Resource: containerName,
+				Problem:  "container is missing",
+				Fix:      "create it",
+			})
+			continue
+		}
+
+		output, err := dockerCommand("inspect", "--format", "{{.HostConfig.NetworkMode}}", containerName).Output()
+		attached := strings.TrimSpace(string(output))
+		switch {
+		case err == nil && (!networkExists || attached != networkName):
+			// a container cannot start once its network is gone, but its data is in a volume
+			issues = append(issues, repairIssue{
+				Resource: containerName,
+				Problem:  fmt.Sprintf("container is attached to missing or foreign network %q", attached),
+				Fix:      "remove and recreate it, keeping its volume",
+				Apply:    removeContainerFunc(containerName),
+			})
+		case state.Status == "created" || state.Status == "dead":
+			// creation was interrupted before the container ever ran
+			issues = append(issues, repairIssue{
+				Resource: containerName,
+				Problem:  fmt.Sprintf("container is %s and never started", state.Status),
+				Fix:      "remove and recreate it, keeping its volume",
+				Apply:    removeContainerFunc(containerName),
+			})
+		case state.crashed():
+			issues = append(issues, repairIssue{
+				Resource: containerName,
+				Problem:  fmt.Sprintf("container exited with code %d", state.ExitCode),
+				Fix:      fmt.Sprintf("start it, check `docker logs %s` if it exits again", containerName),
+				Restore:  startContainerFunc(containerName),
+			})
+		case state.Status != "running":
+			issues = append(issues, repairIssue{
+				Resource: containerName,
+				Problem:  "container is " + state.Status,
+				Fix:      "start it",
+				Restore:  startContainerFunc(containerName),
+			})
+		}
+	}
+
+	// labelled containers that are neither part of the stack, companions nor the canary are only reported
+	known := append(append(append([]string{}, orcaContainers...), companionContainers()...), canaryContainers()...)
+	managed, _ := listManaged("container")
+	for _, containerName := range managed {
+		if !slices.Contains(known, containerName) {
+			issues = append(issues, repairIssue{
+				Resource:   containerName,
+				Problem:    "container is labelled for this stack but unknown to this CLI",
+				Fix:        "none, remove it with `docker rm -f " + containerName + "` if unused",
+				ReportOnly: true,
+			})
+		}
+	}
+	return issues
+}
+
+func removeContainerFunc(containerName string) func() error {
+	return func() error {
+		output, err := dockerCommand("rm", "-f", containerName).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to remove %s: %w: %s", containerName, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+}
+
+func createNetworkFunc() func() error {
+	return func() error {
+		args := append([]string{"network", "create", "--driver", "bridge"}, labelArgs(componentNetwork)...)
+		if _, err := runDockerRetry(append(args, networkName)...); err != nil {
+			return fmt.Errorf("failed to create network %s: %w", networkName, err)
+		}
+		return nil
+	}
+}
+
+func createVolumeFunc(volumeName string) func() error {
+	return func() error {
+		args := append([]string{"volume", "create"}, labelArgs(volumeComponent(volumeName))...)
+		if _, err := runDockerRetry(append(args, volumeName)...); err != nil {
+			return fmt.Errorf("failed to create volume %s: %w", volumeName, err)
+		}
+		return nil
+	}
+}
+
+func startContainerFunc(containerName string) func() error {
+	return func() error {
+		if _, err := runDockerRetry("start", containerName); err != nil {
+			return fmt.Errorf("failed to start %s: %w", containerName, err)
+		}
+		if containerName == pgContainerName {
+			return waitForStore()
+		}
+		return nil
+	}
+}
+
+// showRepairIssues prints the discrepancies found in the stack
+func showRepairIssues(issues []repairIssue) {
+	rows := [][]string{{"RESOURCE", "PROBLEM", "REPAIR"}}
+	for _, issue := range issues {
+		rows = append(rows, []string{issue.Resource, issue.Problem, issue.Fix})
+	}
+	printTable(os.Stdout, rows)
+}
+
+// applyRepairs removes the pieces that `orca start` cannot reuse
+func applyRepairs(issues []repairIssue) error {
+	for _, issue := range issues {
+		if issue.Apply == nil {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Removing %s... ", issue.Resource)
+		if err := issue.Apply(); err != nil {
+			fmt.Fprintln(os.Stderr, renderError("FAILED"))
+			return err
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess("DONE"))
+	}
+	return nil
+}
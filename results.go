@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// resultsFilter narrows which results are exported
+type resultsFilter struct {
+	// Algorithm is an algorithm name, optionally pinned to a version as name@version
+	Algorithm string
+	Since     time.Time
+	Until     time.Time
+}
+
+// buildResultsQuery returns a query joining results with their algorithm and window
+func buildResultsQuery(filter resultsFilter) string {
+	query := `SELECT
+    r.id AS result_id,
+    a.name AS algorithm,
+    a.version AS algorithm_version,
+    wt.name AS window_type,
+    wt.version AS window_type_version,
+    w.id AS window_id,
+    w.time_from,
+    w.time_to,
+    w.origin,
+    w.metadata,
+    r.result_value,
+    r.result_array,
+    r.result_json
+FROM results r
+JOIN algorithm a ON a.id = r.algorithm_id
+JOIN windows w ON w.id = r.windows_id
+JOIN window_type wt ON wt.id = w.window_type_id`
+
+	var conditions []string
+	if filter.Algorithm != "" {
+		name, version, pinned := strings.Cut(filter.Algorithm, "@")
+		conditions = append(conditions, "a.name = "+sqlLiteral(name))
+		if pinned {
+			conditions = append(conditions, "a.version = "+sqlLiteral(version))
+		}
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "w.time_from >= "+sqlLiteral(filter.Since.UTC().Format(time.RFC3339)))
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "w.time_to <= "+sqlLiteral(filter.Until.UTC().Format(time.RFC3339)))
+	}
+	if len(conditions) > 0 {
+		query += "\nWHERE " + strings.Join(conditions, "\n  AND ")
+	}
+	return query + "\nORDER BY w.time_from, r.id"
+}
+
+// exportResultsCSV streams the filtered results straight from Postgres into a CSV file
+func exportResultsCSV(filter resultsFilter, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	copyStmt := fmt.Sprintf("COPY (%s) TO STDOUT WITH (FORMAT csv, HEADER)", buildResultsQuery(filter))
+	cmd := psqlCommand("-c", copyStmt)
+	cmd.Stdout = f
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		os.Remove(path)
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// exportResultsParquet exports to CSV and converts it with the DuckDB CLI, which must be installed
+func exportResultsParquet(filter resultsFilter, path string) error {
+	duckdb, err := exec.LookPath("duckdb")
+	if err != nil {
+		return fmt.Errorf("parquet export requires the DuckDB CLI on your PATH. See https://duckdb.org/docs/installation")
+	}
+
+	csvPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".tmp.csv"
+	if err := exportResultsCSV(filter, csvPath); err != nil {
+		return err
+	}
+	defer os.Remove(csvPath)
+
+	convert := fmt.Sprintf(
+		"COPY (SELECT * FROM read_csv_auto(%s, header = true)) TO %s (FORMAT parquet)",
+		sqlLiteral(csvPath),
+		sqlLiteral(path),
+	)
+	output, err := exec.Command(duckdb, "-c", convert).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("duckdb conversion failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// exportResults writes the filtered results into outDir in the given format, returning the file written
+func exportResults(filter resultsFilter, format, outDir string) (string, error) {
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	path := filepath.Join(outDir, "results."+format)
+	switch format {
+	case "csv":
+		return path, exportResultsCSV(filter, path)
+	case "parquet":
+		return path, exportResultsParquet(filter, path)
+	default:
+		return "", fmt.Errorf("invalid format %q, must be one of: csv, parquet", format)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// dockerRetryAttempts is how often a container operation is tried before giving up
+	dockerRetryAttempts  = 5
+	dockerRetryBaseDelay = time.Millisecond * 500
+	dockerRetryMaxDelay  = time.Second * 8
+)
+
+// transientDockerErrors are fragments of docker error output worth retrying: the
+// daemon restarting, and registry or network hiccups while pulling images
+var transientDockerErrors = []string{
+	"cannot connect to the docker daemon",
+	"is the docker daemon running",
+	"error during connect",
+	"connection refused",
+	"connection reset by peer",
+	"i/o timeout",
+	"tls handshake timeout",
+	"unexpected eof",
+	"context deadline exceeded",
+	"client.timeout exceeded",
+	"temporary failure in name resolution",
+	"toomanyrequests",
+	"500 internal server error",
+	"502 bad gateway",
+	"503 service unavailable",
+}
+
+// isTransientDockerError reports whether a failed docker command may succeed when retried
+func isTransientDockerError(output string) bool {
+	output = strings.ToLower(output)
+	for _, fragment := range transientDockerErrors {
+		if strings.Contains(output, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay returns the backoff before the given retry, starting at 1. The delay
+// doubles each time and is jittered so that concurrent commands do not retry in step.
+func retryDelay(retry int) time.Duration {
+	delay := min(dockerRetryBaseDelay<<(retry-1), dockerRetryMaxDelay)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// retryDocker runs attempt until it succeeds, fails permanently or runs out of
+// attempts. attempt returns the error output of the docker command with its error.
+func retryDocker(args []string, attempt func() (string, error)) error {
+	for retry := 1; ; retry++ {
+		output, err := attempt()
+		if err == nil {
+			return nil
+		}
+		if !isTransientDockerError(output) || retry == dockerRetryAttempts {
+			return err
+		}
+
+		delay := retryDelay(retry)
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf(
+			"docker %s failed with a transient error, retrying in %s (%d/%d)",
+			args[0], delay.Round(time.Millisecond*100), retry, dockerRetryAttempts-1,
+		)))
+		time.Sleep(delay)
+		removeInterruptedContainer(args)
+	}
+}
+
+// removeInterruptedContainer removes the container left behind by a `docker run` or
+// `docker create` that failed after creating it, so that retrying it does not conflict
+func removeInterruptedContainer(args []string) {
+	if args[0] != "run" && args[0] != "create" {
+		return
+	}
+	index := slices.Index(args, "--name")
+	if index < 0 || index+1 >= len(args) {
+		return
+	}
+	containerName := args[index+1]
+	if state, err := getContainerState(containerName); err == nil && state.Status == "created" {
+		dockerCommand("rm", "-f", containerName).Run()
+	}
+}
+
+// runDockerRetry runs a docker command, retrying transient failures, and returns its output
+func runDockerRetry(args ...string) ([]byte, error) {
+	var output []byte
+	err := retryDocker(args, func() (string, error) {
+		var err error
+		output, err = dockerCommand(args...).CombinedOutput()
+		return string(output), err
+	})
+	if err != nil {
+		return output, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return output, nil
+}
+
+// streamDockerCommand runs a docker command, streaming its output under prefix and
+// retrying transient failures. It exits once the command has failed for good.
+func streamDockerCommand(prefix string, args ...string) {
+	if err := streamDockerRetry(prefix, args...); err != nil {
+		fmt.Fprintln(os.Stderr, errorStyle.Render(fmt.Sprintf("%s command failed: %s", prefix, err)))
+		exit(1)
+	}
+}
+
+// streamDockerRetry is streamDockerCommand returning the final error
+func streamDockerRetry(prefix string, args ...string) error {
+	// the error output has already been streamed
+	return retryDocker(args, func() (string, error) {
+		return streamCommandOutput(dockerCommand(args...), prefix)
+	})
+}
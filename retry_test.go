@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRetryDocker(t *testing.T) {
+	attempts := 0
+	err := retryDocker([]string{"pull"}, func() (string, error) {
+		attempts++
+		if attempts < 3 {
+			return "Error response from daemon: Get \"https://registry-1.docker.io/v2/\": net/http: TLS handshake timeout", errors.New("exit status 1")
+		}
+		return "", nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("transient failures: err = %v after %d attempts, want success after 3", err, attempts)
+	}
+
+	attempts = 0
+	err = retryDocker([]string{"run"}, func() (string, error) {
+		attempts++
+		return "docker: invalid reference format.", errors.New("exit status 125")
+	})
+	if err == nil || attempts != 1 {
+		t.Errorf("permanent failure: err = %v after %d attempts, want a failure after 1", err, attempts)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for retry := 1; retry <= 10; retry++ {
+		delay := retryDelay(retry)
+		if delay < dockerRetryBaseDelay/2 || delay > dockerRetryMaxDelay {
+			t.Errorf("retry %d waits %s, outside [%s, %s]", retry, delay, dockerRetryBaseDelay/2, dockerRetryMaxDelay)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// s3Scheme prefixes backup locations in S3-compatible object storage
+const s3Scheme = "s3://"
+
+func isS3URL(location string) bool {
+	return strings.HasPrefix(location, s3Scheme)
+}
+
+// s3ObjectURL returns the object a backup is written to. A location naming only a
+// bucket, or ending in /, is a prefix the archive is written under by name.
+func s3ObjectURL(location, name string) (string, error) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(location, s3Scheme), "/")
+	if bucket == "" {
+		return "", fmt.Errorf("invalid S3 location %q, expected s3://bucket/path", location)
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		key += name
+	}
+	return s3Scheme + bucket + "/" + key, nil
+}
+
+// backupEndpointOf returns the S3-compatible endpoint of backup.endpoint in orca.json
+func backupEndpointOf(config *OrcaConfigFile) string {
+	if config.Backup == nil {
+		return ""
+	}
+	return config.Backup.Endpoint
+}
+
+func checkAWSInstalled() error {
+	if _, err := exec.LookPath("aws"); err != nil {
+		return fmt.Errorf("backups in S3 need the AWS CLI on your PATH. See https://aws.amazon.com/cli/")
+	}
+	return nil
+}
+
+// awsCommand runs the AWS CLI, which resolves credentials the standard way: from the
+// environment, ~/.aws, SSO sessions or instance roles. endpoint points it at an
+// S3-compatible store such as MinIO instead of AWS.
+func awsCommand(endpoint string, args ...string) (*exec.Cmd, error) {
+	if err := checkAWSInstalled(); err != nil {
+		return nil, err
+	}
+	if endpoint != "" {
+		args = append([]string{"--endpoint-url", endpoint}, args...)
+	}
+	cmd := exec.Command("aws", args...)
+	logDebug("+ %s", shellJoin(cmd.Args))
+	return cmd, nil
+}
+
+// runAWS runs an AWS CLI command, showing its progress on stderr
+func runAWS(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s", lastLine(cmp.Or(strings.TrimSpace(stderr.String()), err.Error())))
+	}
+	return nil
+}
+
+// uploadS3 copies a local file to an S3 object
+func uploadS3(local, object, endpoint string) error {
+	cmd, err := awsCommand(endpoint, "s3", "cp", "--no-progress", local, object)
+	if err != nil {
+		return err
+	}
+	if err := runAWS(cmd); err != nil {
+		return fmt.Errorf("failed to upload the backup to %s: %w", object, err)
+	}
+	return nil
+}
+
+// downloadS3 copies an S3 object to a temporary file, which the caller removes
+func downloadS3(object, endpoint string) (string, error) {
+	dir, err := os.MkdirTemp("", "orca-restore-")
+	if err != nil {
+		return "", err
+	}
+	local := filepath.Join(dir, cmp.Or(path.Base(strings.TrimPrefix(object, s3Scheme)), "backup"))
+	cmd, err := awsCommand(endpoint, "s3", "cp", "--no-progress", object, local)
+	if err == nil {
+		err = runAWS(cmd)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to download %s: %w", object, err)
+	}
+	return local, nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestS3ObjectURL(t *testing.T) {
+	tests := []struct {
+		location string
+		want     string
+	}{
+		{"s3://team-backups", "s3://team-backups/orca-backup.tar"},
+		{"s3://team-backups/", "s3://team-backups/orca-backup.tar"},
+		{"s3://team-backups/orca/known-good/", "s3://team-backups/orca/known-good/orca-backup.tar"},
+		{"s3://team-backups/orca/known-good.tar.age", "s3://team-backups/orca/known-good.tar.age"},
+	}
+	for _, test := range tests {
+		got, err := s3ObjectURL(test.location, "orca-backup.tar")
+		if err != nil || got != test.want {
+			t.Errorf("s3ObjectURL(%q) = %q, %v, want %q", test.location, got, err, test.want)
+		}
+	}
+	if _, err := s3ObjectURL("s3://", "orca-backup.tar"); err == nil {
+		t.Error("expected an error for a location without a bucket")
+	}
+}
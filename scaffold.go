@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+)
+
+// packageManagers are the python package managers `orca new processor` scaffolds for
+var packageManagers = []string{"uv", "poetry", "pip"}
+
+const defaultProcessorEntrypoint = "main.py"
+
+// ProcessorConfig describes how `orca run` starts the processor of a project, and
+// how `orca build` packages it
+type ProcessorConfig struct {
+	// PackageManager is one of uv, poetry or pip
+	PackageManager string `json:"packageManager,omitempty"`
+	// Entrypoint is the python script starting the processor, relative to orca.json
+	Entrypoint string `json:"entrypoint,omitempty"`
+	// Image is the repository `orca build` and `orca push` tag the processor image
+	// with, e.g. ghcr.io/acme/speed. Defaults to the project name.
+	Image string `json:"image,omitempty"`
+	// Platforms are built by `orca push` as one multi-platform image, e.g. linux/amd64
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+// defaultPackageManager prefers uv when it is installed
+func defaultPackageManager() string {
+	if _, err := exec.LookPath("uv"); err == nil {
+		return "uv"
+	}
+	return "pip"
+}
+
+const processorMainTemplate = `"""The %[1]s Orca processor, started with ` + "`orca run`" + `"""
+
+from orca_python import ExecutionParams, Processor, ValueResult, WindowType
+
+proc = Processor(%[1]q)
+
+example_window = WindowType(
+    name="ExampleWindow",
+    version="1.0.0",
+    description="An example window, emitted locally with ` + "`orca schedule ExampleWindow`" + `",
+    metadataFields=[],
+)
+
+
+@proc.algorithm("ExampleAlgorithm", "1.0.0", example_window)
+def example_algorithm(params: ExecutionParams) -> ValueResult:
+    """Replace with the algorithm, computing a result from params.window"""
+    _ = params
+    return ValueResult(1.0)
+
+
+if __name__ == "__main__":
+    proc.Register()
+    proc.Start()
+`
+
+const processorGitignore = `.venv/
+__pycache__/
+*.pyc
+`
+
+// processorScaffold returns the files of a new processor project, keyed by path
+// relative to its directory
+func processorScaffold(name, packageManager, requirement string) (map[string]string, error) {
+	files := map[string]string{
+		defaultProcessorEntrypoint: fmt.Sprintf(processorMainTemplate, name),
+		".gitignore":               processorGitignore,
+	}
+	switch packageManager {
+	case "uv":
+		files["pyproject.toml"] = pyprojectContent(name, requirement)
+	case "poetry":
+		// poetry only manages the environment, the project is not packaged
+		files["pyproject.toml"] = pyprojectContent(name, requirement) + "\n[tool.poetry]\npackage-mode = false\n"
+	case "pip":
+		files["requirements.txt"] = requirement + "\n"
+	default:
+		return nil, fmt.Errorf("invalid package manager %q, must be one of: uv, poetry, pip", packageManager)
+	}
+	return files, nil
+}
+
+// scaffoldProcessor writes a new processor project into dir, with an orca.json
+// recording how to run it, returning the paths written. Nothing is written when
+// any of the files already exists.
+func scaffoldProcessor(dir, name, packageManager string) ([]string, error) {
+	requirement, err := pythonSDKRequirement(orcaImageVersion)
+	if err != nil {
+		return nil, err
+	}
+	files, err := processorScaffold(name, packageManager, requirement)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{defaultConfigPath}
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if _, err := os.Stat(filepath.Join(dir, path)); err == nil {
+			return nil, fmt.Errorf("%s already exists, scaffold the processor in a new directory", filepath.Join(dir, path))
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	for path, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", filepath.Join(dir, path), err)
+		}
+	}
+	config := &OrcaConfigFile{
+		ProjectName: name,
+		Processor:   &ProcessorConfig{PackageManager: packageManager, Entrypoint: defaultProcessorEntrypoint},
+	}
+	if err := writeProjectConfig(filepath.Join(dir, defaultConfigPath), config); err != nil {
+		return nil, err
+	}
+
+	written := make([]string, len(paths))
+	for ii, path := range paths {
+		written[ii] = filepath.Join(dir, path)
+	}
+	return written, nil
+}
+
+// venvPython returns the interpreter of the virtual environment in dir
+func venvPython(dir string) string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(dir, ".venv", "Scripts", "python.exe")
+	}
+	return filepath.Join(dir, ".venv", "bin", "python")
+}
+
+// processorCommands returns the commands preparing the environment of a processor
+// in dir, then the command running it
+func processorCommands(config *ProcessorConfig, dir string) ([][]string, []string, error) {
+	entrypoint := config.Entrypoint
+	if entrypoint == "" {
+		entrypoint = defaultProcessorEntrypoint
+	}
+	switch config.PackageManager {
+	case "uv":
+		// uv run syncs the environment itself
+		return nil, []string{"uv", "run", "python", entrypoint}, nil
+	case "poetry":
+		var setup [][]string
+		if _, err := os.Stat(filepath.Join(dir, "poetry.lock")); os.IsNotExist(err) {
+			setup = append(setup, []string{"poetry", "install", "--no-root"})
+		}
+		return setup, []string{"poetry", "run", "python", entrypoint}, nil
+	case "pip", "":
+		python := venvPython(dir)
+		var setup [][]string
+		if _, err := os.Stat(python); os.IsNotExist(err) {
+			system := "python3"
+			if runtime.GOOS == "windows" {
+				system = "python"
+			}
+			setup = append(setup, []string{system, "-m", "venv", ".venv"})
+			if _, err := os.Stat(filepath.Join(dir, "requirements.txt")); err == nil {
+				setup = append(setup, []string{python, "-m", "pip", "install", "-r", "requirements.txt"})
+			}
+		}
+		return setup, []string{python, entrypoint}, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid package manager %q in orca.json, must be one of: uv, poetry, pip", config.PackageManager)
+	}
+}
+
+// processorEnv returns the variables telling a processor where the core is and
+// where the core reaches it, from orca.json
+func processorEnv(config *OrcaConfigFile) []string {
+	var env []string
+	if config.OrcaConnectionString != "" {
+		env = append(env, "ORCA_CORE="+config.OrcaConnectionString)
+	}
+	if config.ProcessorConnectionString != "" {
+		env = append(env, "PROCESSOR_ADDRESS="+config.ProcessorConnectionString)
+	}
+	if config.ProcessorPort > 0 {
+		env = append(env, "PROCESSOR_PORT="+strconv.Itoa(config.ProcessorPort))
+	}
+	return env
+}
+
+// prepareProcessor prepares the environment of the processor in dir, returning the
+// command running it attached to the CLI's standard streams
+func prepareProcessor(config *OrcaConfigFile, dir string) (*exec.Cmd, error) {
+	processor := config.Processor
+	if processor == nil {
+		processor = &ProcessorConfig{PackageManager: "pip"}
+	}
+	// commands run in dir, so paths into it must not be relative
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	setup, command, err := processorCommands(processor, dir)
+	if err != nil {
+		return nil, err
+	}
+	env := append(os.Environ(), processorEnv(config)...)
+
+	newCommand := func(args []string) *exec.Cmd {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = dir
+		cmd.Env = env
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd
+	}
+	for _, args := range setup {
+		fmt.Fprintf(os.Stderr, "Preparing the environment: %s\n", shellJoin(args))
+		if err := newCommand(args).Run(); err != nil {
+			return nil, fmt.Errorf("failed to prepare the processor environment: %w", err)
+		}
+	}
+	return newCommand(command), nil
+}
+
+// runProcessor prepares the environment of the processor in dir and runs it,
+// returning its exit code
+func runProcessor(config *OrcaConfigFile, dir string) int {
+	cmd, err := prepareProcessor(config, dir)
+	if err != nil {
+		printError(err.Error())
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "Running %s\n\n", shellJoin(cmd.Args))
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		printError(fmt.Sprintf("Failed to run the processor: %v", err))
+		return 1
+	}
+	return 0
+}
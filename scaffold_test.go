@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestScaffoldProcessor(t *testing.T) {
+	for _, manager := range packageManagers {
+		dir := filepath.Join(t.TempDir(), "speed")
+		written, err := scaffoldProcessor(dir, "speed", manager)
+		if err != nil {
+			t.Fatalf("scaffoldProcessor with %s: %v", manager, err)
+		}
+		dependencies := "pyproject.toml"
+		if manager == "pip" {
+			dependencies = "requirements.txt"
+		}
+		if !slices.Contains(written, filepath.Join(dir, dependencies)) {
+			t.Errorf("scaffoldProcessor with %s wrote %v, missing %s", manager, written, dependencies)
+		}
+
+		config, err := readProjectConfig(filepath.Join(dir, defaultConfigPath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Processor == nil || config.Processor.PackageManager != manager {
+			t.Errorf("orca.json processor = %+v, want package manager %s", config.Processor, manager)
+		}
+		setup, command, err := processorCommands(config.Processor, dir)
+		if err != nil || len(command) == 0 || command[len(command)-1] != defaultProcessorEntrypoint {
+			t.Errorf("processorCommands with %s = %v, %v, %v", manager, setup, command, err)
+		}
+
+		if _, err := scaffoldProcessor(dir, "speed", manager); err == nil {
+			t.Errorf("scaffoldProcessor over an existing project with %s succeeded", manager)
+		}
+	}
+
+	dir := t.TempDir()
+	if _, err := scaffoldProcessor(dir, "speed", "conda"); err == nil {
+		t.Errorf("scaffoldProcessor with an unknown package manager succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(dir, defaultConfigPath)); !os.IsNotExist(err) {
+		t.Errorf("a failed scaffold wrote orca.json")
+	}
+}
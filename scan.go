@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// scanSeverities are the severities of vulnerabilities, from least to most severe
+var scanSeverities = []string{"unknown", "low", "medium", "high", "critical"}
+
+// scanners are the vulnerability scanners `orca scan` runs, in order of preference
+var scanners = []string{"trivy", "grype"}
+
+// severityRank orders severities, -1 for one that is not known
+func severityRank(severity string) int {
+	return slices.Index(scanSeverities, strings.ToLower(severity))
+}
+
+// vulnerability is a vulnerable package found in an image
+type vulnerability struct {
+	ID        string `json:"id"`
+	Severity  string `json:"severity"`
+	Package   string `json:"package"`
+	Installed string `json:"installed"`
+	// Fixed is the version fixing the vulnerability, empty when there is none yet
+	Fixed string `json:"fixed,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+// imageScan is the report on an image, as printed by `orca scan -o json`
+type imageScan struct {
+	Image           string          `json:"image"`
+	Vulnerabilities []vulnerability `json:"vulnerabilities"`
+	// Error is why the image could not be scanned
+	Error string `json:"error,omitempty"`
+}
+
+// listStackImages lists the images of the stack: those its containers run, or would run,
+// the processors deployed on it, and the project's processor image when built
+func listStackImages(containers containerList, config *OrcaConfigFile) []string {
+	var images []string
+	add := func(image string) {
+		if image != "" && !slices.Contains(images, image) {
+			images = append(images, image)
+		}
+	}
+	for _, component := range stackComponents {
+		containerName, _ := componentContainer(component)
+		add(cmp.Or(containers[containerName].Image, stackImages[component]))
+	}
+	for _, processor := range containers.deployedProcessors() {
+		add(processor.Image)
+	}
+	if config.ProjectName != "" {
+		image := processorRepository(config) + ":latest"
+		if dockerCommand("image", "inspect", "--format", "{{.Id}}", image).Run() == nil {
+			add(image)
+		}
+	}
+	return images
+}
+
+// findScanner returns the scanner to run: the one named, or the first installed
+func findScanner(name string) (string, error) {
+	if name != "" && name != "auto" {
+		if !slices.Contains(scanners, name) {
+			return "", fmt.Errorf("invalid scanner %q, must be one of: auto, %s", name, strings.Join(scanners, ", "))
+		}
+		if _, err := exec.LookPath(name); err != nil {
+			return "", fmt.Errorf("%s is not on your PATH", name)
+		}
+		return name, nil
+	}
+	for _, scanner := range scanners {
+		if _, err := exec.LookPath(scanner); err == nil {
+			return scanner, nil
+		}
+	}
+	return "", fmt.Errorf("orca scan requires Trivy or Grype on your PATH. See https://trivy.dev or https://github.com/anchore/grype")
+}
+
+// scanImage scans an image with a scanner, returning its vulnerabilities from the
+// most severe
+func scanImage(scanner, image string) ([]vulnerability, error) {
+	var cmd *exec.Cmd
+	switch scanner {
+	case "trivy":
+		cmd = exec.Command("trivy", "image", "--quiet", "--format", "json", image)
+	case "grype":
+		cmd = exec.Command("grype", image, "--quiet", "--output", "json")
+	default:
+		return nil, fmt.Errorf("unknown scanner %q", scanner)
+	}
+	logDebug("+ %s", shellJoin(cmd.Args))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %s", scanner, lastLine(msg))
+		}
+		return nil, fmt.Errorf("%s failed: %w", scanner, err)
+	}
+
+	var vulnerabilities []vulnerability
+	var err error
+	if scanner == "trivy" {
+		vulnerabilities, err = parseTrivyReport(stdout.Bytes())
+	} else {
+		vulnerabilities, err = parseGrypeReport(stdout.Bytes())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the report of %s: %w", scanner, err)
+	}
+	sortVulnerabilities(vulnerabilities)
+	return vulnerabilities, nil
+}
+
+// lastLine returns the last line of a scanner's error output, which ends with the
+// reason it failed
+func lastLine(output string) string {
+	return output[strings.LastIndex(output, "\n")+1:]
+}
+
+func parseTrivyReport(data []byte) ([]vulnerability, error) {
+	var report struct {
+		Results []struct {
+			Vulnerabilities []struct {
+				VulnerabilityID  string
+				PkgName          string
+				InstalledVersion string
+				FixedVersion     string
+				Severity         string
+				Title            string
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	vulnerabilities := []vulnerability{}
+	for _, result := range report.Results {
+		for _, found := range result.Vulnerabilities {
+			vulnerabilities = append(vulnerabilities, vulnerability{
+				ID:        found.VulnerabilityID,
+				Severity:  normalizeSeverity(found.Severity),
+				Package:   found.PkgName,
+				Installed: found.InstalledVersion,
+				Fixed:     found.FixedVersion,
+				Title:     found.Title,
+			})
+		}
+	}
+	return vulnerabilities, nil
+}
+
+func parseGrypeReport(data []byte) ([]vulnerability, error) {
+	var report struct {
+		Matches []struct {
+			Vulnerability struct {
+				ID          string `json:"id"`
+				Severity    string `json:"severity"`
+				Description string `json:"description"`
+				Fix         struct {
+					Versions []string `json:"versions"`
+				} `json:"fix"`
+			} `json:"vulnerability"`
+			Artifact struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"artifact"`
+		} `json:"matches"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	vulnerabilities := []vulnerability{}
+	for _, match := range report.Matches {
+		vulnerabilities = append(vulnerabilities, vulnerability{
+			ID:        match.Vulnerability.ID,
+			Severity:  normalizeSeverity(match.Vulnerability.Severity),
+			Package:   match.Artifact.Name,
+			Installed: match.Artifact.Version,
+			Fixed:     strings.Join(match.Vulnerability.Fix.Versions, ", "),
+		})
+	}
+	return vulnerabilities, nil
+}
+
+// normalizeSeverity maps the severities of the scanners onto scanSeverities. Grype's
+// negligible counts as low.
+func normalizeSeverity(severity string) string {
+	severity = strings.ToLower(severity)
+	if severity == "negligible" {
+		return "low"
+	}
+	if severityRank(severity) < 0 {
+		return "unknown"
+	}
+	return severity
+}
+
+// sortVulnerabilities orders vulnerabilities from the most severe, then by package
+// and id
+func sortVulnerabilities(vulnerabilities []vulnerability) {
+	slices.SortStableFunc(vulnerabilities, func(a, b vulnerability) int {
+		return cmp.Or(
+			cmp.Compare(severityRank(b.Severity), severityRank(a.Severity)),
+			cmp.Compare(a.Package, b.Package),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+}
+
+// filterSeverity keeps the vulnerabilities at least as severe as minimum
+func filterSeverity(vulnerabilities []vulnerability, minimum string) []vulnerability {
+	kept := []vulnerability{}
+	for _, found := range vulnerabilities {
+		if severityRank(found.Severity) >= severityRank(minimum) {
+			kept = append(kept, found)
+		}
+	}
+	return kept
+}
+
+// scanFails reports whether any image has a vulnerability at least as severe as
+// failOn, or could not be scanned. An empty failOn never fails.
+func scanFails(scans []imageScan, failOn string) bool {
+	if failOn == "" || failOn == "none" {
+		return false
+	}
+	for _, scan := range scans {
+		if scan.Error != "" || len(filterSeverity(scan.Vulnerabilities, failOn)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// showScans prints the vulnerabilities of each image with a count per severity
+func showScans(w io.Writer, scans []imageScan) {
+	for ii, scan := range scans {
+		if ii > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, scan.Image)
+		if scan.Error != "" {
+			fmt.Fprintln(w, renderStdout(errorStyle, "  "+scan.Error))
+			continue
+		}
+		if len(scan.Vulnerabilities) == 0 {
+			fmt.Fprintln(w, renderStdout(successStyle, "  No vulnerabilities"))
+			continue
+		}
+
+		perSeverity := map[string]int{}
+		for _, found := range scan.Vulnerabilities {
+			perSeverity[found.Severity]++
+		}
+		var counts []string
+		for _, severity := range slices.Backward(scanSeverities) {
+			if perSeverity[severity] > 0 {
+				counts = append(counts, fmt.Sprintf("%d %s", perSeverity[severity], severity))
+			}
+		}
+		fmt.Fprintf(w, "  %s\n", strings.Join(counts, ", "))
+		rows := [][]string{{"SEVERITY", "ID", "PACKAGE", "INSTALLED", "FIXED"}}
+		for _, found := range scan.Vulnerabilities {
+			rows = append(rows, []string{strings.ToUpper(found.Severity), found.ID, found.Package, found.Installed, cmp.Or(found.Fixed, "-")})
+		}
+		printTable(w, rows)
+	}
+}
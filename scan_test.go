@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseTrivyReport(t *testing.T) {
+	report := `{
+		"ArtifactName": "postgres:16",
+		"Results": [
+			{"Target": "postgres:16 (debian 12.5)", "Vulnerabilities": [
+				{"VulnerabilityID": "CVE-2024-0001", "PkgName": "libssl3", "InstalledVersion": "3.0.11", "FixedVersion": "3.0.13", "Severity": "HIGH", "Title": "openssl: overflow"},
+				{"VulnerabilityID": "CVE-2024-0002", "PkgName": "zlib1g", "InstalledVersion": "1.2.13", "Severity": "UNKNOWN"}
+			]},
+			{"Target": "usr/local/bin/gosu", "Vulnerabilities": null}
+		]
+	}`
+	vulnerabilities, err := parseTrivyReport([]byte(report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []vulnerability{
+		{ID: "CVE-2024-0001", Severity: "high", Package: "libssl3", Installed: "3.0.11", Fixed: "3.0.13", Title: "openssl: overflow"},
+		{ID: "CVE-2024-0002", Severity: "unknown", Package: "zlib1g", Installed: "1.2.13"},
+	}
+	if !slices.Equal(vulnerabilities, want) {
+		t.Errorf("got %+v, want %+v", vulnerabilities, want)
+	}
+
+	if _, err := parseTrivyReport([]byte("not json")); err == nil {
+		t.Error("expected an error for an invalid report")
+	}
+}
+
+func TestParseGrypeReport(t *testing.T) {
+	report := `{"matches": [
+		{"vulnerability": {"id": "CVE-2023-1234", "severity": "Negligible", "fix": {"versions": []}}, "artifact": {"name": "tar", "version": "1.34"}},
+		{"vulnerability": {"id": "GHSA-xxxx", "severity": "Critical", "fix": {"versions": ["1.2.1", "1.3.0"]}}, "artifact": {"name": "grpcio", "version": "1.2.0"}}
+	]}`
+	vulnerabilities, err := parseGrypeReport([]byte(report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []vulnerability{
+		{ID: "CVE-2023-1234", Severity: "low", Package: "tar", Installed: "1.34"},
+		{ID: "GHSA-xxxx", Severity: "critical", Package: "grpcio", Installed: "1.2.0", Fixed: "1.2.1, 1.3.0"},
+	}
+	if !slices.Equal(vulnerabilities, want) {
+		t.Errorf("got %+v, want %+v", vulnerabilities, want)
+	}
+}
+
+func TestScanSeverities(t *testing.T) {
+	vulnerabilities := []vulnerability{
+		{ID: "CVE-3", Severity: "low", Package: "b"},
+		{ID: "CVE-2", Severity: "critical", Package: "b"},
+		{ID: "CVE-1", Severity: "critical", Package: "a"},
+		{ID: "CVE-4", Severity: "unknown", Package: "a"},
+		{ID: "CVE-5", Severity: "medium", Package: "a"},
+	}
+	sortVulnerabilities(vulnerabilities)
+	var ids []string
+	for _, found := range vulnerabilities {
+		ids = append(ids, found.ID)
+	}
+	if want := []string{"CVE-1", "CVE-2", "CVE-5", "CVE-3", "CVE-4"}; !slices.Equal(ids, want) {
+		t.Errorf("sorted %v, want %v", ids, want)
+	}
+
+	if kept := filterSeverity(vulnerabilities, "medium"); len(kept) != 3 {
+		t.Errorf("-severity medium kept %d vulnerabilities, want 3", len(kept))
+	}
+	if kept := filterSeverity(vulnerabilities, "unknown"); len(kept) != 5 {
+		t.Errorf("-severity unknown kept %d vulnerabilities, want 5", len(kept))
+	}
+
+	scans := []imageScan{{Image: "a", Vulnerabilities: filterSeverity(vulnerabilities, "low")}}
+	tests := []struct {
+		failOn string
+		want   bool
+	}{
+		{"none", false},
+		{"", false},
+		{"high", true},
+		{"critical", true},
+	}
+	for _, test := range tests {
+		if got := scanFails(scans, test.failOn); got != test.want {
+			t.Errorf("scanFails(-fail-on %q) = %v, want %v", test.failOn, got, test.want)
+		}
+	}
+
+	clean := []imageScan{{Image: "a", Vulnerabilities: []vulnerability{{ID: "CVE-3", Severity: "low"}}}}
+	if scanFails(clean, "high") {
+		t.Error("an image with only low vulnerabilities failed -fail-on high")
+	}
+	if !scanFails(append(clean, imageScan{Image: "b", Error: "trivy failed"}), "high") {
+		t.Error("an image that could not be scanned passed -fail-on high")
+	}
+}
+
+func TestShowScans(t *testing.T) {
+	scans := []imageScan{
+		{Image: "ghcr.io/orca-telemetry/core:latest", Vulnerabilities: []vulnerability{
+			{ID: "CVE-1", Severity: "critical", Package: "libssl3", Installed: "3.0.11", Fixed: "3.0.13"},
+			{ID: "CVE-2", Severity: "high", Package: "zlib1g", Installed: "1.2.13"},
+			{ID: "CVE-3", Severity: "high", Package: "tar", Installed: "1.34"},
+		}},
+		{Image: "redis:7", Vulnerabilities: []vulnerability{}},
+		{Image: "missing:latest", Error: "trivy failed: no such image"},
+	}
+	var out bytes.Buffer
+	showScans(&out, scans)
+	output := out.String()
+	for _, want := range []string{"1 critical, 2 high", "CVE-1", "3.0.13", "No vulnerabilities", "trivy failed: no such image"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+}
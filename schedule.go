@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// scheduleOrigin is the origin of windows emitted by `orca schedule`, unless one is given
+const scheduleOrigin = "orca-schedule"
+
+// findWindowType looks a window type up among those the registered algorithms take.
+// A spec of name@version pins the version, which may otherwise be left out when
+// only one version is registered.
+func findWindowType(state *pb.InternalState, spec string) (*pb.WindowType, error) {
+	name, version, _ := strings.Cut(spec, "@")
+	var matches []*pb.WindowType
+	var versions []string
+	for _, processor := range state.GetProcessors() {
+		for _, algorithm := range processor.GetSupportedAlgorithms() {
+			windowType := algorithm.GetWindowType()
+			if windowType.GetName() != name || slices.Contains(versions, windowType.GetVersion()) {
+				continue
+			}
+			if version == "" || windowType.GetVersion() == version {
+				matches = append(matches, windowType)
+			}
+			versions = append(versions, windowType.GetVersion())
+		}
+	}
+
+	switch {
+	case len(matches) == 1:
+		return matches[0], nil
+	case len(matches) > 1:
+		sort.Strings(versions)
+		return nil, fmt.Errorf("window type %s is registered in several versions, pick one as %s@<version>: %s", name, name, strings.Join(versions, ", "))
+	case len(versions) > 0:
+		sort.Strings(versions)
+		return nil, fmt.Errorf("window type %s has no version %s, registered versions are: %s", name, version, strings.Join(versions, ", "))
+	}
+	return nil, fmt.Errorf("no registered algorithm takes window type %s. Is its processor running?", name)
+}
+
+// missingMetadataFields returns the metadata fields of the window type absent from metadata
+func missingMetadataFields(windowType *pb.WindowType, metadata map[string]any) []string {
+	var missing []string
+	for _, field := range windowType.GetMetadataFields() {
+		if _, ok := metadata[field.GetName()]; !ok {
+			missing = append(missing, field.GetName())
+		}
+	}
+	return missing
+}
+
+// scheduleTick is what metadata templates of `orca schedule` are rendered with
+type scheduleTick struct {
+	// Seq counts the windows emitted, from 1
+	Seq  int
+	From time.Time
+	To   time.Time
+}
+
+var scheduleTemplateFuncs = template.FuncMap{
+	"mod": func(a, b int) int { return a % b },
+	// pick cycles through its values as the sequence advances, e.g. {{pick .Seq "a" "b"}}
+	"pick": func(seq int, values ...string) string {
+		if len(values) == 0 {
+			return ""
+		}
+		return values[(seq-1)%len(values)]
+	},
+	"randInt": func(low, high int) int { return low + rand.IntN(high-low+1) },
+	"randFloat": func(low, high float64) float64 {
+		return low + rand.Float64()*(high-low)
+	},
+}
+
+// metadataTemplates holds window metadata whose string values are Go templates
+type metadataTemplates map[string]*template.Template
+
+// parseMetadataTemplates parses the string values of metadata as templates. Other
+// values are emitted as given.
+func parseMetadataTemplates(metadata map[string]any) (metadataTemplates, map[string]any, error) {
+	templates := metadataTemplates{}
+	fixed := map[string]any{}
+	for key, value := range metadata {
+		text, ok := value.(string)
+		if !ok || !strings.Contains(text, "{{") {
+			fixed[key] = value
+			continue
+		}
+		tmpl, err := template.New(key).Funcs(scheduleTemplateFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid template for metadata %s: %w", key, err)
+		}
+		templates[key] = tmpl
+	}
+	return templates, fixed, nil
+}
+
+// render renders the templates for a tick. Rendered values that parse as JSON keep
+// their type, so that {{.Seq}} yields a number.
+func (t metadataTemplates) render(fixed map[string]any, tick scheduleTick) (map[string]any, error) {
+	metadata := make(map[string]any, len(fixed)+len(t))
+	for key, value := range fixed {
+		metadata[key] = value
+	}
+	for key, tmpl := range t {
+		var out strings.Builder
+		if err := tmpl.Execute(&out, tick); err != nil {
+			return nil, fmt.Errorf("failed to render metadata %s: %w", key, err)
+		}
+		var value any
+		if err := json.Unmarshal([]byte(out.String()), &value); err != nil {
+			value = out.String()
+		}
+		metadata[key] = value
+	}
+	return metadata, nil
+}
+
+// nextTick returns the next multiple of every after now, so that windows line up
+// with the wall clock as with cron
+func nextTick(now time.Time, every time.Duration) time.Time {
+	return now.Truncate(every).Add(every)
+}
+
+// windowSchedule emits windows of a window type on a fixed cadence
+type windowSchedule struct {
+	WindowType *pb.WindowType
+	Every      time.Duration
+	// Span is how much time each window covers, ending at its tick. Defaults to Every.
+	Span      time.Duration
+	Origin    string
+	Templates metadataTemplates
+	Fixed     map[string]any
+	// Count stops the schedule after this many windows, zero runs until interrupted
+	Count   int
+	Timeout time.Duration
+}
+
+// buildWindow builds the window of a tick
+func (s *windowSchedule) buildWindow(tick scheduleTick) (*pb.Window, error) {
+	window := &pb.Window{
+		TimeFrom:          timestamppb.New(tick.From),
+		TimeTo:            timestamppb.New(tick.To),
+		WindowTypeName:    s.WindowType.GetName(),
+		WindowTypeVersion: s.WindowType.GetVersion(),
+		Origin:            s.Origin,
+	}
+	metadata, err := s.Templates.render(s.Fixed, tick)
+	if err != nil {
+		return nil, err
+	}
+	if len(metadata) > 0 {
+		if window.Metadata, err = structpb.NewStruct(metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata: %w", err)
+		}
+	}
+	return window, nil
+}
+
+// run emits windows to the core until interrupted or Count windows were emitted,
+// returning how many were emitted and how many failed
+func (s *windowSchedule) run(client pb.OrcaCoreClient) (int, int) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	span := s.Span
+	if span == 0 {
+		span = s.Every
+	}
+	fmt.Fprintf(os.Stderr, "Emitting a %s %s window every %s. Press Ctrl+C to stop.\n",
+		s.WindowType.GetName(), s.WindowType.GetVersion(), s.Every)
+
+	emitted, failed := 0, 0
+	for s.Count == 0 || emitted+failed < s.Count {
+		next := nextTick(time.Now(), s.Every)
+		select {
+		case <-ctx.Done():
+			return emitted, failed
+		case <-time.After(time.Until(next)):
+		}
+
+		tick := scheduleTick{Seq: emitted + failed + 1, From: next.Add(-span), To: next}
+		prefix := fmt.Sprintf("[%s] #%d %s to %s", next.Format(time.TimeOnly), tick.Seq, tick.From.Format(time.TimeOnly), tick.To.Format(time.TimeOnly))
+		window, err := s.buildWindow(tick)
+		if err != nil {
+			failed++
+			fmt.Fprintln(os.Stderr, prefix, renderError(err.Error()))
+			continue
+		}
+
+		emitCtx, cancel := context.WithTimeout(ctx, s.Timeout)
+		status, err := client.EmitWindow(emitCtx, window)
+		cancel()
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintln(os.Stderr, prefix, renderError("FAILED: "+err.Error()))
+		case status.GetStatus() == pb.WindowEmitStatus_TRIGGERING_FAILED:
+			failed++
+			fmt.Fprintln(os.Stderr, prefix, renderError("the core failed to trigger processing"))
+		case status.GetStatus() == pb.WindowEmitStatus_NO_TRIGGERED_ALGORITHMS:
+			emitted++
+			fmt.Fprintln(os.Stderr, prefix, warningStyle.Render("no algorithms triggered"))
+		default:
+			emitted++
+			fmt.Fprintln(os.Stderr, prefix, renderSuccess("processing triggered"))
+		}
+	}
+	return emitted, failed
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// configSchemaURL is where the schema of orca.json is published, referenced by the
+// $schema of written files so editors can complete and validate them
+const configSchemaURL = "https://raw.githubusercontent.com/orca-telemetry/cli/main/orca.schema.json"
+
+// configSchema is the published schema of orca.json. It is generated from the config
+// structs by generateConfigSchema, and a test keeps the two in step.
+//
+//go:embed orca.schema.json
+var configSchema []byte
+
+// jsonSchema is the subset of JSON Schema generated for, and checked in, orca.json
+type jsonSchema struct {
+	Schema      string                 `json:"$schema,omitempty"`
+	ID          string                 `json:"$id,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Properties  map[string]*jsonSchema `json:"properties,omitempty"`
+	// AdditionalProperties is false, or the schema of the values of a map
+	AdditionalProperties any         `json:"additionalProperties,omitempty"`
+	PropertyNames        *jsonSchema `json:"propertyNames,omitempty"`
+	Items                *jsonSchema `json:"items,omitempty"`
+	Enum                 []string    `json:"enum,omitempty"`
+	Pattern              string      `json:"pattern,omitempty"`
+	Minimum              *int        `json:"minimum,omitempty"`
+	Maximum              *int        `json:"maximum,omitempty"`
+}
+
+// configSchemaConstraints narrow settings by their dotted path, where * matches any
+// key of a map. Descriptions come from the config keys of `orca config`.
+var configSchemaConstraints = map[string]func(schema *jsonSchema){
+	"processorPort":            portRange,
+	"core.logLevel":            enumOf(coreLogLevels),
+	"redis.persistence":        enumOf(redisPersistenceModes),
+	"redis.evictionPolicy":     enumOf(redisEvictionPolicies),
+	"processor.packageManager": enumOf(packageManagers),
+	"processor.platforms.*":    func(schema *jsonSchema) { schema.Pattern = platformPattern.String() },
+	"restart": func(schema *jsonSchema) {
+		schema.PropertyNames = &jsonSchema{Enum: restartComponents}
+	},
+	"restart.*": func(schema *jsonSchema) {
+		schema.Pattern = "^(" + strings.Join(restartPolicies, "|") + ")(:[0-9]+)?$"
+	},
+	"services.*": enumOf(companionChoices()),
+}
+
+func enumOf(values []string) func(schema *jsonSchema) {
+	return func(schema *jsonSchema) { schema.Enum = values }
+}
+
+func portRange(schema *jsonSchema) {
+	minimum, maximum := 0, 65535
+	schema.Minimum, schema.Maximum = &minimum, &maximum
+}
+
+// generateConfigSchema returns the JSON Schema of orca.json, from the json tags of
+// OrcaConfigFile
+func generateConfigSchema() ([]byte, error) {
+	schema := schemaOf(reflect.TypeOf(OrcaConfigFile{}), "")
+	schema.Schema = "https://json-schema.org/draft/2020-12/schema"
+	schema.ID = configSchemaURL
+	schema.Title = "orca.json"
+	schema.Description = "Configuration of an Orca project, read by the orca CLI"
+	data, err := json.MarshalIndent(schema, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// schemaOf returns the schema of a Go type at a dotted path of orca.json
+func schemaOf(t reflect.Type, path string) *jsonSchema {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	schema := &jsonSchema{}
+	switch t.Kind() {
+	case reflect.Struct:
+		schema.Type = "object"
+		schema.Properties = map[string]*jsonSchema{}
+		schema.AdditionalProperties = false
+		for ii := 0; ii < t.NumField(); ii++ {
+			field := t.Field(ii)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			schema.Properties[name] = schemaOf(field.Type, joinSchemaPath(path, name))
+		}
+	case reflect.Map:
+		schema.Type = "object"
+		schema.AdditionalProperties = schemaOf(t.Elem(), joinSchemaPath(path, "*"))
+	case reflect.Slice:
+		schema.Type = "array"
+		schema.Items = schemaOf(t.Elem(), joinSchemaPath(path, "*"))
+	case reflect.String:
+		schema.Type = "string"
+	case reflect.Int, reflect.Int64:
+		schema.Type = "integer"
+	case reflect.Bool:
+		schema.Type = "boolean"
+	}
+
+	if key, ok := configKeys[path]; ok {
+		schema.Description = key.Description
+	}
+	if constrain, ok := configSchemaConstraints[path]; ok {
+		constrain(schema)
+	}
+	return schema
+}
+
+func joinSchemaPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// validateJSONSchema checks a JSON document against a schema, returning a problem
+// for each value that does not conform, located by its dotted path
+func validateJSONSchema(schemaData, data []byte) ([]string, error) {
+	var schema jsonSchema
+	if err := json.Unmarshal(schemaData, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	var problems []string
+	schema.check(value, "", &problems)
+	return problems, nil
+}
+
+// UnmarshalJSON reads additionalProperties as either false or a schema
+func (s *jsonSchema) UnmarshalJSON(data []byte) error {
+	type plainSchema jsonSchema
+	var raw struct {
+		plainSchema
+		AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = jsonSchema(raw.plainSchema)
+	if len(raw.AdditionalProperties) == 0 {
+		return nil
+	}
+	var allowed bool
+	if json.Unmarshal(raw.AdditionalProperties, &allowed) == nil {
+		s.AdditionalProperties = allowed
+		return nil
+	}
+	additional := &jsonSchema{}
+	if err := json.Unmarshal(raw.AdditionalProperties, additional); err != nil {
+		return err
+	}
+	s.AdditionalProperties = additional
+	return nil
+}
+
+func (s *jsonSchema) check(value any, path string, problems *[]string) {
+	at := path
+	if at == "" {
+		at = "the document"
+	}
+	fail := func(format string, args ...any) {
+		*problems = append(*problems, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := joinSchemaPath(path, key)
+			if s.PropertyNames != nil && len(s.PropertyNames.Enum) > 0 && !slices.Contains(s.PropertyNames.Enum, key) {
+				*problems = append(*problems, fmt.Sprintf("%s: unknown key, must be one of: %s", child, strings.Join(s.PropertyNames.Enum, ", ")))
+				continue
+			}
+			if property, ok := s.Properties[key]; ok {
+				property.check(object[key], child, problems)
+				continue
+			}
+			switch additional := s.AdditionalProperties.(type) {
+			case bool:
+				if !additional {
+					*problems = append(*problems, child+": unknown setting")
+				}
+			case *jsonSchema:
+				additional.check(object[key], child, problems)
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for ii, item := range items {
+			if s.Items != nil {
+				s.Items.check(item, fmt.Sprintf("%s[%d]", path, ii), problems)
+			}
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, text) {
+			fail("%q must be one of: %s", text, strings.Join(s.Enum, ", "))
+		}
+		if s.Pattern != "" {
+			if pattern, err := regexp.Compile(s.Pattern); err == nil && !pattern.MatchString(text) {
+				fail("%q does not match %s", text, s.Pattern)
+			}
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			fail("must be a whole number")
+			return
+		}
+		if s.Minimum != nil && number < float64(*s.Minimum) {
+			fail("must be at least %d", *s.Minimum)
+		}
+		if s.Maximum != nil && number > float64(*s.Maximum) {
+			fail("must be at most %d", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be true or false")
+		}
+	}
+}
+
+// validateProjectConfigFile checks an orca.json against its schema, returning the
+// problems found. A missing file has none.
+func validateProjectConfigFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	problems, err := validateJSONSchema(configSchema, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return problems, nil
+}
+
+// schemaProblemsError describes the problems of a config file in one error
+func schemaProblemsError(path string, problems []string) error {
+	return fmt.Errorf("%s does not match its schema:\n  %s", path, strings.Join(problems, "\n  "))
+}
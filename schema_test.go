@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConfigSchemaUpToDate(t *testing.T) {
+	generated, err := generateConfigSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(generated, configSchema) {
+		t.Errorf("orca.schema.json is out of date with the config structs, regenerate it with `go run . config schema > orca.schema.json`")
+	}
+}
+
+func TestValidateConfigSchema(t *testing.T) {
+	valid := `{
+		"$schema": "` + configSchemaURL + `",
+		"projectName": "speed",
+		"processorPort": 5377,
+		"core": {"logLevel": "info", "env": {"FOO": "bar"}},
+		"restart": {"core": "on-failure:3"},
+		"processor": {"packageManager": "uv", "platforms": ["linux/arm64"]}
+	}`
+	if problems, err := validateJSONSchema(configSchema, []byte(valid)); err != nil || len(problems) > 0 {
+		t.Errorf("a valid orca.json has problems %v, %v", problems, err)
+	}
+
+	invalid := `{
+		"projectName": 1,
+		"processorPort": 70000,
+		"projectname": "speed",
+		"core": {"logLevel": "verbose"},
+		"restart": {"api": "always"},
+		"processor": {"platforms": ["arm64"]}
+	}`
+	problems, err := validateJSONSchema(configSchema, []byte(invalid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"projectName:", "processorPort:", "projectname:", "core.logLevel:", "restart.api:", "processor.platforms[0]:"} {
+		found := false
+		for _, problem := range problems {
+			found = found || strings.HasPrefix(problem, path)
+		}
+		if !found {
+			t.Errorf("no problem reported for %s in %v", strings.TrimSuffix(path, ":"), problems)
+		}
+	}
+	if len(problems) != 6 {
+		t.Errorf("problems = %v, want 6", problems)
+	}
+}
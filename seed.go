@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const seedQuoteTag = "$orca_seed$"
+
+var (
+	// optional ordering prefix on fixture file names, e.g. "01_" in 01_window_type.json
+	seedOrderPrefix = regexp.MustCompile(`^\d+[_-]`)
+	sqlIdentifier   = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+)
+
+// seedTableName derives the target table from a JSON fixture file name
+func seedTableName(path string) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	name = seedOrderPrefix.ReplaceAllString(name, "")
+	if !sqlIdentifier.MatchString(name) {
+		return "", fmt.Errorf("%s does not name a valid table", filepath.Base(path))
+	}
+	return name, nil
+}
+
+// buildJSONSeedSQL converts a JSON array of row objects into an INSERT for the table,
+// returning the statement and the number of rows it inserts. Columns missing from
+// every row are left to their database defaults, e.g. generated ids.
+func buildJSONSeedSQL(table string, data []byte) (string, int, error) {
+	var rows []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return "", 0, fmt.Errorf("expected a JSON array of objects: %w", err)
+	}
+	if len(rows) == 0 {
+		return "", 0, nil
+	}
+
+	columnSet := map[string]bool{}
+	for _, row := range rows {
+		for column := range row {
+			if !sqlIdentifier.MatchString(column) {
+				return "", 0, fmt.Errorf("invalid column name %q", column)
+			}
+			columnSet[column] = true
+		}
+	}
+	columns := make([]string, 0, len(columnSet))
+	for column := range columnSet {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	if bytes.Contains(data, []byte(seedQuoteTag)) {
+		return "", 0, fmt.Errorf("fixture must not contain %s", seedQuoteTag)
+	}
+
+	columnList := strings.Join(columns, ", ")
+	statement := fmt.Sprintf(
+		"INSERT INTO %s (%s) SELECT %s FROM json_populate_recordset(NULL::%s, %s%s%s);",
+		table,
+		columnList,
+		columnList,
+		table,
+		seedQuoteTag,
+		data,
+		seedQuoteTag,
+	)
+	return statement, len(rows), nil
+}
+
+// seedFixtures loads every .sql and .json fixture in dir into the store, in file name
+// order. Each file is applied in its own transaction.
+func seedFixtures(dir string, dryRun bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read fixture directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if !entry.IsDir() && (ext == ".sql" || ext == ".json") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		return fmt.Errorf("no .sql or .json fixtures found in %s", dir)
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		var statement, summary string
+		if strings.ToLower(filepath.Ext(file)) == ".json" {
+			table, err := seedTableName(file)
+			if err != nil {
+				return err
+			}
+			sql, count, err := buildJSONSeedSQL(table, data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", filepath.Base(file), err)
+			}
+			statement = sql
+			summary = fmt.Sprintf("%d row(s) into %s", count, table)
+		} else {
+			statement = string(data)
+			summary = "SQL script"
+		}
+
+		if dryRun {
+			fmt.Fprintf(os.Stderr, "Would load %s (%s)\n", filepath.Base(file), summary)
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "Loading %s (%s)... ", filepath.Base(file), summary)
+		if statement != "" {
+			if _, err := runPsql(strings.NewReader(statement), "--single-transaction", "-q", "-f", "-"); err != nil {
+				fmt.Fprintln(os.Stderr, renderError("FAILED"))
+				return fmt.Errorf("%s: %w", filepath.Base(file), err)
+			}
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess("DONE"))
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+// processorResource is a processor, as served by `orca serve` at /processors
+type processorResource struct {
+	Name             string `json:"name"`
+	Project          string `json:"project,omitempty"`
+	Runtime          string `json:"runtime"`
+	ConnectionString string `json:"connectionString"`
+	// Algorithms are named name@version
+	Algorithms []string `json:"algorithms"`
+}
+
+// algorithmResource is an algorithm, as served at /algorithms
+type algorithmResource struct {
+	Processor   string `json:"processor"`
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+	// WindowType is named name@version
+	WindowType string `json:"windowType"`
+	ResultType string `json:"resultType"`
+	// Dependencies are named processor/name@version
+	Dependencies []string `json:"dependencies"`
+}
+
+// windowTypeResource is a window type, as served at /windows
+type windowTypeResource struct {
+	Name           string                  `json:"name"`
+	Version        string                  `json:"version"`
+	Description    string                  `json:"description,omitempty"`
+	MetadataFields []metadataFieldResource `json:"metadataFields"`
+}
+
+type metadataFieldResource struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// registryGateway serves the registry of a core over HTTP as read-only JSON,
+// reading it at most once per cacheFor however many requests arrive
+type registryGateway struct {
+	client   pb.OrcaCoreClient
+	timeout  time.Duration
+	cacheFor time.Duration
+
+	mu        sync.Mutex
+	state     *pb.InternalState
+	fetchedAt time.Time
+}
+
+// registry returns the canonical registry, reading it from the core when the
+// cached copy is older than cacheFor
+func (g *registryGateway) registry(ctx context.Context) (*pb.InternalState, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.state != nil && time.Since(g.fetchedAt) < g.cacheFor {
+		return g.state, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	state, err := exposeCompressed(ctx, g.client, &pb.ExposeSettings{})
+	if err != nil {
+		return nil, fmt.Errorf("issue contacting Orca: %w", err)
+	}
+	g.state, g.fetchedAt = canonicalRegistry(state), time.Now()
+	return g.state, nil
+}
+
+// handler serves GET /processors, /processors/{name}, /algorithms, /windows and /health
+func (g *registryGateway) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /processors", g.withRegistry(func(w http.ResponseWriter, r *http.Request, state *pb.InternalState) {
+		processors := []processorResource{}
+		for _, processor := range state.GetProcessors() {
+			processors = append(processors, processorResourceOf(processor))
+		}
+		writeJSON(w, http.StatusOK, processors)
+	}))
+	mux.HandleFunc("GET /processors/{name}", g.withRegistry(func(w http.ResponseWriter, r *http.Request, state *pb.InternalState) {
+		processor := findRegisteredProcessor(state, r.PathValue("name"))
+		if processor == nil {
+			writeJSONError(w, http.StatusNotFound, fmt.Errorf("processor %q is not registered", r.PathValue("name")))
+			return
+		}
+		writeJSON(w, http.StatusOK, processorResourceOf(processor))
+	}))
+	mux.HandleFunc("GET /algorithms", g.withRegistry(func(w http.ResponseWriter, r *http.Request, state *pb.InternalState) {
+		writeJSON(w, http.StatusOK, algorithmResources(state, r.URL.Query().Get("processor")))
+	}))
+	mux.HandleFunc("GET /windows", g.withRegistry(func(w http.ResponseWriter, r *http.Request, state *pb.InternalState) {
+		writeJSON(w, http.StatusOK, windowTypeResources(state))
+	}))
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		state, err := g.registry(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "down", "error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "up", "processors": len(state.GetProcessors())})
+	})
+	return mux
+}
+
+// withRegistry reads the registry for a handler, answering 502 when the core cannot
+// be reached
+func (g *registryGateway) withRegistry(handle func(w http.ResponseWriter, r *http.Request, state *pb.InternalState)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, err := g.registry(r.Context())
+		if err != nil {
+			writeJSONError(w, http.StatusBadGateway, err)
+			return
+		}
+		handle(w, r, state)
+	}
+}
+
+func processorResourceOf(processor *pb.ProcessorRegistration) processorResource {
+	resource := processorResource{
+		Name:             processor.GetName(),
+		Project:          processor.GetProjectName(),
+		Runtime:          processor.GetRuntime(),
+		ConnectionString: processor.GetConnectionStr(),
+		Algorithms:       []string{},
+	}
+	for _, algorithm := range processor.GetSupportedAlgorithms() {
+		resource.Algorithms = append(resource.Algorithms, algorithm.GetName()+"@"+algorithm.GetVersion())
+	}
+	return resource
+}
+
+// algorithmResources lists the algorithms of the registry, only those of the named
+// processor when it is given
+func algorithmResources(state *pb.InternalState, processorName string) []algorithmResource {
+	algorithms := []algorithmResource{}
+	for _, processor := range state.GetProcessors() {
+		if processorName != "" && processor.GetName() != processorName {
+			continue
+		}
+		for _, algorithm := range processor.GetSupportedAlgorithms() {
+			resource := algorithmResource{
+				Processor:    processor.GetName(),
+				Name:         algorithm.GetName(),
+				Version:      algorithm.GetVersion(),
+				Description:  algorithm.GetDescription(),
+				WindowType:   algorithm.GetWindowType().GetName() + "@" + algorithm.GetWindowType().GetVersion(),
+				ResultType:   algorithm.GetResultType().String(),
+				Dependencies: []string{},
+			}
+			for _, dependency := range algorithm.GetDependencies() {
+				resource.Dependencies = append(resource.Dependencies, fmt.Sprintf("%s/%s@%s", dependency.GetProcessorName(), dependency.GetName(), dependency.GetVersion()))
+			}
+			algorithms = append(algorithms, resource)
+		}
+	}
+	return algorithms
+}
+
+// windowTypeResources lists the window types the algorithms of the registry are
+// triggered by, once each
+func windowTypeResources(state *pb.InternalState) []windowTypeResource {
+	windowTypes := []windowTypeResource{}
+	seen := map[string]bool{}
+	for _, processor := range state.GetProcessors() {
+		for _, algorithm := range processor.GetSupportedAlgorithms() {
+			windowType := algorithm.GetWindowType()
+			if windowType == nil {
+				continue
+			}
+			key := windowType.GetName() + "@" + windowType.GetVersion()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			resource := windowTypeResource{
+				Name:           windowType.GetName(),
+				Version:        windowType.GetVersion(),
+				Description:    windowType.GetDescription(),
+				MetadataFields: []metadataFieldResource{},
+			}
+			for _, field := range windowType.GetMetadataFields() {
+				resource.MetadataFields = append(resource.MetadataFields, metadataFieldResource{Name: field.GetName(), Description: field.GetDescription()})
+			}
+			windowTypes = append(windowTypes, resource)
+		}
+	}
+	slices.SortFunc(windowTypes, func(a, b windowTypeResource) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Version, b.Version))
+	})
+	return windowTypes
+}
+
+// serveRegistry serves the gateway on addr until interrupted
+func serveRegistry(addr string, gateway *registryGateway) error {
+	server := &http.Server{Addr: addr, Handler: gateway.handler(), ReadHeaderTimeout: 10 * time.Second}
+	served := make(chan error, 1)
+	go func() { served <- server.ListenAndServe() }()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	select {
+	case err := <-served:
+		return err
+	case <-sigs:
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
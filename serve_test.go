@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+func TestRegistryGateway(t *testing.T) {
+	hourly := &pb.WindowType{Name: "Hourly", Version: "1.0.0", MetadataFields: []*pb.MetadataField{{Name: "asset_id"}}}
+	core := &fakeCore{processors: []*pb.ProcessorRegistration{{
+		Name:    "stats",
+		Runtime: "python3.12",
+		SupportedAlgorithms: []*pb.Algorithm{
+			{Name: "Mean", Version: "1.0.0", WindowType: hourly, ResultType: pb.ResultType_VALUE},
+			{Name: "Spread", Version: "1.0.0", WindowType: hourly, Dependencies: []*pb.AlgorithmDependency{
+				{ProcessorName: "stats", Name: "Mean", Version: "1.0.0"},
+			}},
+		},
+	}}}
+	conn, client, err := dialCore(startFakeCore(t, core))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	server := httptest.NewServer((&registryGateway{client: client, timeout: 5 * time.Second}).handler())
+	defer server.Close()
+
+	get := func(path string, want int, value any) {
+		t.Helper()
+		response, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		if response.StatusCode != want {
+			t.Fatalf("GET %s answered %d, want %d", path, response.StatusCode, want)
+		}
+		if err := json.NewDecoder(response.Body).Decode(value); err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+	}
+
+	var processors []processorResource
+	get("/processors", http.StatusOK, &processors)
+	if len(processors) != 1 || processors[0].Name != "stats" || len(processors[0].Algorithms) != 2 {
+		t.Errorf("/processors = %+v", processors)
+	}
+	var missing map[string]string
+	get("/processors/routes", http.StatusNotFound, &missing)
+
+	var algorithms []algorithmResource
+	get("/algorithms?processor=stats", http.StatusOK, &algorithms)
+	if len(algorithms) != 2 || algorithms[1].Dependencies[0] != "stats/Mean@1.0.0" || algorithms[0].WindowType != "Hourly@1.0.0" {
+		t.Errorf("/algorithms = %+v", algorithms)
+	}
+	get("/algorithms?processor=routes", http.StatusOK, &algorithms)
+	if len(algorithms) != 0 {
+		t.Errorf("/algorithms of an unknown processor = %+v", algorithms)
+	}
+
+	var windows []windowTypeResource
+	get("/windows", http.StatusOK, &windows)
+	if len(windows) != 1 || windows[0].Name != "Hourly" || len(windows[0].MetadataFields) != 1 {
+		t.Errorf("/windows = %+v, want Hourly once", windows)
+	}
+
+	var health map[string]any
+	get("/health", http.StatusOK, &health)
+	if health["status"] != "up" {
+		t.Errorf("/health = %v", health)
+	}
+}
+
+func TestRegistryGatewayCoreDown(t *testing.T) {
+	// nothing listens on port 1
+	conn, client, err := dialCore("localhost:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	server := httptest.NewServer((&registryGateway{client: client, timeout: time.Second}).handler())
+	defer server.Close()
+
+	for path, want := range map[string]int{"/health": http.StatusServiceUnavailable, "/processors": http.StatusBadGateway} {
+		response, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != want {
+			t.Errorf("GET %s answered %d, want %d", path, response.StatusCode, want)
+		}
+	}
+}
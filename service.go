@@ -0,0 +1,387 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// stackService starts the stack of a project when the machine boots, or the user
+// logs in, and stops it on shutdown where the platform allows
+type stackService struct {
+	// Name is orca-<project>, naming the unit, agent or task
+	Name       string
+	Project    string
+	Executable string
+	// ConfigPath is the absolute path of the project's orca.json
+	ConfigPath string
+	// System installs a systemd unit for the whole machine rather than for the user
+	System bool
+	// User runs a system unit as this user, whose docker access and state it uses
+	User string
+	// Env is passed to the CLI, as the service manager's own environment lacks the
+	// PATH docker is found on
+	Env []string
+}
+
+// newStackService describes the service of the stack project
+func newStackService(configPath string, system bool) (*stackService, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate the orca executable: %w", err)
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return nil, fmt.Errorf("failed to locate the orca executable: %w", err)
+	}
+	if configPath, err = filepath.Abs(configPath); err != nil {
+		return nil, err
+	}
+	if system && runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("-system is only supported with systemd on linux")
+	}
+
+	service := &stackService{
+		Name:       "orca-" + projectLabelValue(stackProject),
+		Project:    stackProject,
+		Executable: executable,
+		ConfigPath: configPath,
+		System:     system,
+		Env:        []string{"ORCA_NO_UPDATE_CHECK=1", "PATH=" + os.Getenv("PATH")},
+	}
+	for _, name := range []string{"DOCKER_HOST", "DOCKER_CONTEXT", "DOCKER_CONFIG"} {
+		if value := os.Getenv(name); value != "" {
+			service.Env = append(service.Env, name+"="+value)
+		}
+	}
+	if system {
+		// a unit installed with sudo runs as the user who ran sudo
+		if name := os.Getenv("SUDO_USER"); name != "" {
+			service.User = name
+		} else if current, err := user.Current(); err == nil && current.Uid != "0" {
+			service.User = current.Username
+		}
+	}
+	return service, nil
+}
+
+// command returns the arguments of the CLI for a subcommand of the service's project
+func (s *stackService) command(args ...string) []string {
+	command := []string{s.Executable}
+	if s.Project != "" {
+		command = append(command, "--project", s.Project)
+	}
+	return append(command, args...)
+}
+
+func (s *stackService) startCommand() []string {
+	return s.command("start", "-config", s.ConfigPath)
+}
+
+func (s *stackService) stopCommand() []string {
+	return s.command("stop")
+}
+
+// definitionPath returns where the systemd unit or launchd agent is written. Tasks
+// of the Windows Task Scheduler have no file.
+func (s *stackService) definitionPath() (string, error) {
+	switch runtime.GOOS {
+	case "linux":
+		if s.System {
+			return filepath.Join("/etc/systemd/system", s.Name+".service"), nil
+		}
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "systemd", "user", s.Name+".service"), nil
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, "Library", "LaunchAgents", s.launchdLabel()+".plist"), nil
+	}
+	return "", nil
+}
+
+// definition returns the systemd unit, the launchd agent, or the schtasks command
+// creating the scheduled task
+func (s *stackService) definition() (string, error) {
+	switch runtime.GOOS {
+	case "linux":
+		return s.systemdUnit(), nil
+	case "darwin":
+		logPath, err := s.logPath()
+		if err != nil {
+			return "", err
+		}
+		return s.launchdPlist(logPath), nil
+	case "windows":
+		return shellJoin(s.scheduledTaskCreate()), nil
+	}
+	return "", fmt.Errorf("installing a service is not supported on %s", runtime.GOOS)
+}
+
+// systemdUnit starts the stack once docker is up and stops it on shutdown
+func (s *stackService) systemdUnit() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Written by `orca service install`, remove with `orca service uninstall`\n")
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=Orca stack (project %s)\n", projectLabelValue(s.Project))
+	if s.System {
+		// user units cannot order themselves after system units
+		fmt.Fprintf(&b, "Wants=docker.service network-online.target\n")
+		fmt.Fprintf(&b, "After=docker.service network-online.target\n")
+	}
+	fmt.Fprintf(&b, "\n[Service]\n")
+	fmt.Fprintf(&b, "Type=oneshot\n")
+	fmt.Fprintf(&b, "RemainAfterExit=yes\n")
+	if s.User != "" {
+		fmt.Fprintf(&b, "User=%s\n", s.User)
+	}
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", systemdQuote(filepath.Dir(s.ConfigPath)))
+	for _, variable := range s.Env {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(variable))
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", systemdCommand(s.startCommand()))
+	fmt.Fprintf(&b, "ExecStop=%s\n", systemdCommand(s.stopCommand()))
+	// starting may pull images
+	fmt.Fprintf(&b, "TimeoutStartSec=10min\n")
+	fmt.Fprintf(&b, "\n[Install]\n")
+	if s.System {
+		fmt.Fprintf(&b, "WantedBy=multi-user.target\n")
+	} else {
+		fmt.Fprintf(&b, "WantedBy=default.target\n")
+	}
+	return b.String()
+}
+
+// systemdCommand joins a command line for ExecStart and ExecStop
+func systemdCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for ii, arg := range args {
+		quoted[ii] = systemdQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// systemdQuote quotes a value of a unit file when needed, escaping the % of
+// specifiers whether quoted or not
+func systemdQuote(value string) string {
+	value = strings.ReplaceAll(value, "%", "%%")
+	if value != "" && !strings.ContainsAny(value, " \t\"'\\;$") {
+		return value
+	}
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$").Replace(value)
+	return `"` + value + `"`
+}
+
+func (s *stackService) launchdLabel() string {
+	return "com.orca-telemetry." + s.Name
+}
+
+// launchdPlist is an agent starting the stack when the user logs in. launchd has no
+// hook to stop it, which Docker Desktop does as it quits.
+func (s *stackService) launchdPlist(logPath string) string {
+	escape := func(value string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(value))
+		return b.String()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(&b, "<!DOCTYPE plist PUBLIC \"-//Apple//DTD PLIST 1.0//EN\" \"http://www.apple.com/DTDs/PropertyList-1.0.dtd\">\n")
+	fmt.Fprintf(&b, "<!-- Written by `orca service install`, remove with `orca service uninstall` -->\n")
+	fmt.Fprintf(&b, "<plist version=\"1.0\">\n<dict>\n")
+	fmt.Fprintf(&b, "  <key>Label</key>\n  <string>%s</string>\n", escape(s.launchdLabel()))
+	fmt.Fprintf(&b, "  <key>ProgramArguments</key>\n  <array>\n")
+	for _, arg := range s.startCommand() {
+		fmt.Fprintf(&b, "    <string>%s</string>\n", escape(arg))
+	}
+	fmt.Fprintf(&b, "  </array>\n")
+	fmt.Fprintf(&b, "  <key>WorkingDirectory</key>\n  <string>%s</string>\n", escape(filepath.Dir(s.ConfigPath)))
+	fmt.Fprintf(&b, "  <key>EnvironmentVariables</key>\n  <dict>\n")
+	for _, variable := range s.Env {
+		name, value, _ := strings.Cut(variable, "=")
+		fmt.Fprintf(&b, "    <key>%s</key>\n    <string>%s</string>\n", escape(name), escape(value))
+	}
+	fmt.Fprintf(&b, "  </dict>\n")
+	fmt.Fprintf(&b, "  <key>RunAtLoad</key>\n  <true/>\n")
+	fmt.Fprintf(&b, "  <key>StandardOutPath</key>\n  <string>%s</string>\n", escape(logPath))
+	fmt.Fprintf(&b, "  <key>StandardErrorPath</key>\n  <string>%s</string>\n", escape(logPath))
+	fmt.Fprintf(&b, "</dict>\n</plist>\n")
+	return b.String()
+}
+
+// logPath is where launchd writes the output of the agent
+func (s *stackService) logPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "service", s.Name+".log"), nil
+}
+
+// scheduledTaskCreate returns the schtasks command creating a task that starts the
+// stack when the user logs in, which is when Docker Desktop starts. Windows services
+// must answer the service control manager, which the CLI does not.
+func (s *stackService) scheduledTaskCreate() []string {
+	quoted := make([]string, 0, len(s.startCommand()))
+	for _, arg := range s.startCommand() {
+		if strings.ContainsAny(arg, " \t") {
+			arg = `"` + arg + `"`
+		}
+		quoted = append(quoted, arg)
+	}
+	return []string{"schtasks", "/Create", "/F", "/TN", s.scheduledTaskName(), "/SC", "ONLOGON", "/TR", strings.Join(quoted, " ")}
+}
+
+func (s *stackService) scheduledTaskName() string {
+	return `Orca\` + s.Name
+}
+
+// systemctl returns the systemctl command of the unit's scope
+func (s *stackService) systemctl(args ...string) *exec.Cmd {
+	if !s.System {
+		args = append([]string{"--user"}, args...)
+	}
+	return exec.Command("systemctl", args...)
+}
+
+// installService writes and enables the service, starting the stack now as well
+// when now is set
+func installService(s *stackService, now bool) error {
+	definition, err := s.definition()
+	if err != nil {
+		return err
+	}
+	path, err := s.definitionPath()
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(definition), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		if err := runServiceCommand(s.systemctl("daemon-reload")); err != nil {
+			return err
+		}
+		enable := []string{"enable", s.Name + ".service"}
+		if now {
+			enable = []string{"enable", "--now", s.Name + ".service"}
+		}
+		return runServiceCommand(s.systemctl(enable...))
+	case "darwin":
+		logPath, err := s.logPath()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(logPath), 0700); err != nil {
+			return err
+		}
+		// agents are loaded at the next login, loading one now runs it at once
+		if !now {
+			return nil
+		}
+		return runServiceCommand(exec.Command("launchctl", "load", "-w", path))
+	case "windows":
+		create := s.scheduledTaskCreate()
+		if err := runServiceCommand(exec.Command(create[0], create[1:]...)); err != nil {
+			return err
+		}
+		if now {
+			return runServiceCommand(exec.Command("schtasks", "/Run", "/TN", s.scheduledTaskName()))
+		}
+	}
+	return nil
+}
+
+// uninstallService disables and removes the service, leaving the stack as it is
+func uninstallService(s *stackService) error {
+	path, err := s.definitionPath()
+	if err != nil {
+		return err
+	}
+	switch runtime.GOOS {
+	case "linux":
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("no service is installed at %s", path)
+		}
+		if err := runServiceCommand(s.systemctl("disable", s.Name+".service")); err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		return runServiceCommand(s.systemctl("daemon-reload"))
+	case "darwin":
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("no service is installed at %s", path)
+		}
+		// an agent that was never loaded fails to unload, which does not matter here
+		exec.Command("launchctl", "unload", "-w", path).Run()
+		return os.Remove(path)
+	case "windows":
+		return runServiceCommand(exec.Command("schtasks", "/Delete", "/F", "/TN", s.scheduledTaskName()))
+	}
+	return fmt.Errorf("installing a service is not supported on %s", runtime.GOOS)
+}
+
+// showServiceStatus prints the state of the installed service as the service
+// manager reports it, returning the exit code of its report
+func showServiceStatus(s *stackService) (int, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		cmd = s.systemctl("status", "--no-pager", s.Name+".service")
+	case "darwin":
+		cmd = exec.Command("launchctl", "list", s.launchdLabel())
+	case "windows":
+		cmd = exec.Command("schtasks", "/Query", "/TN", s.scheduledTaskName(), "/V", "/FO", "LIST")
+	default:
+		return 1, fmt.Errorf("installing a service is not supported on %s", runtime.GOOS)
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return 1, err
+	}
+	return 0, nil
+}
+
+// startsOnLogin reports whether a user unit of systemd waits for the user to log in,
+// unless lingering is enabled for them
+func (s *stackService) startsOnLogin() bool {
+	return runtime.GOOS == "linux" && !s.System
+}
+
+// runServiceCommand runs a command of the service manager, folding its output into
+// any error
+func runServiceCommand(cmd *exec.Cmd) error {
+	logDebug("running %s", shellJoin(cmd.Args))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%s failed: %s", shellJoin(cmd.Args), msg)
+		}
+		return fmt.Errorf("%s failed: %w", shellJoin(cmd.Args), err)
+	}
+	return nil
+}
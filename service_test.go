@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSystemdQuote(t *testing.T) {
+	for value, want := range map[string]string{
+		"/usr/local/bin/orca":  "/usr/local/bin/orca",
+		"/home/sam/my project": `"/home/sam/my project"`,
+		"PATH=/usr/bin:/bin":   "PATH=/usr/bin:/bin",
+		`C:\orca "dev"`:        `"C:\\orca \"dev\""`,
+		"/srv/100%/orca.json":  "/srv/100%%/orca.json",
+		"/srv/$HOME/orca.json": `"/srv/$$HOME/orca.json"`,
+		"":                     `""`,
+	} {
+		if got := systemdQuote(value); got != want {
+			t.Errorf("systemdQuote(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestSystemdUnit(t *testing.T) {
+	service := &stackService{
+		Name:       "orca-demo",
+		Project:    "demo",
+		Executable: "/usr/local/bin/orca",
+		ConfigPath: "/home/sam/my project/orca.json",
+		System:     true,
+		User:       "sam",
+		Env:        []string{"PATH=/usr/bin:/bin"},
+	}
+	unit := service.systemdUnit()
+	for _, line := range []string{
+		"Wants=docker.service network-online.target",
+		"User=sam",
+		`WorkingDirectory="/home/sam/my project"`,
+		"Environment=PATH=/usr/bin:/bin",
+		`ExecStart=/usr/local/bin/orca --project demo start -config "/home/sam/my project/orca.json"`,
+		"ExecStop=/usr/local/bin/orca --project demo stop",
+		"WantedBy=multi-user.target",
+	} {
+		if !strings.Contains(unit, line+"\n") {
+			t.Errorf("the unit lacks %q:\n%s", line, unit)
+		}
+	}
+
+	service.System, service.User, service.Project = false, "", ""
+	unit = service.systemdUnit()
+	if strings.Contains(unit, "docker.service") || strings.Contains(unit, "User=") || strings.Contains(unit, "--project") || !strings.Contains(unit, "WantedBy=default.target\n") {
+		t.Errorf("unexpected user unit of the default project:\n%s", unit)
+	}
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	service := &stackService{
+		Name:       "orca-default",
+		Executable: "/usr/local/bin/orca",
+		ConfigPath: "/Users/sam/R&D/orca.json",
+		Env:        []string{"PATH=/usr/local/bin:/usr/bin"},
+	}
+	plist := service.launchdPlist("/Users/sam/.local/state/orca/service/orca-default.log")
+	for _, fragment := range []string{
+		"<string>com.orca-telemetry.orca-default</string>",
+		"<string>/usr/local/bin/orca</string>\n    <string>start</string>\n    <string>-config</string>\n    <string>/Users/sam/R&amp;D/orca.json</string>",
+		"<key>PATH</key>\n    <string>/usr/local/bin:/usr/bin</string>",
+		"<key>RunAtLoad</key>\n  <true/>",
+	} {
+		if !strings.Contains(plist, fragment) {
+			t.Errorf("the plist lacks %q:\n%s", fragment, plist)
+		}
+	}
+}
+
+func TestScheduledTaskCreate(t *testing.T) {
+	service := &stackService{
+		Name:       "orca-demo",
+		Project:    "demo",
+		Executable: `C:\Program Files\Orca\orca.exe`,
+		ConfigPath: `C:\src\demo\orca.json`,
+	}
+	got := service.scheduledTaskCreate()
+	want := []string{"schtasks", "/Create", "/F", "/TN", `Orca\orca-demo`, "/SC", "ONLOGON", "/TR", `"C:\Program Files\Orca\orca.exe" --project demo start -config C:\src\demo\orca.json`}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
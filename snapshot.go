@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshots are stored as docker volumes labelled with the snapshot name, one per
+// stack volume, so creating and restoring them never leaves the docker host
+const (
+	snapshotLabel        = "orca.snapshot"
+	snapshotSourceLabel  = "orca.snapshot.source"
+	snapshotCreatedLabel = "orca.snapshot.created"
+)
+
+var snapshotNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+type snapshotInfo struct {
+	Name    string   `json:"name"`
+	Created string   `json:"created"`
+	Volumes []string `json:"volumes"`
+}
+
+func validateSnapshotName(name string) error {
+	if !snapshotNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// snapshotVolumeName names the copy of a stack volume in a snapshot of the current project
+func snapshotVolumeName(snapshotName, volumeName string) string {
+	return namespaced("orca-snapshot-"+snapshotName) + "-" + volumeName
+}
+
+// copyVolume replaces the contents of dst with the contents of src using a throwaway helper container
+func copyVolume(src, dst string) error {
+	output, err := dockerCommand(
+		"run", "--rm",
+		"-v", src+":/from:ro",
+		"-v", dst+":/to",
+		helperImage,
+		"sh", "-c", "find /to -mindepth 1 -delete && cp -a /from/. /to/",
+	).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to copy volume %s to %s: %w: %s", src, dst, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// volumeExists reports whether a docker volume with exactly this name exists
+func volumeExists(volumeName string) bool {
+	return dockerCommand("volume", "inspect", volumeName).Run() == nil
+}
+
+// pauseStack stops any running stack containers so volumes can be copied consistently,
+// returning the containers that should be started again afterwards
+func pauseStack() []string {
+	var running []string
+	for _, containerName := range stackContainers() {
+		if getContainerStatus(containerName) == "running" {
+			running = append(running, containerName)
+		}
+	}
+
+	for _, containerName := range running {
+		fmt.Fprintf(os.Stderr, "Stopping %s... ", containerName)
+		if err := dockerCommand("stop", containerName).Run(); err != nil {
+			printError(fmt.Sprintf("ERROR: %v", err))
+		} else {
+			fmt.Fprintln(os.Stderr, renderSuccess("STOPPED"))
+		}
+	}
+	return running
+}
+
+// resumeStack starts the containers returned by pauseStack, core containers
+// first and in dependency order, waiting for Postgres before starting Orca
+func resumeStack(containers []string) {
+	var ordered []string
+	for _, containerName := range orcaContainers {
+		if slices.Contains(containers, containerName) {
+			ordered = append(ordered, containerName)
+		}
+	}
+	for _, containerName := range containers {
+		if !slices.Contains(ordered, containerName) {
+			ordered = append(ordered, containerName)
+		}
+	}
+
+	for _, containerName := range ordered {
+		fmt.Fprintf(os.Stderr, "Starting %s... ", containerName)
+		if _, err := runDockerRetry("start", containerName); err != nil {
+			printError(fmt.Sprintf("ERROR: %v", err))
+			continue
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess("STARTED"))
+
+		if containerName == pgContainerName {
+			if err := waitForStore(); err != nil {
+				fmt.Fprintln(os.Stderr, warningStyle.Render(err.Error()))
+			}
+		}
+	}
+}
+
+// createSnapshot copies every stack volume into a set of snapshot volumes
+func createSnapshot(name string) (err error) {
+	if err := validateSnapshotName(name); err != nil {
+		return err
+	}
+	if _, ok := findSnapshot(name); ok {
+		return fmt.Errorf("snapshot %q already exists", name)
+	}
+
+	for _, volumeName := range orcaVolumes {
+		if !volumeExists(volumeName) {
+			return fmt.Errorf("volume %s does not exist. Start the stack with `orca start` first", volumeName)
+		}
+	}
+
+	running := pauseStack()
+	defer resumeStack(running)
+
+	// a snapshot missing some volumes could not be restored, so a failure removes
+	// the volumes made so far
+	var made []string
+	defer func() {
+		if err != nil {
+			for _, volumeName := range made {
+				dockerCommand("volume", "rm", volumeName).Run()
+			}
+		}
+	}()
+
+	created := time.Now().UTC().Format(time.RFC3339)
+	for _, volumeName := range orcaVolumes {
+		target := snapshotVolumeName(name, volumeName)
+		fmt.Fprintf(os.Stderr, "Snapshotting %s... ", volumeName)
+
+		output, err := dockerCommand(
+			"volume", "create",
+			"--label", snapshotLabel+"="+name,
+			"--label", projectLabel+"="+projectLabelValue(stackProject),
+			"--label", snapshotSourceLabel+"="+volumeName,
+			"--label", snapshotCreatedLabel+"="+created,
+			target,
+		).CombinedOutput()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, renderError("FAILED"))
+			return fmt.Errorf("failed to create volume %s: %w: %s", target, err, strings.TrimSpace(string(output)))
+		}
+		made = append(made, target)
+
+		if err := copyVolume(volumeName, target); err != nil {
+			fmt.Fprintln(os.Stderr, renderError("FAILED"))
+			return err
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess("DONE"))
+	}
+	return nil
+}
+
+// restoreSnapshot replaces the contents of every stack volume with the snapshot's copy
+func restoreSnapshot(name string) error {
+	snapshot, ok := findSnapshot(name)
+	if !ok {
+		return fmt.Errorf("snapshot %q not found", name)
+	}
+
+	running := pauseStack()
+	defer resumeStack(running)
+
+	for _, volumeName := range orcaVolumes {
+		source := snapshotVolumeName(snapshot.Name, volumeName)
+		if !volumeExists(source) {
+			return fmt.Errorf("snapshot %q is missing volume %s", name, source)
+		}
+
+		if !volumeExists(volumeName) {
+			args := append([]string{"volume", "create"}, labelArgs(volumeComponent(volumeName))...)
+			if err := dockerCommand(append(args, volumeName)...).Run(); err != nil {
+				return fmt.Errorf("failed to create volume %s: %w", volumeName, err)
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "Restoring %s... ", volumeName)
+		if err := copyVolume(source, volumeName); err != nil {
+			fmt.Fprintln(os.Stderr, renderError("FAILED"))
+			return err
+		}
+		fmt.Fprintln(os.Stderr, renderSuccess("DONE"))
+	}
+	return nil
+}
+
+// deleteSnapshot removes all volumes belonging to a snapshot
+func deleteSnapshot(name string) error {
+	snapshot, ok := findSnapshot(name)
+	if !ok {
+		return fmt.Errorf("snapshot %q not found", name)
+	}
+
+	for _, volumeName := range snapshot.Volumes {
+		output, err := dockerCommand("volume", "rm", volumeName).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to remove volume %s: %w: %s", volumeName, err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// listSnapshots returns all snapshots, oldest first
+func listSnapshots() ([]snapshotInfo, error) {
+	output, err := dockerCommand(
+		"volume", "ls",
+		"--filter", "label="+snapshotLabel,
+		"--filter", "label="+projectLabel+"="+projectLabelValue(stackProject),
+		"--format", fmt.Sprintf(`{{.Name}} {{.Label "%s"}} {{.Label "%s"}}`, snapshotLabel, snapshotCreatedLabel),
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot volumes: %w", err)
+	}
+
+	byName := map[string]*snapshotInfo{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		snapshot, ok := byName[fields[1]]
+		if !ok {
+			snapshot = &snapshotInfo{Name: fields[1], Created: fields[2]}
+			byName[fields[1]] = snapshot
+		}
+		snapshot.Volumes = append(snapshot.Volumes, fields[0])
+	}
+
+	snapshots := make([]snapshotInfo, 0, len(byName))
+	for _, snapshot := range byName {
+		snapshots = append(snapshots, *snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Created < snapshots[j].Created
+	})
+	return snapshots, nil
+}
+
+func findSnapshot(name string) (snapshotInfo, bool) {
+	snapshots, err := listSnapshots()
+	if err != nil {
+		return snapshotInfo{}, false
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Name == name {
+			return snapshot, true
+		}
+	}
+	return snapshotInfo{}, false
+}
+
+func showSnapshots() error {
+	snapshots, err := listSnapshots()
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		fmt.Fprintln(os.Stderr, "No snapshots found. Create one with `orca snapshot create <name>`")
+		return nil
+	}
+
+	rows := [][]string{{"NAME", "CREATED", "VOLUMES"}}
+	for _, snapshot := range snapshots {
+		rows = append(rows, []string{snapshot.Name, snapshot.Created, fmt.Sprintf("%d", len(snapshot.Volumes))})
+	}
+	printTable(os.Stdout, rows)
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSnapshotsAreScopedToTheProject(t *testing.T) {
+	useFakeEngine(t)
+	t.Cleanup(func() { setStackProject("") })
+
+	for _, project := range []string{"", "pg"} {
+		if err := setStackProject(project); err != nil {
+			t.Fatal(err)
+		}
+		for _, volumeName := range orcaVolumes {
+			if err := dockerCommand(append(append([]string{"volume", "create"}, labelArgs(volumeComponent(volumeName))...), volumeName)...).Run(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := createSnapshot("before"); err != nil {
+			t.Fatalf("snapshot of project %q: %v", project, err)
+		}
+	}
+
+	for _, project := range []string{"", "pg"} {
+		setStackProject(project)
+		snapshots, err := listSnapshots()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(snapshots) != 1 || len(snapshots[0].Volumes) != len(orcaVolumes) {
+			t.Fatalf("project %q lists %+v, want one snapshot of its own %d volumes", project, snapshots, len(orcaVolumes))
+		}
+		for _, volumeName := range orcaVolumes {
+			if !slices.Contains(snapshots[0].Volumes, snapshotVolumeName("before", volumeName)) {
+				t.Errorf("project %q snapshot has volumes %v, want a copy of %s", project, snapshots[0].Volumes, volumeName)
+			}
+		}
+	}
+}
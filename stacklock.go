@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errStackLocked is returned by tryLockFile when another process holds the lock
+var errStackLocked = errors.New("stack is locked by another orca command")
+
+// stackLockFile is the open lock file while the stack is locked
+var stackLockFile *os.File
+
+// stackLockPath returns the lock file serialising commands that change the stack project
+func stackLockPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "locks", projectLabelValue(stackProject)+".lock"), nil
+}
+
+// lockStack takes the lock of the stack project for a command that changes it or the
+// data in its store, waiting for any other orca command holding it. The lock is
+// released when the process exits.
+func lockStack(command string) error {
+	path, err := stackLockPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	err = tryLockFile(file)
+	if errors.Is(err, errStackLocked) {
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf(
+			"Another orca command is running on this stack%s. Waiting for it to finish...",
+			describeLockHolder(path),
+		)))
+		err = lockFile(file)
+	}
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to lock the stack: %w", err)
+	}
+
+	// record the holder for anyone waiting, the file itself stays open until exit
+	file.Truncate(0)
+	file.WriteAt([]byte(fmt.Sprintf("%d\n%s\n%s\n", os.Getpid(), command, time.Now().Format(time.RFC3339))), 0)
+	stackLockFile = file
+	onExit(func(int) { unlockStack() })
+	return nil
+}
+
+// unlockStack releases the lock early, e.g. before running another orca command that
+// takes it. The holder is cleared, so a record left behind is from a command that was
+// killed (see `orca doctor`).
+func unlockStack() {
+	if stackLockFile != nil {
+		stackLockFile.Truncate(0)
+		stackLockFile.Close()
+		stackLockFile = nil
+	}
+}
+
+// describeLockHolder returns who holds the lock, as recorded in the lock file
+func describeLockHolder(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	fields := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(fields) < 3 {
+		return ""
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return ""
+	}
+	started, err := time.Parse(time.RFC3339, fields[2])
+	if err != nil {
+		return fmt.Sprintf(" (`orca %s`, pid %d)", fields[1], pid)
+	}
+	return fmt.Sprintf(" (`orca %s`, pid %d, started %s ago)", fields[1], pid, time.Since(started).Round(time.Second))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+)
+
+// psqlCommand builds a non-interactive psql invocation against the Orca store
+func psqlCommand(extraArgs ...string) *exec.Cmd {
+	args := []string{
+		"exec", "-i", pgContainerName,
+		"psql", "-U", "orca", "-d", "orca",
+		"-v", "ON_ERROR_STOP=1",
+		"--no-psqlrc",
+	}
+	args = append(args, extraArgs...)
+	return dockerCommand(args...)
+}
+
+// runPsql runs psql and returns its stdout, folding stderr into any error
+func runPsql(stdin io.Reader, extraArgs ...string) ([]byte, error) {
+	cmd := psqlCommand(extraArgs...)
+	cmd.Stdin = stdin
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// queryStore runs a query against the Orca store and returns the result rows,
+// with the column names as the first row
+func queryStore(query string) ([][]string, error) {
+	output, err := runPsql(nil, "--csv", "-c", query)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return nil, nil
+	}
+
+	reader := csv.NewReader(bytes.NewReader(output))
+	// statements such as DELETE report a status line instead of a result set
+	reader.FieldsPerRecord = -1
+	return reader.ReadAll()
+}
+
+// queryStoreJSON runs a SELECT query and returns its rows as a JSON array,
+// preserving the column types that CSV output would lose
+func queryStoreJSON(query string) ([]byte, error) {
+	wrapped := fmt.Sprintf(
+		"SELECT coalesce(json_agg(q), '[]'::json) FROM (%s) q",
+		strings.TrimRight(strings.TrimSpace(query), ";"),
+	)
+	output, err := runPsql(nil, "-A", "-t", "-c", wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(output), nil
+}
+
+// printQueryResult writes query rows in the requested format - table, csv or json
+func printQueryResult(w io.Writer, query, format string) error {
+	switch format {
+	case "json":
+		output, err := queryStoreJSON(query)
+		if err != nil {
+			return err
+		}
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, output, "", "    "); err != nil {
+			return fmt.Errorf("failed to format JSON result: %w", err)
+		}
+		fmt.Fprintln(w, pretty.String())
+		return nil
+
+	case "csv":
+		rows, err := queryStore(query)
+		if err != nil {
+			return err
+		}
+		writer := csv.NewWriter(w)
+		if err := writer.WriteAll(rows); err != nil {
+			return err
+		}
+		return nil
+
+	case "table":
+		rows, err := queryStore(query)
+		if err != nil {
+			return err
+		}
+		printTable(w, rows)
+		if len(rows) > 1 {
+			fmt.Fprintf(w, "(%d rows)\n", len(rows)-1)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("invalid output format %q, must be one of: table, csv, json", format)
+	}
+}
+
+// printTable writes rows as aligned columns, treating the first row as the header
+func printTable(w io.Writer, rows [][]string) {
+	if len(rows) == 0 {
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for ii, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+		if ii == 0 && len(rows) > 1 {
+			separators := make([]string, len(row))
+			for jj, column := range row {
+				separators[jj] = strings.Repeat("-", max(len(column), 3))
+			}
+			fmt.Fprintln(tw, strings.Join(separators, "\t"))
+		}
+	}
+	tw.Flush()
+}
+
+// readQueryArg resolves a query given on the command line, reading it from
+// stdin when the argument is "-"
+func readQueryArg(arg string) (string, error) {
+	if arg != "-" {
+		return arg, nil
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read query from stdin: %w", err)
+	}
+	return string(data), nil
+}
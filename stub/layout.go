@@ -0,0 +1,212 @@
+package stub
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+// Layout is how the python stubs are laid out in the output directory
+type Layout string
+
+const (
+	// LayoutFlat generates a registry package holding every algorithm in one module
+	LayoutFlat Layout = "flat"
+	// LayoutPackage generates an orca_stubs package with a module per processor,
+	// which keeps the modules of large registries manageable
+	LayoutPackage Layout = "package"
+)
+
+var Layouts = []Layout{LayoutFlat, LayoutPackage}
+
+// Paths of the generated python stubs, relative to the output directory
+var (
+	pythonInitPath           = filepath.Join("registry", "__init__.py")
+	pythonAlgorithmsPath     = filepath.Join("registry", "algorithms.py")
+	pythonWindowTypesPath    = filepath.Join("registry", "window_types.py")
+	pythonMetadataFieldsPath = filepath.Join("registry", "metadata_fields.py")
+
+	pythonPackageDir           = "orca_stubs"
+	pythonPackageProcessorsDir = filepath.Join(pythonPackageDir, "processors")
+)
+
+// pythonModule is a module of algorithms in the package layout
+type pythonModule struct {
+	Name  string
+	Stubs *AllProcessors
+}
+
+// pythonModuleName turns a processor name into a valid python module name
+func pythonModuleName(name string) string {
+	var result strings.Builder
+	for _, r := range toSnakeCase(name) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			result.WriteRune(unicode.ToLower(r))
+		} else {
+			result.WriteRune('_')
+		}
+	}
+	module := result.String()
+	if module == "" || unicode.IsDigit(rune(module[0])) {
+		module = "_" + module
+	}
+	return module
+}
+
+// pythonModules groups the processors into a module each. Processors whose names
+// map to the same module share it.
+func pythonModules(tmplData *AllProcessors) []pythonModule {
+	byName := map[string]*AllProcessors{}
+	var names []string
+	for _, processor := range tmplData.Processors {
+		name := pythonModuleName(processor.Name)
+		stubs, ok := byName[name]
+		if !ok {
+			stubs = &AllProcessors{Module: pythonPackageDir + ".processors." + name}
+			byName[name] = stubs
+			names = append(names, name)
+		}
+		stubs.Processors = append(stubs.Processors, processor)
+	}
+	sort.Strings(names)
+
+	modules := make([]pythonModule, 0, len(names))
+	for _, name := range names {
+		stubs := byName[name]
+		used := map[ReturnType]bool{}
+		for _, processor := range stubs.Processors {
+			for _, algorithm := range processor.Algorithms {
+				used[algorithm.ReturnType] = true
+			}
+		}
+		for _, returnType := range tmplData.ImportTypes {
+			if used[ReturnType(returnType)] {
+				stubs.ImportTypes = append(stubs.ImportTypes, returnType)
+			}
+		}
+		modules = append(modules, pythonModule{Name: name, Stubs: stubs})
+	}
+	return modules
+}
+
+// renderJob renders one stub file from a template
+type renderJob struct {
+	path string
+	tmpl *template.Template
+	data any
+}
+
+// renderPythonStubs renders the python stubs in a layout, keyed by their path
+// relative to the output directory. onRender, when set, is told how long each file
+// took to render, and may be called concurrently.
+func renderPythonStubs(tmplData *AllProcessors, layout Layout, onRender func(path string, elapsed time.Duration)) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	var jobs []renderJob
+
+	switch layout {
+	case LayoutFlat, "":
+		files[pythonInitPath] = nil
+		flat := *tmplData
+		flat.Module = "registry.algorithms"
+		jobs = []renderJob{
+			{pythonAlgorithmsPath, pythonAlgoTemplate, &flat},
+			{pythonWindowTypesPath, pythonWindowTypeTemplate, &flat},
+			{pythonMetadataFieldsPath, pythonMetadataTemplate, &flat},
+		}
+
+	case LayoutPackage:
+		modules := pythonModules(tmplData)
+		files[filepath.Join(pythonPackageProcessorsDir, "__init__.py")] = nil
+		jobs = []renderJob{
+			{filepath.Join(pythonPackageDir, "__init__.py"), pythonPackageInitTemplate, modules},
+			{filepath.Join(pythonPackageDir, "window_types.py"), pythonWindowTypeTemplate, tmplData},
+			{filepath.Join(pythonPackageDir, "metadata_fields.py"), pythonMetadataTemplate, tmplData},
+		}
+		for _, module := range modules {
+			jobs = append(jobs, renderJob{filepath.Join(pythonPackageProcessorsDir, module.Name+".py"), pythonAlgoTemplate, module.Stubs})
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown layout %q", layout)
+	}
+
+	rendered, err := renderConcurrently(jobs, onRender)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(files, rendered)
+	return files, nil
+}
+
+// renderConcurrently renders the jobs on a worker per CPU, each into its own
+// buffer, so that registries of hundreds of processors render in the time of a
+// few. Every job runs, and the errors of all that failed are returned, ordered by path.
+func renderConcurrently(jobs []renderJob, onRender func(path string, elapsed time.Duration)) (map[string][]byte, error) {
+	files := make(map[string][]byte, len(jobs))
+	var errs []error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	queue := make(chan renderJob)
+	for range min(runtime.GOMAXPROCS(0), len(jobs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				var buf bytes.Buffer
+				started := time.Now()
+				err := job.tmpl.Execute(&buf, job.data)
+				if err == nil && onRender != nil {
+					onRender(job.path, time.Since(started))
+				}
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("could not render %s: %w", job.path, err))
+				} else {
+					files[job.path] = buf.Bytes()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+		return nil, errors.Join(errs...)
+	}
+	return files, nil
+}
+
+// algorithmPath returns the path of the file holding the algorithms of a processor
+// in a layout
+func algorithmPath(layout Layout, processorName string) string {
+	if layout == LayoutPackage {
+		return filepath.Join(pythonPackageProcessorsDir, pythonModuleName(processorName)+".py")
+	}
+	return pythonAlgorithmsPath
+}
+
+// algorithmFiles returns the paths that hold algorithms in a layout, as a glob
+// relative to the output directory
+func algorithmFiles(layout Layout) string {
+	if layout == LayoutPackage {
+		return filepath.Join(pythonPackageProcessorsDir, "*.py")
+	}
+	return pythonAlgorithmsPath
+}
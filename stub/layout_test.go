@@ -0,0 +1,150 @@
+package stub
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"text/template"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+func TestPythonModuleName(t *testing.T) {
+	for name, want := range map[string]string{
+		"ml":           "ml",
+		"ml-test":      "ml_test",
+		"SpeedChecks":  "speed_checks",
+		"3d.processor": "_3d_processor",
+	} {
+		if got := pythonModuleName(name); got != want {
+			t.Errorf("pythonModuleName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGeneratePythonStubsPackageLayout(t *testing.T) {
+	window := &pb.WindowType{Name: "FastWindow", Version: "1.0.0"}
+	state := &pb.InternalState{Processors: []*pb.ProcessorRegistration{
+		{Name: "ml-test", Runtime: "python3.12", SupportedAlgorithms: []*pb.Algorithm{
+			{Name: "SpeedCheck", Version: "1.0.0", WindowType: window, ResultType: pb.ResultType_VALUE},
+		}},
+		{Name: "routes", Runtime: "python3.12", SupportedAlgorithms: []*pb.Algorithm{
+			{Name: "RouteCheck", Version: "1.0.0", WindowType: window, ResultType: pb.ResultType_STRUCT},
+		}},
+	}}
+	dir := t.TempDir()
+	if _, err := GeneratePythonStubs(state, dir, GenerateOptions{}); err != nil {
+		t.Fatalf("GeneratePythonStubs: %v", err)
+	}
+	flatPath := filepath.Join(dir, pythonAlgorithmsPath)
+	content, err := os.ReadFile(flatPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content = []byte(strings.Replace(string(content),
+		"    # orca:begin-user-code ml-test/SpeedCheck@1.0.0\n",
+		"    # orca:begin-user-code ml-test/SpeedCheck@1.0.0\n    return 1.0\n", 1))
+	if err := os.WriteFile(flatPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// switching layouts moves the user code and removes the flat stubs
+	if _, err := GeneratePythonStubs(state, dir, GenerateOptions{Layout: LayoutPackage}); err != nil {
+		t.Fatalf("GeneratePythonStubs in the package layout: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "registry")); !os.IsNotExist(err) {
+		t.Errorf("the flat stubs were left behind: %v", err)
+	}
+	module, err := os.ReadFile(filepath.Join(dir, pythonPackageProcessorsDir, "ml_test.py"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(module), "    return 1.0\n") || strings.Contains(string(module), "RouteCheck") {
+		t.Errorf("ml_test.py = %s", module)
+	}
+	if strings.Contains(string(module), "StructResult") {
+		t.Errorf("ml_test.py imports result types it does not use")
+	}
+	init, err := os.ReadFile(filepath.Join(dir, pythonPackageDir, "__init__.py"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"from .processors.ml_test import (", "from .processors.routes import (", `"route_check_`} {
+		if !strings.Contains(string(init), want) {
+			t.Errorf("__init__.py is missing %q:\n%s", want, init)
+		}
+	}
+
+	// later syncs and verification keep to the layout generated
+	if _, err := GeneratePythonStubs(state, dir, GenerateOptions{}); err != nil {
+		t.Fatalf("GeneratePythonStubs: %v", err)
+	}
+	if layout, err := ManifestLayout(dir); err != nil || layout != LayoutPackage {
+		t.Errorf("ManifestLayout = %q, %v", layout, err)
+	}
+	mismatches, err := VerifyPythonStubs(state, dir)
+	if err != nil || len(mismatches) != 0 {
+		t.Errorf("VerifyPythonStubs = %v, %v", mismatches, err)
+	}
+}
+
+func TestRenderPythonStubsManyProcessors(t *testing.T) {
+	window := &pb.WindowType{Name: "FastWindow", Version: "1.0.0"}
+	state := &pb.InternalState{}
+	for ii := range 200 {
+		state.Processors = append(state.Processors, &pb.ProcessorRegistration{
+			Name: fmt.Sprintf("processor-%03d", ii), Runtime: "python3.12",
+			SupportedAlgorithms: []*pb.Algorithm{
+				{Name: "Check", Version: "1.0.0", WindowType: window, ResultType: pb.ResultType_VALUE},
+			},
+		})
+	}
+	err, tmplData := mapInternalStateToTmpl(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rendered atomic.Int64
+	first, err := renderPythonStubs(tmplData, LayoutPackage, func(string, time.Duration) { rendered.Add(1) })
+	if err != nil {
+		t.Fatalf("renderPythonStubs: %v", err)
+	}
+	if rendered.Load() != 203 {
+		t.Errorf("onRender was called for %d files, want 200 processors and 3 shared files", rendered.Load())
+	}
+	second, err := renderPythonStubs(tmplData, LayoutPackage, nil)
+	if err != nil {
+		t.Fatalf("renderPythonStubs: %v", err)
+	}
+	if !maps.EqualFunc(first, second, bytes.Equal) {
+		t.Error("rendering concurrently is not deterministic")
+	}
+	if _, ok := first[filepath.Join(pythonPackageProcessorsDir, "processor_199.py")]; !ok {
+		t.Error("the stubs of the last processor are missing")
+	}
+}
+
+func TestRenderConcurrentlyJoinsErrors(t *testing.T) {
+	failing := template.Must(template.New("failing").Parse("{{.Missing}}"))
+	_, err := renderConcurrently([]renderJob{
+		{"b.py", failing, 1},
+		{"ok.py", failing, map[string]string{}},
+		{"a.py", failing, 2},
+	}, nil)
+	if err == nil {
+		t.Fatal("renderConcurrently returned no error")
+	}
+	message := err.Error()
+	if !strings.Contains(message, "a.py") || !strings.Contains(message, "b.py") || strings.Contains(message, "ok.py") {
+		t.Errorf("error = %q, want the failures of a.py and b.py only", message)
+	}
+	if strings.Index(message, "a.py") > strings.Index(message, "b.py") {
+		t.Errorf("errors are not ordered by path: %q", message)
+	}
+}
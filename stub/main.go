@@ -1,14 +1,19 @@
 package stub
 
 import (
+	"cmp"
 	"embed"
 	"fmt"
 	"hash/crc32"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	pb "github.com/orca-telemetry/core/protobufs/go"
 )
@@ -17,15 +22,17 @@ const (
 	PYTHON_METADATA_FIELDS_TMPL = "stub_templates/window_metadata_fields.py.tmpl"
 	PYTHON_WINDOW_TYPES_TMPL    = "stub_templates/window_types.py.tmpl"
 	PYTHON_ALGORITHMS_TMPL      = "stub_templates/algorithms.py.tmpl"
+	PYTHON_PACKAGE_INIT_TMPL    = "stub_templates/package_init.py.tmpl"
 )
 
 //go:embed stub_templates/*.tmpl
 var templateFS embed.FS
 
 var (
-	pythonAlgoTemplate       *template.Template
-	pythonMetadataTemplate   *template.Template
-	pythonWindowTypeTemplate *template.Template
+	pythonAlgoTemplate        *template.Template
+	pythonMetadataTemplate    *template.Template
+	pythonWindowTypeTemplate  *template.Template
+	pythonPackageInitTemplate *template.Template
 )
 
 type ReturnType string
@@ -52,6 +59,7 @@ func init() {
 	pythonAlgoTemplate = generateTemplate(PYTHON_ALGORITHMS_TMPL)
 	pythonMetadataTemplate = generateTemplate(PYTHON_METADATA_FIELDS_TMPL)
 	pythonWindowTypeTemplate = generateTemplate(PYTHON_WINDOW_TYPES_TMPL)
+	pythonPackageInitTemplate = generateTemplate(PYTHON_PACKAGE_INIT_TMPL)
 }
 
 func wrapText(limit int, text string) string {
@@ -156,6 +164,8 @@ type ProcessorData struct {
 }
 
 type AllProcessors struct {
+	// Module is the python module the algorithms are generated in
+	Module      string
 	Processors  []ProcessorData
 	ImportTypes []string
 	AllMetadata []Metadata
@@ -254,17 +264,19 @@ func mapInternalStateToTmpl(internalState *pb.InternalState) (error, *AllProcess
 		}
 	}
 
-	// Convert Global Metadata Map to Slice
+	// Convert Global Metadata Map to Slice, sorted so that stubs generate the same each time
 	allMetadata := make([]Metadata, 0, len(globalMetadataMap))
 	for _, m := range globalMetadataMap {
 		allMetadata = append(allMetadata, m)
 	}
+	sort.Slice(allMetadata, func(i, j int) bool { return allMetadata[i].VarName < allMetadata[j].VarName })
 
 	// Convert Global Windows Map to Slice
 	allWindows := make([]Window, 0, len(globalWindowsMap))
 	for _, w := range globalWindowsMap {
 		allWindows = append(allWindows, w)
 	}
+	sort.Slice(allWindows, func(i, j int) bool { return allWindows[i].VarName < allWindows[j].VarName })
 
 	// Finalize Import List
 	importList := []string{}
@@ -283,53 +295,120 @@ func mapInternalStateToTmpl(internalState *pb.InternalState) (error, *AllProcess
 	}
 }
 
-func GeneratePythonStubs(internalState *pb.InternalState, outDir string) error {
+// GenerateOptions configure GeneratePythonStubs
+type GenerateOptions struct {
+	// Layout defaults to that of the stubs already in the output directory
+	Layout Layout
+	// Force overwrites stubs edited outside their user code regions
+	Force bool
+	// OnRender, when set, is called with the time each file took to render
+	OnRender func(path string, elapsed time.Duration)
+}
+
+// GeneratePythonStubs writes the python stubs into outDir. User code regions of
+// existing stubs are carried over, including between layouts, and any whose
+// algorithm is gone are saved beside their file, returning the paths of those
+// saved. Stubs of an earlier generation no longer produced are removed. Stubs
+// edited outside their user code regions since they were generated are only
+// overwritten with Force, otherwise a *ModifiedFilesError is returned and nothing
+// is written.
+func GeneratePythonStubs(internalState *pb.InternalState, outDir string, options GenerateOptions) ([]string, error) {
 
 	err, tmplData := mapInternalStateToTmpl(internalState)
 	if err != nil {
-		return fmt.Errorf("could not parse internal state: %w", err)
+		return nil, fmt.Errorf("could not parse internal state: %w", err)
 	}
 
-	err = os.Mkdir(outDir, 0750)
-	err = os.MkdirAll(filepath.Join(outDir, "registry"), 0750)
-
-	if err != nil && !os.IsExist(err) {
-		return (err)
+	generated, err := readManifest(outDir)
+	if err != nil {
+		return nil, err
 	}
-
-	initFile, err := os.Create(filepath.Join(outDir, "registry", "__init__.py"))
-
-	if err != nil && !os.IsExist(err) {
-		return err
+	layout := cmp.Or(options.Layout, generated.Layout, LayoutFlat)
+	files, err := renderPythonStubs(tmplData, layout, options.OnRender)
+	if err != nil {
+		return nil, err
 	}
-	initFile.Close()
 
-	algorithmsFile, err := os.Create(filepath.Join(outDir, "registry", "algorithms.py"))
-	if err != nil && !os.IsExist(err) {
-		return err
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	stale := generated.staleFiles(paths)
+	if !options.Force {
+		modified, err := generated.modifiedFiles(outDir, slices.Concat(paths, stale))
+		if err != nil {
+			return nil, err
+		}
+		if len(modified) > 0 {
+			return nil, &ModifiedFilesError{Files: modified}
+		}
 	}
-	defer algorithmsFile.Close()
 
-	windowTypesFile, err := os.Create(filepath.Join(outDir, "registry", "window_types.py"))
-	if err != nil && !os.IsExist(err) {
-		return err
+	// user code is gathered from every file first, so that it follows its
+	// algorithm into another file
+	userCode := map[string][]byte{}
+	userCodeFile := map[string]string{}
+	for _, path := range slices.Concat(paths, stale) {
+		existing, err := os.ReadFile(filepath.Join(outDir, path))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		regions, err := parseUserCodeRegions(existing)
+		if err != nil {
+			return nil, fmt.Errorf("could not keep the user code of %s: %w", filepath.Join(outDir, path), err)
+		}
+		for _, region := range regions {
+			userCode[region.ID] = region.Content
+			userCodeFile[region.ID] = path
+		}
 	}
-	defer windowTypesFile.Close()
 
-	metadataFieldsFile, err := os.Create(filepath.Join(outDir, "registry", "metadata_fields.py"))
-	if err != nil && !os.IsExist(err) {
-		return err
+	used := map[string]bool{}
+	for _, path := range paths {
+		fullPath := filepath.Join(outDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0750); err != nil {
+			return nil, err
+		}
+		content, filled := fillUserCode(files[path], userCode)
+		maps.Copy(used, filled)
+		if err := os.WriteFile(fullPath, content, 0644); err != nil {
+			return nil, err
+		}
+		generated.Files[filepath.ToSlash(path)] = generatedHash(content)
 	}
-	defer metadataFieldsFile.Close()
 
-	if err := pythonAlgoTemplate.Execute(algorithmsFile, tmplData); err != nil {
-		panic(err)
+	orphanedByFile := map[string][]userCodeRegion{}
+	for id, content := range userCode {
+		if !used[id] && !isDefaultUserCode(content) {
+			orphanedByFile[userCodeFile[id]] = append(orphanedByFile[userCodeFile[id]], userCodeRegion{ID: id, Content: content})
+		}
 	}
-	if err := pythonWindowTypeTemplate.Execute(windowTypesFile, tmplData); err != nil {
-		panic(err)
+	var orphanedPaths []string
+	for path, orphaned := range orphanedByFile {
+		orphanedPath, err := writeOrphanedUserCode(filepath.Join(outDir, path), orphaned)
+		if err != nil {
+			return nil, err
+		}
+		orphanedPaths = append(orphanedPaths, orphanedPath)
 	}
-	if err := pythonMetadataTemplate.Execute(metadataFieldsFile, tmplData); err != nil {
-		panic(err)
+	sort.Strings(orphanedPaths)
+
+	for _, path := range stale {
+		if err := os.Remove(filepath.Join(outDir, path)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		delete(generated.Files, filepath.ToSlash(path))
+		// the directory goes too once empty, e.g. after switching layouts
+		os.Remove(filepath.Dir(filepath.Join(outDir, path)))
+	}
+
+	generated.Layout = layout
+	if err := writeManifest(outDir, generated); err != nil {
+		return nil, fmt.Errorf("could not write the manifest of generated files: %w", err)
 	}
-	return nil
+	return orphanedPaths, nil
 }
@@ -0,0 +1,145 @@
+package stub
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// manifestPath is where the hashes of the generated files are kept, relative to
+// the output directory
+var manifestPath = filepath.Join(".orca", "manifest.json")
+
+const manifestVersion = 1
+
+// manifest records the hash of each generated file as last written, keyed by its
+// path relative to the output directory
+type manifest struct {
+	Version int `json:"version"`
+	// Layout the files were generated in
+	Layout Layout            `json:"layout,omitempty"`
+	Files  map[string]string `json:"files"`
+}
+
+// ModifiedFilesError is returned when generating would overwrite generated files
+// edited since they were written
+type ModifiedFilesError struct {
+	// Files are relative to the output directory
+	Files []string
+}
+
+func (e *ModifiedFilesError) Error() string {
+	return fmt.Sprintf("generated files were modified since the last sync: %s", strings.Join(e.Files, ", "))
+}
+
+// generatedHash hashes a generated file, leaving out the contents of its user code
+// regions, which are meant to be edited
+func generatedHash(content []byte) string {
+	hash := sha256.New()
+	inRegion := false
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		marker := strings.TrimSpace(string(line))
+		switch {
+		case strings.HasPrefix(marker, userCodeBegin):
+			inRegion = true
+			hash.Write(line)
+		case strings.HasPrefix(marker, userCodeEnd):
+			inRegion = false
+			hash.Write(line)
+		case !inRegion:
+			hash.Write(line)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// readManifest reads the manifest of an output directory, which is empty when
+// nothing was generated there yet
+func readManifest(outDir string) (*manifest, error) {
+	data, err := os.ReadFile(filepath.Join(outDir, manifestPath))
+	if os.IsNotExist(err) {
+		return &manifest{Version: manifestVersion, Files: map[string]string{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", filepath.Join(outDir, manifestPath), err)
+	}
+	if m.Files == nil {
+		m.Files = map[string]string{}
+	}
+	return &m, nil
+}
+
+func writeManifest(outDir string, m *manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(outDir, manifestPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// modifiedFiles returns the files, of those given, whose content no longer hashes
+// to what the manifest recorded. Files the manifest does not know are not checked.
+func (m *manifest) modifiedFiles(outDir string, paths []string) ([]string, error) {
+	var modified []string
+	for _, path := range paths {
+		recorded, ok := m.Files[filepath.ToSlash(path)]
+		if !ok {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(outDir, path))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if generatedHash(content) != recorded {
+			modified = append(modified, path)
+		}
+	}
+	return modified, nil
+}
+
+// staleFiles returns the files the manifest recorded that are not among those given,
+// which are left over from an earlier generation
+func (m *manifest) staleFiles(paths []string) []string {
+	current := map[string]bool{}
+	for _, path := range paths {
+		current[filepath.ToSlash(path)] = true
+	}
+	var stale []string
+	for path := range m.Files {
+		if !current[path] {
+			stale = append(stale, filepath.FromSlash(path))
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// ManifestLayout returns the layout the stubs in outDir were generated in, flat
+// when nothing was generated there yet
+func ManifestLayout(outDir string) (Layout, error) {
+	m, err := readManifest(outDir)
+	if err != nil {
+		return "", err
+	}
+	if m.Layout == "" {
+		return LayoutFlat, nil
+	}
+	return m.Layout, nil
+}
@@ -0,0 +1,66 @@
+package stub
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+func TestGeneratePythonStubsRefusesModifiedFiles(t *testing.T) {
+	state := &pb.InternalState{Processors: []*pb.ProcessorRegistration{{
+		Name:    "ml",
+		Runtime: "python3.12",
+		SupportedAlgorithms: []*pb.Algorithm{
+			{Name: "SpeedCheck", Version: "1.0.0", WindowType: &pb.WindowType{Name: "FastWindow", Version: "1.0.0"}, ResultType: pb.ResultType_VALUE},
+		},
+	}}}
+	dir := t.TempDir()
+	if _, err := GeneratePythonStubs(state, dir, GenerateOptions{}); err != nil {
+		t.Fatalf("GeneratePythonStubs: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, manifestPath)); err != nil {
+		t.Fatalf("manifest not written: %v", err)
+	}
+
+	// user code regions may be edited freely
+	path := filepath.Join(dir, pythonAlgorithmsPath)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content = []byte(strings.Replace(string(content), "# orca:begin-user-code imports registry.algorithms\n", "# orca:begin-user-code imports registry.algorithms\nimport math\n", 1))
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GeneratePythonStubs(state, dir, GenerateOptions{}); err != nil {
+		t.Fatalf("GeneratePythonStubs after editing user code: %v", err)
+	}
+
+	// editing anything else protects the file until forced
+	edited := append(content, []byte("\nprint('edited')\n")...)
+	if err := os.WriteFile(path, edited, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var modified *ModifiedFilesError
+	if _, err := GeneratePythonStubs(state, dir, GenerateOptions{}); !errors.As(err, &modified) || len(modified.Files) != 1 || modified.Files[0] != pythonAlgorithmsPath {
+		t.Fatalf("GeneratePythonStubs after an edit = %v, want a ModifiedFilesError for %s", err, pythonAlgorithmsPath)
+	}
+	if kept, _ := os.ReadFile(path); string(kept) != string(edited) {
+		t.Errorf("the edited file was overwritten without force")
+	}
+
+	if _, err := GeneratePythonStubs(state, dir, GenerateOptions{Force: true}); err != nil {
+		t.Fatalf("GeneratePythonStubs with force: %v", err)
+	}
+	regenerated, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(regenerated), "print('edited')") || !strings.Contains(string(regenerated), "import math\n") {
+		t.Errorf("forced regeneration = %q, want the edit gone and the user code kept", regenerated)
+	}
+}
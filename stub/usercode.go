@@ -0,0 +1,136 @@
+package stub
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Markers of the regions of generated files that hold user code, which survives
+// regeneration. Each marker is followed by the id of its region.
+const (
+	userCodeBegin = "# orca:begin-user-code"
+	userCodeEnd   = "# orca:end-user-code"
+)
+
+// defaultUserCode matches the content regions are generated with, which is not
+// worth keeping once its region is gone
+var defaultUserCode = regexp.MustCompile(`^\s*(_ = params\s*raise NotImplementedError\(\s*"\w+ is a remote algorithm and cannot be executed locally."\s*\)\s*)?$`)
+
+// userCodeRegion is the content between the markers of a region
+type userCodeRegion struct {
+	ID      string
+	Content []byte
+}
+
+// parseUserCodeRegions returns the regions of a file in order. Regions cannot nest,
+// and each must be closed by an end marker with the same id.
+func parseUserCodeRegions(content []byte) ([]userCodeRegion, error) {
+	var regions []userCodeRegion
+	var current *userCodeRegion
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	for ii, line := range lines {
+		marker := strings.TrimSpace(string(line))
+		switch {
+		case strings.HasPrefix(marker, userCodeBegin):
+			if current != nil {
+				return nil, fmt.Errorf("line %d: user code region %q starts inside region %q", ii+1, regionID(marker, userCodeBegin), current.ID)
+			}
+			current = &userCodeRegion{ID: regionID(marker, userCodeBegin), Content: []byte{}}
+		case strings.HasPrefix(marker, userCodeEnd):
+			id := regionID(marker, userCodeEnd)
+			if current == nil || current.ID != id {
+				return nil, fmt.Errorf("line %d: user code region %q ends without starting", ii+1, id)
+			}
+			regions = append(regions, *current)
+			current = nil
+		case current != nil:
+			current.Content = append(current.Content, line...)
+		}
+	}
+	if current != nil {
+		return nil, fmt.Errorf("user code region %q is never ended", current.ID)
+	}
+	return regions, nil
+}
+
+func isDefaultUserCode(content []byte) bool {
+	return defaultUserCode.Match(content)
+}
+
+func regionID(marker, prefix string) string {
+	return strings.TrimSpace(strings.TrimPrefix(marker, prefix))
+}
+
+// mergeUserCode carries the user code regions of an existing file over into its
+// regenerated content. Regions of the existing file missing from the generated
+// one, e.g. for algorithms no longer registered, are returned as orphaned.
+func mergeUserCode(existing, generated []byte) ([]byte, []userCodeRegion, error) {
+	kept, err := parseUserCodeRegions(existing)
+	if err != nil {
+		return nil, nil, err
+	}
+	keptByID := make(map[string][]byte, len(kept))
+	for _, region := range kept {
+		keptByID[region.ID] = region.Content
+	}
+	merged, used := fillUserCode(generated, keptByID)
+
+	var orphaned []userCodeRegion
+	for _, region := range kept {
+		if !used[region.ID] {
+			orphaned = append(orphaned, region)
+		}
+	}
+	return merged, orphaned, nil
+}
+
+// fillUserCode replaces the content of the regions of generated content with the
+// user code of the same id, returning the ids used
+func fillUserCode(generated []byte, userCode map[string][]byte) ([]byte, map[string]bool) {
+	used := map[string]bool{}
+	if len(userCode) == 0 {
+		return generated, used
+	}
+	var filled bytes.Buffer
+	skipping := false
+	for _, line := range bytes.SplitAfter(generated, []byte("\n")) {
+		marker := strings.TrimSpace(string(line))
+		switch {
+		case strings.HasPrefix(marker, userCodeBegin):
+			filled.Write(line)
+			id := regionID(marker, userCodeBegin)
+			if content, ok := userCode[id]; ok {
+				filled.Write(content)
+				used[id] = true
+				skipping = true
+			}
+		case strings.HasPrefix(marker, userCodeEnd):
+			filled.Write(line)
+			skipping = false
+		case !skipping:
+			filled.Write(line)
+		}
+	}
+	return filled.Bytes(), used
+}
+
+// writeOrphanedUserCode saves orphaned regions of a file beside it as path.orphaned,
+// so that no user code is lost when its region is no longer generated
+func writeOrphanedUserCode(path string, orphaned []userCodeRegion) (string, error) {
+	sort.SliceStable(orphaned, func(i, j int) bool { return orphaned[i].ID < orphaned[j].ID })
+	var out bytes.Buffer
+	for _, region := range orphaned {
+		fmt.Fprintf(&out, "%s %s\n", userCodeBegin, region.ID)
+		out.Write(region.Content)
+		fmt.Fprintf(&out, "%s %s\n", userCodeEnd, region.ID)
+	}
+	orphanedPath := path + ".orphaned"
+	if err := os.WriteFile(orphanedPath, out.Bytes(), 0644); err != nil {
+		return "", err
+	}
+	return orphanedPath, nil
+}
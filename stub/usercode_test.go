@@ -0,0 +1,91 @@
+package stub
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGeneratePythonStubsKeepsUserCode(t *testing.T) {
+	state := &pb.InternalState{Processors: []*pb.ProcessorRegistration{{
+		Name:          "ml",
+		Runtime:       "python3.12",
+		ConnectionStr: "host.docker.internal:5377",
+		SupportedAlgorithms: []*pb.Algorithm{
+			{Name: "SpeedCheck", Version: "1.0.0", WindowType: &pb.WindowType{Name: "FastWindow", Version: "1.0.0"}, ResultType: pb.ResultType_VALUE},
+			{Name: "RouteCheck", Version: "1.0.0", WindowType: &pb.WindowType{Name: "FastWindow", Version: "1.0.0"}, ResultType: pb.ResultType_VALUE},
+		},
+	}}}
+	dir := t.TempDir()
+	if _, err := GeneratePythonStubs(state, dir, GenerateOptions{}); err != nil {
+		t.Fatalf("GeneratePythonStubs: %v", err)
+	}
+
+	path := filepath.Join(dir, pythonAlgorithmsPath)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(content),
+		"# orca:begin-user-code imports registry.algorithms\n",
+		"# orca:begin-user-code imports registry.algorithms\nimport math\n", 1)
+	edited = strings.Replace(edited,
+		"    # orca:begin-user-code ml/SpeedCheck@1.0.0\n",
+		"    # orca:begin-user-code ml/SpeedCheck@1.0.0\n    return math.pi\n", 1)
+	edited = strings.Replace(edited,
+		"    # orca:begin-user-code ml/RouteCheck@1.0.0\n",
+		"    # orca:begin-user-code ml/RouteCheck@1.0.0\n    return 42.0\n", 1)
+	if err := os.WriteFile(path, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// edits inside the regions are not mismatches
+	mismatches, err := VerifyPythonStubs(state, dir)
+	if err != nil || len(mismatches) != 0 {
+		t.Fatalf("VerifyPythonStubs with user code = %v, %v", mismatches, err)
+	}
+
+	// regenerating without RouteCheck keeps the rest and saves its code aside
+	dropped := proto.Clone(state).(*pb.InternalState)
+	dropped.Processors[0].SupportedAlgorithms = dropped.Processors[0].SupportedAlgorithms[:1]
+	orphaned, err := GeneratePythonStubs(dropped, dir, GenerateOptions{})
+	if err != nil {
+		t.Fatalf("GeneratePythonStubs: %v", err)
+	}
+	regenerated, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kept := range []string{"import math\n", "    return math.pi\n"} {
+		if !strings.Contains(string(regenerated), kept) {
+			t.Errorf("regenerated stubs lost %q", kept)
+		}
+	}
+	if strings.Contains(string(regenerated), "return 42.0") {
+		t.Errorf("regenerated stubs kept the code of a dropped algorithm")
+	}
+	if len(orphaned) != 1 || orphaned[0] != path+".orphaned" {
+		t.Fatalf("orphaned = %v", orphaned)
+	}
+	saved, err := os.ReadFile(orphaned[0])
+	if err != nil || !strings.Contains(string(saved), "    return 42.0\n") {
+		t.Errorf("orphaned code = %q, %v", saved, err)
+	}
+}
+
+func TestParseUserCodeRegionsUnbalanced(t *testing.T) {
+	for _, content := range []string{
+		"# orca:begin-user-code a\n",
+		"# orca:end-user-code a\n",
+		"# orca:begin-user-code a\n# orca:begin-user-code b\n# orca:end-user-code b\n# orca:end-user-code a\n",
+		"# orca:begin-user-code a\n# orca:end-user-code b\n",
+	} {
+		if _, err := parseUserCodeRegions([]byte(content)); err == nil {
+			t.Errorf("parseUserCodeRegions(%q) succeeded", content)
+		}
+	}
+}
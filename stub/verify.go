@@ -0,0 +1,185 @@
+package stub
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+var (
+	// pythonAlgorithmMetadata matches the metadata attached to each generated algorithm
+	pythonAlgorithmMetadata = regexp.MustCompile(`(?m)^(\w+)\.__orca_metadata__ = \{  # type: ignore\n((?:    .*\n)*?)\}`)
+	pythonMetadataEntry     = regexp.MustCompile(`"(\w+)": "([^"]*)"`)
+	// pythonVarNameHash matches the hash suffix of an algorithm's variable name, for
+	// stubs generated before the hash was part of the metadata
+	pythonVarNameHash = regexp.MustCompile(`_([0-9a-f]+)$`)
+)
+
+// StubMismatch is a difference between the generated stubs and the registry
+type StubMismatch struct {
+	// File is relative to the output directory
+	File string `json:"file"`
+	// Algorithm is set for mismatches of a single algorithm, as processor/name@version
+	Algorithm string `json:"algorithm,omitempty"`
+	Problem   string `json:"problem"`
+}
+
+// generatedAlgorithm is an algorithm found in a generated algorithms file
+type generatedAlgorithm struct {
+	VarName string
+	Key     string
+	Hash    string
+}
+
+// parsePythonAlgorithms extracts the algorithms and their hashes from a generated
+// algorithms file
+func parsePythonAlgorithms(content []byte) []generatedAlgorithm {
+	var algorithms []generatedAlgorithm
+	for _, match := range pythonAlgorithmMetadata.FindAllSubmatch(content, -1) {
+		metadata := map[string]string{}
+		for _, entry := range pythonMetadataEntry.FindAllSubmatch(match[2], -1) {
+			metadata[string(entry[1])] = string(entry[2])
+		}
+		algorithm := generatedAlgorithm{
+			VarName: string(match[1]),
+			Key:     algorithmKey(metadata["ProcessorName"], metadata["Name"], metadata["Version"]),
+			Hash:    metadata["Hash"],
+		}
+		if algorithm.Hash == "" {
+			if suffix := pythonVarNameHash.FindStringSubmatch(algorithm.VarName); suffix != nil {
+				algorithm.Hash = suffix[1]
+			}
+		}
+		algorithms = append(algorithms, algorithm)
+	}
+	return algorithms
+}
+
+func algorithmKey(processor, name, version string) string {
+	return fmt.Sprintf("%s/%s@%s", processor, name, version)
+}
+
+// VerifyPythonStubs checks the python stubs in outDir against the registry. The
+// hash of every generated algorithm must match the registry, and every file must
+// be as it would be generated now, catching both edits to generated code and
+// registry changes since the last sync. Edits inside user code regions are allowed.
+func VerifyPythonStubs(internalState *pb.InternalState, outDir string) ([]StubMismatch, error) {
+	err, tmplData := mapInternalStateToTmpl(internalState)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse internal state: %w", err)
+	}
+	layout, err := ManifestLayout(outDir)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := renderPythonStubs(tmplData, layout, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := filepath.Glob(filepath.Join(outDir, algorithmFiles(layout)))
+	if err != nil {
+		return nil, err
+	}
+	algorithmFiles := map[string][]byte{}
+	for _, match := range matches {
+		content, err := os.ReadFile(match)
+		if err != nil {
+			return nil, err
+		}
+		path, err := filepath.Rel(outDir, match)
+		if err != nil {
+			return nil, err
+		}
+		algorithmFiles[path] = content
+	}
+	mismatches := verifyAlgorithmHashes(tmplData, layout, algorithmFiles)
+
+	paths := make([]string, 0, len(expected))
+	for path := range expected {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		content, err := os.ReadFile(filepath.Join(outDir, path))
+		switch {
+		case os.IsNotExist(err):
+			mismatches = append(mismatches, StubMismatch{File: path, Problem: "missing, run `orca sync` to generate it"})
+		case err != nil:
+			return nil, err
+		case hasFileMismatch(mismatches, path):
+		default:
+			want, orphaned, err := mergeUserCode(content, expected[path])
+			if err != nil {
+				mismatches = append(mismatches, StubMismatch{File: path, Problem: err.Error()})
+			} else if len(orphaned) > 0 || !bytes.Equal(content, want) {
+				mismatches = append(mismatches, StubMismatch{File: path, Problem: "differs from the registry, it was edited or generated from another registry"})
+			}
+		}
+	}
+	return mismatches, nil
+}
+
+// verifyAlgorithmHashes compares the hashes in generated algorithm files, keyed by
+// their path, with those of the registry
+func verifyAlgorithmHashes(tmplData *AllProcessors, layout Layout, files map[string][]byte) []StubMismatch {
+	registered := map[string]string{}
+	registeredPath := map[string]string{}
+	for _, processor := range tmplData.Processors {
+		for _, algorithm := range processor.Algorithms {
+			key := algorithmKey(algorithm.ProcessorName, algorithm.Name, algorithm.Version)
+			registered[key] = algorithm.Hash
+			registeredPath[key] = algorithmPath(layout, algorithm.ProcessorName)
+		}
+	}
+
+	var mismatches []StubMismatch
+	generated := map[string]bool{}
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		for _, algorithm := range parsePythonAlgorithms(files[path]) {
+			generated[algorithm.Key] = true
+			hash, ok := registered[algorithm.Key]
+			switch {
+			case !ok:
+				mismatches = append(mismatches, StubMismatch{File: path, Algorithm: algorithm.Key, Problem: "no longer in the registry"})
+			case algorithm.Hash != hash:
+				mismatches = append(mismatches, StubMismatch{
+					File:      path,
+					Algorithm: algorithm.Key,
+					Problem:   fmt.Sprintf("hash %s does not match the registry's %s", algorithm.Hash, hash),
+				})
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(registered))
+	for key := range registered {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !generated[key] {
+			mismatches = append(mismatches, StubMismatch{File: registeredPath[key], Algorithm: key, Problem: "registered but not generated"})
+		}
+	}
+	return mismatches
+}
+
+func hasFileMismatch(mismatches []StubMismatch, path string) bool {
+	for _, mismatch := range mismatches {
+		if mismatch.File == path {
+			return true
+		}
+	}
+	return false
+}
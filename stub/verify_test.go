@@ -0,0 +1,60 @@
+package stub
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestVerifyPythonStubs(t *testing.T) {
+	state := &pb.InternalState{Processors: []*pb.ProcessorRegistration{{
+		Name:          "ml",
+		Runtime:       "python3.12",
+		ConnectionStr: "host.docker.internal:5377",
+		SupportedAlgorithms: []*pb.Algorithm{{
+			Name:    "SpeedCheck",
+			Version: "1.0.0",
+			WindowType: &pb.WindowType{
+				Name:           "FastWindow",
+				Version:        "1.0.0",
+				MetadataFields: []*pb.MetadataField{{Name: "bus_id"}, {Name: "route"}},
+			},
+			ResultType: pb.ResultType_VALUE,
+		}},
+	}}}
+	dir := t.TempDir()
+	if _, err := GeneratePythonStubs(state, dir, GenerateOptions{}); err != nil {
+		t.Fatalf("GeneratePythonStubs: %v", err)
+	}
+
+	mismatches, err := VerifyPythonStubs(state, dir)
+	if err != nil || len(mismatches) != 0 {
+		t.Fatalf("VerifyPythonStubs on fresh stubs = %v, %v", mismatches, err)
+	}
+
+	// the registry moving underneath the stubs changes the algorithm's hash
+	moved := proto.Clone(state).(*pb.InternalState)
+	moved.Processors[0].ConnectionStr = "host.docker.internal:6000"
+	mismatches, err = VerifyPythonStubs(moved, dir)
+	if err != nil || len(mismatches) != 1 || mismatches[0].Algorithm != "ml/SpeedCheck@1.0.0" || !strings.Contains(mismatches[0].Problem, "hash") {
+		t.Errorf("VerifyPythonStubs after the registry moved = %v, %v", mismatches, err)
+	}
+
+	// editing generated code is caught even though the hashes still match
+	path := filepath.Join(dir, pythonWindowTypesPath)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, append(content, []byte("\nprint('edited')\n")...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mismatches, err = VerifyPythonStubs(state, dir)
+	if err != nil || len(mismatches) != 1 || mismatches[0].File != pythonWindowTypesPath {
+		t.Errorf("VerifyPythonStubs after an edit = %v, %v", mismatches, err)
+	}
+}
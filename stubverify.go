@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/orca-telemetry/cli/stub"
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+// fetchStubRegistry fetches the registry the stubs are generated from, leaving out
+// the project named in the config file as sync does
+func fetchStubRegistry(coreAddress, configPath string, timeout time.Duration) (*pb.InternalState, error) {
+	settings := &pb.ExposeSettings{}
+	if _, err := os.Stat(configPath); err == nil {
+		config, err := readProjectConfig(configPath)
+		if err != nil {
+			return nil, err
+		}
+		settings.ExcludeProject = config.ProjectName
+	} else if configPath != defaultConfigPath {
+		return nil, fmt.Errorf("config file not found: %s", configPath)
+	}
+
+	address := coreAddress
+	if address == "" {
+		checkDockerInstalled()
+		var err error
+		if address, err = localCoreAddress(); err != nil {
+			return nil, err
+		}
+	}
+	conn, client, err := dialCore(address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	state, err := exposeCompressed(ctx, client, settings)
+	if err != nil {
+		return nil, fmt.Errorf("issue contacting Orca: %w", err)
+	}
+	return state, nil
+}
+
+// showStubMismatches prints the mismatches found by `orca stub verify`
+func showStubMismatches(w io.Writer, mismatches []stub.StubMismatch) {
+	if len(mismatches) == 0 {
+		fmt.Fprintln(w, renderStdout(successStyle, "Generated stubs match the registry."))
+		return
+	}
+	rows := [][]string{{"FILE", "ALGORITHM", "PROBLEM"}}
+	for _, mismatch := range mismatches {
+		algorithm := mismatch.Algorithm
+		if algorithm == "" {
+			algorithm = "-"
+		}
+		rows = append(rows, []string{mismatch.File, algorithm, renderStdout(errorStyle, mismatch.Problem)})
+	}
+	printTable(w, rows)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run `orca sync` to regenerate the stubs from the registry.")
+}
@@ -19,13 +19,25 @@ var (
 			Foreground(lipgloss.Color("#e0af68")).
 			Bold(true)
 
+	// Faint text for low-priority notices
+	dimStyle = lipgloss.NewStyle().
+			Faint(true)
+
 	// Muted red for errors
 	errorStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#f7768e")).
 			Bold(true)
 )
 
+// colorDisabled is set by --no-color and NO_COLOR, turning off styling on every stream
+var colorDisabled bool
+
+// stdoutRenderer styles results written to stdout. Everything else, progress, notes
+// and errors, goes to stderr through the default renderer.
+var stdoutRenderer = lipgloss.NewRenderer(os.Stdout)
+
 func init() {
+	lipgloss.DefaultRenderer().SetOutput(termenv.NewOutput(os.Stderr))
 	// Check for color support and set appropriate profile
 	setupColorProfile()
 }
@@ -34,6 +46,15 @@ func init() {
 func setupColorProfile() {
 	// Check for explicit no-color requests
 	if os.Getenv("NO_COLOR") != "" {
+		disableColor()
+		return
+	}
+
+	// Piped or redirected output stays free of escape codes
+	if !isTerminal(os.Stdout) {
+		stdoutRenderer.SetColorProfile(termenv.Ascii)
+	}
+	if !isTerminal(os.Stderr) {
 		lipgloss.SetColorProfile(termenv.Ascii)
 		return
 	}
@@ -64,6 +85,13 @@ func setupColorProfile() {
 	// It will choose the best profile based on terminal capabilities
 }
 
+// disableColor turns off all styling, as requested by --no-color
+func disableColor() {
+	colorDisabled = true
+	lipgloss.SetColorProfile(termenv.Ascii)
+	stdoutRenderer.SetColorProfile(termenv.Ascii)
+}
+
 // safeRender safely renders text with styling, falling back to plain text on error
 func safeRender(style lipgloss.Style, text string) string {
 	defer func() {
@@ -97,3 +125,13 @@ func renderSuccess(text string) string {
 func renderError(text string) string {
 	return safeRender(errorStyle, text)
 }
+
+// renderStdout styles a result written to stdout, which may be piped while stderr is a terminal
+func renderStdout(style lipgloss.Style, text string) string {
+	return safeRender(style.Renderer(stdoutRenderer), text)
+}
+
+// printError writes an error message to stderr, styled only when stderr is a terminal
+func printError(text string) {
+	fmt.Fprintln(os.Stderr, renderError(text))
+}
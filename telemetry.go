@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// number of spooled events that triggers an export attempt
+const telemetryFlushThreshold = 20
+
+// telemetrySpoolLimit caps the spool while there is no endpoint or exports fail,
+// the oldest events being dropped beyond it
+const telemetrySpoolLimit = 1000
+
+// telemetryEvent is a single anonymous usage record. It deliberately holds no
+// arguments, paths, or output - only what command ran, for how long, and how it ended.
+type telemetryEvent struct {
+	Command    string    `json:"command"`
+	DurationMs int64     `json:"durationMs"`
+	ExitCode   int       `json:"exitCode"`
+	ErrorClass string    `json:"errorClass"`
+	Version    string    `json:"version"`
+	OS         string    `json:"os"`
+	Arch       string    `json:"arch"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// telemetryCommand is the command recorded for this invocation. Unknown
+// subcommands are recorded as "unknown" so that typos are never captured.
+var telemetryCommand string
+
+func telemetrySpoolPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "telemetry", "spool.jsonl"), nil
+}
+
+// telemetryDisabledByEnv honours the conventional opt-out environment variables,
+// which take precedence over the global config
+func telemetryDisabledByEnv() bool {
+	if os.Getenv("DO_NOT_TRACK") != "" {
+		return true
+	}
+	value := strings.ToLower(os.Getenv("ORCA_TELEMETRY"))
+	return value == "0" || value == "false" || value == "off"
+}
+
+func telemetryEndpoint(config *GlobalConfig) string {
+	if config.Telemetry.Endpoint != "" {
+		return config.Telemetry.Endpoint
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+}
+
+// initTelemetry records the current command when it exits, if the user has opted in
+func initTelemetry(command string) {
+	config, err := loadGlobalConfig()
+	if err != nil || !config.Telemetry.Enabled || telemetryDisabledByEnv() {
+		return
+	}
+
+	telemetryCommand = command
+	started := time.Now()
+
+	onExit(func(code int) {
+		errorClass := "none"
+		if code != 0 {
+			errorClass = "exit_" + strconv.Itoa(code)
+		}
+
+		event := telemetryEvent{
+			Command:    telemetryCommand,
+			DurationMs: time.Since(started).Milliseconds(),
+			ExitCode:   code,
+			ErrorClass: errorClass,
+			Version:    Version,
+			OS:         runtime.GOOS,
+			Arch:       runtime.GOARCH,
+			Timestamp:  time.Now().UTC(),
+		}
+
+		// telemetry must never interfere with the command itself, so errors are dropped
+		count, err := spoolTelemetryEvent(event)
+		if err != nil {
+			return
+		}
+		if endpoint := telemetryEndpoint(config); endpoint != "" && count >= telemetryFlushThreshold {
+			flushTelemetry(endpoint)
+		}
+	})
+}
+
+// spoolTelemetryEvent appends an event to the local spool and returns the number of
+// spooled events, dropping the oldest beyond telemetrySpoolLimit
+func spoolTelemetryEvent(event telemetryEvent) (int, error) {
+	path, err := telemetrySpoolPath()
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	_, err = f.Write(append(data, '\n'))
+	f.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	events, err := readTelemetrySpool()
+	if err != nil || len(events) <= telemetrySpoolLimit {
+		return len(events), err
+	}
+	events = events[len(events)-telemetrySpoolLimit:]
+	return len(events), writeTelemetrySpool(path, events)
+}
+
+// writeTelemetrySpool replaces the spool with the given events
+func writeTelemetrySpool(path string, events []telemetryEvent) error {
+	var buffer bytes.Buffer
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		buffer.Write(append(data, '\n'))
+	}
+	// written aside and renamed, so that an interrupted write does not lose the spool
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, buffer.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+// readTelemetrySpool returns all events currently held in the local spool
+func readTelemetrySpool() ([]telemetryEvent, error) {
+	path, err := telemetrySpoolPath()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []telemetryEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event telemetryEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// skip corrupted lines rather than discarding the whole spool
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func otlpString(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: map[string]any{"stringValue": value}}
+}
+
+func otlpInt(key string, value int64) otlpAttribute {
+	// OTLP/JSON encodes 64 bit integers as strings
+	return otlpAttribute{Key: key, Value: map[string]any{"intValue": strconv.FormatInt(value, 10)}}
+}
+
+// buildOTLPLogsPayload converts spooled events into an OTLP/HTTP JSON logs request
+func buildOTLPLogsPayload(events []telemetryEvent) ([]byte, error) {
+	records := make([]map[string]any, len(events))
+	for ii, event := range events {
+		records[ii] = map[string]any{
+			"timeUnixNano": strconv.FormatInt(event.Timestamp.UnixNano(), 10),
+			"body":         map[string]any{"stringValue": "orca.command"},
+			"attributes": []otlpAttribute{
+				otlpString("orca.command", event.Command),
+				otlpInt("orca.duration_ms", event.DurationMs),
+				otlpInt("orca.exit_code", int64(event.ExitCode)),
+				otlpString("orca.error_class", event.ErrorClass),
+				otlpString("orca.cli_version", event.Version),
+				otlpString("os.type", event.OS),
+				otlpString("host.arch", event.Arch),
+			},
+		}
+	}
+
+	payload := map[string]any{
+		"resourceLogs": []any{
+			map[string]any{
+				"resource": map[string]any{
+					"attributes": []otlpAttribute{otlpString("service.name", "orca-cli")},
+				},
+				"scopeLogs": []any{
+					map[string]any{
+						"scope":      map[string]any{"name": "orca-cli"},
+						"logRecords": records,
+					},
+				},
+			},
+		},
+	}
+	return json.Marshal(payload)
+}
+
+// flushTelemetry exports the spool to the OTLP endpoint, clearing it on success
+func flushTelemetry(endpoint string) error {
+	events, err := readTelemetrySpool()
+	if err != nil || len(events) == 0 {
+		return err
+	}
+
+	payload, err := buildOTLPLogsPayload(events)
+	if err != nil {
+		return err
+	}
+
+	client := http.Client{Timeout: time.Second * 2}
+	resp, err := client.Post(
+		strings.TrimSuffix(endpoint, "/")+"/v1/logs",
+		"application/json",
+		bytes.NewReader(payload),
+	)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry export failed with status %s", resp.Status)
+	}
+
+	path, err := telemetrySpoolPath()
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// showTelemetryStatus prints whether telemetry is enabled and what is currently spooled
+func showTelemetryStatus(config *GlobalConfig) {
+	switch {
+	case telemetryDisabledByEnv():
+		fmt.Fprintln(os.Stderr, "Telemetry:", warningStyle.Render("disabled by environment (DO_NOT_TRACK / ORCA_TELEMETRY)"))
+	case config.Telemetry.Enabled:
+		fmt.Fprintln(os.Stderr, "Telemetry:", successStyle.Render("enabled"))
+	default:
+		fmt.Fprintln(os.Stderr, "Telemetry:", warningStyle.Render("disabled"))
+	}
+
+	if endpoint := telemetryEndpoint(config); endpoint != "" {
+		fmt.Fprintln(os.Stderr, "Export endpoint: "+endpoint)
+	} else {
+		fmt.Fprintln(os.Stderr, "Export endpoint: none (events are kept in the local spool)")
+	}
+
+	if path, err := telemetrySpoolPath(); err == nil {
+		events, _ := readTelemetrySpool()
+		fmt.Fprintf(os.Stderr, "Spool: %s (%d event(s))\n", path, len(events))
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Only command names, durations, exit codes and error classes are collected.")
+	fmt.Fprintln(os.Stderr, "No arguments, file contents, or telemetry payloads are ever recorded.")
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestSpoolTelemetryEventCaps(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	for ii := range telemetrySpoolLimit + 5 {
+		count, err := spoolTelemetryEvent(telemetryEvent{Command: "status", ExitCode: ii})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := min(ii+1, telemetrySpoolLimit); count != want {
+			t.Fatalf("spool holds %d event(s) after %d, want %d", count, ii+1, want)
+		}
+	}
+	events, err := readTelemetrySpool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != telemetrySpoolLimit || events[0].ExitCode != 5 {
+		t.Errorf("spool kept %d event(s) from exit code %d, want the newest %d", len(events), events[0].ExitCode, telemetrySpoolLimit)
+	}
+}
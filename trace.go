@@ -0,0 +1,413 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// storeTimestampLayout is how timestamp columns come out of queryStoreJSON. The core
+// writes the bounds of windows in UTC.
+const storeTimestampLayout = "2006-01-02T15:04:05.999999"
+
+// storeClockColumn selects a timestamp column the store fills from its own clock,
+// such as windows.created, as an instant. The clock follows the timezone setting of
+// the store, so the column holds local time, which is read in that zone.
+func storeClockColumn(column string) string {
+	return column + " AT TIME ZONE current_setting('TimeZone')"
+}
+
+// traceEvent is one step of a window through the pipeline. Time is unknown for
+// results, which the store does not timestamp.
+type traceEvent struct {
+	Time      *time.Time `json:"time,omitempty"`
+	Stage     string     `json:"stage"`
+	Processor string     `json:"processor,omitempty"`
+	Algorithm string     `json:"algorithm,omitempty"`
+	Detail    string     `json:"detail,omitempty"`
+}
+
+// windowTrace follows a window from the core receiving it to the results its
+// algorithms stored
+type windowTrace struct {
+	WindowID          int64           `json:"windowId"`
+	WindowType        string          `json:"windowType"`
+	WindowTypeVersion string          `json:"windowTypeVersion"`
+	TimeFrom          time.Time       `json:"timeFrom"`
+	TimeTo            time.Time       `json:"timeTo"`
+	Origin            string          `json:"origin"`
+	Metadata          json.RawMessage `json:"metadata,omitempty"`
+	Received          time.Time       `json:"received"`
+	// Status is complete, partial, failed or idle when no algorithm takes the window type
+	Status   string       `json:"status"`
+	Expected int          `json:"expected"`
+	Stored   int          `json:"stored"`
+	Events   []traceEvent `json:"events"`
+}
+
+// traceWindowRow is the stored window, as read by queryTraceWindow
+type traceWindowRow struct {
+	ID                int64           `json:"id"`
+	WindowType        string          `json:"window_type"`
+	WindowTypeVersion string          `json:"window_type_version"`
+	TimeFrom          string          `json:"time_from"`
+	TimeTo            string          `json:"time_to"`
+	Origin            string          `json:"origin"`
+	Metadata          json.RawMessage `json:"metadata"`
+	Created           string          `json:"created"`
+}
+
+// traceAlgorithmRow is an algorithm the core dispatches the window to, with the
+// result it stored for the window, if any
+type traceAlgorithmRow struct {
+	Algorithm   string          `json:"algorithm"`
+	Version     string          `json:"version"`
+	Processor   string          `json:"processor"`
+	ResultID    *int64          `json:"result_id"`
+	ResultValue *float64        `json:"result_value"`
+	ResultArray []float64       `json:"result_array"`
+	ResultJSON  json.RawMessage `json:"result_json"`
+}
+
+// coreLogLine is a warning or error logged by the core
+type coreLogLine struct {
+	Time    time.Time
+	Level   string
+	Message string
+	Attrs   string
+}
+
+// coreLogPattern matches the core's slog text output
+var coreLogPattern = regexp.MustCompile(`^time=(\S+) level=(\S+) msg=("(?:[^"\\]|\\.)*"|\S+)\s*(.*)$`)
+
+// parseCoreLogLine parses a line of the core's log, reporting false for lines that
+// are not warnings or errors
+func parseCoreLogLine(line string) (coreLogLine, bool) {
+	match := coreLogPattern.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil || (match[2] != "WARN" && match[2] != "ERROR") {
+		return coreLogLine{}, false
+	}
+	at, err := time.Parse(time.RFC3339Nano, match[1])
+	if err != nil {
+		return coreLogLine{}, false
+	}
+	message := match[3]
+	if unquoted, err := strconv.Unquote(message); err == nil {
+		message = unquoted
+	}
+	return coreLogLine{Time: at, Level: match[2], Message: message, Attrs: match[4]}, true
+}
+
+// parseStoreClock parses a column selected with storeClockColumn
+func parseStoreClock(value string) (time.Time, error) {
+	at, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected timestamp %q in the store: %w", value, err)
+	}
+	return at, nil
+}
+
+// parseStoreTimestamp parses a timestamp column holding UTC, as read by queryStoreJSON
+func parseStoreTimestamp(value string) (time.Time, error) {
+	at, err := time.Parse(storeTimestampLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected timestamp %q in the store: %w", value, err)
+	}
+	return at, nil
+}
+
+// queryTraceWindow reads the stored window with its window type
+func queryTraceWindow(windowID int64) (*traceWindowRow, error) {
+	output, err := queryStoreJSON(fmt.Sprintf(`SELECT
+    w.id,
+    wt.name AS window_type,
+    wt.version AS window_type_version,
+    w.time_from,
+    w.time_to,
+    w.origin,
+    w.metadata,
+    %s AS created
+FROM windows w
+JOIN window_type wt ON wt.id = w.window_type_id
+WHERE w.id = %d`, storeClockColumn("w.created"), windowID))
+	if err != nil {
+		return nil, err
+	}
+	var rows []traceWindowRow
+	if err := json.Unmarshal(output, &rows); err != nil {
+		return nil, fmt.Errorf("failed to read window: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("window %d is not in the store", windowID)
+	}
+	return &rows[0], nil
+}
+
+// queryTraceAlgorithms reads the algorithms of the window's type, which the core
+// dispatches the window to, alongside the results stored for the window. Results
+// of algorithms no longer registered for the window type are included as well.
+func queryTraceAlgorithms(windowID int64) ([]traceAlgorithmRow, error) {
+	output, err := queryStoreJSON(fmt.Sprintf(`SELECT
+    a.name AS algorithm,
+    a.version,
+    p.name AS processor,
+    r.id AS result_id,
+    r.result_value,
+    r.result_array,
+    r.result_json
+FROM windows w
+JOIN algorithm a ON a.window_type_id = w.window_type_id
+JOIN processor p ON p.id = a.processor_id
+LEFT JOIN results r ON r.algorithm_id = a.id AND r.windows_id = w.id
+WHERE w.id = %[1]d
+UNION
+SELECT a.name, a.version, p.name, r.id, r.result_value, r.result_array, r.result_json
+FROM results r
+JOIN algorithm a ON a.id = r.algorithm_id
+JOIN processor p ON p.id = a.processor_id
+WHERE r.windows_id = %[1]d
+ORDER BY processor, algorithm, version, result_id`, windowID))
+	if err != nil {
+		return nil, err
+	}
+	var rows []traceAlgorithmRow
+	if err := json.Unmarshal(output, &rows); err != nil {
+		return nil, fmt.Errorf("failed to read results: %w", err)
+	}
+	return rows, nil
+}
+
+// readCoreLogs returns the warnings and errors the core logged between since and until
+func readCoreLogs(since, until time.Time) ([]coreLogLine, error) {
+	output, err := dockerCommand(
+		"logs",
+		"--since", since.UTC().Format(time.RFC3339),
+		"--until", until.UTC().Format(time.RFC3339),
+		orcaContainerName,
+	).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the logs of %s: %w", orcaContainerName, err)
+	}
+	var lines []coreLogLine
+	for _, line := range strings.Split(string(output), "\n") {
+		if parsed, ok := parseCoreLogLine(line); ok {
+			lines = append(lines, parsed)
+		}
+	}
+	return lines, nil
+}
+
+// summarizeResult describes a stored result in a few words
+func summarizeResult(row traceAlgorithmRow) string {
+	switch {
+	case len(row.ResultJSON) > 0 && string(row.ResultJSON) != "null" && string(row.ResultJSON) != "{}":
+		text := string(row.ResultJSON)
+		if len(text) > 60 {
+			text = text[:57] + "..."
+		}
+		return fmt.Sprintf("result %d: %s", *row.ResultID, text)
+	case len(row.ResultArray) > 0:
+		return fmt.Sprintf("result %d: %d values", *row.ResultID, len(row.ResultArray))
+	case row.ResultValue != nil:
+		return fmt.Sprintf("result %d: %g", *row.ResultID, *row.ResultValue)
+	default:
+		return fmt.Sprintf("result %d: empty", *row.ResultID)
+	}
+}
+
+// buildWindowTrace assembles the timeline of a window. Processor statuses, keyed by
+// processor name, and core log lines are optional.
+func buildWindowTrace(window traceWindowRow, algorithms []traceAlgorithmRow, statuses map[string]processorStatus, logs []coreLogLine) (*windowTrace, error) {
+	trace := &windowTrace{
+		WindowID:          window.ID,
+		WindowType:        window.WindowType,
+		WindowTypeVersion: window.WindowTypeVersion,
+		Origin:            window.Origin,
+		Events:            []traceEvent{},
+	}
+	if len(window.Metadata) > 0 && string(window.Metadata) != "null" {
+		trace.Metadata = window.Metadata
+	}
+	var err error
+	if trace.TimeFrom, err = parseStoreTimestamp(window.TimeFrom); err != nil {
+		return nil, err
+	}
+	if trace.TimeTo, err = parseStoreTimestamp(window.TimeTo); err != nil {
+		return nil, err
+	}
+	if trace.Received, err = parseStoreClock(window.Created); err != nil {
+		return nil, err
+	}
+
+	received := trace.Received
+	trace.Events = append(trace.Events, traceEvent{
+		Time:   &received,
+		Stage:  "received",
+		Detail: fmt.Sprintf("stored as window %d from %s", window.ID, window.Origin),
+	})
+
+	// group the algorithms by processor, in the order the store returned them
+	var processors []string
+	byProcessor := map[string][]traceAlgorithmRow{}
+	for _, row := range algorithms {
+		if _, seen := byProcessor[row.Processor]; !seen {
+			processors = append(processors, row.Processor)
+		}
+		byProcessor[row.Processor] = append(byProcessor[row.Processor], row)
+	}
+
+	for _, processor := range processors {
+		rows := byProcessor[processor]
+		detail := fmt.Sprintf("%d algorithms", len(rows))
+		if status, ok := statuses[processor]; ok {
+			detail += ", processor " + status.Status
+			if status.Message != "" && !status.Reachable {
+				detail += ": " + status.Message
+			}
+		}
+		trace.Events = append(trace.Events, traceEvent{Stage: "dispatched", Processor: processor, Detail: detail})
+
+		for _, row := range rows {
+			algorithm := row.Algorithm + " " + row.Version
+			trace.Expected++
+			if row.ResultID == nil {
+				trace.Events = append(trace.Events, traceEvent{
+					Stage:     "missing",
+					Processor: processor,
+					Algorithm: algorithm,
+					Detail:    "no result stored: the algorithm failed or is still running",
+				})
+				continue
+			}
+			trace.Stored++
+			trace.Events = append(trace.Events, traceEvent{
+				Stage:     "stored",
+				Processor: processor,
+				Algorithm: algorithm,
+				Detail:    summarizeResult(row),
+			})
+		}
+	}
+
+	for _, line := range logs {
+		at := line.Time
+		detail := line.Message
+		if line.Attrs != "" {
+			detail += " " + line.Attrs
+		}
+		stage := "core error"
+		if line.Level == "WARN" {
+			stage = "core warning"
+		}
+		trace.Events = append(trace.Events, traceEvent{Time: &at, Stage: stage, Detail: detail})
+	}
+
+	switch {
+	case trace.Expected == 0:
+		trace.Status = "idle"
+	case trace.Stored >= trace.Expected:
+		trace.Status = "complete"
+	case trace.Stored == 0:
+		trace.Status = "failed"
+	default:
+		trace.Status = "partial"
+	}
+	return trace, nil
+}
+
+// traceWindow traces a window of the local stack. The core's view of its processors
+// and its logs around the window are added when the core is running, with logs
+// read up to logSpan after the window was received.
+func traceWindow(windowID int64, logSpan, timeout time.Duration) (*windowTrace, error) {
+	window, err := queryTraceWindow(windowID)
+	if err != nil {
+		return nil, err
+	}
+	algorithms, err := queryTraceAlgorithms(windowID)
+	if err != nil {
+		return nil, err
+	}
+	received, err := parseStoreClock(window.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses map[string]processorStatus
+	var logs []coreLogLine
+	if address, err := localCoreAddress(); err != nil {
+		fmt.Fprintln(os.Stderr, warningStyle.Render("Skipping processor reachability and core logs: "+err.Error()))
+	} else {
+		if statuses, err = traceProcessorStatus(address, timeout); err != nil {
+			fmt.Fprintln(os.Stderr, warningStyle.Render("Skipping processor reachability: "+err.Error()))
+		}
+		if logSpan > 0 {
+			// allow for clock skew between the store and the docker daemon
+			if logs, err = readCoreLogs(received.Add(-time.Second), received.Add(logSpan)); err != nil {
+				fmt.Fprintln(os.Stderr, warningStyle.Render("Skipping core logs: "+err.Error()))
+			}
+		}
+	}
+
+	return buildWindowTrace(*window, algorithms, statuses, logs)
+}
+
+// traceProcessorStatus probes the processors registered with the core, keyed by name
+func traceProcessorStatus(address string, timeout time.Duration) (map[string]processorStatus, error) {
+	collected, err := collectProcessorStatus(address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[string]processorStatus, len(collected))
+	for _, status := range collected {
+		statuses[status.Name] = status
+	}
+	return statuses, nil
+}
+
+// showWindowTrace prints a window trace as a timeline
+func showWindowTrace(w io.Writer, trace *windowTrace) {
+	fmt.Fprintf(w, "Window %d  %s %s  %s to %s  from %s\n",
+		trace.WindowID, trace.WindowType, trace.WindowTypeVersion,
+		trace.TimeFrom.Format(time.RFC3339), trace.TimeTo.Format(time.RFC3339), trace.Origin)
+	if len(trace.Metadata) > 0 {
+		fmt.Fprintf(w, "Metadata: %s\n", trace.Metadata)
+	}
+	fmt.Fprintln(w)
+
+	rows := [][]string{{"TIME", "STAGE", "PROCESSOR", "ALGORITHM", "DETAIL"}}
+	for _, event := range trace.Events {
+		at := "-"
+		if event.Time != nil {
+			at = event.Time.UTC().Format("2006-01-02 15:04:05.000")
+		}
+		rows = append(rows, []string{
+			at,
+			renderStdout(statusColor(traceStageColor(event.Stage)), event.Stage),
+			event.Processor,
+			event.Algorithm,
+			event.Detail,
+		})
+	}
+	printTable(w, rows)
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Status: %s, %d of %d results stored\n",
+		renderStdout(statusColor(traceStageColor(trace.Status)), trace.Status), trace.Stored, trace.Expected)
+}
+
+// traceStageColor maps trace stages and statuses onto the container status colors
+func traceStageColor(stage string) string {
+	switch stage {
+	case "received", "dispatched", "stored", "complete":
+		return "running"
+	case "core warning", "partial", "idle":
+		return "stopped"
+	default:
+		return stage
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildWindowTrace(t *testing.T) {
+	line := `time=2025-01-01T10:00:01.500Z level=ERROR msg="issue contacting processor" processor=stats`
+	logged, ok := parseCoreLogLine(line)
+	if !ok || logged.Message != "issue contacting processor" || logged.Attrs != "processor=stats" {
+		t.Fatalf("parseCoreLogLine(%q) = %+v, %v", line, logged, ok)
+	}
+	if _, ok := parseCoreLogLine(`time=2025-01-01T10:00:01Z level=INFO msg="emitting window"`); ok {
+		t.Errorf("parseCoreLogLine kept an info line")
+	}
+
+	resultID := int64(7)
+	value := 1.5
+	window := traceWindowRow{
+		ID:                3,
+		WindowType:        "Hourly",
+		WindowTypeVersion: "1.0.0",
+		TimeFrom:          "2025-01-01T09:00:00",
+		TimeTo:            "2025-01-01T10:00:00",
+		Origin:            "sensor",
+		// received by a store in Europe/Paris, an hour ahead of UTC in winter
+		Created: "2025-01-01T11:00:00.25+01:00",
+	}
+	algorithms := []traceAlgorithmRow{
+		{Algorithm: "Mean", Version: "1.0.0", Processor: "stats", ResultID: &resultID, ResultValue: &value},
+		{Algorithm: "Max", Version: "1.0.0", Processor: "stats"},
+	}
+	statuses := map[string]processorStatus{"stats": {Name: "stats", Status: "serving", Reachable: true}}
+
+	trace, err := buildWindowTrace(window, algorithms, statuses, []coreLogLine{logged})
+	if err != nil {
+		t.Fatalf("buildWindowTrace: %v", err)
+	}
+	if trace.Status != "partial" || trace.Stored != 1 || trace.Expected != 2 {
+		t.Errorf("status = %s with %d of %d stored, want partial with 1 of 2", trace.Status, trace.Stored, trace.Expected)
+	}
+	if want := time.Date(2025, 1, 1, 10, 0, 0, 250_000_000, time.UTC); !trace.Received.Equal(want) {
+		t.Errorf("received = %s, want %s", trace.Received, want)
+	}
+
+	var stages []string
+	for _, event := range trace.Events {
+		stages = append(stages, event.Stage)
+	}
+	want := []string{"received", "dispatched", "stored", "missing", "core error"}
+	if len(stages) != len(want) {
+		t.Fatalf("stages = %v, want %v", stages, want)
+	}
+	for ii := range want {
+		if stages[ii] != want[ii] {
+			t.Fatalf("stages = %v, want %v", stages, want)
+		}
+	}
+	if detail := trace.Events[2].Detail; detail != "result 7: 1.5" {
+		t.Errorf("stored detail = %q", detail)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	latestReleaseURL    = "https://api.github.com/repos/orca-telemetry/cli/releases/latest"
+	installScriptURL    = "https://raw.githubusercontent.com/orca-telemetry/cli/main/install-cli.sh"
+	updateCheckInterval = time.Hour * 24
+	updateCheckTimeout  = time.Second * 3
+	// how long a command waits on exit for an in-flight check before giving up on it
+	updateCheckExitWait = time.Millisecond * 500
+)
+
+// updateCheckCache records the latest release seen, so the release API is
+// queried at most once per updateCheckInterval
+type updateCheckCache struct {
+	CheckedAt     time.Time `json:"checkedAt"`
+	LatestVersion string    `json:"latestVersion"`
+}
+
+func updateCheckCachePath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "update-check.json"), nil
+}
+
+func readUpdateCheckCache() (*updateCheckCache, error) {
+	path, err := updateCheckCachePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cache := &updateCheckCache{}
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+func writeUpdateCheckCache(cache *updateCheckCache) error {
+	path, err := updateCheckCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// updateCheckDisabledByEnv honours ORCA_NO_UPDATE_CHECK, and skips the check in CI
+func updateCheckDisabledByEnv() bool {
+	return os.Getenv("ORCA_NO_UPDATE_CHECK") != "" || os.Getenv("CI") != ""
+}
+
+// fetchLatestVersion asks the release API for the tag of the latest CLI release
+func fetchLatestVersion() (string, error) {
+	client := &http.Client{Timeout: updateCheckTimeout}
+	req, err := http.NewRequest(http.MethodGet, latestReleaseURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("release API returned %s", resp.Status)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", fmt.Errorf("failed to parse release: %w", err)
+	}
+	return release.TagName, nil
+}
+
+// parseVersion splits a version such as v1.2.3 into its numeric parts,
+// ignoring any pre-release or build suffix
+func parseVersion(version string) ([]int, bool) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	version, _, _ = strings.Cut(version, "-")
+	version, _, _ = strings.Cut(version, "+")
+
+	var parts []int
+	for _, field := range strings.Split(version, ".") {
+		number, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, false
+		}
+		parts = append(parts, number)
+	}
+	return parts, len(parts) > 0
+}
+
+// isNewerVersion reports whether latest is a newer release than current.
+// Unparseable versions, such as dev builds, are never considered outdated.
+func isNewerVersion(latest, current string) bool {
+	latestParts, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	currentParts, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+
+	for ii := 0; ii < max(len(latestParts), len(currentParts)); ii++ {
+		var l, c int
+		if ii < len(latestParts) {
+			l = latestParts[ii]
+		}
+		if ii < len(currentParts) {
+			c = currentParts[ii]
+		}
+		if l != c {
+			return l > c
+		}
+	}
+	return false
+}
+
+// initUpdateCheck prints a one-line notice on exit when a newer CLI release is
+// available. The release API is only queried when the cached result is older
+// than a day, in the background so commands are never slowed down by it.
+func initUpdateCheck() {
+	if _, ok := parseVersion(Version); !ok || updateCheckDisabledByEnv() || !isTerminal(os.Stderr) {
+		return
+	}
+	config, err := loadGlobalConfig()
+	if err != nil || config.UpdateCheck.Disabled {
+		return
+	}
+
+	latest := make(chan string, 1)
+	cache, err := readUpdateCheckCache()
+	if err == nil && time.Since(cache.CheckedAt) < updateCheckInterval {
+		latest <- cache.LatestVersion
+	} else {
+		go func() {
+			version, err := fetchLatestVersion()
+			if err != nil && cache != nil {
+				// keep the last known release, but still wait a day before retrying
+				version = cache.LatestVersion
+			}
+			writeUpdateCheckCache(&updateCheckCache{CheckedAt: time.Now().UTC(), LatestVersion: version})
+			latest <- version
+		}()
+	}
+
+	onExit(func(code int) {
+		select {
+		case version := <-latest:
+			if isNewerVersion(version, Version) {
+				fmt.Fprintln(os.Stderr, dimStyle.Render(fmt.Sprintf(
+					"A new version of the Orca CLI is available: %s (current %s). Update with: curl -fsSL %s | bash",
+					version,
+					Version,
+					installScriptURL,
+				)))
+			}
+		case <-time.After(updateCheckExitWait):
+		}
+	})
+}
+
+func showUpdateCheckStatus(config *GlobalConfig) {
+	switch {
+	case updateCheckDisabledByEnv():
+		fmt.Fprintln(os.Stderr, "Update notices:", warningStyle.Render("disabled by environment (ORCA_NO_UPDATE_CHECK / CI)"))
+	case config.UpdateCheck.Disabled:
+		fmt.Fprintln(os.Stderr, "Update notices:", warningStyle.Render("disabled"))
+	default:
+		fmt.Fprintln(os.Stderr, "Update notices:", successStyle.Render("enabled"))
+	}
+
+	fmt.Fprintln(os.Stderr, "Current version: "+Version)
+	if cache, err := readUpdateCheckCache(); err == nil && cache.LatestVersion != "" {
+		fmt.Fprintf(os.Stderr, "Latest version: %s (checked %s)\n", cache.LatestVersion, cache.CheckedAt.Local().Format(time.RFC1123))
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// upgradeRecord is the last upgrade of the core of the stack project, which
+// `orca upgrade -rollback` reverts
+type upgradeRecord struct {
+	// Previous is the image the core ran before the upgrade
+	Previous string `json:"previous"`
+	Image    string `json:"image"`
+	// Snapshot holds the data of the stack from before the upgrade
+	Snapshot string    `json:"snapshot"`
+	At       time.Time `json:"at"`
+}
+
+// upgradeRecordPath returns where the last upgrade of the stack project is recorded
+func upgradeRecordPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "upgrades", projectLabelValue(stackProject)+".json"), nil
+}
+
+func readUpgradeRecord() (*upgradeRecord, error) {
+	path, err := upgradeRecordPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("there is no upgrade to roll back")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var record upgradeRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &record, nil
+}
+
+func writeUpgradeRecord(record *upgradeRecord) error {
+	path, err := upgradeRecordPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(record, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func removeUpgradeRecord() error {
+	path, err := upgradeRecordPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// upgradeSnapshotName names the snapshot taken before an upgrade
+func upgradeSnapshotName(at time.Time) string {
+	return "pre-upgrade-" + at.UTC().Format("20060102-150405")
+}
+
+// coreImage returns the image the core container was created with
+func coreImage() (string, error) {
+	output, err := dockerCommand("inspect", "--format", "{{.Config.Image}}", orcaContainerName).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s does not exist, start the stack with `orca start` first", orcaContainerName)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// recreateCore replaces the core container with one running image, on the port it
+// is published on, and waits for it to migrate the store and answer its health check
+func recreateCore(config *OrcaConfigFile, configPath, image string) error {
+	orcaEnv, err := coreEnv(config.Core, filepath.Dir(configPath))
+	if err != nil {
+		return err
+	}
+	orcaPort, err := config.orcaHostPort()
+	if err != nil {
+		return err
+	}
+	if orcaPort == 0 {
+		// keep the port processors and clients already connect to
+		orcaPort, _ = getPublishedHostPort(orcaContainerName, orcaInternalPort)
+	}
+
+	if output, err := dockerCommand("rm", "-f", orcaContainerName).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove %s: %w: %s", orcaContainerName, err, strings.TrimSpace(string(output)))
+	}
+	stackImages[componentCore] = image
+	started := time.Now()
+	startOrca(networkName, orcaEnv, orcaPort)
+	fmt.Fprintln(os.Stderr, "Waiting for Orca core to become ready...")
+	return followCoreStartup(orcaContainerName, started)
+}
+
+// upgradeCore snapshots the data of the stack and recreates the core with image,
+// recording the upgrade first so that it can be rolled back even when the new core
+// fails to start
+func upgradeCore(config *OrcaConfigFile, configPath, previous, image string) (*upgradeRecord, error) {
+	fmt.Fprintf(os.Stderr, "Pulling %s...\n", image)
+	if err := streamDockerRetry("Pull:", "pull", image); err != nil {
+		return nil, fmt.Errorf("failed to pull %s: %w", image, err)
+	}
+
+	record := &upgradeRecord{Previous: previous, Image: image, At: time.Now().UTC()}
+	record.Snapshot = upgradeSnapshotName(record.At)
+	if err := createSnapshot(record.Snapshot); err != nil {
+		return nil, fmt.Errorf("failed to snapshot the stack before upgrading: %w", err)
+	}
+	if err := writeUpgradeRecord(record); err != nil {
+		return nil, err
+	}
+	return record, recreateCore(config, configPath, image)
+}
+
+// rollbackUpgrade recreates the core with the image it ran before the upgrade and
+// restores the data from before the upgrade. The core is removed first, so that it
+// does not write to the store while it is restored.
+func rollbackUpgrade(config *OrcaConfigFile, configPath string, record *upgradeRecord) error {
+	if _, ok := findSnapshot(record.Snapshot); !ok {
+		return fmt.Errorf("snapshot %q taken before the upgrade no longer exists", record.Snapshot)
+	}
+	if output, err := dockerCommand("rm", "-f", orcaContainerName).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove %s: %w: %s", orcaContainerName, err, strings.TrimSpace(string(output)))
+	}
+	if err := restoreSnapshot(record.Snapshot); err != nil {
+		return err
+	}
+	if err := waitForStore(); err != nil {
+		return err
+	}
+	if err := recreateCore(config, configPath, record.Previous); err != nil {
+		return err
+	}
+	return removeUpgradeRecord()
+}
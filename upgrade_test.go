@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUpgradeRecord(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	if _, err := readUpgradeRecord(); err == nil {
+		t.Fatal("readUpgradeRecord succeeded before any upgrade")
+	}
+
+	at := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	record := &upgradeRecord{
+		Previous: "ghcr.io/orca-telemetry/core:0.13.0",
+		Image:    "ghcr.io/orca-telemetry/core:0.14.2",
+		Snapshot: upgradeSnapshotName(at),
+		At:       at,
+	}
+	if err := validateSnapshotName(record.Snapshot); err != nil {
+		t.Errorf("upgradeSnapshotName: %v", err)
+	}
+	if record.Snapshot != "pre-upgrade-20260304-050607" {
+		t.Errorf("upgradeSnapshotName = %q", record.Snapshot)
+	}
+
+	if err := writeUpgradeRecord(record); err != nil {
+		t.Fatalf("writeUpgradeRecord: %v", err)
+	}
+	got, err := readUpgradeRecord()
+	if err != nil {
+		t.Fatalf("readUpgradeRecord: %v", err)
+	}
+	if *got != *record {
+		t.Errorf("readUpgradeRecord = %+v, want %+v", got, record)
+	}
+
+	if err := removeUpgradeRecord(); err != nil {
+		t.Fatalf("removeUpgradeRecord: %v", err)
+	}
+	if _, err := readUpgradeRecord(); err == nil {
+		t.Error("the upgrade can still be rolled back once removed")
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// containerState holds the subset of `docker inspect` state used by the watchdog
+type containerState struct {
+	Status    string
+	ExitCode  int
+	OOMKilled bool
+}
+
+// getContainerState inspects a container and returns its current state
+func getContainerState(containerName string) (containerState, error) {
+	cmd := dockerCommand(
+		"inspect",
+		"--format",
+		"{{.State.Status}} {{.State.ExitCode}} {{.State.OOMKilled}}",
+		containerName,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		return containerState{}, fmt.Errorf("failed to inspect %s: %w", containerName, err)
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) != 3 {
+		return containerState{}, fmt.Errorf("unexpected inspect output for %s: %q", containerName, output)
+	}
+
+	exitCode, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return containerState{}, fmt.Errorf("failed to parse exit code for %s: %w", containerName, err)
+	}
+
+	return containerState{
+		Status:    fields[0],
+		ExitCode:  exitCode,
+		OOMKilled: fields[2] == "true",
+	}, nil
+}
+
+// exit codes of a process ended by SIGKILL and SIGTERM, as `docker stop` does
+const (
+	exitCodeSIGKILL = 128 + int(syscall.SIGKILL)
+	exitCodeSIGTERM = 128 + int(syscall.SIGTERM)
+)
+
+// crashed reports whether the container exited unexpectedly. A clean exit (code 0),
+// or one by SIGTERM or SIGKILL that was not the OOM killer, is treated as a
+// deliberate stop, e.g. via `orca stop` or `docker stop`.
+func (s containerState) crashed() bool {
+	if s.Status != "exited" && s.Status != "dead" {
+		return false
+	}
+	if s.OOMKilled || s.Status == "dead" {
+		return true
+	}
+	return s.ExitCode != 0 && s.ExitCode != exitCodeSIGKILL && s.ExitCode != exitCodeSIGTERM
+}
+
+// watchReport tracks restarts performed by the watchdog for each container
+type watchReport struct {
+	restarts map[string]int
+	failures map[string]int
+	gaveUp   map[string]bool
+}
+
+func newWatchReport() *watchReport {
+	return &watchReport{
+		restarts: make(map[string]int),
+		failures: make(map[string]int),
+		gaveUp:   make(map[string]bool),
+	}
+}
+
+func (r *watchReport) print() {
+	fmt.Fprintln(os.Stderr, "Watchdog report:")
+	for _, containerName := range orcaContainers {
+		line := fmt.Sprintf(
+			"  %s: %d restart(s), %d failed restart(s)",
+			containerName,
+			r.restarts[containerName],
+			r.failures[containerName],
+		)
+		if r.gaveUp[containerName] {
+			printError(line + " - gave up")
+		} else if r.restarts[containerName] > 0 {
+			fmt.Fprintln(os.Stderr, warningStyle.Render(line))
+		} else {
+			fmt.Fprintln(os.Stderr, renderSuccess(line))
+		}
+	}
+}
+
+// watchContainers polls the Orca containers and restarts any that exit unexpectedly.
+// Each container is restarted at most maxRestarts times before the watchdog gives up
+// on it. The loop runs until interrupted, after which a report is printed.
+func watchContainers(interval time.Duration, maxRestarts int) {
+	report := newWatchReport()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	fmt.Fprintf(os.Stderr,
+		"Watching Orca containers every %s (max %d restarts per container). Press Ctrl+C to stop.\n",
+		interval,
+		maxRestarts,
+	)
+
+	for {
+		for _, containerName := range orcaContainers {
+			if report.gaveUp[containerName] {
+				continue
+			}
+
+			state, err := getContainerState(containerName)
+			if err != nil || !state.crashed() {
+				continue
+			}
+
+			if report.restarts[containerName] >= maxRestarts {
+				report.gaveUp[containerName] = true
+				printError(fmt.Sprintf(
+					"%s has crashed %d times, giving up on restarting it",
+					containerName,
+					report.restarts[containerName]+1,
+				))
+				continue
+			}
+
+			fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf(
+				"[%s] %s exited unexpectedly (exit code %d, OOM killed: %t). Restarting...",
+				time.Now().Format(time.TimeOnly),
+				containerName,
+				state.ExitCode,
+				state.OOMKilled,
+			)))
+
+			report.restarts[containerName]++
+			if err := dockerCommand("start", containerName).Run(); err != nil {
+				report.failures[containerName]++
+				printError(fmt.Sprintf("Failed to restart %s: %v", containerName, err))
+				continue
+			}
+			fmt.Fprintln(os.Stderr, renderSuccess(fmt.Sprintf("%s restarted", containerName)))
+		}
+
+		select {
+		case <-sigs:
+			fmt.Fprintln(os.Stderr)
+			report.print()
+			return
+		case <-ticker.C:
+		}
+	}
+}
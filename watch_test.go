@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestContainerStateCrashed(t *testing.T) {
+	for _, test := range []struct {
+		state containerState
+		want  bool
+	}{
+		{containerState{Status: "running"}, false},
+		{containerState{Status: "exited", ExitCode: 0}, false},
+		{containerState{Status: "exited", ExitCode: 1}, true},
+		// docker stop sends SIGTERM, then SIGKILL after its timeout
+		{containerState{Status: "exited", ExitCode: 143}, false},
+		{containerState{Status: "exited", ExitCode: 137}, false},
+		{containerState{Status: "exited", ExitCode: 137, OOMKilled: true}, true},
+		{containerState{Status: "dead"}, true},
+	} {
+		if got := test.state.crashed(); got != test.want {
+			t.Errorf("%+v crashed() = %t, want %t", test.state, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/orca-telemetry/core/protobufs/go"
+)
+
+// workspaceFileName lists the projects of a monorepo, for commands run with -all-projects
+const workspaceFileName = "orca.workspace.json"
+
+// Workspace is the content of orca.workspace.json
+type Workspace struct {
+	// Projects are directories holding an orca.json, relative to the workspace file.
+	// Glob patterns such as processors/* are expanded.
+	Projects []string `json:"projects"`
+}
+
+// findWorkspace returns the nearest orca.workspace.json in the working directory or
+// its parents
+func findWorkspace() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for dir := cwd; ; dir = filepath.Dir(dir) {
+		path := filepath.Join(dir, workspaceFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		if filepath.Dir(dir) == dir {
+			return "", fmt.Errorf("no %s found in this directory or its parents", workspaceFileName)
+		}
+	}
+}
+
+// workspaceProjects reads a workspace file and returns the directories of its
+// projects, in the order listed. Patterns only match directories holding an
+// orca.json, while a project named outright must have one.
+func workspaceProjects(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var workspace Workspace
+	if err := json.Unmarshal(data, &workspace); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	root := filepath.Dir(path)
+	var dirs []string
+	for _, project := range workspace.Projects {
+		pattern := filepath.Join(root, filepath.FromSlash(project))
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid project %q in %s: %w", project, path, err)
+		}
+		isPattern := strings.ContainsAny(project, "*?[")
+		if len(matches) == 0 && !isPattern {
+			return nil, fmt.Errorf("project %q in %s does not exist", project, path)
+		}
+		for _, dir := range matches {
+			if _, err := os.Stat(filepath.Join(dir, defaultConfigPath)); err != nil {
+				if isPattern {
+					continue
+				}
+				return nil, fmt.Errorf("project %q in %s has no %s", project, path, defaultConfigPath)
+			}
+			if !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("%s lists no projects", path)
+	}
+	return dirs, nil
+}
+
+// withoutFlag removes a boolean flag, in any of its spellings, from arguments
+func withoutFlag(args []string, name string) []string {
+	var kept []string
+	for _, arg := range args {
+		flagName, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if strings.HasPrefix(arg, "-") && flagName == name {
+			continue
+		}
+		kept = append(kept, arg)
+	}
+	return kept
+}
+
+// runAllProjects runs a command of the CLI in each project of the workspace, with
+// the same arguments, so each run finds the orca.json of its project. Every project
+// is run even when one fails, and the failures are reported at the end.
+func runAllProjects(command string, args []string) int {
+	workspacePath, err := findWorkspace()
+	if err != nil {
+		printError(err.Error())
+		return 1
+	}
+	dirs, err := workspaceProjects(workspacePath)
+	if err != nil {
+		printError(err.Error())
+		return 1
+	}
+
+	args = append([]string{command}, withoutFlag(args, "all-projects")...)
+	var failed []string
+	for _, dir := range dirs {
+		fmt.Fprintln(os.Stderr, renderStdout(successStyle, fmt.Sprintf("==> %s", workspaceRelative(workspacePath, dir))))
+		if err := runOrcaIn(dir, args...); err != nil {
+			failed = append(failed, workspaceRelative(workspacePath, dir))
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+	if len(failed) > 0 {
+		printError(fmt.Sprintf("orca %s failed in %d of %d projects: %s", command, len(failed), len(dirs), strings.Join(failed, ", ")))
+		return 1
+	}
+	return 0
+}
+
+func workspaceRelative(workspacePath, dir string) string {
+	if rel, err := filepath.Rel(filepath.Dir(workspacePath), dir); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return dir
+}
+
+// workspaceProjectStatus is the state of a workspace project, as reported by
+// `orca status -all-projects -o`
+type workspaceProjectStatus struct {
+	Dir     string `json:"dir"`
+	Project string `json:"project"`
+	// Deployed is the status of the container of `orca deploy`, or not found
+	Deployed string `json:"deployed"`
+	// Algorithms is the number of algorithms the project registered, or -1 when the
+	// registry could not be read
+	Algorithms int    `json:"algorithms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// collectWorkspaceStatus reports the deployment and registration of each project of
+// the workspace, reading the registry from the daemon, or else once from the local core
+func collectWorkspaceStatus(timeout time.Duration) ([]workspaceProjectStatus, error) {
+	workspacePath, err := findWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := workspaceProjects(workspacePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var state *pb.InternalState
+	if address, err := localCoreAddress(); err == nil {
+		if conn, client, err := dialCore(address); err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			state, _ = exposeRegistry(ctx, client, address, &pb.ExposeSettings{})
+			cancel()
+			conn.Close()
+		}
+	}
+
+	statuses := make([]workspaceProjectStatus, 0, len(dirs))
+	for _, dir := range dirs {
+		status := workspaceProjectStatus{Dir: workspaceRelative(workspacePath, dir), Algorithms: -1}
+		config, err := readProjectConfig(filepath.Join(dir, defaultConfigPath))
+		if err != nil {
+			status.Error = err.Error()
+			statuses = append(statuses, status)
+			continue
+		}
+		status.Project = config.ProjectName
+		status.Deployed = getContainerStatus(processorContainerName(config.ProjectName))
+		if state != nil {
+			algorithms, _, err := projectAlgorithms(state, config.ProjectName)
+			if err != nil {
+				status.Error = err.Error()
+			} else {
+				status.Algorithms = len(algorithms)
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// showWorkspaceStatus prints the workspace project statuses as a table
+func showWorkspaceStatus(w io.Writer, statuses []workspaceProjectStatus) {
+	rows := [][]string{{"DIR", "PROJECT", "DEPLOYED", "ALGORITHMS", "DETAIL"}}
+	for _, status := range statuses {
+		algorithms := "-"
+		if status.Algorithms >= 0 {
+			algorithms = strconv.Itoa(status.Algorithms)
+		}
+		deployed := status.Deployed
+		if deployed != "" {
+			deployed = renderStdout(statusColor(deployed), deployed)
+		}
+		rows = append(rows, []string{status.Dir, status.Project, deployed, algorithms, status.Error})
+	}
+	printTable(w, rows)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWorkspaceProjects(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"api", "processors/speed", "processors/routes", "processors/shared"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dir := range []string{"api", "processors/speed", "processors/routes"} {
+		if err := os.WriteFile(filepath.Join(root, dir, defaultConfigPath), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(root, workspaceFileName)
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// patterns skip directories without an orca.json, and projects are listed once
+	write(`{"projects": ["processors/*", "processors/speed", "api"]}`)
+	dirs, err := workspaceProjects(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rel []string
+	for _, dir := range dirs {
+		rel = append(rel, workspaceRelative(path, dir))
+	}
+	if want := []string{"processors/routes", "processors/speed", "api"}; !slices.Equal(rel, want) {
+		t.Errorf("workspaceProjects = %v, want %v", rel, want)
+	}
+
+	// a project named outright must be one
+	write(`{"projects": ["processors/shared"]}`)
+	if _, err := workspaceProjects(path); err == nil {
+		t.Errorf("workspaceProjects with a directory without orca.json succeeded")
+	}
+
+	t.Chdir(filepath.Join(root, "processors", "speed"))
+	if found, err := findWorkspace(); err != nil || found != path {
+		t.Errorf("findWorkspace = %q, %v", found, err)
+	}
+
+	if args := withoutFlag([]string{"-all-projects", "-out", "stubs", "--all-projects=true", "verify"}, "all-projects"); !slices.Equal(args, []string{"-out", "stubs", "verify"}) {
+		t.Errorf("withoutFlag = %v", args)
+	}
+}
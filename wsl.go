@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// isWSL reports whether the CLI runs inside a WSL2 distribution
+func isWSL() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	return err == nil && strings.Contains(strings.ToLower(string(data)), "microsoft")
+}
+
+// wslWindowsHostIP returns the address of the Windows host as seen from WSL2,
+// which is the distribution's default gateway
+func wslWindowsHostIP() string {
+	output, err := exec.Command("ip", "route", "show", "default").Output()
+	if err == nil {
+		// default via 172.22.96.1 dev eth0 ...
+		fields := strings.Fields(string(output))
+		for ii := 0; ii+1 < len(fields); ii++ {
+			if fields[ii] == "via" && net.ParseIP(fields[ii+1]) != nil {
+				return fields[ii+1]
+			}
+		}
+	}
+
+	// fall back to the nameserver WSL generates, which points at the host
+	file, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// wslDistroIP returns the address of this WSL2 distribution on its virtual network
+func wslDistroIP() string {
+	output, err := exec.Command("hostname", "-I").Output()
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// withPlatformGuidance adjusts the runtime guidance for Windows and WSL2. Under
+// WSL2 the engine runs either in Docker Desktop on Windows or natively inside
+// the distribution, which changes how processors are reached.
+func withPlatformGuidance(detected containerRuntime) containerRuntime {
+	if runtime.GOOS == "windows" {
+		detected.Notes = append(detected.Notes,
+			"Processors must be allowed through the Windows firewall to be reachable from the core.",
+		)
+		return detected
+	}
+	if !isWSL() {
+		return detected
+	}
+
+	detected.Name += " on WSL2"
+	detected.Notes = nil
+	if strings.HasPrefix(detected.Name, dockerDesktopRuntime.Name) {
+		// host.docker.internal reaches Windows, whose localhost is forwarded into WSL
+		detected.Notes = append(detected.Notes,
+			"Processors in WSL are reached through Windows localhost forwarding. If it is disabled in .wslconfig, "+
+				"forward the processor port instead with `orca init -wsl-portproxy` (requires an elevated prompt).",
+		)
+		return detected
+	}
+
+	detected.Notes = append(detected.Notes,
+		"Processors in this WSL distribution must listen on 0.0.0.0 to be reachable from the core.",
+	)
+	if hostIP := wslWindowsHostIP(); hostIP != "" {
+		detected.Notes = append(detected.Notes, fmt.Sprintf(
+			"Processors running on Windows are reached at %s:<processor-port> and must be allowed through the Windows firewall.",
+			hostIP,
+		))
+	}
+	return detected
+}
+
+// wslPortProxyCommand returns the Windows command forwarding a port on the Windows
+// host to the same port in this WSL2 distribution
+func wslPortProxyCommand(port int) ([]string, error) {
+	distroIP := wslDistroIP()
+	if distroIP == "" {
+		return nil, fmt.Errorf("could not determine the WSL distribution's address")
+	}
+	return []string{
+		"netsh.exe", "interface", "portproxy", "add", "v4tov4",
+		fmt.Sprintf("listenport=%d", port),
+		"listenaddress=0.0.0.0",
+		fmt.Sprintf("connectport=%d", port),
+		"connectaddress=" + distroIP,
+	}, nil
+}
+
+// setupWSLPortProxy forwards the processor port from Windows into WSL2, printing
+// the command to run by hand if it cannot be run from here
+func setupWSLPortProxy(port int) error {
+	command, err := wslPortProxyCommand(port)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Forwarding Windows port %d into WSL... ", port)
+	output, err := exec.Command(command[0], command[1:]...).CombinedOutput()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, renderError("FAILED"))
+		fmt.Fprintln(os.Stderr, "Run this from an elevated (administrator) Windows prompt instead:")
+		fmt.Fprintln(os.Stderr, "  "+strings.Join(command, " "))
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	fmt.Fprintln(os.Stderr, renderSuccess("DONE"))
+	return nil
+}